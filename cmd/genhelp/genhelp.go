@@ -0,0 +1,292 @@
+// Genhelp writes the help pages of the got commands, for got help. It reads
+// the commands in the package it runs in: the name from newCommand, the
+// synopsis from the "usage: got" errors, the options from the flags they
+// define, also in the functions they call, and the examples from the
+// Examples section of the doc comment of the command.
+//
+// It is run by go generate in pkg/command.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// option is a flag with its aliases
+type option struct {
+	names []string
+	arg   string
+	usage string
+}
+
+// page is what genhelp finds out about a command
+type page struct {
+	name     string
+	synopsis []string
+	options  []*option
+	examples []string
+}
+
+func main() {
+	output := flag.String("o", "help_pages.go", "The file to write")
+	flag.Parse()
+	source, err := generate(".", *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the help pages of the commands in dir, leaving out the
+// file they are written to
+func generate(dir, output string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != output
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	funcs := map[string]*ast.FuncDecl{}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+					funcs[fn.Name.Name] = fn
+				}
+			}
+		}
+	}
+
+	pages := []*page{}
+	for _, fn := range funcs {
+		if name := commandName(fn); name != "" {
+			pages = append(pages, readPage(fn, name, funcs))
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].name < pages[j].name })
+	return format.Source(render(pages))
+}
+
+// commandName returns the name a function passes to newCommand, or "" if
+// it doesn't create a command
+func commandName(fn *ast.FuncDecl) string {
+	name := ""
+	ast.Inspect(fn, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if ok && name == "" && isIdent(call.Fun, "newCommand") && len(call.Args) == 1 {
+			name, _ = stringValue(call.Args[0])
+		}
+		return name == ""
+	})
+	return name
+}
+
+// readPage finds the synopsis and options of the command that fn creates,
+// in fn and the functions it calls
+func readPage(fn *ast.FuncDecl, name string, funcs map[string]*ast.FuncDecl) *page {
+	p := &page{name: name, examples: examples(fn.Doc)}
+	byName := map[string]*option{}
+	aliases := map[string]string{}
+	visited := map[string]bool{}
+	var read func(fn *ast.FuncDecl)
+	read = func(fn *ast.FuncDecl) {
+		if visited[fn.Name.Name] {
+			return
+		}
+		visited[fn.Name.Name] = true
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BasicLit:
+				value, _ := stringValue(n)
+				if usage, ok := strings.CutPrefix(value, "usage: "); ok && (usage == "got "+name || strings.HasPrefix(usage, "got "+name+" ")) {
+					for _, line := range strings.Split(usage, "\n") {
+						if line = strings.TrimSpace(line); !slices.Contains(p.synopsis, line) {
+							p.synopsis = append(p.synopsis, line)
+						}
+					}
+				}
+			case *ast.CallExpr:
+				if ident, ok := n.Fun.(*ast.Ident); ok && funcs[ident.Name] != nil && commandName(funcs[ident.Name]) == "" {
+					read(funcs[ident.Name])
+				}
+				flagName, arg, usage, ok := flagCall(n)
+				if !ok || byName[flagName] != nil || aliases[flagName] != "" {
+					return true
+				}
+				if target, ok := strings.CutPrefix(usage, "Same as -"); ok {
+					target = strings.TrimLeft(target, "-")
+					if opt := byName[target]; opt != nil {
+						opt.names = append(opt.names, flagName)
+					} else {
+						aliases[target] = flagName
+					}
+					return true
+				}
+				opt := &option{names: []string{flagName}, arg: arg, usage: usage}
+				if alias := aliases[flagName]; alias != "" {
+					opt.names = append(opt.names, alias)
+				}
+				byName[flagName] = opt
+				p.options = append(p.options, opt)
+			}
+			return true
+		})
+	}
+	read(fn)
+
+	for _, opt := range p.options {
+		// Short names go first, like in git
+		sort.SliceStable(opt.names, func(i, j int) bool { return len(opt.names[i]) < len(opt.names[j]) })
+		for i, flagName := range opt.names {
+			if len(flagName) == 1 {
+				opt.names[i] = "-" + flagName
+			} else {
+				opt.names[i] = "--" + flagName
+			}
+		}
+	}
+	sort.SliceStable(p.options, func(i, j int) bool {
+		return strings.TrimLeft(p.options[i].names[0], "-") < strings.TrimLeft(p.options[j].names[0], "-")
+	})
+	return p
+}
+
+// flagCall returns the name, argument and usage of a flag that a call to
+// the flag package defines
+func flagCall(call *ast.CallExpr) (string, string, string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isIdent(sel.X, "flag") {
+		return "", "", "", false
+	}
+	method := sel.Sel.Name
+	nameArg, usageArg := 0, len(call.Args)-1
+	switch {
+	case method == "Var" || strings.HasSuffix(method, "Var"):
+		nameArg = 1
+	case method == "Func" || method == "BoolFunc":
+		usageArg = 1
+	}
+	if method == "TextVar" {
+		usageArg = 3
+	}
+	if len(call.Args) <= max(nameArg, usageArg) {
+		return "", "", "", false
+	}
+	name, ok := stringValue(call.Args[nameArg])
+	if !ok {
+		return "", "", "", false
+	}
+	usage, ok := stringValue(call.Args[usageArg])
+	if !ok {
+		return "", "", "", false
+	}
+
+	// Like flag.UnquoteUsage, a name in back quotes is the argument,
+	// otherwise it is named after the type
+	typ := strings.TrimSuffix(strings.TrimSuffix(method, "Var"), "Func")
+	arg := ""
+	switch typ {
+	case "Bool":
+		return name, "", usage, true
+	case "", "Text":
+		arg = "value"
+	default:
+		arg = strings.ToLower(typ)
+	}
+	if start := strings.Index(usage, "`"); start >= 0 {
+		if end := strings.Index(usage[start+1:], "`"); end >= 0 {
+			arg = usage[start+1 : start+1+end]
+			usage = usage[:start] + arg + usage[start+2+end:]
+		}
+	}
+	return name, arg, usage, true
+}
+
+// examples returns the lines after Examples: in a doc comment
+func examples(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	_, text, ok := strings.Cut(doc.Text(), "Examples:\n")
+	if !ok {
+		return nil
+	}
+	lines := []string{}
+	for _, line := range strings.Split(strings.Trim(text, "\n"), "\n") {
+		lines = append(lines, strings.TrimPrefix(line, "\t"))
+	}
+	return lines
+}
+
+// stringValue returns the value of a string literal, or of literals that
+// are added together
+func stringValue(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind != token.STRING {
+			return "", false
+		}
+		value, err := strconv.Unquote(expr.Value)
+		return value, err == nil
+	case *ast.BinaryExpr:
+		if expr.Op != token.ADD {
+			return "", false
+		}
+		x, ok := stringValue(expr.X)
+		if !ok {
+			return "", false
+		}
+		y, ok := stringValue(expr.Y)
+		return x + y, ok
+	}
+	return "", false
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+// render returns the Go source of the pages
+func render(pages []*page) []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by genhelp; DO NOT EDIT.\n\npackage command\n\n")
+	b.WriteString("var helpPages = map[string]helpPage{\n")
+	for _, p := range pages {
+		fmt.Fprintf(&b, "%q: {\n", p.name)
+		if len(p.synopsis) > 0 {
+			fmt.Fprintf(&b, "Synopsis: %#v,\n", p.synopsis)
+		}
+		if len(p.options) > 0 {
+			b.WriteString("Options: []helpOption{\n")
+			for _, opt := range p.options {
+				arg := ""
+				if opt.arg != "" {
+					arg = fmt.Sprintf(", Arg: %q", opt.arg)
+				}
+				fmt.Fprintf(&b, "{Names: %#v%s, Usage: %q},\n", opt.names, arg, opt.usage)
+			}
+			b.WriteString("},\n")
+		}
+		if len(p.examples) > 0 {
+			fmt.Fprintf(&b, "Examples: %#v,\n", p.examples)
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	source := `package command
+
+import (
+	"errors"
+	"flag"
+)
+
+// FooCommand does foo.
+//
+// Examples:
+//
+//	got foo -v bar
+func FooCommand() *Command {
+	command := newCommand("foo")
+	command.Action = func(args []string) error {
+		var verbose bool
+		flag.BoolVar(&verbose, "verbose", false, "Say more")
+		flag.BoolVar(&verbose, "v", false, "Same as --verbose")
+		flag.String("out", "", "Write to ` + "`file`" + ` instead of " + "stdout")
+		fooFlags()
+		if flag.NArg() != 1 {
+			return errors.New("usage: got foo [-v] [--out <file>] <name>")
+		}
+		return nil
+	}
+	return command
+}
+
+func fooFlags() {
+	flag.Func("n", "How many", nil)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(dir, "help_pages.go")
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	for _, want := range []string{
+		`Synopsis: []string{"got foo [-v] [--out <file>] <name>"}`,
+		`{Names: []string{"-n"}, Arg: "value", Usage: "How many"}`,
+		`{Names: []string{"--out"}, Arg: "file", Usage: "Write to file instead of stdout"}`,
+		`{Names: []string{"-v", "--verbose"}, Usage: "Say more"}`,
+		`Examples: []string{"got foo -v bar"}`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("generate() = %s, want it to have %s", got, want)
+		}
+	}
+}
+
+// The pages of got are generated from its commands, so they have to be
+// generated again when the commands change
+func TestPagesAreUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "pkg", "command")
+	got, err := generate(dir, "help_pages.go")
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "help_pages.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("pkg/command/help_pages.go is out of date, run go generate ./pkg/command")
+	}
+}
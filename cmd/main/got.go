@@ -1,57 +1,143 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"text/tabwriter"
+	"strings"
 
 	"github.com/jessegeens/got/pkg/command"
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/objects"
 )
 
 var (
 	commands = []*command.Command{
 		command.AddCommand(),
+		command.AnnotateCommand(),
+		command.ArchiveCommand(),
+		command.BlameCommand(),
+		command.BugreportCommand(),
 		command.CatFileCommand(),
+		command.CheckAttrCommand(),
 		command.CheckIgnoreCommand(),
+		command.CheckMailmapCommand(),
+		command.CheckRefFormatCommand(),
 		command.CheckoutCommand(),
+		command.CloneCommand(),
 		command.CommitCommand(),
+		command.CommitTreeCommand(),
+		command.CountObjectsCommand(),
+		command.DiffCommand(),
+		command.ExportMetadataCommand(),
+		command.FetchCommand(),
+		command.ForEachRefCommand(),
+		command.ForEachRepoCommand(),
+		command.GcCommand(),
+		command.GrepCommand(),
 		command.HashObjectCommand(),
+		command.HelpCommand(),
+		command.ImportSnapshotsCommand(),
+		command.IndexPackCommand(),
 		command.InitCommand(),
+		command.InteropMapCommand(),
 		command.LogCommand(),
 		command.LsFilesCommand(),
+		command.LsRemoteCommand(),
 		command.LsTreeCommand(),
+		command.MergetoolCommand(),
+		command.MktagCommand(),
+		command.MktreeCommand(),
+		command.PruneCommand(),
+		command.ReadTreeCommand(),
+		command.ReceivePackCommand(),
+		command.ReflogCommand(),
+		command.RemoteCommand(),
+		command.RepackCommand(),
+		command.RestoreCommand(),
+		command.RevListCommand(),
 		command.RevParseCommand(),
 		command.RmCommand(),
+		command.ServeAPICommand(),
+		command.ShowBranchCommand(),
 		command.ShowRefCommand(),
+		command.SparseCheckoutCommand(),
+		command.StashCommand(),
 		command.StatusCommand(),
+		command.StripspaceCommand(),
 		command.TagCommand(),
+		command.UndoCommand(),
+		command.UnpackObjectsCommand(),
+		command.UploadPackCommand(),
+		command.VarCommand(),
+		command.VerifyCommitCommand(),
+		command.VerifyPackCommand(),
+		command.VerifyTagCommand(),
+		command.WebCommand(),
+		command.WorktreeCommand(),
+		command.WriteTreeCommand(),
 	}
 )
 
-func init() {}
+func init() {
+	command.Commands = commands
+}
 
 func main() {
 	if len(os.Args) < 2 {
 		os.Exit(1)
 	}
 	args := os.Args[1:]
+
+	// Global options come before the command
+	for len(args) > 0 && strings.HasPrefix(args[0], "--namespace") {
+		namespace, ok := strings.CutPrefix(args[0], "--namespace=")
+		if !ok {
+			if len(args) < 2 {
+				fmt.Println("got: no namespace given for --namespace")
+				os.Exit(1)
+			}
+			namespace = args[1]
+			args = args[1:]
+		}
+		os.Setenv("GIT_NAMESPACE", namespace)
+		args = args[1:]
+	}
+	if len(args) < 1 {
+		os.Exit(1)
+	}
+	os.Args = append([]string{os.Args[0]}, args...)
+
 	commandName := args[0]
 	if commandName == "--help" || commandName == "-h" {
 		printHelp()
 	}
 
 	flag.Parse()
-	for _, command := range commands {
-		if command.Name == commandName {
+	for _, cmd := range commands {
+		if cmd.Name == commandName {
 			// Now, we remove the command from the args list, because
 			// the `flags` package stops parsing after the first non-option
 			os.Args = []string{os.Args[0]}
 			os.Args = append(os.Args, args[1:]...)
 
-			err := command.Action(args[1:])
+			err := cmd.Action(args[1:])
+			var status command.ExitStatus
+			if errors.As(err, &status) {
+				os.Exit(int(status))
+			}
 			if err != nil {
+				var ambiguous *objects.AmbiguousError
+				if errors.As(err, &ambiguous) {
+					fmt.Print(ambiguous.Hint())
+				}
 				fmt.Printf("Failed to execute command %s with error:\n\t %s\n", commandName, err.Error())
+				// Like in git, a configuration that can't be read is fatal
+				var configErr *config.ReadError
+				if errors.As(err, &configErr) {
+					os.Exit(128)
+				}
 				os.Exit(1)
 			}
 			os.Exit(0)
@@ -62,15 +148,6 @@ func main() {
 }
 
 func printHelp() {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-
-	// Print header
-	fmt.Fprintln(w, "Command\tDescription")
-	for _, cmd := range commands {
-		fmt.Fprintf(w, "%s\t%s\n", cmd.Name, cmd.Description())
-	}
-
-	w.Flush()
-
+	command.WriteCommandList(os.Stdout, commands)
 	os.Exit(0)
 }
@@ -2,10 +2,12 @@ module github.com/jessegeens/got
 
 go 1.24
 
-require gopkg.in/ini.v1 v1.67.0
+require (
+	github.com/fsnotify/fsnotify v1.9.0
+	gopkg.in/ini.v1 v1.67.0
+)
 
 require (
-	github.com/danwakefield/fnmatch v0.0.0-20160403171240-cbb64ac3d964 // indirect
 	github.com/jedib0t/go-pretty/v6 v6.6.8 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
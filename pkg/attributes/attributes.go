@@ -0,0 +1,325 @@
+// Parsing and evaluation of gitattributes
+package attributes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/ignore"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/wildmatch"
+	"github.com/jessegeens/got/pkg/worktree"
+)
+
+type State int
+
+const (
+	// The attribute is not mentioned for the path, or reset with "!attr"
+	Unspecified State = iota
+	// "attr"
+	Set
+	// "-attr"
+	Unset
+	// "attr=value"
+	Valued
+)
+
+type Value struct {
+	State State
+	// Only meaningful if State is Valued
+	Value string
+}
+
+func (v Value) String() string {
+	switch v.State {
+	case Set:
+		return "set"
+	case Unset:
+		return "unset"
+	case Valued:
+		return v.Value
+	}
+	return "unspecified"
+}
+
+func (v Value) IsSet() bool {
+	return v.State == Set
+}
+
+func (v Value) IsUnset() bool {
+	return v.State == Unset
+}
+
+func (v Value) IsSpecified() bool {
+	return v.State != Unspecified
+}
+
+type assignment struct {
+	name  string
+	value Value
+}
+
+type rule struct {
+	// Directory of the .gitattributes file, relative to the worktree.
+	// Empty for files that apply to the whole worktree.
+	dir         string
+	pattern     *wildmatch.Pattern
+	basename    bool
+	assignments []assignment
+}
+
+// Attributes holds all rules that apply to a worktree
+type Attributes struct {
+	// Ordered from lowest to highest precedence
+	rules  []*rule
+	macros map[string][]assignment
+}
+
+// Built-in macro, see gitattributes(5)
+var binaryMacro = []assignment{
+	{name: "diff", value: Value{State: Unset}},
+	{name: "merge", value: Value{State: Unset}},
+	{name: "text", value: Value{State: Unset}},
+}
+
+func New() *Attributes {
+	return &Attributes{
+		rules:  []*rule{},
+		macros: map[string][]assignment{"binary": binaryMacro},
+	}
+}
+
+// Read collects the attributes of a repository, in order of increasing
+// precedence: core.attributesFile, the .gitattributes files in the worktree
+// (deeper directories take precedence) and .git/info/attributes. Ignored
+// directories are not searched for .gitattributes files.
+func Read(repo *repository.Repository) (*Attributes, error) {
+	ign, err := ignore.Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	err = worktree.Walk(repo, worktree.Options{Ignore: ign}, func(entry worktree.Entry) error {
+		if path.Base(entry.Path) == ".gitattributes" {
+			files = append(files, entry.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return read(repo, files, func(file string) ([]byte, error) {
+		return os.ReadFile(path.Join(repo.WorkTree(), file))
+	})
+}
+
+// ReadTree is like Read, but takes the .gitattributes files from a tree
+// instead of the worktree, as given by objects.MapFromTree
+func ReadTree(repo *repository.Repository, tree map[string]*hashing.SHA) (*Attributes, error) {
+	files := []string{}
+	for file := range tree {
+		if path.Base(file) == ".gitattributes" {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	return read(repo, files, func(file string) ([]byte, error) {
+		obj, err := objects.ReadObject(repo, tree[file])
+		if err != nil {
+			return nil, err
+		}
+		return obj.Serialize()
+	})
+}
+
+func read(repo *repository.Repository, files []string, readFile func(file string) ([]byte, error)) (*Attributes, error) {
+	attrs := New()
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if global := globalAttributesFile(cfg); global != "" && fs.IsFile(global) {
+		if err := attrs.addFile(global, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return strings.Count(files[i], "/") < strings.Count(files[j], "/")
+	})
+	for _, file := range files {
+		dir := path.Dir(file)
+		if dir == "." {
+			dir = ""
+		}
+		data, err := readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file, err)
+		}
+		if err := attrs.Parse(data, dir); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+	}
+
+	info := repo.RepositoryPath("info", "attributes")
+	if fs.IsFile(info) {
+		if err := attrs.addFile(info, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return attrs, nil
+}
+
+func globalAttributesFile(cfg config.GitConfig) string {
+	if file, ok := cfg.Get("core", "attributesFile"); ok {
+		if strings.HasPrefix(file, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				file = path.Join(home, file[2:])
+			}
+		}
+		return file
+	}
+	if xdg, ok := os.LookupEnv("XDG_CONFIG_HOME"); ok && xdg != "" {
+		return path.Join(xdg, "git", "attributes")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return path.Join(home, ".config", "git", "attributes")
+	}
+	return ""
+}
+
+func (a *Attributes) addFile(file, dir string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	if err := a.Parse(data, dir); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return nil
+}
+
+// Parse adds the rules in data, as if they were read from a
+// .gitattributes file in dir. The rules take precedence over
+// the ones that were added before.
+func (a *Attributes) Parse(data []byte, dir string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		pattern, attrs := fields[0], fields[1:]
+
+		assignments := []assignment{}
+		for _, attr := range attrs {
+			assignments = append(assignments, parseAssignment(attr))
+		}
+
+		// Macros can only be defined at the top level
+		if strings.HasPrefix(pattern, "[attr]") {
+			if dir != "" {
+				continue
+			}
+			a.macros[strings.TrimPrefix(pattern, "[attr]")] = assignments
+			continue
+		}
+
+		// Like git, we ignore negative patterns
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+
+		// Patterns without a slash match in any directory, the
+		// others are relative to the directory of the file
+		basename := !strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+		compiled, err := wildmatch.Compile(strings.TrimPrefix(pattern, "/"))
+		if err != nil {
+			return err
+		}
+
+		a.rules = append(a.rules, &rule{
+			dir:         dir,
+			pattern:     compiled,
+			basename:    basename,
+			assignments: assignments,
+		})
+	}
+	return scanner.Err()
+}
+
+func parseAssignment(attr string) assignment {
+	switch {
+	case strings.HasPrefix(attr, "-"):
+		return assignment{name: attr[1:], value: Value{State: Unset}}
+	case strings.HasPrefix(attr, "!"):
+		return assignment{name: attr[1:], value: Value{State: Unspecified}}
+	}
+	if name, value, ok := strings.Cut(attr, "="); ok {
+		return assignment{name: name, value: Value{State: Valued, Value: value}}
+	}
+	return assignment{name: attr, value: Value{State: Set}}
+}
+
+func (r *rule) matches(p string) bool {
+	if r.dir != "" {
+		if !strings.HasPrefix(p, r.dir+"/") {
+			return false
+		}
+		p = strings.TrimPrefix(p, r.dir+"/")
+	}
+	if r.basename {
+		return r.pattern.Match(path.Base(p))
+	}
+	return r.pattern.Match(p)
+}
+
+// All returns every attribute that is specified for path, which is
+// relative to the worktree and separated by slashes
+func (a *Attributes) All(p string) map[string]Value {
+	values := map[string]Value{}
+	for _, r := range a.rules {
+		if !r.matches(p) {
+			continue
+		}
+		for _, assign := range r.assignments {
+			a.apply(values, assign, 0)
+		}
+	}
+	for name, value := range values {
+		if !value.IsSpecified() {
+			delete(values, name)
+		}
+	}
+	return values
+}
+
+// apply records an assignment, expanding macros
+func (a *Attributes) apply(values map[string]Value, assign assignment, depth int) {
+	if macro, ok := a.macros[assign.name]; ok && assign.value.IsSet() && depth < 8 {
+		for _, expanded := range macro {
+			a.apply(values, expanded, depth+1)
+		}
+	}
+	values[assign.name] = assign.value
+}
+
+// Get returns the value of a single attribute for path
+func (a *Attributes) Get(p, name string) Value {
+	return a.All(p)[name]
+}
@@ -0,0 +1,104 @@
+package attributes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func TestGet(t *testing.T) {
+	attrs := New()
+	root := `# comment
+*.txt text eol=lf
+*.png binary
+/build.sh -text
+docs/** linguist-documentation
+[attr]generated -diff linguist-generated
+*.pb.go generated
+`
+	if err := attrs.Parse([]byte(root), ""); err != nil {
+		t.Fatal(err)
+	}
+	sub := `*.txt !eol
+local.txt -text
+`
+	if err := attrs.Parse([]byte(sub), "sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		attr string
+		want string
+	}{
+		{"a.txt", "text", "set"},
+		{"a.txt", "eol", "lf"},
+		{"dir/a.txt", "eol", "lf"},
+		{"a.go", "text", "unspecified"},
+		{"img.png", "diff", "unset"},
+		{"img.png", "binary", "set"},
+		{"build.sh", "text", "unset"},
+		{"dir/build.sh", "text", "unspecified"},
+		{"docs/a/b.md", "linguist-documentation", "set"},
+		{"api.pb.go", "diff", "unset"},
+		{"api.pb.go", "linguist-generated", "set"},
+		{"sub/a.txt", "eol", "unspecified"},
+		{"sub/a.txt", "text", "set"},
+		{"sub/local.txt", "text", "unset"},
+		{"local.txt", "text", "set"},
+	}
+
+	for _, tt := range tests {
+		if got := attrs.Get(tt.path, tt.attr).String(); got != tt.want {
+			t.Errorf("Get(%q, %q) = %q, want %q", tt.path, tt.attr, got, tt.want)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	attrs := New()
+	if err := attrs.Parse([]byte("*.c diff=cpp\n*.c !diff text\n"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	all := attrs.All("main.c")
+	if len(all) != 1 || !all["text"].IsSet() {
+		t.Errorf("All(main.c) = %v, want only text", all)
+	}
+}
+
+func TestReadSkipsIgnoredDirectories(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	repo, err := repository.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		".gitignore":                "vendor/\n",
+		"src/.gitattributes":        "*.c diff=cpp\n",
+		"vendor/lib/.gitattributes": "*.c -diff\n",
+	}
+	for name, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attrs, err := Read(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := attrs.Get("src/main.c", "diff").String(); got != "cpp" {
+		t.Errorf("Get(src/main.c, diff) = %q, want cpp", got)
+	}
+	if got := attrs.Get("vendor/lib/main.c", "diff").String(); got != "unspecified" {
+		t.Errorf("Get(vendor/lib/main.c, diff) = %q, want the .gitattributes of the ignored directory to be skipped", got)
+	}
+}
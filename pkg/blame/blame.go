@@ -0,0 +1,245 @@
+// Blame finds the commit that last changed each line of a file, like git
+// blame: the lines are followed back through the history, from a commit
+// to its parents, for as long as a parent has them too. Commits are
+// visited newest first, so lines of a merge go to the parent that had
+// them last. Renames aren't followed.
+package blame
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/jessegeens/got/pkg/diff"
+)
+
+// History gives access to the commits and files that blame goes through
+type History interface {
+	// Commit returns the parents of a commit and its commit time, as a
+	// unix timestamp
+	Commit(sha string) (parents []string, when int64, err error)
+	// File returns the contents of path in a commit, or false if the
+	// commit doesn't have it
+	File(sha, path string) ([]byte, bool, error)
+}
+
+// Entry is a group of consecutive lines of the file that come from the
+// same commit
+type Entry struct {
+	Commit string
+	// Start is the first line in the file, and OrigStart the same line in
+	// the version of Commit. Both count from 0.
+	Start, OrigStart int
+	Lines            int
+	// Previous is the first parent of Commit that has the file, or "" if
+	// there is none, because Commit added the file
+	Previous string
+}
+
+// Blame returns the entries for all lines of path in commit, in the
+// order they are found: the entries of newer commits come first, and
+// the entries of one commit are in the order of the file. When history is
+// a Cache, the lines that reach a commit with a known blame get theirs.
+func Blame(history History, commit, path string) ([]Entry, error) {
+	data, ok, err := history.File(commit, path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no such path '%s' in %s", path, commit)
+	}
+
+	b := &blamer{history: history, path: path, suspects: map[string]*suspect{}}
+	cache, _ := history.(Cache)
+	lines := diff.SplitLines(data)
+	if len(lines) == 0 {
+		return []Entry{}, nil
+	}
+	if _, err := b.suspect(commit, data, []lineRange{{start: 0, orig: 0, n: len(lines)}}); err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for len(b.queue) > 0 {
+		// The newest commit goes first, or the one that was found first
+		next := 0
+		for i, s := range b.queue {
+			if s.when > b.queue[next].when {
+				next = i
+			}
+		}
+		s := b.queue[next]
+		b.queue = slices.Delete(b.queue, next, next+1)
+		delete(b.suspects, s.commit)
+
+		if cache != nil {
+			cached, ok, err := cache.Cached(s.commit, path)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				entries = append(entries, fromCache(s.ranges, cached)...)
+				continue
+			}
+		}
+		found, err := b.passBlame(s)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+type blamer struct {
+	history  History
+	path     string
+	suspects map[string]*suspect
+	queue    []*suspect
+}
+
+// suspect is a commit with lines that may come from it
+type suspect struct {
+	commit string
+	when   int64
+	data   []byte
+	ranges []lineRange
+}
+
+// lineRange is n lines of the file, from start, which are at orig in the
+// version of the suspect
+type lineRange struct {
+	start, orig, n int
+}
+
+// suspect adds ranges to the lines that commit is suspected of
+func (b *blamer) suspect(commit string, data []byte, ranges []lineRange) (*suspect, error) {
+	if s, ok := b.suspects[commit]; ok {
+		s.ranges = append(s.ranges, ranges...)
+		return s, nil
+	}
+	_, when, err := b.history.Commit(commit)
+	if err != nil {
+		return nil, err
+	}
+	s := &suspect{commit: commit, when: when, data: data, ranges: ranges}
+	b.suspects[commit] = s
+	b.queue = append(b.queue, s)
+	return s, nil
+}
+
+// passBlame hands the lines of s that a parent has too to that parent,
+// and returns the entries for the lines that s changed
+func (b *blamer) passBlame(s *suspect) ([]Entry, error) {
+	parents, _, err := b.history.Commit(s.commit)
+	if err != nil {
+		return nil, err
+	}
+	type parentFile struct {
+		commit string
+		data   []byte
+	}
+	files := []parentFile{}
+	for _, parent := range parents {
+		data, ok, err := b.history.File(parent, b.path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			files = append(files, parentFile{parent, data})
+		}
+	}
+
+	// A parent with the same file gets all of it
+	for _, f := range files {
+		if bytes.Equal(f.data, s.data) {
+			_, err := b.suspect(f.commit, f.data, s.ranges)
+			return nil, err
+		}
+	}
+
+	remaining := coalesce(s.ranges)
+	lines := diff.SplitLines(s.data)
+	for _, f := range files {
+		// origins maps our lines to the same lines of the parent
+		origins := make([]int, len(lines))
+		for i := range origins {
+			origins[i] = -1
+		}
+		for _, m := range diff.CommonLines(diff.SplitLines(f.data), lines) {
+			origins[m.B] = m.A
+		}
+
+		passed, kept := []lineRange{}, []lineRange{}
+		for _, r := range remaining {
+			for i := 0; i < r.n; {
+				j := i + 1
+				if origin := origins[r.orig+i]; origin < 0 {
+					for j < r.n && origins[r.orig+j] < 0 {
+						j++
+					}
+					kept = append(kept, lineRange{start: r.start + i, orig: r.orig + i, n: j - i})
+				} else {
+					for j < r.n && origins[r.orig+j] == origin+j-i {
+						j++
+					}
+					passed = append(passed, lineRange{start: r.start + i, orig: origin, n: j - i})
+				}
+				i = j
+			}
+		}
+		if len(passed) > 0 {
+			if _, err := b.suspect(f.commit, f.data, passed); err != nil {
+				return nil, err
+			}
+		}
+		remaining = kept
+	}
+
+	previous := ""
+	if len(files) > 0 {
+		previous = files[0].commit
+	}
+	entries := []Entry{}
+	for _, r := range coalesce(remaining) {
+		entries = append(entries, Entry{Commit: s.commit, Start: r.start, OrigStart: r.orig, Lines: r.n, Previous: previous})
+	}
+	return entries, nil
+}
+
+// fromCache returns the entries for ranges, from the cached entries of
+// the version of the file they point into
+func fromCache(ranges []lineRange, cached []Entry) []Entry {
+	entries := []Entry{}
+	for _, r := range coalesce(ranges) {
+		for _, e := range cached {
+			from, to := max(r.orig, e.Start), min(r.orig+r.n, e.Start+e.Lines)
+			if from >= to {
+				continue
+			}
+			entries = append(entries, Entry{
+				Commit:    e.Commit,
+				Start:     r.start + from - r.orig,
+				OrigStart: e.OrigStart + from - e.Start,
+				Lines:     to - from,
+				Previous:  e.Previous,
+			})
+		}
+	}
+	return entries
+}
+
+// coalesce sorts ranges and joins the ones that follow each other
+func coalesce(ranges []lineRange) []lineRange {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b lineRange) int { return a.start - b.start })
+	joined := []lineRange{}
+	for _, r := range sorted {
+		if n := len(joined); n > 0 && joined[n-1].start+joined[n-1].n == r.start && joined[n-1].orig+joined[n-1].n == r.orig {
+			joined[n-1].n += r.n
+			continue
+		}
+		joined = append(joined, r)
+	}
+	return joined
+}
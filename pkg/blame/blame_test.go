@@ -0,0 +1,130 @@
+package blame
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fakeCommit is a commit of a fakeHistory, with the file at "f" or
+// without it if file is nil
+type fakeCommit struct {
+	parents []string
+	when    int64
+	file    *string
+}
+
+type fakeHistory map[string]fakeCommit
+
+func (h fakeHistory) Commit(sha string) ([]string, int64, error) {
+	return h[sha].parents, h[sha].when, nil
+}
+
+func (h fakeHistory) File(sha, path string) ([]byte, bool, error) {
+	commit := h[sha]
+	if commit.file == nil || path != "f" {
+		return nil, false, nil
+	}
+	return []byte(*commit.file), true, nil
+}
+
+func file(s string) *string { return &s }
+
+func TestBlame(t *testing.T) {
+	tests := []struct {
+		name    string
+		history fakeHistory
+		commit  string
+		want    []Entry
+		wantErr bool
+	}{
+		{
+			name:    "single commit",
+			history: fakeHistory{"a": {nil, 1, file("1\n2\n")}},
+			commit:  "a",
+			want:    []Entry{{Commit: "a", Start: 0, OrigStart: 0, Lines: 2}},
+		},
+		{
+			name: "linear history",
+			history: fakeHistory{
+				"a": {nil, 1, file("1\n2\n3\n")},
+				"b": {[]string{"a"}, 2, file("1\nx\n3\n4\n")},
+			},
+			commit: "b",
+			want: []Entry{
+				{Commit: "b", Start: 1, OrigStart: 1, Lines: 1, Previous: "a"},
+				{Commit: "b", Start: 3, OrigStart: 3, Lines: 1, Previous: "a"},
+				{Commit: "a", Start: 0, OrigStart: 0, Lines: 1},
+				{Commit: "a", Start: 2, OrigStart: 2, Lines: 1},
+			},
+		},
+		{
+			name: "unchanged commit",
+			history: fakeHistory{
+				"a": {nil, 1, file("1\n")},
+				"b": {[]string{"a"}, 2, file("1\n")},
+			},
+			commit: "b",
+			want:   []Entry{{Commit: "a", Start: 0, OrigStart: 0, Lines: 1}},
+		},
+		{
+			name: "lines moved down",
+			history: fakeHistory{
+				"a": {nil, 1, file("1\n2\n")},
+				"b": {[]string{"a"}, 2, file("0\n1\n2\n")},
+			},
+			commit: "b",
+			want: []Entry{
+				{Commit: "b", Start: 0, OrigStart: 0, Lines: 1, Previous: "a"},
+				{Commit: "a", Start: 1, OrigStart: 0, Lines: 2},
+			},
+		},
+		{
+			name: "merge",
+			history: fakeHistory{
+				"a": {nil, 1, file("1\n2\n")},
+				"b": {[]string{"a"}, 2, file("1\nb\n")},
+				"c": {[]string{"a"}, 3, file("c\n1\n2\n")},
+				"m": {[]string{"b", "c"}, 4, file("c\n1\nb\nm\n")},
+			},
+			commit: "m",
+			want: []Entry{
+				{Commit: "m", Start: 3, OrigStart: 3, Lines: 1, Previous: "b"},
+				{Commit: "c", Start: 0, OrigStart: 0, Lines: 1, Previous: "a"},
+				{Commit: "b", Start: 2, OrigStart: 1, Lines: 1, Previous: "a"},
+				{Commit: "a", Start: 1, OrigStart: 0, Lines: 1},
+			},
+		},
+		{
+			name: "added file",
+			history: fakeHistory{
+				"a": {nil, 1, nil},
+				"b": {[]string{"a"}, 2, file("1\n")},
+			},
+			commit: "b",
+			want:   []Entry{{Commit: "b", Start: 0, OrigStart: 0, Lines: 1}},
+		},
+		{
+			name:    "empty file",
+			history: fakeHistory{"a": {nil, 1, file("")}},
+			commit:  "a",
+			want:    []Entry{},
+		},
+		{
+			name:    "missing file",
+			history: fakeHistory{"a": {nil, 1, nil}},
+			commit:  "a",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Blame(tt.history, tt.commit, "f")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Blame() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Blame() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
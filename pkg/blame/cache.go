@@ -0,0 +1,108 @@
+package blame
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+)
+
+// Cache has the results of earlier blames. A History that is a Cache
+// too lets Blame stop at the commits it knows the blame of.
+type Cache interface {
+	// Cached returns the entries of path in commit, or false if they
+	// aren't known
+	Cached(commit, path string) ([]Entry, bool, error)
+}
+
+// DiskCache keeps the results of Blame in files in Dir, per commit and
+// path. A commit never changes, so neither does the blame of its files:
+// entries stay good for as long as the commit exists.
+type DiskCache struct {
+	Dir string
+}
+
+// file is where the entries of path in commit are kept
+func (c DiskCache) file(commit, path string) string {
+	sum := sha1.Sum([]byte(path))
+	return filepath.Join(c.Dir, commit, hex.EncodeToString(sum[:]))
+}
+
+// Cached reads the entries of path in commit. Each entry is a line with
+// its commit, start, original start and number of lines, and the
+// previous commit if there is one.
+func (c DiskCache) Cached(commit, path string) ([]Entry, bool, error) {
+	f, err := os.Open(c.file(commit, path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 5 {
+			e.Previous = fields[4]
+			fields = fields[:4]
+		}
+		if _, err := fmt.Sscan(strings.Join(fields, " "), &e.Commit, &e.Start, &e.OrigStart, &e.Lines); err != nil {
+			// A damaged cache is only a miss
+			return nil, false, nil
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+	return entries, true, nil
+}
+
+// Store writes the entries of path in commit
+func (c DiskCache) Store(commit, path string, entries []Entry) error {
+	var out bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&out, "%s %d %d %d", e.Commit, e.Start, e.OrigStart, e.Lines)
+		if e.Previous != "" {
+			fmt.Fprintf(&out, " %s", e.Previous)
+		}
+		out.WriteByte('\n')
+	}
+	file := c.file(commit, path)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return fs.AtomicWrite(file, out.Bytes())
+}
+
+// Prune removes the entries of the commits that keep doesn't want, like
+// the ones that are no longer reachable
+func (c DiskCache) Prune(keep func(commit string) bool) error {
+	dirs, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if keep(dir.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.Dir, dir.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package blame
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// cachedHistory is a fakeHistory with a DiskCache, which notes the
+// commits whose files are read
+type cachedHistory struct {
+	fakeHistory
+	DiskCache
+	visited map[string]bool
+}
+
+func (h cachedHistory) File(sha, path string) ([]byte, bool, error) {
+	h.visited[sha] = true
+	return h.fakeHistory.File(sha, path)
+}
+
+func TestBlameCached(t *testing.T) {
+	history := fakeHistory{
+		"a": {nil, 1, file("1\n2\n3\n")},
+		"b": {[]string{"a"}, 2, file("1\nb\n3\n")},
+		"c": {[]string{"b"}, 3, file("c\n1\nb\n3\n")},
+		"d": {[]string{"c"}, 4, file("c\n1\nb\nd\n")},
+	}
+	cache := DiskCache{Dir: t.TempDir()}
+	byStart := func(a, b Entry) int { return a.Start - b.Start }
+
+	for _, commit := range []string{"b", "c", "d"} {
+		want, err := Blame(history, commit, "f")
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.SortFunc(want, byStart)
+
+		h := cachedHistory{history, cache, map[string]bool{}}
+		got, err := Blame(h, commit, "f")
+		if err != nil {
+			t.Fatalf("Blame(%s) error = %v", commit, err)
+		}
+		slices.SortFunc(got, byStart)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Blame(%s) with a cache = %+v, want %+v", commit, got, want)
+		}
+		// The parent was blamed before, so the history stops there
+		if commit != "b" && h.visited["a"] {
+			t.Errorf("Blame(%s) went past the cached blame of its parent", commit)
+		}
+		if err := cache.Store(commit, "f", got); err != nil {
+			t.Fatal(err)
+		}
+		if cached, ok, err := cache.Cached(commit, "f"); err != nil || !ok || !reflect.DeepEqual(cached, got) {
+			t.Errorf("Cached(%s) = %+v, %v, %v, want %+v", commit, cached, ok, err, got)
+		}
+	}
+
+	if _, ok, err := cache.Cached("d", "other"); ok || err != nil {
+		t.Errorf("Cached() of another path = %v, %v, want a miss", ok, err)
+	}
+	if err := os.WriteFile(cache.file("d", "f"), []byte("garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := cache.Cached("d", "f"); ok || err != nil {
+		t.Errorf("Cached() of a damaged file = %v, %v, want a miss", ok, err)
+	}
+
+	if err := cache.Prune(func(commit string) bool { return commit != "c" }); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(cache.Dir, "*"))
+	for i := range dirs {
+		dirs[i] = filepath.Base(dirs[i])
+	}
+	if want := []string{"b", "d"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("Prune() left %v, want %v", dirs, want)
+	}
+}
@@ -0,0 +1,218 @@
+// Package checkout writes the files of a tree to a worktree. Blobs are
+// read, filtered and written by a pool of workers, after the directories
+// they go in are created, so checking out many files isn't limited to one
+// file at a time.
+package checkout
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// File is a file to write to the worktree
+type File struct {
+	// Name is the path of the file below the root, with slashes
+	Name string
+	SHA  *hashing.SHA
+	// Mode is the mode of the file in its tree: 100644, 100755, 120000
+	// for symlinks or 160000 for submodules, which get an empty directory
+	Mode string
+}
+
+// Options say how files are written
+type Options struct {
+	// Workers is the number of files written at the same time. Zero or
+	// less means one per CPU.
+	Workers int
+	// Smudge converts the contents of a blob to what is written to the
+	// worktree. Nil writes blobs as they are.
+	Smudge func(name string, contents []byte) ([]byte, error)
+	// Progress gets a progress meter when writing takes a while. Nil
+	// shows none.
+	Progress io.Writer
+}
+
+// Write writes files below root. Whatever is in the way of a file or of
+// the directories it goes in is replaced. Writing stops at the first
+// error.
+func Write(repo *repository.Repository, root string, files []File, opts Options) error {
+	// Directories come first, parents before their children, so the
+	// workers never have to create them concurrently
+	if err := makeDirs(root, files); err != nil {
+		return err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	meter := newMeter(opts.Progress, len(files))
+	done := make(chan error)
+	next := make(chan int)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range min(workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				done <- writeFile(repo, root, files[i], opts.Smudge)
+			}
+		}()
+	}
+	go func() {
+		defer close(next)
+		for i := range files {
+			select {
+			case next <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for written := range len(files) {
+		if err := <-done; err != nil {
+			close(stop)
+			// Workers finish the file they are writing after a stop
+			go func() {
+				for range done {
+				}
+			}()
+			wg.Wait()
+			close(done)
+			return err
+		}
+		meter.update(written + 1)
+	}
+	wg.Wait()
+	meter.finish()
+	return nil
+}
+
+// makeDirs creates the directories of files, and the directories of
+// submodules
+func makeDirs(root string, files []File) error {
+	dirs := map[string]bool{}
+	for _, f := range files {
+		dir := path.Dir(f.Name)
+		if f.Mode == "160000" {
+			dir = f.Name
+		}
+		for ; dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	sorted := slices.SortedFunc(maps.Keys(dirs), func(a, b string) int {
+		if depth := strings.Count(a, "/") - strings.Count(b, "/"); depth != 0 {
+			return depth
+		}
+		return strings.Compare(a, b)
+	})
+	for _, dir := range sorted {
+		full := filepath.Join(root, filepath.FromSlash(dir))
+		info, err := os.Lstat(full)
+		if err == nil && info.IsDir() {
+			continue
+		}
+		if err == nil {
+			if err := os.Remove(full); err != nil {
+				return err
+			}
+		}
+		if err := os.Mkdir(full, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes a file, which makeDirs made the directory for
+func writeFile(repo *repository.Repository, root string, f File, smudge func(string, []byte) ([]byte, error)) error {
+	full := filepath.Join(root, filepath.FromSlash(f.Name))
+	if f.Mode == "160000" {
+		return nil
+	}
+	if info, err := os.Lstat(full); err == nil && !info.Mode().IsRegular() {
+		if err := os.RemoveAll(full); err != nil {
+			return err
+		}
+	}
+
+	obj, err := objects.ReadObject(repo, f.SHA)
+	if err != nil {
+		return err
+	}
+	contents, err := obj.Serialize()
+	if err != nil {
+		return err
+	}
+	if f.Mode == "120000" {
+		os.Remove(full)
+		return os.Symlink(string(contents), full)
+	}
+	if smudge != nil {
+		if contents, err = smudge(f.Name, contents); err != nil {
+			return err
+		}
+	}
+	perm := os.FileMode(0o644)
+	if f.Mode == "100755" {
+		perm = 0o755
+	}
+	if err := os.WriteFile(full, contents, perm); err != nil {
+		return err
+	}
+	// Files that were there keep their permissions when written
+	return os.Chmod(full, perm)
+}
+
+// progressDelay is how long writing goes on before the meter shows, so
+// quick checkouts don't show one
+var progressDelay = time.Second
+
+// meter shows how many files are written, like git's progress meters
+type meter struct {
+	w       io.Writer
+	total   int
+	start   time.Time
+	percent int
+	shown   bool
+}
+
+func newMeter(w io.Writer, total int) *meter {
+	return &meter{w: w, total: total, start: time.Now(), percent: -1}
+}
+
+func (m *meter) update(done int) {
+	if m.w == nil || m.total == 0 || time.Since(m.start) < progressDelay {
+		return
+	}
+	percent := done * 100 / m.total
+	if percent == m.percent {
+		return
+	}
+	m.percent = percent
+	m.shown = true
+	fmt.Fprintf(m.w, "Updating files: %3d%% (%d/%d)\r", percent, done, m.total)
+}
+
+// finish ends the meter, if it was shown
+func (m *meter) finish() {
+	if m.shown {
+		fmt.Fprintf(m.w, "Updating files: 100%% (%d/%d), done.\n", m.total, m.total)
+	}
+}
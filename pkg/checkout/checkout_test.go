@@ -0,0 +1,105 @@
+package checkout
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func writeBlob(t *testing.T, repo *repository.Repository, contents string) *hashing.SHA {
+	t.Helper()
+	blob := &objects.Blob{}
+	blob.Deserialize([]byte(contents))
+	sha, err := objects.WriteObject(blob, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func TestWrite(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	// Things in the way are replaced
+	os.WriteFile(filepath.Join(root, "dir"), []byte("a file where a directory goes"), 0o644)
+	os.MkdirAll(filepath.Join(root, "run.sh", "sub"), 0o755)
+
+	files := []File{
+		{Name: "README", SHA: writeBlob(t, repo, "readme\n"), Mode: "100644"},
+		{Name: "run.sh", SHA: writeBlob(t, repo, "#!/bin/sh\n"), Mode: "100755"},
+		{Name: "link", SHA: writeBlob(t, repo, "README"), Mode: "120000"},
+		{Name: "module", SHA: writeBlob(t, repo, "not read"), Mode: "160000"},
+	}
+	for i := range 50 {
+		name := fmt.Sprintf("dir/sub%d/deep/file%d", i%5, i)
+		files = append(files, File{Name: name, SHA: writeBlob(t, repo, name), Mode: "100644"})
+	}
+
+	defer func(delay time.Duration) { progressDelay = delay }(progressDelay)
+	progressDelay = 0
+	var progress bytes.Buffer
+	smudge := func(name string, contents []byte) ([]byte, error) {
+		return bytes.ToUpper(contents), nil
+	}
+	if err := Write(repo, root, files, Options{Workers: 4, Smudge: smudge, Progress: &progress}); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	for _, f := range files {
+		full := filepath.Join(root, f.Name)
+		info, err := os.Lstat(full)
+		if err != nil {
+			t.Errorf("%s was not written: %v", f.Name, err)
+			continue
+		}
+		switch f.Mode {
+		case "120000":
+			if target, _ := os.Readlink(full); target != "README" {
+				t.Errorf("%s points to %q, want README", f.Name, target)
+			}
+		case "160000":
+			if !info.IsDir() {
+				t.Errorf("%s is not a directory", f.Name)
+			}
+		default:
+			contents, _ := os.ReadFile(full)
+			obj, _ := objects.ReadObject(repo, f.SHA)
+			blob, _ := obj.Serialize()
+			if string(contents) != strings.ToUpper(string(blob)) {
+				t.Errorf("%s = %q, want the smudged blob", f.Name, contents)
+			}
+			if executable := info.Mode().Perm()&0o111 != 0; executable != (f.Mode == "100755") {
+				t.Errorf("%s has mode %v", f.Name, info.Mode())
+			}
+		}
+	}
+	if want := fmt.Sprintf("Updating files: 100%% (%d/%d), done.\n", len(files), len(files)); !strings.HasSuffix(progress.String(), want) {
+		t.Errorf("progress = %q, want it to end with %q", progress.String(), want)
+	}
+}
+
+func TestWriteStopsAtError(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []File{}
+	for i := range 20 {
+		files = append(files, File{Name: fmt.Sprintf("file%d", i), SHA: writeBlob(t, repo, fmt.Sprint(i)), Mode: "100644"})
+	}
+	files[7].SHA = hashing.NewSHA([]byte("missing"))
+	if err := Write(repo, t.TempDir(), files, Options{Workers: 3}); err == nil {
+		t.Error("Write() with a missing blob succeeded")
+	}
+}
@@ -3,17 +3,19 @@ package command
 import (
 	"errors"
 	"fmt"
-	iofs "io/fs"
 	"os"
 	"path/filepath"
 	"strings"
-	"syscall"
-	"time"
 
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/filter"
 	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/ignore"
 	"github.com/jessegeens/got/pkg/index"
 	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/worktree"
 )
 
 func AddCommand() *Command {
@@ -28,7 +30,7 @@ func AddCommand() *Command {
 			return err
 		}
 
-		for _, path := range extractPathsFromArgs(args) {
+		for _, path := range args {
 			err = add(repo, path, true)
 			if err != nil {
 				return err
@@ -42,116 +44,127 @@ func AddCommand() *Command {
 }
 
 func add(repo *repository.Repository, addPath string, delete bool) error {
-	idx, err := index.Read(repo)
-	if err != nil {
-		return err
-	}
+	return index.Update(repo, func(idx *index.Index) error {
+		return addToIndex(repo, idx, addPath)
+	})
+}
 
+func addToIndex(repo *repository.Repository, idx *index.Index, addPath string) error {
 	absPath, err := filepath.Abs(addPath)
 	if err != nil {
 		return err
 	}
 
-	if !strings.HasPrefix(absPath, repo.WorkTree()) {
+	relPath, err := filepath.Rel(repo.WorkTree(), absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, "../") {
 		return errors.New("cannot add a path outside the worktree")
 	}
-
-	// First remove all paths from the index, if they exist
-	rm(repo, addPath, false)
+	if relPath == ".git" || strings.HasPrefix(relPath, ".git/") {
+		return errors.New("cannot add a path inside the .git directory")
+	}
 
 	paths := []string{}
 	if fs.IsDirectory(absPath) {
-		filepath.WalkDir(absPath, func(path string, d iofs.DirEntry, err error) error {
-			// Skip whatever is in .git
-			if strings.HasPrefix(path, repo.GitDir()) {
-				return nil
-			}
+		ign, err := ignore.Read(repo)
+		if err != nil {
+			return err
+		}
+		entries, err := worktree.Collect(repo, worktree.Options{Root: relPath, Ignore: ign})
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			paths = append(paths, e.Path)
+		}
 
-			if !d.IsDir() {
-				paths = append(paths, path)
+		// Files in the directory that no longer exist are removed from the index
+		removed := []string{}
+		for _, e := range idx.Entries {
+			inDirectory := relPath == "." || strings.HasPrefix(e.Name, relPath+"/")
+			if inDirectory && !fs.Exists(filepath.Join(repo.WorkTree(), e.Name)) {
+				removed = append(removed, e.Name)
 			}
-			return nil
-		})
+		}
+		for _, name := range removed {
+			idx.Remove(name)
+		}
+	} else if fs.Exists(absPath) {
+		paths = append(paths, relPath)
 	} else {
-		paths = append(paths, absPath)
+		// Adding a file that was removed stages its removal
+		if !idx.Remove(relPath) {
+			return fmt.Errorf("pathspec '%s' did not match any files", addPath)
+		}
+	}
+
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	threshold, err := bigFileThreshold(cfg)
+	if err != nil {
+		return err
+	}
+	bigFiles, ok := cfg.Get("add", "bigFiles")
+	if !ok {
+		bigFiles = "warn"
+	}
+	if bigFiles != "warn" && bigFiles != "refuse" && bigFiles != "allow" {
+		return fmt.Errorf("invalid add.bigFiles %q, it can be warn, refuse or allow", bigFiles)
 	}
 
 	for _, p := range paths {
-		relPath, err := filepath.Rel(repo.WorkTree(), absPath)
+		sha, err := addBlob(repo, attrs, p, threshold, bigFiles)
 		if err != nil {
 			return err
 		}
 
-		fileContents, err := os.ReadFile(p)
-		if err != nil {
-			return fmt.Errorf("failed to read %s: %s", p, err.Error())
-		}
-		sha, err := objects.ObjectHash(fileContents, objects.TypeBlob, repo)
-		if err != nil {
-			return fmt.Errorf("failed to hash object: %s", err.Error())
-		}
-
-		var stat syscall.Stat_t
-		err = syscall.Stat(addPath, &stat)
+		entry, err := indexEntryFromFile(repo, p, sha)
 		if err != nil {
 			return err
 		}
 
-		ctime := time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec)
-
-		mtime := time.Unix(stat.Mtim.Sec, stat.Mtim.Nsec)
-
-		entry := &index.Entry{
-			CTime:           ctime,
-			MTime:           mtime,
-			Dev:             uint32(stat.Dev),
-			Inode:           uint32(stat.Ino),
-			SHA:             sha,
-			ModeType:        index.ModeTypeRegular,
-			ModePerms:       0o644,
-			UID:             stat.Uid,
-			GID:             stat.Gid,
-			Size:            uint32(stat.Size),
-			FlagAssumeValid: false,
-			FlagStage:       0,
-			Name:            relPath,
-		}
-
-		idx.Entries = appendIfUnique(idx.Entries, entry)
+		// Adding an unmerged file resolves the conflict
+		idx.Add(entry)
 	}
 
-	return idx.Write(repo)
+	return nil
 }
 
-func extractPathsFromArgs(args []string) []string {
-	if args[0] == "." {
-		paths := make([]string, 0)
-		entries, _ := os.ReadDir("./")
-		for _, entry := range entries {
-			paths = append(paths, entry.Name())
+// addBlob stores the file name of the worktree as a blob. Files above
+// threshold are streamed, unless filters need their whole contents, and
+// what add.bigFiles says about them happens: a warning, a refusal, or
+// nothing.
+func addBlob(repo *repository.Repository, attrs *attributes.Attributes, name string, threshold int64, bigFiles string) (*hashing.SHA, error) {
+	path := filepath.Join(repo.WorkTree(), name)
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() && info.Size() > threshold {
+		switch bigFiles {
+		case "refuse":
+			return nil, fmt.Errorf("%s is %d bytes, over core.bigFileThreshold; set add.bigFiles to warn or allow to add it", name, info.Size())
+		case "warn":
+			fmt.Fprintf(os.Stderr, "warning: %s is %d bytes, over core.bigFileThreshold; it is stored without delta compression\n", name, info.Size())
+		}
+		if len(filter.ForPath(attrs, name)) == 0 {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %s", name, err.Error())
+			}
+			defer f.Close()
+			return objects.WriteBlobStream(repo, f, info.Size())
 		}
-		return paths
 	}
 
-	return args
-}
-
-// Only append if the value is not there yet
-func appendIfUnique(entries []*index.Entry, entry *index.Entry) []*index.Entry {
-	for i, e := range entries {
-		// Same file with same contents
-		// No need to change anything
-		if e.SHA.AsString() == entry.SHA.AsString() {
-			return entries
-		}
-		// Same file with different entries
-		// => the old version should not be written to the index
-		// so we remove it
-		if e.Inode == entry.Inode && e.Dev == entry.Dev {
-			entries = append(entries[:i], entries[i+1:]...)
-			return append(entries, entry)
-		}
+	fileContents, err := readWorktreeFile(repo, attrs, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", name, err.Error())
+	}
+	sha, err := objects.ObjectHash(fileContents, objects.TypeBlob, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash object: %s", err.Error())
 	}
-	// Actually new file, we just append
-	return append(entries, entry)
+	return sha, nil
 }
@@ -0,0 +1,12 @@
+package command
+
+func AnnotateCommand() *Command {
+	command := newCommand("annotate")
+	command.Action = func(args []string) error {
+		return BlameCommand().Action(append([]string{"-c"}, args...))
+	}
+	command.Description = func() string {
+		return "Show what revision and author last modified each line of a file, in the format of git annotate"
+	}
+	return command
+}
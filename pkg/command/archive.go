@@ -0,0 +1,243 @@
+package command
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/filter"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func ArchiveCommand() *Command {
+	command := newCommand("archive")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		format := flag.String("format", "", "Format of the archive: tar or zip")
+		prefix := flag.String("prefix", "", "Prepend prefix to each path in the archive")
+		output := flag.String("o", "", "Write the archive to this file instead of stdout")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() != 1 {
+			return errors.New("usage: got archive [--format=<fmt>] [--prefix=<prefix>/] [-o <file>] <tree-ish>")
+		}
+
+		// Like git, we infer the format from the output file name
+		if *format == "" {
+			*format = "tar"
+			if strings.HasSuffix(*output, ".zip") {
+				*format = "zip"
+			}
+		}
+		if *format != "tar" && *format != "zip" {
+			return fmt.Errorf("unknown archive format '%s'", *format)
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		return archive(repo, flag.Arg(0), *format, *prefix, out)
+	}
+	command.Description = func() string { return "Create an archive of files from a named tree" }
+	return command
+}
+
+type archiveFile struct {
+	name string
+	// mode is the mode of the file in the tree, like 100755
+	mode string
+	data []byte
+}
+
+func archive(repo *repository.Repository, name, format, prefix string, out io.Writer) error {
+	tree, err := objects.Find(repo, name, objects.TypeTree, true)
+	if err != nil {
+		return err
+	}
+	leaves := map[string]*objects.TreeLeaf{}
+	if err := treeLeaves(repo, tree.AsString(), "", leaves); err != nil {
+		return err
+	}
+	files := map[string]*hashing.SHA{}
+	for file, leaf := range leaves {
+		files[file] = leaf.Sha
+	}
+
+	attrs, err := attributes.ReadTree(repo, files)
+	if err != nil {
+		return err
+	}
+
+	// When archiving a commit, its metadata is used for export-subst
+	// and for the modification time of the files
+	modTime := time.Now()
+	var subst *filter.ExportSubst
+	if sha, err := objects.Find(repo, name, objects.TypeCommit, true); err == nil {
+		obj, err := objects.ReadObject(repo, sha)
+		if err != nil {
+			return err
+		}
+		commit := obj.(*objects.Commit)
+		subst = &filter.ExportSubst{SHA: sha, Commit: commit}
+		if committer, ok := commit.GetValue("committer"); ok {
+			if when, ok := commitTime(committer); ok {
+				modTime = when
+			}
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for file := range files {
+		if attrs.Get(file, "export-ignore").IsSet() {
+			continue
+		}
+		names = append(names, file)
+	}
+	sort.Strings(names)
+
+	archived := []archiveFile{}
+	for _, file := range names {
+		mode := strings.TrimLeft(string(leaves[file].Mode), "0")
+		var data []byte
+		switch mode {
+		case "160000":
+			// Like git, submodules are empty directories
+		case "120000":
+			// The target of a symbolic link is not filtered
+			data, err = blobContents(repo, files[file])
+		default:
+			data, err = archiveContents(repo, attrs, subst, file, files[file])
+		}
+		if err != nil {
+			return err
+		}
+		archived = append(archived, archiveFile{name: path.Join(prefix, file), mode: mode, data: data})
+	}
+
+	if format == "zip" {
+		return writeZip(out, archived, modTime)
+	}
+	return writeTar(out, archived, modTime)
+}
+
+// archiveContents returns a blob as it should appear in the archive,
+// with the smudge filters and export-subst applied
+func archiveContents(repo *repository.Repository, attrs *attributes.Attributes, subst *filter.ExportSubst, name string, sha *hashing.SHA) ([]byte, error) {
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	data, err := obj.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := filter.ForPath(attrs, name)
+	if subst != nil && attrs.Get(name, "export-subst").IsSet() {
+		filters = append(filters, *subst)
+	}
+	return filter.Smudge(filters, data)
+}
+
+// commitTime parses the timestamp of an author or committer line
+func commitTime(ident []byte) (time.Time, bool) {
+	fields := strings.Fields(string(ident))
+	if len(fields) < 2 {
+		return time.Time{}, false
+	}
+	var seconds int64
+	if _, err := fmt.Sscanf(fields[len(fields)-2], "%d", &seconds); err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+func writeTar(out io.Writer, files []archiveFile, modTime time.Time) error {
+	tw := tar.NewWriter(out)
+	for _, file := range files {
+		header := &tar.Header{
+			Name:     file.name,
+			Mode:     0o644,
+			Size:     int64(len(file.data)),
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		}
+		switch file.mode {
+		case "100755":
+			header.Mode = 0o755
+		case "120000":
+			header.Mode, header.Typeflag = 0o777, tar.TypeSymlink
+			header.Linkname, header.Size = string(file.data), 0
+		case "160000":
+			header.Name += "/"
+			header.Mode, header.Typeflag = 0o755, tar.TypeDir
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeZip(out io.Writer, files []archiveFile, modTime time.Time) error {
+	zw := zip.NewWriter(out)
+	for _, file := range files {
+		header := &zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		// The Unix mode goes in the external attributes, where unzip
+		// finds executables and symbolic links, whose target is the data
+		switch file.mode {
+		case "100755":
+			header.SetMode(0o755)
+		case "120000":
+			header.SetMode(os.ModeSymlink | 0o777)
+		case "160000":
+			header.Name += "/"
+			header.SetMode(os.ModeDir | 0o755)
+		default:
+			header.SetMode(0o644)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,428 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/blame"
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// uncommitted stands for the worktree version of a file, like in git
+var uncommitted = strings.Repeat("0", 40)
+
+func BlameCommand() *Command {
+	command := newCommand("blame")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var porcelain bool
+		flag.BoolVar(&porcelain, "porcelain", false, "Show the results in a format for programs, with the details of each commit once")
+		flag.BoolVar(&porcelain, "p", false, "Same as --porcelain")
+		linePorcelain := flag.Bool("line-porcelain", false, "Like --porcelain, but with the details of the commit for every line")
+		incremental := flag.Bool("incremental", false, "Show the results in a format for programs, as they are found")
+		long := flag.Bool("l", false, "Show the full commit hash")
+		short := flag.Bool("s", false, "Leave out the author name and date")
+		annotate := flag.Bool("c", false, "Show the results like got annotate")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+
+		// got blame [<rev>] [--] <file>
+		rev, file := "", flag.Arg(0)
+		switch flag.NArg() {
+		case 1:
+		case 2:
+			rev, file = flag.Arg(0), flag.Arg(1)
+		default:
+			return errors.New("usage: got blame [<options>] [<rev>] [--] <file>")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		path, err := worktreePath(repo, file)
+		if err != nil {
+			return err
+		}
+		history, start, err := newBlameHistory(repo, rev, path)
+		if err != nil {
+			return err
+		}
+		entries, err := history.blame(start)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case *annotate:
+			return writeAnnotate(os.Stdout, history, entries)
+		case *incremental:
+			return writeBlameIncremental(os.Stdout, history, entries, path)
+		case porcelain || *linePorcelain:
+			return writeBlamePorcelain(os.Stdout, history, entries, path, *linePorcelain)
+		}
+		return writeBlame(os.Stdout, history, entries, *long, *short)
+	}
+	command.Description = func() string { return "Show what revision and author last modified each line of a file" }
+	return command
+}
+
+// blameHistory reads the commits and files for blame from the
+// repository. Without a revision, blame starts from the worktree file, as
+// an uncommitted version on top of HEAD.
+type blameHistory struct {
+	repo    *repository.Repository
+	commits map[string]*objects.Commit
+	trees   map[string]map[string]*hashing.SHA
+	// start is the commit that blame starts from, and path the file
+	start, path string
+	head        string
+	worktree    []byte
+	now         time.Time
+	// cache has the blames of earlier runs, unless blame.cache is false
+	cache *blame.DiskCache
+}
+
+func newBlameHistory(repo *repository.Repository, rev, path string) (*blameHistory, string, error) {
+	h := &blameHistory{
+		repo:    repo,
+		commits: map[string]*objects.Commit{},
+		trees:   map[string]map[string]*hashing.SHA{},
+		path:    path,
+		now:     time.Now(),
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, "", err
+	}
+	if enabled, ok := cfg.GetBool("blame", "cache"); !ok || enabled {
+		cache := blameCache(repo)
+		h.cache = &cache
+	}
+	if rev != "" {
+		sha, err := objects.Find(repo, rev, objects.TypeCommit, true)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, ok, err := h.File(sha.AsString(), path); err != nil {
+			return nil, "", err
+		} else if !ok {
+			return nil, "", fmt.Errorf("no such path '%s' in %s", path, rev)
+		}
+		h.start = sha.AsString()
+		return h, h.start, nil
+	}
+
+	head, err := references.Reference("HEAD").Resolve(repo)
+	if err != nil {
+		return nil, "", err
+	}
+	h.head = head
+	if head == "" {
+		return nil, "", errors.New("no such ref: HEAD")
+	}
+	if _, ok, err := h.File(head, path); err != nil {
+		return nil, "", err
+	} else if !ok {
+		return nil, "", fmt.Errorf("no such path '%s' in HEAD", path)
+	}
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return nil, "", err
+	}
+	h.worktree, err = readWorktreeFile(repo, attrs, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("cannot stat path '%s': No such file or directory", path)
+	}
+	h.start = uncommitted
+	return h, h.start, err
+}
+
+// blameCache is where the blames of committed files are kept
+func blameCache(repo *repository.Repository) blame.DiskCache {
+	return blame.DiskCache{Dir: repo.RepositoryPath("blame-cache")}
+}
+
+// blame blames the file from commit. Committed versions are looked up in
+// and added to the cache. Editors mostly blame the worktree file, so then
+// HEAD is blamed first, to have it in the cache for the next time.
+func (h *blameHistory) blame(commit string) ([]blame.Entry, error) {
+	if h.cache == nil {
+		return blame.Blame(h, commit, h.path)
+	}
+	if commit == uncommitted {
+		if _, err := h.blame(h.head); err != nil {
+			return nil, err
+		}
+		return blame.Blame(h, commit, h.path)
+	}
+	if entries, ok, err := h.cache.Cached(commit, h.path); err != nil || ok {
+		return entries, err
+	}
+	entries, err := blame.Blame(h, commit, h.path)
+	if err != nil {
+		return nil, err
+	}
+	// The cache only saves time, a repository we can't write to is fine
+	_ = h.cache.Store(commit, h.path, entries)
+	return entries, nil
+}
+
+// Cached returns the cached blame of a committed version of the file
+func (h *blameHistory) Cached(commit, path string) ([]blame.Entry, bool, error) {
+	if h.cache == nil || commit == uncommitted {
+		return nil, false, nil
+	}
+	return h.cache.Cached(commit, path)
+}
+
+func (h *blameHistory) commit(sha string) (*objects.Commit, error) {
+	if commit, ok := h.commits[sha]; ok {
+		return commit, nil
+	}
+	hash, err := hashing.NewShaFromHex(sha)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := readCommit(h.repo, hash)
+	if err != nil {
+		return nil, err
+	}
+	h.commits[sha] = commit
+	return commit, nil
+}
+
+func (h *blameHistory) Commit(sha string) ([]string, int64, error) {
+	if sha == uncommitted {
+		return []string{h.head}, h.now.Unix(), nil
+	}
+	commit, err := h.commit(sha)
+	if err != nil {
+		return nil, 0, err
+	}
+	parents := []string{}
+	for _, parent := range commit.GetValues("parent") {
+		parents = append(parents, string(parent))
+	}
+	committer, _ := commit.GetValue("committer")
+	return parents, objects.ParseIdent(committer).When.Unix(), nil
+}
+
+func (h *blameHistory) File(sha, path string) ([]byte, bool, error) {
+	if sha == uncommitted {
+		return h.worktree, true, nil
+	}
+	tree, ok := h.trees[sha]
+	if !ok {
+		commit, err := h.commit(sha)
+		if err != nil {
+			return nil, false, err
+		}
+		treeSha, _ := commit.GetValue("tree")
+		if tree, err = objects.MapFromTree(h.repo, string(treeSha)); err != nil {
+			return nil, false, err
+		}
+		h.trees[sha] = tree
+	}
+	blob, ok := tree[path]
+	if !ok {
+		return nil, false, nil
+	}
+	data, err := blobContents(h.repo, blob)
+	return data, err == nil, err
+}
+
+// blameCommit holds the details of a commit that blame shows
+type blameCommit struct {
+	author, committer objects.Ident
+	summary           string
+	// boundary is set for root commits, where the history ends
+	boundary bool
+}
+
+func (h *blameHistory) details(sha, path string) (blameCommit, error) {
+	if sha == uncommitted {
+		ident := objects.Ident{Name: "Not Committed Yet", Email: "not.committed.yet", When: h.now}
+		return blameCommit{author: ident, committer: ident, summary: fmt.Sprintf("Version of %s from %s", path, path)}, nil
+	}
+	commit, err := h.commit(sha)
+	if err != nil {
+		return blameCommit{}, err
+	}
+	author, _ := commit.GetValue("author")
+	committer, _ := commit.GetValue("committer")
+	summary, _, _ := strings.Cut(strings.TrimLeft(commit.Message(), "\n"), "\n")
+	return blameCommit{
+		author:    objects.ParseIdent(author),
+		committer: objects.ParseIdent(committer),
+		summary:   summary,
+		boundary:  len(commit.GetValues("parent")) == 0,
+	}, nil
+}
+
+// lines returns the lines of the file that is blamed
+func (h *blameHistory) lines() ([][]byte, error) {
+	data, _, err := h.File(h.start, h.path)
+	return diff.SplitLines(data), err
+}
+
+// writeBlame writes a line per line of the file, with the commit, the
+// author and the date
+func writeBlame(w io.Writer, h *blameHistory, entries []blame.Entry, long, short bool) error {
+	lines, err := h.lines()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b blame.Entry) int { return a.Start - b.Start })
+
+	commits := map[string]blameCommit{}
+	authorWidth := 0
+	for _, e := range entries {
+		details, err := h.details(e.Commit, "")
+		if err != nil {
+			return err
+		}
+		commits[e.Commit] = details
+		authorWidth = max(authorWidth, utf8.RuneCountInString(details.author.Name))
+	}
+	numberWidth := len(fmt.Sprint(len(lines)))
+
+	for _, e := range entries {
+		details := commits[e.Commit]
+		hash := e.Commit[:8]
+		if long {
+			hash = e.Commit
+		}
+		if details.boundary {
+			hash = "^" + hash[:len(hash)-1]
+		}
+		for i := e.Start; i < e.Start+e.Lines; i++ {
+			line := strings.TrimSuffix(string(lines[i]), "\n")
+			if short {
+				fmt.Fprintf(w, "%s %*d) %s\n", hash, numberWidth, i+1, line)
+				continue
+			}
+			author := details.author.Name + strings.Repeat(" ", authorWidth-utf8.RuneCountInString(details.author.Name))
+			date := details.author.When.Format("2006-01-02 15:04:05 -0700")
+			fmt.Fprintf(w, "%s (%s %s %*d) %s\n", hash, author, date, numberWidth, i+1, line)
+		}
+	}
+	return nil
+}
+
+// writeBlamePorcelain writes a header per group of lines from the same
+// commit, followed by the lines. The details of a commit are written the
+// first time it occurs, or for every line with repeat.
+func writeBlamePorcelain(w io.Writer, h *blameHistory, entries []blame.Entry, path string, repeat bool) error {
+	lines, err := h.lines()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b blame.Entry) int { return a.Start - b.Start })
+
+	shown := map[string]bool{}
+	for _, e := range entries {
+		for i := 0; i < e.Lines; i++ {
+			if i == 0 {
+				fmt.Fprintf(w, "%s %d %d %d\n", e.Commit, e.OrigStart+1, e.Start+1, e.Lines)
+			} else {
+				fmt.Fprintf(w, "%s %d %d\n", e.Commit, e.OrigStart+i+1, e.Start+i+1)
+			}
+			if (i == 0 && !shown[e.Commit]) || repeat {
+				if err := writeBlameDetails(w, h, e, path); err != nil {
+					return err
+				}
+				shown[e.Commit] = true
+			}
+			line := string(lines[e.Start+i])
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			fmt.Fprintf(w, "\t%s", line)
+		}
+	}
+	return nil
+}
+
+// writeBlameIncremental writes the groups of lines as blame found them,
+// without the lines themselves. The details of a commit are written the
+// first time it occurs.
+func writeBlameIncremental(w io.Writer, h *blameHistory, entries []blame.Entry, path string) error {
+	shown := map[string]bool{}
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s %d %d %d\n", e.Commit, e.OrigStart+1, e.Start+1, e.Lines)
+		if !shown[e.Commit] {
+			if err := writeBlameDetails(w, h, e, path); err != nil {
+				return err
+			}
+			shown[e.Commit] = true
+			continue
+		}
+		writeBlameFilename(w, e, path)
+	}
+	return nil
+}
+
+func writeBlameDetails(w io.Writer, h *blameHistory, e blame.Entry, path string) error {
+	details, err := h.details(e.Commit, path)
+	if err != nil {
+		return err
+	}
+	for _, who := range []struct {
+		role  string
+		ident objects.Ident
+	}{{"author", details.author}, {"committer", details.committer}} {
+		fmt.Fprintf(w, "%s %s\n", who.role, who.ident.Name)
+		fmt.Fprintf(w, "%s-mail <%s>\n", who.role, who.ident.Email)
+		fmt.Fprintf(w, "%s-time %d\n", who.role, who.ident.When.Unix())
+		fmt.Fprintf(w, "%s-tz %s\n", who.role, who.ident.When.Format("-0700"))
+	}
+	fmt.Fprintf(w, "summary %s\n", details.summary)
+	if details.boundary {
+		fmt.Fprintln(w, "boundary")
+	}
+	writeBlameFilename(w, e, path)
+	return nil
+}
+
+func writeBlameFilename(w io.Writer, e blame.Entry, path string) {
+	if e.Previous != "" {
+		fmt.Fprintf(w, "previous %s %s\n", e.Previous, path)
+	}
+	fmt.Fprintf(w, "filename %s\n", path)
+}
+
+// writeAnnotate writes a line per line of the file like git annotate: the
+// commit, author, date and line number, separated by tabs
+func writeAnnotate(w io.Writer, h *blameHistory, entries []blame.Entry) error {
+	lines, err := h.lines()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b blame.Entry) int { return a.Start - b.Start })
+	for _, e := range entries {
+		details, err := h.details(e.Commit, "")
+		if err != nil {
+			return err
+		}
+		date := details.author.When.Format("2006-01-02 15:04:05 -0700")
+		for i := e.Start; i < e.Start+e.Lines; i++ {
+			line := strings.TrimSuffix(string(lines[i]), "\n")
+			fmt.Fprintf(w, "%s\t(%10s\t%10s\t%d)%s\n", e.Commit[:8], details.author.Name, date, i+1, line)
+		}
+	}
+	return nil
+}
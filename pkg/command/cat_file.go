@@ -2,8 +2,11 @@ package command
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"os"
 
+	"github.com/jessegeens/got/pkg/format"
 	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
 )
@@ -11,25 +14,70 @@ import (
 func CatFileCommand() *Command {
 	command := newCommand("cat-file")
 	command.Action = func(args []string) error {
-		if len(args) < 1 {
-			return errors.New("must provide object hash as an argument")
+		command.ResetFlags()
+		showType := flag.Bool("t", false, "Show the type of the object")
+		showSize := flag.Bool("s", false, "Show the size of the object")
+		exists := flag.Bool("e", false, "Only exit with a non-zero status if the object doesn't exist")
+		pretty := flag.Bool("p", false, "Show the contents of the object, with trees listed like ls-tree does")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		// Like git, the type can come before the object, which is then
+		// peeled to that type
+		expected := objects.TypeNoTypeSpecified
+		name := flag.Arg(0)
+		if flag.NArg() == 2 {
+			var err error
+			if expected, err = objects.ParseType(flag.Arg(0)); err != nil {
+				return err
+			}
+			name = flag.Arg(1)
+		}
+		if flag.NArg() < 1 || flag.NArg() > 2 {
+			return errors.New("usage: got cat-file [-t | -s | -e | -p] <object> | got cat-file <type> <object>")
 		}
-		objHash := args[0]
+
 		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
-		sha, err := objects.Find(repo, objHash, objects.TypeNoTypeSpecified, true)
+		sha, err := objects.Find(repo, name, expected, true)
 		if err != nil {
 			return err
 		}
-		object, err := objects.ReadObject(repo, sha)
+
+		switch {
+		case *exists:
+			if !objects.HasObject(repo, sha) {
+				return fmt.Errorf("object %s doesn't exist", sha.AsString())
+			}
+			return nil
+		case *showType || *showSize:
+			objType, size, err := objects.ReadHeader(repo, sha)
+			if err != nil {
+				return err
+			}
+			if *showType {
+				fmt.Println(objType)
+			} else {
+				fmt.Println(size)
+			}
+			return nil
+		}
+
+		objType, contents, err := objects.ReadRaw(repo, sha)
 		if err != nil {
 			return err
 		}
-		fmt.Println(object.Serialize())
-
-		return nil
+		if *pretty && objType == string(objects.TypeTree) {
+			tmpl, err := format.Parse(lsTreeFormat, lsTreeFields)
+			if err != nil {
+				return err
+			}
+			return lsTree(repo, tmpl, sha.AsString(), "", false)
+		}
+		_, err = os.Stdout.Write(contents)
+		return err
 	}
 	command.Description = func() string { return "Provide content of repository objects" }
 	return command
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func CheckAttrCommand() *Command {
+	command := newCommand("check-attr")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		all := flag.Bool("all", false, "List all attributes that are set on the paths")
+		shortAll := flag.Bool("a", false, "List all attributes that are set on the paths")
+		stdin := flag.Bool("stdin", false, "Read paths from standard input, one per line")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		*all = *all || *shortAll
+
+		names, paths, err := checkAttrArgs(flag.Args(), *all, *stdin)
+		if err != nil {
+			return err
+		}
+
+		if *stdin {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				paths = append(paths, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		attrs, err := attributes.Read(repo)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			relPath, err := worktreePath(repo, path)
+			if err != nil {
+				return err
+			}
+
+			if *all {
+				values := attrs.All(relPath)
+				sorted := make([]string, 0, len(values))
+				for name := range values {
+					sorted = append(sorted, name)
+				}
+				sort.Strings(sorted)
+				for _, name := range sorted {
+					fmt.Printf("%s: %s: %s\n", path, name, values[name])
+				}
+				continue
+			}
+
+			for _, name := range names {
+				fmt.Printf("%s: %s: %s\n", path, name, attrs.Get(relPath, name))
+			}
+		}
+
+		return nil
+	}
+	command.Description = func() string { return "Display gitattributes information" }
+	return command
+}
+
+// checkAttrArgs splits the arguments in attribute names and paths. Like git,
+// we accept `attr... -- path...`, or a single attribute followed by paths.
+func checkAttrArgs(args []string, all, stdin bool) ([]string, []string, error) {
+	sep := slices.Index(args, "--")
+
+	var names, paths []string
+	switch {
+	case all:
+		if sep >= 0 {
+			if sep > 0 {
+				return nil, nil, errors.New("attributes cannot be specified together with --all")
+			}
+			args = args[1:]
+		}
+		paths = args
+	case sep >= 0:
+		names, paths = args[:sep], args[sep+1:]
+	case stdin:
+		names = args
+	case len(args) > 0:
+		names, paths = args[:1], args[1:]
+	}
+
+	if !all && len(names) == 0 {
+		return nil, nil, errors.New("no attribute specified")
+	}
+	if stdin && len(paths) > 0 {
+		return nil, nil, errors.New("cannot combine paths with --stdin")
+	}
+	if !stdin && len(paths) == 0 {
+		return nil, nil, errors.New("no path specified")
+	}
+	return names, paths, nil
+}
+
+// worktreePath returns path relative to the worktree, separated by slashes
+func worktreePath(repo *repository.Repository, path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := filepath.Rel(repo.WorkTree(), absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", fmt.Errorf("'%s' is outside repository", path)
+	}
+	return filepath.ToSlash(relPath), nil
+}
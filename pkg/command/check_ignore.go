@@ -1,9 +1,13 @@
 package command
 
 import (
+	"errors"
 	"flag"
-	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
+	"github.com/jessegeens/got/pkg/format"
 	"github.com/jessegeens/got/pkg/ignore"
 	"github.com/jessegeens/got/pkg/repository"
 )
@@ -11,13 +15,22 @@ import (
 func CheckIgnoreCommand() *Command {
 	command := newCommand("check-ignore")
 	command.Action = func(args []string) error {
-		paths := []string{}
-		firstPath := *flag.String("path", "", "Paths to check")
-		flag.Parse()
-		if firstPath != "" {
-			paths = []string{firstPath}
-			tail := flag.Args()
-			paths = append(paths, tail...)
+		command.ResetFlags()
+		firstPath := flag.String("path", "", "Paths to check")
+		verbose := flag.Bool("v", false, "Also show the rule that matched each path")
+		verboseLong := flag.Bool("verbose", false, "Same as -v")
+		nonMatching := flag.Bool("n", false, "With -v, also show paths that don't match any rule")
+		nonMatchingLong := flag.Bool("non-matching", false, "Same as -n")
+		nul := flag.Bool("z", false, "End output with NUL instead of newline, and don't quote paths; with -v, every field ends with NUL")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		paths := flag.Args()
+		if *firstPath != "" {
+			paths = append([]string{*firstPath}, paths...)
+		}
+		if len(paths) == 0 {
+			return errors.New("no path specified")
 		}
 
 		repo, err := repository.Find(".")
@@ -29,10 +42,37 @@ func CheckIgnoreCommand() *Command {
 		if err != nil {
 			return err
 		}
+		records := format.NewRecords(os.Stdout, *nul)
 
 		for _, path := range paths {
-			if ign.ShouldBeIgnored(path) {
-				fmt.Println(path)
+			relPath, err := worktreePath(repo, path)
+			if err != nil {
+				return err
+			}
+			if strings.HasSuffix(path, "/") {
+				// A trailing slash makes it a directory
+				relPath += "/"
+			}
+			explanation := ign.Explain(relPath)
+
+			if !*verbose && !*verboseLong {
+				if explanation.Ignored {
+					if err := records.Write(records.Path(path)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			// Like git, the verbose output shows negated rules as well
+			fields := []string{}
+			if rule := explanation.Deciding(); rule != nil {
+				fields = []string{rule.Source, strconv.Itoa(rule.Line), rule.String(), records.Path(path)}
+			} else if *nonMatching || *nonMatchingLong {
+				fields = []string{"", "", "", records.Path(path)}
+			}
+			if err := writeCheckIgnoreFields(records, fields, *nul); err != nil {
+				return err
 			}
 		}
 
@@ -41,3 +81,21 @@ func CheckIgnoreCommand() *Command {
 	command.Description = func() string { return "Check path(s) against ignore rules" }
 	return command
 }
+
+// writeCheckIgnoreFields writes the source, line number, pattern and path
+// of a verbose line, as "source:line:pattern<TAB>path", or with -z as
+// separate records, which is how git does it
+func writeCheckIgnoreFields(records *format.Records, fields []string, nul bool) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	if !nul {
+		return records.Write(strings.Join(fields[:3], ":") + "\t" + fields[3])
+	}
+	for _, field := range fields {
+		if err := records.Write(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
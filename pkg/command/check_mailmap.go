@@ -0,0 +1,79 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/mailmap"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func CheckMailmapCommand() *Command {
+	command := newCommand("check-mailmap")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		stdin := flag.Bool("stdin", false, "Also read contacts from stdin, one per line")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() == 0 && !*stdin {
+			return errors.New("usage: got check-mailmap [--stdin] <contact>...")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		m, err := mailmap.Load(repo)
+		if err != nil {
+			return err
+		}
+
+		w := bufio.NewWriter(os.Stdout)
+		for _, contact := range flag.Args() {
+			if err := checkMailmap(w, m, contact); err != nil {
+				return err
+			}
+		}
+		if *stdin {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				if err := checkMailmap(w, m, scanner.Text()); err != nil {
+					return err
+				}
+				// Scripts may wait for each answer before writing the next contact
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+			if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+		}
+		return w.Flush()
+	}
+	command.Description = func() string { return "Show the canonical names and emails of contacts according to the mailmap" }
+	return command
+}
+
+// checkMailmap prints the canonical form of a contact, which is either
+// "Name <email>" or "<email>"
+func checkMailmap(w io.Writer, m *mailmap.Mailmap, contact string) error {
+	start := strings.IndexByte(contact, '<')
+	end := strings.LastIndexByte(contact, '>')
+	if start < 0 || end < start {
+		return fmt.Errorf("unable to parse contact: %s", contact)
+	}
+	name, email := m.Resolve(strings.TrimSpace(contact[:start]), contact[start+1:end])
+	if name == "" {
+		_, err := fmt.Fprintf(w, "<%s>\n", email)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s <%s>\n", name, email)
+	return err
+}
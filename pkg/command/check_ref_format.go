@@ -0,0 +1,51 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/references"
+)
+
+func CheckRefFormatCommand() *Command {
+	command := newCommand("check-ref-format")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		normalize := flag.Bool("normalize", false, "Normalize the name and print it")
+		allowOneLevel := flag.Bool("allow-onelevel", false, "Accept names without a slash")
+		refspecPattern := flag.Bool("refspec-pattern", false, "Accept a single * as a component")
+		branch := flag.Bool("branch", false, "Check a branch name and print it")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() != 1 {
+			return errors.New("usage: got check-ref-format [--normalize] [--allow-onelevel] [--refspec-pattern] <refname>\n\t got check-ref-format --branch <branchname>")
+		}
+		name := flag.Arg(0)
+
+		if *branch {
+			// Branch names are checked as refs/heads/<name>
+			if strings.HasPrefix(name, "-") || references.CheckName("refs/heads/"+name, references.CheckOptions{}) != nil {
+				return fmt.Errorf("'%s' is not a valid branch name", name)
+			}
+			fmt.Println(name)
+			return nil
+		}
+
+		if *normalize {
+			name = references.NormalizeName(name)
+		}
+		opts := references.CheckOptions{AllowOneLevel: *allowOneLevel, RefspecPattern: *refspecPattern}
+		if err := references.CheckName(name, opts); err != nil {
+			return err
+		}
+		if *normalize {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	command.Description = func() string { return "Ensure that a reference name is well formed" }
+	return command
+}
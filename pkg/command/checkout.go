@@ -3,12 +3,23 @@ package command
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"io"
-	"io/fs"
+	iofs "io/fs"
+	"maps"
 	"os"
+	pathpkg "path"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/checkout"
+	"github.com/jessegeens/got/pkg/filter"
+	"github.com/jessegeens/got/pkg/fs"
 	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
 	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
 )
@@ -16,13 +27,38 @@ import (
 func CheckoutCommand() *Command {
 	command := newCommand("checkout")
 	command.Action = func(args []string) error {
-		commit := *flag.String("commit", "", "The commit or tree to checkout")
-		path := *flag.String("path", "", "The empty directory to checkout on")
+		command.ResetFlags()
+		commitFlag := flag.String("commit", "", "The commit or tree to checkout")
+		pathFlag := flag.String("path", "", "The empty directory to checkout on")
+		ours := flag.Bool("ours", false, "Check out our version of unmerged paths")
+		theirs := flag.Bool("theirs", false, "Check out their version of unmerged paths")
+		force := flag.Bool("force", false, "Overwrite files and directories that are in the way")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		commit, path := *commitFlag, *pathFlag
 		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
 
+		// With paths, the files are checked out from the index
+		if *ours || *theirs || len(flag.Args()) > 0 {
+			if *ours && *theirs {
+				return errors.New("--ours and --theirs are incompatible")
+			}
+			n, err := restoreFromIndex(repo, "checkout", flag.Args(), stageFlag(*ours, *theirs))
+			if err != nil {
+				return err
+			}
+			if n == 1 {
+				fmt.Fprintln(os.Stderr, "Updated 1 path from the index")
+			} else {
+				fmt.Fprintf(os.Stderr, "Updated %d paths from the index\n", n)
+			}
+			return nil
+		}
+
 		commitHash, err := hashing.NewShaFromHex(commit)
 		if err != nil {
 			return err
@@ -32,16 +68,17 @@ func CheckoutCommand() *Command {
 			return err
 		}
 
+		if object.Type() != objects.TypeTree {
+			return errors.New("ref should point to a tree")
+		}
+		tree := object.(*objects.Tree)
+
 		finfo, err := os.Stat(path)
 		if err == nil {
-			// exists, check that it is a directory and is empty
 			if !finfo.IsDir() {
 				return errors.New("Not a directory: " + path)
 			}
-			if !isEmptyDirectory(path) {
-				return errors.New("Not empty: " + path)
-			}
-		} else if errors.Is(err, fs.ErrNotExist) {
+		} else if errors.Is(err, iofs.ErrNotExist) {
 			err = os.MkdirAll(path, os.ModePerm)
 			if err != nil {
 				return err
@@ -50,51 +87,241 @@ func CheckoutCommand() *Command {
 			return err
 		}
 
-		if object.Type() != objects.TypeTree {
-			return errors.New("ref should point to a tree")
+		attrs, err := attributes.Read(repo)
+		if err != nil {
+			return err
+		}
+
+		// Files in the way are only overwritten when forced
+		if !*force {
+			conflicts, err := checkoutConflicts(repo, attrs, tree, path, fs.CaseInsensitive(path))
+			if err != nil {
+				return err
+			}
+			if len(conflicts) > 0 {
+				var b strings.Builder
+				b.WriteString("checkout would overwrite these paths, use --force to check out anyway:")
+				for _, c := range conflicts {
+					fmt.Fprintf(&b, "\n\t%s: %s", c.path, c.reason)
+				}
+				return errors.New(b.String())
+			}
 		}
-		tree := object.(*objects.Tree)
 
-		return treeCheckout(repo, tree, path)
+		return treeCheckout(repo, attrs, commitHash, path)
 	}
-	command.Description = func() string { return "Checkout a commit inside of a directory" }
+	command.Description = func() string { return "Checkout a commit in a directory, or paths from the index" }
 	return command
 }
 
-func treeCheckout(repo *repository.Repository, tree *objects.Tree, path string) error {
-	for _, item := range tree.Items {
-		obj, err := objects.ReadObject(repo, item.Sha)
-		if err != nil {
-			return err
+// treeCheckout writes the tree to path. Whatever is in the way of its
+// files and directories is replaced.
+func treeCheckout(repo *repository.Repository, attrs *attributes.Attributes, tree *hashing.SHA, path string) error {
+	files := map[string]*objects.TreeLeaf{}
+	if err := treeLeaves(repo, tree.AsString(), "", files); err != nil {
+		return err
+	}
+	return checkoutFiles(repo, attrs, path, files)
+}
+
+// checkoutFiles writes files, by their paths below root, in parallel and
+// with a progress meter for large checkouts
+func checkoutFiles(repo *repository.Repository, attrs *attributes.Attributes, root string, files map[string]*objects.TreeLeaf) error {
+	list := make([]checkout.File, 0, len(files))
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		leaf := files[name]
+		mode := strings.TrimLeft(string(leaf.Mode), "0")
+		list = append(list, checkout.File{Name: name, SHA: leaf.Sha, Mode: mode})
+	}
+	smudge := func(name string, contents []byte) ([]byte, error) {
+		return filter.Smudge(filter.ForPath(attrs, name), contents)
+	}
+	workers, err := checkoutWorkers(repo)
+	if err != nil {
+		return err
+	}
+	return checkout.Write(repo, root, list, checkout.Options{Workers: workers, Smudge: smudge, Progress: os.Stderr})
+}
+
+// checkoutWorkers returns checkout.workers, the number of files written
+// at the same time. Zero or less, the default, means one per CPU.
+func checkoutWorkers(repo *repository.Repository) (int, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return 0, err
+	}
+	value, _ := cfg.Get("checkout", "workers")
+	workers, _ := strconv.Atoi(value)
+	return workers, nil
+}
+
+// checkoutConflict is a path that checking out a tree would clobber
+type checkoutConflict struct {
+	path   string
+	reason string
+}
+
+// checkoutConflicts returns the paths below path that are in the way of
+// checking out tree: files with local modifications or untracked files
+// that differ from the tree, files and directories where the tree has the
+// other kind, and on case-insensitive filesystems, names that would end up
+// being the same file.
+func checkoutConflicts(repo *repository.Repository, attrs *attributes.Attributes, tree *objects.Tree, path string, caseInsensitive bool) ([]checkoutConflict, error) {
+	tracked, err := trackedBelow(repo, path)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []checkoutConflict{}
+	err = findCheckoutConflicts(repo, attrs, tree, path, "", caseInsensitive, tracked, &conflicts)
+	return conflicts, err
+}
+
+func findCheckoutConflicts(repo *repository.Repository, attrs *attributes.Attributes, tree *objects.Tree, path, prefix string, caseInsensitive bool, tracked map[string]*hashing.SHA, conflicts *[]checkoutConflict) error {
+	// The names that are in the directory, by their lower case, to find
+	// the ones that only differ in case from the tree
+	existing := map[string]string{}
+	if caseInsensitive {
+		entries, _ := os.ReadDir(path)
+		for _, entry := range entries {
+			existing[strings.ToLower(entry.Name())] = entry.Name()
 		}
+	}
+	inTree := map[string]string{}
 
+	for _, item := range tree.Items {
 		dest := filepath.Join(path, item.PrintPath())
+		name := pathpkg.Join(prefix, item.PrintPath())
+		mode := strings.TrimLeft(string(item.Mode), "0")
+		isTree := mode == "40000"
+		conflict := func(reason string) {
+			*conflicts = append(*conflicts, checkoutConflict{name, reason})
+		}
 
-		if obj.Type() == objects.TypeTree {
-			os.Mkdir(dest, os.ModePerm)
-			return treeCheckout(repo, tree, dest)
-		} else if obj.Type() == objects.TypeBlob {
-			data, err := obj.Serialize()
-			if err != nil {
-				return err
+		if caseInsensitive {
+			folded := strings.ToLower(item.PrintPath())
+			if other, ok := inTree[folded]; ok {
+				conflict("collides with " + pathpkg.Join(prefix, other) + " on this case-insensitive filesystem")
+				continue
+			}
+			inTree[folded] = item.PrintPath()
+			if other, ok := existing[folded]; ok && other != item.PrintPath() {
+				conflict("collides with the existing " + pathpkg.Join(prefix, other) + " on this case-insensitive filesystem")
+				continue
 			}
+		}
 
-			f, err := os.Create(dest)
-			if err != nil {
-				return err
+		info, exists, err := fs.Lstat(dest)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !exists:
+			if !isTree {
+				continue
 			}
-			defer f.Close()
-			f.Write(data)
-			err = f.Sync()
+		case isTree && !info.IsDir():
+			conflict("is a file, where the tree has a directory")
+			continue
+		case mode == "160000" && info.IsDir():
+			// Submodules are directories, which checkout leaves alone
+			continue
+		case !isTree && info.IsDir():
+			conflict("is a directory, where the tree has a file")
+			continue
+		case !isTree:
+			current, err := existingBlob(attrs, dest, name, info)
 			if err != nil {
 				return err
 			}
+			index, isTracked := tracked[name]
+			switch {
+			case current == nil:
+				conflict("is not a regular file")
+			case sameBlob(current, item.Sha):
+				// It already is what the tree has
+			case !isTracked:
+				conflict("is untracked")
+			case !sameBlob(current, index):
+				conflict("has local modifications")
+			}
+			continue
+		}
 
+		// Directories are checked further down, even when they don't exist
+		// yet, for names that collide
+		obj, err := objects.ReadObject(repo, item.Sha)
+		if err != nil {
+			return err
+		}
+		subtree, ok := obj.(*objects.Tree)
+		if !ok {
+			return fmt.Errorf("%s is not a tree", item.Sha.AsString())
+		}
+		if err := findCheckoutConflicts(repo, attrs, subtree, dest, name, caseInsensitive, tracked, conflicts); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// trackedBelow returns the blobs in the index of the files below path, by
+// their names relative to path. Outside of the worktree, no files are
+// tracked.
+func trackedBelow(repo *repository.Repository, path string) (map[string]*hashing.SHA, error) {
+	tracked := map[string]*hashing.SHA{}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(repo.WorkTree(), abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return tracked, nil
+	}
+	idx, err := index.Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.ToSlash(rel) + "/"
+	for name, sha := range pathsFromIndex(idx) {
+		if rel == "." {
+			tracked[name] = sha
+		} else if below, ok := strings.CutPrefix(name, dir); ok {
+			tracked[below] = sha
+		}
+	}
+	return tracked, nil
+}
+
+// existingBlob returns the hash the file at dest would have as a blob:
+// the target of a symbolic link, or the contents of a regular file once
+// the clean filters of name are applied to them. Other kinds of files
+// have no blob, so it returns nil for them.
+func existingBlob(attrs *attributes.Attributes, dest, name string, info iofs.FileInfo) (*hashing.SHA, error) {
+	var contents []byte
+	switch {
+	case info.Mode()&iofs.ModeSymlink != 0:
+		target, err := os.Readlink(dest)
+		if err != nil {
+			return nil, err
+		}
+		contents = []byte(target)
+	case info.Mode().IsRegular():
+		raw, err := os.ReadFile(dest)
+		if err != nil {
+			return nil, err
+		}
+		contents, err = filter.Clean(filter.ForPath(attrs, name), raw)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	blob := &objects.Blob{}
+	blob.Deserialize(contents)
+	return objects.CalculateSha(blob)
+}
+
 func isEmptyDirectory(path string) bool {
 	f, err := os.Open(path)
 	if err != nil {
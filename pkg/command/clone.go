@@ -0,0 +1,418 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/remote"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/transport"
+)
+
+// CloneCommand clones a remote repository.
+//
+// Examples:
+//
+//	got clone https://example.com/project.git
+//	got clone -b topic -o upstream host:project.git work
+//	got clone --single-branch --no-tags https://example.com/project.git
+func CloneCommand() *Command {
+	command := newCommand("clone")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var opts cloneOptions
+		flag.StringVar(&opts.branch, "branch", "", "Check out this branch, or tag, of the remote instead of its HEAD")
+		flag.StringVar(&opts.branch, "b", "", "Same as --branch")
+		flag.StringVar(&opts.origin, "origin", "origin", "Name of the remote")
+		flag.StringVar(&opts.origin, "o", "origin", "Same as --origin")
+		flag.BoolVar(&opts.noCheckout, "no-checkout", false, "Don't check out HEAD after the clone")
+		flag.BoolVar(&opts.noCheckout, "n", false, "Same as --no-checkout")
+		flag.BoolVar(&opts.singleBranch, "single-branch", false, "Only fetch the branch that is checked out, now and in later fetches")
+		flag.BoolVar(&opts.noTags, "no-tags", false, "Don't fetch any tags, now or in later fetches")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() < 1 || flag.NArg() > 2 {
+			return errors.New("usage: got clone [-b <branch>] [-o <name>] [-n] [--single-branch] [--no-tags] <repository> [<directory>]")
+		}
+		if references.CheckName(references.RemoteHead(opts.origin).String(), references.CheckOptions{}) != nil {
+			return fmt.Errorf("'%s' is not a valid remote name", opts.origin)
+		}
+		url := flag.Arg(0)
+		dir := cloneDir(url)
+		if flag.NArg() == 2 {
+			dir = flag.Arg(1)
+		}
+
+		existed := false
+		partial := interruptedClone(dir)
+		if info, err := os.Stat(dir); err == nil {
+			if !info.IsDir() || (!isEmptyDirectory(dir) && partial == nil) {
+				return fmt.Errorf("destination path '%s' already exists and is not an empty directory", dir)
+			}
+			existed = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		cfg, err := config.Read()
+		if err != nil {
+			return err
+		}
+		t, err := transport.Open(remote.FetchURLs(cfg, url)[0], cfg)
+		if err != nil {
+			return err
+		}
+		defer t.Close()
+		fmt.Fprintf(os.Stderr, "Cloning into '%s'...\n", dir)
+		adv, err := t.Advertise(transport.UploadPack)
+		if err != nil {
+			return err
+		}
+
+		// An interrupted clone starts over, but with what it got already
+		if partial != nil {
+			removeClone(dir, true)
+		}
+		templates, err := templateDir("")
+		if err != nil {
+			return err
+		}
+		repo, err := repository.CreateWithOptions(dir, repository.CreateOptions{TemplateDir: templates, Quiet: true})
+		if err != nil {
+			return err
+		}
+		if partial != nil {
+			if _, err := repo.RepositoryDir(true, "objects", "pack"); err != nil {
+				return err
+			}
+			if err := os.WriteFile(repo.RepositoryPath("objects", "pack", objects.PartialPackName), partial, 0o444); err != nil {
+				return err
+			}
+		}
+		// Like git, a clone that fails leaves nothing behind, unless part of
+		// the pack arrived, which the next clone into dir goes on from
+		if err := clone(repo, t, adv, url, opts); err != nil {
+			if _, statErr := os.Stat(repo.RepositoryPath("objects", "pack", objects.PartialPackName)); statErr == nil {
+				fmt.Fprintf(os.Stderr, "hint: the clone was interrupted; run it again to resume into '%s'\n", dir)
+			} else {
+				removeClone(dir, existed)
+			}
+			return err
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Clone a remote repository into a new directory and check out its default branch"
+	}
+	return command
+}
+
+// cloneOptions say what a clone fetches and checks out
+type cloneOptions struct {
+	// origin is the name of the remote
+	origin string
+	// branch is the branch or tag of the remote to check out, instead of
+	// the branch of its HEAD
+	branch     string
+	noCheckout bool
+	// singleBranch only fetches the branch, or tag, that is checked out,
+	// and configures the remote to only fetch that branch
+	singleBranch bool
+	// noTags doesn't fetch tags, and configures the remote not to either
+	noTags bool
+}
+
+// clone fetches the branches and tags of the remote into a new repository,
+// sets up the remote and checks out a branch
+func clone(repo *repository.Repository, t transport.Transport, adv *transport.Advertisement, url string, opts cloneOptions) error {
+	origin, branch := opts.origin, opts.branch
+	remoteHead := defaultBranch(adv)
+	if branch == "" {
+		branch = remoteHead
+	}
+	tracking := references.RemotesPrefix + origin + "/"
+	// A single branch clone only fetches the branch, or the tag, it checks
+	// out, in later fetches too
+	refspec := "+refs/heads/*:" + tracking + "*"
+	if opts.singleBranch && branch != "" {
+		if advertised(adv, "refs/heads/"+branch) {
+			refspec = "+refs/heads/" + branch + ":" + references.RemoteBranch(origin, branch).String()
+		} else if advertised(adv, "refs/tags/"+branch) {
+			refspec = "+refs/tags/" + branch + ":refs/tags/" + branch
+		} else {
+			return fmt.Errorf("remote branch %s not found in upstream %s", branch, origin)
+		}
+	}
+	entries := []config.Entry{{Key: "url", Value: url}, {Key: "fetch", Value: refspec}}
+	if opts.noTags {
+		entries = append(entries, config.Entry{Key: "tagOpt", Value: "--no-tags"})
+	}
+	err := config.AppendSection(repo.RepositoryPath("config"), `remote "`+origin+`"`, entries...)
+	if err != nil {
+		return err
+	}
+	spec, err := remote.ParseRefspec(refspec)
+	if err != nil {
+		return err
+	}
+	// With a single branch, the tags come along when they point to what is
+	// fetched, like in a fetch
+	cloned := func(name string) bool {
+		if _, ok := spec.Match(name); ok {
+			return true
+		}
+		return strings.HasPrefix(name, "refs/tags/") && !opts.noTags && !opts.singleBranch
+	}
+
+	wants := []string{}
+	for _, ref := range adv.Refs {
+		if cloned(ref.Name.String()) && !slices.Contains(wants, ref.SHA) {
+			wants = append(wants, ref.SHA)
+		}
+	}
+	if len(wants) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: You appear to have cloned an empty repository.")
+		return nil
+	}
+	check, err := incomingCheck(repo, "fetch", false)
+	if err != nil {
+		return err
+	}
+	haves, err := objects.RecoverPartialPack(repo, check)
+	if err != nil {
+		return err
+	}
+	req := transport.FetchRequest{Wants: wants, Haves: haves, IncludeTag: opts.singleBranch && !opts.noTags, Progress: os.Stderr}
+	pack, err := transport.Fetch(t, adv, req)
+	if err != nil {
+		return err
+	}
+	_, err = objects.IndexPack(repo, pack, check)
+	if closeErr := pack.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	heads := map[string]string{}
+	tags := map[string]string{}
+	for _, ref := range adv.Refs {
+		name := ref.Name.String()
+		if !cloned(name) && !(req.IncludeTag && strings.HasPrefix(name, "refs/tags/") && hasObject(repo, ref.SHA)) {
+			continue
+		}
+		if head, ok := strings.CutPrefix(name, "refs/heads/"); ok {
+			heads[head] = ref.SHA
+			err = references.Update(repo, references.RemoteBranch(origin, head), ref.SHA)
+		} else if tag, ok := strings.CutPrefix(name, "refs/tags/"); ok {
+			tags[tag] = ref.SHA
+			err = references.Update(repo, references.Reference(name), ref.SHA)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, ok := heads[remoteHead]; ok {
+		err := references.UpdateSymbolic(repo, references.RemoteHead(origin), references.RemoteBranch(origin, remoteHead))
+		if err != nil {
+			return err
+		}
+	}
+
+	// A tag is checked out on a detached HEAD
+	var commit string
+	if sha, ok := heads[branch]; ok {
+		commit = sha
+		err = references.Update(repo, references.Reference("refs/heads/"+branch), sha)
+		if err != nil {
+			return err
+		}
+		err = references.UpdateSymbolic(repo, "HEAD", references.Reference("refs/heads/"+branch))
+		if err != nil {
+			return err
+		}
+		err = config.AppendSection(repo.RepositoryPath("config"), `branch "`+branch+`"`,
+			config.Entry{Key: "remote", Value: origin},
+			config.Entry{Key: "merge", Value: "refs/heads/" + branch})
+	} else if sha, ok := tags[branch]; ok {
+		var peeled *hashing.SHA
+		if peeled, err = hashing.NewShaFromHex(sha); err == nil {
+			if peeled, err = objects.Peel(repo, peeled, objects.TypeCommit); err == nil {
+				commit = peeled.AsString()
+				err = references.Update(repo, "HEAD", commit)
+			}
+		}
+	} else if branch != "" {
+		return fmt.Errorf("remote branch %s not found in upstream %s", branch, origin)
+	}
+	if err != nil {
+		return err
+	}
+	if commit == "" || opts.noCheckout {
+		return nil
+	}
+	return checkoutClone(repo, commit)
+}
+
+// checkoutClone writes the files of commit to the empty worktree and the
+// index, with their modes: executable files, symlinks, and the empty
+// directories of submodules
+func checkoutClone(repo *repository.Repository, commit string) error {
+	sha, err := hashing.NewShaFromHex(commit)
+	if err != nil {
+		return err
+	}
+	c, err := readCommit(repo, sha)
+	if err != nil {
+		return err
+	}
+	tree, _ := c.GetValue("tree")
+	files := map[string]*objects.TreeLeaf{}
+	if err := treeLeaves(repo, string(tree), "", files); err != nil {
+		return err
+	}
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+
+	if err := checkoutFiles(repo, attrs, repo.WorkTree(), files); err != nil {
+		return err
+	}
+
+	entries := []*index.Entry{}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		entry, err := indexEntryFromFile(repo, name, files[name].Sha)
+		if err != nil {
+			return err
+		}
+		switch string(bytes.TrimLeft(files[name].Mode, "0")) {
+		case "120000":
+			entry.ModeType, entry.ModePerms = index.ModeTypeSymlink, 0
+		case "160000":
+			entry.ModeType, entry.ModePerms = index.ModeTypeGitlink, 0
+		case "100755":
+			entry.ModePerms = 0o755
+		}
+		entries = append(entries, entry)
+	}
+	return index.New(entries).Write(repo)
+}
+
+// treeLeaves adds the files of a tree, and of the trees in it, to files
+// by their full paths
+func treeLeaves(repo *repository.Repository, treeRef, prefix string, files map[string]*objects.TreeLeaf) error {
+	sha, err := hashing.NewShaFromHex(treeRef)
+	if err != nil {
+		return err
+	}
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return err
+	}
+	tree, ok := obj.(*objects.Tree)
+	if !ok {
+		return fmt.Errorf("%s is not a tree", treeRef)
+	}
+	for _, leaf := range tree.Items {
+		name := path.Join(prefix, string(leaf.Path))
+		if strings.HasPrefix(string(leaf.Mode), "04") {
+			if err := treeLeaves(repo, leaf.Sha.AsString(), name, files); err != nil {
+				return err
+			}
+			continue
+		}
+		files[name] = leaf
+	}
+	return nil
+}
+
+// defaultBranch returns the branch that the HEAD of a remote is on. Remotes
+// that don't advertise it have HEAD on the branch it points to, master
+// first, like git guesses.
+func defaultBranch(adv *transport.Advertisement) string {
+	if target, ok := adv.Symrefs()["HEAD"]; ok {
+		return strings.TrimPrefix(target, "refs/heads/")
+	}
+	head := ""
+	for _, ref := range adv.Refs {
+		if ref.Name == "HEAD" {
+			head = ref.SHA
+		}
+	}
+	guess := ""
+	for _, ref := range adv.Refs {
+		branch, ok := strings.CutPrefix(ref.Name.String(), "refs/heads/")
+		if !ok || ref.SHA != head {
+			continue
+		}
+		if branch == "master" {
+			return branch
+		}
+		if guess == "" {
+			guess = branch
+		}
+	}
+	return guess
+}
+
+// cloneDir returns the directory a clone goes into without one given: the
+// last part of the URL, without .git
+func cloneDir(url string) string {
+	name := strings.TrimRight(url, "/")
+	name = strings.TrimSuffix(name, "/.git")
+	name = name[strings.LastIndexAny(name, "/:")+1:]
+	if trimmed := strings.TrimSuffix(name, ".git"); trimmed != "" {
+		name = trimmed
+	}
+	return name
+}
+
+// advertised reports whether the remote has a ref
+func advertised(adv *transport.Advertisement, name string) bool {
+	return slices.ContainsFunc(adv.Refs, func(ref references.Ref) bool { return ref.Name.String() == name })
+}
+
+// interruptedClone returns the partial pack of a clone into dir that was
+// interrupted, or nil if dir doesn't hold one. Such a clone has nothing but
+// its .git directory.
+func interruptedClone(dir string) []byte {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != ".git" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".git", "objects", "pack", objects.PartialPackName))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return data
+}
+
+// removeClone removes what a failed clone left in dir, and dir itself if
+// the clone created it
+func removeClone(dir string, existed bool) {
+	if !existed {
+		os.RemoveAll(dir)
+		return
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+}
@@ -3,6 +3,9 @@ package command
 import (
 	"flag"
 	"fmt"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/repository"
 )
 
 // Command is the representation to create commands.
@@ -12,7 +15,19 @@ type Command struct {
 	Action      func(args []string) error
 	Usage       func() string
 	Description func() string
-	ResetFlags  func()
+	// ResetFlags gives the command a new flag set, which the functions of
+	// the flag package then use. Actions call it before they define their
+	// flags, so commands that run in the same process, like in tests, can
+	// define flags with the same name.
+	ResetFlags func()
+}
+
+// ExitStatus is returned by actions that exit with a status but have no
+// error to report, like got diff --no-index when the files differ.
+type ExitStatus int
+
+func (s ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
 }
 
 // newCommand creates a new command.
@@ -29,8 +44,20 @@ func newCommand(name string) *Command {
 		Description: func() string {
 			return "Command description"
 		},
-		FlagSet:    fs,
-		ResetFlags: func() {},
+		FlagSet: fs,
+	}
+	cmd.ResetFlags = func() {
+		cmd.FlagSet = flag.NewFlagSet(name, flag.ExitOnError)
+		flag.CommandLine = cmd.FlagSet
 	}
 	return cmd
 }
+
+// currentConfig returns the configuration of the repository got runs in,
+// or outside of a repository the global configuration
+func currentConfig() (config.GitConfig, error) {
+	if repo, err := repository.Find("."); err == nil {
+		return repo.Config()
+	}
+	return config.Read()
+}
@@ -1,31 +1,59 @@
 package command
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"os/exec"
 	"path"
 	"strings"
 	"time"
 
-	gouser "os/user"
-
+	"github.com/jessegeens/got/pkg/commitlint"
 	"github.com/jessegeens/got/pkg/config"
 	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/gitdate"
 	"github.com/jessegeens/got/pkg/hashing"
 	"github.com/jessegeens/got/pkg/index"
-	"github.com/jessegeens/got/pkg/kvlm"
 	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/signature"
+	"github.com/jessegeens/got/pkg/stripspace"
 )
 
 func CommitCommand() *Command {
 	command := newCommand("commit")
 	command.Action = func(args []string) error {
+		command.ResetFlags()
 		message := flag.String("m", "", "Message to associate with this commit")
-		flag.Parse()
-		if message == nil || *message == "" {
-			message = flag.String("message", "", "Message to associate with this commit")
-			flag.Parse()
+		longMessage := flag.String("message", "", "Message to associate with this commit")
+		allowEmpty := flag.Bool("allow-empty", false, "Allow recording a commit that does not change the tree")
+		allowEmptyMessage := flag.Bool("allow-empty-message", false, "Allow recording a commit with an empty message")
+		noVerify := flag.Bool("no-verify", false, "Bypass the pre-commit and commit-msg hooks and callbacks, and the checks of the message")
+		author := flag.String("author", "", "Override the author, given as 'Name <email>'")
+		date := flag.String("date", "", "Override the author date, e.g. in RFC 2822 format, as '<unix timestamp> <zone>' or like '2 days ago'")
+		var template string
+		flag.StringVar(&template, "template", "", "Start the message in the editor from this file instead of commit.template")
+		flag.StringVar(&template, "t", "", "Same as --template")
+		amend := flag.Bool("amend", false, "Replace the last commit, with the message of that commit as the starting point")
+		noEdit := flag.Bool("no-edit", false, "Use the prepared message, like the one of the amended commit, without launching an editor")
+		var sign bool
+		flag.BoolVar(&sign, "gpg-sign", false, "Sign the commit with user.signingKey, in the format of gpg.format")
+		flag.BoolVar(&sign, "S", false, "Same as --gpg-sign")
+		noSign := flag.Bool("no-gpg-sign", false, "Don't sign the commit, even if commit.gpgSign is set")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		messageGiven := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "m" || f.Name == "message" {
+				messageGiven = true
+			}
+		})
+		if *message == "" {
+			message = longMessage
 		}
 
 		repo, err := repository.Find(".")
@@ -33,42 +61,147 @@ func CommitCommand() *Command {
 			return err
 		}
 
-		_, err = commit(repo, *message)
+		_, err = commit(repo, *message, commitOptions{
+			allowEmpty:        *allowEmpty,
+			allowEmptyMessage: *allowEmptyMessage,
+			noVerify:          *noVerify,
+			author:            *author,
+			date:              *date,
+			messageGiven:      messageGiven,
+			template:          template,
+			amend:             *amend,
+			noEdit:            *noEdit,
+			sign:              sign,
+			noSign:            *noSign,
+		})
 		return err
 	}
 	command.Description = func() string { return "Record changes to the repository" }
 	return command
 }
 
-func commit(repo *repository.Repository, message string) (*hashing.SHA, error) {
-	// We ignore errors on purpose, because the user may not have a gitconfig file
-	cfg, _ := config.Read()
+type commitOptions struct {
+	// Record a commit even if its tree is the same as the parent's tree
+	allowEmpty bool
+	// Record a commit even if the message is empty
+	allowEmptyMessage bool
+	// Skip the pre-commit and commit-msg hooks, and the checks of the message
+	noVerify bool
+	// Author as "Name <email>", instead of the configured one
+	author string
+	// Author date, instead of the current time
+	date string
+	// The message was given with -m, so there is no editor
+	messageGiven bool
+	// File to start the message from, instead of commit.template
+	template string
+	// Replace HEAD instead of adding a commit on top of it
+	amend bool
+	// Don't launch the editor on the prepared message
+	noEdit bool
+	// Sign the commit, or don't even if commit.gpgSign is set
+	sign, noSign bool
+}
+
+func commit(repo *repository.Repository, message string, opts commitOptions) (*hashing.SHA, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	author, committer, err := commitIdents(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// We don't have to find the parent, so we can ignore the error
+	head, _ := objects.Find(repo, "HEAD", objects.TypeNoTypeSpecified, true)
+	parents := []*hashing.SHA{}
+	if head != nil {
+		parents = append(parents, head)
+	}
+	merging, err := mergeHeads(repo)
+	if err != nil {
+		return nil, err
+	}
+	if opts.amend {
+		if head == nil {
+			return nil, errors.New("you have nothing to amend")
+		}
+		if len(merging) > 0 {
+			return nil, errors.New("you are in the middle of a merge -- cannot amend")
+		}
+		// The amended commit keeps its author and parents
+		amended, err := readCommit(repo, head)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := amended.GetValue("author"); ok {
+			author = objects.ParseIdent(value)
+		}
+		parents = []*hashing.SHA{}
+		for _, value := range amended.GetValues("parent") {
+			parent, err := hashing.NewShaFromHex(string(value))
+			if err != nil {
+				return nil, err
+			}
+			parents = append(parents, parent)
+		}
+	}
+	parents = append(parents, merging...)
+	author, err = overrideAuthor(author, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.noVerify {
+		if err := repo.RunPreCommit(); err != nil {
+			return nil, err
+		}
+	}
 
 	idx, err := index.Read(repo)
 	if err != nil {
 		return nil, err
 	}
+	if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+		return nil, fmt.Errorf("committing is not possible because you have unmerged files, fix them and use got add to mark them as resolved:\n\t%s", strings.Join(unmerged, "\n\t"))
+	}
 
 	tree, err := objects.TreeFromIndex(repo, idx)
 	if err != nil {
 		return nil, err
 	}
 
-	user, ok := cfg.GetUser()
-	if !ok {
-		systemUser, err := gouser.Current()
-		// TODO: turn into user@host
-		if err == nil {
-			user = systemUser.Username
-		} else {
-			user = "User"
+	// A merge is recorded even if it doesn't change our tree
+	if !opts.allowEmpty && len(merging) == 0 {
+		parentTree := objects.EmptyTreeSHA()
+		if len(parents) > 0 {
+			if parentTree, err = objects.Find(repo, parents[0].AsString(), objects.TypeTree, true); err != nil {
+				return nil, err
+			}
+		}
+		if parentTree.AsString() == tree.AsString() {
+			if opts.amend {
+				return nil, errors.New("you asked to amend the most recent commit, but doing so would make it empty, use --allow-empty to amend it anyway")
+			}
+			return nil, errors.New("nothing to commit, use --allow-empty to record a commit anyway")
 		}
 	}
 
-	// We don't have to find the parent, so we can ignore the error
-	parent, _ := objects.Find(repo, "HEAD", objects.TypeNoTypeSpecified, true)
+	message, err = commitMessage(repo, cfg, message, head, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	commit, err := createCommit(repo, tree, parent, user, message, time.Now())
+	var signer signature.Signer
+	if configured, _ := cfg.GetBool("commit", "gpgSign"); (configured || opts.sign) && !opts.noSign {
+		signer, err = signature.NewSigner(cfg, fmt.Sprintf("%s <%s>", committer.Name, committer.Email))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := objects.CreateSignedCommit(repo, tree, parents, author, committer, message, signer)
 	if err != nil {
 		return commit, err
 	}
@@ -79,72 +212,236 @@ func commit(repo *repository.Repository, message string) (*hashing.SHA, error) {
 		return commit, err
 	}
 
-	// If we are on a branch, we update refs/heads/branch
+	// If we are on a branch, we update refs/heads/branch,
+	// otherwise we update HEAD itself
+	ref := references.Reference("HEAD")
 	if onBranch {
-		file, err := repo.RepositoryFile(true, path.Join("refs/heads", branch))
-		if err != nil {
-			return commit, err
-		}
-
-		err = fs.WriteStringToFile(file, fmt.Sprintf("%s\n", commit.AsString()))
-
-		if err == nil {
-			printCommitResult(branch, message, commit)
-		}
-
+		ref = references.Reference(path.Join("refs/heads", branch))
+	}
+	if err := references.Update(repo, ref, commit.AsString()); err != nil {
 		return commit, err
-	} else {
-		// If we are not on a branch, we update HEAD itself
-		file, err := repo.RepositoryFile(false, "HEAD")
-		if err != nil {
+	}
+
+	// The merge or squash that was in progress is done
+	for _, name := range []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE", "AUTO_MERGE", "SQUASH_MSG"} {
+		if err := os.Remove(repo.RepositoryPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
 			return commit, err
 		}
+	}
 
-		err = fs.WriteStringToFile(file, commit.AsString()+"\n")
+	if onBranch {
+		printCommitResult(branch, message, commit)
+	}
 
-		return commit, err
+	// The commit is recorded, so a failing post-commit hook is not an error
+	if err := repo.RunPostCommit(commit.AsString()); err != nil {
+		fmt.Println(err)
 	}
 
+	return commit, nil
 }
 
-func createCommit(repo *repository.Repository, tree *hashing.SHA, parent *hashing.SHA, author, message string, timestamp time.Time) (*hashing.SHA, error) {
-	data := kvlm.New()
-
-	data.Okv.Set("tree", []byte(tree.AsString()))
+// commitMessage prepares the message in COMMIT_EDITMSG, like git: it
+// starts from the -m message, the amended commit, MERGE_MSG, SQUASH_MSG
+// or the template, in that order. The prepare-commit-msg hook gets to
+// change it, with where it came from as argument, and then it is edited
+// unless it was given with -m or --no-edit is used. The commit-msg hook
+// gets the edited message, and then the callbacks and commitlint check it.
+func commitMessage(repo *repository.Repository, cfg config.GitConfig, message string, head *hashing.SHA, opts commitOptions) (string, error) {
+	var source, sha, template string
+	switch {
+	case opts.messageGiven:
+		source = "message"
+	case opts.amend:
+		amended, err := readCommit(repo, head)
+		if err != nil {
+			return "", err
+		}
+		message, source, sha = amended.Message(), "commit", "HEAD"
+	default:
+		for _, prepared := range []struct{ file, source string }{{"MERGE_MSG", "merge"}, {"SQUASH_MSG", "squash"}} {
+			data, err := os.ReadFile(repo.RepositoryPath(prepared.file))
+			if err == nil {
+				message, source = string(data), prepared.source
+				break
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+		}
+		if source != "" {
+			break
+		}
+		file := opts.template
+		if file == "" {
+			file, _ = cfg.Get("commit", "template")
+		}
+		if file == "" {
+			break
+		}
+		if strings.HasPrefix(file, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				file = path.Join(home, file[2:])
+			}
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("could not read commit message template: %w", err)
+		}
+		message, source, template = string(data), "template", string(data)
+	}
 
-	if parent != nil {
-		data.Okv.Set("parent", []byte(parent.AsString()))
+	edit := !opts.messageGiven && !opts.noEdit
+	var buffer strings.Builder
+	buffer.WriteString(message)
+	if message != "" && !strings.HasSuffix(message, "\n") {
+		buffer.WriteString("\n")
+	}
+	// Comments are only stripped from messages that were edited
+	commentPrefix := ""
+	if edit {
+		commentPrefix = stripspace.CommentPrefix(cfg)
+		instructions := fmt.Sprintf("Please enter the commit message for your changes. Lines starting\nwith '%s' will be ignored, and an empty message aborts the commit.\n", commentPrefix)
+		buffer.WriteString("\n" + stripspace.CommentLines(instructions, commentPrefix))
+	}
+	file := repo.RepositoryPath("COMMIT_EDITMSG")
+	if err := fs.WriteStringToFile(file, buffer.String()); err != nil {
+		return "", err
 	}
 
-	message = strings.TrimSpace(message) + "\n"
-	data.Message = []byte(message)
+	args := []string{file}
+	if source != "" {
+		args = append(args, source)
+	}
+	if sha != "" {
+		args = append(args, sha)
+	}
+	if err := repo.RunHook("prepare-commit-msg", "", args...); err != nil {
+		return "", err
+	}
+	if edit {
+		if err := launchEditor(repo, cfg, file); err != nil {
+			return "", err
+		}
+	}
+	if !opts.noVerify {
+		if err := repo.RunHook("commit-msg", "", file); err != nil {
+			return "", err
+		}
+	}
 
-	author = fmt.Sprintf("%s %d %s", author, time.Now().Unix(), calculateTimeOffset())
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	message = stripspace.Strip(string(data), commentPrefix)
+	if source == "template" && message == stripspace.Strip(template, commentPrefix) && message != "" {
+		return "", errors.New("aborting commit; you did not edit the message")
+	}
+	if message == "" && !opts.allowEmptyMessage {
+		return "", errors.New("aborting commit due to empty commit message")
+	}
 
-	data.Okv.Set("author", []byte(author))
-	data.Okv.Set("committer", []byte(author))
+	if !opts.noVerify {
+		if err := repo.RunCommitMsg(message); err != nil {
+			return "", err
+		}
+		linter, err := commitlint.FromConfig(cfg)
+		if err != nil {
+			return "", err
+		}
+		if err := linter.Check(message); err != nil {
+			return "", fmt.Errorf("aborting commit, the message does not follow the commit message policy: %w", err)
+		}
+	}
+	return message, nil
+}
 
-	commit := objects.NewCommit(data)
+// launchEditor lets the user edit file with their editor
+func launchEditor(repo *repository.Repository, cfg config.GitConfig, file string) error {
+	editor := editor(cfg)
+	if editor == ":" {
+		return nil
+	}
+	// Like git, the editor goes through the shell, so it can have arguments
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, file)
+	cmd.Dir = repo.WorkTree()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("there was a problem with the editor '%s'", editor)
+	}
+	return nil
+}
 
-	return objects.WriteObject(commit, repo)
+// mergeHeads returns the commits in MERGE_HEAD, which become extra parents
+// of the commit that concludes a merge
+func mergeHeads(repo *repository.Repository) ([]*hashing.SHA, error) {
+	data, err := os.ReadFile(repo.RepositoryPath("MERGE_HEAD"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	heads := []*hashing.SHA{}
+	for _, line := range strings.Fields(string(data)) {
+		sha, err := hashing.NewShaFromHex(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MERGE_HEAD: %w", err)
+		}
+		heads = append(heads, sha)
+	}
+	return heads, nil
 }
 
-func calculateTimeOffset() string {
-	_, offset := time.Now().Zone()
-	offsetDuration := time.Duration(float64(offset) * float64(time.Second))
-	symbol := "+"
-	if offset < 0 {
-		symbol = "-"
+func readCommit(repo *repository.Repository, sha *hashing.SHA) (*objects.Commit, error) {
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return nil, err
 	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a commit", sha.AsString())
+	}
+	return commit, nil
+}
 
-	hours := int(offsetDuration.Hours())
-	minutes := int(offsetDuration.Minutes()) % 60
+// commitIdents returns the author and committer of new commits
+func commitIdents(cfg config.GitConfig) (objects.Ident, objects.Ident, error) {
+	author, err := objects.AuthorIdent(cfg)
+	if err != nil {
+		return objects.Ident{}, objects.Ident{}, err
+	}
+	committer, err := objects.CommitterIdent(cfg)
+	if err != nil {
+		return objects.Ident{}, objects.Ident{}, err
+	}
+	return author, committer, nil
+}
 
-	timezone := fmt.Sprintf("%s%02d%02d", symbol, hours, minutes)
-	return timezone
+// overrideAuthor applies --author and --date. The committer stays the same,
+// like when committing on behalf of someone else.
+func overrideAuthor(author objects.Ident, opts commitOptions) (objects.Ident, error) {
+	if opts.author != "" {
+		start, end := strings.IndexByte(opts.author, '<'), strings.LastIndexByte(opts.author, '>')
+		override := objects.ParseIdent([]byte(opts.author))
+		if start < 0 || end < start || strings.TrimSpace(opts.author[end+1:]) != "" || override.Name == "" || override.Email == "" {
+			return author, fmt.Errorf("--author '%s' is not 'Name <email>'", opts.author)
+		}
+		author.Name, author.Email = override.Name, override.Email
+	}
+	if opts.date != "" {
+		when, err := gitdate.Approxidate(opts.date, time.Now())
+		if err != nil {
+			return author, fmt.Errorf("invalid date format: %s", opts.date)
+		}
+		author.When = when
+	}
+	return author, nil
 }
 
 func printCommitResult(branch, message string, commit *hashing.SHA) {
 	shortCommit := commit.AsString()[:7]
-	fmt.Printf("[%s %s] %s\n", branch, shortCommit, message)
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	fmt.Printf("[%s %s] %s\n", branch, shortCommit, subject)
 }
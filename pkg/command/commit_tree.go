@@ -0,0 +1,129 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/signature"
+)
+
+func CommitTreeCommand() *Command {
+	command := newCommand("commit-tree")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		// Like git, the tree may come before the options
+		tree := ""
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			tree, args = args[0], args[1:]
+		}
+		parents := stringList{}
+		flag.Var(&parents, "p", "A parent commit, can be given multiple times")
+		messages := stringList{}
+		flag.Var(&messages, "m", "A paragraph of the message, can be given multiple times")
+		files := stringList{}
+		flag.Var(&files, "F", "Read a paragraph of the message from a file, or from standard input for -")
+		var sign bool
+		flag.BoolVar(&sign, "gpg-sign", false, "Sign the commit with user.signingKey, in the format of gpg.format")
+		flag.BoolVar(&sign, "S", false, "Same as --gpg-sign")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if tree == "" && flag.NArg() == 1 {
+			tree = flag.Arg(0)
+		} else if tree == "" || flag.NArg() > 0 {
+			return errors.New("usage: got commit-tree <tree> [-p <parent>]... [-S] [-m <message>]... [-F <file>]...")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		message, err := commitTreeMessage(messages, files)
+		if err != nil {
+			return err
+		}
+		commit, err := commitTree(repo, tree, parents, message, sign)
+		if err != nil {
+			return err
+		}
+		fmt.Println(commit.AsString())
+		return nil
+	}
+	command.Description = func() string { return "Create a commit object from a tree, parents and a message" }
+	return command
+}
+
+// commitTreeMessage joins the -m and -F paragraphs, separated by blank
+// lines. Without any, the message is read from standard input.
+func commitTreeMessage(messages, files []string) (string, error) {
+	paragraphs := []string{}
+	for _, m := range messages {
+		paragraphs = append(paragraphs, strings.TrimRight(m, "\n"))
+	}
+	for _, file := range files {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not read log file '%s': %w", file, err)
+		}
+		paragraphs = append(paragraphs, strings.TrimRight(string(data), "\n"))
+	}
+	if len(messages) == 0 && len(files) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return strings.Join(paragraphs, "\n\n"), nil
+}
+
+// commitTree writes a commit of tree with parents, by the author and
+// committer from the configuration and environment. No ref is updated.
+func commitTree(repo *repository.Repository, tree string, parentNames []string, message string, sign bool) (*hashing.SHA, error) {
+	treeSha, err := objects.Find(repo, tree, objects.TypeTree, true)
+	if err != nil {
+		return nil, err
+	}
+	parents := []*hashing.SHA{}
+	for _, name := range parentNames {
+		parent, err := objects.Find(repo, name, objects.TypeCommit, true)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid object name %s", name)
+		}
+		// Like git, a parent that is given twice is only recorded once
+		if !slices.ContainsFunc(parents, func(p *hashing.SHA) bool { return p.AsString() == parent.AsString() }) {
+			parents = append(parents, parent)
+		}
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	author, committer, err := commitIdents(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var signer signature.Signer
+	if configured, _ := cfg.GetBool("commit", "gpgSign"); configured || sign {
+		signer, err = signature.NewSigner(cfg, fmt.Sprintf("%s <%s>", committer.Name, committer.Email))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects.CreateSignedCommit(repo, treeSha, parents, author, committer, message, signer)
+}
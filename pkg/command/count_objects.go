@@ -0,0 +1,94 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func CountObjectsCommand() *Command {
+	command := newCommand("count-objects")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var verbose, human bool
+		flag.BoolVar(&verbose, "verbose", false, "Also report the packs, the packed objects and the garbage")
+		flag.BoolVar(&verbose, "v", false, "Same as --verbose")
+		flag.BoolVar(&human, "human-readable", false, "Show sizes in a human readable format")
+		flag.BoolVar(&human, "H", false, "Same as --human-readable")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 {
+			return errors.New("usage: got count-objects [-v] [-H]")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		counts, err := objects.CountObjects(repo)
+		if err != nil {
+			return err
+		}
+		size := func(bytes int64) string {
+			if human {
+				return humanBytes(bytes)
+			}
+			return fmt.Sprint(bytes / 1024)
+		}
+
+		if !verbose {
+			if human {
+				fmt.Printf("%d objects, %s\n", counts.Count, humanBytes(counts.Size))
+			} else {
+				fmt.Printf("%d objects, %d kilobytes\n", counts.Count, counts.Size/1024)
+			}
+			return nil
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		for _, garbage := range counts.Garbage {
+			path := garbage.Path
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				path = rel
+			}
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", garbage.Reason, path)
+		}
+		fmt.Printf("count: %d\n", counts.Count)
+		fmt.Printf("size: %s\n", size(counts.Size))
+		fmt.Printf("in-pack: %d\n", counts.InPack)
+		fmt.Printf("packs: %d\n", counts.Packs)
+		fmt.Printf("size-pack: %s\n", size(counts.SizePack))
+		fmt.Printf("prune-packable: %d\n", counts.PrunePackable)
+		fmt.Printf("garbage: %d\n", len(counts.Garbage))
+		fmt.Printf("size-garbage: %s\n", size(counts.SizeGarbage))
+		return nil
+	}
+	command.Description = func() string {
+		return "Count the loose objects and the packs, and how much disk space they take"
+	}
+	return command
+}
+
+// humanBytes formats a size like git does, in GiB, MiB or KiB with two
+// decimals, or in bytes
+func humanBytes(bytes int64) string {
+	switch {
+	case bytes > 1<<30:
+		return fmt.Sprintf("%d.%02d GiB", bytes>>30, (bytes&(1<<30-1))/10737419)
+	case bytes > 1<<20:
+		return fmt.Sprintf("%d.%02d MiB", bytes>>20, (bytes&(1<<20-1))*100>>20)
+	case bytes > 1<<10:
+		return fmt.Sprintf("%d.%02d KiB", bytes>>10, (bytes&(1<<10-1))*100>>10)
+	case bytes == 1:
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", bytes)
+}
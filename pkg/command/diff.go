@@ -0,0 +1,447 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/format"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/submodule"
+)
+
+func DiffCommand() *Command {
+	command := newCommand("diff")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		cached := flag.Bool("cached", false, "Compare the index to HEAD, or to the given commit")
+		staged := flag.Bool("staged", false, "Same as --cached")
+		var context int
+		flag.IntVar(&context, "U", diff.DefaultContext, "Number of unchanged lines to show around changes")
+		flag.IntVar(&context, "unified", diff.DefaultContext, "Same as -U")
+		stat := flag.Bool("stat", false, "Show the number of changed lines per file instead of a patch")
+		raw := flag.Bool("raw", false, "Show the changed files in git's raw format instead of a patch")
+		nameOnly := flag.Bool("name-only", false, "Only show the names of the changed files")
+		nul := flag.Bool("z", false, "With --name-only, end names with NUL instead of newline, and don't quote them")
+		noIndex := flag.Bool("no-index", false, "Compare two files or directories on disk, which don't have to be in a repository")
+		submoduleFormat := flag.String("submodule", "", "How submodules are shown: short, the commits they are at, or log, the subjects of the commits in between; defaults to diff.submodule or short")
+		renames := addRenameFlags(args)
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if context < 0 {
+			return errors.New("the number of context lines can't be negative")
+		}
+
+		repo, err := repository.Find(".")
+		// Like git, two paths outside a repository are compared as files
+		if *noIndex || (err != nil && flag.NArg() == 2) {
+			if flag.NArg() != 2 {
+				return errors.New("usage: got diff --no-index <path> <path>")
+			}
+			out := noIndexOutput{context: context, stat: *stat, nameOnly: *nameOnly, nul: *nul}
+			// Like git, the exit status says whether the files differ
+			if differ, err := diffNoIndex(os.Stdout, flag.Arg(0), flag.Arg(1), out); err != nil {
+				return err
+			} else if differ {
+				return ExitStatus(1)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		from, to, err := diffSides(repo, *cached || *staged, flag.Args())
+		if err != nil {
+			return err
+		}
+		if *nameOnly {
+			records := format.NewRecords(os.Stdout, *nul)
+			for _, name := range changedPaths(from.paths, to.paths) {
+				if err := records.Write(records.Path(name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if *stat {
+			stats, err := diffStat(from, to)
+			if err != nil || len(stats) == 0 {
+				return err
+			}
+			return diff.WriteStat(os.Stdout, stats, diff.DefaultStatWidth)
+		}
+		if *raw {
+			cfg, err := repo.Config()
+			if err != nil {
+				return err
+			}
+			return writeRawDiff(os.Stdout, repo, from, to, renames.options(cfg))
+		}
+		if *submoduleFormat == "" {
+			cfg, err := repo.Config()
+			if err != nil {
+				return err
+			}
+			*submoduleFormat, _ = cfg.Get("diff", "submodule")
+		}
+		switch *submoduleFormat {
+		case "", "short":
+			return writeDiff(os.Stdout, repo, from, to, context, false)
+		case "log":
+			return writeDiff(os.Stdout, repo, from, to, context, true)
+		}
+		return fmt.Errorf("invalid --submodule format: %s", *submoduleFormat)
+	}
+	command.Description = func() string { return "Show changes between commits, the index and the worktree" }
+	return command
+}
+
+// diffSide is the state of the files on one side of a diff
+type diffSide struct {
+	paths map[string]*hashing.SHA
+	// contents returns the contents of a file that is in paths
+	contents func(name string) ([]byte, error)
+	// worktree is set if the files are read from the worktree, so their
+	// hashes are not of objects in the repository
+	worktree bool
+	// gitlinks are the paths of submodules, whose hash is of a commit in
+	// the repository of the submodule
+	gitlinks map[string]bool
+}
+
+// gitlinkContents is what a submodule looks like in a patch
+func gitlinkContents(sha *hashing.SHA) []byte {
+	return []byte("Subproject commit " + sha.AsString() + "\n")
+}
+
+// diffSides returns what is compared, depending on the arguments:
+//
+//	got diff                     the index to the worktree
+//	got diff --cached [<commit>] HEAD or commit to the index
+//	got diff <tree-ish>          tree-ish to the worktree
+//	got diff <tree-ish> <tree-ish>
+func diffSides(repo *repository.Repository, cached bool, revs []string) (*diffSide, *diffSide, error) {
+	if len(revs) > 2 || (cached && len(revs) > 1) {
+		return nil, nil, errors.New("usage: got diff [--cached] [<tree-ish> [<tree-ish>]]")
+	}
+
+	if len(revs) == 2 {
+		from, err := treeSide(repo, revs[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		to, err := treeSide(repo, revs[1])
+		return from, to, err
+	}
+
+	idx, err := index.Read(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexPaths := pathsFromIndex(idx)
+	indexGitlinks := map[string]bool{}
+	for _, e := range idx.Entries {
+		if e.ModeType == index.ModeTypeGitlink {
+			indexGitlinks[e.Name] = true
+		}
+	}
+	indexSide := &diffSide{
+		paths: indexPaths,
+		contents: func(name string) ([]byte, error) {
+			if indexGitlinks[name] {
+				return gitlinkContents(indexPaths[name]), nil
+			}
+			return blobContents(repo, indexPaths[name])
+		},
+		gitlinks: indexGitlinks,
+	}
+
+	if cached {
+		rev := "HEAD"
+		if len(revs) == 1 {
+			rev = revs[0]
+		}
+		from, err := treeSide(repo, rev)
+		return from, indexSide, err
+	}
+
+	if len(revs) == 0 {
+		to, err := worktreeSide(repo, indexSide.paths, indexGitlinks)
+		return indexSide, to, err
+	}
+
+	from, err := treeSide(repo, revs[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	// Files that are in neither the tree nor the index are untracked
+	tracked := map[string]*hashing.SHA{}
+	gitlinks := map[string]bool{}
+	for _, side := range []*diffSide{from, indexSide} {
+		for name, sha := range side.paths {
+			tracked[name] = sha
+			gitlinks[name] = side.gitlinks[name]
+		}
+	}
+	to, err := worktreeSide(repo, tracked, gitlinks)
+	return from, to, err
+}
+
+// treeSide reads the files of a tree-ish. HEAD is the empty tree if there
+// are no commits yet.
+func treeSide(repo *repository.Repository, name string) (*diffSide, error) {
+	var tree *hashing.SHA
+	var err error
+	if name == "HEAD" {
+		tree, err = objects.HeadTree(repo)
+	} else {
+		tree, err = objects.Find(repo, name, objects.TypeTree, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	leaves := map[string]*objects.TreeLeaf{}
+	if err := treeLeaves(repo, tree.AsString(), "", leaves); err != nil {
+		return nil, err
+	}
+	paths := map[string]*hashing.SHA{}
+	gitlinks := map[string]bool{}
+	for name, leaf := range leaves {
+		paths[name] = leaf.Sha
+		if strings.TrimLeft(string(leaf.Mode), "0") == "160000" {
+			gitlinks[name] = true
+		}
+	}
+	return &diffSide{
+		paths: paths,
+		contents: func(name string) ([]byte, error) {
+			if gitlinks[name] {
+				return gitlinkContents(paths[name]), nil
+			}
+			return blobContents(repo, paths[name])
+		},
+		gitlinks: gitlinks,
+	}, nil
+}
+
+// worktreeSide hashes the tracked files as they are in the worktree.
+// Files that were deleted are left out. Submodules, which gitlinks are
+// the paths of, are at the commit their HEAD is on.
+func worktreeSide(repo *repository.Repository, tracked map[string]*hashing.SHA, gitlinks map[string]bool) (*diffSide, error) {
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]*hashing.SHA{}
+	for name := range tracked {
+		if gitlinks[name] {
+			sha, err := submoduleHead(repo, name, tracked[name])
+			if err != nil {
+				return nil, err
+			}
+			paths[name] = sha
+			continue
+		}
+		sha, err := worktreeBlob(repo, attrs, name)
+		if err != nil {
+			return nil, err
+		}
+		if sha != nil {
+			paths[name] = sha
+		}
+	}
+	return &diffSide{
+		paths: paths,
+		contents: func(name string) ([]byte, error) {
+			if gitlinks[name] {
+				return gitlinkContents(paths[name]), nil
+			}
+			return readWorktreeFile(repo, attrs, name)
+		},
+		worktree: true,
+		gitlinks: gitlinks,
+	}, nil
+}
+
+// submoduleHead returns the commit that the submodule at name is on, or
+// tracked, the commit of the superproject, if it isn't checked out
+func submoduleHead(repo *repository.Repository, name string, tracked *hashing.SHA) (*hashing.SHA, error) {
+	sub, err := repo.Submodule(name)
+	if errors.Is(err, repository.ErrSubmoduleNotInitialized) {
+		return tracked, nil
+	} else if err != nil {
+		return nil, err
+	}
+	head, err := references.Reference("HEAD").Resolve(sub)
+	if err != nil || head == "" {
+		return tracked, err
+	}
+	return hashing.NewShaFromHex(head)
+}
+
+// forEachChange calls fn with the contents of every file that differs
+// between the two sides. A nil hash means the file doesn't exist on that
+// side, and its contents are empty.
+func forEachChange(from, to *diffSide, fn func(name string, oldSha, newSha *hashing.SHA, oldContents, newContents []byte) error) error {
+	for _, name := range changedPaths(from.paths, to.paths) {
+		oldSha, newSha := from.paths[name], to.paths[name]
+		oldContents, newContents := []byte{}, []byte{}
+		var err error
+		if oldSha != nil {
+			if oldContents, err = from.contents(name); err != nil {
+				return err
+			}
+		}
+		if newSha != nil {
+			if newContents, err = to.contents(name); err != nil {
+				return err
+			}
+		}
+		if err := fn(name, oldSha, newSha, oldContents, newContents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDiff writes the changed files from one side to the other as a
+// patch, in the format of git diff. Submodules show the commits they are
+// at, or with submoduleLog, the commits in between.
+func writeDiff(w io.Writer, repo *repository.Repository, from, to *diffSide, context int, submoduleLog bool) error {
+	return forEachChange(from, to, func(name string, oldSha, newSha *hashing.SHA, oldContents, newContents []byte) error {
+		if !from.gitlinks[name] && !to.gitlinks[name] {
+			return writeFileDiff(w, repo, name, oldSha, newSha, oldContents, newContents, context)
+		}
+		if submoduleLog {
+			change, err := submodule.Describe(repo, name, oldSha, newSha)
+			if err != nil {
+				return err
+			}
+			return change.Write(w)
+		}
+		return writeGitlinkDiff(w, repo, name, oldSha, newSha, oldContents, newContents, context)
+	})
+}
+
+// writeGitlinkDiff writes the change of a submodule as a patch of its
+// "Subproject commit" line, like git diff
+func writeGitlinkDiff(w io.Writer, repo *repository.Repository, name string, oldSha, newSha *hashing.SHA, oldContents, newContents []byte, context int) error {
+	mode := string(index.ModeTypeGitlink.Octal())
+	abbrev := objects.AbbrevLength(repo)
+	short := func(sha *hashing.SHA) string {
+		if sha == nil {
+			return strings.Repeat("0", abbrev)
+		}
+		return sha.AsString()[:abbrev]
+	}
+	file := patchFile{
+		oldPath:  name,
+		newPath:  name,
+		oldIndex: short(oldSha),
+		newIndex: short(newSha),
+		added:    oldSha == nil,
+		deleted:  newSha == nil,
+		oldMode:  mode,
+		newMode:  mode,
+	}
+	return writePatch(w, file, oldContents, newContents, context)
+}
+
+// diffStat counts the changes of every file that differs between the two sides
+func diffStat(from, to *diffSide) ([]diff.FileStat, error) {
+	stats := []diff.FileStat{}
+	err := forEachChange(from, to, func(name string, _, _ *hashing.SHA, oldContents, newContents []byte) error {
+		stats = append(stats, diff.Stat(name, oldContents, newContents))
+		return nil
+	})
+	return stats, err
+}
+
+// writeFileDiff writes the headers and hunks for a single file. A nil hash
+// means the file doesn't exist on that side.
+func writeFileDiff(w io.Writer, repo *repository.Repository, name string, oldSha, newSha *hashing.SHA, oldContents, newContents []byte, context int) error {
+	// got stores every file as a regular file
+	mode := string(index.ModeTypeRegular.Octal())
+	abbrev := objects.AbbrevLength(repo)
+	short := func(sha *hashing.SHA) string {
+		if sha == nil {
+			return strings.Repeat("0", abbrev)
+		}
+		return objects.ShortSHA(repo, sha, abbrev)
+	}
+	file := patchFile{
+		oldPath:  name,
+		newPath:  name,
+		oldIndex: short(oldSha),
+		newIndex: short(newSha),
+		added:    oldSha == nil,
+		deleted:  newSha == nil,
+		oldMode:  mode,
+		newMode:  mode,
+	}
+	return writePatch(w, file, oldContents, newContents, context)
+}
+
+// patchFile is what the headers of a file in a patch show
+type patchFile struct {
+	oldPath, newPath string
+	// oldIndex and newIndex are the abbreviated hashes of the contents
+	oldIndex, newIndex string
+	// A file that is added or deleted doesn't exist on one side
+	added, deleted   bool
+	oldMode, newMode string
+}
+
+// writePatch writes the headers and hunks of a file, in the format of git
+// diff
+func writePatch(w io.Writer, f patchFile, oldContents, newContents []byte, context int) error {
+	header := fmt.Sprintf("diff --git a/%s b/%s\n", f.oldPath, f.newPath)
+	oldName, newName := "a/"+f.oldPath, "b/"+f.newPath
+	switch {
+	case f.added:
+		header += fmt.Sprintf("new file mode %s\nindex %s..%s\n", f.newMode, f.oldIndex, f.newIndex)
+		oldName = "/dev/null"
+	case f.deleted:
+		header += fmt.Sprintf("deleted file mode %s\nindex %s..%s\n", f.oldMode, f.oldIndex, f.newIndex)
+		newName = "/dev/null"
+	case f.oldMode != f.newMode:
+		header += fmt.Sprintf("old mode %s\nnew mode %s\n", f.oldMode, f.newMode)
+		if f.oldIndex != f.newIndex {
+			header += fmt.Sprintf("index %s..%s\n", f.oldIndex, f.newIndex)
+		}
+	default:
+		header += fmt.Sprintf("index %s..%s %s\n", f.oldIndex, f.newIndex, f.newMode)
+	}
+
+	hunks := false
+	switch {
+	case len(oldContents) == 0 && len(newContents) == 0:
+		// Empty files that are added or deleted have no hunks
+	case f.oldIndex == f.newIndex:
+		// Only the mode changed
+	case diff.IsBinary(oldContents) || diff.IsBinary(newContents):
+		header += fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	default:
+		header += fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName)
+		hunks = true
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	if !hunks {
+		return nil
+	}
+	return diff.WriteUnified(w, oldContents, newContents, context)
+}
@@ -0,0 +1,280 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/format"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+)
+
+// noIndexOutput is what got diff --no-index shows: a patch with context
+// lines, a diffstat or the names of the changed files
+type noIndexOutput struct {
+	context  int
+	stat     bool
+	nameOnly bool
+	nul      bool
+}
+
+// noIndexFile is a file on disk that is compared without a repository
+type noIndexFile struct {
+	// path is how the file is shown, with slashes
+	path string
+	mode string
+	data []byte
+}
+
+// noIndexChange is a file that differs between the two sides. A nil
+// side doesn't exist.
+type noIndexChange struct {
+	old, new *noIndexFile
+}
+
+// diffNoIndex compares two files or directories on disk, like git diff
+// --no-index. Files in directories are matched by their path below the
+// directory, and a file compared to a directory is compared to the file
+// with the same name in it. /dev/null stands for a missing file. It
+// returns whether the two sides differ.
+func diffNoIndex(w io.Writer, from, to string, out noIndexOutput) (bool, error) {
+	fromDir, toDir := isDir(from), isDir(to)
+	switch {
+	case fromDir && !toDir && to != os.DevNull:
+		from = filepath.Join(from, filepath.Base(to))
+		fromDir = false
+	case toDir && !fromDir && from != os.DevNull:
+		to = filepath.Join(to, filepath.Base(from))
+		toDir = false
+	}
+	if (fromDir || toDir) && (from == os.DevNull || to == os.DevNull) {
+		return false, errors.New("cannot compare a directory to /dev/null")
+	}
+
+	oldFiles, err := noIndexFiles(from)
+	if err != nil {
+		return false, err
+	}
+	newFiles, err := noIndexFiles(to)
+	if err != nil {
+		return false, err
+	}
+	names := []string{}
+	for name := range oldFiles {
+		names = append(names, name)
+	}
+	for name := range newFiles {
+		if _, ok := oldFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []noIndexChange{}
+	for _, name := range names {
+		old, new := oldFiles[name], newFiles[name]
+		if old != nil && new != nil && old.mode == new.mode && string(old.data) == string(new.data) {
+			continue
+		}
+		changes = append(changes, noIndexChange{old: old, new: new})
+	}
+
+	switch {
+	case out.nameOnly:
+		records := format.NewRecords(w, out.nul)
+		for _, c := range changes {
+			// Like git, deleted files are named by the missing side
+			name := os.DevNull
+			if c.new != nil {
+				name = c.new.path
+			}
+			if err := records.Write(records.Path(name)); err != nil {
+				return false, err
+			}
+		}
+		return len(changes) > 0, nil
+	case out.stat:
+		stats := []diff.FileStat{}
+		for _, c := range changes {
+			oldData, newData := c.contents()
+			stats = append(stats, diff.Stat(c.statName(), oldData, newData))
+		}
+		if len(stats) == 0 {
+			return false, nil
+		}
+		return true, diff.WriteStat(w, stats, diff.DefaultStatWidth)
+	}
+	for _, c := range changes {
+		if err := writePatch(w, c.patchFile(), c.old.contents(), c.new.contents(), out.context); err != nil {
+			return false, err
+		}
+	}
+	return len(changes) > 0, nil
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// noIndexFiles reads a file or the files below a directory, keyed by
+// their path below it. A single file has an empty key, and /dev/null
+// has no files.
+func noIndexFiles(root string) (map[string]*noIndexFile, error) {
+	files := map[string]*noIndexFile{}
+	if root == os.DevNull {
+		return files, nil
+	}
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		file, err := readNoIndexFile(root, info)
+		if err != nil {
+			return nil, err
+		}
+		files[""] = file
+		return files, nil
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file, err := readNoIndexFile(p, info)
+		if err != nil || file == nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = file
+		return nil
+	})
+	return files, err
+}
+
+// readNoIndexFile reads a regular file or the target of a symlink. Other
+// kinds of files are skipped.
+func readNoIndexFile(name string, info fs.FileInfo) (*noIndexFile, error) {
+	file := &noIndexFile{path: strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "/")}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(name)
+		if err != nil {
+			return nil, err
+		}
+		file.mode, file.data = "120000", []byte(target)
+	case info.Mode().IsRegular():
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		file.mode, file.data = "100644", data
+		if info.Mode()&0o111 != 0 {
+			file.mode = "100755"
+		}
+	default:
+		return nil, nil
+	}
+	return file, nil
+}
+
+func (f *noIndexFile) contents() []byte {
+	if f == nil {
+		return []byte{}
+	}
+	return f.data
+}
+
+// index returns the abbreviated hash the file would have as a blob.
+// Without a repository to look for similar objects, the default length is
+// used, like git does.
+func (f *noIndexFile) index() string {
+	if f == nil {
+		return strings.Repeat("0", objects.DefaultAbbrev)
+	}
+	header := "blob " + strconv.Itoa(len(f.data)) + "\x00"
+	sha := hashing.NewSHA(append([]byte(header), f.data...))
+	return sha.AsString()[:objects.DefaultAbbrev]
+}
+
+func (c noIndexChange) contents() ([]byte, []byte) {
+	return c.old.contents(), c.new.contents()
+}
+
+// path is the name of the change: the new file, unless it was deleted
+func (c noIndexChange) path() string {
+	if c.new == nil {
+		return c.old.path
+	}
+	return c.new.path
+}
+
+func (c noIndexChange) patchFile() patchFile {
+	f := patchFile{
+		oldIndex: c.old.index(),
+		newIndex: c.new.index(),
+		added:    c.old == nil,
+		deleted:  c.new == nil,
+	}
+	// Like git, a file that only exists on one side has its own name on
+	// both sides of the header
+	f.oldPath, f.newPath = c.path(), c.path()
+	if c.old != nil {
+		f.oldPath, f.oldMode = c.old.path, c.old.mode
+	}
+	if c.new != nil {
+		f.newPath, f.newMode = c.new.path, c.new.mode
+	}
+	return f
+}
+
+// statName names a change in a diffstat like git does for files that
+// have different names on both sides: the parts of the names that are
+// the same are only shown once, like {old => new}/file
+func (c noIndexChange) statName() string {
+	oldPath, newPath := os.DevNull, os.DevNull
+	if c.old != nil {
+		oldPath = c.old.path
+	}
+	if c.new != nil {
+		newPath = c.new.path
+	}
+	if oldPath == newPath {
+		return oldPath
+	}
+
+	// The common prefix ends with a slash, and the common suffix starts
+	// with one, without overlapping
+	prefix := 0
+	for i := 0; i < len(oldPath) && i < len(newPath) && oldPath[i] == newPath[i]; i++ {
+		if oldPath[i] == '/' {
+			prefix = i + 1
+		}
+	}
+	suffix := 0
+	for i := 1; i <= len(oldPath)-prefix && i <= len(newPath)-prefix && oldPath[len(oldPath)-i] == newPath[len(newPath)-i]; i++ {
+		if oldPath[len(oldPath)-i] == '/' {
+			suffix = i
+		}
+	}
+	if prefix == 0 && suffix == 0 {
+		return fmt.Sprintf("%s => %s", oldPath, newPath)
+	}
+	return fmt.Sprintf("%s{%s => %s}%s", oldPath[:prefix], oldPath[prefix:len(oldPath)-suffix], newPath[prefix:len(newPath)-suffix], oldPath[len(oldPath)-suffix:])
+}
@@ -0,0 +1,198 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// renameFlags are the flags of diff and log that decide which deleted and
+// added files are shown as renames and copies
+type renameFlags struct {
+	renames, copies similarityScore
+	copiesHarder    bool
+	noRenames       bool
+}
+
+// addRenameFlags defines the rename flags. Like git, the score can be
+// attached to -M and -C, as in -M60%, so args are rewritten for the flag
+// package.
+func addRenameFlags(args []string) *renameFlags {
+	f := &renameFlags{}
+	flag.Var(&f.renames, "M", "Find renames, of files that are at least n similar, like 60% (default 50%)")
+	flag.Var(&f.renames, "find-renames", "Same as -M")
+	flag.Var(&f.copies, "C", "Find copies of modified files as well as renames, of files that are at least n similar (default 50%)")
+	flag.Var(&f.copies, "find-copies", "Same as -C")
+	flag.BoolVar(&f.copiesHarder, "find-copies-harder", false, "Find copies of unmodified files too")
+	flag.BoolVar(&f.noRenames, "no-renames", false, "Don't find renames, even if diff.renames is set")
+	for i, arg := range args {
+		for _, name := range []string{"-M", "-C"} {
+			if score, ok := strings.CutPrefix(arg, name); ok && score != "" && !strings.HasPrefix(score, "=") {
+				args[i] = name + "=" + score
+			}
+		}
+	}
+	return f
+}
+
+// options returns how renames and copies are found. Without flags, this
+// depends on diff.renames, which can be false, true or copies, and which
+// is true by default like in git.
+func (f *renameFlags) options(cfg config.GitConfig) diff.ChangeOptions {
+	opts := diff.ChangeOptions{RenameScore: diff.DefaultRenameScore}
+	if value, _ := cfg.Get("diff", "renames"); value == "copies" || value == "copy" {
+		opts.CopyScore = diff.DefaultRenameScore
+	} else if enabled, ok := cfg.GetBool("diff", "renames"); ok && !enabled {
+		opts.RenameScore = 0
+	}
+	if f.renames.set {
+		opts.RenameScore = f.renames.score
+	}
+	if f.copies.set || f.copiesHarder {
+		if opts.CopyScore = diff.DefaultRenameScore; f.copies.set {
+			opts.CopyScore = f.copies.score
+		}
+		opts.FindCopiesHarder = f.copiesHarder
+		if !f.renames.set {
+			opts.RenameScore = opts.CopyScore
+		}
+	}
+	if f.noRenames {
+		opts = diff.ChangeOptions{}
+	}
+	return opts
+}
+
+// similarityScore is the value of -M or -C, which can be given without a
+// score to use the default one
+type similarityScore struct {
+	score int
+	set   bool
+}
+
+func (s *similarityScore) String() string {
+	if !s.set {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", s.score*100/diff.MaxScore)
+}
+
+// Set reads a score like git: 60% is a percentage, and digits without a
+// percent sign are a fraction, so 6 and 0.6 are 60% too
+func (s *similarityScore) Set(value string) error {
+	s.set = true
+	if value == "true" {
+		s.score = diff.DefaultRenameScore
+		return nil
+	}
+	num, scale := 0, 1
+	dot := false
+	i := 0
+	for ; i < len(value); i++ {
+		c := value[i]
+		if c == '.' && !dot {
+			scale, dot = 1, true
+		} else if c == '%' {
+			if dot {
+				scale *= 100
+			} else {
+				scale = 100
+			}
+			i++
+			break
+		} else if '0' <= c && c <= '9' {
+			if scale < 100000 {
+				scale *= 10
+				num = num*10 + int(c-'0')
+			}
+		} else {
+			break
+		}
+	}
+	if i != len(value) || value == "%" {
+		return fmt.Errorf("invalid similarity score: %s", value)
+	}
+	s.score = diff.MaxScore
+	if num < scale {
+		s.score = diff.MaxScore * num / scale
+	}
+	return nil
+}
+
+func (s *similarityScore) IsBoolFlag() bool {
+	return true
+}
+
+// rawChanges returns the files that differ between the two sides, with
+// renames and copies found according to opts
+func rawChanges(from, to *diffSide, opts diff.ChangeOptions) ([]diff.Change, error) {
+	// The files are looked up by their hash, which for the worktree isn't
+	// that of an object in the repository
+	names := map[string]func() ([]byte, error){}
+	for _, side := range []*diffSide{from, to} {
+		for name, sha := range side.paths {
+			names[sha.AsString()] = func() ([]byte, error) { return side.contents(name) }
+		}
+	}
+	contents := func(sha *hashing.SHA) ([]byte, error) { return names[sha.AsString()]() }
+	return diff.Changes(from.paths, to.paths, contents, opts)
+}
+
+// writeRawDiff writes the changes between the two sides in git's raw
+// format, like ":100644 100644 bcd1234 0123456 M\tfile". Files in the
+// worktree have no object yet, so their hash is all zeroes.
+func writeRawDiff(w io.Writer, repo *repository.Repository, from, to *diffSide, opts diff.ChangeOptions) error {
+	changes, err := rawChanges(from, to, opts)
+	if err != nil {
+		return err
+	}
+	for _, line := range rawLines(repo, changes, to.worktree) {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawLines formats changes in the raw format, one line per change
+func rawLines(repo *repository.Repository, changes []diff.Change, worktree bool) []string {
+	abbrev := objects.AbbrevLength(repo)
+	short := func(sha *hashing.SHA) string {
+		if sha == nil {
+			return strings.Repeat("0", abbrev)
+		}
+		return objects.ShortSHA(repo, sha, abbrev)
+	}
+	// got stores every file as a regular file
+	mode := func(sha *hashing.SHA) string {
+		if sha == nil {
+			return "000000"
+		}
+		return string(index.ModeTypeRegular.Octal())
+	}
+
+	lines := []string{}
+	for _, c := range changes {
+		newSha := short(c.NewSHA)
+		if worktree {
+			newSha = short(nil)
+		}
+		line := fmt.Sprintf(":%s %s %s %s %c", mode(c.OldSHA), mode(c.NewSHA), short(c.OldSHA), newSha, c.Status)
+		switch c.Status {
+		case diff.StatusRenamed, diff.StatusCopied:
+			line += fmt.Sprintf("%03d\t%s\t%s", c.Score*100/diff.MaxScore, c.From, c.To)
+		default:
+			line += "\t" + c.To
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/metadata"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func ExportMetadataCommand() *Command {
+	command := newCommand("export-metadata")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		format := flag.String("format", "", "Format of the summary: json or html")
+		templateFile := flag.String("template", "", "Render the summary with this html/template file")
+		commits := flag.Int("commits", 20, "How many recent commits to list")
+		output := flag.String("o", "", "Write the summary to this file instead of stdout")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() != 0 || *commits < 0 {
+			return errors.New("usage: got export-metadata [--format=json|html] [--template=<file>] [--commits=<n>] [-o <file>]")
+		}
+
+		// A template or an .html output file means HTML, like archive
+		// infers its format
+		if *format == "" {
+			*format = "json"
+			if *templateFile != "" || strings.HasSuffix(*output, ".html") {
+				*format = "html"
+			}
+		}
+		if *format != "json" && *format != "html" {
+			return fmt.Errorf("unknown metadata format '%s'", *format)
+		}
+		if *templateFile != "" && *format != "html" {
+			return errors.New("--template only works with --format=html")
+		}
+		var tmpl *template.Template
+		if *templateFile != "" {
+			text, err := os.ReadFile(*templateFile)
+			if err != nil {
+				return err
+			}
+			if tmpl, err = metadata.ParseTemplate(string(text)); err != nil {
+				return err
+			}
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		summary, err := metadata.Collect(repo, metadata.Options{Commits: *commits})
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		if *format == "html" {
+			return metadata.WriteHTML(out, summary, tmpl)
+		}
+		return metadata.WriteJSON(out, summary)
+	}
+	command.Description = func() string {
+		return "Export the branches, tags, recent commits and contributors as JSON or HTML, for static sites"
+	}
+	return command
+}
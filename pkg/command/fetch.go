@@ -0,0 +1,534 @@
+package command
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/format"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/remote"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/revwalk"
+	"github.com/jessegeens/got/pkg/transport"
+)
+
+func FetchCommand() *Command {
+	command := newCommand("fetch")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var force, tags bool
+		flag.BoolVar(&force, "force", false, "Update refs even when it isn't a fast-forward")
+		flag.BoolVar(&force, "f", false, "Same as --force")
+		flag.BoolVar(&tags, "tags", false, "Fetch all the tags of the remote")
+		flag.BoolVar(&tags, "t", false, "Same as --tags")
+		noTags := flag.Bool("no-tags", false, "Don't fetch the tags that point to what is fetched")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+
+		name := defaultRemote(repo, cfg)
+		if flag.NArg() > 0 {
+			name = flag.Arg(0)
+		}
+		var refspecs []string
+		if flag.NArg() > 1 {
+			refspecs = flag.Args()[1:]
+		}
+		return fetchRemote(repo, name, refspecs, fetchOptions{force: force, followTags: !*noTags}, tags)
+	}
+	command.Description = func() string {
+		return "Download objects and refs from a remote, and update its remote-tracking branches"
+	}
+	return command
+}
+
+// fetchRemote fetches from a configured remote, or a URL, with its
+// configured refspecs or the refspecs given on the command line. allTags
+// fetches all tags, like remote.<name>.tagOpt = --tags.
+func fetchRemote(repo *repository.Repository, name string, refspecs []string, opts fetchOptions, allTags bool) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	switch tagOpt, _ := cfg.Get(`remote "`+name+`"`, "tagOpt"); {
+	case allTags || tagOpt == "--tags":
+		opts.refspecs = append(opts.refspecs, remote.Refspec{Src: "refs/tags/*", Dst: "refs/tags/*"})
+	case tagOpt == "--no-tags":
+		opts.followTags = false
+	}
+	configured, err := remote.FetchRefspecs(cfg, name)
+	if err != nil {
+		return err
+	}
+	if len(refspecs) > 0 {
+		for _, spec := range refspecs {
+			r, err := remote.ParseRefspec(spec)
+			if err != nil {
+				return err
+			}
+			opts.refspecs = append(opts.refspecs, r)
+		}
+		opts.fromCommandLine = true
+		opts.tracking = configured
+	} else {
+		opts.refspecs = append(opts.refspecs, configured...)
+	}
+	opts.mergeRef = mergeRef(repo, cfg, name)
+
+	url := remote.FetchURLs(cfg, name)[0]
+	t, err := transport.Open(url, cfg)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	adv, err := t.Advertise(transport.UploadPack)
+	if err != nil {
+		return err
+	}
+	return fetch(repo, t, adv, url, opts)
+}
+
+// fetchOptions say what a fetch updates
+type fetchOptions struct {
+	// refspecs map the refs of the remote to local refs
+	refspecs []remote.Refspec
+	// fromCommandLine is set for refspecs that are given on the command
+	// line. The refs they name are candidates for a merge, and tracking
+	// refspecs, those of the configuration, update the remote-tracking
+	// branches of the refs too.
+	fromCommandLine bool
+	tracking        []remote.Refspec
+	// mergeRef is the ref of the remote the current branch merges
+	mergeRef string
+	force    bool
+	// followTags fetches the tags that point to what is fetched
+	followTags bool
+}
+
+// fetchedRef is a ref of the remote that a fetch writes to FETCH_HEAD,
+// and to dst if it is set
+type fetchedRef struct {
+	name     string
+	sha      string
+	dst      string
+	force    bool
+	forMerge bool
+}
+
+// fetch downloads what the refs of opts need and updates them
+func fetch(repo *repository.Repository, t transport.Transport, adv *transport.Advertisement, url string, opts fetchOptions) error {
+	fetched, err := fetchedRefs(adv, opts)
+	if err != nil {
+		return err
+	}
+	if err := checkCurrentBranch(repo, fetched); err != nil {
+		return err
+	}
+	check, err := incomingCheck(repo, "fetch", false)
+	if err != nil {
+		return err
+	}
+	// What an interrupted fetch got doesn't have to come again
+	recovered, err := objects.RecoverPartialPack(repo, check)
+	if err != nil {
+		return err
+	}
+
+	wants := []string{}
+	for _, ref := range fetched {
+		if !hasObject(repo, ref.sha) && !slices.Contains(wants, ref.sha) {
+			wants = append(wants, ref.sha)
+		}
+	}
+	// Tags of commits we have already don't come with include-tag
+	if opts.followTags {
+		for _, ref := range followedTags(repo, adv, fetched) {
+			if !hasObject(repo, ref.sha) && hasObject(repo, cmp.Or(ref.peeled, ref.sha)) && !slices.Contains(wants, ref.sha) {
+				wants = append(wants, ref.sha)
+			}
+		}
+	}
+	if len(wants) > 0 {
+		haves, err := fetchHaves(repo)
+		if err != nil {
+			return err
+		}
+		for _, have := range recovered {
+			if !slices.Contains(haves, have) {
+				haves = append(haves, have)
+			}
+		}
+		req := transport.FetchRequest{Wants: wants, Haves: haves, IncludeTag: opts.followTags, Progress: os.Stderr}
+		pack, err := transport.Fetch(t, adv, req)
+		if err != nil {
+			return err
+		}
+		_, err = objects.IndexPack(repo, pack, check)
+		if closeErr := pack.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if opts.followTags {
+		for _, ref := range followedTags(repo, adv, fetched) {
+			if hasObject(repo, ref.sha) {
+				fetched = append(fetched, fetchedRef{name: ref.name, sha: ref.sha, dst: ref.name})
+			}
+		}
+	}
+
+	if err := writeFetchHead(repo, url, fetched); err != nil {
+		return err
+	}
+	return updateFetchedRefs(repo, url, fetched, opts.force)
+}
+
+// fetchedRefs returns the refs of the remote that the refspecs of a fetch
+// match
+func fetchedRefs(adv *transport.Advertisement, opts fetchOptions) ([]fetchedRef, error) {
+	fetched := []fetchedRef{}
+	add := func(ref fetchedRef) {
+		if !slices.ContainsFunc(fetched, func(f fetchedRef) bool { return f.name == ref.name && f.dst == ref.dst }) {
+			fetched = append(fetched, ref)
+		}
+	}
+	if !opts.fromCommandLine {
+		refspecs := opts.refspecs
+		// A remote without refspecs, like a URL, only fetches its HEAD
+		if len(refspecs) == 0 {
+			refspecs = []remote.Refspec{{Src: "HEAD"}}
+		}
+		for _, ref := range adv.Refs {
+			name := ref.Name.String()
+			r, dst, ok := remote.MapRef(refspecs, name)
+			if !ok {
+				continue
+			}
+			forMerge := name == opts.mergeRef || (len(opts.refspecs) == 0 && name == "HEAD")
+			add(fetchedRef{name: name, sha: ref.SHA, dst: dst, force: r.Force, forMerge: forMerge})
+		}
+		return fetched, nil
+	}
+
+	for _, r := range opts.refspecs {
+		if r.Negative {
+			continue
+		}
+		for _, ref := range adv.Refs {
+			name := ref.Name.String()
+			dst, ok := r.Match(name)
+			if !r.IsPattern() {
+				// Refspecs on the command line can use short names
+				dst, ok = expandDst(r.Dst, name), name == expandRemoteRef(adv, r.Src)
+			}
+			if !ok {
+				continue
+			}
+			if remote.Excluded(opts.refspecs, name) {
+				continue
+			}
+			add(fetchedRef{name: name, sha: ref.SHA, dst: dst, force: r.Force, forMerge: !r.IsPattern()})
+			// The remote-tracking branch of the ref is updated too
+			if tracking, trackingDst, ok := remote.MapRef(opts.tracking, name); ok && trackingDst != "" && trackingDst != dst {
+				add(fetchedRef{name: name, sha: ref.SHA, dst: trackingDst, force: tracking.Force})
+			}
+		}
+		if !r.IsPattern() && expandRemoteRef(adv, r.Src) == "" {
+			return nil, fmt.Errorf("couldn't find remote ref %s", r.Src)
+		}
+	}
+	return fetched, nil
+}
+
+// expandRemoteRef returns the ref of the remote that a short name means,
+// with the rules of git rev-parse, or "" if there is none
+func expandRemoteRef(adv *transport.Advertisement, short string) string {
+	for _, candidate := range []string{short, "refs/" + short, "refs/tags/" + short, "refs/heads/" + short, "refs/remotes/" + short, "refs/remotes/" + short + "/HEAD"} {
+		for _, ref := range adv.Refs {
+			if ref.Name.String() == candidate {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// expandDst returns the full name of the local ref dst, which is a branch
+// unless the remote ref is a tag
+func expandDst(dst, remoteRef string) string {
+	if dst == "" || strings.HasPrefix(dst, "refs/") {
+		return dst
+	}
+	if strings.HasPrefix(remoteRef, "refs/tags/") {
+		return "refs/tags/" + dst
+	}
+	return "refs/heads/" + dst
+}
+
+// checkCurrentBranch refuses to update the branch that is checked out,
+// because that would leave the worktree and the index out of date
+func checkCurrentBranch(repo *repository.Repository, fetched []fetchedRef) error {
+	branch, onBranch, err := repo.GetActiveBranch()
+	if err != nil || !onBranch {
+		return err
+	}
+	for _, ref := range fetched {
+		if ref.dst == "refs/heads/"+branch {
+			return fmt.Errorf("refusing to fetch into branch '%s' checked out at '%s'", ref.dst, filepath.Clean(repo.WorkTree()))
+		}
+	}
+	return nil
+}
+
+// advertisedTag is a tag of the remote, and the object it points to
+type advertisedTag struct {
+	name   string
+	sha    string
+	peeled string
+}
+
+// followedTags returns the tags of the remote that aren't fetched or in
+// the repository yet
+func followedTags(repo *repository.Repository, adv *transport.Advertisement, fetched []fetchedRef) []advertisedTag {
+	tags := []advertisedTag{}
+	for _, ref := range adv.Refs {
+		name := ref.Name.String()
+		if !strings.HasPrefix(name, "refs/tags/") || strings.HasSuffix(name, "^{}") {
+			continue
+		}
+		if slices.ContainsFunc(fetched, func(f fetchedRef) bool { return f.dst == name }) {
+			continue
+		}
+		if sha, _ := references.Reference(name).Resolve(repo); sha != "" {
+			continue
+		}
+		tags = append(tags, advertisedTag{name: name, sha: ref.SHA, peeled: ref.Peeled})
+	}
+	return tags
+}
+
+// maxHaves limits the commits a fetch says it has. They are the most
+// recent ones, which are the likeliest to be in the remote as well.
+const maxHaves = 256
+
+// fetchHaves returns the commits of the repository that a fetch tells the
+// remote about, so it leaves out what they reach
+func fetchHaves(repo *repository.Repository) ([]string, error) {
+	refs, err := references.All(repo)
+	if err != nil {
+		return nil, err
+	}
+	tips := []*hashing.SHA{}
+	for _, ref := range refs {
+		sha, err := hashing.NewShaFromHex(ref.SHA)
+		if err != nil {
+			continue
+		}
+		// Refs can point to tags or other objects
+		if commit, err := objects.Peel(repo, sha, objects.TypeCommit); err == nil {
+			tips = append(tips, commit)
+		}
+	}
+	w, err := revwalk.New(repo, tips...)
+	if err != nil {
+		return nil, err
+	}
+	haves := []string{}
+	for len(haves) < maxHaves {
+		commit, err := w.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		haves = append(haves, commit.SHA.AsString())
+	}
+	return haves, nil
+}
+
+// updateFetchedRefs points the local refs of a fetch to what was fetched,
+// and shows what changed like git does
+func updateFetchedRefs(repo *repository.Repository, url string, fetched []fetchedRef, force bool) error {
+	shownURL := false
+	rejected := false
+	show := func(flag, summary, from, to, note string) {
+		if !shownURL {
+			fmt.Fprintf(os.Stderr, "From %s\n", displayURL(url))
+			shownURL = true
+		}
+		fmt.Fprintf(os.Stderr, " %s %-17s %-10s -> %s%s\n", flag, summary, from, to, note)
+	}
+	for _, ref := range fetched {
+		from := format.ShortRefName(ref.name)
+		if ref.dst == "" {
+			if ref.forMerge {
+				show("*", fetchKind(ref.name), from, "FETCH_HEAD", "")
+			}
+			continue
+		}
+		to := format.ShortRefName(ref.dst)
+		old, err := references.Reference(ref.dst).Resolve(repo)
+		if err != nil {
+			return err
+		}
+		if old == ref.sha {
+			continue
+		}
+		forced := force || ref.force
+		var flag, summary, note string
+		switch {
+		case old == "":
+			flag, summary = "*", "[new "+fetchKind(ref.dst)+"]"
+		case strings.HasPrefix(ref.dst, "refs/tags/") && !forced:
+			flag, summary, note = "!", "[rejected]", "  (would clobber existing tag)"
+		case isFastForward(repo, old, ref.sha):
+			flag, summary = " ", shortRange(repo, old, ref.sha, "..")
+		case forced:
+			flag, summary, note = "+", shortRange(repo, old, ref.sha, "..."), "  (forced update)"
+		default:
+			flag, summary, note = "!", "[rejected]", "  (non-fast-forward)"
+		}
+		if flag == "!" {
+			rejected = true
+		} else if err := references.Update(repo, references.Reference(ref.dst), ref.sha); err != nil {
+			return err
+		}
+		show(flag, summary, from, to, note)
+	}
+	if rejected {
+		return errors.New("some local refs could not be updated")
+	}
+	return nil
+}
+
+// fetchKind returns what a ref is, as fetch shows it
+func fetchKind(ref string) string {
+	switch {
+	case ref == "HEAD", strings.HasPrefix(ref, "refs/heads/"), strings.HasPrefix(ref, "refs/remotes/"):
+		return "branch"
+	case strings.HasPrefix(ref, "refs/tags/"):
+		return "tag"
+	}
+	return "ref"
+}
+
+func shortRange(repo *repository.Repository, from, to, sep string) string {
+	short := func(sha string) string {
+		if parsed, err := hashing.NewShaFromHex(sha); err == nil {
+			return objects.ShortSHA(repo, parsed, objects.AbbrevLength(repo))
+		}
+		return sha
+	}
+	return short(from) + sep + short(to)
+}
+
+// isFastForward reports whether old is a commit in the history of new
+func isFastForward(repo *repository.Repository, old, new string) bool {
+	oldSha, err := hashing.NewShaFromHex(old)
+	if err != nil {
+		return false
+	}
+	newSha, err := hashing.NewShaFromHex(new)
+	if err != nil {
+		return false
+	}
+	ok, err := revwalk.IsAncestor(repo, oldSha, newSha)
+	return ok && err == nil
+}
+
+// writeFetchHead writes the fetched refs to FETCH_HEAD, like git: the ones
+// to merge come first, and the others are marked not-for-merge
+func writeFetchHead(repo *repository.Repository, url string, fetched []fetchedRef) error {
+	entries := []references.FetchHeadEntry{}
+	written := map[string]bool{}
+	for _, forMerge := range []bool{true, false} {
+		for _, ref := range fetched {
+			if ref.forMerge != forMerge || written[ref.name] {
+				continue
+			}
+			written[ref.name] = true
+			entries = append(entries, references.FetchHeadEntry{SHA: ref.sha, NotForMerge: !forMerge, Description: fetchHeadDescription(ref.name, url)})
+		}
+	}
+	return references.WriteFetchHead(repo, entries)
+}
+
+// fetchHeadDescription describes a fetched ref in FETCH_HEAD, e.g.
+// "branch 'main' of https://example.com/repo"
+func fetchHeadDescription(ref, url string) string {
+	url = displayURL(url)
+	switch {
+	case ref == "HEAD":
+		return url
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return "branch '" + strings.TrimPrefix(ref, "refs/heads/") + "' of " + url
+	case strings.HasPrefix(ref, "refs/tags/"):
+		return "tag '" + strings.TrimPrefix(ref, "refs/tags/") + "' of " + url
+	case strings.HasPrefix(ref, "refs/remotes/"):
+		return "remote-tracking branch '" + strings.TrimPrefix(ref, "refs/remotes/") + "' of " + url
+	}
+	return "'" + ref + "' of " + url
+}
+
+// userinfo matches the user and password of a URL
+var userinfo = regexp.MustCompile(`^([a-z+]+://)[^/@]*@`)
+
+// displayURL returns a URL as fetch shows it: without a password, and
+// without trailing slashes and .git
+func displayURL(url string) string {
+	url = userinfo.ReplaceAllString(url, "$1")
+	url = strings.TrimRight(url, "/")
+	return strings.TrimSuffix(url, ".git")
+}
+
+// defaultRemote returns the remote of the current branch, or origin
+func defaultRemote(repo *repository.Repository, cfg config.GitConfig) string {
+	if branch, onBranch, err := repo.GetActiveBranch(); err == nil && onBranch {
+		if name, ok := cfg.Get(`branch "`+branch+`"`, "remote"); ok && name != "" {
+			return name
+		}
+	}
+	return "origin"
+}
+
+// mergeRef returns the ref of a remote that the current branch merges,
+// or "" if it merges from another remote
+func mergeRef(repo *repository.Repository, cfg config.GitConfig, name string) string {
+	branch, onBranch, err := repo.GetActiveBranch()
+	if err != nil || !onBranch {
+		return ""
+	}
+	if r, _ := cfg.Get(`branch "`+branch+`"`, "remote"); r != name {
+		return ""
+	}
+	ref, _ := cfg.Get(`branch "`+branch+`"`, "merge")
+	return ref
+}
+
+// hasObject reports whether the object named by a hex SHA is in the
+// repository
+func hasObject(repo *repository.Repository, sha string) bool {
+	parsed, err := hashing.NewShaFromHex(sha)
+	return err == nil && objects.HasObject(repo, parsed)
+}
@@ -0,0 +1,269 @@
+package command
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/filter"
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/ignore"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/worktree"
+)
+
+// Helpers shared by the commands that read or update the worktree
+
+// pathsFromIndex maps the files in the index to their blobs. For
+// unmerged files, our version is used.
+func pathsFromIndex(idx *index.Index) map[string]*hashing.SHA {
+	paths := map[string]*hashing.SHA{}
+	for _, e := range idx.Entries {
+		if _, ok := paths[e.Name]; !ok || e.FlagStage == 0 || e.FlagStage == 2 {
+			paths[e.Name] = e.SHA
+		}
+	}
+	return paths
+}
+
+// treeFromPaths writes a tree containing the given files
+func treeFromPaths(repo *repository.Repository, paths map[string]*hashing.SHA) (*hashing.SHA, error) {
+	entries := []*index.Entry{}
+	for name, sha := range paths {
+		entries = append(entries, &index.Entry{
+			ModeType:  index.ModeTypeRegular,
+			ModePerms: 0o644,
+			SHA:       sha,
+			Name:      name,
+		})
+	}
+	return objects.TreeFromIndex(repo, index.New(entries))
+}
+
+// changedPaths returns the paths that differ between two trees
+func changedPaths(from, to map[string]*hashing.SHA) []string {
+	changed := []string{}
+	for name, sha := range to {
+		if !sameBlob(from[name], sha) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func sameBlob(a, b *hashing.SHA) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.AsString() == b.AsString()
+}
+
+// readWorktreeFile returns the contents of a file in the worktree, with
+// the clean filters applied so they can be stored as a blob
+func readWorktreeFile(repo *repository.Repository, attrs *attributes.Attributes, name string) ([]byte, error) {
+	contents, err := os.ReadFile(filepath.Join(repo.WorkTree(), name))
+	if err != nil {
+		return nil, err
+	}
+	return filter.Clean(filter.ForPath(attrs, name), contents)
+}
+
+// worktreeBlob returns the hash the file name in the worktree would have
+// as a blob, or nil if there is no such file
+func worktreeBlob(repo *repository.Repository, attrs *attributes.Attributes, name string) (*hashing.SHA, error) {
+	contents, err := readWorktreeFile(repo, attrs, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	blob := &objects.Blob{}
+	blob.Deserialize(contents)
+	return objects.CalculateSha(blob)
+}
+
+// checkoutPaths updates the worktree from the state in current to the state in target
+func checkoutPaths(repo *repository.Repository, attrs *attributes.Attributes, current, target map[string]*hashing.SHA) error {
+	for name := range current {
+		if _, ok := target[name]; !ok {
+			err := removeWorktreeFile(repo, name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for name, sha := range target {
+		if sameBlob(current[name], sha) {
+			continue
+		}
+		err := writeWorktreeFile(repo, attrs, name, sha)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWorktreeFile writes a blob to the worktree, with the smudge filters applied
+func writeWorktreeFile(repo *repository.Repository, attrs *attributes.Attributes, name string, sha *hashing.SHA) error {
+	contents, err := blobContents(repo, sha)
+	if err != nil {
+		return err
+	}
+	return writeWorktreeContents(repo, attrs, name, contents)
+}
+
+// writeWorktreeContents writes contents to the worktree, with the smudge filters applied
+func writeWorktreeContents(repo *repository.Repository, attrs *attributes.Attributes, name string, contents []byte) error {
+	contents, err := filter.Smudge(filter.ForPath(attrs, name), contents)
+	if err != nil {
+		return err
+	}
+	fullPath := filepath.Join(repo.WorkTree(), name)
+	err = os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fullPath, contents, 0o644)
+}
+
+// blobContents returns the contents of a blob, or nothing if sha is nil
+func blobContents(repo *repository.Repository, sha *hashing.SHA) ([]byte, error) {
+	if sha == nil {
+		return []byte{}, nil
+	}
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Serialize()
+}
+
+// removeWorktreeFile removes a file, and the directories that became empty because of it
+func removeWorktreeFile(repo *repository.Repository, name string) error {
+	fullPath := filepath.Join(repo.WorkTree(), name)
+	err := os.Remove(fullPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(fullPath); strings.HasPrefix(dir, repo.WorkTree()) && fs.IsEmptyDirectory(dir); dir = filepath.Dir(dir) {
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// writeIndexFromPaths replaces the index with the given files,
+// using the current state of the files in the worktree
+func writeIndexFromPaths(repo *repository.Repository, paths map[string]*hashing.SHA) error {
+	entries, err := indexEntriesFromPaths(repo, paths)
+	if err != nil {
+		return err
+	}
+	return index.New(entries).Write(repo)
+}
+
+// indexEntriesFromPaths returns index entries for the given files, sorted by name
+func indexEntriesFromPaths(repo *repository.Repository, paths map[string]*hashing.SHA) ([]*index.Entry, error) {
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := []*index.Entry{}
+	for _, name := range names {
+		entry, err := indexEntryFromFile(repo, name, paths[name])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// indexStages returns the blobs in the stages of a file in the index, by
+// stage number. Stages the file doesn't have are nil.
+func indexStages(idx *index.Index, name string) [4]*hashing.SHA {
+	stages := [4]*hashing.SHA{}
+	for _, e := range idx.Entries {
+		if e.Name == name {
+			stages[e.FlagStage] = e.SHA
+		}
+	}
+	return stages
+}
+
+// conflictEntries returns the unmerged index entries for a file, with the
+// common ancestor, our and their version in stage 1, 2 and 3. Versions
+// that don't exist, like a deleted file, have no entry.
+func conflictEntries(name string, ancestor, ours, theirs *hashing.SHA) []*index.Entry {
+	entries := []*index.Entry{}
+	for i, sha := range []*hashing.SHA{ancestor, ours, theirs} {
+		if sha == nil {
+			continue
+		}
+		entries = append(entries, &index.Entry{
+			ModeType:  index.ModeTypeRegular,
+			ModePerms: 0o644,
+			SHA:       sha,
+			FlagStage: uint16(i + 1),
+			Name:      name,
+		})
+	}
+	return entries
+}
+
+func indexEntryFromFile(repo *repository.Repository, name string, sha *hashing.SHA) (*index.Entry, error) {
+	entry := &index.Entry{
+		ModeType:  index.ModeTypeRegular,
+		ModePerms: 0o644,
+		SHA:       sha,
+		Name:      name,
+	}
+
+	// Files that are staged for removal are not in the worktree
+	var stat syscall.Stat_t
+	if err := syscall.Lstat(filepath.Join(repo.WorkTree(), name), &stat); err != nil {
+		return entry, nil
+	}
+	entry.CTime = time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec)
+	entry.MTime = time.Unix(stat.Mtim.Sec, stat.Mtim.Nsec)
+	entry.Dev = uint32(stat.Dev)
+	entry.Inode = uint32(stat.Ino)
+	entry.UID = stat.Uid
+	entry.GID = stat.Gid
+	entry.Size = uint32(stat.Size)
+	return entry, nil
+}
+
+// untrackedFiles lists the files in the worktree that are neither in the index nor ignored
+func untrackedFiles(repo *repository.Repository, idx *index.Index) ([]string, error) {
+	ign, err := ignore.Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	tracked := pathsFromIndex(idx)
+
+	untracked := []string{}
+	err = worktree.Walk(repo, worktree.Options{Ignore: ign}, func(entry worktree.Entry) error {
+		if _, ok := tracked[entry.Path]; !ok {
+			untracked = append(untracked, entry.Path)
+		}
+		return nil
+	})
+	return untracked, err
+}
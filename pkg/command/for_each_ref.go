@@ -0,0 +1,322 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/format"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/wildmatch"
+)
+
+var forEachRefFields = []string{
+	"refname", "objectname", "objecttype", "objectsize", "HEAD",
+	"*objectname", "*objecttype",
+	"authorname", "authoremail", "authordate",
+	"committername", "committeremail", "committerdate",
+	"taggername", "taggeremail", "taggerdate",
+	"creatordate", "subject",
+}
+
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func ForEachRefCommand() *Command {
+	command := newCommand("for-each-ref")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		sortKeys := stringList{}
+		flag.Var(&sortKeys, "sort", "Field to sort on, prefix with - for descending order. Can be given multiple times, the last key is the primary one")
+		formatString := flag.String("format", "%(objectname) %(objecttype)%09%(refname)", "Format of each line")
+		count := flag.Int("count", 0, "Stop after showing this many refs")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+
+		tmpl, err := format.Parse(*formatString, forEachRefFields)
+		if err != nil {
+			return err
+		}
+		if len(sortKeys) == 0 {
+			sortKeys = stringList{"refname"}
+		}
+		if err := checkSortKeys(sortKeys); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		head, err := references.Reference("HEAD").Resolve(repo)
+		if err != nil {
+			return err
+		}
+		branch, onBranch, err := repo.GetActiveBranch()
+		if err != nil {
+			return err
+		}
+		currentRef := ""
+		if onBranch {
+			currentRef = "refs/heads/" + branch
+		}
+
+		details := []*refDetails{}
+		err = references.NewRefStore(repo).Each("refs/", func(ref references.Ref) error {
+			if matchesRefPatterns(ref.Name.String(), flag.Args()) {
+				details = append(details, &refDetails{repo: repo, ref: ref, head: ref.Name.String() == currentRef && head != ""})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		sortRefs(details, sortKeys, versionSuffixes(cfg))
+
+		for i, d := range details {
+			if *count > 0 && i >= *count {
+				break
+			}
+			fmt.Println(tmpl.Expand(format.Func(d.Field)))
+		}
+		return nil
+	}
+	command.Description = func() string { return "Output information on each ref" }
+	return command
+}
+
+// matchesRefPatterns reports whether a ref matches one of the patterns, either
+// as a glob or literally up to a slash. Everything matches if there are no patterns.
+func matchesRefPatterns(ref string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ref == pattern || strings.HasPrefix(ref, strings.TrimSuffix(pattern, "/")+"/") || wildmatch.Match(pattern, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// refDetails provides the fields of a ref. The object the ref points
+// to is only read when a field needs it.
+type refDetails struct {
+	repo *repository.Repository
+	ref  references.Ref
+	head bool
+
+	headerRead bool
+	objType    objects.GitObjectType
+	size       int
+
+	objectRead bool
+	object     objects.GitObject
+}
+
+func (d *refDetails) header() (objects.GitObjectType, int) {
+	if !d.headerRead {
+		d.headerRead = true
+		if sha, err := hashing.NewShaFromHex(d.ref.SHA); err == nil {
+			d.objType, d.size, _ = objects.ReadHeader(d.repo, sha)
+		}
+	}
+	return d.objType, d.size
+}
+
+// value returns a header of the commit or tag the ref points to
+func (d *refDetails) value(key string) ([]byte, bool) {
+	if !d.objectRead {
+		d.objectRead = true
+		if typ, _ := d.header(); typ == objects.TypeCommit || typ == objects.TypeTag {
+			if sha, err := hashing.NewShaFromHex(d.ref.SHA); err == nil {
+				d.object, _ = objects.ReadObject(d.repo, sha)
+			}
+		}
+	}
+	switch obj := d.object.(type) {
+	case *objects.Commit:
+		return obj.GetValue(key)
+	case *objects.Tag:
+		return obj.GetValue(key)
+	}
+	return nil, false
+}
+
+func (d *refDetails) message() string {
+	d.value("")
+	switch obj := d.object.(type) {
+	case *objects.Commit:
+		return obj.Message()
+	case *objects.Tag:
+		return obj.Message()
+	}
+	return ""
+}
+
+// ident returns the identity for fields like authorname or creatordate
+func (d *refDetails) ident(field string) (objects.Ident, bool) {
+	for _, role := range []string{"author", "committer", "tagger"} {
+		if strings.HasPrefix(field, role) {
+			value, ok := d.value(role)
+			return objects.ParseIdent(value), ok
+		}
+	}
+	if field == "creatordate" {
+		if value, ok := d.value("tagger"); ok {
+			return objects.ParseIdent(value), true
+		}
+		value, ok := d.value("committer")
+		return objects.ParseIdent(value), ok
+	}
+	return objects.Ident{}, false
+}
+
+func (d *refDetails) Field(name string) (string, bool) {
+	switch name {
+	case "refname":
+		return d.ref.Name.String(), true
+	case "objectname":
+		return d.ref.SHA, true
+	case "objecttype":
+		typ, _ := d.header()
+		return typ.String(), true
+	case "objectsize":
+		_, size := d.header()
+		return strconv.Itoa(size), true
+	// Like git, the * fields dereference a tag once
+	case "*objectname":
+		if typ, _ := d.header(); typ == objects.TypeTag {
+			target, _ := d.value("object")
+			return string(target), true
+		}
+		return "", true
+	case "*objecttype":
+		if typ, _ := d.header(); typ == objects.TypeTag {
+			target, _ := d.value("type")
+			return string(target), true
+		}
+		return "", true
+	case "HEAD":
+		if d.head {
+			return "*", true
+		}
+		return " ", true
+	case "subject":
+		subject, _, _ := strings.Cut(strings.TrimSpace(d.message()), "\n\n")
+		return strings.ReplaceAll(subject, "\n", " "), true
+	}
+
+	id, ok := d.ident(name)
+	if !ok {
+		return "", false
+	}
+	switch {
+	case strings.HasSuffix(name, "name"):
+		return id.Name, true
+	case strings.HasSuffix(name, "email"):
+		return "<" + id.Email + ">", true
+	case strings.HasSuffix(name, "date"):
+		return id.When.Format(objects.DefaultDateFormat), true
+	}
+	return "", false
+}
+
+// checkSortKeys makes sure the fields of sort keys exist. A key is a field,
+// prefixed with "version:" or "v:" to sort on the version numbers in it,
+// and with "-" for descending order.
+func checkSortKeys(keys []string) error {
+	for _, key := range keys {
+		field, _ := sortField(key)
+		if !slices.Contains(forEachRefFields, field) {
+			return fmt.Errorf("unknown field name: %s", field)
+		}
+	}
+	return nil
+}
+
+// sortField returns the field of a sort key, and whether it is sorted by
+// version
+func sortField(key string) (string, bool) {
+	key = strings.TrimPrefix(key, "-")
+	for _, prefix := range []string{"version:", "v:"} {
+		if field, ok := strings.CutPrefix(key, prefix); ok {
+			return field, true
+		}
+	}
+	return key, false
+}
+
+// sortRefs sorts refs on the keys. suffixes are the prerelease suffixes
+// for version sorts.
+func sortRefs(details []*refDetails, keys []string, suffixes []string) {
+	// Sorting on each key in turn with a stable sort makes the last key the primary one
+	for _, key := range keys {
+		descending := strings.HasPrefix(key, "-")
+		field, version := sortField(key)
+		less := func(a, b *refDetails) bool {
+			if version {
+				x, _ := a.Field(field)
+				y, _ := b.Field(field)
+				return references.CompareVersions(x, y, suffixes) < 0
+			}
+			return a.less(b, field)
+		}
+		sort.SliceStable(details, func(i, j int) bool {
+			if descending {
+				return less(details[j], details[i])
+			}
+			return less(details[i], details[j])
+		})
+	}
+}
+
+// versionSuffixes returns versionsort.suffix, or the older
+// versionsort.prereleaseSuffix if that isn't set
+func versionSuffixes(cfg config.GitConfig) []string {
+	if suffixes := cfg.GetAll("versionsort", "suffix"); len(suffixes) > 0 {
+		return suffixes
+	}
+	return cfg.GetAll("versionsort", "prereleaseSuffix")
+}
+
+// less compares two refs on a sort key. Dates and sizes are compared
+// numerically, the other fields as strings.
+func (d *refDetails) less(other *refDetails, key string) bool {
+	switch {
+	case strings.HasSuffix(key, "date"):
+		a, _ := d.ident(key)
+		b, _ := other.ident(key)
+		return a.When.Before(b.When)
+	case key == "objectsize":
+		_, a := d.header()
+		_, b := other.header()
+		return a < b
+	}
+	a, _ := d.Field(key)
+	b, _ := other.Field(key)
+	return a < b
+}
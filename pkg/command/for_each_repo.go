@@ -0,0 +1,115 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/foreachrepo"
+)
+
+func ForEachRepoCommand() *Command {
+	command := newCommand("for-each-repo")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		key := flag.String("config", "", "Run in the repositories of this multi-valued config key, e.g. maintenance.repo")
+		file := flag.String("file", "", "Run in the repositories listed in this file, one per line, or - for stdin")
+		jobs := flag.Int("jobs", 1, "Number of commands run at the same time, one per CPU if 0")
+		keepGoing := flag.Bool("keep-going", false, "Keep going after the command fails in a repository")
+		format := flag.String("format", "text", "Format of the results: text, or json, which reports failures in the results and not in the exit status")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() == 0 || (*key == "" && *file == "") {
+			return errors.New("usage: got for-each-repo (--config=<key> | --file=<path>) [--jobs=<n>] [--keep-going] [--format=text|json] [--] <command> [<args>...]")
+		}
+		if *format != "text" && *format != "json" {
+			return fmt.Errorf("unknown format '%s'", *format)
+		}
+
+		repos := []string{}
+		if *key != "" {
+			section, name, err := config.SplitKey(*key)
+			if err != nil {
+				return err
+			}
+			cfg, err := currentConfig()
+			if err != nil {
+				return err
+			}
+			for _, path := range cfg.GetAll(section, name) {
+				repos = append(repos, foreachrepo.ExpandHome(path))
+			}
+		}
+		if *file != "" {
+			list, err := readRepoList(*file)
+			if err != nil {
+				return err
+			}
+			repos = append(repos, list...)
+		}
+
+		// Every repository gets a got of its own, run in it
+		program, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		opts := foreachrepo.Options{Jobs: *jobs, KeepGoing: *keepGoing}
+		results := []foreachrepo.Result{}
+		failed := foreachrepo.Run(repos, opts, foreachrepo.Exec(program, flag.Args()), func(r foreachrepo.Result) {
+			if *format == "json" {
+				results = append(results, r)
+				return
+			}
+			fmt.Print(r.Stdout)
+			fmt.Fprint(os.Stderr, r.Stderr)
+			if r.Error != "" {
+				fmt.Fprintf(os.Stderr, "got for-each-repo: %s: %s\n", r.Repo, r.Error)
+			}
+		})
+
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			summary := struct {
+				Repositories []foreachrepo.Result `json:"repositories"`
+				Failed       int                  `json:"failed"`
+			}{results, failed}
+			// An error would be printed after the JSON
+			return enc.Encode(summary)
+		}
+		if failed > 0 {
+			return fmt.Errorf("the command failed in %d of %d repositories", failed, len(repos))
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Run a got command in every repository of a config key or a list"
+	}
+	return command
+}
+
+// readRepoList reads the repositories listed in a file, or in stdin for -
+func readRepoList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	repos, err := foreachrepo.ReadList(r)
+	if err != nil {
+		return nil, err
+	}
+	for i, repo := range repos {
+		repos[i] = foreachrepo.ExpandHome(repo)
+	}
+	return repos, nil
+}
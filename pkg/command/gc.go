@@ -0,0 +1,245 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/commitgraph"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/prune"
+	"github.com/jessegeens/got/pkg/reflog"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// The window and depth of gc --aggressive, when gc.aggressiveWindow and
+// gc.aggressiveDepth aren't set
+const (
+	defaultAggressiveWindow = 250
+	defaultAggressiveDepth  = 50
+)
+
+// analyzeTop is how many of the biggest objects, duplicate blobs and delta
+// groups gc --analyze reports
+const analyzeTop = 10
+
+// GcCommand cleans up the repository.
+//
+// Examples:
+//
+//	got gc --analyze
+//	got gc --aggressive --prune=now
+func GcCommand() *Command {
+	command := newCommand("gc")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		aggressive := flag.Bool("aggressive", false, "Look harder for deltas, with gc.aggressiveWindow and gc.aggressiveDepth (250 and 50 by default)")
+		pruneExpire := flag.String("prune", "", "Prune unreachable loose objects older than this time, like now, instead of gc.pruneExpire (2.weeks.ago by default)")
+		noPrune := flag.Bool("no-prune", false, "Don't prune unreachable objects")
+		cruft := flag.Bool("cruft", false, "Pack unreachable objects that haven't expired into a cruft pack, even if gc.cruftPacks is false")
+		noCruft := flag.Bool("no-cruft", false, "Write unreachable objects loose instead of into a cruft pack")
+		analyze := flag.Bool("analyze", false, "Report what takes up space among the reachable objects, and what packing them saves, before repacking")
+		var quiet bool
+		flag.BoolVar(&quiet, "quiet", false, "Don't report what was done")
+		flag.BoolVar(&quiet, "q", false, "Same as --quiet")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 {
+			return errors.New("usage: got gc [--aggressive] [--analyze] [--prune=<time> | --no-prune] [--[no-]cruft] [-q]")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		refs, err := reflog.Refs(repo)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			if err := reflogExpire(repo, ref, reflogExpiry{}, false, false); err != nil {
+				return err
+			}
+		}
+
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		// Like git, the objects of a precious-objects repo are left alone,
+		// since other repositories may use them
+		if precious, _ := cfg.GetBool("extensions", "preciousObjects"); precious {
+			return nil
+		}
+
+		opts, err := packWriteOptions(repo, -1, -1)
+		if err != nil {
+			return err
+		}
+		if *aggressive {
+			opts.Window, opts.Depth = defaultAggressiveWindow, defaultAggressiveDepth
+			for _, setting := range []struct {
+				key   string
+				value *int
+			}{{"aggressiveWindow", &opts.Window}, {"aggressiveDepth", &opts.Depth}} {
+				if configured, ok := cfg.Get("gc", setting.key); ok {
+					if *setting.value, err = strconv.Atoi(configured); err != nil || *setting.value < 0 {
+						return fmt.Errorf("invalid gc.%s %q", setting.key, configured)
+					}
+				}
+			}
+		}
+
+		// Only what is reachable goes in the pack. The rest goes in a cruft
+		// pack, or stays loose, until it is pruned.
+		roots, err := prune.Roots(repo)
+		if err != nil {
+			return err
+		}
+		reachable, err := prune.Reachable(repo, roots)
+		if err != nil {
+			return err
+		}
+		if *analyze {
+			analysis, err := objects.Analyze(repo, slices.Collect(maps.Keys(reachable)), objects.AnalyzeOptions{WriteOptions: opts, Top: analyzeTop})
+			if err != nil {
+				return err
+			}
+			printAnalysis(repo, analysis)
+		}
+		expire := time.Time{}
+		if !*noPrune {
+			if expire, err = pruneExpiry(repo, *pruneExpire); err != nil {
+				return err
+			}
+		}
+		repackOpts := objects.RepackOptions{
+			WriteOptions: opts,
+			All:          true,
+			Keep:         func(sha string) bool { return reachable[sha] },
+		}
+		// Cruft packs are the default, like in git
+		cruftPacks, ok := cfg.GetBool("gc", "cruftPacks")
+		if (cruftPacks || !ok || *cruft) && !*noCruft {
+			// Like for loose objects, what recent objects reach is kept, since
+			// a command that is still running may use it
+			recent, err := prune.Recent(repo, reachable, expire)
+			if err != nil {
+				return err
+			}
+			repackOpts.Cruft = func(sha string, modTime time.Time) bool {
+				return recent[sha] || !modTime.Before(expire)
+			}
+		}
+		result, err := objects.Repack(repo, repackOpts)
+		if err != nil {
+			return err
+		}
+		if !quiet && result.Count > 0 {
+			fmt.Printf("Packed %d objects into %s\n", result.Count, result.Name)
+		}
+		if !quiet && result.CruftCount > 0 {
+			fmt.Printf("Packed %d unreachable objects into cruft pack %s\n", result.CruftCount, result.CruftName)
+		}
+		// The blames of commits that are going away aren't needed anymore
+		if err := blameCache(repo).Prune(func(sha string) bool { return reachable[sha] }); err != nil {
+			return err
+		}
+		// The commit-graph speeds up walking the history, like in git
+		if write, ok := cfg.GetBool("gc", "writeCommitGraph"); write || !ok {
+			if err := writeCommitGraph(repo, roots, quiet); err != nil {
+				return err
+			}
+		}
+
+		if *noPrune {
+			return nil
+		}
+		pruned, err := prune.Prune(repo, prune.Options{Expire: expire})
+		if err != nil {
+			return err
+		}
+		if !quiet && len(pruned) > 0 {
+			fmt.Printf("Pruned %d unreachable objects\n", len(pruned))
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Clean up the repository: expire old reflog entries, pack the reachable objects and the recent unreachable ones, and prune the rest"
+	}
+	return command
+}
+
+// writeCommitGraph writes the commit-graph of the commits that the roots
+// reach. Roots that aren't commits, like the trees of the index, are left
+// out.
+func writeCommitGraph(repo *repository.Repository, roots []prune.Root, quiet bool) error {
+	tips := []*hashing.SHA{}
+	for _, root := range roots {
+		sha, err := hashing.NewShaFromHex(root.SHA)
+		if err != nil {
+			continue
+		}
+		if commit, err := objects.Peel(repo, sha, objects.TypeCommit); err == nil {
+			tips = append(tips, commit)
+		}
+	}
+	count, err := commitgraph.Write(repo, tips)
+	if err != nil {
+		return err
+	}
+	if !quiet && count > 0 {
+		fmt.Printf("Wrote the commit-graph of %d commits\n", count)
+	}
+	return nil
+}
+
+// printAnalysis prints the report of gc --analyze
+func printAnalysis(repo *repository.Repository, analysis *objects.Analysis) {
+	abbrev := objects.AbbrevLength(repo)
+	short := func(sha string) string {
+		hash, err := hashing.NewShaFromHex(sha)
+		if err != nil {
+			return sha
+		}
+		return objects.ShortSHA(repo, hash, abbrev)
+	}
+
+	fmt.Println("Reachable objects:")
+	for _, stats := range analysis.Types {
+		fmt.Printf("  %-8s %8d  %12s\n", stats.Type+"s", stats.Count, humanBytes(stats.Size))
+	}
+	fmt.Printf("  %-8s %8s  %12s\n", "total", "", humanBytes(analysis.Size))
+
+	if len(analysis.Biggest) > 0 {
+		fmt.Println("\nBiggest objects:")
+		for _, obj := range analysis.Biggest {
+			line := fmt.Sprintf("  %12s  %-6s %s  %s", humanBytes(obj.Size), obj.Type, short(obj.SHA), obj.Path)
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	}
+	if len(analysis.Duplicates) > 0 {
+		fmt.Println("\nBlobs at more than one path, stored once:")
+		for _, dup := range analysis.Duplicates {
+			fmt.Printf("  %12s saved  %s  %s\n", humanBytes(dup.Saved()), short(dup.SHA), strings.Join(dup.Paths, ", "))
+		}
+	}
+	if len(analysis.Groups) > 0 {
+		fmt.Println("\nObjects packed as deltas of each other:")
+		for _, group := range analysis.Groups {
+			name := group.Base.Path
+			if name == "" {
+				name = string(group.Base.Type) + " " + short(group.Base.SHA)
+			}
+			fmt.Printf("  %12s saved  %d objects, %s as %s  %s\n", humanBytes(group.Saved()), group.Count, humanBytes(group.Size), humanBytes(group.DeltaSize), name)
+		}
+	}
+	fmt.Printf("\nDeltas take the objects from %s to %s, before compression\n", humanBytes(analysis.Size), humanBytes(analysis.DeltaSize))
+}
@@ -0,0 +1,281 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/grep"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+type grepOptions struct {
+	lineNumbers      bool
+	count            bool
+	filesWithMatches bool
+}
+
+func GrepCommand() *Command {
+	command := newCommand("grep")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var opts grepOptions
+		cached := flag.Bool("cached", false, "Search the blobs in the index instead of the files in the worktree")
+		pattern := flag.String("e", "", "The pattern to search for")
+		ignoreCase := flag.Bool("i", false, "Ignore case differences between the pattern and the files")
+		fixed := flag.Bool("F", false, "Take the pattern as a fixed string instead of a regular expression")
+		extended := flag.Bool("E", false, "Take the pattern as an extended regular expression instead of a basic one")
+		flag.BoolVar(extended, "extended-regexp", false, "Same as -E")
+		basic := func(string) error {
+			*extended = false
+			return nil
+		}
+		flag.BoolFunc("G", "Take the pattern as a basic regular expression, which is the default", basic)
+		flag.BoolFunc("basic-regexp", "Same as -G", basic)
+		invert := flag.Bool("v", false, "Select the lines that don't match")
+		flag.BoolVar(&opts.lineNumbers, "n", false, "Prefix matching lines with their line number")
+		flag.BoolVar(&opts.count, "c", false, "Show the number of matching lines of each file instead of the lines")
+		flag.BoolVar(&opts.count, "count", false, "Same as -c")
+		flag.BoolVar(&opts.filesWithMatches, "l", false, "Only show the names of the files that match")
+		flag.BoolVar(&opts.filesWithMatches, "files-with-matches", false, "Same as -l")
+		flag.BoolVar(&opts.filesWithMatches, "name-only", false, "Same as -l")
+		threads := flag.Int("threads", 0, "Number of files searched at the same time, one per CPU by default")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		rest := flag.Args()
+		if *pattern == "" {
+			if len(rest) == 0 {
+				return errors.New("usage: got grep [<options>] <pattern> [<tree-ish>...] [--] [<path>...]")
+			}
+			*pattern, rest = rest[0], rest[1:]
+		}
+		switch {
+		case *fixed:
+			*pattern = regexp.QuoteMeta(*pattern)
+		case !*extended:
+			translated, err := grep.TranslateBasic(*pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern: %w", err)
+			}
+			*pattern = translated
+		}
+		if *ignoreCase {
+			*pattern = "(?i)" + *pattern
+		}
+		re, err := regexp.Compile(*pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		trees, paths, err := grepArgs(repo, rest)
+		if err != nil {
+			return err
+		}
+		if *cached && len(trees) > 0 {
+			return errors.New("--cached cannot be used with a tree")
+		}
+
+		files := []grep.File{}
+		for _, tree := range trees {
+			treeFiles, err := grepTreeFiles(repo, tree, paths)
+			if err != nil {
+				return err
+			}
+			files = append(files, treeFiles...)
+		}
+		if len(trees) == 0 {
+			idx, err := index.Read(repo)
+			if err != nil {
+				return err
+			}
+			files = grepIndexFiles(repo, idx, paths, *cached)
+		}
+
+		searchOpts := grep.Options{
+			Pattern:   re,
+			Invert:    *invert,
+			FirstOnly: opts.filesWithMatches,
+			Threads:   *threads,
+		}
+		w := bufio.NewWriter(os.Stdout)
+		matched := false
+		err = grep.Search(files, searchOpts, func(r grep.Result) error {
+			matched = true
+			return writeGrepResult(w, r, opts)
+		})
+		if flushErr := w.Flush(); err == nil {
+			err = flushErr
+		}
+		// Like git, nothing matching is not an error, but exits with 1
+		if err == nil && !matched {
+			return ExitStatus(1)
+		}
+		return err
+	}
+	command.Description = func() string { return "Print lines of tracked files that match a pattern" }
+	return command
+}
+
+// grepArgs splits the arguments after the pattern in trees and paths.
+// Like git, arguments are trees until one isn't, or until --.
+func grepArgs(repo *repository.Repository, args []string) ([]string, []string, error) {
+	trees := []string{}
+	for len(args) > 0 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		if _, err := objects.Find(repo, args[0], objects.TypeTree, true); err != nil {
+			break
+		}
+		trees, args = append(trees, args[0]), args[1:]
+	}
+
+	paths := []string{}
+	for _, arg := range args {
+		if arg == "--" {
+			continue
+		}
+		p, err := worktreePath(repo, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, p)
+	}
+	return trees, paths, nil
+}
+
+// grepPathMatches returns whether name is one of paths or below one of
+// them. Without paths, every file matches.
+func grepPathMatches(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		if p == "." || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// grepIndexFiles returns the files in the index, read from the index with
+// cached or from the worktree otherwise. Submodules are skipped, and
+// conflicted files are only searched once.
+func grepIndexFiles(repo *repository.Repository, idx *index.Index, paths []string, cached bool) []grep.File {
+	files := []grep.File{}
+	for _, e := range idx.Entries {
+		if e.ModeType == index.ModeTypeGitlink || !grepPathMatches(e.Name, paths) {
+			continue
+		}
+		if n := len(files); n > 0 && files[n-1].Path == e.Name {
+			continue
+		}
+		name, sha := e.Name, e.SHA
+		load := func() ([]byte, error) {
+			return os.ReadFile(filepath.Join(repo.WorkTree(), name))
+		}
+		if cached {
+			load = func() ([]byte, error) { return blobContents(repo, sha) }
+		}
+		files = append(files, grep.File{Path: name, Load: load})
+	}
+	return files
+}
+
+// grepTreeFiles returns the blobs in a tree, sorted by path, which are
+// prefixed with the name of the tree like git does
+func grepTreeFiles(repo *repository.Repository, tree string, paths []string) ([]grep.File, error) {
+	sha, err := objects.Find(repo, tree, objects.TypeTree, true)
+	if err != nil {
+		return nil, err
+	}
+	blobs := map[string]*hashing.SHA{}
+	if err := collectTreeBlobs(repo, sha, "", blobs); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range blobs {
+		if grepPathMatches(name, paths) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	files := []grep.File{}
+	for _, name := range names {
+		sha := blobs[name]
+		files = append(files, grep.File{
+			Path: tree + ":" + name,
+			Load: func() ([]byte, error) { return blobContents(repo, sha) },
+		})
+	}
+	return files, nil
+}
+
+// collectTreeBlobs adds the blobs below a tree to blobs, by path.
+// Submodules are skipped, since their commits aren't in the repository.
+func collectTreeBlobs(repo *repository.Repository, sha *hashing.SHA, prefix string, blobs map[string]*hashing.SHA) error {
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return err
+	}
+	tree, ok := obj.(*objects.Tree)
+	if !ok {
+		return fmt.Errorf("%s is not a tree", sha.AsString())
+	}
+	for _, item := range tree.Items {
+		name := path.Join(prefix, item.PrintPath())
+		switch {
+		case strings.HasPrefix(string(item.Mode), "4") || strings.HasPrefix(string(item.Mode), "04"):
+			if err := collectTreeBlobs(repo, item.Sha, name, blobs); err != nil {
+				return err
+			}
+		case string(item.Mode) == "160000":
+			continue
+		default:
+			blobs[name] = item.Sha
+		}
+	}
+	return nil
+}
+
+// writeGrepResult prints the matches in a file like git grep
+func writeGrepResult(w *bufio.Writer, r grep.Result, opts grepOptions) error {
+	var err error
+	switch {
+	case opts.filesWithMatches:
+		_, err = fmt.Fprintln(w, r.Path)
+	case opts.count:
+		_, err = fmt.Fprintf(w, "%s:%d\n", r.Path, len(r.Matches))
+	case r.Binary:
+		_, err = fmt.Fprintf(w, "Binary file %s matches\n", r.Path)
+	default:
+		for _, m := range r.Matches {
+			if opts.lineNumbers {
+				_, err = fmt.Fprintf(w, "%s:%d:%s\n", r.Path, m.Line, m.Text)
+			} else {
+				_, err = fmt.Fprintf(w, "%s:%s\n", r.Path, m.Text)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
@@ -1,10 +1,13 @@
 package command
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/jessegeens/got/pkg/hashing"
 	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
 )
@@ -12,35 +15,64 @@ import (
 func HashObjectCommand() *Command {
 	command := newCommand("hash-object")
 	command.Action = func(args []string) error {
-		write := *flag.Bool("w", true, "Actually write the object into the database")
-		path := *flag.String("path", "", "Read object from <file>")
-		objType := *flag.String("type", "", "Object type. Possible values are blob, commit, tag, tree")
-
-		parsedObjType, err := objects.ParseType(objType)
-		if err != nil {
+		command.ResetFlags()
+		write := flag.Bool("w", false, "Actually write the object into the database")
+		var objType string
+		flag.StringVar(&objType, "t", "blob", "Object type. Possible values are blob, commit, tag, tree")
+		flag.StringVar(&objType, "type", "blob", "Same as -t")
+		stdin := flag.Bool("stdin", false, "Read the object from standard input instead of from files")
+		literally := flag.Bool("literally", false, "Hash the object without checking it, with any type, to make broken objects for tests")
+		if err := flag.CommandLine.Parse(args); err != nil {
 			return err
 		}
+		if !*stdin && flag.NArg() == 0 {
+			return errors.New("usage: got hash-object [-t <type>] [-w] [--literally] (--stdin | <file>...)")
+		}
 
-		var repo *repository.Repository = nil
-
-		if write {
-			repo, err = repository.Find(".")
+		var repo *repository.Repository
+		if *write {
+			var err error
+			if repo, err = repository.Find("."); err != nil {
+				return err
+			}
+		}
+		hash := func(data []byte) error {
+			var sha *hashing.SHA
+			var err error
+			if *literally {
+				sha, err = objects.HashLiterally(data, objType, repo)
+			} else {
+				var parsedObjType objects.GitObjectType
+				if parsedObjType, err = objects.ParseType(objType); err != nil {
+					return err
+				}
+				sha, err = objects.ObjectHash(data, parsedObjType, repo)
+			}
 			if err != nil {
 				return err
 			}
+			fmt.Println(sha.AsString())
+			return nil
 		}
 
-		b, err := os.ReadFile(path)
-		if err != nil {
-			return err
+		if *stdin {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			if err := hash(data); err != nil {
+				return err
+			}
 		}
-		sha, err := objects.ObjectHash(b, parsedObjType, repo)
-		if err != nil {
-			return err
+		for _, path := range flag.Args() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := hash(data); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
 		}
-
-		fmt.Println(sha)
-
 		return nil
 	}
 	command.Description = func() string { return "Compute object ID and optionally creates a blob from a file" }
@@ -0,0 +1,172 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+)
+
+//go:generate go run ../../cmd/genhelp -o help_pages.go
+
+// Commands are the commands of got, which got help documents. They are set
+// by main, since the help command is one of them.
+var Commands []*Command
+
+// helpPage is the long-form help of a command, which genhelp reads from
+// its source into help_pages.go
+type helpPage struct {
+	Synopsis []string
+	Options  []helpOption
+	Examples []string
+}
+
+// helpOption is a flag of a command, with its aliases
+type helpOption struct {
+	Names []string
+	// Arg names the value the flag takes, if it takes one
+	Arg   string
+	Usage string
+}
+
+// HelpCommand shows the manual pages of the commands.
+//
+// Examples:
+//
+//	got help gc
+//	got help --all
+func HelpCommand() *Command {
+	command := newCommand("help")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var all bool
+		flag.BoolVar(&all, "all", false, "Show the pages of all commands")
+		flag.BoolVar(&all, "a", false, "Same as --all")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 1 || all && flag.NArg() > 0 {
+			return errors.New("usage: got help [--all | <command>]")
+		}
+
+		var b strings.Builder
+		switch {
+		case all:
+			for i, cmd := range Commands {
+				if i > 0 {
+					b.WriteString("\n")
+				}
+				writeHelpPage(&b, cmd)
+			}
+		case flag.NArg() == 1:
+			name := flag.Arg(0)
+			for _, cmd := range Commands {
+				if cmd.Name == name {
+					writeHelpPage(&b, cmd)
+				}
+			}
+			if b.Len() == 0 {
+				return fmt.Errorf("no help for '%s', it is not a got command", name)
+			}
+		default:
+			WriteCommandList(os.Stdout, Commands)
+			fmt.Println("\nSee 'got help <command>' for the page of a command, or 'got help --all' for all of them.")
+			return nil
+		}
+		return showPaged(b.String())
+	}
+	command.Description = func() string { return "Show the manual page of a command, or of all of them with --all" }
+	return command
+}
+
+// WriteCommandList writes the names and descriptions of the commands
+func WriteCommandList(w io.Writer, commands []*Command) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "Command\tDescription")
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "%s\t%s\n", cmd.Name, cmd.Description())
+	}
+	tw.Flush()
+}
+
+// writeHelpPage writes the page of a command in the layout of a man page.
+// Commands without a synopsis, options or examples in help_pages.go still
+// have a page, with their name and description.
+func writeHelpPage(w io.Writer, cmd *Command) {
+	help := helpPages[cmd.Name]
+	section := func(title string) {
+		fmt.Fprintf(w, "\n%s\n", title)
+	}
+	fmt.Fprintf(w, "GOT-%s\n", strings.ToUpper(cmd.Name))
+	section("NAME")
+	fmt.Fprintf(w, "       got-%s - %s\n", cmd.Name, cmd.Description())
+
+	synopsis := help.Synopsis
+	if len(synopsis) == 0 {
+		synopsis = []string{"got " + cmd.Name}
+		if len(help.Options) > 0 {
+			synopsis[0] += " [<options>]"
+		}
+	}
+	section("SYNOPSIS")
+	for _, line := range synopsis {
+		fmt.Fprintf(w, "       %s\n", line)
+	}
+
+	if len(help.Options) > 0 {
+		section("OPTIONS")
+		for i, opt := range help.Options {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			names := strings.Join(opt.Names, ", ")
+			if opt.Arg != "" {
+				names += " <" + opt.Arg + ">"
+			}
+			fmt.Fprintf(w, "       %s\n", names)
+			fmt.Fprintf(w, "           %s\n", opt.Usage)
+		}
+	}
+
+	if len(help.Examples) > 0 {
+		section("EXAMPLES")
+		for _, line := range help.Examples {
+			fmt.Fprintln(w, strings.TrimRight("       "+line, " "))
+		}
+	}
+}
+
+// showPaged writes text to the pager, like git does for long output, when
+// the output is a terminal
+func showPaged(text string) error {
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+	// Outside a repository, only the global configuration applies
+	cfg, err := currentConfig()
+	if err != nil {
+		return err
+	}
+	program := pager(cfg)
+	if program == "cat" {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+
+	cmd := exec.Command("sh", "-c", program)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Like git, less quits when the page fits on the screen, and keeps
+	// the colors
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	return cmd.Run()
+}
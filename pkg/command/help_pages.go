@@ -0,0 +1,490 @@
+// Code generated by genhelp; DO NOT EDIT.
+
+package command
+
+var helpPages = map[string]helpPage{
+	"add":      {},
+	"annotate": {},
+	"archive": {
+		Synopsis: []string{"got archive [--format=<fmt>] [--prefix=<prefix>/] [-o <file>] <tree-ish>"},
+		Options: []helpOption{
+			{Names: []string{"--format"}, Arg: "string", Usage: "Format of the archive: tar or zip"},
+			{Names: []string{"-o"}, Arg: "string", Usage: "Write the archive to this file instead of stdout"},
+			{Names: []string{"--prefix"}, Arg: "string", Usage: "Prepend prefix to each path in the archive"},
+		},
+	},
+	"blame": {
+		Synopsis: []string{"got blame [<options>] [<rev>] [--] <file>"},
+		Options: []helpOption{
+			{Names: []string{"-c"}, Usage: "Show the results like got annotate"},
+			{Names: []string{"--incremental"}, Usage: "Show the results in a format for programs, as they are found"},
+			{Names: []string{"-l"}, Usage: "Show the full commit hash"},
+			{Names: []string{"--line-porcelain"}, Usage: "Like --porcelain, but with the details of the commit for every line"},
+			{Names: []string{"-p", "--porcelain"}, Usage: "Show the results in a format for programs, with the details of each commit once"},
+			{Names: []string{"-s"}, Usage: "Leave out the author name and date"},
+		},
+	},
+	"bugreport": {
+		Options: []helpOption{
+			{Names: []string{"-o"}, Arg: "string", Usage: "Directory to write the report to, defaults to the current directory"},
+		},
+	},
+	"cat-file": {
+		Synopsis: []string{"got cat-file [-t | -s | -e | -p] <object> | got cat-file <type> <object>"},
+		Options: []helpOption{
+			{Names: []string{"-e"}, Usage: "Only exit with a non-zero status if the object doesn't exist"},
+			{Names: []string{"-p"}, Usage: "Show the contents of the object, with trees listed like ls-tree does"},
+			{Names: []string{"-s"}, Usage: "Show the size of the object"},
+			{Names: []string{"-t"}, Usage: "Show the type of the object"},
+		},
+	},
+	"check-attr": {
+		Options: []helpOption{
+			{Names: []string{"-a"}, Usage: "List all attributes that are set on the paths"},
+			{Names: []string{"--all"}, Usage: "List all attributes that are set on the paths"},
+			{Names: []string{"--stdin"}, Usage: "Read paths from standard input, one per line"},
+		},
+	},
+	"check-ignore": {
+		Options: []helpOption{
+			{Names: []string{"-n", "--non-matching"}, Usage: "With -v, also show paths that don't match any rule"},
+			{Names: []string{"--path"}, Arg: "string", Usage: "Paths to check"},
+			{Names: []string{"-v", "--verbose"}, Usage: "Also show the rule that matched each path"},
+			{Names: []string{"-z"}, Usage: "End output with NUL instead of newline, and don't quote paths; with -v, every field ends with NUL"},
+		},
+	},
+	"check-mailmap": {
+		Synopsis: []string{"got check-mailmap [--stdin] <contact>..."},
+		Options: []helpOption{
+			{Names: []string{"--stdin"}, Usage: "Also read contacts from stdin, one per line"},
+		},
+	},
+	"check-ref-format": {
+		Synopsis: []string{"got check-ref-format [--normalize] [--allow-onelevel] [--refspec-pattern] <refname>", "got check-ref-format --branch <branchname>"},
+		Options: []helpOption{
+			{Names: []string{"--allow-onelevel"}, Usage: "Accept names without a slash"},
+			{Names: []string{"--branch"}, Usage: "Check a branch name and print it"},
+			{Names: []string{"--normalize"}, Usage: "Normalize the name and print it"},
+			{Names: []string{"--refspec-pattern"}, Usage: "Accept a single * as a component"},
+		},
+	},
+	"checkout": {
+		Options: []helpOption{
+			{Names: []string{"--commit"}, Arg: "string", Usage: "The commit or tree to checkout"},
+			{Names: []string{"--force"}, Usage: "Overwrite files and directories that are in the way"},
+			{Names: []string{"--ours"}, Usage: "Check out our version of unmerged paths"},
+			{Names: []string{"--path"}, Arg: "string", Usage: "The empty directory to checkout on"},
+			{Names: []string{"--theirs"}, Usage: "Check out their version of unmerged paths"},
+		},
+	},
+	"clone": {
+		Synopsis: []string{"got clone [-b <branch>] [-o <name>] [-n] [--single-branch] [--no-tags] <repository> [<directory>]"},
+		Options: []helpOption{
+			{Names: []string{"-b", "--branch"}, Arg: "string", Usage: "Check out this branch, or tag, of the remote instead of its HEAD"},
+			{Names: []string{"-n", "--no-checkout"}, Usage: "Don't check out HEAD after the clone"},
+			{Names: []string{"--no-tags"}, Usage: "Don't fetch any tags, now or in later fetches"},
+			{Names: []string{"-o", "--origin"}, Arg: "string", Usage: "Name of the remote"},
+			{Names: []string{"--single-branch"}, Usage: "Only fetch the branch that is checked out, now and in later fetches"},
+		},
+		Examples: []string{"got clone https://example.com/project.git", "got clone -b topic -o upstream host:project.git work", "got clone --single-branch --no-tags https://example.com/project.git"},
+	},
+	"commit": {
+		Options: []helpOption{
+			{Names: []string{"-S", "--gpg-sign"}, Usage: "Sign the commit with user.signingKey, in the format of gpg.format"},
+			{Names: []string{"--allow-empty"}, Usage: "Allow recording a commit that does not change the tree"},
+			{Names: []string{"--allow-empty-message"}, Usage: "Allow recording a commit with an empty message"},
+			{Names: []string{"--amend"}, Usage: "Replace the last commit, with the message of that commit as the starting point"},
+			{Names: []string{"--author"}, Arg: "string", Usage: "Override the author, given as 'Name <email>'"},
+			{Names: []string{"--date"}, Arg: "string", Usage: "Override the author date, e.g. in RFC 2822 format, as '<unix timestamp> <zone>' or like '2 days ago'"},
+			{Names: []string{"-m"}, Arg: "string", Usage: "Message to associate with this commit"},
+			{Names: []string{"--message"}, Arg: "string", Usage: "Message to associate with this commit"},
+			{Names: []string{"--no-edit"}, Usage: "Use the prepared message, like the one of the amended commit, without launching an editor"},
+			{Names: []string{"--no-gpg-sign"}, Usage: "Don't sign the commit, even if commit.gpgSign is set"},
+			{Names: []string{"--no-verify"}, Usage: "Bypass the pre-commit and commit-msg hooks and callbacks, and the checks of the message"},
+			{Names: []string{"-t", "--template"}, Arg: "string", Usage: "Start the message in the editor from this file instead of commit.template"},
+		},
+	},
+	"commit-tree": {
+		Synopsis: []string{"got commit-tree <tree> [-p <parent>]... [-S] [-m <message>]... [-F <file>]..."},
+		Options: []helpOption{
+			{Names: []string{"-F"}, Arg: "value", Usage: "Read a paragraph of the message from a file, or from standard input for -"},
+			{Names: []string{"-S", "--gpg-sign"}, Usage: "Sign the commit with user.signingKey, in the format of gpg.format"},
+			{Names: []string{"-m"}, Arg: "value", Usage: "A paragraph of the message, can be given multiple times"},
+			{Names: []string{"-p"}, Arg: "value", Usage: "A parent commit, can be given multiple times"},
+		},
+	},
+	"count-objects": {
+		Synopsis: []string{"got count-objects [-v] [-H]"},
+		Options: []helpOption{
+			{Names: []string{"-H", "--human-readable"}, Usage: "Show sizes in a human readable format"},
+			{Names: []string{"-v", "--verbose"}, Usage: "Also report the packs, the packed objects and the garbage"},
+		},
+	},
+	"diff": {
+		Synopsis: []string{"got diff --no-index <path> <path>", "got diff [--cached] [<tree-ish> [<tree-ish>]]"},
+		Options: []helpOption{
+			{Names: []string{"-C", "--find-copies"}, Arg: "value", Usage: "Find copies of modified files as well as renames, of files that are at least n similar (default 50%)"},
+			{Names: []string{"-M", "--find-renames"}, Arg: "value", Usage: "Find renames, of files that are at least n similar, like 60% (default 50%)"},
+			{Names: []string{"-U", "--unified"}, Arg: "int", Usage: "Number of unchanged lines to show around changes"},
+			{Names: []string{"--cached", "--staged"}, Usage: "Compare the index to HEAD, or to the given commit"},
+			{Names: []string{"--find-copies-harder"}, Usage: "Find copies of unmodified files too"},
+			{Names: []string{"--name-only"}, Usage: "Only show the names of the changed files"},
+			{Names: []string{"--no-index"}, Usage: "Compare two files or directories on disk, which don't have to be in a repository"},
+			{Names: []string{"--no-renames"}, Usage: "Don't find renames, even if diff.renames is set"},
+			{Names: []string{"--raw"}, Usage: "Show the changed files in git's raw format instead of a patch"},
+			{Names: []string{"--stat"}, Usage: "Show the number of changed lines per file instead of a patch"},
+			{Names: []string{"--submodule"}, Arg: "string", Usage: "How submodules are shown: short, the commits they are at, or log, the subjects of the commits in between; defaults to diff.submodule or short"},
+			{Names: []string{"-z"}, Usage: "With --name-only, end names with NUL instead of newline, and don't quote them"},
+		},
+	},
+	"export-metadata": {
+		Synopsis: []string{"got export-metadata [--format=json|html] [--template=<file>] [--commits=<n>] [-o <file>]"},
+		Options: []helpOption{
+			{Names: []string{"--commits"}, Arg: "int", Usage: "How many recent commits to list"},
+			{Names: []string{"--format"}, Arg: "string", Usage: "Format of the summary: json or html"},
+			{Names: []string{"-o"}, Arg: "string", Usage: "Write the summary to this file instead of stdout"},
+			{Names: []string{"--template"}, Arg: "string", Usage: "Render the summary with this html/template file"},
+		},
+	},
+	"fetch": {
+		Options: []helpOption{
+			{Names: []string{"-f", "--force"}, Usage: "Update refs even when it isn't a fast-forward"},
+			{Names: []string{"--no-tags"}, Usage: "Don't fetch the tags that point to what is fetched"},
+			{Names: []string{"-t", "--tags"}, Usage: "Fetch all the tags of the remote"},
+		},
+	},
+	"for-each-ref": {
+		Options: []helpOption{
+			{Names: []string{"--count"}, Arg: "int", Usage: "Stop after showing this many refs"},
+			{Names: []string{"--format"}, Arg: "string", Usage: "Format of each line"},
+			{Names: []string{"--sort"}, Arg: "value", Usage: "Field to sort on, prefix with - for descending order. Can be given multiple times, the last key is the primary one"},
+		},
+	},
+	"for-each-repo": {
+		Synopsis: []string{"got for-each-repo (--config=<key> | --file=<path>) [--jobs=<n>] [--keep-going] [--format=text|json] [--] <command> [<args>...]"},
+		Options: []helpOption{
+			{Names: []string{"--config"}, Arg: "string", Usage: "Run in the repositories of this multi-valued config key, e.g. maintenance.repo"},
+			{Names: []string{"--file"}, Arg: "string", Usage: "Run in the repositories listed in this file, one per line, or - for stdin"},
+			{Names: []string{"--format"}, Arg: "string", Usage: "Format of the results: text, or json, which reports failures in the results and not in the exit status"},
+			{Names: []string{"--jobs"}, Arg: "int", Usage: "Number of commands run at the same time, one per CPU if 0"},
+			{Names: []string{"--keep-going"}, Usage: "Keep going after the command fails in a repository"},
+		},
+	},
+	"gc": {
+		Synopsis: []string{"got gc [--aggressive] [--analyze] [--prune=<time> | --no-prune] [--[no-]cruft] [-q]"},
+		Options: []helpOption{
+			{Names: []string{"--aggressive"}, Usage: "Look harder for deltas, with gc.aggressiveWindow and gc.aggressiveDepth (250 and 50 by default)"},
+			{Names: []string{"--analyze"}, Usage: "Report what takes up space among the reachable objects, and what packing them saves, before repacking"},
+			{Names: []string{"--cruft"}, Usage: "Pack unreachable objects that haven't expired into a cruft pack, even if gc.cruftPacks is false"},
+			{Names: []string{"--no-cruft"}, Usage: "Write unreachable objects loose instead of into a cruft pack"},
+			{Names: []string{"--no-prune"}, Usage: "Don't prune unreachable objects"},
+			{Names: []string{"--prune"}, Arg: "string", Usage: "Prune unreachable loose objects older than this time, like now, instead of gc.pruneExpire (2.weeks.ago by default)"},
+			{Names: []string{"-q", "--quiet"}, Usage: "Don't report what was done"},
+		},
+		Examples: []string{"got gc --analyze", "got gc --aggressive --prune=now"},
+	},
+	"grep": {
+		Synopsis: []string{"got grep [<options>] <pattern> [<tree-ish>...] [--] [<path>...]"},
+		Options: []helpOption{
+			{Names: []string{"-E", "--extended-regexp"}, Usage: "Take the pattern as an extended regular expression instead of a basic one"},
+			{Names: []string{"-F"}, Usage: "Take the pattern as a fixed string instead of a regular expression"},
+			{Names: []string{"-G", "--basic-regexp"}, Usage: "Take the pattern as a basic regular expression, which is the default"},
+			{Names: []string{"-c", "--count"}, Usage: "Show the number of matching lines of each file instead of the lines"},
+			{Names: []string{"--cached"}, Usage: "Search the blobs in the index instead of the files in the worktree"},
+			{Names: []string{"-e"}, Arg: "string", Usage: "The pattern to search for"},
+			{Names: []string{"-i"}, Usage: "Ignore case differences between the pattern and the files"},
+			{Names: []string{"-l", "--name-only", "--files-with-matches"}, Usage: "Only show the names of the files that match"},
+			{Names: []string{"-n"}, Usage: "Prefix matching lines with their line number"},
+			{Names: []string{"--threads"}, Arg: "int", Usage: "Number of files searched at the same time, one per CPU by default"},
+			{Names: []string{"-v"}, Usage: "Select the lines that don't match"},
+		},
+	},
+	"hash-object": {
+		Synopsis: []string{"got hash-object [-t <type>] [-w] [--literally] (--stdin | <file>...)"},
+		Options: []helpOption{
+			{Names: []string{"--literally"}, Usage: "Hash the object without checking it, with any type, to make broken objects for tests"},
+			{Names: []string{"--stdin"}, Usage: "Read the object from standard input instead of from files"},
+			{Names: []string{"-t", "--type"}, Arg: "string", Usage: "Object type. Possible values are blob, commit, tag, tree"},
+			{Names: []string{"-w"}, Usage: "Actually write the object into the database"},
+		},
+	},
+	"help": {
+		Synopsis: []string{"got help [--all | <command>]"},
+		Options: []helpOption{
+			{Names: []string{"-a", "--all"}, Usage: "Show the pages of all commands"},
+		},
+		Examples: []string{"got help gc", "got help --all"},
+	},
+	"import-snapshots": {
+		Synopsis: []string{"got import-snapshots [--branch <name>] [--author <ident>] [--manifest <file>] [<tarball|directory>...]"},
+		Options: []helpOption{
+			{Names: []string{"--author"}, Arg: "string", Usage: "Author of the commits as 'Name <email>', instead of the configured one"},
+			{Names: []string{"--branch"}, Arg: "string", Usage: "The branch that the commits are added to"},
+			{Names: []string{"--manifest"}, Arg: "string", Usage: "Read the snapshots from a file with lines of '<snapshot> TAB <date> TAB <message>'"},
+		},
+	},
+	"index-pack": {
+		Synopsis: []string{"got index-pack [-o <index-file>] <pack-file> | got index-pack --stdin [--strict]"},
+		Options: []helpOption{
+			{Names: []string{"-o"}, Arg: "string", Usage: "Write the index to this file instead of next to the pack"},
+			{Names: []string{"--stdin"}, Usage: "Read the pack from standard input and store it in the repository, with its index"},
+			{Names: []string{"--strict"}, Usage: "With --stdin, refuse the pack if an object in it fails the fsck checks, even if receive.fsckObjects is off"},
+		},
+	},
+	"init": {
+		Synopsis: []string{"got init [--template=<template-directory>] [<directory>]"},
+		Options: []helpOption{
+			{Names: []string{"--template"}, Arg: "string", Usage: "Directory whose files are copied into the new gitdir"},
+		},
+	},
+	"interop-map": {
+		Synopsis: []string{"got interop-map write | lookup <name>..."},
+	},
+	"log": {
+		Options: []helpOption{
+			{Names: []string{"-C", "--find-copies"}, Arg: "value", Usage: "Find copies of modified files as well as renames, of files that are at least n similar (default 50%)"},
+			{Names: []string{"-M", "--find-renames"}, Arg: "value", Usage: "Find renames, of files that are at least n similar, like 60% (default 50%)"},
+			{Names: []string{"--commit"}, Arg: "string", Usage: "Commit to start at"},
+			{Names: []string{"--date"}, Arg: "string", Usage: "Show the author date of each commit, in a format like relative, iso, rfc or short"},
+			{Names: []string{"--find-copies-harder"}, Usage: "Find copies of unmodified files too"},
+			{Names: []string{"-n", "--max-count"}, Arg: "int", Usage: "Show at most this many commits"},
+			{Names: []string{"--no-renames"}, Usage: "Don't find renames, even if diff.renames is set"},
+			{Names: []string{"--raw"}, Usage: "Show the commits like git log, with the changed files of each commit in git's raw format, instead of a graph"},
+			{Names: []string{"--show-signature"}, Usage: "Check the validity of signed commits"},
+			{Names: []string{"--since", "--after"}, Arg: "string", Usage: "Only show commits more recent than a date, like '2 weeks ago'"},
+			{Names: []string{"--skip"}, Arg: "int", Usage: "Skip this many commits before starting to show them"},
+			{Names: []string{"--stat"}, Usage: "Show the number of changed lines per file of each commit"},
+			{Names: []string{"--until", "--before"}, Arg: "string", Usage: "Only show commits older than a date"},
+		},
+	},
+	"ls-files": {
+		Options: []helpOption{
+			{Names: []string{"--eol"}, Usage: "Show the line endings of files in the index and the worktree, and their text and eol attributes"},
+			{Names: []string{"--format"}, Arg: "string", Usage: "Format of each line, e.g. %(objectname) %(path)"},
+			{Names: []string{"--sparse"}, Usage: "Show the directories of a sparse index as they are, instead of the files in them"},
+			{Names: []string{"--verbose"}, Usage: "Show everything"},
+			{Names: []string{"-z"}, Usage: "End lines with NUL instead of newline, and don't quote paths; implies --verbose=false"},
+		},
+	},
+	"ls-remote": {
+		Options: []helpOption{
+			{Names: []string{"--heads"}, Usage: "Only show branches"},
+			{Names: []string{"--refs"}, Usage: "Leave out HEAD and the objects annotated tags point to"},
+			{Names: []string{"--symref"}, Usage: "Also show the targets of symbolic refs"},
+			{Names: []string{"--tags"}, Usage: "Only show tags"},
+		},
+	},
+	"ls-tree": {
+		Options: []helpOption{
+			{Names: []string{"--format"}, Arg: "string", Usage: "Format of each line"},
+			{Names: []string{"-r"}, Usage: "Recurse into sub-trees"},
+			{Names: []string{"--tree"}, Arg: "string", Usage: "A tree-ish object"},
+		},
+	},
+	"mergetool": {
+		Options: []helpOption{
+			{Names: []string{"--prompt"}, Usage: "Ask before launching the tool for each path"},
+			{Names: []string{"-t", "--tool"}, Arg: "string", Usage: "The merge tool to use instead of merge.tool"},
+			{Names: []string{"-y", "--no-prompt"}, Usage: "Don't ask before launching the tool"},
+		},
+	},
+	"mktag": {
+		Synopsis: []string{"got mktag [--no-strict] < <tag>"},
+		Options: []helpOption{
+			{Names: []string{"--no-strict"}, Usage: "Only refuse tags with errors, instead of also refusing tags with fsck warnings"},
+		},
+	},
+	"mktree": {
+		Synopsis: []string{"got mktree [-z] [--missing] [--batch]"},
+		Options: []helpOption{
+			{Names: []string{"--batch"}, Usage: "Build a tree for every group of entries, separated by empty lines"},
+			{Names: []string{"--missing"}, Usage: "Allow entries that point to objects that don't exist"},
+			{Names: []string{"-z"}, Usage: "Read entries terminated by NUL instead of newline"},
+		},
+	},
+	"prune": {
+		Synopsis: []string{"got prune [-n] [-v] [--expire <time>]"},
+		Options: []helpOption{
+			{Names: []string{"--expire"}, Arg: "string", Usage: "Only remove unreachable objects older than this time, instead of gc.pruneExpire (2.weeks.ago by default)"},
+			{Names: []string{"-n", "--dry-run"}, Usage: "Only show what would be removed"},
+			{Names: []string{"-v", "--verbose"}, Usage: "Show the objects that are removed"},
+		},
+	},
+	"read-tree": {
+		Synopsis: []string{"got read-tree (--empty | <tree> | -m [-u] <tree> | -m [-u] <base> <ours> <theirs>)"},
+		Options: []helpOption{
+			{Names: []string{"--empty"}, Usage: "Empty the index instead of reading a tree"},
+			{Names: []string{"-m"}, Usage: "Merge the trees into the index: one tree keeps the cached stat data, three trees are a three-way merge of base, ours and theirs"},
+			{Names: []string{"-u"}, Usage: "Also update the files in the worktree"},
+		},
+	},
+	"reflog": {
+		Synopsis: []string{"got reflog expire [--expire=<time>] [--expire-unreachable=<time>] [-n] [-v] [--all | <ref>...]"},
+		Options: []helpOption{
+			{Names: []string{"--all"}, Usage: "Expire the logs of all refs"},
+			{Names: []string{"--expire"}, Arg: "string", Usage: "Remove entries older than this time, like 90.days.ago, instead of gc.reflogExpire"},
+			{Names: []string{"--expire-unreachable"}, Arg: "string", Usage: "Remove entries older than this time whose commit isn't reachable from the ref, instead of gc.reflogExpireUnreachable"},
+			{Names: []string{"-n", "--dry-run"}, Usage: "Only show what would be removed"},
+			{Names: []string{"-v", "--verbose"}, Usage: "Show the entries that are removed"},
+		},
+	},
+	"remote": {
+		Synopsis: []string{"got remote add [-f] [-t <branch>]... [-m <branch>] [--tags | --no-tags] <name> <url>", "got remote remove <name>", "got remote rename <old> <new>", "got remote set-url [--push] [--add] <name> <url>"},
+		Options: []helpOption{
+			{Names: []string{"--add"}, Usage: "Add the URL, instead of replacing the URL"},
+			{Names: []string{"-f", "--fetch"}, Usage: "Fetch from the remote once it is added"},
+			{Names: []string{"-m"}, Arg: "string", Usage: "The branch that refs/remotes/<name>/HEAD points to"},
+			{Names: []string{"--no-tags"}, Usage: "Don't fetch the tags of the remote, not even those that point to what is fetched"},
+			{Names: []string{"--push"}, Usage: "Set the push URL instead of the fetch URL"},
+			{Names: []string{"-t", "--track"}, Arg: "value", Usage: "Only track this branch, instead of all branches; can be given multiple times"},
+			{Names: []string{"--tags"}, Usage: "Fetch all the tags of the remote"},
+			{Names: []string{"-v", "--verbose"}, Usage: "Show the URLs of the remotes"},
+		},
+		Examples: []string{"got remote -v", "got remote add -f -t main upstream https://example.com/project.git", "got remote set-url --push origin host:project.git"},
+	},
+	"repack": {
+		Synopsis: []string{"got repack [-a] [-q] [--window <n>] [--depth <n>]"},
+		Options: []helpOption{
+			{Names: []string{"-a"}, Usage: "Pack the objects of the existing packs too, into one pack that replaces them"},
+			{Names: []string{"--depth"}, Arg: "int", Usage: "How long delta chains can get, instead of pack.depth (50 by default)"},
+			{Names: []string{"-q", "--quiet"}, Usage: "Don't report what was packed"},
+			{Names: []string{"--window"}, Arg: "int", Usage: "How many objects to try as the delta base of an object, instead of pack.window (10 by default)"},
+		},
+	},
+	"restore": {
+		Options: []helpOption{
+			{Names: []string{"--ours"}, Usage: "Restore unmerged paths from our version"},
+			{Names: []string{"-s", "--source"}, Arg: "string", Usage: "Restore the files from this tree-ish instead of the index"},
+			{Names: []string{"--theirs"}, Usage: "Restore unmerged paths from their version"},
+		},
+	},
+	"rev-list": {
+		Synopsis: []string{"got rev-list [--skip=<n>] [--max-count=<n>] [--show-cursor] (<commit>... | --resume=<cursor>)"},
+		Options: []helpOption{
+			{Names: []string{"-n", "--max-count"}, Arg: "int", Usage: "List at most this many commits"},
+			{Names: []string{"--resume"}, Arg: "string", Usage: "Continue a listing from the cursor it printed, instead of starting at commits"},
+			{Names: []string{"--show-cursor"}, Usage: "End with a line 'cursor <cursor>' when there are more commits, to continue with --resume"},
+			{Names: []string{"--skip"}, Arg: "int", Usage: "Skip this many commits before starting to list them"},
+		},
+	},
+	"rev-parse": {
+		Options: []helpOption{
+			{Names: []string{"--abbrev-ref"}, Usage: "Print the short name of refs, e.g. the current branch for HEAD"},
+			{Names: []string{"--git-dir"}, Usage: "Print the path of the git directory"},
+			{Names: []string{"--name"}, Arg: "string", Usage: "The name to parse"},
+			{Names: []string{"--short"}, Arg: "value", Usage: "Abbreviate object names, optionally to the given minimum length"},
+			{Names: []string{"--show-toplevel"}, Usage: "Print the absolute path of the top-level directory of the worktree"},
+			{Names: []string{"--type"}, Arg: "string", Usage: "Specify the expected type: one of blob, commit, tag, tree"},
+		},
+	},
+	"rm": {
+		Options: []helpOption{
+			{Names: []string{"--cached"}, Usage: "Only remove the files from the index, and keep them in the worktree"},
+			{Names: []string{"-f", "--force"}, Usage: "Remove files even if they have changes that would be lost"},
+			{Names: []string{"-q", "--quiet"}, Usage: "Don't print the removed files"},
+			{Names: []string{"-r"}, Usage: "Remove the files in directories that are given"},
+		},
+	},
+	"serve-api": {
+		Options: []helpOption{
+			{Names: []string{"--socket"}, Arg: "string", Usage: "Unix socket to listen on, instead of got-api.sock in the gitdir"},
+		},
+	},
+	"show-branch": {
+		Synopsis: []string{"got show-branch [--more=<n>] [--sparse] [--merge-base] [<rev>...]"},
+		Options: []helpOption{
+			{Names: []string{"--merge-base"}, Usage: "Only print the common ancestors of the branches"},
+			{Names: []string{"--more"}, Arg: "int", Usage: "Show this many more commits past the common ancestor"},
+			{Names: []string{"--sparse"}, Usage: "Also show the merges that only one branch reaches"},
+		},
+	},
+	"show-ref": {
+		Options: []helpOption{
+			{Names: []string{"-d"}, Usage: "Also show the object annotated tags point to, as <ref>^{}"},
+			{Names: []string{"--dereference"}, Usage: "Also show the object annotated tags point to, as <ref>^{}"},
+			{Names: []string{"--format"}, Arg: "string", Usage: "Format of each line, e.g. %(objectname:short) %(refname:short)"},
+		},
+	},
+	"sparse-checkout": {
+		Synopsis: []string{"got sparse-checkout (set|add|list|disable) [--no-sparse-index] [<directory>...]"},
+		Options: []helpOption{
+			{Names: []string{"--no-sparse-index"}, Usage: "Keep every file in the index, instead of a single entry per directory outside the cone"},
+		},
+	},
+	"stash": {
+		Synopsis: []string{"got stash branch <branchname> [<stash>]"},
+		Options: []helpOption{
+			{Names: []string{"--include-untracked"}, Usage: "Also stash untracked files, and remove them from the worktree"},
+			{Names: []string{"--index"}, Usage: "Also restore the changes that were added to the index"},
+			{Names: []string{"--keep-index"}, Usage: "Keep the changes that are already added to the index in place"},
+			{Names: []string{"-m"}, Arg: "string", Usage: "Description of the stash"},
+			{Names: []string{"-p", "--patch"}, Usage: "With show, show the changes as a patch instead of a diffstat"},
+			{Names: []string{"-u"}, Usage: "Shorthand for --include-untracked"},
+		},
+	},
+	"status": {
+		Options: []helpOption{
+			{Names: []string{"--porcelain"}, Usage: "Show a line per changed file, in a format for scripts"},
+			{Names: []string{"--watch"}, Usage: "Keep showing the status, and show it again when files change"},
+			{Names: []string{"-z"}, Usage: "End entries with NUL instead of newline, and don't quote paths; implies --porcelain"},
+		},
+	},
+	"stripspace": {
+		Synopsis: []string{"got stripspace [-s | --strip-comments | -c | --comment-lines] < input"},
+		Options: []helpOption{
+			{Names: []string{"-c", "--comment-lines"}, Usage: "Turn every line into a comment instead of cleaning up"},
+			{Names: []string{"-s", "--strip-comments"}, Usage: "Also remove lines starting with the comment character"},
+		},
+	},
+	"tag": {
+		Options: []helpOption{
+			{Names: []string{"--annotate"}, Usage: "Whether to create a tag object"},
+			{Names: []string{"-l", "--list"}, Usage: "List the tags, only the ones that match the given patterns if there are any"},
+			{Names: []string{"-m", "--message"}, Arg: "string", Usage: "The message of the tag object, which implies --annotate"},
+			{Names: []string{"-n"}, Arg: "value", Usage: "Show the first line of the annotation of each tag, or the first n lines with -n<n>"},
+			{Names: []string{"--name"}, Arg: "string", Usage: "The new tag's name"},
+			{Names: []string{"--object"}, Arg: "string", Usage: "The object the new tag will point to"},
+			{Names: []string{"--points-at"}, Arg: "value", Usage: "Only list the tags of this object. Can be given multiple times"},
+			{Names: []string{"--sort"}, Arg: "value", Usage: "Field to sort on, like creatordate or v:refname to sort by version. Prefix with - for descending order"},
+		},
+	},
+	"undo": {},
+	"unpack-objects": {
+		Synopsis: []string{"got unpack-objects [-n] [-q] [--strict] < <pack-file>"},
+		Options: []helpOption{
+			{Names: []string{"-n"}, Usage: "Check the pack, but don't write any objects"},
+			{Names: []string{"-q"}, Usage: "Don't report how many objects were unpacked"},
+			{Names: []string{"--strict"}, Usage: "Refuse the pack if an object in it fails the fsck checks, even if receive.fsckObjects is off"},
+		},
+	},
+	"var": {
+		Synopsis: []string{"got var (-l | <variable>)"},
+		Options: []helpOption{
+			{Names: []string{"-l"}, Usage: "List all variables, including the configuration"},
+		},
+	},
+	"verify-commit": {},
+	"verify-pack": {
+		Synopsis: []string{"got verify-pack [-v | -s] <pack>.idx..."},
+		Options: []helpOption{
+			{Names: []string{"-s", "--stat-only"}, Usage: "Only show the histogram of delta chain lengths"},
+			{Names: []string{"-v", "--verbose"}, Usage: "List the objects of the pack and a histogram of delta chain lengths"},
+		},
+	},
+	"verify-tag": {},
+	"web": {
+		Synopsis: []string{"got web [--listen=<address>]"},
+		Options: []helpOption{
+			{Names: []string{"--listen"}, Arg: "string", Usage: "Address to listen on"},
+		},
+	},
+	"worktree": {
+		Synopsis: []string{"got worktree (prune | repair) [<options>]", "got worktree prune [-n] [-v] [--expire <expire>]"},
+		Options: []helpOption{
+			{Names: []string{"--expire"}, Arg: "string", Usage: "With prune, only remove worktrees that are gone if they weren't used since this time, like 2.weeks.ago"},
+			{Names: []string{"-n", "--dry-run"}, Usage: "With prune, only show what would be removed"},
+			{Names: []string{"-v", "--verbose"}, Usage: "With prune, show what is removed"},
+		},
+	},
+	"write-tree": {},
+}
@@ -0,0 +1,200 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/gitdate"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/snapshot"
+)
+
+// snapshotSpec is a snapshot to import, with the metadata of its commit.
+// An empty date means the time of the newest file in the snapshot.
+type snapshotSpec struct {
+	path    string
+	date    string
+	message string
+}
+
+func ImportSnapshotsCommand() *Command {
+	command := newCommand("import-snapshots")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		branch := flag.String("branch", "import", "The branch that the commits are added to")
+		author := flag.String("author", "", "Author of the commits as 'Name <email>', instead of the configured one")
+		manifest := flag.String("manifest", "", "Read the snapshots from a file with lines of '<snapshot> TAB <date> TAB <message>'")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		specs := []snapshotSpec{}
+		for _, arg := range flag.Args() {
+			specs = append(specs, snapshotSpec{path: arg})
+		}
+		if *manifest != "" {
+			fromManifest, err := readSnapshotManifest(*manifest)
+			if err != nil {
+				return err
+			}
+			specs = append(specs, fromManifest...)
+		}
+		if len(specs) == 0 {
+			return errors.New("usage: got import-snapshots [--branch <name>] [--author <ident>] [--manifest <file>] [<tarball|directory>...]")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		// Moving the checked out branch would leave the index and worktree
+		// behind, so the imported history goes on a branch of its own
+		if active, onBranch, err := repo.GetActiveBranch(); err != nil {
+			return err
+		} else if onBranch && active == *branch {
+			return fmt.Errorf("refusing to import into the checked out branch '%s'", *branch)
+		}
+		ref := references.Reference("refs/heads/" + *branch)
+		if err := references.CheckName(ref.String(), references.CheckOptions{}); err != nil {
+			return err
+		}
+
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		authorIdent, committer, err := commitIdents(cfg)
+		if err != nil {
+			return err
+		}
+		if authorIdent, err = overrideAuthor(authorIdent, commitOptions{author: *author}); err != nil {
+			return err
+		}
+
+		// Importing again continues the history of the branch
+		tip, err := ref.Resolve(repo)
+		if err != nil {
+			return err
+		}
+		var parent *hashing.SHA
+		if tip != "" {
+			if parent, err = hashing.NewShaFromHex(tip); err != nil {
+				return err
+			}
+		}
+		for _, spec := range specs {
+			if parent, err = importSnapshot(repo, spec, parent, authorIdent, committer); err != nil {
+				return err
+			}
+			// Write the ref after each snapshot, so a failure keeps the
+			// snapshots imported so far
+			if err := references.Update(repo, ref, parent.AsString()); err != nil {
+				return err
+			}
+			fmt.Printf("%s %s\n", parent.AsString()[:7], spec.path)
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Create a linear history from tarballs or directories, one commit per snapshot"
+	}
+	return command
+}
+
+// readSnapshotManifest reads lines of a snapshot path, followed by an
+// optional date and message, separated by tabs. Relative paths are
+// relative to the manifest. Empty lines and lines starting with # are
+// skipped.
+func readSnapshotManifest(name string) ([]snapshotSpec, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	specs := []snapshotSpec{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		spec := snapshotSpec{path: fields[0]}
+		if !filepath.IsAbs(spec.path) {
+			spec.path = filepath.Join(filepath.Dir(name), spec.path)
+		}
+		if len(fields) > 1 {
+			spec.date = strings.TrimSpace(fields[1])
+		}
+		if len(fields) > 2 {
+			spec.message = fields[2]
+		}
+		specs = append(specs, spec)
+	}
+	return specs, scanner.Err()
+}
+
+// importSnapshot commits the files of a snapshot on top of parent, which
+// is nil for the first commit of the history
+func importSnapshot(repo *repository.Repository, spec snapshotSpec, parent *hashing.SHA, author, committer objects.Ident) (*hashing.SHA, error) {
+	s, err := snapshot.Read(spec.path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*index.Entry, 0, len(s.Files))
+	for _, f := range s.Files {
+		sha, err := objects.ObjectHash(f.Data, objects.TypeBlob, repo)
+		if err != nil {
+			return nil, err
+		}
+		entry := &index.Entry{
+			ModeType:  index.ModeTypeRegular,
+			ModePerms: 0o644,
+			Size:      uint32(len(f.Data)),
+			SHA:       sha,
+			Name:      f.Path,
+		}
+		if f.Symlink {
+			entry.ModeType, entry.ModePerms = index.ModeTypeSymlink, 0
+		} else if f.Executable {
+			entry.ModePerms = 0o755
+		}
+		entries = append(entries, entry)
+	}
+	tree, err := objects.TreeFromIndex(repo, index.New(entries))
+	if err != nil {
+		return nil, err
+	}
+
+	when := s.ModTime
+	if spec.date != "" {
+		if when, err = gitdate.Approxidate(spec.date, time.Now()); err != nil {
+			return nil, fmt.Errorf("invalid date format: %s", spec.date)
+		}
+	} else if when.IsZero() {
+		when = time.Now()
+	}
+	author.When = when
+	committer.When = when
+
+	message := spec.message
+	if message == "" {
+		message = "Import " + filepath.Base(spec.path)
+	}
+	parents := []*hashing.SHA{}
+	if parent != nil {
+		parents = append(parents, parent)
+	}
+	return objects.CreateCommit(repo, tree, parents, author, committer, message)
+}
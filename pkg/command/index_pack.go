@@ -0,0 +1,107 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/fsck"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func IndexPackCommand() *Command {
+	command := newCommand("index-pack")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		output := flag.String("o", "", "Write the index to this file instead of next to the pack")
+		stdin := flag.Bool("stdin", false, "Read the pack from standard input and store it in the repository, with its index")
+		strict := flag.Bool("strict", false, "With --stdin, refuse the pack if an object in it fails the fsck checks, even if receive.fsckObjects is off")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		usage := errors.New("usage: got index-pack [-o <index-file>] <pack-file> | got index-pack --stdin [--strict]")
+
+		if *stdin {
+			if flag.NArg() > 0 || *output != "" {
+				return usage
+			}
+			repo, err := repository.Find(".")
+			if err != nil {
+				return err
+			}
+			check, err := incomingCheck(repo, "receive", *strict)
+			if err != nil {
+				return err
+			}
+			name, err := objects.IndexPack(repo, os.Stdin, check)
+			if err != nil {
+				return err
+			}
+			fmt.Println(strings.TrimPrefix(name, "pack-"))
+			return nil
+		}
+
+		if flag.NArg() != 1 {
+			return usage
+		}
+		packFile := flag.Arg(0)
+		idxFile := *output
+		if idxFile == "" {
+			base, ok := strings.CutSuffix(packFile, ".pack")
+			if !ok {
+				return fmt.Errorf("packfile name '%s' does not end with '.pack'", packFile)
+			}
+			idxFile = base + ".idx"
+		}
+		data, err := os.ReadFile(packFile)
+		if err != nil {
+			return err
+		}
+		entries, checksum, err := pack.IndexStream(bytes.NewReader(data), nil)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(data, checksum) {
+			return errors.New("pack has junk at the end")
+		}
+		var idx bytes.Buffer
+		if err := pack.WriteIndex(&idx, entries, checksum); err != nil {
+			return err
+		}
+		if err := fs.AtomicWrite(idxFile, idx.Bytes(), fs.WithPerm(0o444)); err != nil {
+			return err
+		}
+		fmt.Println(hex.EncodeToString(checksum))
+		return nil
+	}
+	command.Description = func() string {
+		return "Build the index of a pack, like one received over the wire, checking every object on the way"
+	}
+	return command
+}
+
+// incomingCheck returns the fsck check of the objects that come in, in a
+// scope like "fetch" or "receive", or nil if they aren't checked. Like git,
+// they are checked if <scope>.fsckObjects or else transfer.fsckObjects is
+// set, or if force is.
+func incomingCheck(repo *repository.Repository, scope string, force bool) (objects.CheckFunc, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if !force && !fsck.Enabled(cfg, scope) {
+		return nil, nil
+	}
+	opts, err := fsck.LoadOptions(cfg, scope)
+	if err != nil {
+		return nil, err
+	}
+	return opts.CheckFunc(os.Stderr), nil
+}
@@ -1,18 +1,61 @@
 package command
 
 import (
+	"errors"
 	"flag"
+	"os"
+	"path"
+	"strings"
 
+	"github.com/jessegeens/got/pkg/config"
 	"github.com/jessegeens/got/pkg/repository"
 )
 
 func InitCommand() *Command {
 	command := newCommand("init")
 	command.Action = func(args []string) error {
-		path := *flag.String("Path", ".", "Path to create repository")
-		_, err := repository.Create(path)
+		command.ResetFlags()
+		template := flag.String("template", "", "Directory whose files are copied into the new gitdir")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 1 {
+			return errors.New("usage: got init [--template=<template-directory>] [<directory>]")
+		}
+		path := "."
+		if flag.NArg() == 1 {
+			path = flag.Arg(0)
+		}
+		templates, err := templateDir(*template)
+		if err != nil {
+			return err
+		}
+		_, err = repository.CreateWithOptions(path, repository.CreateOptions{TemplateDir: templates})
 		return err
 	}
 	command.Description = func() string { return "Create a new git repository" }
 	return command
 }
+
+// templateDir returns the template directory of a new repository: the
+// one given with --template, $GIT_TEMPLATE_DIR or init.templateDir, in
+// that order. Without one, the built-in templates are used.
+func templateDir(flagValue string) (string, error) {
+	dir := flagValue
+	if dir == "" {
+		dir = os.Getenv("GIT_TEMPLATE_DIR")
+	}
+	if dir == "" {
+		cfg, err := config.Read()
+		if err != nil {
+			return "", err
+		}
+		dir, _ = cfg.Get("init", "templateDir")
+	}
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = path.Join(home, dir[2:])
+		}
+	}
+	return dir, nil
+}
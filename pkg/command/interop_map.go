@@ -0,0 +1,67 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/jessegeens/got/pkg/interop"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func InteropMapCommand() *Command {
+	command := newCommand("interop-map")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		usage := errors.New("usage: got interop-map write | lookup <name>...")
+		if len(args) == 0 {
+			return usage
+		}
+		subcommand := args[0]
+		if err := flag.CommandLine.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		m, err := interop.ReadMap(repo)
+		if err != nil {
+			return err
+		}
+
+		switch subcommand {
+		case "write":
+			before := m.Len()
+			if err := interop.Build(repo, m); err != nil {
+				return err
+			}
+			if err := m.Write(repo); err != nil {
+				return err
+			}
+			fmt.Printf("Mapped %d objects (%d new)\n", m.Len(), m.Len()-before)
+			return nil
+		case "lookup":
+			if flag.NArg() == 0 {
+				return usage
+			}
+			for _, name := range flag.Args() {
+				other, ok := m.Compat(name)
+				if !ok {
+					other, ok = m.Storage(name)
+				}
+				if !ok {
+					return fmt.Errorf("%s is not in the interop map", name)
+				}
+				fmt.Println(other)
+			}
+			return nil
+		}
+		return usage
+	}
+	command.Description = func() string {
+		return "Build and query the map from SHA-1 to SHA-256 object names, for a migration to SHA-256"
+	}
+	return command
+}
@@ -1,57 +1,183 @@
 package command
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/gitdate"
 	"github.com/jessegeens/got/pkg/hashing"
 	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/revwalk"
+	"github.com/jessegeens/got/pkg/signature"
 )
 
 func LogCommand() *Command {
 	command := newCommand("log")
 	command.Action = func(args []string) error {
-		commit := *flag.String("commit", "HEAD", "Commit to start at") //args[0]
-		return handleLogCommand(commit)
+		command.ResetFlags()
+		commit := flag.String("commit", "HEAD", "Commit to start at") //args[0]
+		showSignature := flag.Bool("show-signature", false, "Check the validity of signed commits")
+		stat := flag.Bool("stat", false, "Show the number of changed lines per file of each commit")
+		raw := flag.Bool("raw", false, "Show the commits like git log, with the changed files of each commit in git's raw format, instead of a graph")
+		var since, until string
+		flag.StringVar(&since, "since", "", "Only show commits more recent than a date, like '2 weeks ago'")
+		flag.StringVar(&since, "after", "", "Same as --since")
+		flag.StringVar(&until, "until", "", "Only show commits older than a date")
+		flag.StringVar(&until, "before", "", "Same as --until")
+		skip := flag.Int("skip", 0, "Skip this many commits before starting to show them")
+		var maxCount int
+		flag.IntVar(&maxCount, "max-count", -1, "Show at most this many commits")
+		flag.IntVar(&maxCount, "n", -1, "Same as --max-count")
+		date := flag.String("date", "", "Show the author date of each commit, in a format like relative, iso, rfc or short")
+		renames := addRenameFlags(args)
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+
+		opts := logOptions{stat: *stat, raw: *raw, renames: renames, skip: *skip, maxCount: maxCount}
+		var err error
+		now := time.Now()
+		if since != "" {
+			if opts.since, err = gitdate.Approxidate(since, now); err != nil {
+				return err
+			}
+		}
+		if until != "" {
+			if opts.until, err = gitdate.Approxidate(until, now); err != nil {
+				return err
+			}
+		}
+		if *date != "" {
+			if opts.dateMode, err = gitdate.ParseMode(*date); err != nil {
+				return err
+			}
+		}
+		return handleLogCommand(*commit, *showSignature, opts)
 	}
 	command.Description = func() string { return "Display history of a given commit" }
 	return command
 }
 
-func handleLogCommand(commit string) error {
+// logOptions change what is shown for each commit
+type logOptions struct {
+	// If verifier is not nil, the signature status of each commit is shown
+	verifier *signature.Verifier
+	stat     bool
+	// raw shows the commits as text, with the changed files found with
+	// renames, instead of as a graph
+	raw     bool
+	renames *renameFlags
+	// changes is set from renames once the repository is known
+	changes diff.ChangeOptions
+	// Only commits with a committer date between since and until are
+	// shown, if they are set
+	since, until time.Time
+	// dateMode is how the author date is shown, if it is set
+	dateMode gitdate.Mode
+	// skip is the number of commits passed over before the first one
+	// that is shown, and maxCount the most that are shown, or -1 for all
+	skip, maxCount int
+}
+
+// shows returns whether a commit is shown, which depends on its
+// committer date
+func (opts logOptions) shows(commit *objects.Commit) bool {
+	if opts.since.IsZero() && opts.until.IsZero() {
+		return true
+	}
+	committer, _ := commit.GetValue("committer")
+	when := objects.ParseIdent(committer).When
+	return (opts.since.IsZero() || !when.Before(opts.since)) && (opts.until.IsZero() || !when.After(opts.until))
+}
+
+func handleLogCommand(commit string, showSignature bool, opts logOptions) error {
 	repo, err := repository.Find(".")
 	if err != nil {
 		return err
 	}
-	obj, err := objects.Find(repo, commit, objects.TypeNoTypeSpecified, true)
+
+	// Without any commits, there is no history to show
+	if commit == "HEAD" {
+		head, err := references.Reference("HEAD").Resolve(repo)
+		if err != nil {
+			return err
+		}
+		if head == "" && opts.raw {
+			return nil
+		}
+		if head == "" {
+			fmt.Println("digraph gitlog{")
+			fmt.Println("  node[shape=rect]")
+			fmt.Println("}")
+			return nil
+		}
+	}
+
+	obj, err := objects.Find(repo, commit, objects.TypeCommit, true)
 	if err != nil {
 		return err
 	}
 
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	if showSignature {
+		opts.verifier = signature.NewVerifier(cfg)
+	}
+	if opts.renames != nil {
+		opts.changes = opts.renames.options(cfg)
+	}
+
+	walker, err := revwalk.New(repo, obj)
+	if err != nil {
+		return err
+	}
+	if _, err := walker.Skip(opts.skip); err != nil {
+		return err
+	}
+
+	// The edges only go to commits that are shown too, so they are known
+	// before anything is printed
+	shown := []*revwalk.Commit{}
+	isShown := map[string]bool{}
+	for opts.maxCount < 0 || len(shown) < opts.maxCount {
+		c, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if opts.shows(c.Commit) {
+			shown = append(shown, c)
+			isShown[c.SHA.AsString()] = true
+		}
+	}
+
+	if opts.raw {
+		return logRaw(repo, shown, opts)
+	}
 	fmt.Println("digraph gitlog{")
 	fmt.Println("  node[shape=rect]")
-	logGraphviz(repo, obj.AsString(), make(map[string]bool))
+	for _, c := range shown {
+		if err := logGraphviz(repo, c, isShown, opts); err != nil {
+			return err
+		}
+	}
 	fmt.Println("}")
 	return nil
 }
 
-func logGraphviz(repo *repository.Repository, objSha string, seen map[string]bool) error {
-	// We already handled this commit
-	if _, in := seen[objSha]; in {
-		return nil
-	}
-
-	seen[objSha] = true
-
-	// Get commit data
-	sha, _ := hashing.NewShaFromHex(objSha)
-	gitobj, err := objects.ReadObject(repo, sha)
-	if err != nil {
-		return err
-	}
-	commit := gitobj.(*objects.Commit)
+func logGraphviz(repo *repository.Repository, c *revwalk.Commit, isShown map[string]bool, opts logOptions) error {
+	objSha := c.SHA.AsString()
+	commit := c.Commit
 	shortHash := objSha[0:7]
 	message := commit.Message()
 
@@ -60,24 +186,154 @@ func logGraphviz(repo *repository.Repository, objSha string, seen map[string]boo
 		message = strings.Split(message, "\n")[0]
 	}
 
-	// Print line
+	if opts.dateMode != "" {
+		author, _ := commit.GetValue("author")
+		message += "\\n" + gitdate.Format(objects.ParseIdent(author).When, opts.dateMode)
+	}
+	if opts.verifier != nil {
+		message += "\\n" + signatureSummary(commit, opts.verifier)
+	}
+	if opts.stat {
+		stat, err := commitStat(repo, commit)
+		if err != nil {
+			return err
+		}
+		message += stat
+	}
 	fmt.Printf("  c_%s [label=\"%s: %s\"]\n", objSha, shortHash, message)
+	for _, parent := range commit.GetValues("parent") {
+		if isShown[string(parent)] {
+			fmt.Printf("  c_%s -> c_%s;\n", objSha, parent)
+		}
+	}
+	return nil
+}
 
-	// Now, we go on to the recursion
-	parents, hasParent := commit.GetValue("parent")
-	// Base case: initial commit (i.e. no parents)
-	if !hasParent {
-		return nil
+func signatureSummary(commit *objects.Commit, verifier *signature.Verifier) string {
+	payload, sig, signed := commit.Signature()
+	if !signed {
+		return "No signature"
+	}
+	result, err := verifier.Verify(payload, sig)
+	if err != nil {
+		return "Can't check signature: " + err.Error()
+	}
+	if !result.Good {
+		return "BAD signature from " + result.Signer
+	}
+	return "Good signature from " + result.Signer
+}
+
+// commitStat returns the diffstat of a commit against its parent, as
+// left-aligned lines for its label. Like git log, merges don't have one.
+func commitStat(repo *repository.Repository, commit *objects.Commit) (string, error) {
+	from, to, err := commitSides(repo, commit)
+	if err != nil || from == nil {
+		return "", err
+	}
+	stats, err := diffStat(from, to)
+	if err != nil || len(stats) == 0 {
+		return "", err
 	}
 
-	// Recursive case
-	parentsList := strings.Split(string(parents), ",")
-	for _, parent := range parentsList {
-		fmt.Printf("  c_%s -> c_%s;\n", objSha, parent)
-		err = logGraphviz(repo, parent, seen)
+	label := "\\n"
+	for _, line := range diff.StatLines(stats, diff.DefaultStatWidth) {
+		label += labelEscaper.Replace(line) + "\\l"
+	}
+	return label, nil
+}
+
+// logRaw shows commits like git log --raw: the header and message of
+// each commit, followed by the files it changed in the raw format
+func logRaw(repo *repository.Repository, shown []*revwalk.Commit, opts logOptions) error {
+	dateMode := opts.dateMode
+	if dateMode == "" {
+		dateMode = gitdate.ModeDefault
+	}
+	for i, c := range shown {
+		commit := c.Commit
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("commit %s\n", c.SHA.AsString())
+		if parents := commit.GetValues("parent"); len(parents) > 1 {
+			short := []string{}
+			for _, parent := range parents {
+				sha, err := hashing.NewShaFromHex(string(parent))
+				if err != nil {
+					return err
+				}
+				short = append(short, objects.ShortSHA(repo, sha, objects.AbbrevLength(repo)))
+			}
+			fmt.Printf("Merge: %s\n", strings.Join(short, " "))
+		}
+		authorLine, _ := commit.GetValue("author")
+		author := objects.ParseIdent(authorLine)
+		fmt.Printf("Author: %s <%s>\n", author.Name, author.Email)
+		fmt.Printf("Date:   %s\n", gitdate.Format(author.When, dateMode))
+		if opts.verifier != nil {
+			fmt.Println(signatureSummary(commit, opts.verifier))
+		}
+		fmt.Println()
+		for _, line := range strings.Split(strings.TrimRight(commit.Message(), "\n"), "\n") {
+			fmt.Println("    " + line)
+		}
+
+		lines, err := commitRaw(repo, commit, opts.changes)
 		if err != nil {
 			return err
 		}
+		if len(lines) > 0 {
+			fmt.Println()
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
 	}
 	return nil
 }
+
+// commitRaw returns the changed files of a commit in the raw format.
+// Like git log, merges don't have any.
+func commitRaw(repo *repository.Repository, commit *objects.Commit, opts diff.ChangeOptions) ([]string, error) {
+	from, to, err := commitSides(repo, commit)
+	if err != nil || from == nil {
+		return nil, err
+	}
+	changes, err := rawChanges(from, to, opts)
+	if err != nil {
+		return nil, err
+	}
+	return rawLines(repo, changes, false), nil
+}
+
+// labelEscaper escapes text for a graphviz label
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// commitSides returns the tree of the parent of a commit and its own
+// tree, to show what it changed. The first commit is compared to the
+// empty tree, and like git log, merges return nil.
+func commitSides(repo *repository.Repository, commit *objects.Commit) (*diffSide, *diffSide, error) {
+	parents := commit.GetValues("parent")
+	if len(parents) > 1 {
+		return nil, nil, nil
+	}
+
+	parent := ""
+	if len(parents) == 1 {
+		parent = string(parents[0])
+	} else {
+		parent = objects.EmptyTree
+	}
+	tree, _ := commit.GetValue("tree")
+
+	from, err := treeSide(repo, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := treeSide(repo, string(tree))
+	if err != nil {
+		return nil, nil, err
+	}
+	return from, to, nil
+}
@@ -3,39 +3,143 @@ package command
 import (
 	"flag"
 	"fmt"
+	"os"
 	"os/user"
+	"path/filepath"
 	"strconv"
 
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/filter"
+	"github.com/jessegeens/got/pkg/format"
 	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
 )
 
 func LsFilesCommand() *Command {
 	command := newCommand("ls-files")
 	command.Action = func(args []string) error {
-		verbose := *flag.Bool("verbose", true, "Show everything")
-		flag.Parse()
+		command.ResetFlags()
+		verbose := flag.Bool("verbose", true, "Show everything")
+		formatString := flag.String("format", "", "Format of each line, e.g. %(objectname) %(path)")
+		nul := flag.Bool("z", false, "End lines with NUL instead of newline, and don't quote paths; implies --verbose=false")
+		sparseDirs := flag.Bool("sparse", false, "Show the directories of a sparse index as they are, instead of the files in them")
+		eol := flag.Bool("eol", false, "Show the line endings of files in the index and the worktree, and their text and eol attributes")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
 		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
-		idx, err := index.Read(repo)
+		read := index.Read
+		if *sparseDirs {
+			read = index.ReadSparse
+		}
+		idx, err := read(repo)
 		if err != nil {
 			return err
 		}
-		return lsFiles(idx, verbose)
+		records := format.NewRecords(os.Stdout, *nul)
+		if *formatString != "" {
+			tmpl, err := format.Parse(*formatString, lsFilesFields)
+			if err != nil {
+				return err
+			}
+			return lsFilesFormat(repo, idx, tmpl, records)
+		}
+		if *eol {
+			return lsFilesEOL(repo, idx, records)
+		}
+		// The details of the verbose output can't be NUL-terminated
+		return lsFiles(idx, *verbose && !*nul, records)
 	}
 	command.Description = func() string { return "List all the stage files" }
 	return command
 }
 
-func lsFiles(idx *index.Index, verbose bool) error {
+var lsFilesFields = []string{"objectmode", "objecttype", "objectname", "objectsize", "stage", "path"}
+
+func lsFilesFormat(repo *repository.Repository, idx *index.Index, tmpl *format.Template, records *format.Records) error {
+	for _, e := range idx.Entries {
+		fields := format.Func(func(name string) (string, bool) {
+			switch name {
+			case "objectmode":
+				return fmt.Sprintf("%02o%04o", e.ModeType, e.ModePerms), true
+			case "objecttype":
+				switch e.ModeType {
+				case index.ModeTypeGitlink:
+					return objects.TypeCommit.String(), true
+				case index.ModeTypeTree:
+					return objects.TypeTree.String(), true
+				}
+				return objects.TypeBlob.String(), true
+			case "objectname":
+				return e.SHA.AsString(), true
+			case "objectsize":
+				// The size in the index is the size of the file in the worktree
+				obj, err := objects.ReadObject(repo, e.SHA)
+				if err != nil {
+					return "", false
+				}
+				data, err := obj.Serialize()
+				if err != nil {
+					return "", false
+				}
+				return strconv.Itoa(len(data)), true
+			case "stage":
+				return strconv.Itoa(int(e.FlagStage)), true
+			case "path":
+				return records.Path(e.Name), true
+			}
+			return "", false
+		})
+		if err := records.Write(tmpl.Expand(fields)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lsFilesEOL shows how the line endings of each file look in the index
+// and in the worktree, and what its attributes say they should be, like
+// "i/lf    w/crlf  attr/text eol=lf      	path"
+func lsFilesEOL(repo *repository.Repository, idx *index.Index, records *format.Records) error {
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+	for _, e := range idx.Entries {
+		// Only regular files have line endings
+		var indexEOL, worktreeEOL string
+		if e.ModeType == index.ModeTypeRegular {
+			if data, err := blobContents(repo, e.SHA); err == nil {
+				indexEOL = filter.EOLStats(data)
+			}
+		}
+		fullPath := filepath.Join(repo.WorkTree(), filepath.FromSlash(e.Name))
+		if info, err := os.Lstat(fullPath); err == nil && info.Mode().IsRegular() {
+			if data, err := os.ReadFile(fullPath); err == nil {
+				worktreeEOL = filter.EOLStats(data)
+			}
+		}
+		line := fmt.Sprintf("i/%-5s w/%-5s attr/%-17s\t%s", indexEOL, worktreeEOL, filter.EOLAttr(attrs, e.Name), records.Path(e.Name))
+		if err := records.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lsFiles(idx *index.Index, verbose bool, records *format.Records) error {
 	if verbose {
 		fmt.Printf("Index file format v%d containing %d entries\n", idx.Version, len(idx.Entries))
 	}
 
 	for _, e := range idx.Entries {
-		fmt.Println(e.Name)
+		if err := records.Write(records.Path(e.Name)); err != nil {
+			return err
+		}
 		if verbose {
 			var username, group string
 			usr, err := user.LookupId(strconv.Itoa(int(e.UID)))
@@ -55,7 +159,7 @@ func lsFiles(idx *index.Index, verbose bool) error {
 			fmt.Printf("  created: %s, modified: %s\n", e.CTime.String(), e.MTime.String())
 			fmt.Printf("  device: %d, inode: %d\n", e.Dev, e.Inode)
 			fmt.Printf("  user: %s (%d)  group: %s (%d)\n", username, e.UID, group, e.GID)
-			fmt.Printf("  flags: stage=%d assume_valid=%t\n", e.FlagStage, e.FlagAssumeValid)
+			fmt.Printf("  flags: stage=%d assume_valid=%t skip_worktree=%t\n", e.FlagStage, e.FlagAssumeValid, e.SkipWorktree())
 		}
 	}
 	return nil
@@ -0,0 +1,94 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/remote"
+	"github.com/jessegeens/got/pkg/transport"
+	"github.com/jessegeens/got/pkg/wildmatch"
+)
+
+func LsRemoteCommand() *Command {
+	command := newCommand("ls-remote")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		heads := flag.Bool("heads", false, "Only show branches")
+		tags := flag.Bool("tags", false, "Only show tags")
+		refsOnly := flag.Bool("refs", false, "Leave out HEAD and the objects annotated tags point to")
+		symref := flag.Bool("symref", false, "Also show the targets of symbolic refs")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		name := "origin"
+		if flag.NArg() > 0 {
+			name = flag.Arg(0)
+		}
+		patterns := []string{}
+		for _, pattern := range flag.Args()[min(1, flag.NArg()):] {
+			patterns = append(patterns, "*/"+pattern)
+		}
+
+		url, cfg, err := remoteURL(name)
+		if err != nil {
+			return err
+		}
+		t, err := transport.Open(url, cfg)
+		if err != nil {
+			return err
+		}
+		defer t.Close()
+		adv, err := t.Advertise(transport.UploadPack)
+		if err != nil {
+			return err
+		}
+
+		symrefs := adv.Symrefs()
+		for _, ref := range adv.Refs {
+			name := ref.Name.String()
+			switch {
+			case (*heads || *tags) && !(*heads && strings.HasPrefix(name, "refs/heads/")) && !(*tags && strings.HasPrefix(name, "refs/tags/")):
+				continue
+			case *refsOnly && !strings.HasPrefix(name, "refs/"):
+				continue
+			}
+			if matchesTail(patterns, name) {
+				if target, ok := symrefs[name]; ok && *symref {
+					fmt.Printf("ref: %s\t%s\n", target, name)
+				}
+				fmt.Printf("%s\t%s\n", ref.SHA, name)
+			}
+			// The patterns match the peeled names on their own
+			if ref.Peeled != "" && !*refsOnly && matchesTail(patterns, name+"^{}") {
+				fmt.Printf("%s\t%s^{}\n", ref.Peeled, name)
+			}
+		}
+		return nil
+	}
+	command.Description = func() string { return "List the references of a remote repository" }
+	return command
+}
+
+// remoteURL returns the URL to fetch from for a remote name or URL, and
+// the configuration it was found in. It works outside a repository too,
+// with the global configuration.
+func remoteURL(name string) (string, config.GitConfig, error) {
+	cfg, err := currentConfig()
+	if err != nil {
+		return "", cfg, err
+	}
+	return remote.FetchURLs(cfg, name)[0], cfg, nil
+}
+
+// matchesTail reports whether a ref matches one of the patterns, which
+// match the ends of ref names, or whether there are no patterns
+func matchesTail(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if wildmatch.MatchText(pattern, "/"+name) {
+			return true
+		}
+	}
+	return len(patterns) == 0
+}
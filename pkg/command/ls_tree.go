@@ -4,30 +4,54 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"path/filepath"
+	"path"
+	"strconv"
 
+	"github.com/jessegeens/got/pkg/format"
 	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
 )
 
+var lsTreeFields = []string{"objectmode", "objecttype", "objectname", "objectsize", "path"}
+
+// lsTreeFormat is how ls-tree and cat-file -p list trees by default
+const lsTreeFormat = "%(objectmode) %(objecttype) %(objectname)%x09%(path)"
+
 func LsTreeCommand() *Command {
 	command := newCommand("ls-tree")
 	command.Action = func(args []string) error {
-		recursive := *flag.Bool("r", false, "Recurse into sub-trees")
-		tree := *flag.String("tree", "", "A tree-ish object")
-		flag.Parse()
+		command.ResetFlags()
+		recursive := flag.Bool("r", false, "Recurse into sub-trees")
+		tree := flag.String("tree", "", "A tree-ish object")
+		formatString := flag.String("format", lsTreeFormat, "Format of each line")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		// The tree can also be given as an argument, like in git
+		if *tree == "" && flag.NArg() > 0 {
+			*tree = flag.Arg(0)
+		}
+		if *tree == "" {
+			return errors.New("must specify a tree-ish")
+		}
+
+		tmpl, err := format.Parse(*formatString, lsTreeFields)
+		if err != nil {
+			return err
+		}
+
 		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
-		return lsTree(repo, tree, "", recursive)
+		return lsTree(repo, tmpl, *tree, "", *recursive)
 	}
-	command.Description = func() string { return "Compute object ID and optionally creates a blob from a file" }
+	command.Description = func() string { return "List the contents of a tree object" }
 	return command
 }
 
-func lsTree(repo *repository.Repository, ref, prefix string, recursive bool) error {
-	sha, err := objects.Find(repo, ref, objects.TypeNoTypeSpecified, true)
+func lsTree(repo *repository.Repository, tmpl *format.Template, ref, prefix string, recursive bool) error {
+	sha, err := objects.Find(repo, ref, objects.TypeTree, true)
 	if err != nil {
 		return err
 	}
@@ -52,7 +76,7 @@ func lsTree(repo *repository.Repository, ref, prefix string, recursive bool) err
 		}
 
 		switch string(rawobjtype) {
-		case "4":
+		case "4", "04":
 			objtype = objects.TypeTree
 		case "10":
 			objtype = objects.TypeBlob // A regular file
@@ -62,14 +86,43 @@ func lsTree(repo *repository.Repository, ref, prefix string, recursive bool) err
 			objtype = objects.TypeCommit // A submodule
 		}
 
-		if !(recursive && objtype == objects.TypeTree) {
-			fmt.Printf("")
-		} else {
-			err = lsTree(repo, item.PrintSHA(), filepath.Join(prefix, item.PrintPath()), recursive)
+		itemPath := path.Join(prefix, item.PrintPath())
+		if recursive && objtype == objects.TypeTree {
+			err = lsTree(repo, tmpl, item.PrintSHA(), itemPath, recursive)
 			if err != nil {
 				return err
 			}
+			continue
 		}
+
+		fields := format.Func(func(name string) (string, bool) {
+			switch name {
+			case "objectmode":
+				return fmt.Sprintf("%06s", item.Mode), true
+			case "objecttype":
+				return objtype.String(), true
+			case "objectname":
+				return item.PrintSHA(), true
+			case "objectsize":
+				// Like git, only blobs have a size
+				if objtype != objects.TypeBlob {
+					return "-", true
+				}
+				obj, err := objects.ReadObject(repo, item.Sha)
+				if err != nil {
+					return "", false
+				}
+				data, err := obj.Serialize()
+				if err != nil {
+					return "", false
+				}
+				return strconv.Itoa(len(data)), true
+			case "path":
+				return itemPath, true
+			}
+			return "", false
+		})
+		fmt.Println(tmpl.Expand(fields))
 	}
 	return nil
 }
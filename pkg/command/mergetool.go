@@ -0,0 +1,198 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/filter"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func MergetoolCommand() *Command {
+	command := newCommand("mergetool")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var tool string
+		flag.StringVar(&tool, "tool", "", "The merge tool to use instead of merge.tool")
+		flag.StringVar(&tool, "t", "", "Same as --tool")
+		noPrompt := flag.Bool("no-prompt", false, "Don't ask before launching the tool")
+		yes := flag.Bool("y", false, "Same as --no-prompt")
+		prompt := flag.Bool("prompt", false, "Ask before launching the tool for each path")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		if tool == "" {
+			tool, _ = cfg.Get("merge", "tool")
+		}
+		if tool == "" {
+			return errors.New("no merge tool is configured, set merge.tool or use --tool")
+		}
+		toolCmd, _ := cfg.Get(fmt.Sprintf("mergetool %q", tool), "cmd")
+		if toolCmd == "" {
+			return fmt.Errorf("merge tool %s has no command, set mergetool.%s.cmd", tool, tool)
+		}
+
+		ask, _ := cfg.GetBool("mergetool", "prompt")
+		ask = (ask || *prompt) && !*noPrompt && !*yes
+
+		paths, err := mergetoolPaths(repo, flag.Args())
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			fmt.Println("No files need merging")
+			return nil
+		}
+		fmt.Printf("Merging:\n%s\n", strings.Join(paths, "\n"))
+
+		attrs, err := attributes.Read(repo)
+		if err != nil {
+			return err
+		}
+		failed := false
+		for _, name := range paths {
+			resolved, err := runMergetool(repo, cfg, attrs, tool, toolCmd, name, ask)
+			if err != nil {
+				return err
+			}
+			if !resolved {
+				failed = true
+			}
+		}
+		if failed {
+			return errors.New("not all conflicts were resolved")
+		}
+		return nil
+	}
+	command.Description = func() string { return "Run a merge tool to resolve conflicts" }
+	return command
+}
+
+// mergetoolPaths returns the unmerged paths, limited to the given paths
+// and the files in the given directories
+func mergetoolPaths(repo *repository.Repository, args []string) ([]string, error) {
+	idx, err := index.Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	unmerged := idx.Unmerged()
+	if len(args) == 0 {
+		return unmerged, nil
+	}
+
+	paths := []string{}
+	for _, arg := range args {
+		rel, err := worktreePath(repo, arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range unmerged {
+			if (rel == "." || name == rel || strings.HasPrefix(name, rel+"/")) && !slices.Contains(paths, name) {
+				paths = append(paths, name)
+			}
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// runMergetool launches the tool for one unmerged path, with the versions
+// in its stages in temporary files next to it. When the tool exits
+// successfully, the result is added to the index. It reports whether the
+// path was resolved.
+func runMergetool(repo *repository.Repository, cfg config.GitConfig, attrs *attributes.Attributes, tool, toolCmd, name string, ask bool) (bool, error) {
+	idx, err := index.Read(repo)
+	if err != nil {
+		return false, err
+	}
+	stages := indexStages(idx, name)
+
+	fmt.Printf("\nNormal merge conflict for '%s':\n", name)
+	if stages[2] == nil || stages[3] == nil {
+		// git asks whether to keep the modified or the deleted version,
+		// that choice is made with got add or got rm
+		fmt.Printf("Skipping '%s': it was deleted on one side, resolve it with got add or got rm\n", name)
+		return false, nil
+	}
+	fmt.Printf("  {local}: modified file\n  {remote}: modified file\n")
+	if ask {
+		fmt.Printf("Hit return to start merge resolution tool (%s): ", tool)
+		if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+			return false, nil
+		}
+	}
+
+	// Like git, the temporary files keep the extension of the file, so
+	// tools can recognize its type: foo_BASE_1234.c
+	ext := filepath.Ext(name)
+	temp := func(kind string) string {
+		return fmt.Sprintf("%s_%s_%d%s", strings.TrimSuffix(name, ext), kind, os.Getpid(), ext)
+	}
+	env := []string{"MERGED=" + name}
+	for i, kind := range []string{"BASE", "LOCAL", "REMOTE", "BACKUP"} {
+		var contents []byte
+		if kind == "BACKUP" {
+			contents, err = os.ReadFile(filepath.Join(repo.WorkTree(), name))
+		} else {
+			contents, err = blobContents(repo, stages[i+1])
+			if err == nil {
+				contents, err = filter.Smudge(filter.ForPath(attrs, name), contents)
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+		file := temp(kind)
+		if err := os.WriteFile(filepath.Join(repo.WorkTree(), file), contents, 0o644); err != nil {
+			return false, err
+		}
+		defer os.Remove(filepath.Join(repo.WorkTree(), file))
+		env = append(env, kind+"="+file)
+	}
+
+	cmd := exec.Command("sh", "-c", toolCmd)
+	cmd.Dir = repo.WorkTree()
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return false, err
+		}
+		fmt.Printf("merge of %s failed\n", name)
+		return false, nil
+	}
+
+	// The conflicted version is kept as name.orig, unless
+	// mergetool.keepBackup is false
+	if keep, ok := cfg.GetBool("mergetool", "keepBackup"); !ok || keep {
+		if err := os.Rename(filepath.Join(repo.WorkTree(), temp("BACKUP")), filepath.Join(repo.WorkTree(), name+".orig")); err != nil {
+			return false, err
+		}
+	}
+	err = index.Update(repo, func(idx *index.Index) error {
+		return addToIndex(repo, idx, filepath.Join(repo.WorkTree(), name))
+	})
+	return err == nil, err
+}
@@ -0,0 +1,135 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fsck"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// tagHeaders are the headers every tag starts with, in this order. Only
+// the tagger can be missing, which fsck warns about.
+var tagHeaders = []string{"object", "type", "tag", "tagger"}
+
+func MktagCommand() *Command {
+	command := newCommand("mktag")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		noStrict := flag.Bool("no-strict", false, "Only refuse tags with errors, instead of also refusing tags with fsck warnings")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 {
+			return errors.New("usage: got mktag [--no-strict] < <tag>")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		sha, err := mktag(repo, data, !*noStrict)
+		if err != nil {
+			return err
+		}
+		fmt.Println(sha.AsString())
+		return nil
+	}
+	command.Description = func() string { return "Create a tag object from its contents on stdin, after checking it" }
+	return command
+}
+
+// mktag writes a tag object after checking it like git mktag: the tag has
+// to pass fsck, be written the way git writes tags, and the object it tags
+// has to exist with the type the tag says. With strict, fsck warnings are
+// errors too.
+func mktag(repo *repository.Repository, data []byte, strict bool) (*hashing.SHA, error) {
+	tag := &objects.Tag{}
+	if err := tag.Deserialize(data); err != nil {
+		return nil, fmt.Errorf("tag on stdin did not pass our strict fsck check: %w", err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := fsck.LoadOptions(cfg, "")
+	if err != nil {
+		return nil, err
+	}
+	findings, err := opts.Check(nil, tag)
+	if err != nil {
+		return nil, fmt.Errorf("tag on stdin did not pass our strict fsck check: %w", err)
+	}
+	for _, f := range findings {
+		if strict {
+			return nil, fmt.Errorf("tag on stdin did not pass our strict fsck check: %s", f)
+		}
+		fmt.Fprintln(os.Stderr, f)
+	}
+
+	if err := checkTagHeaders(data); err != nil {
+		return nil, err
+	}
+	serialized, err := tag.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(serialized, data) {
+		return nil, errors.New("tag on stdin is not in the format git writes tags")
+	}
+
+	object, _ := tag.GetValue("object")
+	sha, err := hashing.NewShaFromHex(string(object))
+	if err != nil {
+		return nil, err
+	}
+	typ, _, err := objects.ReadHeader(repo, sha)
+	if err != nil {
+		return nil, fmt.Errorf("could not read tagged object '%s': %w", object, err)
+	}
+	want, _ := tag.GetValue("type")
+	if string(typ) != string(want) {
+		return nil, fmt.Errorf("object '%s' tagged as '%s', but is a '%s' type", object, want, typ)
+	}
+	return objects.WriteObject(tag, repo)
+}
+
+// checkTagHeaders makes sure the tag has the headers git writes, in the
+// same order, and nothing else before the message
+func checkTagHeaders(data []byte) error {
+	header, _, _ := strings.Cut(string(data), "\n\n")
+	keys := []string{}
+	for _, line := range strings.Split(strings.TrimSuffix(header, "\n"), "\n") {
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+		key, _, _ := strings.Cut(line, " ")
+		keys = append(keys, key)
+	}
+	expected := tagHeaders
+	if !slices.Contains(keys, "tagger") {
+		expected = tagHeaders[:len(tagHeaders)-1]
+	}
+	for i, want := range expected {
+		if i >= len(keys) || keys[i] != want {
+			return fmt.Errorf("tag on stdin has no '%s' header where git expects it", want)
+		}
+	}
+	if len(keys) > len(expected) {
+		return fmt.Errorf("tag on stdin has extra headers after '%s'", expected[len(expected)-1])
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func MktreeCommand() *Command {
+	command := newCommand("mktree")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		nulTerminated := flag.Bool("z", false, "Read entries terminated by NUL instead of newline")
+		missing := flag.Bool("missing", false, "Allow entries that point to objects that don't exist")
+		batch := flag.Bool("batch", false, "Build a tree for every group of entries, separated by empty lines")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 {
+			return errors.New("usage: got mktree [-z] [--missing] [--batch]")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		terminator := byte('\n')
+		if *nulTerminated {
+			terminator = 0
+		}
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, terminator); i >= 0 {
+				return i + 1, data[:i], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
+
+		entries := []*objects.TreeLeaf{}
+		written := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				// Without --batch, an empty line ends the input
+				if !*batch {
+					break
+				}
+				if err := mktree(repo, entries, *missing); err != nil {
+					return err
+				}
+				entries, written = []*objects.TreeLeaf{}, true
+				continue
+			}
+			leaf, err := objects.ParseTreeEntry(line)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, leaf)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		// Like git, an empty batch at the end doesn't make an empty tree
+		if len(entries) > 0 || !written {
+			return mktree(repo, entries, *missing)
+		}
+		return nil
+	}
+	command.Description = func() string { return "Build a tree object from ls-tree formatted text on stdin" }
+	return command
+}
+
+// mktree writes a tree with entries and prints its SHA. Unless missing is
+// set, the objects of the entries have to exist with the type their mode
+// says. Submodule commits are never checked, since they live in another
+// repository.
+func mktree(repo *repository.Repository, entries []*objects.TreeLeaf, missing bool) error {
+	for _, leaf := range entries {
+		want := objects.LeafType(leaf)
+		if missing || want == objects.TypeCommit {
+			continue
+		}
+		if err := checkEntryObject(repo, leaf, want); err != nil {
+			return err
+		}
+	}
+
+	tree, err := objects.NewTree(entries)
+	if err != nil {
+		return err
+	}
+	sha, err := objects.WriteObject(tree, repo)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sha.AsString())
+	return nil
+}
+
+func checkEntryObject(repo *repository.Repository, leaf *objects.TreeLeaf, want objects.GitObjectType) error {
+	typ, _, err := objects.ReadHeader(repo, leaf.Sha)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("entry '%s' object %s is unavailable", leaf.Path, leaf.Sha.AsString())
+	} else if err != nil {
+		return err
+	}
+	if typ != want {
+		return fmt.Errorf("entry '%s' object %s is a %s but specified type was (%s)", leaf.Path, leaf.Sha.AsString(), typ, want)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jessegeens/got/pkg/gitdate"
+	"github.com/jessegeens/got/pkg/prune"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// defaultPruneExpire is how old unreachable objects have to be before
+// they are removed, when gc.pruneExpire isn't set
+const defaultPruneExpire = "2.weeks.ago"
+
+func PruneCommand() *Command {
+	command := newCommand("prune")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var dryRun, verbose bool
+		flag.BoolVar(&dryRun, "dry-run", false, "Only show what would be removed")
+		flag.BoolVar(&dryRun, "n", false, "Same as --dry-run")
+		flag.BoolVar(&verbose, "verbose", false, "Show the objects that are removed")
+		flag.BoolVar(&verbose, "v", false, "Same as --verbose")
+		expire := flag.String("expire", "", "Only remove unreachable objects older than this time, instead of gc.pruneExpire (2.weeks.ago by default)")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 {
+			return errors.New("usage: got prune [-n] [-v] [--expire <time>]")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		opts := prune.Options{DryRun: dryRun}
+		if opts.Expire, err = pruneExpiry(repo, *expire); err != nil {
+			return err
+		}
+		pruned, err := prune.Prune(repo, opts)
+		if err != nil {
+			return err
+		}
+		if verbose || dryRun {
+			for _, obj := range pruned {
+				fmt.Println(obj.SHA)
+			}
+		}
+		return nil
+	}
+	command.Description = func() string { return "Remove unreachable loose objects that are older than the expiry time" }
+	return command
+}
+
+// pruneExpiry returns when unreachable objects expire: at value if it is
+// set, or else at gc.pruneExpire
+func pruneExpiry(repo *repository.Repository, value string) (time.Time, error) {
+	if value == "" {
+		cfg, err := repo.Config()
+		if err != nil {
+			return time.Time{}, err
+		}
+		value = defaultPruneExpire
+		if configured, ok := cfg.Get("gc", "pruneExpire"); ok {
+			value = configured
+		}
+	}
+	return gitdate.Expiry(value, time.Now())
+}
@@ -0,0 +1,190 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func ReadTreeCommand() *Command {
+	command := newCommand("read-tree")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		merge := flag.Bool("m", false, "Merge the trees into the index: one tree keeps the cached stat data, three trees are a three-way merge of base, ours and theirs")
+		update := flag.Bool("u", false, "Also update the files in the worktree")
+		empty := flag.Bool("empty", false, "Empty the index instead of reading a tree")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		trees := flag.Args()
+		switch {
+		case *empty && len(trees) > 0:
+			return errors.New("--empty can't be used with a tree")
+		case *update && !*merge:
+			return errors.New("-u is only allowed with -m")
+		case !*empty && !*merge && len(trees) != 1:
+			return errors.New("usage: got read-tree (--empty | <tree> | -m [-u] <tree> | -m [-u] <base> <ours> <theirs>)")
+		case *merge && len(trees) != 1 && len(trees) != 3:
+			return errors.New("-m needs one tree, or three for a three-way merge")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		sides := []map[string]*hashing.SHA{}
+		for _, tree := range trees {
+			sha, err := objects.Find(repo, tree, objects.TypeTree, true)
+			if err != nil {
+				return err
+			}
+			paths, err := objects.MapFromTree(repo, sha.AsString())
+			if err != nil {
+				return err
+			}
+			sides = append(sides, paths)
+		}
+		return readTree(repo, sides, *merge, *update)
+	}
+	command.Description = func() string { return "Read trees into the index, merging them with -m" }
+	return command
+}
+
+// readTree replaces the index with the files in sides, which are one tree
+// or the base, ours and theirs of a three-way merge. Entries that don't
+// change keep their stat data with merge. With update, the worktree is
+// changed to match.
+func readTree(repo *repository.Repository, sides []map[string]*hashing.SHA, merge, update bool) error {
+	var attrs *attributes.Attributes
+	if update {
+		var err error
+		if attrs, err = attributes.Read(repo); err != nil {
+			return err
+		}
+	}
+
+	return index.Update(repo, func(idx *index.Index) error {
+		if merge {
+			if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+				return fmt.Errorf("you need to resolve your current index first:\n\t%s", strings.Join(unmerged, "\n\t"))
+			}
+		}
+		current := pathsFromIndex(idx)
+
+		var merged map[string]*hashing.SHA
+		var conflicts []*index.Entry
+		switch len(sides) {
+		case 0:
+			merged = map[string]*hashing.SHA{}
+		case 1:
+			merged = sides[0]
+		default:
+			merged, conflicts = trivialMerge(sides[0], sides[1], sides[2])
+		}
+
+		if update {
+			if err := checkUpToDate(repo, attrs, current, merged, conflicts); err != nil {
+				return err
+			}
+			// Conflicted files are left as they are
+			target := maps.Clone(merged)
+			for _, e := range conflicts {
+				target[e.Name] = current[e.Name]
+				if current[e.Name] == nil {
+					delete(target, e.Name)
+				}
+			}
+			if err := checkoutPaths(repo, attrs, current, target); err != nil {
+				return err
+			}
+		}
+
+		entries := []*index.Entry{}
+		for _, name := range slices.Sorted(maps.Keys(merged)) {
+			sha := merged[name]
+			// Entries that didn't change keep their stat data, so that the
+			// files don't have to be hashed again
+			if old, ok := idx.Get(name); ok && merge && sameBlob(old.SHA, sha) {
+				entries = append(entries, old)
+				continue
+			}
+			entry := &index.Entry{ModeType: index.ModeTypeRegular, ModePerms: 0o644, SHA: sha, Name: name}
+			if update {
+				var err error
+				if entry, err = indexEntryFromFile(repo, name, sha); err != nil {
+					return err
+				}
+			}
+			entries = append(entries, entry)
+		}
+		*idx = *index.New(append(entries, conflicts...))
+		return nil
+	})
+}
+
+// trivialMerge merges three trees like git read-tree -m: a file that is
+// the same on both sides, or only changed on one side, is merged. Other
+// files are conflicts, with the base, our and their version in stage 1,
+// 2 and 3.
+func trivialMerge(base, ours, theirs map[string]*hashing.SHA) (map[string]*hashing.SHA, []*index.Entry) {
+	names := map[string]bool{}
+	for _, side := range []map[string]*hashing.SHA{base, ours, theirs} {
+		for name := range side {
+			names[name] = true
+		}
+	}
+
+	merged := map[string]*hashing.SHA{}
+	conflicts := []*index.Entry{}
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		o, a, b := base[name], ours[name], theirs[name]
+		var result *hashing.SHA
+		switch {
+		case sameBlob(a, b):
+			result = a
+		case sameBlob(o, a):
+			result = b
+		case sameBlob(o, b):
+			result = a
+		default:
+			conflicts = append(conflicts, conflictEntries(name, o, a, b)...)
+			continue
+		}
+		// A file that was deleted stays deleted
+		if result != nil {
+			merged[name] = result
+		}
+	}
+	return merged, conflicts
+}
+
+// checkUpToDate makes sure the files that the update changes have no
+// changes in the worktree that would be lost
+func checkUpToDate(repo *repository.Repository, attrs *attributes.Attributes, current, merged map[string]*hashing.SHA, conflicts []*index.Entry) error {
+	conflicted := map[string]bool{}
+	for _, e := range conflicts {
+		conflicted[e.Name] = true
+	}
+	for _, name := range changedPaths(current, merged) {
+		if conflicted[name] {
+			continue
+		}
+		sha, err := worktreeBlob(repo, attrs, name)
+		if err != nil {
+			return err
+		}
+		if !sameBlob(sha, current[name]) {
+			return fmt.Errorf("entry '%s' not uptodate, cannot merge", name)
+		}
+	}
+	return nil
+}
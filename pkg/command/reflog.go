@@ -0,0 +1,113 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jessegeens/got/pkg/gitdate"
+	"github.com/jessegeens/got/pkg/reflog"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func ReflogCommand() *Command {
+	command := newCommand("reflog")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		if len(args) == 0 || args[0] != "expire" {
+			return errors.New("usage: got reflog expire [--expire=<time>] [--expire-unreachable=<time>] [-n] [-v] [--all | <ref>...]")
+		}
+
+		var dryRun, verbose bool
+		expire := flag.String("expire", "", "Remove entries older than this time, like 90.days.ago, instead of gc.reflogExpire")
+		expireUnreachable := flag.String("expire-unreachable", "", "Remove entries older than this time whose commit isn't reachable from the ref, instead of gc.reflogExpireUnreachable")
+		all := flag.Bool("all", false, "Expire the logs of all refs")
+		flag.BoolVar(&dryRun, "dry-run", false, "Only show what would be removed")
+		flag.BoolVar(&dryRun, "n", false, "Same as --dry-run")
+		flag.BoolVar(&verbose, "verbose", false, "Show the entries that are removed")
+		flag.BoolVar(&verbose, "v", false, "Same as --verbose")
+		if err := flag.CommandLine.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		refs := flag.Args()
+		if *all {
+			if refs, err = reflog.Refs(repo); err != nil {
+				return err
+			}
+		}
+		if len(refs) == 0 {
+			return errors.New("no reflog specified, use --all to expire the logs of all refs")
+		}
+
+		overrides := reflogExpiry{expire: *expire, expireUnreachable: *expireUnreachable}
+		for _, ref := range refs {
+			if err := reflogExpire(repo, resolveReflogName(repo, ref), overrides, dryRun, verbose || dryRun); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	command.Description = func() string { return "Remove old entries from ref logs" }
+	return command
+}
+
+// reflogExpiry are expiry times given on the command line, which win over
+// the configuration
+type reflogExpiry struct {
+	expire            string
+	expireUnreachable string
+}
+
+// reflogExpire removes the expired entries of the log of ref. The expiry
+// times come from the configuration, unless they are given in overrides.
+func reflogExpire(repo *repository.Repository, ref string, overrides reflogExpiry, dryRun, verbose bool) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	opts, err := reflog.LoadExpireOptions(cfg, ref, now)
+	if err != nil {
+		return err
+	}
+	if overrides.expire != "" {
+		if opts.Expire, err = gitdate.Expiry(overrides.expire, now); err != nil {
+			return err
+		}
+	}
+	if overrides.expireUnreachable != "" {
+		if opts.ExpireUnreachable, err = gitdate.Expiry(overrides.expireUnreachable, now); err != nil {
+			return err
+		}
+	}
+	opts.DryRun = dryRun
+
+	expired, err := reflog.Expire(repo, ref, opts)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		for _, e := range expired {
+			fmt.Fprintf(os.Stderr, "prune %s: %s %s\n", ref, e.New, e.Message)
+		}
+	}
+	return nil
+}
+
+// resolveReflogName turns a short name like main into the ref that has a
+// log, like refs/heads/main
+func resolveReflogName(repo *repository.Repository, name string) string {
+	for _, candidate := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+		if _, err := os.Stat(reflog.Path(repo, candidate)); err == nil {
+			return candidate
+		}
+	}
+	return name
+}
@@ -0,0 +1,439 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/remote"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// RemoteCommand manages the remotes of the repository.
+//
+// Examples:
+//
+//	got remote -v
+//	got remote add -f -t main upstream https://example.com/project.git
+//	got remote set-url --push origin host:project.git
+func RemoteCommand() *Command {
+	command := newCommand("remote")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			var verbose bool
+			flag.BoolVar(&verbose, "verbose", false, "Show the URLs of the remotes")
+			flag.BoolVar(&verbose, "v", false, "Same as --verbose")
+			if err := flag.CommandLine.Parse(args); err != nil {
+				return err
+			}
+			return remoteList(repo, verbose)
+		}
+
+		subcommand, args := args[0], args[1:]
+		switch subcommand {
+		case "add":
+			return remoteAdd(repo, args)
+		case "remove", "rm":
+			if len(args) != 1 {
+				return errors.New("usage: got remote remove <name>")
+			}
+			return remoteRemove(repo, args[0])
+		case "rename":
+			if len(args) != 2 {
+				return errors.New("usage: got remote rename <old> <new>")
+			}
+			return remoteRename(repo, args[0], args[1])
+		case "set-url":
+			return remoteSetURL(repo, args)
+		case "show":
+			return remoteShow(repo, args)
+		}
+		return fmt.Errorf("unknown remote subcommand: %s", subcommand)
+	}
+	command.Description = func() string {
+		return "Manage the remotes whose branches are fetched: add, remove, rename, set-url and show"
+	}
+	return command
+}
+
+// remoteList prints the names of the remotes, with verbose their fetch
+// and push URLs
+func remoteList(repo *repository.Repository, verbose bool) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	for _, name := range remote.Names(cfg) {
+		if !verbose {
+			fmt.Println(name)
+			continue
+		}
+		for _, url := range remote.FetchURLs(cfg, name) {
+			fmt.Printf("%s\t%s (fetch)\n", name, url)
+		}
+		for _, url := range remote.PushURLs(cfg, name) {
+			fmt.Printf("%s\t%s (push)\n", name, url)
+		}
+	}
+	return nil
+}
+
+func remoteAdd(repo *repository.Repository, args []string) error {
+	var fetch bool
+	var tracked []string
+	flag.BoolVar(&fetch, "fetch", false, "Fetch from the remote once it is added")
+	flag.BoolVar(&fetch, "f", false, "Same as --fetch")
+	track := func(branch string) error {
+		tracked = append(tracked, branch)
+		return nil
+	}
+	flag.Func("track", "Only track this branch, instead of all branches; can be given multiple times", track)
+	flag.Func("t", "Same as --track", track)
+	master := flag.String("m", "", "The branch that refs/remotes/<name>/HEAD points to")
+	tags := flag.Bool("tags", false, "Fetch all the tags of the remote")
+	noTags := flag.Bool("no-tags", false, "Don't fetch the tags of the remote, not even those that point to what is fetched")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	if flag.NArg() != 2 {
+		return errors.New("usage: got remote add [-f] [-t <branch>]... [-m <branch>] [--tags | --no-tags] <name> <url>")
+	}
+	name, url := flag.Arg(0), flag.Arg(1)
+	if err := checkRemoteName(repo, name); err != nil {
+		return err
+	}
+
+	entries := []config.Entry{{Key: "url", Value: url}}
+	if len(tracked) == 0 {
+		tracked = []string{"*"}
+	}
+	for _, branch := range tracked {
+		entries = append(entries, config.Entry{Key: "fetch", Value: "+refs/heads/" + branch + ":" + references.RemoteBranch(name, branch).String()})
+	}
+	if *tags {
+		entries = append(entries, config.Entry{Key: "tagOpt", Value: "--tags"})
+	} else if *noTags {
+		entries = append(entries, config.Entry{Key: "tagOpt", Value: "--no-tags"})
+	}
+	if err := config.AppendSection(repo.RepositoryPath("config"), `remote "`+name+`"`, entries...); err != nil {
+		return err
+	}
+
+	if *master != "" {
+		if _, err := repo.RepositoryDir(true, "refs", "remotes", name); err != nil {
+			return err
+		}
+		if err := references.UpdateSymbolic(repo, references.RemoteHead(name), references.RemoteBranch(name, *master)); err != nil {
+			return err
+		}
+	}
+	if fetch {
+		return fetchRemote(repo, name, nil, fetchOptions{followTags: true}, false)
+	}
+	return nil
+}
+
+// checkRemoteName checks that a new remote has a name that can be part of
+// its remote-tracking branches, and that isn't taken
+func checkRemoteName(repo *repository.Repository, name string) error {
+	if err := references.CheckName(references.RemoteBranch(name, "test").String(), references.CheckOptions{}); err != nil {
+		return fmt.Errorf("'%s' is not a valid remote name", name)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(remote.Names(cfg), name) {
+		return fmt.Errorf("remote %s already exists", name)
+	}
+	return nil
+}
+
+// existingRemote returns the configuration of a remote that must exist
+func existingRemote(repo *repository.Repository, name string) (config.GitConfig, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return cfg, err
+	}
+	if !slices.Contains(remote.Names(cfg), name) {
+		return cfg, fmt.Errorf("no such remote: '%s'", name)
+	}
+	return cfg, nil
+}
+
+// remoteRemove removes a remote, its remote-tracking branches and the
+// settings of the branches that merge from it
+func remoteRemove(repo *repository.Repository, name string) error {
+	cfg, err := existingRemote(repo, name)
+	if err != nil {
+		return err
+	}
+	refs, err := trackingRefs(repo, cfg, name)
+	if err != nil {
+		return err
+	}
+
+	path := repo.RepositoryPath("config")
+	if err := config.RemoveSection(path, `remote "`+name+`"`); err != nil {
+		return err
+	}
+	for _, branch := range branchesOf(cfg, name) {
+		for _, key := range []string{"remote", "merge"} {
+			if err := config.Unset(path, `branch "`+branch+`"`, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		if err := references.Delete(repo, ref.Name); err != nil {
+			return err
+		}
+	}
+	// The symbolic HEAD may point to a branch that is gone already
+	os.Remove(repo.RepositoryPath(references.RemoteHead(name).String()))
+	removeEmptyDirs(repo.RepositoryPath(references.RemotesPrefix + name))
+	return nil
+}
+
+// remoteRename renames a remote, with its remote-tracking branches, and
+// updates the branches that merge from it
+func remoteRename(repo *repository.Repository, old, new string) error {
+	cfg, err := existingRemote(repo, old)
+	if err != nil {
+		return err
+	}
+	if err := checkRemoteName(repo, new); err != nil {
+		return err
+	}
+	refs, err := trackingRefs(repo, cfg, old)
+	if err != nil {
+		return err
+	}
+
+	path := repo.RepositoryPath("config")
+	if err := config.RenameSection(path, `remote "`+old+`"`, `remote "`+new+`"`); err != nil {
+		return err
+	}
+	// Refspecs that map to the remote-tracking branches of the old name
+	// map to those of the new name
+	oldPrefix, newPrefix := references.RemotesPrefix+old+"/", references.RemotesPrefix+new+"/"
+	fetch := cfg.GetAll(`remote "`+old+`"`, "fetch")
+	if err := config.Unset(path, `remote "`+new+`"`, "fetch"); err != nil {
+		return err
+	}
+	for _, spec := range fetch {
+		if err := config.Add(path, `remote "`+new+`"`, "fetch", strings.Replace(spec, ":"+oldPrefix, ":"+newPrefix, 1)); err != nil {
+			return err
+		}
+	}
+	for _, branch := range branchesOf(cfg, old) {
+		if err := config.Set(path, `branch "`+branch+`"`, "remote", new); err != nil {
+			return err
+		}
+	}
+
+	head := references.RemoteHead(old)
+	target, symbolic := head.Target(repo)
+	for _, ref := range refs {
+		rest, ok := strings.CutPrefix(ref.Name.String(), oldPrefix)
+		if !ok || ref.Name == head {
+			continue
+		}
+		if err := references.Update(repo, references.Reference(newPrefix+rest), ref.SHA); err != nil {
+			return err
+		}
+		if err := references.Delete(repo, ref.Name); err != nil {
+			return err
+		}
+	}
+	if symbolic {
+		os.Remove(repo.RepositoryPath(head.String()))
+		target = references.Reference(strings.Replace(target.String(), oldPrefix, newPrefix, 1))
+		if _, err := repo.RepositoryDir(true, "refs", "remotes", new); err != nil {
+			return err
+		}
+		if err := references.UpdateSymbolic(repo, references.RemoteHead(new), target); err != nil {
+			return err
+		}
+	}
+	removeEmptyDirs(repo.RepositoryPath(strings.TrimSuffix(oldPrefix, "/")))
+	return nil
+}
+
+func remoteSetURL(repo *repository.Repository, args []string) error {
+	push := flag.Bool("push", false, "Set the push URL instead of the fetch URL")
+	add := flag.Bool("add", false, "Add the URL, instead of replacing the URL")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	if flag.NArg() != 2 {
+		return errors.New("usage: got remote set-url [--push] [--add] <name> <url>")
+	}
+	name, url := flag.Arg(0), flag.Arg(1)
+	if _, err := existingRemote(repo, name); err != nil {
+		return err
+	}
+	key := "url"
+	if *push {
+		key = "pushurl"
+	}
+	path := repo.RepositoryPath("config")
+	if *add {
+		return config.Add(path, `remote "`+name+`"`, key, url)
+	}
+	// A remote can have several URLs, which are all replaced
+	if err := config.Unset(path, `remote "`+name+`"`, key); err != nil {
+		return err
+	}
+	return config.Set(path, `remote "`+name+`"`, key, url)
+}
+
+// remoteShow describes remotes as far as we know them without contacting
+// them: their URLs, remote-tracking branches and the branches that merge
+// from them
+func remoteShow(repo *repository.Repository, args []string) error {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	names := flag.Args()
+	if len(names) == 0 {
+		names = remote.Names(cfg)
+	}
+	for _, name := range names {
+		if _, err := existingRemote(repo, name); err != nil {
+			return err
+		}
+		fmt.Printf("* remote %s\n", name)
+		fmt.Printf("  Fetch URL: %s\n", remote.FetchURLs(cfg, name)[0])
+		for _, url := range remote.PushURLs(cfg, name) {
+			fmt.Printf("  Push  URL: %s\n", url)
+		}
+
+		prefix := references.RemotesPrefix + name + "/"
+		head := "(unknown)"
+		if target, ok := references.RemoteHead(name).Target(repo); ok {
+			head = strings.TrimPrefix(target.String(), prefix)
+		}
+		fmt.Printf("  HEAD branch: %s\n", head)
+
+		refs, err := trackingRefs(repo, cfg, name)
+		if err != nil {
+			return err
+		}
+		branches := []string{}
+		for _, ref := range refs {
+			if branch := strings.TrimPrefix(ref.Name.String(), prefix); branch != "HEAD" {
+				branches = append(branches, branch)
+			}
+		}
+		if len(branches) > 0 {
+			if len(branches) == 1 {
+				fmt.Println("  Remote branch:")
+			} else {
+				fmt.Println("  Remote branches:")
+			}
+			width := 0
+			for _, branch := range branches {
+				width = max(width, len(branch))
+			}
+			for _, branch := range branches {
+				fmt.Printf("    %-*s tracked\n", width, branch)
+			}
+		}
+
+		merging := branchesOf(cfg, name)
+		if len(merging) > 0 {
+			if len(merging) == 1 {
+				fmt.Println("  Local branch configured to merge from it:")
+			} else {
+				fmt.Println("  Local branches configured to merge from it:")
+			}
+			width := 0
+			for _, branch := range merging {
+				width = max(width, len(branch))
+			}
+			for _, branch := range merging {
+				merge, _ := cfg.Get(`branch "`+branch+`"`, "merge")
+				fmt.Printf("    %-*s merges with remote %s\n", width, branch, strings.TrimPrefix(merge, "refs/heads/"))
+			}
+		}
+	}
+	return nil
+}
+
+// trackingRefs returns the refs that the fetch refspecs of a remote map
+// its refs to, sorted by name
+func trackingRefs(repo *repository.Repository, cfg config.GitConfig, name string) ([]references.Ref, error) {
+	refspecs, err := remote.FetchRefspecs(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	all, err := references.NewRefStore(repo).List("refs/")
+	if err != nil {
+		return nil, err
+	}
+	refs := []references.Ref{}
+	for _, ref := range all {
+		for _, r := range refspecs {
+			// A refspec maps to its destination like the reverse refspec
+			// maps from it
+			reverse := remote.Refspec{Src: r.Dst}
+			if _, ok := reverse.Match(ref.Name.String()); ok && !r.Negative && r.Dst != "" {
+				refs = append(refs, ref)
+				break
+			}
+		}
+	}
+	return refs, nil
+}
+
+// branchesOf returns the local branches that merge from a remote, sorted
+// by name
+func branchesOf(cfg config.GitConfig, name string) []string {
+	branches := []string{}
+	for _, sec := range cfg.Sections() {
+		branch, ok := strings.CutPrefix(sec, `branch "`)
+		if !ok || !strings.HasSuffix(branch, `"`) {
+			continue
+		}
+		branch = strings.TrimSuffix(branch, `"`)
+		if r, _ := cfg.Get(sec, "remote"); r == name && !slices.Contains(branches, branch) {
+			branches = append(branches, branch)
+		}
+	}
+	slices.Sort(branches)
+	return branches
+}
+
+// removeEmptyDirs removes dir and the directories below it, as far as
+// they are empty
+func removeEmptyDirs(dir string) {
+	dirs := []string{}
+	filepath.WalkDir(dir, func(path string, d iofs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Children come after their parents in the walk
+	for _, d := range slices.Backward(dirs) {
+		os.Remove(d)
+	}
+}
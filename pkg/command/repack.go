@@ -0,0 +1,103 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func RepackCommand() *Command {
+	command := newCommand("repack")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		window := flag.Int("window", -1, "How many objects to try as the delta base of an object, instead of pack.window (10 by default)")
+		depth := flag.Int("depth", -1, "How long delta chains can get, instead of pack.depth (50 by default)")
+		all := flag.Bool("a", false, "Pack the objects of the existing packs too, into one pack that replaces them")
+		var quiet bool
+		flag.BoolVar(&quiet, "quiet", false, "Don't report what was packed")
+		flag.BoolVar(&quiet, "q", false, "Same as --quiet")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 {
+			return errors.New("usage: got repack [-a] [-q] [--window <n>] [--depth <n>]")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		opts, err := packWriteOptions(repo, *window, *depth)
+		if err != nil {
+			return err
+		}
+		result, err := objects.Repack(repo, objects.RepackOptions{WriteOptions: opts, All: *all})
+		if err != nil {
+			return err
+		}
+		if quiet {
+			return nil
+		}
+		if result.Count == 0 {
+			fmt.Println("Nothing new to pack.")
+			return nil
+		}
+		fmt.Printf("Packed %d objects into %s\n", result.Count, result.Name)
+		return nil
+	}
+	command.Description = func() string { return "Pack the loose objects and remove them, or pack all objects with -a" }
+	return command
+}
+
+// packWriteOptions returns the window and depth to write packs with: the
+// given ones, or else pack.window and pack.depth. Negative values aren't
+// given. Objects above core.bigFileThreshold aren't deltified.
+func packWriteOptions(repo *repository.Repository, window, depth int) (pack.WriteOptions, error) {
+	opts := pack.DefaultWriteOptions
+	cfg, err := repo.Config()
+	if err != nil {
+		return opts, err
+	}
+	if opts.BigFileThreshold, err = bigFileThreshold(cfg); err != nil {
+		return opts, err
+	}
+	for _, setting := range []struct {
+		key   string
+		given int
+		value *int
+	}{{"window", window, &opts.Window}, {"depth", depth, &opts.Depth}} {
+		if setting.given >= 0 {
+			*setting.value = setting.given
+			continue
+		}
+		if value, ok := cfg.Get("pack", setting.key); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("invalid pack.%s %q", setting.key, value)
+			}
+			*setting.value = n
+		}
+	}
+	return opts, nil
+}
+
+// bigFileThreshold returns core.bigFileThreshold, the size above which
+// files are big: they are streamed when they are added and never
+// deltified
+func bigFileThreshold(cfg config.GitConfig) (int64, error) {
+	value, ok := cfg.Get("core", "bigFileThreshold")
+	if !ok {
+		return pack.DefaultBigFileThreshold, nil
+	}
+	threshold, ok := cfg.GetInt("core", "bigFileThreshold")
+	if !ok || threshold <= 0 {
+		return 0, fmt.Errorf("invalid core.bigFileThreshold %q", value)
+	}
+	return threshold, nil
+}
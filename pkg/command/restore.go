@@ -0,0 +1,193 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func RestoreCommand() *Command {
+	command := newCommand("restore")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var source string
+		flag.StringVar(&source, "source", "", "Restore the files from this tree-ish instead of the index")
+		flag.StringVar(&source, "s", "", "Same as --source")
+		ours := flag.Bool("ours", false, "Restore unmerged paths from our version")
+		theirs := flag.Bool("theirs", false, "Restore unmerged paths from their version")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if len(flag.Args()) == 0 {
+			return errors.New("you must specify path(s) to restore")
+		}
+		if *ours && *theirs {
+			return errors.New("--ours and --theirs are incompatible")
+		}
+		if source != "" && (*ours || *theirs) {
+			return errors.New("cannot specify both --source and --ours or --theirs")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		if source != "" {
+			return restoreFromTree(repo, "restore", source, flag.Args())
+		}
+		_, err = restoreFromIndex(repo, "restore", flag.Args(), stageFlag(*ours, *theirs))
+		return err
+	}
+	command.Description = func() string { return "Restore worktree files from the index or a commit" }
+	return command
+}
+
+// stageFlag returns the index stage that --ours or --theirs selects, or 0
+func stageFlag(ours, theirs bool) uint16 {
+	switch {
+	case ours:
+		return 2
+	case theirs:
+		return 3
+	}
+	return 0
+}
+
+// restoreFromIndex writes the index version of the files matching the
+// paths to the worktree, and returns how many were written. Unmerged files
+// are only restored when stage says which of their versions to take: 2
+// for ours, or 3 for theirs. The files are backed up so the operation can
+// be undone.
+func restoreFromIndex(repo *repository.Repository, operation string, paths []string, stage uint16) (int, error) {
+	idx, err := index.Read(repo)
+	if err != nil {
+		return 0, err
+	}
+	names := []string{}
+	for _, e := range idx.Entries {
+		if len(names) == 0 || names[len(names)-1] != e.Name {
+			names = append(names, e.Name)
+		}
+	}
+	matched, err := matchPathspecs(repo, names, paths)
+	if err != nil {
+		return 0, err
+	}
+
+	// Every file is checked before anything is written
+	blobs := map[string]*hashing.SHA{}
+	for _, name := range matched {
+		stages := indexStages(idx, name)
+		switch {
+		case stages[0] != nil:
+			blobs[name] = stages[0]
+		case stage == 0:
+			return 0, fmt.Errorf("path '%s' is unmerged", name)
+		case stages[stage] == nil && stage == 2:
+			return 0, fmt.Errorf("path '%s' does not have our version", name)
+		case stages[stage] == nil:
+			return 0, fmt.Errorf("path '%s' does not have their version", name)
+		default:
+			blobs[name] = stages[stage]
+		}
+	}
+
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return 0, err
+	}
+	record := newUndoRecord(operation)
+	if err := record.saveFiles(repo, matched); err != nil {
+		return 0, err
+	}
+	for _, name := range matched {
+		if err := writeWorktreeFile(repo, attrs, name, blobs[name]); err != nil {
+			return 0, err
+		}
+	}
+	return len(matched), record.write(repo)
+}
+
+// restoreFromTree writes the version of the files matching the paths in a
+// tree-ish, like MERGE_HEAD, to the worktree. Tracked files that aren't in
+// the tree are removed.
+func restoreFromTree(repo *repository.Repository, operation, source string, paths []string) error {
+	tree, err := objects.Find(repo, source, objects.TypeTree, true)
+	if err != nil {
+		return err
+	}
+	blobs, err := objects.MapFromTree(repo, tree.AsString())
+	if err != nil {
+		return err
+	}
+	idx, err := index.Read(repo)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range blobs {
+		names = append(names, name)
+	}
+	for _, e := range idx.Entries {
+		names = append(names, e.Name)
+	}
+	slices.Sort(names)
+	matched, err := matchPathspecs(repo, slices.Compact(names), paths)
+	if err != nil {
+		return err
+	}
+
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+	record := newUndoRecord(operation)
+	if err := record.saveFiles(repo, matched); err != nil {
+		return err
+	}
+	for _, name := range matched {
+		if blobs[name] == nil {
+			err = removeWorktreeFile(repo, name)
+		} else {
+			err = writeWorktreeFile(repo, attrs, name, blobs[name])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return record.write(repo)
+}
+
+// matchPathspecs returns the names that are one of the paths, or inside
+// one of them if it is a directory. Paths are relative to the current
+// directory, and each one has to match a name.
+func matchPathspecs(repo *repository.Repository, names, paths []string) ([]string, error) {
+	matched := []string{}
+	for _, path := range paths {
+		rel, err := worktreePath(repo, path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, name := range names {
+			if rel == "." || name == rel || strings.HasPrefix(name, rel+"/") {
+				matched = append(matched, name)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", path)
+		}
+	}
+	slices.Sort(matched)
+	return slices.Compact(matched), nil
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/revwalk"
+)
+
+func RevListCommand() *Command {
+	command := newCommand("rev-list")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		skip := flag.Int("skip", 0, "Skip this many commits before starting to list them")
+		var maxCount int
+		flag.IntVar(&maxCount, "max-count", -1, "List at most this many commits")
+		flag.IntVar(&maxCount, "n", -1, "Same as --max-count")
+		resume := flag.String("resume", "", "Continue a listing from the cursor it printed, instead of starting at commits")
+		showCursor := flag.Bool("show-cursor", false, "End with a line 'cursor <cursor>' when there are more commits, to continue with --resume")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if (flag.NArg() == 0) == (*resume == "") {
+			return errors.New("usage: got rev-list [--skip=<n>] [--max-count=<n>] [--show-cursor] (<commit>... | --resume=<cursor>)")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		var walker *revwalk.Walker
+		if *resume != "" {
+			walker, err = revwalk.Resume(repo, *resume)
+		} else {
+			starts := []*hashing.SHA{}
+			for _, name := range flag.Args() {
+				sha, err := objects.Find(repo, name, objects.TypeCommit, true)
+				if err != nil {
+					return err
+				}
+				starts = append(starts, sha)
+			}
+			walker, err = revwalk.New(repo, starts...)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := walker.Skip(*skip); err != nil {
+			return err
+		}
+		w := bufio.NewWriter(os.Stdout)
+		for n := 0; maxCount < 0 || n < maxCount; n++ {
+			c, err := walker.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, c.SHA.AsString())
+		}
+		if cursor := walker.Cursor(); *showCursor && cursor != "" {
+			fmt.Fprintf(w, "cursor %s\n", cursor)
+		}
+		return w.Flush()
+	}
+	command.Description = func() string { return "List commits in reverse chronological order" }
+	return command
+}
@@ -4,30 +4,89 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/jessegeens/got/pkg/format"
 	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
 )
 
+// abbrevFlag is a flag that can be given with or without a length,
+// like --short and --short=10
+type abbrevFlag struct {
+	set    bool
+	length int
+}
+
+func (a *abbrevFlag) String() string {
+	if !a.set {
+		return ""
+	}
+	return strconv.Itoa(a.length)
+}
+
+func (a *abbrevFlag) Set(value string) error {
+	a.set = true
+	if value == "true" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid length '%s'", value)
+	}
+	a.length = n
+	return nil
+}
+
+func (a *abbrevFlag) IsBoolFlag() bool { return true }
+
+type revParseOptions struct {
+	revType      string
+	short        abbrevFlag
+	abbrevRef    bool
+	showToplevel bool
+	showGitDir   bool
+	names        []string
+}
+
 func RevParseCommand() *Command {
 	command := newCommand("rev-parse")
 	command.Action = func(args []string) error {
-		revType := *flag.String("type", "", "Specify the expected type: one of blob, commit, tag, tree")
-		name := *flag.String("name", "", "The name to parse")
-		flag.Parse()
+		command.ResetFlags()
+		opts := revParseOptions{}
+		flag.StringVar(&opts.revType, "type", "", "Specify the expected type: one of blob, commit, tag, tree")
+		name := flag.String("name", "", "The name to parse")
+		flag.Var(&opts.short, "short", "Abbreviate object names, optionally to the given minimum length")
+		flag.BoolVar(&opts.abbrevRef, "abbrev-ref", false, "Print the short name of refs, e.g. the current branch for HEAD")
+		flag.BoolVar(&opts.showToplevel, "show-toplevel", false, "Print the absolute path of the top-level directory of the worktree")
+		flag.BoolVar(&opts.showGitDir, "git-dir", false, "Print the path of the git directory")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if *name != "" {
+			opts.names = append(opts.names, *name)
+		}
+		opts.names = append(opts.names, flag.Args()...)
 
-		return revParse(revType, name)
+		return revParse(opts)
 	}
 	command.Description = func() string { return "Parse revision (or other objects) identifiers" }
 	return command
 }
 
-func revParse(revType, name string) error {
-	format, err := objects.ParseType(revType)
-	if err != nil {
-		return errors.New("invalid type: " + revType)
+func revParse(opts revParseOptions) error {
+	objType := objects.TypeNoTypeSpecified
+	if opts.revType != "" {
+		var err error
+		objType, err = objects.ParseType(opts.revType)
+		if err != nil {
+			return errors.New("invalid type: " + opts.revType)
+		}
 	}
-	if name == "" {
+	if len(opts.names) == 0 && !opts.showToplevel && !opts.showGitDir {
 		return errors.New("no name given")
 	}
 
@@ -36,10 +95,79 @@ func revParse(revType, name string) error {
 		return err
 	}
 
-	output, err := objects.Find(repo, name, format, true)
-	if err != nil {
-		return err
+	if opts.showToplevel {
+		fmt.Println(strings.TrimSuffix(repo.WorkTree(), string(filepath.Separator)))
+	}
+	if opts.showGitDir {
+		gitDir, err := revParseGitDir(repo)
+		if err != nil {
+			return err
+		}
+		fmt.Println(gitDir)
+	}
+
+	for _, name := range opts.names {
+		if opts.abbrevRef {
+			// Like git, names that are not refs print nothing
+			if ref, ok := abbrevRefName(repo, name); ok {
+				fmt.Println(ref)
+			}
+			continue
+		}
+
+		sha, err := objects.Find(repo, name, objType, true)
+		if err != nil {
+			return err
+		}
+		if opts.short.set {
+			length := opts.short.length
+			if length == 0 {
+				length = objects.AbbrevLength(repo)
+			}
+			fmt.Println(objects.ShortSHA(repo, sha, length))
+			continue
+		}
+		fmt.Println(sha.AsString())
 	}
-	fmt.Printf("%s\n", output)
 	return nil
 }
+
+// revParseGitDir returns the gitdir like git does: relative when
+// run from the top of the worktree, absolute otherwise
+func revParseGitDir(repo *repository.Repository) (string, error) {
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		return "", err
+	}
+	if cwd == strings.TrimSuffix(repo.WorkTree(), string(filepath.Separator)) {
+		return ".git", nil
+	}
+	return repo.GitDir(), nil
+}
+
+// abbrevRefName finds the ref a name refers to and returns its short
+// name. HEAD gives the current branch, or HEAD when it is detached.
+func abbrevRefName(repo *repository.Repository, name string) (string, bool) {
+	if name == "HEAD" {
+		branch, onBranch, err := repo.GetActiveBranch()
+		if err != nil {
+			return "", false
+		}
+		if !onBranch {
+			return "HEAD", true
+		}
+		return branch, true
+	}
+
+	// The same lookup order as git
+	for _, candidate := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+		if !strings.HasPrefix(candidate, "refs/") {
+			continue
+		}
+		sha, err := references.Reference(candidate).Resolve(repo)
+		if err == nil && sha != "" {
+			return format.ShortRefName(candidate), true
+		}
+	}
+	return "", false
+}
@@ -3,69 +3,188 @@ package command
 import (
 	"errors"
 	"flag"
-	"os"
-	"path"
-	"path/filepath"
+	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/jessegeens/got/pkg/attributes"
 	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
 )
 
 func RmCommand() *Command {
 	command := newCommand("rm")
 	command.Action = func(args []string) error {
-		path := *flag.String("path", "", "Specify the path of the file or directory to remove")
-		flag.Parse()
+		command.ResetFlags()
+		var force, quiet bool
+		flag.BoolVar(&force, "force", false, "Remove files even if they have changes that would be lost")
+		flag.BoolVar(&force, "f", false, "Same as --force")
+		flag.BoolVar(&quiet, "quiet", false, "Don't print the removed files")
+		flag.BoolVar(&quiet, "q", false, "Same as --quiet")
+		recursive := flag.Bool("r", false, "Remove the files in directories that are given")
+		cached := flag.Bool("cached", false, "Only remove the files from the index, and keep them in the worktree")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() == 0 {
+			return errors.New("you must specify path(s) to remove")
+		}
+
 		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
-
-		return rm(repo, path, true)
+		record := newUndoRecord("rm")
+		err = index.Update(repo, func(idx *index.Index) error {
+			names, err := rmPaths(repo, idx, flag.Args(), *recursive)
+			if err != nil {
+				return err
+			}
+			if !force {
+				if err := checkRemovable(repo, idx, names, *cached); err != nil {
+					return err
+				}
+			}
+			record.saveEntries(idx, names)
+			if !*cached {
+				if err := record.saveFiles(repo, names); err != nil {
+					return err
+				}
+			}
+			for _, name := range names {
+				idx.Remove(name)
+				if !*cached {
+					if err := removeWorktreeFile(repo, name); err != nil {
+						return err
+					}
+				}
+				if !quiet {
+					fmt.Printf("rm '%s'\n", name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return record.write(repo)
 	}
 	command.Description = func() string { return "Remove files from the working tree and the index" }
 	return command
 }
 
-func rm(repo *repository.Repository, rmPath string, delete bool) error {
-	idx, err := index.Read(repo)
+// rmPaths returns the files in the index that the paths name. A directory
+// only names the files in it if recursive is set.
+func rmPaths(repo *repository.Repository, idx *index.Index, paths []string, recursive bool) ([]string, error) {
+	names := []string{}
+	for _, e := range idx.Entries {
+		if len(names) == 0 || names[len(names)-1] != e.Name {
+			names = append(names, e.Name)
+		}
+	}
+
+	matched := []string{}
+	for _, path := range paths {
+		rel, err := worktreePath(repo, path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, name := range names {
+			if name == rel {
+				matched = append(matched, name)
+				found = true
+			} else if rel == "." || strings.HasPrefix(name, rel+"/") {
+				if !recursive {
+					return nil, fmt.Errorf("not removing '%s' recursively without -r", path)
+				}
+				matched = append(matched, name)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any files", path)
+		}
+	}
+	slices.Sort(matched)
+	return slices.Compact(matched), nil
+}
+
+// checkRemovable makes sure removing the files loses nothing that can't be
+// found in HEAD, like git: a file whose staged version differs from both
+// HEAD and the worktree is never removed. Without cached, files with
+// staged changes or local modifications aren't removed either. Unmerged
+// files and files that are already gone from the worktree can always be
+// removed.
+func checkRemovable(repo *repository.Repository, idx *index.Index, names []string, cached bool) error {
+	headTree, err := objects.HeadTree(repo)
 	if err != nil {
 		return err
 	}
-
-	absPath, err := filepath.Abs(rmPath)
+	head, err := objects.MapFromTree(repo, headTree.AsString())
 	if err != nil {
 		return err
 	}
-
-	if !strings.HasPrefix(absPath, repo.WorkTree()) {
-		return errors.New("cannot remove a path outside the worktree")
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
 	}
 
-	toKeep := []*index.Entry{}
-	var toDelete *index.Entry
-
-	for _, e := range idx.Entries {
-		fullPath := path.Join(repo.WorkTree(), e.Name)
-		if fullPath == absPath {
-			toDelete = e
-		} else {
-			toKeep = append(toKeep, e)
+	var both, staged, local []string
+	for _, name := range names {
+		indexed := indexStages(idx, name)[0]
+		if indexed == nil {
+			continue
 		}
-	}
-
-	if toDelete == nil {
-		return errors.New("path not found in the worktree")
-	}
-
-	if delete {
-		err = os.RemoveAll(absPath)
+		worktree, err := worktreeBlob(repo, attrs, name)
 		if err != nil {
 			return err
 		}
+		if worktree == nil {
+			continue
+		}
+		hasStaged := !sameBlob(indexed, head[name])
+		hasLocal := !sameBlob(indexed, worktree)
+		switch {
+		case hasStaged && hasLocal:
+			both = append(both, name)
+		case cached:
+		case hasStaged:
+			staged = append(staged, name)
+		case hasLocal:
+			local = append(local, name)
+		}
 	}
 
-	idx.Entries = toKeep
-	return idx.Write(repo)
+	messages := []string{}
+	report := func(files []string, one, many, hint string) {
+		if len(files) == 0 {
+			return
+		}
+		message := one
+		if len(files) > 1 {
+			message = many
+		}
+		for _, name := range files {
+			message += "\n    " + name
+		}
+		messages = append(messages, message+"\n"+hint)
+	}
+	report(both,
+		"the following file has staged content different from both the\nfile and the HEAD:",
+		"the following files have staged content different\nfrom both the file and the HEAD:",
+		"(use -f to force removal)")
+	report(staged,
+		"the following file has changes staged in the index:",
+		"the following files have changes staged in the index:",
+		"(use --cached to keep the file, or -f to force removal)")
+	report(local,
+		"the following file has local modifications:",
+		"the following files have local modifications:",
+		"(use --cached to keep the file, or -f to force removal)")
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "\n"))
+	}
+	return nil
 }
@@ -0,0 +1,639 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/revwalk"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	// rpcFailed is for errors of the operation itself, like a commit with
+	// nothing to commit
+	rpcFailed = -32000
+)
+
+func ServeAPICommand() *Command {
+	command := newCommand("serve-api")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		socket := flag.String("socket", "", "Unix socket to listen on, instead of got-api.sock in the gitdir")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		if *socket == "" {
+			*socket = repo.RepositoryPath("got-api.sock")
+		}
+		return serveAPI(repo, *socket)
+	}
+	command.Description = func() string {
+		return "Serve status, diff, hunk staging, commit and log as JSON-RPC over HTTP on a unix socket, for editors"
+	}
+	return command
+}
+
+// serveAPI answers JSON-RPC 2.0 requests, POSTed over HTTP to the unix
+// socket, until it is interrupted. Requests are handled concurrently, on
+// the same Repository handle.
+func serveAPI(repo *repository.Repository, socket string) error {
+	// A socket that is left behind by a server that is gone is in the way,
+	// but one that is still answering is not ours to take over
+	if _, err := os.Lstat(socket); err == nil {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return fmt.Errorf("another server is listening on %s", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return err
+		}
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+	// The API can change the repository, so only the user gets to use it
+	if err := os.Chmod(socket, 0o600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	server := &http.Server{Handler: &apiHandler{repo: repo}}
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	go func() {
+		<-interrupted
+		server.Shutdown(context.Background())
+	}()
+
+	fmt.Printf("Listening on %s\n", socket)
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// apiMethods are the methods of the API. They decode their own params,
+// and an error that is an *rpcError is returned as is.
+var apiMethods = map[string]func(repo *repository.Repository, params json.RawMessage) (any, error){
+	"status":    apiStatus,
+	"diff":      apiDiff,
+	"stageHunk": apiStageHunk,
+	"commit":    apiCommit,
+	"log":       apiLog,
+}
+
+type apiHandler struct {
+	repo *repository.Repository
+}
+
+func (h *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "JSON-RPC requests have to be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var response any
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+			response = parseErrorResponse(err)
+		} else {
+			responses := []*rpcResponse{}
+			for _, request := range batch {
+				if response := h.call(request); response != nil {
+					responses = append(responses, response)
+				}
+			}
+			if len(responses) > 0 {
+				response = responses
+			}
+		}
+	} else if single := h.call(body); single != nil {
+		response = single
+	}
+
+	// Notifications don't get a response
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func parseErrorResponse(err error) *rpcResponse {
+	message := "empty batch"
+	if err != nil {
+		message = err.Error()
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcInvalidRequest, message}}
+}
+
+// call handles a single request. It returns nil for notifications, which
+// are requests without an id.
+func (h *apiHandler) call(data []byte) *rpcResponse {
+	var request rpcRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, err.Error()}}
+		}
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcInvalidRequest, err.Error()}}
+	}
+	response := &rpcResponse{JSONRPC: "2.0", ID: request.ID}
+	if response.ID == nil {
+		response.ID = json.RawMessage("null")
+	}
+
+	method, ok := apiMethods[request.Method]
+	switch {
+	case request.JSONRPC != "2.0" || request.Method == "":
+		response.Error = &rpcError{rpcInvalidRequest, `requests need "jsonrpc": "2.0" and a method`}
+	case !ok:
+		response.Error = &rpcError{rpcMethodNotFound, fmt.Sprintf("method %q not found", request.Method)}
+	default:
+		result, err := method(h.repo, request.Params)
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			response.Error = rpcErr
+		} else if err != nil {
+			response.Error = &rpcError{rpcFailed, err.Error()}
+		} else {
+			response.Result = result
+		}
+	}
+	// Notifications don't get a response, not even for errors
+	if request.ID == nil {
+		return nil
+	}
+	return response
+}
+
+// decodeParams decodes the params of a method into v. Methods can be
+// called without params, so v keeps its defaults then.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &rpcError{rpcInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+type apiStatusResult struct {
+	// Branch is the branch HEAD is on, or "" if HEAD is detached
+	Branch string `json:"branch"`
+	// Head is the commit HEAD points to, or "" if there are no commits yet
+	Head      string       `json:"head"`
+	Staged    []fileChange `json:"staged"`
+	Unstaged  []fileChange `json:"unstaged"`
+	Untracked []string     `json:"untracked"`
+	Unmerged  []string     `json:"unmerged"`
+}
+
+func apiStatus(repo *repository.Repository, params json.RawMessage) (any, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	result := apiStatusResult{}
+	branch, onBranch, err := repo.GetActiveBranch()
+	if err != nil {
+		return nil, err
+	}
+	if onBranch {
+		result.Branch = branch
+	}
+	if result.Head, err = references.Reference("HEAD").Resolve(repo); err != nil {
+		return nil, err
+	}
+
+	idx, err := index.Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	if result.Staged, err = stagedChanges(repo, idx); err != nil {
+		return nil, err
+	}
+	if result.Unstaged, result.Untracked, err = unstagedChanges(repo, idx, nil); err != nil {
+		return nil, err
+	}
+	result.Unmerged = idx.Unmerged()
+	return result, nil
+}
+
+type apiDiffParams struct {
+	// Cached compares HEAD to the index, instead of the index to the worktree
+	Cached bool `json:"cached"`
+	// Context is the number of unchanged lines around changes
+	Context *int `json:"context"`
+	// Paths limits the diff to these files and directories
+	Paths []string `json:"paths"`
+}
+
+type apiFileDiff struct {
+	Path string `json:"path"`
+	// The hashes are "" if the file doesn't exist on that side
+	OldHash string    `json:"oldHash"`
+	NewHash string    `json:"newHash"`
+	Binary  bool      `json:"binary"`
+	Hunks   []apiHunk `json:"hunks"`
+	// Patch is the diff of the file, as got diff shows it
+	Patch string `json:"patch"`
+}
+
+type apiHunk struct {
+	Header   string    `json:"header"`
+	OldStart int       `json:"oldStart"`
+	OldLines int       `json:"oldLines"`
+	NewStart int       `json:"newStart"`
+	NewLines int       `json:"newLines"`
+	Lines    []apiLine `json:"lines"`
+}
+
+type apiLine struct {
+	// Op is " " for unchanged lines, "-" for removed ones and "+" for
+	// added ones
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+func apiDiff(repo *repository.Repository, params json.RawMessage) (any, error) {
+	p := apiDiffParams{}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	context, err := contextParam(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range p.Paths {
+		if err := checkAPIPath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	from, to, err := diffSides(repo, p.Cached, nil)
+	if err != nil {
+		return nil, err
+	}
+	files := []apiFileDiff{}
+	err = forEachChange(from, to, func(name string, oldSha, newSha *hashing.SHA, oldContents, newContents []byte) error {
+		if len(p.Paths) > 0 && !slices.ContainsFunc(p.Paths, func(path string) bool {
+			return path == "." || name == path || strings.HasPrefix(name, strings.TrimSuffix(path, "/")+"/")
+		}) {
+			return nil
+		}
+		var patch strings.Builder
+		if err := writeFileDiff(&patch, repo, name, oldSha, newSha, oldContents, newContents, context); err != nil {
+			return err
+		}
+		file := apiFileDiff{
+			Path:   name,
+			Binary: diff.IsBinary(oldContents) || diff.IsBinary(newContents),
+			Hunks:  []apiHunk{},
+			Patch:  patch.String(),
+		}
+		if oldSha != nil {
+			file.OldHash = oldSha.AsString()
+		}
+		if newSha != nil {
+			file.NewHash = newSha.AsString()
+		}
+		if !file.Binary {
+			for _, hunk := range diff.Hunks(oldContents, newContents, context) {
+				file.Hunks = append(file.Hunks, newAPIHunk(hunk))
+			}
+		}
+		files = append(files, file)
+		return nil
+	})
+	return map[string]any{"files": files}, err
+}
+
+func newAPIHunk(hunk diff.Hunk) apiHunk {
+	lines := []apiLine{}
+	for _, line := range hunk.Lines {
+		lines = append(lines, apiLine{Op: string(line.Op), Text: string(line.Text)})
+	}
+	return apiHunk{
+		Header:   hunk.Header(),
+		OldStart: hunk.OldStart,
+		OldLines: hunk.OldLines,
+		NewStart: hunk.NewStart,
+		NewLines: hunk.NewLines,
+		Lines:    lines,
+	}
+}
+
+func contextParam(context *int) (int, error) {
+	if context == nil {
+		return diff.DefaultContext, nil
+	}
+	if *context < 0 {
+		return 0, &rpcError{rpcInvalidParams, "the number of context lines can't be negative"}
+	}
+	return *context, nil
+}
+
+// checkAPIPath makes sure a path from a request is relative to the root of
+// the worktree, and stays inside of it
+func checkAPIPath(path string) error {
+	if path != "." && (!filepath.IsLocal(path) || filepath.Clean(path) != path) {
+		return &rpcError{rpcInvalidParams, fmt.Sprintf("path '%s' is not relative to the root of the worktree", path)}
+	}
+	return nil
+}
+
+type apiStageHunkParams struct {
+	Path string `json:"path"`
+	// Hunk is the position of the hunk in the diff of the file between the
+	// index and the worktree, counting from 0, with the same context as
+	// the diff it was picked from
+	Hunk    int  `json:"hunk"`
+	Context *int `json:"context"`
+}
+
+// apiStageHunk stages one hunk of the unstaged changes of a file, like
+// got add -p
+func apiStageHunk(repo *repository.Repository, params json.RawMessage) (any, error) {
+	p := apiStageHunkParams{}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	context, err := contextParam(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAPIPath(p.Path); err != nil {
+		return nil, err
+	}
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var staged *hashing.SHA
+	err = index.Update(repo, func(idx *index.Index) error {
+		entry, ok := idx.Get(p.Path)
+		if !ok {
+			if slices.Contains(idx.Unmerged(), p.Path) {
+				return fmt.Errorf("path '%s' is unmerged", p.Path)
+			}
+			return fmt.Errorf("path '%s' is not in the index", p.Path)
+		}
+		old, err := blobContents(repo, entry.SHA)
+		if err != nil {
+			return err
+		}
+		// A deleted file has its lines removed
+		worktreeContents, err := readWorktreeFile(repo, attrs, p.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if diff.IsBinary(old) || diff.IsBinary(worktreeContents) {
+			return fmt.Errorf("cannot stage hunks of binary file '%s'", p.Path)
+		}
+
+		hunks := diff.Hunks(old, worktreeContents, context)
+		if p.Hunk < 0 || p.Hunk >= len(hunks) {
+			return &rpcError{rpcInvalidParams, fmt.Sprintf("'%s' has %d unstaged hunks, there is no hunk %d", p.Path, len(hunks), p.Hunk)}
+		}
+		contents, err := diff.ApplyHunks(old, hunks[p.Hunk:p.Hunk+1])
+		if err != nil {
+			return err
+		}
+		if staged, err = objects.ObjectHash(contents, objects.TypeBlob, repo); err != nil {
+			return err
+		}
+
+		// The stat data of the file only goes with the staged version if
+		// that is all of the file, or status would miss the unstaged rest
+		updated := &index.Entry{ModeType: entry.ModeType, ModePerms: entry.ModePerms, SHA: staged, Name: entry.Name}
+		if bytes.Equal(contents, worktreeContents) {
+			if updated, err = indexEntryFromFile(repo, p.Path, staged); err != nil {
+				return err
+			}
+			updated.ModeType, updated.ModePerms = entry.ModeType, entry.ModePerms
+		}
+		idx.Add(updated)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"path": p.Path, "blob": staged.AsString()}, nil
+}
+
+type apiCommitParams struct {
+	Message           string `json:"message"`
+	AllowEmpty        bool   `json:"allowEmpty"`
+	AllowEmptyMessage bool   `json:"allowEmptyMessage"`
+	NoVerify          bool   `json:"noVerify"`
+	// Author is "Name <email>", instead of the configured one
+	Author string `json:"author"`
+	Amend  bool   `json:"amend"`
+	// Sign and NoSign are like --gpg-sign and --no-gpg-sign
+	Sign   bool `json:"sign"`
+	NoSign bool `json:"noSign"`
+}
+
+// apiCommit commits the index. There is no editor, so the message has to
+// be given.
+func apiCommit(repo *repository.Repository, params json.RawMessage) (any, error) {
+	p := apiCommitParams{}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	// Commits through the API don't race each other for HEAD
+	lock := repo.Lock("commit")
+	lock.Lock()
+	defer lock.Unlock()
+
+	sha, err := commit(repo, p.Message, commitOptions{
+		allowEmpty:        p.AllowEmpty,
+		allowEmptyMessage: p.AllowEmptyMessage,
+		noVerify:          p.NoVerify,
+		author:            p.Author,
+		messageGiven:      true,
+		amend:             p.Amend,
+		sign:              p.Sign,
+		noSign:            p.NoSign,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"commit": sha.AsString()}, nil
+}
+
+type apiLogParams struct {
+	// Rev is the commit to start from, HEAD if it is empty
+	Rev string `json:"rev"`
+	// Max is the number of commits to return, or all of them if it is 0
+	Max int `json:"max"`
+	// Skip is the number of commits to pass over before the first one
+	// that is returned
+	Skip int `json:"skip"`
+	// Cursor continues the history where a previous request stopped,
+	// instead of starting at Rev
+	Cursor string `json:"cursor"`
+}
+
+type apiCommitInfo struct {
+	Hash      string      `json:"hash"`
+	Parents   []string    `json:"parents"`
+	Author    apiIdentity `json:"author"`
+	Committer apiIdentity `json:"committer"`
+	Summary   string      `json:"summary"`
+	Message   string      `json:"message"`
+}
+
+type apiIdentity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Time is a unix timestamp, and Timezone the offset like "+0100"
+	Time     int64  `json:"time"`
+	Timezone string `json:"timezone"`
+}
+
+func newAPIIdentity(ident objects.Ident) apiIdentity {
+	return apiIdentity{Name: ident.Name, Email: ident.Email, Time: ident.When.Unix(), Timezone: ident.When.Format("-0700")}
+}
+
+// apiLog returns the history of a commit, newest commits first. The
+// result has a cursor when there are more commits, which continues the
+// history in the next request.
+func apiLog(repo *repository.Repository, params json.RawMessage) (any, error) {
+	p := apiLogParams{Rev: "HEAD"}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Max < 0 {
+		return nil, &rpcError{rpcInvalidParams, "max can't be negative"}
+	}
+	if p.Skip < 0 {
+		return nil, &rpcError{rpcInvalidParams, "skip can't be negative"}
+	}
+
+	commits := []apiCommitInfo{}
+	var walker *revwalk.Walker
+	var err error
+	if p.Cursor != "" {
+		if walker, err = revwalk.Resume(repo, p.Cursor); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	} else {
+		start := p.Rev
+		if start == "" || start == "HEAD" {
+			// Without any commits, there is no history to show
+			head, err := references.Reference("HEAD").Resolve(repo)
+			if err != nil || head == "" {
+				return map[string]any{"commits": commits}, err
+			}
+			start = head
+		}
+		sha, err := objects.Find(repo, start, objects.TypeCommit, true)
+		if err != nil {
+			return nil, err
+		}
+		if walker, err = revwalk.New(repo, sha); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := walker.Skip(p.Skip); err != nil {
+		return nil, err
+	}
+	for p.Max == 0 || len(commits) < p.Max {
+		c, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		author, _ := c.Commit.GetValue("author")
+		committer, _ := c.Commit.GetValue("committer")
+		message := c.Commit.Message()
+		summary, _, _ := strings.Cut(strings.TrimLeft(message, "\n"), "\n")
+		info := apiCommitInfo{
+			Hash:      c.SHA.AsString(),
+			Parents:   []string{},
+			Author:    newAPIIdentity(objects.ParseIdent(author)),
+			Committer: newAPIIdentity(objects.ParseIdent(committer)),
+			Summary:   summary,
+			Message:   message,
+		}
+		for _, parent := range c.Commit.GetValues("parent") {
+			info.Parents = append(info.Parents, string(parent))
+		}
+		commits = append(commits, info)
+	}
+	result := map[string]any{"commits": commits}
+	if cursor := walker.Cursor(); cursor != "" {
+		result["cursor"] = cursor
+	}
+	return result, nil
+}
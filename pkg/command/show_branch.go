@@ -0,0 +1,186 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/showbranch"
+)
+
+func ShowBranchCommand() *Command {
+	command := newCommand("show-branch")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		more := flag.Int("more", 0, "Show this many more commits past the common ancestor")
+		mergeBase := flag.Bool("merge-base", false, "Only print the common ancestors of the branches")
+		sparse := flag.Bool("sparse", false, "Also show the merges that only one branch reaches")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		names := flag.Args()
+		if len(names) == 0 {
+			if names, err = defaultShowBranches(repo); err != nil {
+				return err
+			}
+		}
+		if len(names) == 0 {
+			return errors.New("usage: got show-branch [--more=<n>] [--sparse] [--merge-base] [<rev>...]")
+		}
+		tips := make([]string, len(names))
+		for i, name := range names {
+			sha, err := objects.Find(repo, name, objects.TypeCommit, true)
+			if err != nil {
+				return fmt.Errorf("bad sha1 reference %s", name)
+			}
+			tips[i] = sha.AsString()
+		}
+		history := commitHistory{repo}
+
+		if *mergeBase {
+			bases, err := showbranch.MergeBases(history, tips)
+			if err != nil {
+				return err
+			}
+			if len(bases) == 0 {
+				return errors.New("no common ancestor")
+			}
+			for _, base := range bases {
+				fmt.Println(base)
+			}
+			return nil
+		}
+
+		commits, err := showbranch.Compare(history, tips, names, showbranch.Options{More: *more, Sparse: *sparse})
+		if err != nil {
+			return err
+		}
+		// The branch HEAD is on is marked with a *
+		head := -1
+		if target, ok := references.Reference("HEAD").Target(repo); ok {
+			branch := strings.TrimPrefix(target.String(), "refs/heads/")
+			headSha, _ := target.Resolve(repo)
+			for i, name := range names {
+				if (name == branch || name == "heads/"+branch || name == target.String()) && tips[i] == headSha {
+					head = i
+				}
+			}
+		}
+
+		if len(names) > 1 {
+			for i, name := range names {
+				mark := '!'
+				if i == head {
+					mark = '*'
+				}
+				subject, err := showBranchSubject(repo, tips[i])
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s%c [%s] %s\n", strings.Repeat(" ", i), mark, name, subject)
+			}
+			fmt.Println(strings.Repeat("-", len(names)))
+		}
+		for _, c := range commits {
+			if len(names) > 1 {
+				marks := make([]byte, len(names))
+				for i, reached := range c.Reach {
+					switch {
+					case !reached:
+						marks[i] = ' '
+					case len(c.Parents) > 1:
+						marks[i] = '-'
+					case i == head:
+						marks[i] = '*'
+					default:
+						marks[i] = '+'
+					}
+				}
+				fmt.Printf("%s ", marks)
+			}
+			name := c.Name
+			if name == "" {
+				sha, err := hashing.NewShaFromHex(c.SHA)
+				if err != nil {
+					return err
+				}
+				name = objects.ShortSHA(repo, sha, objects.DefaultAbbrev)
+			}
+			subject, err := showBranchSubject(repo, c.SHA)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("[%s] %s\n", name, subject)
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Show how branches relate: the commits each one has that the others don't, down to their common ancestor"
+	}
+	return command
+}
+
+// defaultShowBranches returns the branches to show without arguments:
+// showbranch.default, or else all local branches
+func defaultShowBranches(repo *repository.Repository) ([]string, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if configured := cfg.GetAll("showbranch", "default"); len(configured) > 0 {
+		return configured, nil
+	}
+	refs, err := references.NewRefStore(repo).List("refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, ref := range refs {
+		names = append(names, strings.TrimPrefix(ref.Name.String(), "refs/heads/"))
+	}
+	return names, nil
+}
+
+// showBranchSubject returns the subject of a commit, without the
+// "[PATCH] " that patches sent by mail start with
+func showBranchSubject(repo *repository.Repository, sha string) (string, error) {
+	hash, err := hashing.NewShaFromHex(sha)
+	if err != nil {
+		return "", err
+	}
+	subject, err := commitSubject(repo, hash)
+	return strings.TrimPrefix(subject, "[PATCH] "), err
+}
+
+// commitHistory reads the commits that a walk goes through from the
+// repository
+type commitHistory struct {
+	repo *repository.Repository
+}
+
+func (h commitHistory) Commit(sha string) ([]string, int64, error) {
+	hash, err := hashing.NewShaFromHex(sha)
+	if err != nil {
+		return nil, 0, err
+	}
+	commit, err := readCommit(h.repo, hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	parents := []string{}
+	for _, parent := range commit.GetValues("parent") {
+		parents = append(parents, string(parent))
+	}
+	committer, _ := commit.GetValue("committer")
+	return parents, objects.ParseIdent(committer).When.Unix(), nil
+}
@@ -1,26 +1,56 @@
 package command
 
 import (
+	"flag"
 	"fmt"
 
+	"github.com/jessegeens/got/pkg/format"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
 )
 
+var showRefFields = []string{"objectname", "objecttype", "refname"}
+
 func ShowRefCommand() *Command {
 	command := newCommand("show-ref")
 	command.Action = func(args []string) error {
+		command.ResetFlags()
+		formatString := flag.String("format", "%(objectname) %(refname)", "Format of each line, e.g. %(objectname:short) %(refname:short)")
+		dereference := flag.Bool("dereference", false, "Also show the object annotated tags point to, as <ref>^{}")
+		shortDereference := flag.Bool("d", false, "Also show the object annotated tags point to, as <ref>^{}")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		tmpl, err := format.Parse(*formatString, showRefFields)
+		if err != nil {
+			return err
+		}
+
 		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
 
-		refs, err := references.List(repo)
+		refs, err := references.All(repo)
 		if err != nil {
 			return err
 		}
 
-		showRefs(repo, true, refs, "")
+		for _, ref := range refs {
+			fmt.Println(tmpl.Expand(refFields(repo, ref)))
+			if !*dereference && !*shortDereference {
+				continue
+			}
+			peeled, err := peeledRef(repo, ref)
+			if err != nil {
+				return err
+			}
+			if peeled != "" {
+				fmt.Println(tmpl.Expand(refFields(repo, references.Ref{Name: ref.Name + "^{}", SHA: peeled})))
+			}
+		}
 
 		return nil
 	}
@@ -28,26 +58,26 @@ func ShowRefCommand() *Command {
 	return command
 }
 
-func showRefs(repo *repository.Repository, withHash bool, refs map[references.Reference]any, prefix string) {
-	for k, v := range refs {
-		switch v := v.(type) {
-		case string:
-			withHashPrefix := ""
-			if withHash {
-				withHashPrefix = " "
-			}
-			if prefix != "" {
-				fmt.Printf("%s/%s", withHashPrefix, k)
-			} else {
-				fmt.Printf("%s%s", withHashPrefix, k)
+// refFields provides the format fields of a ref. The object
+// is only read if the format needs its type.
+func refFields(repo *repository.Repository, ref references.Ref) format.Fields {
+	return format.Func(func(name string) (string, bool) {
+		switch name {
+		case "objectname":
+			return ref.SHA, true
+		case "refname":
+			return ref.Name.String(), true
+		case "objecttype":
+			sha, err := hashing.NewShaFromHex(ref.SHA)
+			if err != nil {
+				return "", false
 			}
-		case map[references.Reference]any:
-			if prefix != "" {
-				prefix = fmt.Sprintf("%s/%s", prefix, k)
-			} else {
-				prefix = k.String()
+			obj, err := objects.ReadObject(repo, sha)
+			if err != nil {
+				return "", false
 			}
-			showRefs(repo, withHash, v, prefix)
+			return obj.Type().String(), true
 		}
-	}
+		return "", false
+	})
 }
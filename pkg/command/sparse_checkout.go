@@ -0,0 +1,171 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/sparse"
+)
+
+func SparseCheckoutCommand() *Command {
+	command := newCommand("sparse-checkout")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		if len(args) == 0 {
+			return errors.New("usage: got sparse-checkout (set|add|list|disable) [--no-sparse-index] [<directory>...]")
+		}
+		subcommand := args[0]
+		noSparseIndex := flag.Bool("no-sparse-index", false, "Keep every file in the index, instead of a single entry per directory outside the cone")
+		if err := flag.CommandLine.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		switch subcommand {
+		case "set":
+			return sparseCheckoutSet(repo, flag.Args(), !*noSparseIndex)
+		case "add":
+			cone, err := sparse.Load(repo)
+			if err != nil {
+				return err
+			}
+			if cone == nil {
+				return errors.New("no sparse-checkout to add to")
+			}
+			settings, err := sparse.ReadSettings(repo)
+			if err != nil {
+				return err
+			}
+			return sparseCheckoutSet(repo, slices.Concat(cone.Dirs(), flag.Args()), settings.SparseIndex)
+		case "list":
+			cone, err := sparse.Load(repo)
+			if err != nil {
+				return err
+			}
+			if cone == nil {
+				return errors.New("this worktree is not sparse")
+			}
+			for _, dir := range cone.Dirs() {
+				fmt.Println(dir)
+			}
+			return nil
+		case "disable":
+			err := sparse.WriteSettings(repo, sparse.Settings{Cone: true})
+			if err != nil {
+				return err
+			}
+			return applySparse(repo, nil)
+		}
+		return fmt.Errorf("unknown sparse-checkout subcommand: %s", subcommand)
+	}
+	command.Description = func() string {
+		return "Only check out the files in some directories, and with a sparse index, only keep those in the index"
+	}
+	return command
+}
+
+// sparseCheckoutSet makes the cone the files at the top and in dirs
+func sparseCheckoutSet(repo *repository.Repository, dirs []string, sparseIndex bool) error {
+	cone := sparse.NewCone(dirs)
+	if err := sparse.Write(repo, cone); err != nil {
+		return err
+	}
+	err := sparse.WriteSettings(repo, sparse.Settings{Enabled: true, Cone: true, SparseIndex: sparseIndex})
+	if err != nil {
+		return err
+	}
+	return applySparse(repo, cone)
+}
+
+// applySparse updates the worktree and the index to a cone, or to all files
+// if cone is nil. Files outside the cone are removed from the worktree and
+// marked skip-worktree, except when they have changes, like git does.
+// Writing the index collapses it, if it is sparse.
+func applySparse(repo *repository.Repository, cone *sparse.Cone) error {
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+	return index.Update(repo, func(idx *index.Index) error {
+		for i, e := range idx.Entries {
+			if e.FlagStage != 0 {
+				continue
+			}
+			included := cone == nil || cone.Includes(e.Name)
+			if included && e.SkipWorktree() {
+				entry, err := checkoutEntry(repo, attrs, e)
+				if err != nil {
+					return err
+				}
+				idx.Entries[i] = entry
+			} else if !included && !e.SkipWorktree() {
+				switch e.ModeType {
+				case index.ModeTypeGitlink:
+					// The directory of a submodule is only removed when it is empty
+					os.Remove(filepath.Join(repo.WorkTree(), e.Name))
+				case index.ModeTypeRegular:
+					sha, err := worktreeBlob(repo, attrs, e.Name)
+					if err != nil {
+						return err
+					}
+					if sha != nil && !sameBlob(sha, e.SHA) {
+						fmt.Fprintf(os.Stderr, "warning: not removing '%s', which has changes\n", e.Name)
+						continue
+					}
+					fallthrough
+				default:
+					if err := removeWorktreeFile(repo, e.Name); err != nil {
+						return err
+					}
+				}
+				e.SetSkipWorktree(true)
+			}
+		}
+		return nil
+	})
+}
+
+// checkoutEntry writes the file of an index entry to the worktree, and
+// returns the entry with the stat of the file and without skip-worktree
+func checkoutEntry(repo *repository.Repository, attrs *attributes.Attributes, e *index.Entry) (*index.Entry, error) {
+	fullPath := filepath.Join(repo.WorkTree(), e.Name)
+	var err error
+	switch e.ModeType {
+	case index.ModeTypeSymlink:
+		var target []byte
+		if target, err = blobContents(repo, e.SHA); err == nil {
+			if err = os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err == nil {
+				os.Remove(fullPath)
+				err = os.Symlink(string(target), fullPath)
+			}
+		}
+	case index.ModeTypeGitlink:
+		err = os.MkdirAll(fullPath, os.ModePerm)
+	default:
+		if err = writeWorktreeFile(repo, attrs, e.Name, e.SHA); err == nil {
+			err = os.Chmod(fullPath, os.FileMode(e.ModePerms))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry, err := indexEntryFromFile(repo, e.Name, e.SHA)
+	if err != nil {
+		return nil, err
+	}
+	entry.ModeType, entry.ModePerms = e.ModeType, e.ModePerms
+	entry.ExtendedFlags = e.ExtendedFlags
+	entry.SetSkipWorktree(false)
+	return entry, nil
+}
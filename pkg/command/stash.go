@@ -0,0 +1,812 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/merge"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// A stash is stored like git does it: refs/stash points to a commit whose
+// tree is the state of the worktree. Its first parent is HEAD at the time
+// of stashing, its second parent a commit holding the state of the index
+// and the optional third parent a commit holding the untracked files.
+// Older stashes are kept in the reflog of refs/stash.
+
+func StashCommand() *Command {
+	command := newCommand("stash")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		subcommand := "push"
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			subcommand = args[0]
+			args = args[1:]
+		}
+
+		includeUntracked := flag.Bool("include-untracked", false, "Also stash untracked files, and remove them from the worktree")
+		includeUntrackedShort := flag.Bool("u", false, "Shorthand for --include-untracked")
+		keepIndex := flag.Bool("keep-index", false, "Keep the changes that are already added to the index in place")
+		restoreIndex := flag.Bool("index", false, "Also restore the changes that were added to the index")
+		message := flag.String("m", "", "Description of the stash")
+		patch := flag.Bool("p", false, "With show, show the changes as a patch instead of a diffstat")
+		patchLong := flag.Bool("patch", false, "Same as -p")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		switch subcommand {
+		case "push", "save":
+			return stashPush(repo, *message, *includeUntracked || *includeUntrackedShort, *keepIndex)
+		case "list":
+			return stashList(repo)
+		case "show":
+			return stashShow(repo, stashName(flag.Args()), *patch || *patchLong)
+		case "apply":
+			return stashApply(repo, stashName(flag.Args()), false, *restoreIndex)
+		case "pop":
+			return stashApply(repo, stashName(flag.Args()), true, *restoreIndex)
+		case "branch":
+			if flag.NArg() < 1 {
+				return errors.New("usage: got stash branch <branchname> [<stash>]")
+			}
+			return stashBranch(repo, flag.Arg(0), stashName(flag.Args()[1:]))
+		case "drop":
+			return stashDrop(repo, stashName(flag.Args()))
+		}
+		return fmt.Errorf("unknown stash subcommand: %s", subcommand)
+	}
+	command.Description = func() string { return "Stash the changes in a dirty working directory away" }
+	return command
+}
+
+func stashName(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return "stash@{0}"
+}
+
+func stashPush(repo *repository.Repository, message string, includeUntracked, keepIndex bool) error {
+	head, err := references.Reference("HEAD").Resolve(repo)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return errors.New("you do not have the initial commit yet")
+	}
+	headSha, err := hashing.NewShaFromHex(head)
+	if err != nil {
+		return err
+	}
+	headTree, err := objects.HeadTree(repo)
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Read(repo)
+	if err != nil {
+		return err
+	}
+
+	// The index is recorded as is
+	indexTree, err := objects.TreeFromIndex(repo, idx)
+	if err != nil {
+		return err
+	}
+
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+
+	// For the worktree, we take the files in the index with their current contents
+	worktree := map[string]*hashing.SHA{}
+	for _, e := range idx.Entries {
+		contents, err := readWorktreeFile(repo, attrs, e.Name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		sha, err := objects.ObjectHash(contents, objects.TypeBlob, repo)
+		if err != nil {
+			return err
+		}
+		worktree[e.Name] = sha
+	}
+	worktreeTree, err := treeFromPaths(repo, worktree)
+	if err != nil {
+		return err
+	}
+
+	untracked := []string{}
+	if includeUntracked {
+		untracked, err = untrackedFiles(repo, idx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if worktreeTree.AsString() == headTree.AsString() && indexTree.AsString() == headTree.AsString() && len(untracked) == 0 {
+		fmt.Println("No local changes to save")
+		return nil
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	author, committer, err := commitIdents(cfg)
+	if err != nil {
+		return err
+	}
+
+	branch, onBranch, err := repo.GetActiveBranch()
+	if err != nil {
+		return err
+	}
+	if !onBranch {
+		branch = "(no branch)"
+	}
+	subject, err := commitSubject(repo, headSha)
+	if err != nil {
+		return err
+	}
+	description := fmt.Sprintf("%s: %s %s", branch, head[:7], subject)
+
+	indexCommit, err := objects.CreateCommit(repo, indexTree, []*hashing.SHA{headSha}, author, committer, "index on "+description)
+	if err != nil {
+		return err
+	}
+	parents := []*hashing.SHA{headSha, indexCommit}
+
+	if len(untracked) > 0 {
+		files := map[string]*hashing.SHA{}
+		for _, name := range untracked {
+			contents, err := readWorktreeFile(repo, attrs, name)
+			if err != nil {
+				return err
+			}
+			sha, err := objects.ObjectHash(contents, objects.TypeBlob, repo)
+			if err != nil {
+				return err
+			}
+			files[name] = sha
+		}
+		untrackedTree, err := treeFromPaths(repo, files)
+		if err != nil {
+			return err
+		}
+		untrackedCommit, err := objects.CreateCommit(repo, untrackedTree, nil, author, committer, "untracked files on "+description)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, untrackedCommit)
+	}
+
+	stashMessage := "WIP on " + description
+	if message != "" {
+		stashMessage = fmt.Sprintf("On %s: %s", branch, message)
+	}
+	stash, err := objects.CreateCommit(repo, worktreeTree, parents, author, committer, stashMessage)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readStashLog(repo)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, &stashEntry{sha: stash.AsString(), ident: committer.String(), message: stashMessage})
+	err = writeStashLog(repo, entries)
+	if err != nil {
+		return err
+	}
+
+	// Now we clean up: the index goes back to HEAD, unless we keep it,
+	// and the worktree follows the index
+	current := pathsFromIndex(idx)
+	target := current
+	if !keepIndex {
+		target, err = objects.MapFromTree(repo, headTree.AsString())
+		if err != nil {
+			return err
+		}
+	}
+	err = checkoutPaths(repo, attrs, worktree, target)
+	if err != nil {
+		return err
+	}
+	err = writeIndexFromPaths(repo, target)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range untracked {
+		err = removeWorktreeFile(repo, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Saved working directory and index state %s\n", stashMessage)
+	return nil
+}
+
+func stashList(repo *repository.Repository) error {
+	entries, err := readStashLog(repo)
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		fmt.Printf("stash@{%d}: %s\n", len(entries)-1-i, entries[i].message)
+	}
+	return nil
+}
+
+// stashLabels are shown after the conflict markers when applying a stash
+var stashLabels = merge.Labels{Ours: "Updated upstream", Theirs: "Stashed changes"}
+
+// stashApply merges the changes of a stash into the index and worktree.
+// Conflicting changes are written with conflict markers and recorded as
+// unmerged entries in the index, and the stash is kept.
+func stashApply(repo *repository.Repository, name string, drop, restoreIndex bool) error {
+	stashSha, parents, err := readStash(repo, name)
+	if err != nil {
+		return err
+	}
+
+	base, err := objects.MapFromTree(repo, parents[0])
+	if err != nil {
+		return err
+	}
+	stashed, err := objects.MapFromTree(repo, stashSha.AsString())
+	if err != nil {
+		return err
+	}
+	var stashedIndex map[string]*hashing.SHA
+	if restoreIndex {
+		stashedIndex, err = objects.MapFromTree(repo, parents[1])
+		if err != nil {
+			return err
+		}
+	}
+	untracked := map[string]*hashing.SHA{}
+	if len(parents) > 2 {
+		untracked, err = objects.MapFromTree(repo, parents[2])
+		if err != nil {
+			return err
+		}
+	}
+
+	idx, err := index.Read(repo)
+	if err != nil {
+		return err
+	}
+	if len(idx.Unmerged()) > 0 {
+		return errors.New("cannot apply a stash while there are unmerged files in the index")
+	}
+	current := pathsFromIndex(idx)
+
+	// The stashed index can only be restored if the files it changed
+	// are still as they were in the base commit
+	if restoreIndex {
+		for _, name := range changedPaths(base, stashedIndex) {
+			if !sameBlob(current[name], base[name]) && !sameBlob(current[name], stashedIndex[name]) {
+				return errors.New("conflicts in index, try without --index")
+			}
+		}
+	}
+
+	// Files that one side renamed are merged with the same file on the
+	// other side, wherever it ended up
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	trees, pathConflicts, err := merge.FollowRenames(
+		merge.Trees{Base: base, Ours: current, Theirs: stashed},
+		func(sha *hashing.SHA) ([]byte, error) { return blobContents(repo, sha) },
+		merge.DirectoryRenamesFromConfig(cfg),
+		stashLabels,
+	)
+	if err != nil {
+		return err
+	}
+	// Besides what the stash changed, files that moved on our side because
+	// of their renames have to be written
+	changed := changedPaths(trees.Base, trees.Theirs)
+	for _, name := range append(changedPaths(current, trees.Ours), slices.Collect(maps.Keys(pathConflicts))...) {
+		if !slices.Contains(changed, name) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	// The index holds our side of the merge. Local changes in the worktree
+	// to the files we touch would be lost, so we refuse to apply.
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+	dirty := []string{}
+	for _, name := range changed {
+		if sameBlob(trees.Ours[name], trees.Theirs[name]) && sameBlob(trees.Ours[name], current[name]) {
+			continue
+		}
+		worktreeSha, err := worktreeBlob(repo, attrs, name)
+		if err != nil {
+			return err
+		}
+		if !sameBlob(worktreeSha, current[name]) {
+			dirty = append(dirty, name)
+		}
+	}
+	for name := range untracked {
+		if fs.Exists(filepath.Join(repo.WorkTree(), name)) {
+			dirty = append(dirty, name)
+		}
+	}
+	if len(dirty) > 0 {
+		sort.Strings(dirty)
+		return fmt.Errorf("your local changes to the following files would be overwritten:\n\t%s", strings.Join(dirty, "\n\t"))
+	}
+
+	// Three-way merge of every file the stash changed, with the base commit
+	// of the stash as the common ancestor
+	merged := map[string]*hashing.SHA{}
+	conflicts := []*index.Entry{}
+	for _, name := range changed {
+		ancestor, ours, theirs := trees.Base[name], trees.Ours[name], trees.Theirs[name]
+		if c, ok := pathConflicts[name]; ok {
+			if c.Message != "" {
+				fmt.Println(c.Message)
+			}
+			conflicts = append(conflicts, conflictEntries(name, c.Stages[0], c.Stages[1], c.Stages[2])...)
+			switch {
+			case ours == nil && theirs != nil:
+				err = writeWorktreeFile(repo, attrs, name, theirs)
+			case ours != nil && theirs != nil && !sameBlob(ours, theirs):
+				fmt.Printf("Auto-merging %s\n", name)
+				_, err = mergeFile(repo, attrs, name, ancestor, ours, theirs)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch {
+		// Our version of a file that they renamed isn't at its new path yet
+		case sameBlob(ours, theirs) && sameBlob(ours, current[name]):
+			continue
+		case sameBlob(ours, ancestor):
+			merged[name] = theirs
+			if theirs == nil {
+				err = removeWorktreeFile(repo, name)
+			} else {
+				err = writeWorktreeFile(repo, attrs, name, theirs)
+			}
+		case ours == nil || theirs == nil:
+			deletedIn, modifiedIn := stashLabels.Ours, stashLabels.Theirs
+			if theirs == nil {
+				deletedIn, modifiedIn = modifiedIn, deletedIn
+			}
+			fmt.Printf("CONFLICT (modify/delete): %s deleted in %s and modified in %s.\n", name, deletedIn, modifiedIn)
+			conflicts = append(conflicts, conflictEntries(name, ancestor, ours, theirs)...)
+			if ours == nil {
+				// Like git, the modified version is left in the worktree
+				err = writeWorktreeFile(repo, attrs, name, theirs)
+			}
+		default:
+			fmt.Printf("Auto-merging %s\n", name)
+			var sha *hashing.SHA
+			sha, err = mergeFile(repo, attrs, name, ancestor, ours, theirs)
+			if err == nil && sha != nil {
+				merged[name] = sha
+			} else if err == nil {
+				fmt.Printf("CONFLICT (content): Merge conflict in %s\n", name)
+				conflicts = append(conflicts, conflictEntries(name, ancestor, ours, theirs)...)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for name, sha := range untracked {
+		err = writeWorktreeFile(repo, attrs, name, sha)
+		if err != nil {
+			return err
+		}
+	}
+
+	target := map[string]*hashing.SHA{}
+	for name, sha := range current {
+		target[name] = sha
+	}
+
+	if len(conflicts) > 0 {
+		// Like after a merge, the clean results are staged, next to the
+		// stages of the conflicting files
+		for name, sha := range merged {
+			if sha == nil {
+				delete(target, name)
+			} else {
+				target[name] = sha
+			}
+		}
+		for _, e := range conflicts {
+			delete(target, e.Name)
+		}
+		entries, err := indexEntriesFromPaths(repo, target)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, conflicts...)
+		err = index.New(entries).Write(repo)
+		if err != nil {
+			return err
+		}
+
+		if restoreIndex {
+			fmt.Println("Index was not unstashed.")
+		}
+		if drop {
+			fmt.Println("The stash entry is kept in case you need it again.")
+		}
+		return errors.New("conflicts while applying the stash, fix them and use got add to mark them as resolved")
+	}
+
+	if restoreIndex {
+		for _, name := range changedPaths(base, stashedIndex) {
+			if sha, ok := stashedIndex[name]; ok {
+				target[name] = sha
+			} else {
+				delete(target, name)
+			}
+		}
+	} else {
+		// Files that were added or removed in the stash are added or removed
+		// in the index as well, so they don't show up as untracked
+		for _, name := range changed {
+			_, inBase := trees.Base[name]
+			_, inStash := trees.Theirs[name]
+			_, inCurrent := current[name]
+			if !inCurrent && inStash {
+				target[name] = merged[name]
+			} else if inBase && !inStash {
+				delete(target, name)
+			}
+		}
+	}
+	err = writeIndexFromPaths(repo, target)
+	if err != nil {
+		return err
+	}
+
+	if drop {
+		return stashDrop(repo, name)
+	}
+	return nil
+}
+
+// mergeFile merges the changes to a file and writes the result to the
+// worktree. It returns the blob of the result, or nil if there are conflicts.
+func mergeFile(repo *repository.Repository, attrs *attributes.Attributes, name string, ancestor, ours, theirs *hashing.SHA) (*hashing.SHA, error) {
+	contents := [][]byte{}
+	for _, sha := range []*hashing.SHA{ancestor, ours, theirs} {
+		data, err := blobContents(repo, sha)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, data)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	driver, err := merge.DriverFor(cfg, attrs, name, repo.WorkTree())
+	if err != nil {
+		return nil, err
+	}
+	result, err := driver.Merge(name, contents[0], contents[1], contents[2], stashLabels)
+	if err != nil {
+		return nil, err
+	}
+	if result.Binary {
+		fmt.Fprintf(os.Stderr, "warning: Cannot merge binary files: %s (%s vs. %s)\n", name, stashLabels.Ours, stashLabels.Theirs)
+	}
+	if err := writeWorktreeContents(repo, attrs, name, result.Content); err != nil {
+		return nil, err
+	}
+	if result.Conflicts > 0 {
+		return nil, nil
+	}
+	return objects.ObjectHash(result.Content, objects.TypeBlob, repo)
+}
+
+// stashBranch creates a branch at the commit a stash was based on, and
+// applies the stash there, so it applies without conflicts
+func stashBranch(repo *repository.Repository, branch, name string) error {
+	ref := references.Reference("refs/heads/" + branch)
+	if strings.HasPrefix(branch, "-") || references.CheckName(ref.String(), references.CheckOptions{}) != nil {
+		return fmt.Errorf("'%s' is not a valid branch name", branch)
+	}
+	existing, err := ref.Resolve(repo)
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		return fmt.Errorf("a branch named '%s' already exists", branch)
+	}
+
+	_, parents, err := readStash(repo, name)
+	if err != nil {
+		return err
+	}
+	base, err := objects.MapFromTree(repo, parents[0])
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Read(repo)
+	if err != nil {
+		return err
+	}
+	if len(idx.Unmerged()) > 0 {
+		return errors.New("cannot switch branches while there are unmerged files in the index")
+	}
+	current := pathsFromIndex(idx)
+	headTree, err := objects.HeadTree(repo)
+	if err != nil {
+		return err
+	}
+	head, err := objects.MapFromTree(repo, headTree.AsString())
+	if err != nil {
+		return err
+	}
+
+	// Local changes are carried over to the new branch, unless they are
+	// to files that differ between HEAD and the base commit
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return err
+	}
+	changed := changedPaths(current, base)
+	dirty := []string{}
+	for _, name := range changed {
+		worktreeSha, err := worktreeBlob(repo, attrs, name)
+		if err != nil {
+			return err
+		}
+		if !sameBlob(current[name], head[name]) || !sameBlob(worktreeSha, current[name]) {
+			dirty = append(dirty, name)
+		}
+	}
+	if len(dirty) > 0 {
+		return fmt.Errorf("your local changes to the following files would be overwritten by checkout:\n\t%s", strings.Join(dirty, "\n\t"))
+	}
+
+	target := map[string]*hashing.SHA{}
+	for name, sha := range current {
+		target[name] = sha
+	}
+	for _, name := range changed {
+		if sha, ok := base[name]; ok {
+			target[name] = sha
+		} else {
+			delete(target, name)
+		}
+	}
+	err = checkoutPaths(repo, attrs, current, target)
+	if err != nil {
+		return err
+	}
+	err = writeIndexFromPaths(repo, target)
+	if err != nil {
+		return err
+	}
+
+	err = references.Update(repo, ref, parents[0])
+	if err != nil {
+		return err
+	}
+	err = references.UpdateSymbolic(repo, "HEAD", ref)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Switched to a new branch '%s'\n", branch)
+
+	return stashApply(repo, name, true, true)
+}
+
+// readStash returns the commit of a stash and its parents
+func readStash(repo *repository.Repository, name string) (*hashing.SHA, []string, error) {
+	entries, err := readStashLog(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	position, err := stashPosition(entries, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	stashSha, err := hashing.NewShaFromHex(entries[position].sha)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := objects.ReadObject(repo, stashSha)
+	if err != nil {
+		return nil, nil, err
+	}
+	stash, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a stash commit", name)
+	}
+	parents := []string{}
+	for _, parent := range stash.GetValues("parent") {
+		parents = append(parents, string(parent))
+	}
+	if len(parents) < 2 {
+		return nil, nil, fmt.Errorf("%s is not a stash commit", name)
+	}
+	return stashSha, parents, nil
+}
+
+// stashShow shows the changes recorded in a stash, compared to the commit
+// it was made on
+func stashShow(repo *repository.Repository, name string, patch bool) error {
+	stashSha, parents, err := readStash(repo, name)
+	if err != nil {
+		return err
+	}
+	from, err := treeSide(repo, parents[0])
+	if err != nil {
+		return err
+	}
+	to, err := treeSide(repo, stashSha.AsString())
+	if err != nil {
+		return err
+	}
+
+	if patch {
+		return writeDiff(os.Stdout, repo, from, to, diff.DefaultContext, false)
+	}
+	stats, err := diffStat(from, to)
+	if err != nil || len(stats) == 0 {
+		return err
+	}
+	return diff.WriteStat(os.Stdout, stats, diff.DefaultStatWidth)
+}
+
+func stashDrop(repo *repository.Repository, name string) error {
+	entries, err := readStashLog(repo)
+	if err != nil {
+		return err
+	}
+	position, err := stashPosition(entries, name)
+	if err != nil {
+		return err
+	}
+	dropped := entries[position]
+	entries = append(entries[:position], entries[position+1:]...)
+	err = writeStashLog(repo, entries)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Dropped %s (%s)\n", name, dropped.sha)
+
+	// The stash commit is still there, so it can be brought back
+	record := newUndoRecord("stash drop")
+	record.saveStash(len(entries)-position, dropped)
+	return record.write(repo)
+}
+
+type stashEntry struct {
+	sha     string
+	ident   string
+	message string
+}
+
+var stashNameRegex = regexp.MustCompile(`^(?:stash@\{)?(\d+)\}?$`)
+
+// stashPosition returns the position in entries of a stash called stash@{n}, or just n
+func stashPosition(entries []*stashEntry, name string) (int, error) {
+	matches := stashNameRegex.FindStringSubmatch(name)
+	if matches == nil {
+		return 0, fmt.Errorf("%s is not a valid stash reference", name)
+	}
+	n, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, err
+	}
+	if n >= len(entries) {
+		if len(entries) == 0 {
+			return 0, errors.New("no stash entries found")
+		}
+		return 0, fmt.Errorf("%s does not exist, there are only %d stash entries", name, len(entries))
+	}
+	return len(entries) - 1 - n, nil
+}
+
+// readStashLog reads the reflog of refs/stash, oldest entry first
+func readStashLog(repo *repository.Repository) ([]*stashEntry, error) {
+	data, err := os.ReadFile(repo.RepositoryPath("logs", "refs", "stash"))
+	if errors.Is(err, os.ErrNotExist) {
+		return []*stashEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entries := []*stashEntry{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		// <old sha> <new sha> <ident> <timestamp> <timezone>\t<message>
+		header, message, _ := strings.Cut(line, "\t")
+		fields := strings.SplitN(header, " ", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		entries = append(entries, &stashEntry{sha: fields[1], ident: fields[2], message: message})
+	}
+	return entries, nil
+}
+
+// writeStashLog writes the reflog of refs/stash, and points
+// refs/stash to the newest entry
+func writeStashLog(repo *repository.Repository, entries []*stashEntry) error {
+	logFile := repo.RepositoryPath("logs", "refs", "stash")
+	if len(entries) == 0 {
+		os.Remove(logFile)
+		return references.Delete(repo, "refs/stash")
+	}
+
+	var log strings.Builder
+	previous := strings.Repeat("0", 40)
+	for _, e := range entries {
+		fmt.Fprintf(&log, "%s %s %s\t%s\n", previous, e.sha, e.ident, e.message)
+		previous = e.sha
+	}
+
+	if _, err := repo.RepositoryDir(true, "logs", "refs"); err != nil {
+		return err
+	}
+	if err := fs.WriteStringToFile(logFile, log.String()); err != nil {
+		return err
+	}
+	return references.Update(repo, "refs/stash", previous)
+}
+
+func commitSubject(repo *repository.Repository, sha *hashing.SHA) (string, error) {
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return "", err
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return "", fmt.Errorf("%s is not a commit", sha.AsString())
+	}
+	subject, _, _ := strings.Cut(commit.Message(), "\n")
+	return subject, nil
+}
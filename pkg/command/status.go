@@ -1,47 +1,76 @@
 package command
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	iofs "io/fs"
+	"maps"
 	"os"
 	"path"
-	"path/filepath"
+	"slices"
 	"strings"
 
-	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/format"
+	"github.com/jessegeens/got/pkg/hashing"
 	"github.com/jessegeens/got/pkg/ignore"
 	"github.com/jessegeens/got/pkg/index"
 	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/worktree"
 )
 
 func StatusCommand() *Command {
 	command := newCommand("status")
 	command.Action = func(args []string) error {
-		repo, err := repository.Find(".")
-		if err != nil {
+		command.ResetFlags()
+		watch := flag.Bool("watch", false, "Keep showing the status, and show it again when files change")
+		porcelain := flag.Bool("porcelain", false, "Show a line per changed file, in a format for scripts")
+		nul := flag.Bool("z", false, "End entries with NUL instead of newline, and don't quote paths; implies --porcelain")
+		if err := flag.CommandLine.Parse(args); err != nil {
 			return err
 		}
-
-		idx, err := index.Read(repo)
-		if err != nil {
-			return err
+		if *watch && (*porcelain || *nul) {
+			return errors.New("--watch can't be used with --porcelain or -z")
 		}
 
-		err = statusBranch(repo)
+		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
-		err = statusHeadIndex(repo, idx)
-		if err != nil {
-			return err
+		if *watch {
+			return watchStatus(repo)
+		}
+		if *porcelain || *nul {
+			return showPorcelainStatus(repo, format.NewRecords(os.Stdout, *nul))
 		}
-		return statusIndexWorktree(repo, idx)
+		return showStatus(repo, nil)
 	}
 	command.Description = func() string { return "Show the working tree status" }
 	return command
 }
 
+// showStatus prints the status. The files in the worktree are walked,
+// unless they are given.
+func showStatus(repo *repository.Repository, files map[string]worktree.Entry) error {
+	idx, err := index.ReadSparse(repo)
+	if err != nil {
+		return err
+	}
+
+	err = statusBranch(repo)
+	if err != nil {
+		return err
+	}
+	err = statusHeadIndex(repo, idx)
+	if err != nil {
+		return err
+	}
+	statusUnmerged(idx)
+	return statusIndexWorktree(repo, idx, files)
+}
+
 func statusBranch(repo *repository.Repository) error {
 	branch, onBranch, err := repo.GetActiveBranch()
 	if err != nil {
@@ -49,6 +78,10 @@ func statusBranch(repo *repository.Repository) error {
 	}
 	if onBranch {
 		fmt.Printf("On branch %s\n\n", branch)
+		// The branch may be packed, so we resolve it instead of reading its file
+		if sha, err := references.Reference("refs/heads/" + branch).Resolve(repo); err != nil || sha == "" {
+			fmt.Printf("No commits yet\n\n")
+		}
 	} else {
 		obj, err := objects.Find(repo, "HEAD", objects.TypeNoTypeSpecified, true)
 		if err != nil {
@@ -60,125 +93,313 @@ func statusBranch(repo *repository.Repository) error {
 	return nil
 }
 
+// statusCodes are the letters of the changes in the porcelain format
+var statusCodes = map[string]byte{"added": 'A', "modified": 'M', "deleted": 'D'}
+
+// showPorcelainStatus prints the status like git status --porcelain: a
+// line "XY path" per changed file, where X is the change in the index and
+// Y the change in the worktree, followed by "?? path" for untracked files
+func showPorcelainStatus(repo *repository.Repository, records *format.Records) error {
+	idx, err := index.ReadSparse(repo)
+	if err != nil {
+		return err
+	}
+	staged, err := stagedChanges(repo, idx)
+	if err != nil {
+		return err
+	}
+	unstaged, untracked, err := unstagedChanges(repo, idx, nil)
+	if err != nil {
+		return err
+	}
+
+	codes := map[string][]byte{}
+	for _, change := range unmergedChanges(idx) {
+		codes[change.Path] = []byte(unmergedCodes[change.Change])
+	}
+	for _, change := range staged {
+		codes[change.Path] = []byte{statusCodes[change.Change], ' '}
+	}
+	for _, change := range unstaged {
+		if _, ok := codes[change.Path]; !ok {
+			codes[change.Path] = []byte{' ', ' '}
+		}
+		codes[change.Path][1] = statusCodes[change.Change]
+	}
+	for _, name := range slices.Sorted(maps.Keys(codes)) {
+		if err := records.Write(string(codes[name]) + " " + records.Path(name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range untracked {
+		if err := records.Write("?? " + records.Path(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileChange is a changed file, as status shows it
+type fileChange struct {
+	Path string `json:"path"`
+	// Change is "added", "modified" or "deleted"
+	Change string `json:"change"`
+}
+
 // We compare HEAD to the index
 func statusHeadIndex(repo *repository.Repository, idx *index.Index) error {
-	head, err := objects.MapFromTree(repo, "HEAD")
+	changes, err := stagedChanges(repo, idx)
 	if err != nil {
-		fmt.Printf("No commits yet\n\n")
+		return err
 	}
-
-	if len(idx.Entries) > 0 || len(head) > 0 {
+	if len(changes) > 0 {
 		fmt.Println("Changes to be committed:")
+		for _, change := range changes {
+			fmt.Printf("  %s: %s\n", change.Change, change.Path)
+		}
+	}
+	return nil
+}
+
+// unmergedCodes are the XY codes of the porcelain format for the kinds of
+// conflicts, by the stages that are in the index: 1 for the common
+// ancestor, 2 for ours and 3 for theirs
+var unmergedCodes = map[string]string{
+	"both modified":   "UU",
+	"both added":      "AA",
+	"both deleted":    "DD",
+	"added by us":     "AU",
+	"added by them":   "UA",
+	"deleted by us":   "DU",
+	"deleted by them": "UD",
+}
+
+// unmergedKinds are the kinds of conflicts by their stages, as bits
+var unmergedKinds = map[int]string{
+	0b111: "both modified",
+	0b110: "both added",
+	0b001: "both deleted",
+	0b010: "added by us",
+	0b100: "added by them",
+	0b101: "deleted by us",
+	0b011: "deleted by them",
+}
+
+// unmergedChanges returns the files with conflicts, once per file, with
+// the kind of conflict as the change
+func unmergedChanges(idx *index.Index) []fileChange {
+	stages := map[string]int{}
+	for _, entry := range idx.Entries {
+		if entry.FlagStage != 0 {
+			stages[entry.Name] |= 1 << (entry.FlagStage - 1)
+		}
+	}
+	changes := []fileChange{}
+	for _, name := range idx.Unmerged() {
+		changes = append(changes, fileChange{name, unmergedKinds[stages[name]]})
+	}
+	return changes
+}
+
+// statusUnmerged prints the files with conflicts
+func statusUnmerged(idx *index.Index) {
+	changes := unmergedChanges(idx)
+	if len(changes) > 0 {
+		fmt.Println("\nUnmerged paths:")
+		for _, change := range changes {
+			fmt.Printf("  %s: %s\n", change.Change, change.Path)
+		}
 	}
+}
 
+// stagedChanges returns the files that differ between HEAD and the index.
+// The directories of a sparse index are compared to the trees in HEAD, and
+// only expanded when they differ.
+func stagedChanges(repo *repository.Repository, idx *index.Index) ([]fileChange, error) {
+	// Without commits, HEAD is the empty tree, so everything in the index is new
+	headTree, err := objects.HeadTree(repo)
+	if err != nil {
+		return nil, err
+	}
+	sparseDirs := map[string]bool{}
 	for _, entry := range idx.Entries {
-		if sha, ok := head[entry.Name]; ok {
-			if sha.AsString() != entry.SHA.AsString() {
-				fmt.Printf("  modified: %s\n", entry.Name)
+		if entry.IsSparseDir() {
+			sparseDirs[entry.Name] = true
+		}
+	}
+	head, err := sparseTreeMap(repo, headTree.AsString(), "", sparseDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []fileChange{}
+	compare := func(name string, sha *hashing.SHA) {
+		if headSha, ok := head[name]; ok {
+			if headSha.AsString() != sha.AsString() {
+				changes = append(changes, fileChange{name, "modified"})
 			}
-			delete(head, entry.Name)
+			delete(head, name)
 		} else {
-			fmt.Printf("  added: %s\n", entry.Name)
+			changes = append(changes, fileChange{name, "added"})
+		}
+	}
+	for _, entry := range idx.Entries {
+		// Files with conflicts are unmerged, not staged
+		if entry.FlagStage != 0 {
+			delete(head, entry.Name)
+			continue
+		}
+		if !entry.IsSparseDir() {
+			compare(entry.Name, entry.SHA)
+			continue
+		}
+		headSha, ok := head[entry.Name]
+		if ok && headSha.AsString() == entry.SHA.AsString() {
+			delete(head, entry.Name)
+			continue
+		}
+		// The directory changed, so we compare the files in it
+		dir := strings.TrimSuffix(entry.Name, "/")
+		if ok {
+			delete(head, entry.Name)
+			files, err := sparseTreeMap(repo, headSha.AsString(), dir, nil)
+			if err != nil {
+				return nil, err
+			}
+			maps.Copy(head, files)
+		}
+		files, err := sparseTreeMap(repo, entry.SHA.AsString(), dir, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range slices.Sorted(maps.Keys(files)) {
+			compare(name, files[name])
 		}
 	}
 
-	for path := range head {
-		fmt.Printf("  deleted: %s\n", path)
+	for _, path := range slices.Sorted(maps.Keys(head)) {
+		changes = append(changes, fileChange{path, "deleted"})
 	}
-	return nil
+	return changes, nil
 }
 
-func statusIndexWorktree(repo *repository.Repository, idx *index.Index) error {
-	ignore, err := ignore.Read(repo)
+// sparseTreeMap returns the files of a tree by their full paths, like
+// objects.MapFromTree. The directories in sparseDirs, whose names end with
+// a slash, are not walked but returned with the hash of their tree.
+func sparseTreeMap(repo *repository.Repository, treeRef, prefix string, sparseDirs map[string]bool) (map[string]*hashing.SHA, error) {
+	files := map[string]*hashing.SHA{}
+	sha, err := hashing.NewShaFromHex(treeRef)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	tree, ok := obj.(*objects.Tree)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a tree", treeRef)
+	}
+	for _, leaf := range tree.Items {
+		name := path.Join(prefix, string(leaf.Path))
+		if !strings.HasPrefix(string(leaf.Mode), "04") {
+			files[name] = leaf.Sha
+			continue
+		}
+		if sparseDirs[name+"/"] {
+			files[name+"/"] = leaf.Sha
+			continue
+		}
+		inner, err := sparseTreeMap(repo, leaf.Sha.AsString(), name, sparseDirs)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(files, inner)
 	}
+	return files, nil
+}
 
-	// We begin by walking the filesystem
-	gitDirPrefix := repo.GitDir() + string(os.PathSeparator)
-	allFiles := []string{}
-	err = filepath.WalkDir(repo.WorkTree(), func(path string, d iofs.DirEntry, e error) error {
-		// Skip whatever is in .git
-		if strings.HasPrefix(path, gitDirPrefix) {
-			return nil
+func statusIndexWorktree(repo *repository.Repository, idx *index.Index, files map[string]worktree.Entry) error {
+	changes, untracked, err := unstagedChanges(repo, idx, files)
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		fmt.Println("\nChanges not staged for commit:")
+		for _, change := range changes {
+			fmt.Printf("  %s: %s\n", change.Change, change.Path)
 		}
+	}
+	if len(untracked) > 0 {
+		fmt.Println("\nUntracked files:")
+		for _, file := range untracked {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+	return nil
+}
+
+// unstagedChanges returns the files that differ between the index and the
+// worktree, and the untracked files. files are the files in the worktree,
+// which are walked if it is nil; it is changed.
+func unstagedChanges(repo *repository.Repository, idx *index.Index, files map[string]worktree.Entry) ([]fileChange, []string, error) {
+	attrs, err := attributes.Read(repo)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		relativePath, err := filepath.Rel(repo.WorkTree(), path)
+	// We begin by walking the filesystem. Ignored files are
+	// skipped, so they never show up as untracked
+	if files == nil {
+		ign, err := ignore.Read(repo)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		if relativePath == ".git" || relativePath == "." {
+		files = map[string]worktree.Entry{}
+		err = worktree.Walk(repo, worktree.Options{Ignore: ign}, func(entry worktree.Entry) error {
+			files[entry.Path] = entry
 			return nil
+		})
+		if err != nil {
+			return nil, nil, err
 		}
-		allFiles = append(allFiles, relativePath)
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 
-	hasPrinted := false
-
 	// Now we traverse the index and compare real files with the cached versions
+	changes := []fileChange{}
 	for _, entry := range idx.Entries {
-		fullPath := path.Join(repo.WorkTree(), entry.Name)
-		if !fs.Exists(fullPath) {
-			if !hasPrinted {
-				fmt.Println("\nChanges not staged for commit:")
-				hasPrinted = true
+		// Files outside the sparse-checkout cone aren't in the worktree,
+		// and files with conflicts are unmerged
+		if entry.SkipWorktree() || entry.FlagStage != 0 {
+			delete(files, entry.Name)
+			continue
+		}
+		file, ok := files[entry.Name]
+		if !ok {
+			// Tracked files can be in an ignored directory
+			info, err := os.Lstat(path.Join(repo.WorkTree(), entry.Name))
+			if err == nil {
+				file, ok = worktree.Entry{Path: entry.Name, Info: info}, true
 			}
-			fmt.Printf("  deleted: %s\n", entry.Name)
-		} else {
-			finfo, err := os.Stat(fullPath)
+		}
+
+		if !ok {
+			changes = append(changes, fileChange{entry.Name, "deleted"})
+		} else if !file.Info.ModTime().Equal(entry.MTime) || file.Info.Size() != int64(entry.Size) {
+			// Let's do a deep compare
+			newSha, err := worktreeBlob(repo, attrs, entry.Name)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
 
-			if finfo.ModTime() != entry.MTime {
-				// Let's do a deep compare
-				content, err := os.ReadFile(fullPath)
-				if err != nil {
-					return err
-				}
-				newSha, err := objects.ObjectHash(content, objects.TypeBlob, repo)
-				if err != nil {
-					return err
-				}
-
-				if newSha != entry.SHA {
-					if !hasPrinted {
-						fmt.Println("\nChanges not staged for commit:")
-						hasPrinted = true
-					}
-					fmt.Printf("  modified: %s\n", entry.Name)
-				}
+			if !sameBlob(newSha, entry.SHA) {
+				changes = append(changes, fileChange{entry.Name, "modified"})
 			}
 		}
-		allFiles, _ = deleteFromSlice(allFiles, entry.Name)
+		delete(files, entry.Name)
 	}
 
-	// Everything that's left in allFiles was not found in the index,
+	// Everything that's left in files was not found in the index,
 	// so those files are not tracked
-	hasPrinted = false
-	for _, file := range allFiles {
-		if !ignore.ShouldBeIgnored(file) {
-			if !hasPrinted {
-				fmt.Println("\nUntracked files:")
-				hasPrinted = true
-			}
-			fmt.Printf("  %s\n", file)
-		}
-	}
-
-	return nil
-}
-
-// Delete first occurence of entry from slice, if it exists
-func deleteFromSlice[K comparable](slice []K, elem K) ([]K, bool) {
-	for idx, arrElem := range slice {
-		if arrElem == elem {
-			return append(slice[:idx], slice[idx+1:]...), true
-		}
-	}
-	return slice, false
+	return changes, slices.Sorted(maps.Keys(files)), nil
 }
@@ -0,0 +1,168 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jessegeens/got/pkg/ignore"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/worktree"
+)
+
+// statusDebounce is how long status --watch waits for things to settle,
+// so that e.g. a checkout of many files shows the status only once
+const statusDebounce = 100 * time.Millisecond
+
+// watchStatus shows the status, and shows it again whenever files in the
+// worktree, the index or the refs change, until it is interrupted. The
+// files of the worktree are kept in a cache, so only the changed ones are
+// read again.
+func watchStatus(repo *repository.Repository) error {
+	ign, err := ignore.Read(repo)
+	if err != nil {
+		return err
+	}
+	cache, err := worktree.NewCache(repo, worktree.Options{Ignore: ign})
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watchWorktreeDirs(watcher, repo, ign, ""); err != nil {
+		return err
+	}
+	// The index, HEAD and packed-refs are in the gitdir itself, and the
+	// other refs below refs
+	if err := watcher.Add(repo.GitDir()); err != nil {
+		return err
+	}
+	if err := watchDirs(watcher, repo.RepositoryPath("refs")); err != nil {
+		return err
+	}
+
+	renderStatus(repo, cache)
+	gitdir := filepath.Clean(repo.GitDir())
+	changed := map[string]bool{}
+	rulesChanged, gitChanged := false, false
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if rel, err := filepath.Rel(gitdir, event.Name); err == nil && filepath.IsLocal(rel) {
+				// Lock files come and go while the real file is written
+				if strings.HasSuffix(rel, ".lock") {
+					continue
+				}
+				if event.Has(fsnotify.Create) && strings.HasPrefix(rel, "refs"+string(filepath.Separator)) {
+					watchDirs(watcher, event.Name)
+				}
+				rulesChanged = rulesChanged || rel == filepath.Join("info", "exclude")
+				gitChanged = true
+			} else if rel, err := filepath.Rel(repo.WorkTree(), event.Name); err == nil && filepath.IsLocal(rel) {
+				rel = filepath.ToSlash(rel)
+				if event.Has(fsnotify.Create) {
+					if err := watchWorktreeDirs(watcher, repo, ign, rel); err != nil {
+						return err
+					}
+				}
+				rulesChanged = rulesChanged || filepath.Base(rel) == ".gitignore"
+				changed[rel] = true
+			}
+			debounce = time.After(statusDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-debounce:
+			if rulesChanged {
+				if ign, err = ignore.Read(repo); err != nil {
+					return err
+				}
+				if err := cache.Reset(worktree.Options{Ignore: ign}); err != nil {
+					return err
+				}
+				// Directories that are no longer ignored need watching now
+				if err := watchWorktreeDirs(watcher, repo, ign, ""); err != nil {
+					return err
+				}
+			} else if len(changed) > 0 {
+				paths := []string{}
+				for p := range changed {
+					paths = append(paths, p)
+				}
+				slices.Sort(paths)
+				if err := cache.Refresh(paths...); err != nil {
+					return err
+				}
+			}
+			if rulesChanged || gitChanged || len(changed) > 0 {
+				renderStatus(repo, cache)
+			}
+			changed = map[string]bool{}
+			rulesChanged, gitChanged = false, false
+			debounce = nil
+		}
+	}
+}
+
+// renderStatus clears the terminal and shows the status. Errors are shown
+// instead, because they can be temporary, like a file that is removed
+// while it is read.
+func renderStatus(repo *repository.Repository, cache *worktree.Cache) {
+	fmt.Print("\033[H\033[2J")
+	if err := showStatus(repo, cache.Entries()); err != nil {
+		fmt.Printf("Failed to show the status: %s\n", err)
+	}
+	fmt.Printf("\nWatching for changes, press Ctrl-C to stop\n")
+}
+
+// watchWorktreeDirs watches the directory root of the worktree, if it is
+// one, and the directories below it that aren't ignored
+func watchWorktreeDirs(watcher *fsnotify.Watcher, repo *repository.Repository, ign *ignore.Ignore, root string) error {
+	fullPath := filepath.Join(repo.WorkTree(), root)
+	if info, err := os.Lstat(fullPath); err != nil || !info.IsDir() {
+		return nil
+	}
+	if root != "" && ign.ShouldBeIgnored(root) {
+		return nil
+	}
+	if err := watcher.Add(fullPath); err != nil {
+		return err
+	}
+	entries, err := worktree.Collect(repo, worktree.Options{Root: root, Ignore: ign, IncludeDirectories: true})
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Info.IsDir() {
+			if err := watcher.Add(filepath.Join(repo.WorkTree(), entry.Path)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// watchDirs watches dir and all directories below it
+func watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return watcher.Add(path)
+	})
+}
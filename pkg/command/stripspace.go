@@ -0,0 +1,57 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessegeens/got/pkg/stripspace"
+)
+
+func StripspaceCommand() *Command {
+	command := newCommand("stripspace")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var stripComments, commentLines bool
+		flag.BoolVar(&stripComments, "strip-comments", false, "Also remove lines starting with the comment character")
+		flag.BoolVar(&stripComments, "s", false, "Same as --strip-comments")
+		flag.BoolVar(&commentLines, "comment-lines", false, "Turn every line into a comment instead of cleaning up")
+		flag.BoolVar(&commentLines, "c", false, "Same as --comment-lines")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 || (stripComments && commentLines) {
+			return errors.New("usage: got stripspace [-s | --strip-comments | -c | --comment-lines] < input")
+		}
+
+		prefix := stripspace.DefaultCommentPrefix
+		if stripComments || commentLines {
+			// Outside a repository, only the global configuration applies
+			cfg, err := currentConfig()
+			if err != nil {
+				return err
+			}
+			prefix = stripspace.CommentPrefix(cfg)
+		}
+
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if commentLines {
+			_, err = fmt.Print(stripspace.CommentLines(string(input), prefix))
+			return err
+		}
+		if !stripComments {
+			prefix = ""
+		}
+		_, err = fmt.Print(stripspace.Strip(string(input), prefix))
+		return err
+	}
+	command.Description = func() string {
+		return "Remove trailing whitespace and extra blank lines from standard input, and optionally comments"
+	}
+	return command
+}
@@ -3,60 +3,236 @@ package command
 import (
 	"flag"
 	"fmt"
-	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/jessegeens/got/pkg/hashing"
 	"github.com/jessegeens/got/pkg/kvlm"
 	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/stripspace"
+	"github.com/jessegeens/got/pkg/wildmatch"
 )
 
 func TagCommand() *Command {
 	command := newCommand("tag")
 	command.Action = func(args []string) error {
-		create := *flag.Bool("annotate", false, "Whether to create a tag object")
-		name := *flag.String("name", "", "The new tag's name")
-		object := *flag.String("object", "HEAD", "The object the new tag will point to")
+		command.ResetFlags()
+		create := flag.Bool("annotate", false, "Whether to create a tag object")
+		var message string
+		flag.StringVar(&message, "message", "", "The message of the tag object, which implies --annotate")
+		flag.StringVar(&message, "m", "", "Same as --message")
+		name := flag.String("name", "", "The new tag's name")
+		object := flag.String("object", "HEAD", "The object the new tag will point to")
+		var list bool
+		flag.BoolVar(&list, "list", false, "List the tags, only the ones that match the given patterns if there are any")
+		flag.BoolVar(&list, "l", false, "Same as --list")
+		lines := annotationLines(0)
+		flag.Var(&lines, "n", "Show the first line of the annotation of each tag, or the first n lines with -n<n>")
+		sortKeys := stringList{}
+		flag.Var(&sortKeys, "sort", "Field to sort on, like creatordate or v:refname to sort by version. Prefix with - for descending order")
+		pointsAt := stringList{}
+		flag.Var(&pointsAt, "points-at", "Only list the tags of this object. Can be given multiple times")
+		// Like git, the number of lines can be attached to -n
+		for i, arg := range args {
+			if number, ok := strings.CutPrefix(arg, "-n"); ok && number != "" && strings.Trim(number, "0123456789") == "" {
+				args[i] = "-n=" + number
+			}
+		}
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
 
 		repo, err := repository.Find(".")
 		if err != nil {
 			return err
 		}
 
-		if name != "" {
+		if *name != "" && !list {
 			// Name is set, so we want to create a tag
-			tagCreate(repo, name, object, create)
+			return tagCreate(repo, *name, *object, *create || message != "", message)
+		}
+		return tagList(repo, flag.Args(), tagListOptions{sortKeys: sortKeys, pointsAt: pointsAt, lines: int(lines)})
+	}
+	command.Description = func() string { return "List and create tags" }
+	return command
+}
+
+// annotationLines is the number of lines of -n, which can be given
+// without a number for one line
+type annotationLines int
+
+func (n *annotationLines) String() string {
+	return strconv.Itoa(int(*n))
+}
+
+func (n *annotationLines) Set(value string) error {
+	if value == "true" {
+		*n = 1
+		return nil
+	}
+	lines, err := strconv.Atoi(value)
+	if err != nil || lines < 0 {
+		return fmt.Errorf("invalid number of lines: %s", value)
+	}
+	*n = annotationLines(lines)
+	return nil
+}
+
+func (n *annotationLines) IsBoolFlag() bool {
+	return true
+}
+
+type tagListOptions struct {
+	// sortKeys are like the ones of for-each-ref, tag.sort is used if
+	// there are none
+	sortKeys []string
+	// pointsAt are the objects to list the tags of, if there are any
+	pointsAt []string
+	// lines is the number of lines of the annotations to show
+	lines int
+}
+
+// tagList lists the tags whose names match one of the patterns, or all
+// tags if there are no patterns
+func tagList(repo *repository.Repository, patterns []string, opts tagListOptions) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	sortKeys := opts.sortKeys
+	if len(sortKeys) == 0 {
+		if key, ok := cfg.Get("tag", "sort"); ok && key != "" {
+			sortKeys = []string{key}
 		} else {
-			refs, err := references.List(repo)
-			if err != nil {
+			sortKeys = []string{"refname"}
+		}
+	}
+	if err := checkSortKeys(sortKeys); err != nil {
+		return err
+	}
+
+	targets := map[string]bool{}
+	for _, name := range opts.pointsAt {
+		sha, err := objects.Find(repo, name, objects.TypeNoTypeSpecified, true)
+		if err != nil {
+			return fmt.Errorf("malformed object name %s", name)
+		}
+		targets[sha.AsString()] = true
+	}
+
+	details := []*refDetails{}
+	err = references.NewRefStore(repo).Each("refs/tags/", func(ref references.Ref) error {
+		short := strings.TrimPrefix(ref.Name.String(), "refs/tags/")
+		if len(patterns) > 0 && !slices.ContainsFunc(patterns, func(pattern string) bool { return wildmatch.Match(pattern, short) }) {
+			return nil
+		}
+		if len(targets) > 0 {
+			if pointing, err := tagPointsAt(repo, ref.SHA, targets); err != nil || !pointing {
 				return err
 			}
-			showRefs(repo, false, refs, "")
 		}
-
+		details = append(details, &refDetails{repo: repo, ref: ref})
 		return nil
+	})
+	if err != nil {
+		return err
 	}
-	command.Description = func() string { return "List and create tags" }
-	return command
+	sortRefs(details, sortKeys, versionSuffixes(cfg))
+
+	for _, d := range details {
+		short := strings.TrimPrefix(d.ref.Name.String(), "refs/tags/")
+		if opts.lines == 0 {
+			fmt.Println(short)
+			continue
+		}
+		fmt.Printf("%-15s %s\n", short, annotation(d, opts.lines))
+	}
+	return nil
 }
 
-func tagCreate(repo *repository.Repository, name, ref string, createTagObject bool) error {
+// tagPointsAt reports whether a tag is one of the targets, or points to
+// one of them, through any number of tag objects
+func tagPointsAt(repo *repository.Repository, sha string, targets map[string]bool) (bool, error) {
+	for !targets[sha] {
+		hash, err := hashing.NewShaFromHex(sha)
+		if err != nil {
+			return false, err
+		}
+		obj, err := objects.ReadObject(repo, hash)
+		if err != nil {
+			return false, err
+		}
+		tag, ok := obj.(*objects.Tag)
+		if !ok {
+			return false, nil
+		}
+		object, _ := tag.GetValue("object")
+		sha = string(object)
+	}
+	return true, nil
+}
+
+// annotation returns the first lines of the message of a tag, without its
+// signature, or of the commit of a lightweight tag. Like git, lines after
+// the first are indented, to go below the message of the first line.
+func annotation(d *refDetails, lines int) string {
+	message := d.message()
+	if tag, ok := d.object.(*objects.Tag); ok {
+		if _, sig, signed := tag.Signature(); signed {
+			message = strings.TrimSuffix(message, string(sig))
+		}
+	}
+	result := []string{}
+	for len(result) < lines && message != "" {
+		line, rest, found := strings.Cut(message, "\n")
+		result = append(result, line)
+		if !found {
+			break
+		}
+		message = rest
+	}
+	return strings.Join(result, "\n    ")
+}
+
+// tagCreate creates a tag of ref. The message of a tag object is cleaned
+// up like git does, comments included.
+func tagCreate(repo *repository.Repository, name, ref string, createTagObject bool, message string) error {
+	// Check the name before writing a tag object for it
+	if err := references.CheckName("refs/tags/"+name, references.CheckOptions{}); err != nil {
+		return err
+	}
+
 	sha, err := objects.Find(repo, ref, objects.TypeNoTypeSpecified, true)
 	if err != nil {
 		return err
 	}
 
 	if createTagObject {
+		obj, err := objects.ReadObject(repo, sha)
+		if err != nil {
+			return err
+		}
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		tagger, err := objects.CommitterIdent(cfg)
+		if err != nil {
+			return err
+		}
 
 		tagData := kvlm.New()
 		tagData.Okv.Set("object", []byte(sha.AsString()))
-		tagData.Okv.Set("type", []byte("commit"))
-		tagData.Okv.Set("name", []byte(name))
-		// This little trick gets me so many tags $$$$$
-		// TODO: make general
-		tagData.Okv.Set("tagger", []byte("Jesse Geens"))
-		tagData.Message = []byte("A tag generated by got")
+		tagData.Okv.Set("type", []byte(obj.Type()))
+		tagData.Okv.Set("tag", []byte(name))
+		tagData.Okv.Set("tagger", []byte(tagger.String()))
+		tagData.Message = []byte("A tag generated by got\n")
+		if message != "" {
+			tagData.Message = []byte(stripspace.Strip(message, stripspace.CommentPrefix(cfg)))
+		}
 
 		tag := objects.Tag(*objects.NewCommit(tagData))
 		tagSha, err := objects.WriteObject(objects.GitObject(&tag), repo)
@@ -72,10 +248,5 @@ func tagCreate(repo *repository.Repository, name, ref string, createTagObject bo
 }
 
 func refCreate(repo *repository.Repository, refName string, sha *hashing.SHA) error {
-	path, err := repo.RepositoryFile(false, fmt.Sprintf("refs/%s", refName))
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(path, []byte(sha.AsString()+"\n"), os.ModePerm)
+	return references.Update(repo, references.Reference("refs/"+refName), sha.AsString())
 }
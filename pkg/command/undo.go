@@ -0,0 +1,348 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Commands that throw work away, like rm, restore and stash drop, record
+// what they changed in GOT_UNDO in the git directory, so got undo can
+// revert them. The worktree files they overwrite or remove are backed up
+// as blobs in the object database. Dropped stashes are still there too,
+// so putting their reflog entry back is enough. Only the last of these
+// commands can be undone.
+
+func UndoCommand() *Command {
+	command := newCommand("undo")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		record, err := readUndoRecord(repo)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return errors.New("there is nothing to undo")
+		}
+		return record.undo(repo)
+	}
+	command.Description = func() string { return "Undo the last rm, restore, checkout of paths or stash drop" }
+	return command
+}
+
+type undoRecord struct {
+	// operation is the command that is undone, like "stash drop"
+	operation string
+	files     []*undoFile
+	// entries are the index entries to put back
+	entries []*index.Entry
+	stashes []*undoStash
+}
+
+// undoFile is a worktree file before and after the operation. A nil blob
+// means there was no file.
+type undoFile struct {
+	name          string
+	perm          os.FileMode
+	before, after *hashing.SHA
+}
+
+type undoStash struct {
+	position int
+	entry    *stashEntry
+}
+
+func newUndoRecord(operation string) *undoRecord {
+	return &undoRecord{operation: operation}
+}
+
+// saveFiles backs up the worktree files that the operation is about to
+// change
+func (r *undoRecord) saveFiles(repo *repository.Repository, names []string) error {
+	for _, name := range names {
+		file := &undoFile{name: name, perm: 0o644}
+		fullPath := filepath.Join(repo.WorkTree(), name)
+		info, err := os.Lstat(fullPath)
+		if err == nil && info.Mode().IsRegular() {
+			contents, err := os.ReadFile(fullPath)
+			if err != nil {
+				return err
+			}
+			if file.before, err = objects.ObjectHash(contents, objects.TypeBlob, repo); err != nil {
+				return err
+			}
+			file.perm = info.Mode().Perm()
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		r.files = append(r.files, file)
+	}
+	return nil
+}
+
+// saveEntries remembers the index entries of the files, with all their
+// stages
+func (r *undoRecord) saveEntries(idx *index.Index, names []string) {
+	for _, e := range idx.Entries {
+		if slices.Contains(names, e.Name) {
+			r.entries = append(r.entries, e)
+		}
+	}
+}
+
+func (r *undoRecord) saveStash(position int, entry *stashEntry) {
+	r.stashes = append(r.stashes, &undoStash{position: position, entry: entry})
+}
+
+// write records the operation once it is done, replacing the one
+// recorded before
+func (r *undoRecord) write(repo *repository.Repository) error {
+	var out strings.Builder
+	fmt.Fprintf(&out, "operation %s\n", r.operation)
+	for _, f := range r.files {
+		after, err := rawWorktreeBlob(repo, f.name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&out, "file %o %s %s %s\n", f.perm, undoBlobName(f.before), undoBlobName(after), f.name)
+	}
+	for _, e := range r.entries {
+		fmt.Fprintf(&out, "index %o %d %s %s\n", uint32(e.ModeType)<<12|uint32(e.ModePerms), e.FlagStage, e.SHA.AsString(), e.Name)
+	}
+	for _, s := range r.stashes {
+		fmt.Fprintf(&out, "stash %d %s %s\t%s\n", s.position, s.entry.sha, s.entry.ident, s.entry.message)
+	}
+	return fs.WriteStringToFile(repo.RepositoryPath("GOT_UNDO"), out.String())
+}
+
+// readUndoRecord reads the last recorded operation, or returns nil if
+// there is none
+func readUndoRecord(repo *repository.Repository) (*undoRecord, error) {
+	data, err := os.ReadFile(repo.RepositoryPath("GOT_UNDO"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	record := &undoRecord{}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		kind, rest, _ := strings.Cut(scanner.Text(), " ")
+		var err error
+		switch kind {
+		case "operation":
+			record.operation = rest
+		case "file":
+			// <perm> <before> <after> <path>
+			fields := strings.SplitN(rest, " ", 4)
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("invalid undo record: %s", scanner.Text())
+			}
+			file := &undoFile{name: fields[3]}
+			var perm uint64
+			if perm, err = strconv.ParseUint(fields[0], 8, 32); err != nil {
+				return nil, err
+			}
+			file.perm = os.FileMode(perm)
+			if file.before, err = parseUndoBlob(fields[1]); err != nil {
+				return nil, err
+			}
+			if file.after, err = parseUndoBlob(fields[2]); err != nil {
+				return nil, err
+			}
+			record.files = append(record.files, file)
+		case "index":
+			// <mode> <stage> <sha> <path>
+			fields := strings.SplitN(rest, " ", 4)
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("invalid undo record: %s", scanner.Text())
+			}
+			mode, err := strconv.ParseUint(fields[0], 8, 32)
+			if err != nil {
+				return nil, err
+			}
+			stage, err := strconv.ParseUint(fields[1], 10, 16)
+			if err != nil {
+				return nil, err
+			}
+			sha, err := hashing.NewShaFromHex(fields[2])
+			if err != nil {
+				return nil, err
+			}
+			record.entries = append(record.entries, &index.Entry{
+				ModeType:  index.ModeType(mode >> 12),
+				ModePerms: uint16(mode & 0o777),
+				SHA:       sha,
+				FlagStage: uint16(stage),
+				Name:      fields[3],
+			})
+		case "stash":
+			// <position> <sha> <ident>\t<message>
+			fields := strings.SplitN(rest, " ", 3)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid undo record: %s", scanner.Text())
+			}
+			position, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			ident, message, _ := strings.Cut(fields[2], "\t")
+			record.saveStash(position, &stashEntry{sha: fields[1], ident: ident, message: message})
+		default:
+			return nil, fmt.Errorf("invalid undo record: %s", scanner.Text())
+		}
+	}
+	return record, scanner.Err()
+}
+
+// undo puts back what the operation changed. Files that changed again
+// since are left alone, and nothing is undone then.
+func (r *undoRecord) undo(repo *repository.Repository) error {
+	changed := []string{}
+	for _, f := range r.files {
+		current, err := rawWorktreeBlob(repo, f.name)
+		if err != nil {
+			return err
+		}
+		if !sameBlob(current, f.after) {
+			changed = append(changed, f.name)
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("the following files changed since got %s, so it can't be undone:\n\t%s", r.operation, strings.Join(changed, "\n\t"))
+	}
+
+	for _, f := range r.files {
+		if sameBlob(f.before, f.after) {
+			continue
+		}
+		if f.before == nil {
+			if err := removeWorktreeFile(repo, f.name); err != nil {
+				return err
+			}
+			fmt.Printf("Removed '%s'\n", f.name)
+			continue
+		}
+		contents, err := blobContents(repo, f.before)
+		if err != nil {
+			return err
+		}
+		fullPath := filepath.Join(repo.WorkTree(), f.name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(fullPath, contents, f.perm); err != nil {
+			return err
+		}
+		if err := os.Chmod(fullPath, f.perm); err != nil {
+			return err
+		}
+		fmt.Printf("Restored '%s'\n", f.name)
+	}
+
+	if len(r.entries) > 0 {
+		attrs, err := attributes.Read(repo)
+		if err != nil {
+			return err
+		}
+		err = index.Update(repo, func(idx *index.Index) error {
+			for _, e := range r.entries {
+				idx.Remove(e.Name)
+			}
+			for _, e := range r.entries {
+				// The stat data of the worktree file is only recorded when it
+				// has the contents of the entry, so other files show up as
+				// modified
+				entry := e
+				worktree, err := worktreeBlob(repo, attrs, e.Name)
+				if err != nil {
+					return err
+				}
+				if e.FlagStage == 0 && sameBlob(worktree, e.SHA) {
+					if entry, err = indexEntryFromFile(repo, e.Name, e.SHA); err != nil {
+						return err
+					}
+					entry.ModeType, entry.ModePerms = e.ModeType, e.ModePerms
+				}
+				idx.Add(entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(r.stashes) > 0 {
+		entries, err := readStashLog(repo)
+		if err != nil {
+			return err
+		}
+		// The log is oldest first, positions count from the newest
+		for _, s := range r.stashes {
+			at := max(0, len(entries)-s.position)
+			entries = slices.Insert(entries, at, s.entry)
+		}
+		if err := writeStashLog(repo, entries); err != nil {
+			return err
+		}
+		for _, s := range r.stashes {
+			fmt.Printf("Restored stash@{%d} (%s)\n", s.position, s.entry.sha)
+		}
+	}
+
+	if err := os.Remove(repo.RepositoryPath("GOT_UNDO")); err != nil {
+		return err
+	}
+	fmt.Printf("Undid got %s\n", r.operation)
+	return nil
+}
+
+// rawWorktreeBlob returns the hash of a worktree file as it is, without
+// filters, or nil if there is no such file
+func rawWorktreeBlob(repo *repository.Repository, name string) (*hashing.SHA, error) {
+	contents, err := os.ReadFile(filepath.Join(repo.WorkTree(), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	blob := &objects.Blob{}
+	blob.Deserialize(contents)
+	return objects.CalculateSha(blob)
+}
+
+func undoBlobName(sha *hashing.SHA) string {
+	if sha == nil {
+		return "-"
+	}
+	return sha.AsString()
+}
+
+func parseUndoBlob(name string) (*hashing.SHA, error) {
+	if name == "-" {
+		return nil, nil
+	}
+	return hashing.NewShaFromHex(name)
+}
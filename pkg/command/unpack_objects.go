@@ -0,0 +1,47 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func UnpackObjectsCommand() *Command {
+	command := newCommand("unpack-objects")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		dryRun := flag.Bool("n", false, "Check the pack, but don't write any objects")
+		quiet := flag.Bool("q", false, "Don't report how many objects were unpacked")
+		strict := flag.Bool("strict", false, "Refuse the pack if an object in it fails the fsck checks, even if receive.fsckObjects is off")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() > 0 {
+			return errors.New("usage: got unpack-objects [-n] [-q] [--strict] < <pack-file>")
+		}
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		check, err := incomingCheck(repo, "receive", *strict)
+		if err != nil {
+			return err
+		}
+		n, err := objects.UnpackObjects(repo, os.Stdin, *dryRun, check)
+		if err != nil {
+			return err
+		}
+		if !*quiet {
+			fmt.Printf("Unpacked %d objects\n", n)
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Write the objects of a pack read from standard input as loose objects"
+	}
+	return command
+}
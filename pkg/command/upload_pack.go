@@ -0,0 +1,255 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/pktline"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func UploadPackCommand() *Command {
+	return serverCommand("upload-pack", "uploadpack", "Send objects packed back to got fetch", true)
+}
+
+func ReceivePackCommand() *Command {
+	return serverCommand("receive-pack", "receive", "Receive what is pushed into the repository", false)
+}
+
+// serverCapabilities are the capabilities of the ref advertisement. Only
+// the advertisement is served, so it has none of the capabilities that
+// fetches and pushes ask for, only those that describe the server.
+var serverCapabilities = []string{"agent=got", "object-format=sha1"}
+
+// serverCommand creates the server side of the pack protocol. For now,
+// only the ref advertisement is supported, and for upload-pack, the
+// ls-refs command of protocol version 2.
+func serverCommand(name, section, description string, peel bool) *Command {
+	command := newCommand(name)
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		advertiseRefs := flag.Bool("advertise-refs", false, "Only print the ref advertisement and exit")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		// Only upload-pack peels, and it is the one that speaks version 2
+		v2 := peel && protocolVersion() == 2
+		if !*advertiseRefs && !v2 {
+			return errors.New("only --advertise-refs is supported")
+		}
+
+		dir := "."
+		if flag.NArg() > 0 {
+			dir = flag.Arg(0)
+		}
+		repo, err := repository.Find(dir)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		namespace := references.NamespaceFromEnv()
+		refs, err := references.Advertised(repo, namespace, references.LoadHiddenRefs(cfg, section))
+		if err != nil {
+			return err
+		}
+
+		if v2 {
+			if err := advertiseV2(os.Stdout); err != nil {
+				return err
+			}
+			if *advertiseRefs {
+				return nil
+			}
+			return serveV2(os.Stdin, os.Stdout, repo, references.NamespacePrefix(namespace), refs)
+		}
+		return advertise(os.Stdout, repo, refs, serverCapabilities, peel)
+	}
+	command.Description = func() string { return description }
+	return command
+}
+
+// advertise writes the refs in pkt-line format. The capabilities are sent
+// after the first ref, or after a placeholder if there are no refs.
+func advertise(out io.Writer, repo *repository.Repository, refs []references.Ref, capabilities []string, peel bool) error {
+	w := pktline.NewWriter(out)
+	caps := ""
+	for i, capability := range capabilities {
+		if i > 0 {
+			caps += " "
+		}
+		caps += capability
+	}
+
+	if len(refs) == 0 {
+		zero := "0000000000000000000000000000000000000000"
+		if err := w.WriteLine(fmt.Sprintf("%s capabilities^{}\x00%s", zero, caps)); err != nil {
+			return err
+		}
+	}
+
+	for i, ref := range refs {
+		line := fmt.Sprintf("%s %s", ref.SHA, ref.Name)
+		if i == 0 {
+			line += "\x00" + caps
+		}
+		if err := w.WriteLine(line); err != nil {
+			return err
+		}
+
+		// Annotated tags are followed by the object they point to
+		if !peel || ref.Name == "HEAD" {
+			continue
+		}
+		peeled, err := peeledRef(repo, ref)
+		if err != nil {
+			return err
+		}
+		if peeled != "" {
+			if err := w.WriteLine(fmt.Sprintf("%s %s^{}", peeled, ref.Name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+// peeledRef returns the object an annotated tag ultimately points to,
+// or "" if the ref does not point to a tag
+func peeledRef(repo *repository.Repository, ref references.Ref) (string, error) {
+	if ref.Peeled != "" {
+		return ref.Peeled, nil
+	}
+	sha, err := hashing.NewShaFromHex(ref.SHA)
+	if err != nil {
+		return "", err
+	}
+	typ, _, err := objects.ReadHeader(repo, sha)
+	if err != nil || typ != objects.TypeTag {
+		return "", err
+	}
+	peeled, err := objects.Peel(repo, sha, objects.TypeNoTypeSpecified)
+	if err != nil {
+		return "", err
+	}
+	return peeled.AsString(), nil
+}
+
+// protocolVersion returns the protocol version the client asked for in
+// GIT_PROTOCOL, which holds colon-separated key=value pairs
+func protocolVersion() int {
+	version := 0
+	for _, pair := range strings.Split(os.Getenv("GIT_PROTOCOL"), ":") {
+		if value, ok := strings.CutPrefix(pair, "version="); ok {
+			if n, err := strconv.Atoi(value); err == nil {
+				version = max(version, n)
+			}
+		}
+	}
+	return version
+}
+
+// advertiseV2 writes the capabilities of protocol version 2, which
+// replace the ref advertisement
+func advertiseV2(w io.Writer) error {
+	return pktline.NewWriter(w).WriteLines("version 2", "agent=got", "ls-refs", "object-format=sha1")
+}
+
+// serveV2 answers the requests of a protocol version 2 client until it
+// hangs up. A request is a command with capabilities, then a delimiter
+// and the arguments of the command, up to a flush.
+func serveV2(r io.Reader, w io.Writer, repo *repository.Repository, namespacePrefix string, refs []references.Ref) error {
+	pr := pktline.NewReader(r)
+	for {
+		line, typ, err := pr.ReadLine()
+		if err == io.EOF || typ == pktline.Flush {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		command, ok := strings.CutPrefix(line, "command=")
+		if !ok || typ != pktline.Data {
+			return fmt.Errorf("expected a command, got %q", line)
+		}
+
+		// The capabilities come first, then the arguments
+		args := []string{}
+		if _, typ, err = pr.ReadSection(); err != nil {
+			return err
+		}
+		if typ == pktline.Delim {
+			if args, typ, err = pr.ReadSection(); err != nil {
+				return err
+			}
+		}
+		if typ != pktline.Flush {
+			return fmt.Errorf("expected a flush after the arguments of %s", command)
+		}
+
+		switch command {
+		case "ls-refs":
+			err = lsRefs(w, repo, namespacePrefix, refs, args)
+		default:
+			err = fmt.Errorf("unknown command %q", command)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lsRefs writes the refs that start with one of the ref-prefix arguments,
+// so clients that only want a few refs don't get all of them
+func lsRefs(out io.Writer, repo *repository.Repository, namespacePrefix string, refs []references.Ref, args []string) error {
+	var symrefs, peel bool
+	w := pktline.NewWriter(out)
+	prefixes := []string{}
+	for _, arg := range args {
+		switch {
+		case arg == "symrefs":
+			symrefs = true
+		case arg == "peel":
+			peel = true
+		case strings.HasPrefix(arg, "ref-prefix "):
+			prefixes = append(prefixes, strings.TrimPrefix(arg, "ref-prefix "))
+		}
+	}
+
+	for _, ref := range refs {
+		if !references.MatchesPrefixes(ref.Name.String(), prefixes) {
+			continue
+		}
+		line := fmt.Sprintf("%s %s", ref.SHA, ref.Name)
+		if symrefs {
+			if target, ok := references.Reference(namespacePrefix + ref.Name.String()).Target(repo); ok {
+				line += " symref-target:" + strings.TrimPrefix(target.String(), namespacePrefix)
+			}
+		}
+		if peel && ref.Name != "HEAD" {
+			peeled, err := peeledRef(repo, ref)
+			if err != nil {
+				return err
+			}
+			if peeled != "" {
+				line += " peeled:" + peeled
+			}
+		}
+		if err := w.WriteLine(line); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,129 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/objects"
+)
+
+// gotVar is a logical variable that can be queried with `got var`
+type gotVar struct {
+	name  string
+	value func(cfg config.GitConfig) (string, error)
+}
+
+var gotVars = []gotVar{
+	{"GIT_COMMITTER_IDENT", func(cfg config.GitConfig) (string, error) {
+		id, err := objects.CommitterIdent(cfg)
+		return id.String(), err
+	}},
+	{"GIT_AUTHOR_IDENT", func(cfg config.GitConfig) (string, error) {
+		id, err := objects.AuthorIdent(cfg)
+		return id.String(), err
+	}},
+	{"GIT_EDITOR", func(cfg config.GitConfig) (string, error) { return editor(cfg), nil }},
+	{"GIT_PAGER", func(cfg config.GitConfig) (string, error) { return pager(cfg), nil }},
+	{"GIT_DEFAULT_BRANCH", func(cfg config.GitConfig) (string, error) {
+		if branch, ok := cfg.Get("init", "defaultBranch"); ok && branch != "" {
+			return branch, nil
+		}
+		return "master", nil
+	}},
+}
+
+func VarCommand() *Command {
+	command := newCommand("var")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		list := flag.Bool("l", false, "List all variables, including the configuration")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if *list == (flag.NArg() == 1) || flag.NArg() > 1 {
+			return errors.New("usage: got var (-l | <variable>)")
+		}
+
+		// Like git, var also works outside of a repository
+		cfg, err := currentConfig()
+		if err != nil {
+			return err
+		}
+
+		if *list {
+			return listVars(cfg)
+		}
+
+		for _, v := range gotVars {
+			if v.name == flag.Arg(0) {
+				value, err := v.value(cfg)
+				if err != nil {
+					return err
+				}
+				fmt.Println(value)
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown variable '%s'", flag.Arg(0))
+	}
+	command.Description = func() string { return "Show a logical variable" }
+	return command
+}
+
+func listVars(cfg config.GitConfig) error {
+	for _, section := range cfg.Sections() {
+		values := cfg.Section(section)
+		for _, key := range sortedKeys(values) {
+			fmt.Printf("%s.%s=%s\n", section, key, values[key])
+		}
+	}
+	for _, v := range gotVars {
+		value, err := v.value(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s=%s\n", v.name, value)
+	}
+	return nil
+}
+
+// editor returns the editor to use for messages, like git: GIT_EDITOR,
+// core.editor, VISUAL, EDITOR and finally vi
+func editor(cfg config.GitConfig) string {
+	if value := os.Getenv("GIT_EDITOR"); value != "" {
+		return value
+	}
+	if value, ok := cfg.Get("core", "editor"); ok && value != "" {
+		return value
+	}
+	// VISUAL is for full-screen editors, which do not work on dumb terminals
+	if value := os.Getenv("VISUAL"); value != "" && os.Getenv("TERM") != "dumb" {
+		return value
+	}
+	if value := os.Getenv("EDITOR"); value != "" {
+		return value
+	}
+	return "vi"
+}
+
+// pager returns the pager for long output, like git: GIT_PAGER,
+// core.pager, PAGER and finally less. An empty pager disables paging.
+func pager(cfg config.GitConfig) string {
+	value, ok := os.LookupEnv("GIT_PAGER")
+	if !ok {
+		value, ok = cfg.Get("core", "pager")
+	}
+	if !ok {
+		value, ok = os.LookupEnv("PAGER")
+	}
+	if !ok {
+		value = "less"
+	}
+	if value == "" {
+		return "cat"
+	}
+	return value
+}
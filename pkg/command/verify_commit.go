@@ -0,0 +1,73 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/signature"
+)
+
+func VerifyCommitCommand() *Command {
+	command := newCommand("verify-commit")
+	command.Action = func(args []string) error {
+		if len(args) < 1 {
+			return errors.New("must provide at least one commit to verify")
+		}
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range args {
+			err = verifyObject(repo, name, objects.TypeCommit)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	command.Description = func() string { return "Check the signature of commits" }
+	return command
+}
+
+// verifyObject verifies the signature of the commit or tag called name
+func verifyObject(repo *repository.Repository, name string, format objects.GitObjectType) error {
+	sha, err := objects.Find(repo, name, format, format == objects.TypeCommit)
+	if err != nil {
+		return err
+	}
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return err
+	}
+
+	var payload, sig []byte
+	var signed bool
+	switch obj := obj.(type) {
+	case *objects.Commit:
+		payload, sig, signed = obj.Signature()
+	case *objects.Tag:
+		payload, sig, signed = obj.Signature()
+	}
+	if !signed {
+		return fmt.Errorf("%s: no signature found", name)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	result, err := signature.NewVerifier(cfg).Verify(payload, sig)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(result.Output)
+	if !result.Good {
+		return fmt.Errorf("%s: bad signature from %q", name, result.Signer)
+	}
+	fmt.Printf("Good %s signature from %q\n", result.Format, result.Signer)
+	return nil
+}
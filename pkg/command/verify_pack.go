@@ -0,0 +1,97 @@
+package command
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func VerifyPackCommand() *Command {
+	command := newCommand("verify-pack")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		var verbose, statOnly bool
+		flag.BoolVar(&verbose, "verbose", false, "List the objects of the pack and a histogram of delta chain lengths")
+		flag.BoolVar(&verbose, "v", false, "Same as --verbose")
+		flag.BoolVar(&statOnly, "stat-only", false, "Only show the histogram of delta chain lengths")
+		flag.BoolVar(&statOnly, "s", false, "Same as --stat-only")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() == 0 {
+			return errors.New("usage: got verify-pack [-v | -s] <pack>.idx...")
+		}
+
+		for _, arg := range flag.Args() {
+			name := strings.TrimSuffix(strings.TrimSuffix(arg, ".idx"), ".pack")
+			if err := verifyPack(name, verbose, statOnly); err != nil {
+				fmt.Printf("%s.pack: bad\n", name)
+				return fmt.Errorf("%s.pack: %w", name, err)
+			}
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Check that packs match their index, and show their objects and delta chains"
+	}
+	return command
+}
+
+// verifyPack verifies the pack called name, without .pack or .idx, and
+// shows what was asked for
+func verifyPack(name string, verbose, statOnly bool) error {
+	data, err := os.ReadFile(name + ".idx")
+	if err != nil {
+		return err
+	}
+	index, err := pack.ParseIndex(data)
+	if err != nil {
+		return err
+	}
+	verified, err := pack.Verify(&pack.Pack{Path: name + ".pack", Index: index})
+	if err != nil {
+		return err
+	}
+	if !verbose && !statOnly {
+		return nil
+	}
+
+	chains := map[int]int{}
+	for _, obj := range verified {
+		chains[obj.Depth]++
+		if statOnly {
+			continue
+		}
+		fmt.Printf("%s %-6s %d %d %d", hex.EncodeToString(obj.Name), obj.Type, obj.Size, obj.PackedSize, obj.Offset)
+		if obj.Depth > 0 {
+			fmt.Printf(" %d %s", obj.Depth, hex.EncodeToString(obj.Base))
+		}
+		fmt.Println()
+	}
+	depths := []int{}
+	for depth := range chains {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	for _, depth := range depths {
+		objects := "objects"
+		if chains[depth] == 1 {
+			objects = "object"
+		}
+		if depth == 0 {
+			fmt.Printf("non delta: %d %s\n", chains[depth], objects)
+		} else {
+			fmt.Printf("chain length = %d: %d %s\n", depth, chains[depth], objects)
+		}
+	}
+	if verbose {
+		fmt.Printf("%s.pack: ok\n", name)
+	}
+	return nil
+}
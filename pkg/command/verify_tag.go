@@ -0,0 +1,31 @@
+package command
+
+import (
+	"errors"
+
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func VerifyTagCommand() *Command {
+	command := newCommand("verify-tag")
+	command.Action = func(args []string) error {
+		if len(args) < 1 {
+			return errors.New("must provide at least one tag to verify")
+		}
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range args {
+			err = verifyObject(repo, name, objects.TypeTag)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	command.Description = func() string { return "Check the signature of tags" }
+	return command
+}
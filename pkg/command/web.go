@@ -0,0 +1,438 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/revwalk"
+)
+
+// webPageSize is how many commits a page of the log has
+const webPageSize = 50
+
+func WebCommand() *Command {
+	command := newCommand("web")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		address := flag.String("listen", "localhost:1234", "Address to listen on")
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		if flag.NArg() != 0 {
+			return errors.New("usage: got web [--listen=<address>]")
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", *address)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: newWebHandler(repo)}
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupted)
+		go func() {
+			<-interrupted
+			server.Shutdown(context.Background())
+		}()
+
+		fmt.Printf("Serving %s on http://%s/\n", repo.WorkTree(), listener.Addr())
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+	command.Description = func() string {
+		return "Browse the commits, trees, files and diffs of the repository in a web browser"
+	}
+	return command
+}
+
+// newWebHandler serves the pages of got web. Nothing is ever written to
+// the repository, and only GET requests are answered.
+func newWebHandler(repo *repository.Repository) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		webLog(w, r, repo, "HEAD")
+	})
+	mux.HandleFunc("GET /log/{rev...}", func(w http.ResponseWriter, r *http.Request) {
+		webLog(w, r, repo, r.PathValue("rev"))
+	})
+	mux.HandleFunc("GET /commit/{rev}", func(w http.ResponseWriter, r *http.Request) {
+		webCommit(w, repo, r.PathValue("rev"))
+	})
+	mux.HandleFunc("GET /tree/{rev}/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		webTree(w, repo, r.PathValue("rev"), r.PathValue("path"))
+	})
+	mux.HandleFunc("GET /blob/{rev}/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		webBlob(w, repo, r.PathValue("rev"), r.PathValue("path"), false)
+	})
+	mux.HandleFunc("GET /raw/{rev}/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		webBlob(w, repo, r.PathValue("rev"), r.PathValue("path"), true)
+	})
+	return mux
+}
+
+var webTemplates = template.Must(template.New("web").Funcs(template.FuncMap{
+	"short": func(hash string) string { return hash[:min(len(hash), 8)] },
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+pre, .hash { font-family: monospace; }
+td { padding: 0 1em 0 0; vertical-align: top; }
+.add { background: #e6ffec; } .del { background: #ffebe9; } .hunk { color: #0550ae; } .file { font-weight: bold; }
+</style>
+</head>
+<body>
+<p><a href="/">log</a>{{range .Branches}} | <a href="/log/{{.}}">{{.}}</a>{{end}}</p>
+<h1>{{.Title}}</h1>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "log"}}{{template "header" .}}<table>
+{{range .Commits}}<tr><td class="hash"><a href="/commit/{{.Hash}}">{{short .Hash}}</a></td><td>{{.Date}}</td><td>{{.Author}}</td><td>{{.Subject}}</td></tr>
+{{end}}</table>
+{{with .Next}}<p><a href="?cursor={{.}}">Older commits</a></p>
+{{end}}{{template "footer"}}{{end}}
+
+{{define "commit"}}{{template "header" .}}<table>
+<tr><td>commit</td><td class="hash">{{.Hash}}</td></tr>
+{{range .Parents}}<tr><td>parent</td><td class="hash"><a href="/commit/{{.}}">{{.}}</a></td></tr>
+{{end}}<tr><td>author</td><td>{{.Author}}, {{.Date}}</td></tr>
+<tr><td>tree</td><td><a href="/tree/{{.Hash}}/">browse</a></td></tr>
+</table>
+<pre>{{.Message}}</pre>
+{{range .Files}}<pre>{{range .}}<span class="{{.Class}}">{{.Text}}</span>
+{{end}}</pre>
+{{end}}{{template "footer"}}{{end}}
+
+{{define "tree"}}{{template "header" .}}<table>
+{{range .Entries}}<tr><td>{{.Mode}}</td><td><a href="{{.Link}}">{{.Name}}</a></td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+
+{{define "blob"}}{{template "header" .}}<p><a href="{{.Raw}}">raw</a></p>
+{{if .Binary}}<p>Binary file, {{.Size}} bytes</p>{{else}}<pre>{{.Text}}</pre>{{end}}
+{{template "footer"}}{{end}}
+`))
+
+// webPage is what every page has
+type webPage struct {
+	Title    string
+	Branches []string
+}
+
+type webCommitInfo struct {
+	Hash, Author, Date, Subject string
+}
+
+// webLine is a line of a diff, with the class that colors it
+type webLine struct {
+	Class, Text string
+}
+
+type webTreeEntry struct {
+	Mode, Name, Link string
+}
+
+// renderWeb executes a template, or shows the error if there is one
+func renderWeb(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := webTemplates.ExecuteTemplate(w, name, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// newWebPage returns the parts of a page that all pages have
+func newWebPage(repo *repository.Repository, title string) webPage {
+	p := webPage{Title: title}
+	if refs, err := references.NewRefStore(repo).List("refs/heads/"); err == nil {
+		for _, ref := range refs {
+			p.Branches = append(p.Branches, strings.TrimPrefix(ref.Name.String(), "refs/heads/"))
+		}
+	}
+	return p
+}
+
+// webError answers with an error. Names that don't resolve are not
+// found, everything else is the server's fault.
+func webError(w http.ResponseWriter, err error, notFound bool) {
+	status := http.StatusInternalServerError
+	if notFound {
+		status = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func webLog(w http.ResponseWriter, r *http.Request, repo *repository.Repository, rev string) {
+	data := struct {
+		webPage
+		Commits []webCommitInfo
+		Next    string
+	}{webPage: newWebPage(repo, rev)}
+	// Without any commits, there is no history to show
+	if head, err := references.Reference("HEAD").Resolve(repo); rev == "HEAD" && err == nil && head == "" {
+		renderWeb(w, "log", data)
+		return
+	}
+
+	var walker *revwalk.Walker
+	var err error
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		walker, err = revwalk.Resume(repo, cursor)
+	} else {
+		var sha *hashing.SHA
+		if sha, err = objects.Find(repo, rev, objects.TypeCommit, true); err != nil {
+			webError(w, err, true)
+			return
+		}
+		walker, err = revwalk.New(repo, sha)
+	}
+	if err != nil {
+		webError(w, err, true)
+		return
+	}
+
+	for len(data.Commits) < webPageSize {
+		c, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			webError(w, err, false)
+			return
+		}
+		data.Commits = append(data.Commits, newWebCommitInfo(c.SHA, c.Commit))
+	}
+	data.Next = walker.Cursor()
+	renderWeb(w, "log", data)
+}
+
+func newWebCommitInfo(sha *hashing.SHA, commit *objects.Commit) webCommitInfo {
+	author, _ := commit.GetValue("author")
+	ident := objects.ParseIdent(author)
+	subject, _, _ := strings.Cut(strings.TrimLeft(commit.Message(), "\n"), "\n")
+	return webCommitInfo{
+		Hash:    sha.AsString(),
+		Author:  ident.Name,
+		Date:    ident.When.Format("2006-01-02 15:04"),
+		Subject: subject,
+	}
+}
+
+func webCommit(w http.ResponseWriter, repo *repository.Repository, rev string) {
+	sha, err := objects.Find(repo, rev, objects.TypeCommit, true)
+	if err != nil {
+		webError(w, err, true)
+		return
+	}
+	commit, err := readCommit(repo, sha)
+	if err != nil {
+		webError(w, err, false)
+		return
+	}
+	info := newWebCommitInfo(sha, commit)
+	data := struct {
+		webPage
+		webCommitInfo
+		Parents []string
+		Message string
+		Files   [][]webLine
+	}{webPage: newWebPage(repo, info.Subject), webCommitInfo: info, Message: commit.Message()}
+
+	// Merges are shown against their first parent, and root commits
+	// against nothing
+	from := &diffSide{paths: map[string]*hashing.SHA{}}
+	for i, parent := range commit.GetValues("parent") {
+		data.Parents = append(data.Parents, string(parent))
+		if i == 0 {
+			if from, err = treeSide(repo, string(parent)); err != nil {
+				webError(w, err, false)
+				return
+			}
+		}
+	}
+	to, err := treeSide(repo, sha.AsString())
+	if err != nil {
+		webError(w, err, false)
+		return
+	}
+	err = forEachChange(from, to, func(name string, oldSha, newSha *hashing.SHA, oldContents, newContents []byte) error {
+		var patch strings.Builder
+		if err := writeFileDiff(&patch, repo, name, oldSha, newSha, oldContents, newContents, 3); err != nil {
+			return err
+		}
+		data.Files = append(data.Files, diffLines(patch.String()))
+		return nil
+	})
+	if err != nil {
+		webError(w, err, false)
+		return
+	}
+	renderWeb(w, "commit", data)
+}
+
+// diffLines splits the patch of a file into lines, classed by what they
+// are: the headers up to the first hunk, hunk headers, and lines that
+// were added or deleted
+func diffLines(patch string) []webLine {
+	lines := []webLine{}
+	inHunks := false
+	for _, text := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		class := ""
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			class, inHunks = "hunk", true
+		case !inHunks:
+			class = "file"
+		case strings.HasPrefix(text, "+"):
+			class = "add"
+		case strings.HasPrefix(text, "-"):
+			class = "del"
+		}
+		lines = append(lines, webLine{Class: class, Text: text})
+	}
+	return lines
+}
+
+// treeEntry looks up a path in the tree of rev. The empty path is the
+// tree itself.
+func treeEntry(repo *repository.Repository, rev, name string) (*objects.TreeLeaf, error) {
+	sha, err := objects.Find(repo, rev, objects.TypeTree, true)
+	if err != nil {
+		return nil, err
+	}
+	entry := &objects.TreeLeaf{Sha: sha, Mode: []byte("40000")}
+	for _, part := range strings.Split(strings.Trim(name, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		obj, err := objects.ReadObject(repo, entry.Sha)
+		if err != nil {
+			return nil, err
+		}
+		tree, ok := obj.(*objects.Tree)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a directory", name)
+		}
+		var found *objects.TreeLeaf
+		for _, item := range tree.Items {
+			if item.PrintPath() == part {
+				found = item
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("%s does not exist in %s", name, rev)
+		}
+		entry = found
+	}
+	return entry, nil
+}
+
+func webTree(w http.ResponseWriter, repo *repository.Repository, rev, name string) {
+	entry, err := treeEntry(repo, rev, name)
+	if err != nil {
+		webError(w, err, true)
+		return
+	}
+	obj, err := objects.ReadObject(repo, entry.Sha)
+	if err != nil {
+		webError(w, err, false)
+		return
+	}
+	tree, ok := obj.(*objects.Tree)
+	if !ok {
+		webError(w, fmt.Errorf("%s is not a directory", name), true)
+		return
+	}
+
+	data := struct {
+		webPage
+		Entries []webTreeEntry
+	}{webPage: newWebPage(repo, "/"+strings.Trim(name, "/"))}
+	if strings.Trim(name, "/") != "" {
+		data.Entries = append(data.Entries, webTreeEntry{Name: "..", Link: path.Join("/tree", rev, path.Dir(strings.Trim(name, "/"))) + "/"})
+	}
+	for _, item := range tree.Items {
+		itemPath := path.Join(strings.Trim(name, "/"), item.PrintPath())
+		e := webTreeEntry{Mode: fmt.Sprintf("%06s", item.Mode), Name: item.PrintPath()}
+		switch {
+		case strings.HasPrefix(string(item.Mode), "4"):
+			e.Name += "/"
+			e.Link = path.Join("/tree", rev, itemPath) + "/"
+		case strings.HasPrefix(string(item.Mode), "16"):
+			// Submodules are in another repository
+			e.Link = "#"
+		default:
+			e.Link = path.Join("/blob", rev, itemPath)
+		}
+		data.Entries = append(data.Entries, e)
+	}
+	renderWeb(w, "tree", data)
+}
+
+func webBlob(w http.ResponseWriter, repo *repository.Repository, rev, name string, raw bool) {
+	entry, err := treeEntry(repo, rev, name)
+	if err != nil {
+		webError(w, err, true)
+		return
+	}
+	obj, err := objects.ReadObject(repo, entry.Sha)
+	if err != nil {
+		webError(w, err, false)
+		return
+	}
+	if obj.Type() != objects.TypeBlob {
+		webError(w, fmt.Errorf("%s is not a file", name), true)
+		return
+	}
+	data, err := obj.Serialize()
+	if err != nil {
+		webError(w, err, false)
+		return
+	}
+	binary := diff.IsBinary(data)
+	if raw {
+		// Files are never shown as HTML, which could run scripts
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if binary {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(data)
+		return
+	}
+	renderWeb(w, "blob", struct {
+		webPage
+		Raw    string
+		Binary bool
+		Size   int
+		Text   string
+	}{newWebPage(repo, name), path.Join("/raw", rev, name), binary, len(data), string(data)})
+}
@@ -0,0 +1,95 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jessegeens/got/pkg/gitdate"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/worktree"
+)
+
+func WorktreeCommand() *Command {
+	command := newCommand("worktree")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		if len(args) == 0 {
+			return errors.New("usage: got worktree (prune | repair) [<options>]")
+		}
+		subcommand := args[0]
+
+		var dryRun, verbose bool
+		flag.BoolVar(&dryRun, "dry-run", false, "With prune, only show what would be removed")
+		flag.BoolVar(&dryRun, "n", false, "Same as --dry-run")
+		flag.BoolVar(&verbose, "verbose", false, "With prune, show what is removed")
+		flag.BoolVar(&verbose, "v", false, "Same as --verbose")
+		expire := flag.String("expire", "", "With prune, only remove worktrees that are gone if they weren't used since this time, like 2.weeks.ago")
+		if err := flag.CommandLine.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+
+		switch subcommand {
+		case "prune":
+			if flag.NArg() > 0 {
+				return errors.New("usage: got worktree prune [-n] [-v] [--expire <expire>]")
+			}
+			opts := worktree.PruneOptions{DryRun: dryRun}
+			if *expire != "" {
+				if opts.Expire, err = gitdate.Expiry(*expire, time.Now()); err != nil {
+					return err
+				}
+			}
+			return worktreePrune(repo, opts, verbose)
+		case "repair":
+			return worktreeRepair(repo, flag.Args())
+		}
+		return fmt.Errorf("unknown worktree subcommand: %s", subcommand)
+	}
+	command.Description = func() string { return "Prune and repair the administrative files of linked worktrees" }
+	return command
+}
+
+// worktreePrune removes the registrations of linked worktrees that are
+// gone. Like git, what is removed is only shown with -v or -n.
+func worktreePrune(repo *repository.Repository, opts worktree.PruneOptions, verbose bool) error {
+	pruned, err := worktree.Prune(repo, opts)
+	if err != nil {
+		return err
+	}
+	if verbose || opts.DryRun {
+		for _, p := range pruned {
+			fmt.Fprintf(os.Stderr, "Removing worktrees/%s: %s\n", p.ID, p.Reason)
+		}
+	}
+	return nil
+}
+
+// worktreeRepair fixes the links between the repository and its linked
+// worktrees, after either was moved
+func worktreeRepair(repo *repository.Repository, paths []string) error {
+	problems, err := worktree.Repair(repo, paths)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, p := range problems {
+		if p.Failed {
+			failed = true
+			fmt.Fprintf(os.Stderr, "error: %s: %s\n", p.Message, p.Path)
+			continue
+		}
+		fmt.Printf("repair: %s: %s\n", p.Message, p.Path)
+	}
+	if failed {
+		return errors.New("some worktrees could not be repaired")
+	}
+	return nil
+}
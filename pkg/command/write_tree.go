@@ -0,0 +1,41 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func WriteTreeCommand() *Command {
+	command := newCommand("write-tree")
+	command.Action = func(args []string) error {
+		command.ResetFlags()
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return err
+		}
+		repo, err := repository.Find(".")
+		if err != nil {
+			return err
+		}
+		idx, err := index.Read(repo)
+		if err != nil {
+			return err
+		}
+		// Like git, a tree can only be written once conflicts are resolved
+		if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+			return fmt.Errorf("the index has unmerged entries:\n\t%s", strings.Join(unmerged, "\n\t"))
+		}
+		tree, err := objects.TreeFromIndex(repo, idx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(tree.AsString())
+		return nil
+	}
+	command.Description = func() string { return "Create a tree object from the index" }
+	return command
+}
@@ -0,0 +1,271 @@
+// Package commitgraph reads and writes commit-graph files, in which git
+// keeps the parents, root trees, commit times and generation numbers of
+// commits, so history can be walked without reading the commits. A walk
+// that looks for a commit can stop at commits with a lower generation,
+// since they can't reach it.
+package commitgraph
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+var magic = []byte("CGPH")
+
+// The chunks of a commit-graph file
+var (
+	chunkFanout     = [4]byte{'O', 'I', 'D', 'F'}
+	chunkOIDs       = [4]byte{'O', 'I', 'D', 'L'}
+	chunkCommitData = [4]byte{'C', 'D', 'A', 'T'}
+	chunkEdges      = [4]byte{'E', 'D', 'G', 'E'}
+)
+
+const (
+	// commitDataSize is the size of a commit in the CDAT chunk: its root
+	// tree, two parents, and its generation and time
+	commitDataSize = sha1.Size + 16
+	// parentNone is the parent of commits without one
+	parentNone = 0x70000000
+	// parentEdges marks the second parent of an octopus merge as a
+	// position in the EDGE chunk, where its parents after the first are,
+	// the last one marked with it too
+	parentEdges = 0x80000000
+)
+
+// Commit is a commit in the graph
+type Commit struct {
+	Parents []*hashing.SHA
+	// Generation is the topological level of the commit: 1 for root
+	// commits, and one more than the highest of its parents otherwise.
+	// Zero means the graph doesn't know it.
+	Generation uint32
+	// When is the commit time
+	When time.Time
+}
+
+// Graph is the commit-graph of a repository: a file, or a chain of them in
+// which every layer has the commits that are new since the layers below it
+type Graph struct {
+	layers []*layer
+}
+
+// layer is a commit-graph file
+type layer struct {
+	fanout     [256]uint32
+	oids       []byte
+	commitData []byte
+	edges      []byte
+	// base is how many commits the layers below have, which come before
+	// the ones of this layer in the positions of parents
+	base uint32
+}
+
+// Read returns the commit-graph of the repository: objects/info/commit-graph,
+// or else the chain in objects/info/commit-graphs, like git. It returns nil
+// when the repository has neither.
+func Read(repo *repository.Repository) (*Graph, error) {
+	path := repo.RepositoryPath("objects", "info", "commit-graph")
+	l, err := readLayer(repo, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if l != nil {
+		return &Graph{layers: []*layer{l}}, nil
+	}
+
+	chain, err := os.ReadFile(repo.RepositoryPath("objects", "info", "commit-graphs", "commit-graph-chain"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	g := &Graph{}
+	scanner := bufio.NewScanner(bytes.NewReader(chain))
+	for scanner.Scan() {
+		name := "graph-" + strings.TrimSpace(scanner.Text()) + ".graph"
+		l, err := readLayer(repo, repo.RepositoryPath("objects", "info", "commit-graphs", name))
+		if err == nil && l == nil {
+			err = errors.New("the file is missing")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(g.layers) > 0 {
+			below := g.layers[len(g.layers)-1]
+			l.base = below.base + below.count()
+		}
+		g.layers = append(g.layers, l)
+	}
+	return g, nil
+}
+
+// readLayer parses a commit-graph file, which is cached until it changes.
+// A file that doesn't exist is nil.
+func readLayer(repo *repository.Repository, path string) (*layer, error) {
+	value, err := repo.CachedFile(path, func(data []byte) (any, error) {
+		if data == nil {
+			return (*layer)(nil), nil
+		}
+		return parse(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*layer), nil
+}
+
+// Parse parses a commit-graph file that isn't part of a chain
+func Parse(data []byte) (*Graph, error) {
+	l, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Graph{layers: []*layer{l}}, nil
+}
+
+func parse(data []byte) (*layer, error) {
+	if len(data) < 8+sha1.Size || !bytes.HasPrefix(data, magic) {
+		return nil, errors.New("not a commit-graph file")
+	}
+	if sum := sha1.Sum(data[:len(data)-sha1.Size]); !bytes.Equal(sum[:], data[len(data)-sha1.Size:]) {
+		return nil, errors.New("commit-graph checksum mismatch")
+	}
+	if data[4] != 1 {
+		return nil, fmt.Errorf("unsupported commit-graph version %d", data[4])
+	}
+	if data[5] != 1 {
+		return nil, fmt.Errorf("unsupported commit-graph hash version %d", data[5])
+	}
+
+	// The table of contents has the offset of every chunk, and the end of
+	// the last one
+	chunks := map[[4]byte][]byte{}
+	count := int(data[6])
+	end := len(data) - sha1.Size
+	if 8+(count+1)*12 > end {
+		return nil, errors.New("commit-graph is too short")
+	}
+	for i := range count {
+		entry := data[8+i*12:]
+		start, next := binary.BigEndian.Uint64(entry[4:]), binary.BigEndian.Uint64(entry[16:])
+		if start > next || next > uint64(end) {
+			return nil, errors.New("commit-graph has a corrupt chunk table")
+		}
+		chunks[[4]byte(entry[:4])] = data[start:next]
+	}
+
+	l := &layer{oids: chunks[chunkOIDs], commitData: chunks[chunkCommitData], edges: chunks[chunkEdges]}
+	fanout := chunks[chunkFanout]
+	if len(fanout) != 256*4 {
+		return nil, errors.New("commit-graph has no fanout table")
+	}
+	for i := range l.fanout {
+		l.fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+		if i > 0 && l.fanout[i] < l.fanout[i-1] {
+			return nil, errors.New("commit-graph has a corrupt fanout table")
+		}
+	}
+	n := int(l.fanout[255])
+	if len(l.oids) != n*sha1.Size || len(l.commitData) != n*commitDataSize {
+		return nil, errors.New("commit-graph has the wrong number of commits")
+	}
+	return l, nil
+}
+
+func (l *layer) count() uint32 {
+	return l.fanout[255]
+}
+
+// find returns the position of a commit in the layer
+func (l *layer) find(sha []byte) (int, bool) {
+	lo, hi := 0, int(l.fanout[sha[0]])
+	if sha[0] > 0 {
+		lo = int(l.fanout[sha[0]-1])
+	}
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(l.oids[(lo+i)*sha1.Size:(lo+i+1)*sha1.Size], sha) >= 0
+	})
+	return i, i < hi && bytes.Equal(l.oids[i*sha1.Size:(i+1)*sha1.Size], sha)
+}
+
+// Len returns the number of commits in the graph
+func (g *Graph) Len() int {
+	if g == nil || len(g.layers) == 0 {
+		return 0
+	}
+	top := g.layers[len(g.layers)-1]
+	return int(top.base + top.count())
+}
+
+// Lookup returns a commit of the graph. Commits that are newer than the
+// graph aren't in it, and a nil graph has none.
+func (g *Graph) Lookup(sha *hashing.SHA) (Commit, bool, error) {
+	if g == nil {
+		return Commit{}, false, nil
+	}
+	for _, l := range g.layers {
+		i, ok := l.find(sha.AsBytes())
+		if !ok {
+			continue
+		}
+		data := l.commitData[i*commitDataSize+sha1.Size:]
+		commit := Commit{}
+		genAndTime := binary.BigEndian.Uint32(data[8:])
+		commit.Generation = genAndTime >> 2
+		commit.When = time.Unix(int64(genAndTime&3)<<32|int64(binary.BigEndian.Uint32(data[12:])), 0)
+
+		positions := []uint32{}
+		if first := binary.BigEndian.Uint32(data); first != parentNone {
+			positions = append(positions, first)
+		}
+		second := binary.BigEndian.Uint32(data[4:])
+		switch {
+		case second == parentNone:
+		case second&parentEdges == 0:
+			positions = append(positions, second)
+		default:
+			for edge := int(second &^ parentEdges); ; edge++ {
+				if (edge+1)*4 > len(l.edges) {
+					return Commit{}, false, errors.New("commit-graph has a corrupt edge list")
+				}
+				position := binary.BigEndian.Uint32(l.edges[edge*4:])
+				positions = append(positions, position&^parentEdges)
+				if position&parentEdges != 0 {
+					break
+				}
+			}
+		}
+		for _, position := range positions {
+			parent, err := g.name(position)
+			if err != nil {
+				return Commit{}, false, err
+			}
+			commit.Parents = append(commit.Parents, parent)
+		}
+		return commit, true, nil
+	}
+	return Commit{}, false, nil
+}
+
+// name returns the commit at a position of the graph
+func (g *Graph) name(position uint32) (*hashing.SHA, error) {
+	for _, l := range g.layers {
+		if position >= l.base && position < l.base+l.count() {
+			i := int(position - l.base)
+			return hashing.NewShaFromBytes(bytes.Clone(l.oids[i*sha1.Size : (i+1)*sha1.Size])), nil
+		}
+	}
+	return nil, fmt.Errorf("commit-graph has no commit at position %d", position)
+}
@@ -0,0 +1,129 @@
+package commitgraph
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func commitAt(t *testing.T, repo *repository.Repository, when int64, parents ...*hashing.SHA) *hashing.SHA {
+	t.Helper()
+	tree := objects.EmptyTreeSHA()
+	ident := objects.Ident{Name: "Jane", Email: "jane@example.com", When: time.Unix(when, 0).UTC()}
+	sha, err := objects.CreateCommit(repo, tree, parents, ident, ident, "commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func shaNames(shas []*hashing.SHA) []string {
+	names := []string{}
+	for _, sha := range shas {
+		names = append(names, sha.AsString())
+	}
+	return names
+}
+
+func TestWriteRead(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	if g, err := Read(repo); err != nil || g != nil {
+		t.Fatalf("Read() without a graph = %v, %v, want nil", g, err)
+	}
+
+	// root - a - octopus
+	//    \- b -/   /
+	//     \- c ---/
+	root := commitAt(t, repo, 100)
+	a := commitAt(t, repo, 200, root)
+	b := commitAt(t, repo, 300, root)
+	c := commitAt(t, repo, 400, root)
+	octopus := commitAt(t, repo, 500, a, b, c)
+	newer := commitAt(t, repo, 600, octopus)
+
+	n, err := Write(repo, []*hashing.SHA{octopus})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if n != 5 {
+		t.Errorf("Write() = %d commits, want 5", n)
+	}
+	g, err := Read(repo)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if g.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", g.Len())
+	}
+
+	tests := []struct {
+		sha        *hashing.SHA
+		parents    []*hashing.SHA
+		generation uint32
+		when       int64
+	}{
+		{root, nil, 1, 100},
+		{a, []*hashing.SHA{root}, 2, 200},
+		{c, []*hashing.SHA{root}, 2, 400},
+		{octopus, []*hashing.SHA{a, b, c}, 3, 500},
+	}
+	for _, tt := range tests {
+		commit, ok, err := g.Lookup(tt.sha)
+		if err != nil || !ok {
+			t.Fatalf("Lookup(%s) = %v, %v, want the commit", tt.sha.AsString(), ok, err)
+		}
+		if got, want := shaNames(commit.Parents), shaNames(tt.parents); !slices.Equal(got, want) {
+			t.Errorf("Lookup(%s) parents = %v, want %v", tt.sha.AsString(), got, want)
+		}
+		if commit.Generation != tt.generation {
+			t.Errorf("Lookup(%s) generation = %d, want %d", tt.sha.AsString(), commit.Generation, tt.generation)
+		}
+		if commit.When.Unix() != tt.when {
+			t.Errorf("Lookup(%s) time = %d, want %d", tt.sha.AsString(), commit.When.Unix(), tt.when)
+		}
+	}
+	if _, ok, err := g.Lookup(newer); err != nil || ok {
+		t.Errorf("Lookup() of a commit newer than the graph = %v, %v, want not found", ok, err)
+	}
+
+	var none *Graph
+	if _, ok, err := none.Lookup(root); err != nil || ok || none.Len() != 0 {
+		t.Errorf("a nil graph has commits")
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	if _, err := Write(repo, []*hashing.SHA{commitAt(t, repo, 100)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	path := repo.RepositoryPath("objects", "info", "commit-graph")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(repo); err == nil {
+		t.Errorf("Read() of a corrupt graph succeeded")
+	}
+	if _, err := Parse([]byte("CGPH")); err == nil {
+		t.Errorf("Parse() of a truncated graph succeeded")
+	}
+}
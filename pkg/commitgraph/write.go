@@ -0,0 +1,188 @@
+package commitgraph
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// The largest generation and commit time a commit-graph can have
+const (
+	maxGeneration = 1<<30 - 1
+	maxTime       = 1<<34 - 1
+)
+
+// graphCommit is a commit that is written to the graph
+type graphCommit struct {
+	tree    []byte
+	parents []string
+	when    int64
+}
+
+// chunk is a chunk of a commit-graph file
+type chunk struct {
+	id   [4]byte
+	data []byte
+}
+
+// Write writes the commit-graph of the commits that can be reached from
+// tips to objects/info/commit-graph, in place of the one that was there,
+// and returns how many commits it has
+func Write(repo *repository.Repository, tips []*hashing.SHA) (int, error) {
+	commits := map[string]*graphCommit{}
+	queue := []string{}
+	for _, tip := range tips {
+		queue = append(queue, tip.AsString())
+	}
+	for len(queue) > 0 {
+		name := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if commits[name] != nil {
+			continue
+		}
+		sha, err := hashing.NewShaFromHex(name)
+		if err != nil {
+			return 0, err
+		}
+		obj, err := objects.ReadObject(repo, sha)
+		if err != nil {
+			return 0, err
+		}
+		commit, ok := obj.(*objects.Commit)
+		if !ok {
+			return 0, fmt.Errorf("object %s is a %s, not a commit", name, obj.Type())
+		}
+		tree, _ := commit.GetValue("tree")
+		treeSha, err := hex.DecodeString(string(tree))
+		if err != nil || len(treeSha) != sha1.Size {
+			return 0, fmt.Errorf("commit %s has an invalid tree", name)
+		}
+		committer, _ := commit.GetValue("committer")
+		c := &graphCommit{tree: treeSha, when: min(max(objects.ParseIdent(committer).When.Unix(), 0), maxTime)}
+		for _, parent := range commit.GetValues("parent") {
+			c.parents = append(c.parents, string(parent))
+			queue = append(queue, string(parent))
+		}
+		commits[name] = c
+	}
+
+	names := make([]string, 0, len(commits))
+	for name := range commits {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	positions := map[string]uint32{}
+	for i, name := range names {
+		positions[name] = uint32(i)
+	}
+	generations := topologicalLevels(commits)
+
+	var fanout, oids, commitData, edges []byte
+	var counts [256]uint32
+	for _, name := range names {
+		sha, _ := hex.DecodeString(name)
+		counts[sha[0]]++
+		oids = append(oids, sha...)
+
+		c := commits[name]
+		commitData = append(commitData, c.tree...)
+		parents := []uint32{parentNone, parentNone}
+		for i, parent := range c.parents {
+			if i < 2 {
+				parents[i] = positions[parent]
+			}
+		}
+		// Octopus merges have their parents after the first in the edge list
+		if len(c.parents) > 2 {
+			parents[1] = parentEdges | uint32(len(edges)/4)
+			for i, parent := range c.parents[1:] {
+				position := positions[parent]
+				if i == len(c.parents)-2 {
+					position |= parentEdges
+				}
+				edges = binary.BigEndian.AppendUint32(edges, position)
+			}
+		}
+		commitData = binary.BigEndian.AppendUint32(commitData, parents[0])
+		commitData = binary.BigEndian.AppendUint32(commitData, parents[1])
+		commitData = binary.BigEndian.AppendUint32(commitData, generations[name]<<2|uint32(c.when>>32))
+		commitData = binary.BigEndian.AppendUint32(commitData, uint32(c.when))
+	}
+	total := uint32(0)
+	for _, count := range counts {
+		total += count
+		fanout = binary.BigEndian.AppendUint32(fanout, total)
+	}
+
+	chunks := []chunk{{chunkFanout, fanout}, {chunkOIDs, oids}, {chunkCommitData, commitData}}
+	if len(edges) > 0 {
+		chunks = append(chunks, chunk{chunkEdges, edges})
+	}
+	var data bytes.Buffer
+	data.Write(magic)
+	data.Write([]byte{1, 1, byte(len(chunks)), 0})
+	offset := uint64(8 + (len(chunks)+1)*12)
+	for _, c := range chunks {
+		data.Write(c.id[:])
+		data.Write(binary.BigEndian.AppendUint64(nil, offset))
+		offset += uint64(len(c.data))
+	}
+	data.Write([]byte{0, 0, 0, 0})
+	data.Write(binary.BigEndian.AppendUint64(nil, offset))
+	for _, c := range chunks {
+		data.Write(c.data)
+	}
+	sum := sha1.Sum(data.Bytes())
+	data.Write(sum[:])
+
+	if _, err := repo.RepositoryDir(true, "objects", "info"); err != nil {
+		return 0, err
+	}
+	mode, err := repo.SharedMode(0o444)
+	if err != nil {
+		return 0, err
+	}
+	if err := fs.AtomicWrite(repo.RepositoryPath("objects", "info", "commit-graph"), data.Bytes(), fs.WithExactPerm(mode)); err != nil {
+		return 0, err
+	}
+	return len(names), nil
+}
+
+// topologicalLevels returns the generation of every commit: one more than
+// the highest of its parents. Parents are done before their children
+// without recursion, since histories can be very long.
+func topologicalLevels(commits map[string]*graphCommit) map[string]uint32 {
+	levels := map[string]uint32{}
+	for name := range commits {
+		stack := []string{name}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if levels[top] != 0 {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			level, done := uint32(1), true
+			for _, parent := range commits[top].parents {
+				if levels[parent] == 0 {
+					stack = append(stack, parent)
+					done = false
+				} else {
+					level = max(level, levels[parent]+1)
+				}
+			}
+			if done {
+				levels[top] = min(level, maxGeneration)
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return levels
+}
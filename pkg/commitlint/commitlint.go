@@ -0,0 +1,121 @@
+// Checks of commit messages against a policy, which commit runs before it
+// creates a commit, so teams can enforce one without commit-msg hooks. The
+// policy is configured like this:
+//
+//	[commitlint]
+//		# conventional, pattern or off, the default
+//		mode = conventional
+//		# The types that conventional commits can have
+//		types = feat, fix, docs
+//		# The regular expression that subjects have to match with pattern
+//		pattern = ^\[[A-Z]+-[0-9]+\]
+package commitlint
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+type Mode string
+
+const (
+	ModeOff Mode = "off"
+	// ModeConventional checks that messages follow conventional commits,
+	// see https://www.conventionalcommits.org
+	ModeConventional Mode = "conventional"
+	// ModePattern checks that subjects match a regular expression
+	ModePattern Mode = "pattern"
+)
+
+// DefaultTypes are the types of conventional commits that are allowed if
+// commitlint.types isn't set
+var DefaultTypes = []string{"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test"}
+
+// conventionalSubject is "<type>[(<scope>)][!]: <description>"
+var conventionalSubject = regexp.MustCompile(`^([A-Za-z]+)(\([^()]+\))?(!)?: \S`)
+
+// generatedPrefixes start the messages that git writes itself, which are
+// accepted as they are
+var generatedPrefixes = []string{"Merge ", "Revert \"", "fixup! ", "squash! ", "amend! "}
+
+// Linter checks commit messages
+type Linter struct {
+	Mode Mode
+	// Types are the allowed types of conventional commits
+	Types []string
+	// Pattern is what subjects have to match in ModePattern
+	Pattern *regexp.Regexp
+}
+
+// FromConfig creates the linter that the configuration asks for. It
+// returns nil if messages aren't checked.
+func FromConfig(cfg config.GitConfig) (*Linter, error) {
+	mode, _ := cfg.Get("commitlint", "mode")
+	switch Mode(strings.ToLower(mode)) {
+	case "", ModeOff:
+		return nil, nil
+	case ModeConventional:
+		types := []string{}
+		for _, value := range cfg.GetAll("commitlint", "types") {
+			for _, typ := range strings.Split(value, ",") {
+				if typ = strings.ToLower(strings.TrimSpace(typ)); typ != "" {
+					types = append(types, typ)
+				}
+			}
+		}
+		if len(types) == 0 {
+			types = DefaultTypes
+		}
+		return &Linter{Mode: ModeConventional, Types: types}, nil
+	case ModePattern:
+		value, _ := cfg.Get("commitlint", "pattern")
+		if value == "" {
+			return nil, errors.New("commitlint.pattern needs to be set for commitlint.mode pattern")
+		}
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid commitlint.pattern: %w", err)
+		}
+		return &Linter{Mode: ModePattern, Pattern: pattern}, nil
+	}
+	return nil, fmt.Errorf("unsupported value for commitlint.mode: %s", mode)
+}
+
+// Check returns an error that explains what is wrong with message, if it
+// doesn't follow the policy. Empty messages and the messages that git
+// writes for merges, reverts and fixups are accepted.
+func (l *Linter) Check(message string) error {
+	if l == nil || message == "" {
+		return nil
+	}
+	for _, prefix := range generatedPrefixes {
+		if strings.HasPrefix(message, prefix) {
+			return nil
+		}
+	}
+	subject, body, _ := strings.Cut(message, "\n")
+
+	switch l.Mode {
+	case ModeConventional:
+		match := conventionalSubject.FindStringSubmatch(subject)
+		if match == nil {
+			return fmt.Errorf("the subject '%s' is not '<type>[(<scope>)][!]: <description>', like conventional commits ask for", subject)
+		}
+		if !slices.Contains(l.Types, strings.ToLower(match[1])) {
+			return fmt.Errorf("the type '%s' is not one of %s", match[1], strings.Join(l.Types, ", "))
+		}
+		if body != "" && !strings.HasPrefix(body, "\n") {
+			return errors.New("the body has to be separated from the subject by an empty line")
+		}
+	case ModePattern:
+		if !l.Pattern.MatchString(subject) {
+			return fmt.Errorf("the subject '%s' does not match commitlint.pattern '%s'", subject, l.Pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package commitlint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+func TestCheck(t *testing.T) {
+	conventional := &Linter{Mode: ModeConventional, Types: DefaultTypes}
+	pattern, err := FromConfig(readConfig(t, "[commitlint]\n\tmode = pattern\n\tpattern = ^\\\\[[A-Z]+-[0-9]+\\\\] \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		linter  *Linter
+		message string
+		wantErr bool
+	}{
+		{"conventional", conventional, "feat: add blame\n", false},
+		{"scope", conventional, "fix(index): keep stat data\n", false},
+		{"breaking change", conventional, "refactor(api)!: rename methods\n\nBREAKING CHANGE: renamed\n", false},
+		{"type case", conventional, "Docs: describe hooks\n", false},
+		{"unknown type", conventional, "feature: add blame\n", true},
+		{"no type", conventional, "Add blame\n", true},
+		{"no space", conventional, "feat:add blame\n", true},
+		{"empty description", conventional, "feat: \n", true},
+		{"body without empty line", conventional, "feat: add blame\nwith porcelain output\n", true},
+		{"merge", conventional, "Merge branch 'topic'\n", false},
+		{"fixup", conventional, "fixup! feat: add blame\n", false},
+		{"empty", conventional, "", false},
+		{"pattern", pattern, "[GOT-12] Add blame\n", false},
+		{"pattern mismatch", pattern, "Add blame\n", true},
+		{"pattern only checks the subject", pattern, "Add blame\n[GOT-12] in the body\n", true},
+		{"off", nil, "anything", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.linter.Check(tt.message); (err != nil) != tt.wantErr {
+				t.Errorf("Check(%q) error = %v, wantErr %v", tt.message, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    *Linter
+		wantErr bool
+	}{
+		{name: "not configured", config: "", want: nil},
+		{name: "off", config: "[commitlint]\n\tmode = off\n", want: nil},
+		{
+			name:   "conventional",
+			config: "[commitlint]\n\tmode = conventional\n",
+			want:   &Linter{Mode: ModeConventional, Types: DefaultTypes},
+		},
+		{
+			name:   "types",
+			config: "[commitlint]\n\tmode = Conventional\n\ttypes = feat, Fix\n\ttypes = docs\n",
+			want:   &Linter{Mode: ModeConventional, Types: []string{"feat", "fix", "docs"}},
+		},
+		{name: "pattern without pattern", config: "[commitlint]\n\tmode = pattern\n", wantErr: true},
+		{name: "invalid pattern", config: "[commitlint]\n\tmode = pattern\n\tpattern = (\n", wantErr: true},
+		{name: "unknown mode", config: "[commitlint]\n\tmode = strict\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromConfig(readConfig(t, tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func readConfig(t *testing.T, data string) config.GitConfig {
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.ReadWithRepository(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
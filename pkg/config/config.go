@@ -1,9 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
 
 	"gopkg.in/ini.v1"
 )
@@ -12,22 +17,66 @@ type GitConfig struct {
 	data *ini.File
 }
 
+// Keys can be given multiple times, e.g. transfer.hideRefs. Quotes and
+// comments are left in values, since git's rules for them differ from
+// those of the ini library; see unquote.
+var loadOptions = ini.LoadOptions{AllowShadows: true, IgnoreInlineComment: true, PreserveSurroundedQuote: true}
+
+// ReadError is returned when the configuration can't be read, e.g.
+// because a file or the environment is malformed. Like in git, that is
+// fatal for every command that needs the configuration.
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// readError wraps err, if there is one, in a ReadError
+func readError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ReadError{Err: err}
+}
+
+// Read reads the global configuration, overlaid with the configuration
+// given in the environment
 func Read() (GitConfig, error) {
-	homedir, err := os.UserHomeDir()
+	cfg, err := readGlobal()
 	if err != nil {
-		return GitConfig{}, fmt.Errorf("failed to parse ~/.gitconfig: not able to read home directory: %s", err.Error())
+		return GitConfig{}, readError(err)
+	}
+	return cfg, readError(cfg.applyEnv())
+}
+
+// readGlobal reads ~/.gitconfig and $XDG_CONFIG_HOME/git/config. Like in
+// git, both are optional, and so is the home directory.
+func readGlobal() (GitConfig, error) {
+	files := []any{}
+	if homedir, err := os.UserHomeDir(); err == nil {
+		files = append(files, path.Join(homedir, ".gitconfig"))
 	}
-	gitConfigFileLocation := path.Join(homedir, ".gitconfig")
-	var cfg *ini.File
 	if val, ok := os.LookupEnv("XDG_CONFIG_HOME"); ok {
-		cfg, err = ini.Load(gitConfigFileLocation, path.Join(val, "/git/config"))
-	} else {
-		cfg, err = ini.Load(gitConfigFileLocation)
+		files = append(files, path.Join(val, "/git/config"))
+	}
+	files = slices.DeleteFunc(files, func(file any) bool {
+		_, err := os.Stat(file.(string))
+		return errors.Is(err, os.ErrNotExist)
+	})
+	if len(files) == 0 {
+		return GitConfig{data: ini.Empty(loadOptions)}, nil
 	}
+	cfg, err := ini.LoadSources(loadOptions, files[0], files[1:]...)
 	if err != nil {
 		return GitConfig{}, err
 	}
-	return GitConfig{data: cfg}, err
+	return GitConfig{data: cfg}, nil
 }
 
 func (c *GitConfig) GetUser() (string, bool) {
@@ -47,5 +96,211 @@ func (c *GitConfig) GetUser() (string, bool) {
 		return "", false
 	}
 
-	return fmt.Sprintf("%s <%s>", name.String(), email.String()), true
+	return fmt.Sprintf("%s <%s>", unquote(name.String()), unquote(email.String())), true
+}
+
+// Get returns the value of key in section, e.g. Get(`gpg "ssh"`, "program")
+func (c *GitConfig) Get(section, key string) (string, bool) {
+	if c.data == nil {
+		return "", false
+	}
+	sec, err := c.data.GetSection(section)
+	if err != nil {
+		return "", false
+	}
+	if !sec.HasKey(key) {
+		return "", false
+	}
+	// Like git, the last value wins if a key is given multiple times
+	values := sec.Key(key).ValueWithShadows()
+	return unquote(values[len(values)-1]), true
+}
+
+// GetAll returns all values of a key that can be given multiple times,
+// in the order they appear in the configuration files
+func (c *GitConfig) GetAll(section, key string) []string {
+	if c.data == nil {
+		return nil
+	}
+	sec, err := c.data.GetSection(section)
+	if err != nil || !sec.HasKey(key) {
+		return nil
+	}
+	values := []string{}
+	for _, value := range sec.Key(key).ValueWithShadows() {
+		values = append(values, unquote(value))
+	}
+	return values
+}
+
+// ReadWithRepository reads the global configuration, overlaid with
+// the repository configuration file at repoConfig and the configuration
+// given in the environment
+func ReadWithRepository(repoConfig string) (GitConfig, error) {
+	cfg, err := readRepository(repoConfig)
+	if err != nil {
+		return cfg, readError(err)
+	}
+	return cfg, readError(cfg.applyEnv())
+}
+
+// ReadWithWorktree reads the configuration like ReadWithRepository, with
+// the per-worktree configuration file at worktreeConfig, which is
+// optional, between the repository configuration and the environment
+func ReadWithWorktree(repoConfig, worktreeConfig string) (GitConfig, error) {
+	cfg, err := readRepository(repoConfig)
+	if err != nil {
+		return cfg, readError(err)
+	}
+	if _, err := os.Stat(worktreeConfig); err == nil {
+		if err := cfg.data.Append(worktreeConfig); err != nil {
+			return cfg, readError(fmt.Errorf("failed to read worktree configuration: %w", err))
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return cfg, readError(fmt.Errorf("failed to read worktree configuration: %w", err))
+	}
+	return cfg, readError(cfg.applyEnv())
+}
+
+// readRepository reads the global configuration, overlaid with the
+// repository configuration file at repoConfig
+func readRepository(repoConfig string) (GitConfig, error) {
+	cfg, err := readGlobal()
+	if err != nil {
+		return cfg, err
+	}
+	if err := cfg.data.Append(repoConfig); err != nil {
+		return cfg, fmt.Errorf("failed to read repository configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// GetBool returns the value of key in section, interpreted as a boolean
+func (c *GitConfig) GetBool(section, key string) (bool, bool) {
+	val, ok := c.Get(section, key)
+	if !ok {
+		return false, false
+	}
+	switch strings.ToLower(val) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// GetInt returns the value of key in section, interpreted as an integer.
+// Like git, it can have a k, m or g suffix for KiB, MiB or GiB.
+func (c *GitConfig) GetInt(section, key string) (int64, bool) {
+	val, ok := c.Get(section, key)
+	if !ok {
+		return 0, false
+	}
+	return parseInt(val)
+}
+
+// parseInt parses an integer with an optional k, m or g suffix
+func parseInt(val string) (int64, bool) {
+	factor := int64(1)
+	if val != "" {
+		switch strings.ToLower(val[len(val)-1:]) {
+		case "k":
+			factor = 1 << 10
+		case "m":
+			factor = 1 << 20
+		case "g":
+			factor = 1 << 30
+		}
+		if factor != 1 {
+			val = val[:len(val)-1]
+		}
+	}
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || n > math.MaxInt64/factor || n < math.MinInt64/factor {
+		return 0, false
+	}
+	return n * factor, true
+}
+
+// Section returns all key-value pairs in section
+func (c *GitConfig) Section(section string) map[string]string {
+	values := map[string]string{}
+	if c.data == nil {
+		return values
+	}
+	sec, err := c.data.GetSection(section)
+	if err != nil {
+		return values
+	}
+	for key, value := range sec.KeysHash() {
+		values[key] = unquote(value)
+	}
+	return values
+}
+
+// Sections returns the names of all sections, e.g. `remote "origin"`
+func (c *GitConfig) Sections() []string {
+	names := []string{}
+	if c.data == nil {
+		return names
+	}
+	for _, name := range c.data.SectionStrings() {
+		// The ini library always has a default section for keys without one
+		if name == ini.DefaultSection {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// unquote interprets a value like git: parts of it can be between double
+// quotes, which keep whitespace and ; or # that would otherwise start a
+// comment, and \\, \", \n, \t and \b are escapes
+func unquote(raw string) string {
+	var value strings.Builder
+	quoted := false
+	// Whitespace outside quotes is only kept between other characters
+	spaces := 0
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if !quoted {
+			if c == ' ' || c == '\t' {
+				spaces++
+				continue
+			}
+			if c == ';' || c == '#' {
+				break
+			}
+		}
+		if value.Len() > 0 {
+			value.WriteString(strings.Repeat(" ", spaces))
+		}
+		spaces = 0
+
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 't':
+				value.WriteByte('\t')
+			case 'n':
+				value.WriteByte('\n')
+			case 'b':
+				value.WriteByte('\b')
+			case '\\', '"':
+				value.WriteByte(raw[i])
+			default:
+				// git rejects other escapes, we keep them as they are
+				value.WriteByte('\\')
+				value.WriteByte(raw[i])
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return value.String()
 }
@@ -0,0 +1,53 @@
+package config
+
+import "testing"
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "plain", want: "plain"},
+		{raw: "two  words", want: "two  words"},
+		{raw: `"quoted value"`, want: "quoted value"},
+		{raw: "value ; comment", want: "value"},
+		{raw: "value # comment", want: "value"},
+		{raw: `"a ; b" c`, want: "a ; b c"},
+		{raw: `"echo \"%A\"; exit 1"`, want: `echo "%A"; exit 1`},
+		{raw: `tab\there\nnewline`, want: "tab\there\nnewline"},
+		{raw: `C:\\path`, want: `C:\path`},
+		{raw: `"  padded  "`, want: "  padded  "},
+		{raw: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := unquote(tt.raw); got != tt.want {
+			t.Errorf("unquote(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		val    string
+		want   int64
+		wantOk bool
+	}{
+		{"0", 0, true},
+		{"512", 512, true},
+		{"-3", -3, true},
+		{"1k", 1024, true},
+		{"512m", 512 << 20, true},
+		{"2G", 2 << 30, true},
+		{"", 0, false},
+		{"k", 0, false},
+		{"1.5m", 0, false},
+		{"12 m", 0, false},
+		{"9999999999g", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseInt(tt.val)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("parseInt(%q) = %d, %v, want %d, %v", tt.val, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+)
+
+// Entry is a key and its value, to write to a configuration file
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// AppendSection adds a section with entries at the end of the
+// configuration file at path. The section is named like Get takes it,
+// e.g. `remote "origin"`.
+func AppendSection(path, section string, entries ...Entry) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	lines := splitLines(string(data))
+	lines = append(lines, sectionLines(section, entries)...)
+	return writeLines(path, lines)
+}
+
+// Set sets a key in the configuration file at path. The last value of the
+// key is replaced, or the key is added to the last of its sections, or to
+// a new section at the end.
+func Set(path, section, key, value string) error {
+	return setKey(path, section, key, value, true)
+}
+
+// Add adds a value to a key that can be given multiple times, like
+// remote.<name>.fetch, in the configuration file at path. It goes at the
+// end of the last section named section, or a new section at the end.
+func Add(path, section, key, value string) error {
+	return setKey(path, section, key, value, false)
+}
+
+func setKey(path, section, key, value string, replace bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	lines := splitLines(string(data))
+	line := "\t" + key + " = " + formatValue(value)
+
+	keyLine, sectionEnd := -1, -1
+	inSection := false
+	for i, l := range lines {
+		if name, ok := sectionHeader(l); ok {
+			inSection = name == canonicalSection(section)
+			if inSection {
+				sectionEnd = i + 1
+			}
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		sectionEnd = i + 1
+		if k, _, _ := strings.Cut(strings.TrimSpace(l), "="); replace && strings.EqualFold(strings.TrimSpace(k), key) {
+			keyLine = i
+		}
+	}
+	switch {
+	case keyLine >= 0:
+		lines[keyLine] = line
+	case sectionEnd >= 0:
+		lines = append(lines[:sectionEnd], append([]string{line}, lines[sectionEnd:]...)...)
+	default:
+		lines = append(lines, sectionLines(section, []Entry{{key, value}})...)
+	}
+	return writeLines(path, lines)
+}
+
+// Unset removes all values of a key in section from the configuration
+// file at path
+func Unset(path, section, key string) error {
+	return editSections(path, func(name string, header string, body []string) []string {
+		if name != canonicalSection(section) {
+			return append([]string{header}, body...)
+		}
+		kept := []string{header}
+		for _, l := range body {
+			if k, _, _ := strings.Cut(strings.TrimSpace(l), "="); !strings.EqualFold(strings.TrimSpace(k), key) {
+				kept = append(kept, l)
+			}
+		}
+		return kept
+	})
+}
+
+// RemoveSection removes every section named section, with its keys, from
+// the configuration file at path
+func RemoveSection(path, section string) error {
+	return editSections(path, func(name string, header string, body []string) []string {
+		if name == canonicalSection(section) {
+			return nil
+		}
+		return append([]string{header}, body...)
+	})
+}
+
+// RenameSection renames every section named old in the configuration
+// file at path to new, e.g. `remote "origin"` to `remote "upstream"`
+func RenameSection(path, old, new string) error {
+	return editSections(path, func(name string, header string, body []string) []string {
+		if name == canonicalSection(old) {
+			header = "[" + new + "]"
+		}
+		return append([]string{header}, body...)
+	})
+}
+
+// editSections replaces each section of the configuration file at path,
+// its header line and the lines up to the next section, with what edit
+// returns for it. Lines before the first section are kept.
+func editSections(path string, edit func(name, header string, body []string) []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := splitLines(string(data))
+	edited := []string{}
+	start := len(lines)
+	for i, l := range lines {
+		if _, ok := sectionHeader(l); ok {
+			start = i
+			break
+		}
+		edited = append(edited, l)
+	}
+	for start < len(lines) {
+		end := start + 1
+		for end < len(lines) {
+			if _, ok := sectionHeader(lines[end]); ok {
+				break
+			}
+			end++
+		}
+		name, _ := sectionHeader(lines[start])
+		edited = append(edited, edit(name, lines[start], lines[start+1:end])...)
+		start = end
+	}
+	return writeLines(path, edited)
+}
+
+// sectionHeader returns the name of the section that a line starts, like
+// Get takes it
+func sectionHeader(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") {
+		return "", false
+	}
+	end := strings.LastIndex(line, "]")
+	if end < 0 {
+		return "", false
+	}
+	return canonicalSection(line[1:end]), true
+}
+
+// canonicalSection lowercases the name of a section, but not its
+// subsection, since only section names are case-insensitive
+func canonicalSection(section string) string {
+	name, sub, ok := strings.Cut(section, " ")
+	if !ok {
+		return strings.ToLower(section)
+	}
+	return strings.ToLower(name) + " " + strings.TrimSpace(sub)
+}
+
+func sectionLines(section string, entries []Entry) []string {
+	lines := []string{"[" + section + "]"}
+	for _, entry := range entries {
+		lines = append(lines, "\t"+entry.Key+" = "+formatValue(entry.Value))
+	}
+	return lines
+}
+
+func splitLines(data string) []string {
+	if data == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+}
+
+func writeLines(path string, lines []string) error {
+	return fs.AtomicWrite(path, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// formatValue quotes a value when it would not be read back as it is,
+// like git does
+func formatValue(value string) string {
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "\"\\;#\n") {
+		return quote(value)
+	}
+	return value
+}
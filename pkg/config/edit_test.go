@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("[core]\n\tbare = false"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := AppendSection(path, `remote "origin"`,
+		Entry{"url", "https://example.com/repo.git"},
+		Entry{"fetch", "+refs/heads/*:refs/remotes/origin/*"},
+		Entry{"note", ` a "quoted" ; value`})
+	if err != nil {
+		t.Fatalf("AppendSection() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "[core]\n\tbare = false\n[remote \"origin\"]\n" +
+		"\turl = https://example.com/repo.git\n" +
+		"\tfetch = +refs/heads/*:refs/remotes/origin/*\n" +
+		"\tnote = \" a \\\"quoted\\\" ; value\"\n"
+	if string(data) != want {
+		t.Errorf("AppendSection() wrote %q, want %q", data, want)
+	}
+
+	cfg, err := ReadWithRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"url": "https://example.com/repo.git", "fetch": "+refs/heads/*:refs/remotes/origin/*", "note": ` a "quoted" ; value`} {
+		if got, _ := cfg.Get(`remote "origin"`, key); got != want {
+			t.Errorf("Get(remote.origin.%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	contents := "[core]\n\tbare = false\n\tsparseCheckout = false\n[Remote \"origin\"]\n\turl = /a\n[user]\n\tname = A\n"
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, set := range []struct{ section, key, value string }{
+		{"core", "sparsecheckout", "true"},
+		{`remote "origin"`, "fetch", "+refs/heads/*:refs/remotes/origin/*"},
+		{"index", "sparse", "true"},
+	} {
+		if err := Set(path, set.section, set.key, set.value); err != nil {
+			t.Fatalf("Set() error: %v", err)
+		}
+	}
+	if err := Add(path, `remote "origin"`, "fetch", "+refs/tags/*:refs/tags/*"); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "[core]\n\tbare = false\n\tsparsecheckout = true\n" +
+		"[Remote \"origin\"]\n\turl = /a\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n\tfetch = +refs/tags/*:refs/tags/*\n" +
+		"[user]\n\tname = A\n" +
+		"[index]\n\tsparse = true\n"
+	if string(data) != want {
+		t.Errorf("Set() wrote %q, want %q", data, want)
+	}
+}
+
+func TestEditSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	contents := "[core]\n\tbare = false\n[remote \"origin\"]\n\turl = /a\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n" +
+		"[branch \"main\"]\n\tremote = origin\n\tmerge = refs/heads/main\n[remote \"old\"]\n\turl = /b\n[Remote \"old\"]\n\tpushurl = /c\n"
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveSection(path, `remote "old"`); err != nil {
+		t.Fatalf("RemoveSection() error: %v", err)
+	}
+	if err := RenameSection(path, `remote "origin"`, `remote "upstream"`); err != nil {
+		t.Fatalf("RenameSection() error: %v", err)
+	}
+	if err := Unset(path, `branch "main"`, "merge"); err != nil {
+		t.Fatalf("Unset() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "[core]\n\tbare = false\n[remote \"upstream\"]\n\turl = /a\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n" +
+		"[branch \"main\"]\n\tremote = origin\n"
+	if string(data) != want {
+		t.Errorf("config = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// applyEnv adds the configuration given in the environment like git does
+// it: GIT_CONFIG_COUNT is the number of entries, and GIT_CONFIG_KEY_<n>
+// and GIT_CONFIG_VALUE_<n> are the key and value of entry n, counting
+// from 0. They come after the configuration files, so they win.
+func (c *GitConfig) applyEnv() error {
+	raw, ok := os.LookupEnv("GIT_CONFIG_COUNT")
+	if !ok || raw == "" {
+		return nil
+	}
+	count, err := strconv.Atoi(raw)
+	if err != nil || count < 0 {
+		return fmt.Errorf("bogus count in GIT_CONFIG_COUNT: %s", raw)
+	}
+
+	for i := 0; i < count; i++ {
+		key, ok := os.LookupEnv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i))
+		if !ok {
+			return fmt.Errorf("missing config key GIT_CONFIG_KEY_%d", i)
+		}
+		value, ok := os.LookupEnv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i))
+		if !ok {
+			return fmt.Errorf("missing config value GIT_CONFIG_VALUE_%d", i)
+		}
+		section, name, err := SplitKey(key)
+		if err != nil {
+			return err
+		}
+		if _, err := c.data.Section(section).NewKey(name, quote(value)); err != nil {
+			return fmt.Errorf("failed to set %s from the environment: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// SplitKey splits a key like remote.origin.url into the name of its
+// section, e.g. `remote "origin"`, and the name of the key. Like git,
+// section names are case-insensitive and subsections are not.
+func SplitKey(key string) (string, string, error) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", "", fmt.Errorf("invalid config key: %s", key)
+	}
+	section := strings.ToLower(key[:first])
+	if first != last {
+		section = section + ` "` + key[first+1:last] + `"`
+	}
+	return section, key[last+1:], nil
+}
+
+// quote puts value between double quotes for unquote, since values from
+// the environment are taken as they are
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
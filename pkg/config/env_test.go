@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestApplyEnv(t *testing.T) {
+	repoConfig := filepath.Join(t.TempDir(), "config")
+	contents := "[user]\n\tname = From File\n[remote \"origin\"]\n\turl = /from/file\n"
+	if err := os.WriteFile(repoConfig, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_COUNT", "4")
+	t.Setenv("GIT_CONFIG_KEY_0", "user.name")
+	t.Setenv("GIT_CONFIG_VALUE_0", "CI Bot")
+	t.Setenv("GIT_CONFIG_KEY_1", "User.email")
+	t.Setenv("GIT_CONFIG_VALUE_1", `"ci"@example.com ; not a comment`)
+	t.Setenv("GIT_CONFIG_KEY_2", "remote.origin.url")
+	t.Setenv("GIT_CONFIG_VALUE_2", "/from/env")
+	t.Setenv("GIT_CONFIG_KEY_3", "transfer.hideRefs")
+	t.Setenv("GIT_CONFIG_VALUE_3", "refs/hidden")
+
+	cfg, err := ReadWithRepository(repoConfig)
+	if err != nil {
+		t.Fatalf("ReadWithRepository() error: %v", err)
+	}
+	tests := []struct {
+		section, key, want string
+	}{
+		{"user", "name", "CI Bot"},
+		{"user", "email", `"ci"@example.com ; not a comment`},
+		{`remote "origin"`, "url", "/from/env"},
+		{"transfer", "hideRefs", "refs/hidden"},
+	}
+	for _, tt := range tests {
+		if got, _ := cfg.Get(tt.section, tt.key); got != tt.want {
+			t.Errorf("Get(%s, %s) = %q, want %q", tt.section, tt.key, got, tt.want)
+		}
+	}
+	if got := cfg.GetAll(`remote "origin"`, "url"); len(got) != 2 {
+		t.Errorf("GetAll() = %q, want the value of the file and of the environment", got)
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{name: "bogus count", env: map[string]string{"GIT_CONFIG_COUNT": "many"}},
+		{name: "missing key", env: map[string]string{"GIT_CONFIG_COUNT": "1", "GIT_CONFIG_VALUE_0": "x"}},
+		{name: "missing value", env: map[string]string{"GIT_CONFIG_COUNT": "1", "GIT_CONFIG_KEY_0": "user.name"}},
+		{name: "no section", env: map[string]string{"GIT_CONFIG_COUNT": "1", "GIT_CONFIG_KEY_0": "name", "GIT_CONFIG_VALUE_0": "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg := GitConfig{data: ini.Empty(loadOptions)}
+			if err := cfg.applyEnv(); err == nil {
+				t.Errorf("applyEnv() should fail")
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	// Global configuration files that don't exist are fine
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, err := Read(); err != nil {
+		t.Fatalf("Read() without configuration files error = %v", err)
+	}
+
+	t.Setenv("GIT_CONFIG_COUNT", "bogus")
+	_, err := Read()
+	var readErr *ReadError
+	if !errors.As(err, &readErr) {
+		t.Errorf("Read() with a bogus GIT_CONFIG_COUNT error = %v, want a ReadError", err)
+	}
+}
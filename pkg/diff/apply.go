@@ -0,0 +1,53 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ApplyHunks applies hunks to a, the old side they were made from, and
+// returns the result. Applying some of the hunks of Hunks(a, b) gives a
+// version between a and b, which is how single hunks are staged. The hunks
+// have to be in order and can't overlap.
+func ApplyHunks(a []byte, hunks []Hunk) ([]byte, error) {
+	lines := SplitLines(a)
+	var result bytes.Buffer
+	next := 0
+	for _, hunk := range hunks {
+		// A hunk without old lines comes after line OldStart
+		start := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			start = hunk.OldStart
+		}
+		if start < next || start+hunk.OldLines > len(lines) {
+			return nil, fmt.Errorf("hunk %s does not apply", hunk.Header())
+		}
+		for _, line := range lines[next:start] {
+			result.Write(line)
+		}
+
+		i := start
+		for _, line := range hunk.Lines {
+			if line.Op == Insert {
+				result.Write(line.Text)
+				continue
+			}
+			if i >= len(lines) || !bytes.Equal(lines[i], line.Text) {
+				return nil, fmt.Errorf("hunk %s does not apply", hunk.Header())
+			}
+			if line.Op == Equal {
+				result.Write(line.Text)
+			}
+			i++
+		}
+		if i != start+hunk.OldLines {
+			return nil, errors.New("hunk header does not match its lines")
+		}
+		next = i
+	}
+	for _, line := range lines[next:] {
+		result.Write(line)
+	}
+	return result.Bytes(), nil
+}
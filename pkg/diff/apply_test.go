@@ -0,0 +1,86 @@
+package diff
+
+import "testing"
+
+func TestApplyHunks(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		// apply are the indexes of the hunks of Hunks(a, b, 1) to apply
+		apply   []int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "all hunks",
+			a:     "1\n2\n3\n4\n5\n6\n7\n",
+			b:     "1\nx\n3\n4\n5\ny\n7\n",
+			apply: []int{0, 1},
+			want:  "1\nx\n3\n4\n5\ny\n7\n",
+		},
+		{
+			name:  "first hunk",
+			a:     "1\n2\n3\n4\n5\n6\n7\n",
+			b:     "1\nx\n3\n4\n5\ny\n7\n",
+			apply: []int{0},
+			want:  "1\nx\n3\n4\n5\n6\n7\n",
+		},
+		{
+			name:  "second hunk",
+			a:     "1\n2\n3\n4\n5\n6\n7\n",
+			b:     "1\nx\n3\n4\n5\ny\n7\n",
+			apply: []int{1},
+			want:  "1\n2\n3\n4\n5\ny\n7\n",
+		},
+		{
+			name:  "new file",
+			a:     "",
+			b:     "new\n",
+			apply: []int{0},
+			want:  "new\n",
+		},
+		{
+			name:  "no newline at end",
+			a:     "x\n",
+			b:     "x",
+			apply: []int{0},
+			want:  "x",
+		},
+		{
+			name:  "deleted lines",
+			a:     "1\n2\n3\n",
+			b:     "1\n",
+			apply: []int{0},
+			want:  "1\n",
+		},
+		{
+			name:    "out of order",
+			a:       "1\n2\n3\n4\n5\n6\n7\n",
+			b:       "1\nx\n3\n4\n5\ny\n7\n",
+			apply:   []int{1, 0},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := Hunks([]byte(tt.a), []byte(tt.b), 1)
+			hunks := []Hunk{}
+			for _, i := range tt.apply {
+				hunks = append(hunks, all[i])
+			}
+			got, err := ApplyHunks([]byte(tt.a), hunks)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyHunks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("ApplyHunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Hunks that don't match the contents are refused
+	hunks := Hunks([]byte("1\n2\n"), []byte("1\nx\n"), 1)
+	if _, err := ApplyHunks([]byte("1\n3\n"), hunks); err == nil {
+		t.Error("ApplyHunks() applied a hunk to other contents")
+	}
+}
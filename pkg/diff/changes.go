@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+// The status letters of a change, like in git's raw diff format
+const (
+	StatusAdded    = 'A'
+	StatusCopied   = 'C'
+	StatusDeleted  = 'D'
+	StatusModified = 'M'
+	StatusRenamed  = 'R'
+)
+
+// Change is a file that differs between two trees
+type Change struct {
+	Status byte
+	// From and To are the names of the file on both sides, which are only
+	// different for renames and copies
+	From, To string
+	// OldSHA and NewSHA are nil if the file doesn't exist on that side
+	OldSHA, NewSHA *hashing.SHA
+	// Score is how similar the files of a rename or copy are, up to MaxScore
+	Score int
+}
+
+// ChangeOptions decide how deleted and added files are paired
+type ChangeOptions struct {
+	// RenameScore is the minimum similarity of a rename, or 0 to not
+	// look for renames
+	RenameScore int
+	// CopyScore is the minimum similarity of a copy, or 0 to not look
+	// for copies. Like git, only files that were modified are copied
+	// from, unless FindCopiesHarder is set.
+	CopyScore        int
+	FindCopiesHarder bool
+}
+
+// Changes returns the files that differ between old and new, sorted by
+// their new name
+func Changes(old, new map[string]*hashing.SHA, contents func(sha *hashing.SHA) ([]byte, error), opts ChangeOptions) ([]Change, error) {
+	changes := []Change{}
+	paired := map[string]bool{}
+	if opts.RenameScore > 0 {
+		renames, err := DetectRenames(old, new, contents, opts.RenameScore)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range renames {
+			changes = append(changes, Change{Status: StatusRenamed, From: r.From, To: r.To, OldSHA: old[r.From], NewSHA: new[r.To], Score: r.Score})
+			paired[r.From], paired[r.To] = true, true
+		}
+	}
+	if opts.CopyScore > 0 {
+		copies, err := detectCopies(old, new, paired, contents, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range copies {
+			changes = append(changes, Change{Status: StatusCopied, From: c.From, To: c.To, OldSHA: old[c.From], NewSHA: new[c.To], Score: c.Score})
+			paired[c.To] = true
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(old)) {
+		if _, ok := new[name]; !ok && !paired[name] {
+			changes = append(changes, Change{Status: StatusDeleted, From: name, To: name, OldSHA: old[name]})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(new)) {
+		oldSha, ok := old[name]
+		switch {
+		case paired[name] && !ok:
+		case !ok:
+			changes = append(changes, Change{Status: StatusAdded, From: name, To: name, NewSHA: new[name]})
+		case oldSha.AsString() != new[name].AsString():
+			changes = append(changes, Change{Status: StatusModified, From: name, To: name, OldSHA: oldSha, NewSHA: new[name]})
+		}
+	}
+	slices.SortStableFunc(changes, func(a, b Change) int { return cmp.Compare(a.To, b.To) })
+	return changes, nil
+}
+
+// detectCopies finds the added files that weren't renamed and are similar
+// to a file that is still there. Identical files are preferred, then the
+// most similar file, then the one with the same name.
+func detectCopies(old, new map[string]*hashing.SHA, renamed map[string]bool, contents func(sha *hashing.SHA) ([]byte, error), opts ChangeOptions) ([]Rename, error) {
+	sources := []*renameFile{}
+	for _, name := range slices.Sorted(maps.Keys(old)) {
+		sha, ok := new[name]
+		if ok && (opts.FindCopiesHarder || sha.AsString() != old[name].AsString()) {
+			sources = append(sources, &renameFile{name: name, sha: old[name]})
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	load := func(f *renameFile) error {
+		if f.data != nil {
+			return nil
+		}
+		data, err := contents(f.sha)
+		f.data = data
+		return err
+	}
+
+	copies := []Rename{}
+	for _, name := range slices.Sorted(maps.Keys(new)) {
+		if _, ok := old[name]; ok || renamed[name] {
+			continue
+		}
+		dst := &renameFile{name: name, sha: new[name]}
+		if err := load(dst); err != nil {
+			return nil, err
+		}
+		if len(dst.data) == 0 {
+			continue
+		}
+		best := renameCandidate{}
+		for _, src := range sources {
+			score := MaxScore
+			if src.sha.AsString() != dst.sha.AsString() {
+				if err := load(src); err != nil {
+					return nil, err
+				}
+				if len(src.data) == 0 {
+					continue
+				}
+				score = similarity(src, dst, opts.CopyScore)
+			}
+			candidate := renameCandidate{src: src, dst: dst, score: score}
+			if score >= opts.CopyScore && (best.src == nil || compareCandidates(candidate, best) < 0) {
+				best = candidate
+			}
+		}
+		if best.src != nil {
+			copies = append(copies, Rename{From: best.src.name, To: dst.name, Score: best.score})
+		}
+	}
+	return copies, nil
+}
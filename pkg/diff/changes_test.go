@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+func TestChanges(t *testing.T) {
+	long := strings.Repeat("line of text\n", 20)
+	tests := []struct {
+		name     string
+		old, new map[string]string
+		opts     ChangeOptions
+		// want has the status, the names and the score of each change
+		want []Change
+	}{
+		{
+			name: "added, deleted and modified",
+			old:  map[string]string{"a": "a\n", "b": "b\n", "same": "same\n"},
+			new:  map[string]string{"b": "changed\n", "c": "c\n", "same": "same\n"},
+			want: []Change{
+				{Status: StatusDeleted, From: "a", To: "a"},
+				{Status: StatusModified, From: "b", To: "b"},
+				{Status: StatusAdded, From: "c", To: "c"},
+			},
+		},
+		{
+			name: "renames are not found without a score",
+			old:  map[string]string{"a": long},
+			new:  map[string]string{"b": long},
+			want: []Change{
+				{Status: StatusDeleted, From: "a", To: "a"},
+				{Status: StatusAdded, From: "b", To: "b"},
+			},
+		},
+		{
+			name: "rename sorts by its new name",
+			old:  map[string]string{"z": long, "m": "m\n"},
+			new:  map[string]string{"a": long, "m": "changed\n"},
+			opts: ChangeOptions{RenameScore: DefaultRenameScore},
+			want: []Change{
+				{Status: StatusRenamed, From: "z", To: "a", Score: MaxScore},
+				{Status: StatusModified, From: "m", To: "m"},
+			},
+		},
+		{
+			name: "copy of a modified file",
+			old:  map[string]string{"src": long},
+			new:  map[string]string{"src": long + "more\n", "copy": long},
+			opts: ChangeOptions{RenameScore: DefaultRenameScore, CopyScore: DefaultRenameScore},
+			want: []Change{
+				{Status: StatusCopied, From: "src", To: "copy", Score: MaxScore},
+				{Status: StatusModified, From: "src", To: "src"},
+			},
+		},
+		{
+			name: "copy of an unmodified file needs harder",
+			old:  map[string]string{"src": long},
+			new:  map[string]string{"src": long, "copy": long},
+			opts: ChangeOptions{RenameScore: DefaultRenameScore, CopyScore: DefaultRenameScore},
+			want: []Change{
+				{Status: StatusAdded, From: "copy", To: "copy"},
+			},
+		},
+		{
+			name: "copy of an unmodified file",
+			old:  map[string]string{"src": long},
+			new:  map[string]string{"src": long, "copy": long + "end\n"},
+			opts: ChangeOptions{RenameScore: DefaultRenameScore, CopyScore: DefaultRenameScore, FindCopiesHarder: true},
+			want: []Change{
+				{Status: StatusCopied, From: "src", To: "copy", Score: 260 * MaxScore / 264},
+			},
+		},
+		{
+			name: "renames come before copies",
+			old:  map[string]string{"gone": long, "src": long},
+			new:  map[string]string{"src": long + "more\n", "new": long},
+			opts: ChangeOptions{RenameScore: DefaultRenameScore, CopyScore: DefaultRenameScore},
+			want: []Change{
+				{Status: StatusRenamed, From: "gone", To: "new", Score: MaxScore},
+				{Status: StatusModified, From: "src", To: "src"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := map[string][]byte{}
+			old, new := renameTrees(tt.old, blobs), renameTrees(tt.new, blobs)
+			contents := func(sha *hashing.SHA) ([]byte, error) { return blobs[sha.AsString()], nil }
+			changes, err := Changes(old, new, contents, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []Change{}
+			for _, c := range changes {
+				if !sameBlob(c.OldSHA, old[c.From]) || !sameBlob(c.NewSHA, new[c.To]) {
+					t.Errorf("hashes of %c %s -> %s don't match the trees", c.Status, c.From, c.To)
+				}
+				got = append(got, Change{Status: c.Status, From: c.From, To: c.To, Score: c.Score})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Changes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func sameBlob(a, b *hashing.SHA) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.AsString() == b.AsString()
+}
@@ -0,0 +1,288 @@
+package diff
+
+import (
+	"bytes"
+	"cmp"
+)
+
+// diffFile is one side of a diff. changed has an extra false entry at the
+// end, so groups can be scanned without bounds checks.
+type diffFile struct {
+	lines   [][]byte
+	changed []bool
+}
+
+// group is a run of changed lines [start, end). Groups are delimited by
+// unchanged lines, so the nth group of both files belong together.
+type group struct {
+	start, end int
+}
+
+func (f *diffFile) first() group {
+	g := group{}
+	for f.changed[g.end] {
+		g.end++
+	}
+	return g
+}
+
+func (f *diffFile) next(g *group) bool {
+	if g.end == len(f.lines) {
+		return false
+	}
+	g.start = g.end + 1
+	g.end = g.start
+	for f.changed[g.end] {
+		g.end++
+	}
+	return true
+}
+
+func (f *diffFile) previous(g *group) bool {
+	if g.start == 0 {
+		return false
+	}
+	g.end = g.start - 1
+	g.start = g.end
+	for g.start > 0 && f.changed[g.start-1] {
+		g.start--
+	}
+	return true
+}
+
+func (f *diffFile) slideDown(g *group) bool {
+	if g.end >= len(f.lines) || !bytes.Equal(f.lines[g.start], f.lines[g.end]) {
+		return false
+	}
+	f.changed[g.start], f.changed[g.end] = false, true
+	g.start, g.end = g.start+1, g.end+1
+	for f.changed[g.end] {
+		g.end++
+	}
+	return true
+}
+
+func (f *diffFile) slideUp(g *group) bool {
+	if g.start == 0 || !bytes.Equal(f.lines[g.start-1], f.lines[g.end-1]) {
+		return false
+	}
+	f.changed[g.start-1], f.changed[g.end-1] = true, false
+	g.start, g.end = g.start-1, g.end-1
+	for g.start > 0 && f.changed[g.start-1] {
+		g.start--
+	}
+	return true
+}
+
+// compactFile is xdl_change_compact. Groups of changes are moved down as
+// far as possible, unless they can line up with a change in the other
+// file. With the indent heuristic, which git diff uses by default, groups
+// are moved to where the lines around them look best instead.
+func compactFile(f, other *diffFile, indentHeuristic bool) {
+	g, og := f.first(), other.first()
+	for {
+		if g.end != g.start {
+			var size, earliestEnd, endMatchingOther int
+			for size != g.end-g.start {
+				size = g.end - g.start
+				endMatchingOther = -1
+
+				// Slide up as far as possible, joining groups on the way
+				for f.slideUp(&g) {
+					other.previous(&og)
+				}
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+
+				// Then slide down as far as possible
+				for f.slideDown(&g) {
+					other.next(&og)
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+			}
+
+			switch {
+			case g.end == earliestEnd:
+				// The group can't move
+			case endMatchingOther != -1:
+				// Prefer lining up with a change in the other file
+				for og.end == og.start {
+					f.slideUp(&g)
+					other.previous(&og)
+				}
+			case indentHeuristic:
+				best := f.bestShift(g, earliestEnd)
+				for g.end > best {
+					f.slideUp(&g)
+					other.previous(&og)
+				}
+			}
+		}
+
+		if !f.next(&g) {
+			return
+		}
+		other.next(&og)
+	}
+}
+
+// Weights of the indent heuristic, tuned by git on a corpus of
+// human-reviewed diffs
+const (
+	maxIndent                       = 200
+	maxBlanks                       = 20
+	startOfFilePenalty              = 1
+	endOfFilePenalty                = 21
+	totalBlankWeight                = -30
+	postBlankWeight                 = 6
+	relativeIndentPenalty           = -4
+	relativeIndentWithBlankPenalty  = 10
+	relativeOutdentPenalty          = 24
+	relativeOutdentWithBlankPenalty = 17
+	relativeDedentPenalty           = 23
+	relativeDedentWithBlankPenalty  = 17
+	indentWeight                    = 60
+	indentHeuristicMaxSliding       = 100
+)
+
+// bestShift returns the end position of g, between earliestEnd and its
+// current end, where the splits before and after the group score best
+func (f *diffFile) bestShift(g group, earliestEnd int) int {
+	size := g.end - g.start
+	shift := max(earliestEnd, g.end-size-1, g.end-indentHeuristicMaxSliding)
+
+	best := -1
+	var bestScore splitScore
+	for ; shift <= g.end; shift++ {
+		score := splitScore{}
+		score.add(f.measureSplit(shift))
+		score.add(f.measureSplit(shift - size))
+		if best == -1 || score.compare(bestScore) <= 0 {
+			best, bestScore = shift, score
+		}
+	}
+	return best
+}
+
+// splitMeasurement describes the lines around a split between two lines
+type splitMeasurement struct {
+	endOfFile bool
+	// Indent of the line after the split, or -1 if it is blank
+	indent     int
+	preBlank   int
+	preIndent  int
+	postBlank  int
+	postIndent int
+}
+
+func (f *diffFile) measureSplit(split int) splitMeasurement {
+	m := splitMeasurement{indent: -1, preIndent: -1, postIndent: -1}
+	if split >= len(f.lines) {
+		m.endOfFile = true
+	} else {
+		m.indent = lineIndent(f.lines[split])
+	}
+
+	for i := split - 1; i >= 0; i-- {
+		m.preIndent = lineIndent(f.lines[i])
+		if m.preIndent != -1 {
+			break
+		}
+		m.preBlank++
+		if m.preBlank == maxBlanks {
+			m.preIndent = 0
+			break
+		}
+	}
+
+	for i := split + 1; i < len(f.lines); i++ {
+		m.postIndent = lineIndent(f.lines[i])
+		if m.postIndent != -1 {
+			break
+		}
+		m.postBlank++
+		if m.postBlank == maxBlanks {
+			m.postIndent = 0
+			break
+		}
+	}
+	return m
+}
+
+// lineIndent returns the width of the leading whitespace of line, with
+// tabs to multiples of 8, or -1 if the line is blank
+func lineIndent(line []byte) int {
+	indent := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 8 - indent%8
+		case '\n', '\v', '\f', '\r':
+			// Other whitespace doesn't count
+		default:
+			return indent
+		}
+		if indent >= maxIndent {
+			return maxIndent
+		}
+	}
+	return -1
+}
+
+type splitScore struct {
+	effectiveIndent int
+	penalty         int
+}
+
+func (s *splitScore) add(m splitMeasurement) {
+	if m.preIndent == -1 && m.preBlank == 0 {
+		s.penalty += startOfFilePenalty
+	}
+	if m.endOfFile {
+		s.penalty += endOfFilePenalty
+	}
+
+	postBlank := 0
+	if m.indent == -1 {
+		postBlank = 1 + m.postBlank
+	}
+	totalBlank := m.preBlank + postBlank
+	s.penalty += totalBlankWeight * totalBlank
+	s.penalty += postBlankWeight * postBlank
+
+	indent := m.indent
+	if indent == -1 {
+		indent = m.postIndent
+	}
+	anyBlanks := totalBlank != 0
+	s.effectiveIndent += indent
+
+	switch {
+	case indent == -1 || m.preIndent == -1 || indent == m.preIndent:
+		// No adjustments needed
+	case indent > m.preIndent:
+		s.penalty += pick(anyBlanks, relativeIndentWithBlankPenalty, relativeIndentPenalty)
+	case m.postIndent != -1 && m.postIndent > indent:
+		s.penalty += pick(anyBlanks, relativeOutdentWithBlankPenalty, relativeOutdentPenalty)
+	default:
+		s.penalty += pick(anyBlanks, relativeDedentWithBlankPenalty, relativeDedentPenalty)
+	}
+}
+
+// compare is negative if s is better than other
+func (s splitScore) compare(other splitScore) int {
+	return indentWeight*cmp.Compare(s.effectiveIndent, other.effectiveIndent) + s.penalty - other.penalty
+}
+
+func pick(cond bool, ifTrue, ifFalse int) int {
+	if cond {
+		return ifTrue
+	}
+	return ifFalse
+}
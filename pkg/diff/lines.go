@@ -0,0 +1,62 @@
+// Line based differences between files, computed like git's xdiff so the
+// results are the same as git's, and unified diff output.
+package diff
+
+import "bytes"
+
+// SplitLines splits data in lines, keeping the line terminators
+func SplitLines(data []byte) [][]byte {
+	lines := [][]byte{}
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			lines = append(lines, data)
+			break
+		}
+		lines = append(lines, data[:end+1])
+		data = data[end+1:]
+	}
+	return lines
+}
+
+// Match is a pair of equal lines at positions A and B
+type Match struct {
+	A, B int
+}
+
+// CommonLines returns the lines a and b have in common, in order. Like
+// git merge-file, the indent heuristic isn't used.
+func CommonLines(a, b [][]byte) []Match {
+	changedA, changedB := changes(a, b, false)
+	return matches(changedA, changedB)
+}
+
+// changes marks the lines of a and b that are not in common. The slices
+// have an extra false entry at the end.
+func changes(a, b [][]byte, indentHeuristic bool) ([]bool, []bool) {
+	changedA, changedB := make([]bool, len(a)+1), make([]bool, len(b)+1)
+	classesA, classesB := classify(a, b)
+	newXdiff(classesA, classesB, changedA, changedB).run()
+
+	fileA, fileB := &diffFile{a, changedA}, &diffFile{b, changedB}
+	compactFile(fileA, fileB, indentHeuristic)
+	compactFile(fileB, fileA, indentHeuristic)
+	return changedA, changedB
+}
+
+// matches pairs up the unchanged lines, in order
+func matches(changedA, changedB []bool) []Match {
+	matches := []Match{}
+	j := 0
+	for i := range len(changedA) - 1 {
+		if changedA[i] {
+			continue
+		}
+		for changedB[j] {
+			j++
+		}
+		matches = append(matches, Match{i, j})
+		j++
+	}
+	return matches
+}
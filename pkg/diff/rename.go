@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"cmp"
+	"maps"
+	"path"
+	"slices"
+
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+// MaxScore is the similarity score of identical files
+const MaxScore = 60000
+
+// DefaultRenameScore is the similarity from which a deleted and an added
+// file are a rename, 50% like git
+const DefaultRenameScore = MaxScore / 2
+
+// Each added file keeps this many of its best matches
+const candidatesPerFile = 4
+
+// Rename is a file that was deleted and added again under another name
+type Rename struct {
+	From, To string
+	// Score is how similar the contents are, up to MaxScore
+	Score int
+}
+
+// DetectRenames finds the files that were deleted between old and new
+// and added under another name, with contents that are at least minScore
+// similar. Like git, identical files are paired first, then files with
+// the same name in another directory, then the most similar files.
+// Empty files are never renames.
+func DetectRenames(old, new map[string]*hashing.SHA, contents func(sha *hashing.SHA) ([]byte, error), minScore int) ([]Rename, error) {
+	sources, destinations := []*renameFile{}, []*renameFile{}
+	for _, name := range slices.Sorted(maps.Keys(old)) {
+		if _, ok := new[name]; !ok {
+			sources = append(sources, &renameFile{name: name, sha: old[name]})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(new)) {
+		if _, ok := old[name]; !ok {
+			destinations = append(destinations, &renameFile{name: name, sha: new[name]})
+		}
+	}
+	if len(sources) == 0 || len(destinations) == 0 {
+		return nil, nil
+	}
+	for _, f := range append(slices.Clone(sources), destinations...) {
+		data, err := contents(f.sha)
+		if err != nil {
+			return nil, err
+		}
+		f.data = data
+	}
+	sources = slices.DeleteFunc(sources, func(f *renameFile) bool { return len(f.data) == 0 })
+	destinations = slices.DeleteFunc(destinations, func(f *renameFile) bool { return len(f.data) == 0 })
+
+	renames := []Rename{}
+	pair := func(src, dst *renameFile, score int) {
+		src.paired, dst.paired = true, true
+		renames = append(renames, Rename{From: src.name, To: dst.name, Score: score})
+	}
+	unpaired := func(files []*renameFile) []*renameFile {
+		return slices.DeleteFunc(slices.Clone(files), func(f *renameFile) bool { return f.paired })
+	}
+
+	// Identical files, preferring a source with the same name
+	for _, dst := range destinations {
+		var match *renameFile
+		for _, src := range sources {
+			if src.paired || src.sha.AsString() != dst.sha.AsString() {
+				continue
+			}
+			if match == nil || (path.Base(src.name) == path.Base(dst.name) && path.Base(match.name) != path.Base(dst.name)) {
+				match = src
+			}
+		}
+		if match != nil {
+			pair(match, dst, MaxScore)
+		}
+	}
+
+	// Then files with the same name, if no other remaining file on either
+	// side has that name and they are clearly similar
+	basenameScore := minScore + (MaxScore-minScore)/2
+	byName := func(files []*renameFile) map[string]*renameFile {
+		names := map[string]*renameFile{}
+		seen := map[string]bool{}
+		for _, f := range files {
+			base := path.Base(f.name)
+			if seen[base] {
+				delete(names, base)
+				continue
+			}
+			seen[base] = true
+			names[base] = f
+		}
+		return names
+	}
+	srcNames, dstNames := byName(unpaired(sources)), byName(unpaired(destinations))
+	for base, dst := range dstNames {
+		src, ok := srcNames[base]
+		if !ok {
+			continue
+		}
+		if score := similarity(src, dst, minScore); score >= basenameScore {
+			pair(src, dst, score)
+		}
+	}
+
+	// The best matches of the other files, most similar first
+	candidates := []renameCandidate{}
+	remaining := unpaired(sources)
+	for _, dst := range unpaired(destinations) {
+		best := []renameCandidate{}
+		for _, src := range remaining {
+			if score := similarity(src, dst, minScore); score >= minScore {
+				best = append(best, renameCandidate{src: src, dst: dst, score: score})
+			}
+		}
+		slices.SortStableFunc(best, compareCandidates)
+		candidates = append(candidates, best[:min(len(best), candidatesPerFile)]...)
+	}
+	slices.SortStableFunc(candidates, compareCandidates)
+	for _, c := range candidates {
+		if !c.src.paired && !c.dst.paired {
+			pair(c.src, c.dst, c.score)
+		}
+	}
+
+	slices.SortFunc(renames, func(a, b Rename) int { return cmp.Compare(a.To, b.To) })
+	return renames, nil
+}
+
+type renameFile struct {
+	name   string
+	sha    *hashing.SHA
+	data   []byte
+	paired bool
+	// chunks is computed when the file is first compared
+	chunks map[uint32]int
+}
+
+type renameCandidate struct {
+	src, dst *renameFile
+	score    int
+}
+
+// compareCandidates sorts more similar files first, and files with the
+// same name first among equally similar ones
+func compareCandidates(a, b renameCandidate) int {
+	return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(b.sameName(), a.sameName()))
+}
+
+func (c renameCandidate) sameName() int {
+	if path.Base(c.src.name) == path.Base(c.dst.name) {
+		return 1
+	}
+	return 0
+}
+
+// similarity estimates how much of src is still in dst, like git: both
+// files are cut into chunks that end at a newline or after 64 bytes, and
+// the bytes in chunks they have in common are counted. Files whose sizes
+// are too different to reach minScore get 0.
+func similarity(src, dst *renameFile, minScore int) int {
+	maxSize, baseSize := max(len(src.data), len(dst.data)), min(len(src.data), len(dst.data))
+	if maxSize*(MaxScore-minScore) < (maxSize-baseSize)*MaxScore {
+		return 0
+	}
+	if src.chunks == nil {
+		src.chunks = chunks(src.data)
+	}
+	if dst.chunks == nil {
+		dst.chunks = chunks(dst.data)
+	}
+	copied := 0
+	for hash, count := range src.chunks {
+		copied += min(count, dst.chunks[hash])
+	}
+	return int(int64(copied) * MaxScore / int64(maxSize))
+}
+
+// chunkHashBase is the number of distinct chunk hashes
+const chunkHashBase = 107927
+
+// chunks counts the bytes in the chunks of data by their hash. In text,
+// the CR of CRLF line endings is left out.
+func chunks(data []byte) map[uint32]int {
+	text := !IsBinary(data)
+	counts := map[uint32]int{}
+	n := 0
+	var accum1, accum2 uint32
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if text && c == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			continue
+		}
+		old1 := accum1
+		accum1 = (accum1 << 7) ^ (accum2 >> 25)
+		accum2 = (accum2 << 7) ^ (old1 >> 25)
+		accum1 += uint32(c)
+		n++
+		if n < 64 && c != '\n' {
+			continue
+		}
+		counts[(accum1+accum2*0x61)%chunkHashBase] += n
+		n, accum1, accum2 = 0, 0, 0
+	}
+	if n > 0 {
+		counts[(accum1+accum2*0x61)%chunkHashBase] += n
+	}
+	return counts
+}
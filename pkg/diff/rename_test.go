@@ -0,0 +1,87 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+// renameTrees hashes the contents of files so they can be looked up again
+func renameTrees(files map[string]string, blobs map[string][]byte) map[string]*hashing.SHA {
+	tree := map[string]*hashing.SHA{}
+	for name, data := range files {
+		sha := hashing.NewSHA([]byte(data))
+		blobs[sha.AsString()] = []byte(data)
+		tree[name] = sha
+	}
+	return tree
+}
+
+func TestDetectRenames(t *testing.T) {
+	long := strings.Repeat("line of text\n", 20)
+	tests := []struct {
+		name     string
+		old, new map[string]string
+		want     []Rename
+	}{
+		{
+			name: "identical",
+			old:  map[string]string{"a": "hello\n", "kept": "kept\n"},
+			new:  map[string]string{"b": "hello\n", "kept": "kept\n"},
+			want: []Rename{{From: "a", To: "b", Score: MaxScore}},
+		},
+		{
+			name: "identical prefers same name",
+			old:  map[string]string{"x/file": "same\n", "y/other": "same\n"},
+			new:  map[string]string{"z/other": "same\n"},
+			want: []Rename{{From: "y/other", To: "z/other", Score: MaxScore}},
+		},
+		{
+			name: "similar",
+			old:  map[string]string{"old.txt": long + "end\n"},
+			new:  map[string]string{"new.txt": long + "changed end\n"},
+			want: []Rename{{From: "old.txt", To: "new.txt", Score: 260 * MaxScore / 272}},
+		},
+		{
+			name: "too different",
+			old:  map[string]string{"old.txt": "a\nb\nc\n"},
+			new:  map[string]string{"new.txt": "x\ny\nz\n"},
+			want: []Rename{},
+		},
+		{
+			name: "empty files",
+			old:  map[string]string{"a": ""},
+			new:  map[string]string{"b": ""},
+			want: []Rename{},
+		},
+		{
+			name: "most similar pairs first",
+			old:  map[string]string{"a": long + "one\n", "b": long + "two\n"},
+			new:  map[string]string{"c": long + "two\n", "d": long + "one\ntwo\n"},
+			want: []Rename{
+				{From: "b", To: "c", Score: MaxScore},
+				{From: "a", To: "d", Score: 264 * MaxScore / 268},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := map[string][]byte{}
+			old, new := renameTrees(tt.old, blobs), renameTrees(tt.new, blobs)
+			contents := func(sha *hashing.SHA) ([]byte, error) { return blobs[sha.AsString()], nil }
+			got, err := DetectRenames(old, new, contents, DefaultRenameScore)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == nil {
+				got = []Rename{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectRenames() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultStatWidth is the width of a diffstat when the output isn't a
+// terminal, like git
+const DefaultStatWidth = 80
+
+// FileStat is the number of lines added to and deleted from a file. For
+// binary files, they are the new and old sizes in bytes.
+type FileStat struct {
+	Name    string
+	Added   int
+	Deleted int
+	Binary  bool
+}
+
+// Stat counts the changes between the old contents a and the new
+// contents b of a file
+func Stat(name string, a, b []byte) FileStat {
+	if IsBinary(a) || IsBinary(b) {
+		return FileStat{Name: name, Added: len(b), Deleted: len(a), Binary: true}
+	}
+	stat := FileStat{Name: name}
+	for _, line := range edits(SplitLines(a), SplitLines(b)) {
+		switch line.Op {
+		case Insert:
+			stat.Added++
+		case Delete:
+			stat.Deleted++
+		}
+	}
+	return stat
+}
+
+// StatLines renders the diffstat of a list of files, one line per file
+// followed by the summary, like git diff --stat:
+//
+//	main.go   | 12 +++++++---
+//	image.png | Bin 1024 -> 2048 bytes
+//	2 files changed, 7 insertions(+), 3 deletions(-)
+//
+// The bars are scaled so the lines are at most width wide.
+func StatLines(stats []FileStat, width int) []string {
+	maxChange, nameWidth, binWidth, numberWidth := 0, 0, 0, 0
+	for _, s := range stats {
+		nameWidth = max(nameWidth, len(s.Name))
+		if s.Binary {
+			// "Bin XXX -> YYY bytes" takes the place of the graph
+			binWidth = max(binWidth, 14+len(strconv.Itoa(s.Added))+len(strconv.Itoa(s.Deleted)))
+			// Counts are aligned with "Bin"
+			numberWidth = 3
+			continue
+		}
+		maxChange = max(maxChange, s.Added+s.Deleted)
+	}
+	numberWidth = max(numberWidth, len(strconv.Itoa(maxChange)))
+
+	// The name gets at least 10 columns and the graph 6
+	width = max(width, 16+6+numberWidth)
+	graphWidth := maxChange
+	if maxChange+4 <= binWidth {
+		graphWidth = binWidth - 4
+	}
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = max(width*3/8-numberWidth-6, 6)
+		}
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	lines := make([]string, 0, len(stats)+1)
+	for _, s := range stats {
+		// Names that are too long are cut at the start
+		name := s.Name
+		if len(name) > nameWidth {
+			name = name[len(name)-max(nameWidth-3, 0):]
+			if slash := strings.IndexByte(name, '/'); slash >= 0 {
+				name = name[slash:]
+			}
+			name = "..." + name
+		}
+		prefix := fmt.Sprintf(" %-*s | ", nameWidth, name)
+
+		if s.Binary {
+			if s.Added == 0 && s.Deleted == 0 {
+				lines = append(lines, prefix+fmt.Sprintf("%*s", numberWidth, "Bin"))
+			} else {
+				lines = append(lines, prefix+fmt.Sprintf("%*s %d -> %d bytes", numberWidth, "Bin", s.Deleted, s.Added))
+			}
+			continue
+		}
+
+		added, deleted := s.Added, s.Deleted
+		if graphWidth <= maxChange {
+			total := scaleLinear(added+deleted, graphWidth, maxChange)
+			if total < 2 && added > 0 && deleted > 0 {
+				total = 2
+			}
+			if added < deleted {
+				added = scaleLinear(added, graphWidth, maxChange)
+				deleted = total - added
+			} else {
+				deleted = scaleLinear(deleted, graphWidth, maxChange)
+				added = total - deleted
+			}
+		}
+		line := prefix + fmt.Sprintf("%*d", numberWidth, s.Added+s.Deleted)
+		if s.Added+s.Deleted > 0 {
+			line += " " + strings.Repeat("+", added) + strings.Repeat("-", deleted)
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, StatSummary(stats))
+}
+
+// scaleLinear scales a change to the width of the graph, rounding so that
+// every change gets at least one column
+func scaleLinear(change, width, maxChange int) int {
+	if change == 0 {
+		return 0
+	}
+	return 1 + change*(width-1)/maxChange
+}
+
+// StatSummary returns the last line of a diffstat, like
+// "2 files changed, 7 insertions(+), 3 deletions(-)"
+func StatSummary(stats []FileStat) string {
+	if len(stats) == 0 {
+		return " 0 files changed"
+	}
+	insertions, deletions := 0, 0
+	for _, s := range stats {
+		if !s.Binary {
+			insertions += s.Added
+			deletions += s.Deleted
+		}
+	}
+
+	summary := " " + plural(len(stats), "file changed", "files changed")
+	// Like git, the counts are only left out if the other one is shown
+	if insertions > 0 || deletions == 0 {
+		summary += ", " + plural(insertions, "insertion(+)", "insertions(+)")
+	}
+	if deletions > 0 || insertions == 0 {
+		summary += ", " + plural(deletions, "deletion(-)", "deletions(-)")
+	}
+	return summary
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// WriteStat writes the diffstat of a list of files
+func WriteStat(w io.Writer, stats []FileStat, width int) error {
+	for _, line := range StatLines(stats, width) {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
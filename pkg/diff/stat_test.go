@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	got := Stat("f", []byte("a\nb\nc\n"), []byte("a\nB\nc\nd\n"))
+	if want := (FileStat{Name: "f", Added: 2, Deleted: 1}); got != want {
+		t.Errorf("Stat() = %+v, want %+v", got, want)
+	}
+	got = Stat("bin", []byte("bin\x00"), []byte("bin\x00ary"))
+	if want := (FileStat{Name: "bin", Added: 7, Deleted: 4, Binary: true}); got != want {
+		t.Errorf("Stat() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStatLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats []FileStat
+		want  []string
+	}{
+		{
+			name: "small",
+			stats: []FileStat{
+				{Name: "a", Added: 1, Deleted: 1},
+				{Name: "empty"},
+				{Name: "gone", Deleted: 1},
+			},
+			want: []string{
+				" a     | 2 +-",
+				" empty | 0",
+				" gone  | 1 -",
+				" 3 files changed, 1 insertion(+), 2 deletions(-)",
+			},
+		},
+		{
+			name: "binary",
+			stats: []FileStat{
+				{Name: "a", Added: 3},
+				{Name: "bin", Added: 7, Binary: true},
+			},
+			want: []string{
+				" a   |   3 +++",
+				" bin | Bin 0 -> 7 bytes",
+				" 2 files changed, 3 insertions(+)",
+			},
+		},
+		{
+			name: "scaled",
+			stats: []FileStat{
+				{Name: "a", Added: 300},
+				{Name: "b", Added: 10, Deleted: 39},
+				{Name: strings.Repeat("x", 60), Deleted: 299},
+			},
+			want: []string{
+				" a                                                  | 300 +++++++++++++++++++++",
+				" b                                                  |  49 +---",
+				" ...xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx | 299 --------------------",
+				" 3 files changed, 310 insertions(+), 338 deletions(-)",
+			},
+		},
+		{
+			name: "long path",
+			stats: []FileStat{
+				{Name: "very/long/directory/name/that/goes/on/and/on/forever/file_with_a_long_name.go", Added: 1},
+			},
+			// The name is cut at a slash, so it is shorter than the column
+			want: []string{
+				" .../directory/name/that/goes/on/and/on/forever/file_with_a_long_name.go  | 1 +",
+				" 1 file changed, 1 insertion(+)",
+			},
+		},
+		{
+			name: "nothing",
+			want: []string{" 0 files changed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StatLines(tt.stats, DefaultStatWidth)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("StatLines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DefaultContext is the number of unchanged lines shown around a change
+const DefaultContext = 3
+
+// Like git, only the start of a file is checked for NUL bytes
+const binaryCheckSize = 8000
+
+// maxFunctionLength is the length git truncates function names to in
+// hunk headers
+const maxFunctionLength = 80
+
+// IsBinary reports whether git would treat data as binary, which is the
+// case if it has a NUL byte in its first 8000 bytes
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binaryCheckSize)], 0) >= 0
+}
+
+// Op says whether a line is unchanged, removed or added. Its value is the
+// character the line starts with in a unified diff.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Line is a line of a hunk, including its line terminator
+type Line struct {
+	Op   Op
+	Text []byte
+}
+
+// Hunk is a group of changes that are close together, with the unchanged
+// lines around them. The starts are 1-based line numbers, except when
+// a side has no lines: then it is the line the hunk comes after.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Function is the closest line above the hunk that looks like the
+	// start of a function, which git shows in the hunk header
+	Function string
+	Lines    []Line
+}
+
+// Header returns the hunk header, like "@@ -1,3 +1,4 @@ func main() {"
+func (h Hunk) Header() string {
+	header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+	if h.Function != "" {
+		header += " " + h.Function
+	}
+	return header
+}
+
+// hunkRange leaves out the count when it is 1, like git
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Hunks returns the differences between a and b, with context unchanged
+// lines around each change. Changes that are at most twice the context
+// apart share a hunk.
+func Hunks(a, b []byte, context int) []Hunk {
+	oldLines, newLines := SplitLines(a), SplitLines(b)
+	lines := edits(oldLines, newLines)
+
+	hunks := []Hunk{}
+	// The search for the function name stops at the previous hunk, which
+	// has the same function if none is found before that
+	functionLimit, function := -1, ""
+	for start := 0; start < len(lines); {
+		if lines[start].Op == Equal {
+			start++
+			continue
+		}
+
+		// Extend the hunk until the unchanged lines between two
+		// changes are too far apart
+		end := start
+		for i := start; i < len(lines) && i-end <= 2*context; i++ {
+			if lines[i].Op != Equal {
+				end = i + 1
+			}
+		}
+		from, to := max(start-context, 0), min(end+context, len(lines))
+
+		hunk := Hunk{Lines: lines[from:to]}
+		for _, l := range lines[:from] {
+			if l.Op != Insert {
+				hunk.OldStart++
+			}
+			if l.Op != Delete {
+				hunk.NewStart++
+			}
+		}
+		oldStart := hunk.OldStart
+		for _, l := range hunk.Lines {
+			if l.Op != Insert {
+				hunk.OldLines++
+			}
+			if l.Op != Delete {
+				hunk.NewLines++
+			}
+		}
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+		if name, ok := functionName(oldLines, oldStart-1, functionLimit); ok {
+			function = name
+		}
+		hunk.Function = function
+		functionLimit = oldStart - 1
+
+		hunks = append(hunks, hunk)
+		start = to
+	}
+	return hunks
+}
+
+// edits lists the lines of a and b as unchanged, removed from a or added
+// in b. Like git diff, the changes are placed using the indent heuristic.
+func edits(a, b [][]byte) []Line {
+	changedA, changedB := changes(a, b, true)
+	lines := make([]Line, 0, max(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case changedA[i]:
+			lines = append(lines, Line{Delete, a[i]})
+			i++
+		case changedB[j]:
+			lines = append(lines, Line{Insert, b[j]})
+			j++
+		default:
+			lines = append(lines, Line{Equal, a[i]})
+			i++
+			j++
+		}
+	}
+	return lines
+}
+
+// functionName searches upwards from line start, and stops before line
+// limit, for a line that starts with a letter, "_" or "$", which is how
+// git finds function names without a diff driver
+func functionName(lines [][]byte, start, limit int) (string, bool) {
+	for i := start; i > limit && i >= 0; i-- {
+		line := lines[i]
+		if len(line) == 0 || !(isLetter(line[0]) || line[0] == '_' || line[0] == '$') {
+			continue
+		}
+		line = line[:min(len(line), maxFunctionLength)]
+		return string(bytes.TrimRight(line, " \t\n\v\f\r")), true
+	}
+	return "", false
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// WriteUnified writes the hunks of the differences between a and b. The
+// file headers are up to the caller.
+func WriteUnified(w io.Writer, a, b []byte, context int) error {
+	for _, hunk := range Hunks(a, b, context) {
+		if _, err := fmt.Fprintln(w, hunk.Header()); err != nil {
+			return err
+		}
+		for _, line := range hunk.Lines {
+			text := line.Text
+			if !bytes.HasSuffix(text, []byte("\n")) {
+				text = append(text[:len(text):len(text)], "\n\\ No newline at end of file\n"...)
+			}
+			if _, err := fmt.Fprintf(w, "%c%s", line.Op, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
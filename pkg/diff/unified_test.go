@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{
+			name:    "changed line",
+			a:       "a\nb\nc\n",
+			b:       "a\nB\nc\n",
+			context: 3,
+			want:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "no newline at end",
+			a:       "x\n",
+			b:       "x",
+			context: 3,
+			want:    "@@ -1 +1 @@\n-x\n+x\n\\ No newline at end of file\n",
+		},
+		{
+			name:    "new file",
+			a:       "",
+			b:       "new\n",
+			context: 3,
+			want:    "@@ -0,0 +1 @@\n+new\n",
+		},
+		{
+			name:    "identical",
+			a:       "same\n",
+			b:       "same\n",
+			context: 3,
+			want:    "",
+		},
+		{
+			// Without the indent heuristic, the added lines would start
+			// with the closing brace of a()
+			name:    "indent heuristic and function name",
+			a:       "func a() {\n\t1\n}\n\nfunc b() {\n\t2\n}\n",
+			b:       "func a() {\n\t1\n}\n\nfunc c() {\n\t3\n}\n\nfunc b() {\n\t2\n}\n",
+			context: 3,
+			want:    "@@ -2,6 +2,10 @@ func a() {\n \t1\n }\n \n+func c() {\n+\t3\n+}\n+\n func b() {\n \t2\n }\n",
+		},
+		{
+			name:    "separate hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:       "1\n2\nx\n4\n5\n6\n7\n8\n9\n10\ny\n12\n",
+			context: 3,
+			want:    "@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+x\n 4\n 5\n 6\n@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n+y\n 12\n",
+		},
+		{
+			name:    "close changes share a hunk",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:       "1\nx\n3\n4\n5\n6\n7\n8\ny\n10\n",
+			context: 3,
+			want:    "@@ -1,10 +1,10 @@\n 1\n-2\n+x\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+y\n 10\n",
+		},
+		{
+			name:    "no context",
+			a:       "1\n2\n3\n",
+			b:       "1\n3\n",
+			context: 0,
+			want:    "@@ -2 +1,0 @@\n-2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteUnified(&out, []byte(tt.a), []byte(tt.b), tt.context); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("WriteUnified() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if IsBinary([]byte("text\n")) {
+		t.Errorf("IsBinary(text) = true")
+	}
+	if !IsBinary([]byte("bin\x00ary")) {
+		t.Errorf("IsBinary(bin\\0ary) = false")
+	}
+	// Only the first 8000 bytes are checked
+	if IsBinary(append(bytes.Repeat([]byte("a"), binaryCheckSize), 0)) {
+		t.Errorf("IsBinary() = true for a NUL after %d bytes", binaryCheckSize)
+	}
+}
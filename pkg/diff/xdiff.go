@@ -0,0 +1,396 @@
+package diff
+
+import "math"
+
+// The algorithm below is xdiff's, which git uses: Myers' algorithm
+// searching from both ends for the middle snake, with heuristics that
+// give up on finding the minimal diff when that gets expensive. Before
+// that, lines that don't occur in the other file are set aside, which
+// makes the search cheaper and changes which of several equally small
+// diffs is found.
+
+const (
+	// Cost from which a good snake is taken without looking further
+	heurMinCost = 256
+	// Minimal cost at which the search gives up
+	maxCostMin = 256
+	// Length of a snake that is considered good
+	snakeCount = 20
+	kHeur      = 4
+	// Lines that occur this often in the other file may be discarded
+	maxEqLimit = 1024
+	// How far around a line is looked at to decide to discard it
+	simScanWindow = 100
+	kpDisRun      = 4
+)
+
+// classify numbers the lines of a and b, so equal lines have the same
+// number
+func classify(a, b [][]byte) ([]int, []int) {
+	classes := map[string]int{}
+	number := func(lines [][]byte) []int {
+		numbers := make([]int, len(lines))
+		for i, line := range lines {
+			class, ok := classes[string(line)]
+			if !ok {
+				class = len(classes)
+				classes[string(line)] = class
+			}
+			numbers[i] = class
+		}
+		return numbers
+	}
+	return number(a), number(b)
+}
+
+// xdiffFile is a file reduced to the lines that take part in the search
+type xdiffFile struct {
+	// Classes of the remaining lines
+	classes []int
+	// Positions of the remaining lines in the whole file
+	index   []int
+	changed []bool
+}
+
+type xdiff struct {
+	a, b       xdiffFile
+	kvdf, kvdb []int
+	// Offset of diagonal 0 in kvdf and kvdb
+	offset  int
+	maxCost int
+}
+
+// newXdiff prepares the search: the common lines at the start and end
+// are left out, and lines without a match in the other file are marked as
+// changed straight away
+func newXdiff(classesA, classesB []int, changedA, changedB []bool) *xdiff {
+	start := 0
+	for start < min(len(classesA), len(classesB)) && classesA[start] == classesB[start] {
+		start++
+	}
+	end := 0
+	for end < min(len(classesA), len(classesB))-start && classesA[len(classesA)-1-end] == classesB[len(classesB)-1-end] {
+		end++
+	}
+
+	countA, countB := map[int]int{}, map[int]int{}
+	for _, c := range classesA {
+		countA[c]++
+	}
+	for _, c := range classesB {
+		countB[c]++
+	}
+
+	x := &xdiff{
+		a: reduce(classesA, start, len(classesA)-end, countB, changedA),
+		b: reduce(classesB, start, len(classesB)-end, countA, changedB),
+	}
+	diagonals := len(x.a.classes) + len(x.b.classes) + 3
+	x.kvdf, x.kvdb = make([]int, diagonals), make([]int, diagonals)
+	x.offset = len(x.b.classes) + 1
+	x.maxCost = max(bogoSqrt(diagonals), maxCostMin)
+	return x
+}
+
+// reduce is xdl_cleanup_records for one file: lines between start and
+// end without a match in the other file are changed. Lines with many
+// matches are changed too, if they are surrounded by lines without a
+// match.
+func reduce(classes []int, start, end int, otherCount map[int]int, changed []bool) xdiffFile {
+	limit := min(bogoSqrt(len(classes)), maxEqLimit)
+	discard := make([]byte, len(classes))
+	for i := start; i < end; i++ {
+		switch n := otherCount[classes[i]]; {
+		case n == 0:
+			discard[i] = 0
+		case n >= limit:
+			discard[i] = 2
+		default:
+			discard[i] = 1
+		}
+	}
+
+	f := xdiffFile{changed: changed}
+	for i := start; i < end; i++ {
+		if discard[i] == 1 || (discard[i] == 2 && !discardMultimatch(discard, i, start, end-1)) {
+			f.classes = append(f.classes, classes[i])
+			f.index = append(f.index, i)
+		} else {
+			changed[i] = true
+		}
+	}
+	return f
+}
+
+// discardMultimatch is xdl_clean_mmatch: line i, which has many matches,
+// is discarded if the lines around it mostly have no match at all
+func discardMultimatch(discard []byte, i, start, end int) bool {
+	start = max(start, i-simScanWindow)
+	end = min(end, i+simScanWindow)
+
+	noMatchBefore, multiBefore := 0, 1
+	for r := 1; i-r >= start; r++ {
+		if discard[i-r] == 0 {
+			noMatchBefore++
+		} else if discard[i-r] == 2 {
+			multiBefore++
+		} else {
+			break
+		}
+	}
+	if noMatchBefore == 0 {
+		return false
+	}
+
+	noMatchAfter, multiAfter := 0, 1
+	for r := 1; i+r <= end; r++ {
+		if discard[i+r] == 0 {
+			noMatchAfter++
+		} else if discard[i+r] == 2 {
+			multiAfter++
+		} else {
+			break
+		}
+	}
+	if noMatchAfter == 0 {
+		return false
+	}
+
+	noMatch := noMatchBefore + noMatchAfter
+	multi := multiBefore + multiAfter
+	return multi*kpDisRun < multi+noMatch
+}
+
+// bogoSqrt is the power of two close to the square root of n
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+	return i
+}
+
+func (x *xdiff) run() {
+	x.compare(0, len(x.a.classes), 0, len(x.b.classes), false)
+}
+
+// compare is xdl_recs_cmp: it marks the changed lines between off and lim
+// in both files, by splitting the problem in two around a snake
+func (x *xdiff) compare(off1, lim1, off2, lim2 int, needMin bool) {
+	ha1, ha2 := x.a.classes, x.b.classes
+	for off1 < lim1 && off2 < lim2 && ha1[off1] == ha2[off2] {
+		off1++
+		off2++
+	}
+	for off1 < lim1 && off2 < lim2 && ha1[lim1-1] == ha2[lim2-1] {
+		lim1--
+		lim2--
+	}
+
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			x.b.changed[x.b.index[off2]] = true
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			x.a.changed[x.a.index[off1]] = true
+		}
+	default:
+		s := x.split(off1, lim1, off2, lim2, needMin)
+		x.compare(off1, s.i1, off2, s.i2, s.minLow)
+		x.compare(s.i1, lim1, s.i2, lim2, s.minHigh)
+	}
+}
+
+type splitPoint struct {
+	i1, i2          int
+	minLow, minHigh bool
+}
+
+// split is xdl_split: it searches forwards and backwards at the same time
+// until the paths meet, or until the heuristics decide to cut the search
+// short
+func (x *xdiff) split(off1, lim1, off2, lim2 int, needMin bool) splitPoint {
+	ha1, ha2 := x.a.classes, x.b.classes
+	kvdf := func(d int) *int { return &x.kvdf[x.offset+d] }
+	kvdb := func(d int) *int { return &x.kvdb[x.offset+d] }
+
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+
+	*kvdf(fmid) = off1
+	*kvdb(bmid) = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+
+		// Extend the forward diagonals by one on each side
+		if fmin > dmin {
+			fmin--
+			*kvdf(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			*kvdf(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if *kvdf(d - 1) >= *kvdf(d + 1) {
+				i1 = *kvdf(d - 1) + 1
+			} else {
+				i1 = *kvdf(d + 1)
+			}
+			prev1 := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && ha1[i1] == ha2[i2] {
+				i1++
+				i2++
+			}
+			if i1-prev1 > snakeCount {
+				gotSnake = true
+			}
+			*kvdf(d) = i1
+			if odd && bmin <= d && d <= bmax && *kvdb(d) <= i1 {
+				return splitPoint{i1, i2, true, true}
+			}
+		}
+
+		// Extend the backward diagonals by one on each side
+		if bmin > dmin {
+			bmin--
+			*kvdb(bmin - 1) = math.MaxInt
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			*kvdb(bmax + 1) = math.MaxInt
+		} else {
+			bmax--
+		}
+
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if *kvdb(d - 1) < *kvdb(d + 1) {
+				i1 = *kvdb(d - 1)
+			} else {
+				i1 = *kvdb(d + 1) - 1
+			}
+			prev1 := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && ha1[i1-1] == ha2[i2-1] {
+				i1--
+				i2--
+			}
+			if prev1-i1 > snakeCount {
+				gotSnake = true
+			}
+			*kvdb(d) = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= *kvdf(d) {
+				return splitPoint{i1, i2, true, true}
+			}
+		}
+
+		if needMin {
+			continue
+		}
+
+		// With a good snake and a high cost, take a diagonal that got
+		// far, measured from the corner and penalized by how far it is
+		// from the middle diagonal
+		if gotSnake && ec > heurMinCost {
+			best := 0
+			var s splitPoint
+			for d := fmax; d >= fmin; d -= 2 {
+				dd := abs(d - fmid)
+				i1 := *kvdf(d)
+				i2 := i1 - d
+				v := (i1 - off1) + (i2 - off2) - dd
+				if v > kHeur*ec && v > best &&
+					off1+snakeCount <= i1 && i1 < lim1 &&
+					off2+snakeCount <= i2 && i2 < lim2 {
+					for k := 1; ha1[i1-k] == ha2[i2-k]; k++ {
+						if k == snakeCount {
+							best = v
+							s = splitPoint{i1, i2, true, false}
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return s
+			}
+
+			for d := bmax; d >= bmin; d -= 2 {
+				dd := abs(d - bmid)
+				i1 := *kvdb(d)
+				i2 := i1 - d
+				v := (lim1 - i1) + (lim2 - i2) - dd
+				if v > kHeur*ec && v > best &&
+					off1 < i1 && i1 <= lim1-snakeCount &&
+					off2 < i2 && i2 <= lim2-snakeCount {
+					for k := 0; ha1[i1+k] == ha2[i2+k]; k++ {
+						if k == snakeCount-1 {
+							best = v
+							s = splitPoint{i1, i2, false, true}
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return s
+			}
+		}
+
+		// The search has been expensive enough, so we take the path
+		// that got furthest
+		if ec >= x.maxCost {
+			fbest, fbest1 := -1, -1
+			for d := fmax; d >= fmin; d -= 2 {
+				i1 := min(*kvdf(d), lim1)
+				i2 := i1 - d
+				if lim2 < i2 {
+					i1, i2 = lim2+d, lim2
+				}
+				if fbest < i1+i2 {
+					fbest, fbest1 = i1+i2, i1
+				}
+			}
+
+			bbest, bbest1 := math.MaxInt, math.MaxInt
+			for d := bmax; d >= bmin; d -= 2 {
+				i1 := max(off1, *kvdb(d))
+				i2 := i1 - d
+				if i2 < off2 {
+					i1, i2 = off2+d, off2
+				}
+				if i1+i2 < bbest {
+					bbest, bbest1 = i1+i2, i1
+				}
+			}
+
+			if (lim1+lim2)-bbest < fbest-(off1+off2) {
+				return splitPoint{fbest1, fbest - fbest1, true, false}
+			}
+			return splitPoint{bbest1, bbest - bbest1, false, true}
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
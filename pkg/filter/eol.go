@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"github.com/jessegeens/got/pkg/attributes"
+)
+
+// EOLStats classifies the line endings of contents like git ls-files
+// --eol: "lf", "crlf", "mixed" for both, "none" without line endings, or
+// "-text" for contents that look binary
+func EOLStats(data []byte) string {
+	var lonecr, lonelf, crlf, nul, printable, nonprintable int
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\r':
+			if i+1 < len(data) && data[i+1] == '\n' {
+				crlf++
+				i++
+			} else {
+				lonecr++
+			}
+		case c == '\n':
+			lonelf++
+		case c == 127:
+			nonprintable++
+		case c == 0:
+			nul++
+			nonprintable++
+		case c < 32:
+			switch c {
+			case '\b', '\t', '\033', '\f':
+				printable++
+			default:
+				nonprintable++
+			}
+		default:
+			printable++
+		}
+	}
+	// A DOS end-of-file marker at the end doesn't make a file binary
+	if len(data) > 0 && data[len(data)-1] == '\032' {
+		nonprintable--
+	}
+
+	switch {
+	case lonecr > 0 || nul > 0 || printable>>7 < nonprintable:
+		return "-text"
+	case crlf > 0 && lonelf > 0:
+		return "mixed"
+	case crlf > 0:
+		return "crlf"
+	case lonelf > 0:
+		return "lf"
+	}
+	return "none"
+}
+
+// EOLAttr describes what the text, crlf and eol attributes say about the
+// line endings of path, like git ls-files --eol: "text", "-text",
+// "text=auto", either of those text values followed by " eol=lf" or
+// " eol=crlf", or "" when they say nothing
+func EOLAttr(attrs *attributes.Attributes, path string) string {
+	if attrs == nil {
+		return ""
+	}
+	// The crlf attribute is the older name of text
+	text, eol := eolText(attrs.Get(path, "text")), ""
+	if text == "" {
+		text = eolText(attrs.Get(path, "crlf"))
+	}
+	if text == "text eol=lf" {
+		text, eol = "text", "lf"
+	}
+	if text == "-text" {
+		return text
+	}
+	if value := attrs.Get(path, "eol"); value.State == attributes.Valued && (value.Value == "lf" || value.Value == "crlf") {
+		eol = value.Value
+		if text == "" {
+			text = "text"
+		}
+	}
+	if eol != "" {
+		return text + " eol=" + eol
+	}
+	return text
+}
+
+func eolText(value attributes.Value) string {
+	switch {
+	case value.IsSet():
+		return "text"
+	case value.IsUnset():
+		return "-text"
+	case value.State == attributes.Valued && value.Value == "input":
+		return "text eol=lf"
+	case value.State == attributes.Valued && value.Value == "auto":
+		return "text=auto"
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package filter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+)
+
+var formatPattern = regexp.MustCompile(`\$Format:([^$]*)\$`)
+
+// ExportSubst implements the export-subst attribute: "$Format:<format>$"
+// is replaced by the expansion of <format> for the archived commit.
+// It only applies when exporting, so cleaning leaves data as is.
+type ExportSubst struct {
+	SHA    *hashing.SHA
+	Commit *objects.Commit
+}
+
+func (e ExportSubst) Smudge(data []byte) ([]byte, error) {
+	return formatPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		format := formatPattern.FindSubmatch(match)[1]
+		return []byte(e.expand(string(format)))
+	}), nil
+}
+
+func (ExportSubst) Clean(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// expand supports the commonly used placeholders of git's pretty formats.
+// Unknown placeholders are copied verbatim.
+func (e ExportSubst) expand(format string) string {
+	var out strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		value, length, ok := e.placeholder(format[i+1:])
+		if !ok {
+			out.WriteByte(format[i])
+			continue
+		}
+		out.WriteString(value)
+		i += length
+	}
+	return out.String()
+}
+
+func (e ExportSubst) placeholder(spec string) (string, int, bool) {
+	value := func(key string) string {
+		v, _ := e.Commit.GetValue(key)
+		return string(v)
+	}
+	short := func(hex string) string {
+		if len(hex) > 7 {
+			return hex[:7]
+		}
+		return hex
+	}
+	parents := func(abbreviate bool) string {
+		hashes := []string{}
+		for _, p := range e.Commit.GetValues("parent") {
+			if abbreviate {
+				hashes = append(hashes, short(string(p)))
+			} else {
+				hashes = append(hashes, string(p))
+			}
+		}
+		return strings.Join(hashes, " ")
+	}
+
+	switch spec[0] {
+	case '%':
+		return "%", 1, true
+	case 'n':
+		return "\n", 1, true
+	case 'H':
+		return e.SHA.AsString(), 1, true
+	case 'h':
+		return short(e.SHA.AsString()), 1, true
+	case 'T':
+		return value("tree"), 1, true
+	case 't':
+		return short(value("tree")), 1, true
+	case 'P':
+		return parents(false), 1, true
+	case 'p':
+		return parents(true), 1, true
+	case 's':
+		subject, _, _ := strings.Cut(strings.TrimSpace(e.Commit.Message()), "\n\n")
+		return strings.ReplaceAll(subject, "\n", " "), 1, true
+	case 'b':
+		_, body, _ := strings.Cut(strings.TrimSpace(e.Commit.Message()), "\n\n")
+		if body != "" {
+			body += "\n"
+		}
+		return body, 1, true
+	case 'B':
+		return e.Commit.Message(), 1, true
+	case 'a', 'c':
+		if len(spec) < 2 {
+			return "", 0, false
+		}
+		key := "author"
+		if spec[0] == 'c' {
+			key = "committer"
+		}
+		field, ok := formatIdent(objects.ParseIdent([]byte(value(key))), spec[1])
+		return field, 2, ok
+	}
+	return "", 0, false
+}
+
+func formatIdent(id objects.Ident, field byte) (string, bool) {
+	switch field {
+	case 'n':
+		return id.Name, true
+	case 'e':
+		return id.Email, true
+	case 'd':
+		return id.When.Format(objects.DefaultDateFormat), true
+	case 't':
+		return strconv.FormatInt(id.When.Unix(), 10), true
+	case 'i':
+		return id.When.Format("2006-01-02 15:04:05 -0700"), true
+	case 'I':
+		return id.When.Format(time.RFC3339), true
+	}
+	return "", false
+}
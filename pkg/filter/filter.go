@@ -0,0 +1,50 @@
+// Content filters, which convert between the contents of a blob
+// and the contents of the corresponding file in the worktree
+package filter
+
+import (
+	"github.com/jessegeens/got/pkg/attributes"
+)
+
+type Filter interface {
+	// Clean is applied to the contents of a file before it is stored as a blob
+	Clean(data []byte) ([]byte, error)
+	// Smudge is applied to the contents of a blob before it is written out
+	Smudge(data []byte) ([]byte, error)
+}
+
+// ForPath returns the filters that the attributes enable for path
+func ForPath(attrs *attributes.Attributes, path string) []Filter {
+	filters := []Filter{}
+	if attrs == nil {
+		return filters
+	}
+	if attrs.Get(path, "ident").IsSet() {
+		filters = append(filters, Ident{})
+	}
+	return filters
+}
+
+// Smudge applies the filters in order
+func Smudge(filters []Filter, data []byte) ([]byte, error) {
+	var err error
+	for _, f := range filters {
+		data, err = f.Smudge(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Clean applies the filters in reverse order, undoing Smudge
+func Clean(filters []Filter, data []byte) ([]byte, error) {
+	var err error
+	for i := len(filters) - 1; i >= 0; i-- {
+		data, err = filters[i].Clean(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,137 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/kvlm"
+	"github.com/jessegeens/got/pkg/objects"
+)
+
+func TestIdent(t *testing.T) {
+	clean := []byte("version $Id$\n")
+	// git hash-object of the clean contents
+	blob := &objects.Blob{}
+	blob.Deserialize(clean)
+	sha, err := objects.CalculateSha(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	smudged, err := Ident{}.Smudge(clean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "version $Id: " + sha.AsString() + " $\n"
+	if string(smudged) != want {
+		t.Errorf("Smudge() = %q, want %q", smudged, want)
+	}
+
+	cleaned, err := Ident{}.Clean(smudged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cleaned) != string(clean) {
+		t.Errorf("Clean() = %q, want %q", cleaned, clean)
+	}
+}
+
+func TestExportSubst(t *testing.T) {
+	data := kvlm.New()
+	data.Okv.Set("tree", []byte("4b825dc642cb6eb9a060e54bf8d69288fbee4904"))
+	data.Okv.Set("parent", []byte("1111111111111111111111111111111111111111"))
+	data.Okv.Set("author", []byte("A U Thor <author@example.com> 1700000000 +0100"))
+	data.Okv.Set("committer", []byte("C O Mitter <committer@example.com> 1700000000 -0500"))
+	data.Message = []byte("Subject line\n\nBody text\n")
+
+	sha, _ := hashing.NewShaFromHex("0123456789abcdef0123456789abcdef01234567")
+	subst := ExportSubst{SHA: sha, Commit: objects.NewCommit(data)}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"$Format:%H$", "0123456789abcdef0123456789abcdef01234567"},
+		{"rev $Format:%h$ of $Format:%t$", "rev 0123456 of 4b825dc"},
+		{"$Format:%p$", "1111111"},
+		{"$Format:%an <%ae>$", "A U Thor <author@example.com>"},
+		{"$Format:%cn%n%ct$", "C O Mitter\n1700000000"},
+		{"$Format:%ai$", "2023-11-14 23:13:20 +0100"},
+		{"$Format:%cd$", "Tue Nov 14 17:13:20 2023 -0500"},
+		{"$Format:%s$", "Subject line"},
+		{"$Format:100%%$", "100%"},
+		{"$Format:%x$", "%x"},
+		{"no placeholders", "no placeholders"},
+	}
+
+	for _, tt := range tests {
+		got, err := subst.Smudge([]byte(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Smudge(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestForPath(t *testing.T) {
+	attrs := attributes.New()
+	if err := attrs.Parse([]byte("*.c ident\n"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ForPath(attrs, "main.c")) != 1 {
+		t.Errorf("expected ident filter for main.c")
+	}
+	if len(ForPath(attrs, "main.go")) != 0 {
+		t.Errorf("expected no filters for main.go")
+	}
+}
+
+func TestEOLStats(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "none"},
+		{"no newline", "none"},
+		{"a\nb\n", "lf"},
+		{"a\r\nb\r\n", "crlf"},
+		{"a\r\nb\n", "mixed"},
+		{"a\rb\n", "-text"},
+		{"a\x00b\n", "-text"},
+		{"a\tb\n\x1a", "lf"},
+	}
+	for _, tt := range tests {
+		if got := EOLStats([]byte(tt.in)); got != tt.want {
+			t.Errorf("EOLStats(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEOLAttr(t *testing.T) {
+	attrs := attributes.New()
+	rules := "*.txt text\n*.sh text eol=lf\n*.bat eol=crlf\n*.png binary\n*.c text=auto eol=crlf\n*.in crlf=input\n"
+	if err := attrs.Parse([]byte(rules), ""); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"a.txt", "text"},
+		{"run.sh", "text eol=lf"},
+		{"run.bat", "text eol=crlf"},
+		{"logo.png", "-text"},
+		{"main.c", "text=auto eol=crlf"},
+		{"config.in", "text eol=lf"},
+		{"main.go", ""},
+	}
+	for _, tt := range tests {
+		if got := EOLAttr(attrs, tt.path); got != tt.want {
+			t.Errorf("EOLAttr(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package filter
+
+import (
+	"regexp"
+
+	"github.com/jessegeens/got/pkg/objects"
+)
+
+var (
+	// Matches both "$Id$" and an expanded "$Id: ... $" on a single line
+	identPattern         = regexp.MustCompile(`\$Id(:[^$\n]*)?\$`)
+	expandedIdentPattern = regexp.MustCompile(`\$Id:[^$\n]*\$`)
+)
+
+// Ident implements the ident attribute: "$Id$" is expanded to
+// "$Id: <blob hash> $" on checkout, and collapsed again when the
+// file is stored, so the blob hash does not depend on itself.
+type Ident struct{}
+
+func (Ident) Smudge(data []byte) ([]byte, error) {
+	if !identPattern.Match(data) {
+		return data, nil
+	}
+
+	blob := &objects.Blob{}
+	blob.Deserialize(data)
+	sha, err := objects.CalculateSha(blob)
+	if err != nil {
+		return nil, err
+	}
+	return identPattern.ReplaceAllLiteral(data, []byte("$Id: "+sha.AsString()+" $")), nil
+}
+
+func (Ident) Clean(data []byte) ([]byte, error) {
+	return expandedIdentPattern.ReplaceAllLiteral(data, []byte("$Id$")), nil
+}
@@ -0,0 +1,158 @@
+// Package foreachrepo runs a command in many repositories, like git
+// for-each-repo, at the same time if asked, and collects what every run
+// did.
+package foreachrepo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result is what a command did in a repository
+type Result struct {
+	Repo     string `json:"repo"`
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	// Error says why the command could not run at all, e.g. because the
+	// repository is missing
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// OK reports whether the command ran and succeeded
+func (r Result) OK() bool {
+	return r.Error == "" && r.ExitCode == 0
+}
+
+// Runner runs a command in a repository
+type Runner func(repo string) Result
+
+// Options say how the repositories are gone through
+type Options struct {
+	// Jobs is how many commands run at the same time, one per CPU if 0
+	Jobs int
+	// KeepGoing runs the command in every repository, even after it
+	// failed in one
+	KeepGoing bool
+}
+
+// ReadList reads repositories from a list with a path on every line.
+// Blank lines and lines that start with # are skipped.
+func ReadList(r io.Reader) ([]string, error) {
+	repos := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repos = append(repos, line)
+	}
+	return repos, scanner.Err()
+}
+
+// ExpandHome expands a path that starts with ~/ to one in the home
+// directory, as the paths that git for-each-repo reads from the
+// configuration are
+func ExpandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// Exec returns a Runner that runs a program with args in the repository,
+// and keeps its output
+func Exec(program string, args []string) Runner {
+	return func(repo string) Result {
+		result := Result{Repo: repo}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(program, args...)
+		cmd.Dir = repo
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		start := time.Now()
+		err := cmd.Run()
+		result.Duration = time.Since(start).Milliseconds()
+		result.Stdout, result.Stderr = stdout.String(), stderr.String()
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		} else if err != nil {
+			result.ExitCode = -1
+			result.Error = err.Error()
+		}
+		return result
+	}
+}
+
+// Run runs a command in every repository and calls fn with the results,
+// in the order of repos. Without KeepGoing, it stops after the first
+// failure, although commands that already started still finish. It
+// returns how many commands failed.
+func Run(repos []string, opts Options, run Runner, fn func(Result)) int {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	// Every repository has a buffered channel for its result, which is
+	// read in order
+	results := make([]chan Result, len(repos))
+	for i := range results {
+		results[i] = make(chan Result, 1)
+	}
+	next := make(chan int)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range min(jobs, len(repos)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] <- run(repos[i])
+			}
+		}()
+	}
+	go func() {
+		defer close(next)
+		for i := range repos {
+			select {
+			case next <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer wg.Wait()
+	defer close(stop)
+
+	failed := 0
+	for i := range repos {
+		result := <-results[i]
+		fn(result)
+		if !result.OK() {
+			failed++
+			if !opts.KeepGoing {
+				break
+			}
+		}
+	}
+	return failed
+}
@@ -0,0 +1,99 @@
+package foreachrepo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadList(t *testing.T) {
+	repos, err := ReadList(strings.NewReader("# checkouts\n/src/a\n\n  /src/b  \n#/src/c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/src/a", "/src/b"}; !reflect.DeepEqual(repos, want) {
+		t.Errorf("ReadList() = %q, want %q", repos, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	repos := []string{"a", "b", "c", "d", "e"}
+	// The first repositories take the longest, so that they finish last
+	run := func(repo string) Result {
+		time.Sleep(time.Duration('f'-repo[0]) * 5 * time.Millisecond)
+		if repo == "c" {
+			return Result{Repo: repo, ExitCode: 1}
+		}
+		return Result{Repo: repo}
+	}
+
+	var order []string
+	failed := Run(repos, Options{Jobs: 3, KeepGoing: true}, run, func(r Result) { order = append(order, r.Repo) })
+	if failed != 1 || !reflect.DeepEqual(order, repos) {
+		t.Errorf("Run() reported %q with %d failed, want %q with 1", order, failed, repos)
+	}
+
+	order = nil
+	failed = Run(repos, Options{Jobs: 1}, run, func(r Result) { order = append(order, r.Repo) })
+	if want := []string{"a", "b", "c"}; failed != 1 || !reflect.DeepEqual(order, want) {
+		t.Errorf("Run() without KeepGoing reported %q with %d failed, want %q with 1", order, failed, want)
+	}
+}
+
+func TestRunJobs(t *testing.T) {
+	var running, most atomic.Int32
+	run := func(repo string) Result {
+		n := running.Add(1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return Result{Repo: repo}
+	}
+	Run([]string{"a", "b", "c", "d", "e", "f"}, Options{Jobs: 2}, run, func(Result) {})
+	if most.Load() != 2 {
+		t.Errorf("Run() with 2 jobs ran %d commands at the same time", most.Load())
+	}
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs sh")
+	}
+	dir := t.TempDir()
+	result := Exec("sh", []string{"-c", "pwd; echo oops >&2; exit 3"})(dir)
+	resolved, _ := filepath.EvalSymlinks(dir)
+	if strings.TrimSpace(result.Stdout) != resolved && strings.TrimSpace(result.Stdout) != dir {
+		t.Errorf("Exec() ran in %q, want %q", result.Stdout, dir)
+	}
+	if result.ExitCode != 3 || result.Stderr != "oops\n" || result.Error != "" || result.OK() {
+		t.Errorf("Exec() = %+v, want exit code 3 and stderr", result)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	if result := Exec("sh", []string{"-c", "true"})(missing); result.Error == "" || result.OK() {
+		t.Errorf("Exec() in a missing directory = %+v, want an error", result)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	if got := ExpandHome("~/src/a"); got != filepath.Join(home, "src/a") {
+		t.Errorf("ExpandHome() = %q", got)
+	}
+	if got := ExpandHome("/src/~/a"); got != "/src/~/a" {
+		t.Errorf("ExpandHome() = %q", got)
+	}
+}
@@ -0,0 +1,203 @@
+// Templates for the --format option of commands that list objects or refs.
+//
+// A template is literal text with placeholders:
+//
+//   - %(field) is replaced by the value of field
+//   - %(field:modifier) is the value of field, changed by the modifier
+//   - %% is a literal percent sign, %NN and %xNN insert the byte with hex code NN
+//
+// Supported modifiers are short (abbreviated object name or ref name),
+// short=<n>, lstrip=<n> and rstrip=<n>.
+package format
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Fields provides the values of the fields in a template
+type Fields interface {
+	Field(name string) (string, bool)
+}
+
+// Map is a Fields with precomputed values
+type Map map[string]string
+
+func (m Map) Field(name string) (string, bool) {
+	value, ok := m[name]
+	return value, ok
+}
+
+// Func is a Fields that computes values on demand, e.g.
+// when a field requires reading an object
+type Func func(name string) (string, bool)
+
+func (f Func) Field(name string) (string, bool) {
+	return f(name)
+}
+
+type placeholder struct {
+	name     string
+	modifier string
+}
+
+type part struct {
+	literal     string
+	placeholder *placeholder
+}
+
+type Template struct {
+	parts []part
+}
+
+// Parse compiles a template. Only the fields in known can be used.
+func Parse(format string, known []string) (*Template, error) {
+	t := &Template{}
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			t.parts = append(t.parts, part{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			literal.WriteByte(c)
+			continue
+		}
+
+		switch next := format[i+1]; {
+		case next == '%':
+			literal.WriteByte('%')
+			i++
+		case next == '(':
+			end := strings.IndexByte(format[i:], ')')
+			if end < 0 {
+				return nil, fmt.Errorf("malformed format string %s", format)
+			}
+			name, modifier, _ := strings.Cut(format[i+2:i+end], ":")
+			if !slices.Contains(known, name) {
+				return nil, fmt.Errorf("unknown field name: %s", name)
+			}
+			if err := validateModifier(modifier); err != nil {
+				return nil, err
+			}
+			flush()
+			t.parts = append(t.parts, part{placeholder: &placeholder{name: name, modifier: modifier}})
+			i += end
+		case next == 'x' && i+3 < len(format) && isHex(format[i+2]) && isHex(format[i+3]):
+			b, _ := hex.DecodeString(format[i+2 : i+4])
+			literal.Write(b)
+			i += 3
+		case i+2 < len(format) && isHex(format[i+1]) && isHex(format[i+2]):
+			b, _ := hex.DecodeString(format[i+1 : i+3])
+			literal.Write(b)
+			i += 2
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flush()
+	return t, nil
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func validateModifier(modifier string) error {
+	if modifier == "" || modifier == "short" {
+		return nil
+	}
+	name, arg, ok := strings.Cut(modifier, "=")
+	if ok && (name == "short" || name == "lstrip" || name == "rstrip" || name == "strip") {
+		if _, err := strconv.Atoi(arg); err != nil {
+			return fmt.Errorf("invalid argument for %s: %s", name, arg)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown modifier: %s", modifier)
+}
+
+// Names returns the fields that the template uses
+func (t *Template) Names() []string {
+	names := []string{}
+	for _, p := range t.parts {
+		if p.placeholder != nil && !slices.Contains(names, p.placeholder.name) {
+			names = append(names, p.placeholder.name)
+		}
+	}
+	return names
+}
+
+// Expand fills in the template. Fields without a value expand to nothing.
+func (t *Template) Expand(fields Fields) string {
+	var out strings.Builder
+	for _, p := range t.parts {
+		if p.placeholder == nil {
+			out.WriteString(p.literal)
+			continue
+		}
+		value, _ := fields.Field(p.placeholder.name)
+		out.WriteString(modify(value, p.placeholder.modifier))
+	}
+	return out.String()
+}
+
+func modify(value, modifier string) string {
+	if modifier == "" {
+		return value
+	}
+	if modifier == "short" {
+		if strings.HasPrefix(value, "refs/") {
+			return ShortRefName(value)
+		}
+		return abbreviate(value, 7)
+	}
+
+	name, arg, _ := strings.Cut(modifier, "=")
+	n, _ := strconv.Atoi(arg)
+	switch name {
+	case "short":
+		return abbreviate(value, n)
+	case "lstrip", "strip":
+		components := strings.Split(value, "/")
+		if n < 0 {
+			n = max(len(components)+n, 0)
+		}
+		return strings.Join(components[min(n, len(components)):], "/")
+	case "rstrip":
+		components := strings.Split(value, "/")
+		if n < 0 {
+			n = max(len(components)+n, 0)
+		}
+		return strings.Join(components[:len(components)-min(n, len(components))], "/")
+	}
+	return value
+}
+
+func abbreviate(value string, n int) string {
+	if n < 4 {
+		n = 4
+	}
+	if len(value) > n {
+		return value[:n]
+	}
+	return value
+}
+
+// ShortRefName strips the well-known prefixes from a full ref name,
+// e.g. refs/heads/main becomes main
+func ShortRefName(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/", "refs/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+	return ref
+}
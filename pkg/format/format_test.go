@@ -0,0 +1,48 @@
+package format
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	fields := Map{
+		"objectname": "0123456789abcdef0123456789abcdef01234567",
+		"refname":    "refs/heads/feature/x",
+		"path":       "dir/file.txt",
+	}
+	known := []string{"objectname", "refname", "path"}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%(objectname)", "0123456789abcdef0123456789abcdef01234567"},
+		{"%(objectname:short)", "0123456"},
+		{"%(objectname:short=10)", "0123456789"},
+		{"%(refname:short)", "feature/x"},
+		{"%(refname:lstrip=2)", "feature/x"},
+		{"%(refname:lstrip=-1)", "x"},
+		{"%(refname:rstrip=1)", "refs/heads/feature"},
+		{"%(objectname:short)%09%(path)", "0123456\tdir/file.txt"},
+		{"%(objectname:short)%x09%(path)", "0123456\tdir/file.txt"},
+		{"100%% %(path)", "100% dir/file.txt"},
+		{"literal only", "literal only"},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.format, known)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.format, err)
+		}
+		if got := tmpl.Expand(fields); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	known := []string{"objectname"}
+	for _, format := range []string{"%(unknown)", "%(objectname", "%(objectname:bogus)", "%(objectname:short=x)"} {
+		if _, err := Parse(format, known); err == nil {
+			t.Errorf("Parse(%q) should fail", format)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Records writes the output of commands that scripts read, like lists of
+// paths. Every record ends with a newline, and the paths in them are
+// quoted with QuotePath, so that a path can't span lines. With -z,
+// records end with a NUL byte instead, and paths are written as they
+// are, since they can't contain one.
+type Records struct {
+	w   io.Writer
+	nul bool
+}
+
+// NewRecords returns a Records that writes to w, with NUL-terminated
+// records if nul is set
+func NewRecords(w io.Writer, nul bool) *Records {
+	return &Records{w: w, nul: nul}
+}
+
+// Path returns name the way it is written in a record
+func (r *Records) Path(name string) string {
+	if r.nul {
+		return name
+	}
+	return QuotePath(name)
+}
+
+// Write writes a record, followed by its terminator
+func (r *Records) Write(record string) error {
+	terminator := "\n"
+	if r.nul {
+		terminator = "\x00"
+	}
+	_, err := io.WriteString(r.w, record+terminator)
+	return err
+}
+
+// pathEscapes are the characters that QuotePath escapes with a letter
+var pathEscapes = map[byte]byte{
+	'\a': 'a', '\b': 'b', '\t': 't', '\n': 'n', '\v': 'v', '\f': 'f', '\r': 'r',
+	'"': '"', '\\': '\\',
+}
+
+// QuotePath quotes a path like git does by default: if it has control
+// characters, double quotes, backslashes or bytes outside of ASCII, it
+// is put between double quotes, with those characters escaped like in C.
+// Other paths are returned as they are.
+func QuotePath(name string) string {
+	needsQuotes := false
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		return name
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if escape, ok := pathEscapes[c]; ok {
+			quoted.WriteByte('\\')
+			quoted.WriteByte(escape)
+		} else if c < 0x20 || c >= 0x7f {
+			fmt.Fprintf(&quoted, "\\%03o", c)
+		} else {
+			quoted.WriteByte(c)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
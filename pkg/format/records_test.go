@@ -0,0 +1,46 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQuotePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"dir/file.txt", "dir/file.txt"},
+		{"with space", "with space"},
+		{"new\nline", `"new\nline"`},
+		{"tab\there", `"tab\there"`},
+		{`quote"d`, `"quote\"d"`},
+		{`back\slash`, `"back\\slash"`},
+		{"café", `"caf\303\251"`},
+		{"bell\x07", `"bell\a"`},
+		{"del\x7f", `"del\177"`},
+	}
+	for _, tt := range tests {
+		if got := QuotePath(tt.name); got != tt.want {
+			t.Errorf("QuotePath(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecords(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRecords(&buf, false)
+	r.Write("?? " + r.Path("new\nline"))
+	r.Write(r.Path("plain"))
+	if got, want := buf.String(), "?? \"new\\nline\"\nplain\n"; got != want {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	r = NewRecords(&buf, true)
+	r.Write("?? " + r.Path("new\nline"))
+	r.Write(r.Path("café"))
+	if got, want := buf.String(), "?? new\nline\x00café\x00"; got != want {
+		t.Errorf("records with -z = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package fs
+
+import "os"
+
+// Umask returns the permission bits that new files don't get, which are
+// none where there is no umask
+func Umask() os.FileMode {
+	return 0
+}
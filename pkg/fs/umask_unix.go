@@ -0,0 +1,23 @@
+//go:build unix
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// umask is read once, before any goroutine can create files, since reading
+// it means setting it
+var umask = readUmask()
+
+func readUmask() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
+
+// Umask returns the permission bits that new files don't get
+func Umask() os.FileMode {
+	return umask
+}
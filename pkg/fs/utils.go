@@ -6,11 +6,12 @@ import (
 	"io"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
 )
 
 func Exists(path string) bool {
-	_, err := os.Open(path)
+	_, err := os.Stat(path)
 	return !errors.Is(err, os.ErrNotExist)
 }
 
@@ -81,7 +82,7 @@ func IsEmptyDirectory(path string) bool {
 }
 
 func WriteStringToFile(path string, contents string) error {
-	return os.WriteFile(path, []byte(contents), os.ModePerm)
+	return WriteFile(path, []byte(contents))
 }
 
 func ReadContents(path string) (string, error) {
@@ -92,3 +93,20 @@ func ReadContents(path string) (string, error) {
 	contents := strings.TrimSpace(string(contentsBytes))
 	return strings.TrimSuffix(contents, "\n"), nil
 }
+
+// CaseInsensitive reports whether the filesystem of dir, which must exist,
+// treats names that only differ in case as the same name. It is found out
+// by creating a file and looking it up in upper case.
+func CaseInsensitive(dir string) bool {
+	f, err := os.CreateTemp(dir, "got-case-probe-")
+	if err != nil {
+		return false
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	upper := filepath.Join(filepath.Dir(name), strings.ToUpper(filepath.Base(name)))
+	_, err = os.Lstat(upper)
+	return err == nil
+}
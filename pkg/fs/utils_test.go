@@ -122,3 +122,23 @@ func TestWriteStringToFile(t *testing.T) {
 		t.Errorf("File content mismatch: got %q, want %q", string(data), content)
 	}
 }
+
+func TestCaseInsensitive(t *testing.T) {
+	dir := t.TempDir()
+	// The probe must not be left behind
+	defer func() {
+		if !IsEmptyDirectory(dir) {
+			t.Error("CaseInsensitive left a file behind")
+		}
+	}()
+
+	os.WriteFile(filepath.Join(dir, "lower"), nil, 0o644)
+	_, err := os.Lstat(filepath.Join(dir, "LOWER"))
+	os.Remove(filepath.Join(dir, "lower"))
+	if got, want := CaseInsensitive(dir), err == nil; got != want {
+		t.Errorf("CaseInsensitive() = %v, want %v", got, want)
+	}
+	if CaseInsensitive(filepath.Join(dir, "missing")) {
+		t.Error("CaseInsensitive() of a missing directory = true, want false")
+	}
+}
@@ -0,0 +1,117 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DefaultPerm is the permission used for files written by this package
+const DefaultPerm os.FileMode = 0o644
+
+type writeOptions struct {
+	perm os.FileMode
+	// exact permissions aren't reduced by the umask
+	exact bool
+	sync  bool
+}
+
+// WriteOption configures WriteFile and AtomicWrite
+type WriteOption func(*writeOptions)
+
+// WithPerm sets the permissions of the written file, minus the umask
+func WithPerm(perm os.FileMode) WriteOption {
+	return func(o *writeOptions) { o.perm, o.exact = perm, false }
+}
+
+// WithExactPerm sets the permissions of a file written by AtomicWrite,
+// regardless of the umask, e.g. ones that already take it into account
+func WithExactPerm(perm os.FileMode) WriteOption {
+	return func(o *writeOptions) { o.perm, o.exact = perm, true }
+}
+
+// WithSync flushes the file to disk before returning
+func WithSync() WriteOption {
+	return func(o *writeOptions) { o.sync = true }
+}
+
+func newWriteOptions(opts []WriteOption) writeOptions {
+	o := writeOptions{perm: DefaultPerm}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WriteFile writes data to path, replacing its contents. New files are
+// created with the configured permissions, minus the umask.
+func WriteFile(path string, data []byte, opts ...WriteOption) error {
+	o := newWriteOptions(opts)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, o.perm)
+	if err != nil {
+		return err
+	}
+	return finishWrite(f, data, o)
+}
+
+// AtomicWrite writes data to a temporary file next to path and renames it
+// into place, so readers see either the old or the new contents and an
+// interrupted write leaves no partial file behind. Like with WriteFile,
+// the file gets the configured permissions minus the umask.
+func AtomicWrite(path string, data []byte, opts ...WriteOption) error {
+	o := newWriteOptions(opts)
+	f, err := TempFile(filepath.Dir(path), ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	if err := finishWrite(f, data, o); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	perm := o.perm
+	if !o.exact {
+		perm &^= Umask()
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// TempFile creates a new temporary file in dir, creating dir if needed.
+// See os.CreateTemp for the meaning of pattern.
+func TempFile(dir, pattern string) (*os.File, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
+}
+
+// Lstat returns information about path without following symlinks. A
+// missing path is not an error: ok is false and info is nil.
+func Lstat(path string) (info os.FileInfo, ok bool, err error) {
+	info, err = os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return info, true, nil
+}
+
+func finishWrite(f *os.File, data []byte, o writeOptions) error {
+	_, err := f.Write(data)
+	if err == nil && o.sync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
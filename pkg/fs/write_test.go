@@ -0,0 +1,160 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		opts     []WriteOption
+		data     string
+	}{
+		{name: "new file", data: "hello"},
+		{name: "truncates existing file", existing: "a much longer text", data: "short"},
+		{name: "synced", opts: []WriteOption{WithSync()}, data: "synced"},
+		{name: "empty", data: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := WriteFile(path, []byte(tt.data), tt.opts...); err != nil {
+				t.Fatalf("WriteFile returned error: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.data {
+				t.Errorf("File content mismatch: got %q, want %q", data, tt.data)
+			}
+		})
+	}
+}
+
+func TestAtomicWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+		opt      WriteOption
+		want     os.FileMode
+	}{
+		{name: "new file", want: DefaultPerm &^ Umask()},
+		{name: "replaces existing file", existing: true, want: DefaultPerm &^ Umask()},
+		{name: "read-only", opt: WithPerm(0o444), want: 0o444 &^ Umask()},
+		{name: "applies umask", opt: WithPerm(0o666), want: 0o666 &^ Umask()},
+		{name: "exact", opt: WithExactPerm(0o666), want: 0o666},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "file")
+			if tt.existing {
+				if err := os.WriteFile(path, []byte("old contents"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := []WriteOption{WithSync()}
+			if tt.opt != nil {
+				opts = append(opts, tt.opt)
+			}
+			err := AtomicWrite(path, []byte("new"), opts...)
+			if err != nil {
+				t.Fatalf("AtomicWrite returned error: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "new" {
+				t.Errorf("File content mismatch: got %q, want %q", data, "new")
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.want {
+				t.Errorf("Permissions mismatch: got %o, want %o", info.Mode().Perm(), tt.want)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("Temporary files left behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestAtomicWriteMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "file")
+	if err := AtomicWrite(path, []byte("data")); err != nil {
+		t.Fatalf("AtomicWrite returned error: %v", err)
+	}
+	if !IsFile(path) {
+		t.Errorf("AtomicWrite should create the parent directory")
+	}
+}
+
+func TestTempFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sub")
+	f, err := TempFile(dir, "tmp-*")
+	if err != nil {
+		t.Fatalf("TempFile returned error: %v", err)
+	}
+	defer f.Close()
+	if filepath.Dir(f.Name()) != dir {
+		t.Errorf("TempFile created %s outside of %s", f.Name(), dir)
+	}
+}
+
+func TestLstat(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("missing", link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantOk  bool
+		symlink bool
+	}{
+		{"file", file, true, false},
+		{"dangling symlink", link, true, true},
+		{"missing", filepath.Join(dir, "missing"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok, err := Lstat(tt.path)
+			if err != nil {
+				t.Fatalf("Lstat returned error: %v", err)
+			}
+			if ok != tt.wantOk {
+				t.Errorf("Lstat ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && (info.Mode()&os.ModeSymlink != 0) != tt.symlink {
+				t.Errorf("Lstat mode = %v, symlink %v", info.Mode(), tt.symlink)
+			}
+		})
+	}
+}
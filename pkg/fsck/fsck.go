@@ -0,0 +1,283 @@
+// Consistency checks for git objects, e.g. for objects received from a remote
+package fsck
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+)
+
+// MessageID identifies a kind of problem, using the same names as git,
+// so that fsck.<msg-id> settings can be shared between git and got
+type MessageID string
+
+const (
+	BadDate                 MessageID = "badDate"
+	BadEmail                MessageID = "badEmail"
+	BadFilemode             MessageID = "badFilemode"
+	BadName                 MessageID = "badName"
+	BadObjectSha1           MessageID = "badObjectSha1"
+	BadParentSha1           MessageID = "badParentSha1"
+	BadTagName              MessageID = "badTagName"
+	BadTimezone             MessageID = "badTimezone"
+	BadTreeSha1             MessageID = "badTreeSha1"
+	BadType                 MessageID = "badType"
+	DuplicateEntries        MessageID = "duplicateEntries"
+	EmptyName               MessageID = "emptyName"
+	FullPathname            MessageID = "fullPathname"
+	HasDot                  MessageID = "hasDot"
+	HasDotdot               MessageID = "hasDotdot"
+	HasDotgit               MessageID = "hasDotgit"
+	MissingAuthor           MessageID = "missingAuthor"
+	MissingCommitter        MessageID = "missingCommitter"
+	MissingEmail            MessageID = "missingEmail"
+	MissingNameBeforeEmail  MessageID = "missingNameBeforeEmail"
+	MissingObject           MessageID = "missingObject"
+	MissingSpaceBeforeDate  MessageID = "missingSpaceBeforeDate"
+	MissingSpaceBeforeEmail MessageID = "missingSpaceBeforeEmail"
+	MissingTagEntry         MessageID = "missingTagEntry"
+	MissingTaggerEntry      MessageID = "missingTaggerEntry"
+	MissingTree             MessageID = "missingTree"
+	MissingTypeEntry        MessageID = "missingTypeEntry"
+	TreeNotSorted           MessageID = "treeNotSorted"
+)
+
+// Finding is a single problem found in an object
+type Finding struct {
+	Object   *hashing.SHA
+	ID       MessageID
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	object := "object"
+	if f.Object != nil {
+		object = f.Object.AsString()
+	}
+	return fmt.Sprintf("%s in %s: %s: %s", f.Severity, object, f.ID, f.Message)
+}
+
+var (
+	hexShaRegex  = regexp.MustCompile("^[0-9a-f]{40}$")
+	tagNameRegex = regexp.MustCompile(`^[^\x00-\x20~^:?*\[\\]+$`)
+)
+
+// Check validates a single object. It returns every finding that is not
+// ignored, and an error if at least one of them has error severity.
+func (o *Options) Check(sha *hashing.SHA, obj objects.GitObject) ([]Finding, error) {
+	c := &checker{options: o, sha: sha}
+
+	switch obj := obj.(type) {
+	case *objects.Commit:
+		c.checkCommit(obj)
+	case *objects.Tag:
+		c.checkTag(obj)
+	case *objects.Tree:
+		c.checkTree(obj)
+	case *objects.Blob:
+		// Any content is a valid blob
+	default:
+		return nil, errors.New("unknown object type")
+	}
+
+	for _, f := range c.findings {
+		if f.Severity == SeverityError {
+			return c.findings, errors.New(f.String())
+		}
+	}
+	return c.findings, nil
+}
+
+type checker struct {
+	options  *Options
+	sha      *hashing.SHA
+	findings []Finding
+}
+
+func (c *checker) report(id MessageID, format string, args ...any) {
+	severity := c.options.Severity(id)
+	if severity == SeverityIgnore {
+		return
+	}
+	c.findings = append(c.findings, Finding{
+		Object:   c.sha,
+		ID:       id,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) checkCommit(commit *objects.Commit) {
+	tree, ok := commit.GetValue("tree")
+	if !ok {
+		c.report(MissingTree, "invalid format - expected 'tree' line")
+	} else if !hexShaRegex.Match(tree) {
+		c.report(BadTreeSha1, "invalid 'tree' line format - bad sha1")
+	}
+
+	for _, parent := range commit.GetValues("parent") {
+		if !hexShaRegex.Match(parent) {
+			c.report(BadParentSha1, "invalid 'parent' line format - bad sha1")
+		}
+	}
+
+	authors := commit.GetValues("author")
+	if len(authors) == 0 {
+		c.report(MissingAuthor, "invalid format - expected 'author' line")
+	}
+	for _, author := range authors {
+		c.checkIdent(author)
+	}
+
+	committers := commit.GetValues("committer")
+	if len(committers) == 0 {
+		c.report(MissingCommitter, "invalid format - expected 'committer' line")
+	}
+	for _, committer := range committers {
+		c.checkIdent(committer)
+	}
+}
+
+func (c *checker) checkTag(tag *objects.Tag) {
+	object, ok := tag.GetValue("object")
+	if !ok {
+		c.report(MissingObject, "invalid format - expected 'object' line")
+	} else if !hexShaRegex.Match(object) {
+		c.report(BadObjectSha1, "invalid 'object' line format - bad sha1")
+	}
+
+	objType, ok := tag.GetValue("type")
+	if !ok {
+		c.report(MissingTypeEntry, "invalid format - expected 'type' line")
+	} else if _, err := objects.ParseType(string(objType)); err != nil {
+		c.report(BadType, "invalid 'type' value")
+	}
+
+	name, ok := tag.GetValue("tag")
+	if !ok {
+		c.report(MissingTagEntry, "invalid format - expected 'tag' line")
+	} else if !tagNameRegex.Match(name) {
+		c.report(BadTagName, "invalid 'tag' name: %s", name)
+	}
+
+	tagger, ok := tag.GetValue("tagger")
+	if !ok {
+		c.report(MissingTaggerEntry, "invalid format - expected 'tagger' line")
+	} else {
+		c.checkIdent(tagger)
+	}
+}
+
+func (c *checker) checkTree(tree *objects.Tree) {
+	seen := map[string]bool{}
+	previous := ""
+
+	for _, leaf := range tree.Items {
+		name := string(leaf.Path)
+		switch {
+		case name == "":
+			c.report(EmptyName, "contains empty pathname")
+		case strings.Contains(name, "/"):
+			c.report(FullPathname, "contains full pathnames")
+		case name == ".":
+			c.report(HasDot, "contains '.'")
+		case name == "..":
+			c.report(HasDotdot, "contains '..'")
+		case strings.EqualFold(name, ".git"):
+			c.report(HasDotgit, "contains '.git'")
+		}
+
+		if !isValidTreeMode(leaf.Mode) {
+			c.report(BadFilemode, "contains bad file modes")
+		}
+
+		if seen[name] {
+			c.report(DuplicateEntries, "contains duplicate file entries")
+		}
+		seen[name] = true
+
+		key := sortKey(leaf)
+		if previous != "" && key < previous {
+			c.report(TreeNotSorted, "not properly sorted")
+		}
+		previous = key
+	}
+}
+
+// checkIdent validates lines like "Name <email> 1700000000 +0100"
+func (c *checker) checkIdent(ident []byte) {
+	lt := bytes.IndexByte(ident, '<')
+	if lt < 0 {
+		c.report(MissingEmail, "invalid author/committer line - missing email")
+		return
+	}
+	if lt == 0 {
+		c.report(MissingNameBeforeEmail, "invalid author/committer line - missing space before email")
+		return
+	}
+	if ident[lt-1] != ' ' {
+		c.report(MissingSpaceBeforeEmail, "invalid author/committer line - missing space before email")
+		return
+	}
+	if bytes.ContainsAny(ident[:lt-1], "<>") {
+		c.report(BadName, "invalid author/committer line - bad name")
+		return
+	}
+
+	rest := ident[lt+1:]
+	gt := bytes.IndexByte(rest, '>')
+	if gt < 0 || bytes.ContainsAny(rest[:gt], "<") {
+		c.report(BadEmail, "invalid author/committer line - bad email")
+		return
+	}
+
+	rest = rest[gt+1:]
+	if len(rest) == 0 || rest[0] != ' ' {
+		c.report(MissingSpaceBeforeDate, "invalid author/committer line - missing space before date")
+		return
+	}
+
+	fields := strings.Split(string(rest[1:]), " ")
+	if len(fields) != 2 || !isDigits(fields[0]) || (len(fields[0]) > 1 && fields[0][0] == '0') {
+		c.report(BadDate, "invalid author/committer line - bad date")
+		return
+	}
+	tz := fields[1]
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') || !isDigits(tz[1:]) {
+		c.report(BadTimezone, "invalid author/committer line - bad time zone")
+	}
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidTreeMode(mode []byte) bool {
+	switch strings.TrimLeft(string(mode), "0") {
+	case "100644", "100755", "120000", "160000", "40000":
+		return true
+	}
+	return false
+}
+
+// Same ordering as the one used when serializing trees
+func sortKey(leaf *objects.TreeLeaf) string {
+	if strings.HasPrefix(strings.TrimLeft(string(leaf.Mode), "0"), "4") {
+		return string(leaf.Path) + "/"
+	}
+	return string(leaf.Path)
+}
@@ -0,0 +1,222 @@
+package fsck
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+const treeSha = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+func commitFromString(t *testing.T, raw string) *objects.Commit {
+	commit := &objects.Commit{}
+	if err := commit.Deserialize([]byte(raw)); err != nil {
+		t.Fatalf("Failed to deserialize commit: %v", err)
+	}
+	return commit
+}
+
+func TestCheck_Commit(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantIDs []MessageID
+		wantErr bool
+	}{
+		{
+			name:    "valid commit",
+			raw:     "tree " + treeSha + "\nauthor A U Thor <a@example.com> 1700000000 +0100\ncommitter A U Thor <a@example.com> 1700000000 +0100\n\nmsg\n",
+			wantIDs: nil,
+		},
+		{
+			name:    "missing tree",
+			raw:     "author A <a@example.com> 1700000000 +0100\ncommitter A <a@example.com> 1700000000 +0100\n\nmsg\n",
+			wantIDs: []MessageID{MissingTree},
+			wantErr: true,
+		},
+		{
+			name:    "bad parent",
+			raw:     "tree " + treeSha + "\nparent xyz\nauthor A <a@example.com> 1700000000 +0100\ncommitter A <a@example.com> 1700000000 +0100\n\nmsg\n",
+			wantIDs: []MessageID{BadParentSha1},
+			wantErr: true,
+		},
+		{
+			name:    "malformed committer",
+			raw:     "tree " + treeSha + "\nauthor A <a@example.com> 1700000000 +0100\ncommitter A 1700000000 +0100\n\nmsg\n",
+			wantIDs: []MessageID{MissingEmail},
+			wantErr: true,
+		},
+		{
+			name:    "bad timezone",
+			raw:     "tree " + treeSha + "\nauthor A <a@example.com> 1700000000 0100\ncommitter A <a@example.com> 1700000000 +0100\n\nmsg\n",
+			wantIDs: []MessageID{BadTimezone},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := DefaultOptions().Check(nil, commitFromString(t, tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(findings) != len(tt.wantIDs) {
+				t.Fatalf("Check() findings = %v, want %v", findings, tt.wantIDs)
+			}
+			for i, f := range findings {
+				if f.ID != tt.wantIDs[i] {
+					t.Errorf("Check() finding %d = %s, want %s", i, f.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheck_Tree(t *testing.T) {
+	sha, _ := hashing.NewShaFromHex(treeSha)
+	tree := &objects.Tree{Items: []*objects.TreeLeaf{
+		{Sha: sha, Path: []byte("b"), Mode: []byte("100644")},
+		{Sha: sha, Path: []byte("a"), Mode: []byte("100644")},
+		{Sha: sha, Path: []byte(".git"), Mode: []byte("040000")},
+	}}
+
+	findings, err := DefaultOptions().Check(nil, tree)
+	if err == nil {
+		t.Error("Expected unsorted tree to be an error")
+	}
+	ids := map[MessageID]bool{}
+	for _, f := range findings {
+		ids[f.ID] = true
+	}
+	if !ids[TreeNotSorted] || !ids[HasDotgit] {
+		t.Errorf("Unexpected findings: %v", findings)
+	}
+}
+
+func TestCheck_SeverityConfiguration(t *testing.T) {
+	raw := "tree " + treeSha + "\nauthor A <a@example.com> 1700000000 +0100\ncommitter A 1700000000 +0100\n\nmsg\n"
+
+	options := DefaultOptions()
+	options.SetSeverity(MissingEmail, SeverityWarn)
+	findings, err := options.Check(nil, commitFromString(t, raw))
+	if err != nil {
+		t.Errorf("Expected warnings not to fail the check, got %v", err)
+	}
+	if len(findings) != 1 || findings[0].Severity != SeverityWarn {
+		t.Errorf("Unexpected findings: %v", findings)
+	}
+
+	options.SetSeverity(MissingEmail, SeverityIgnore)
+	findings, _ = options.Check(nil, commitFromString(t, raw))
+	if len(findings) != 0 {
+		t.Errorf("Expected ignored findings not to be reported, got %v", findings)
+	}
+}
+
+func TestLoadOptions(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config")
+	contents := "[fsck]\nmissingEmail = warn\nbadDate = ignore\nskipList = .git/skip\n" +
+		"[receive \"fsck\"]\nmissingemail = error\n" +
+		"[receive]\nfsckObjects = true\n"
+	if err := os.WriteFile(cfgPath, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.ReadWithRepository(cfgPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+
+	options, err := LoadOptions(cfg, "")
+	if err != nil {
+		t.Fatalf("LoadOptions() error = %v", err)
+	}
+	if options.Severity(MissingEmail) != SeverityWarn || options.Severity(BadDate) != SeverityIgnore {
+		t.Error("Expected fsck.<msg-id> to be applied")
+	}
+
+	options, err = LoadOptions(cfg, "receive")
+	if err != nil {
+		t.Fatalf("LoadOptions() error = %v", err)
+	}
+	if options.Severity(MissingEmail) != SeverityError {
+		t.Error("Expected receive.fsck.<msg-id> to take precedence")
+	}
+
+	if !Enabled(cfg, "receive") {
+		t.Error("Expected receive.fsckObjects to be enabled")
+	}
+	if Enabled(cfg, "fetch") {
+		t.Error("Expected fetch.fsckObjects to be disabled")
+	}
+}
+
+func TestCheckFunc(t *testing.T) {
+	options := DefaultOptions()
+	options.SetSeverity(MissingEmail, SeverityWarn)
+	var warnings bytes.Buffer
+	check := options.CheckFunc(&warnings)
+
+	// A commit without an author is refused
+	corrupt := "tree " + treeSha + "\ncommitter A <a@example.com> 1700000000 +0100\n\nmsg\n"
+	if err := check(nil, commitFromString(t, corrupt)); err == nil {
+		t.Error("Expected a commit without an author to be refused")
+	}
+
+	warned := "tree " + treeSha + "\nauthor A <a@example.com> 1700000000 +0100\ncommitter A 1700000000 +0100\n\nmsg\n"
+	warnings.Reset()
+	if err := check(nil, commitFromString(t, warned)); err != nil {
+		t.Errorf("Expected warnings not to refuse the commit, got %v", err)
+	}
+	if !bytes.Contains(warnings.Bytes(), []byte(MissingEmail)) {
+		t.Errorf("Expected the warning to be written, got %q", warnings.String())
+	}
+}
+
+func TestCheckFunc_IncomingPack(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	// A commit without an author, which fsck refuses
+	corrupt := []byte("tree " + treeSha + "\ncommitter A <a@example.com> 1700000000 +0100\n\nmsg\n")
+	blob := []byte("fine\n")
+	objs := []pack.Object{
+		{Name: hashing.NewSHA(append([]byte(fmt.Sprintf("blob %d\x00", len(blob))), blob...)).AsBytes(), Type: pack.TypeBlob, Data: blob},
+		{Name: hashing.NewSHA(append([]byte(fmt.Sprintf("commit %d\x00", len(corrupt))), corrupt...)).AsBytes(), Type: pack.TypeCommit, Data: corrupt},
+	}
+	var stream bytes.Buffer
+	if _, _, err := pack.Write(&stream, objs, pack.DefaultWriteOptions); err != nil {
+		t.Fatal(err)
+	}
+	check := DefaultOptions().CheckFunc(io.Discard)
+
+	if _, err := objects.IndexPack(repo, bytes.NewReader(stream.Bytes()), check); err == nil {
+		t.Error("IndexPack() of a pack with a corrupt commit succeeded")
+	}
+	if packs, _ := os.ReadDir(repo.RepositoryPath("objects", "pack")); len(packs) > 0 {
+		t.Errorf("IndexPack() stored the refused pack: %v", packs)
+	}
+	if _, err := objects.UnpackObjects(repo, bytes.NewReader(stream.Bytes()), false, check); err == nil {
+		t.Error("UnpackObjects() of a pack with a corrupt commit succeeded")
+	}
+	for _, obj := range objs {
+		if objects.HasObject(repo, hashing.NewShaFromBytes(obj.Name)) {
+			t.Errorf("UnpackObjects() wrote object %x of the refused pack", obj.Name)
+		}
+	}
+
+	// Without checks, like when fsckObjects is off, the pack is taken
+	if _, err := objects.IndexPack(repo, bytes.NewReader(stream.Bytes()), nil); err != nil {
+		t.Errorf("IndexPack() without a check error = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package fsck
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+)
+
+type Severity string
+
+const (
+	SeverityError  Severity = "error"
+	SeverityWarn   Severity = "warn"
+	SeverityIgnore Severity = "ignore"
+)
+
+func ParseSeverity(severity string) (Severity, error) {
+	switch strings.ToLower(severity) {
+	case string(SeverityError):
+		return SeverityError, nil
+	case string(SeverityWarn):
+		return SeverityWarn, nil
+	case string(SeverityIgnore):
+		return SeverityIgnore, nil
+	}
+	return "", fmt.Errorf("invalid fsck severity: %s", severity)
+}
+
+// The severities git uses when nothing is configured.
+// Problems that do not make an object unusable are warnings.
+var defaultSeverities = map[MessageID]Severity{
+	BadDate:                 SeverityError,
+	BadEmail:                SeverityError,
+	BadFilemode:             SeverityWarn,
+	BadName:                 SeverityError,
+	BadObjectSha1:           SeverityError,
+	BadParentSha1:           SeverityError,
+	BadTagName:              SeverityWarn,
+	BadTimezone:             SeverityError,
+	BadTreeSha1:             SeverityError,
+	BadType:                 SeverityError,
+	DuplicateEntries:        SeverityError,
+	EmptyName:               SeverityWarn,
+	FullPathname:            SeverityWarn,
+	HasDot:                  SeverityWarn,
+	HasDotdot:               SeverityWarn,
+	HasDotgit:               SeverityWarn,
+	MissingAuthor:           SeverityError,
+	MissingCommitter:        SeverityError,
+	MissingEmail:            SeverityError,
+	MissingNameBeforeEmail:  SeverityError,
+	MissingObject:           SeverityError,
+	MissingSpaceBeforeDate:  SeverityError,
+	MissingSpaceBeforeEmail: SeverityError,
+	MissingTagEntry:         SeverityError,
+	MissingTaggerEntry:      SeverityWarn,
+	MissingTree:             SeverityError,
+	MissingTypeEntry:        SeverityError,
+	TreeNotSorted:           SeverityError,
+}
+
+// Options determine how severe each kind of problem is
+type Options struct {
+	severities map[MessageID]Severity
+}
+
+func DefaultOptions() *Options {
+	severities := make(map[MessageID]Severity, len(defaultSeverities))
+	for id, severity := range defaultSeverities {
+		severities[id] = severity
+	}
+	return &Options{severities: severities}
+}
+
+func (o *Options) Severity(id MessageID) Severity {
+	if severity, ok := o.severities[id]; ok {
+		return severity
+	}
+	return SeverityError
+}
+
+func (o *Options) SetSeverity(id MessageID, severity Severity) {
+	o.severities[id] = severity
+}
+
+// LoadOptions reads the fsck.<msg-id> settings from the configuration.
+//
+// If scope is set (e.g. "receive" or "fetch"), the <scope>.fsck.<msg-id>
+// settings are applied on top, like git does for objects that are transferred.
+func LoadOptions(cfg config.GitConfig, scope string) (*Options, error) {
+	options := DefaultOptions()
+
+	sections := []string{"fsck"}
+	if scope != "" {
+		sections = append(sections, fmt.Sprintf(`%s "fsck"`, scope))
+	}
+
+	for _, section := range sections {
+		for key, value := range cfg.Section(section) {
+			id, ok := lookupMessageID(key)
+			if !ok {
+				// Other fsck settings, like fsck.skipList, are not severities
+				continue
+			}
+			severity, err := ParseSeverity(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", section, key, err)
+			}
+			options.SetSeverity(id, severity)
+		}
+	}
+	return options, nil
+}
+
+// Enabled returns whether objects should be checked when they are
+// transferred in the given scope (e.g. "receive" or "fetch").
+// <scope>.fsckObjects takes precedence over transfer.fsckObjects.
+func Enabled(cfg config.GitConfig, scope string) bool {
+	if enabled, ok := cfg.GetBool(scope, "fsckObjects"); ok {
+		return enabled
+	}
+	enabled, _ := cfg.GetBool("transfer", "fsckObjects")
+	return enabled
+}
+
+// CheckFunc returns a check of the objects that come in, which refuses
+// objects with errors. Findings that aren't errors are written to w.
+func (o *Options) CheckFunc(w io.Writer) objects.CheckFunc {
+	return func(sha *hashing.SHA, obj objects.GitObject) error {
+		findings, err := o.Check(sha, obj)
+		if err != nil {
+			return err
+		}
+		for _, f := range findings {
+			fmt.Fprintln(w, f)
+		}
+		return nil
+	}
+}
+
+// Config keys are case insensitive
+func lookupMessageID(key string) (MessageID, bool) {
+	for id := range defaultSeverities {
+		if strings.EqualFold(string(id), key) {
+			return id, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,112 @@
+package gitdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode is how Format shows a date, like git's --date
+type Mode string
+
+const (
+	ModeDefault   Mode = "default"
+	ModeLocal     Mode = "local"
+	ModeRelative  Mode = "relative"
+	ModeISO       Mode = "iso"
+	ModeISOStrict Mode = "iso-strict"
+	ModeRFC       Mode = "rfc"
+	ModeShort     Mode = "short"
+	ModeRaw       Mode = "raw"
+	ModeUnix      Mode = "unix"
+)
+
+// modeAliases are the other names git accepts for modes
+var modeAliases = map[string]Mode{
+	"iso8601":        ModeISO,
+	"iso8601-strict": ModeISOStrict,
+	"rfc2822":        ModeRFC,
+	"default-local":  ModeLocal,
+}
+
+// ParseMode parses the value of --date
+func ParseMode(value string) (Mode, error) {
+	if mode, ok := modeAliases[value]; ok {
+		return mode, nil
+	}
+	switch mode := Mode(value); mode {
+	case ModeDefault, ModeLocal, ModeRelative, ModeISO, ModeISOStrict, ModeRFC, ModeShort, ModeRaw, ModeUnix:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown date format %s", value)
+}
+
+// Format shows t in mode. Dates are shown in their own time zone, except
+// in ModeLocal.
+func Format(t time.Time, mode Mode) string {
+	switch mode {
+	case ModeLocal:
+		return t.Local().Format("Mon Jan 2 15:04:05 2006")
+	case ModeRelative:
+		return FormatRelative(t, time.Now())
+	case ModeISO:
+		return t.Format("2006-01-02 15:04:05 -0700")
+	case ModeISOStrict:
+		return t.Format(time.RFC3339)
+	case ModeRFC:
+		return t.Format("Mon, 2 Jan 2006 15:04:05 -0700")
+	case ModeShort:
+		return t.Format("2006-01-02")
+	case ModeRaw:
+		return strconv.FormatInt(t.Unix(), 10) + " " + t.Format("-0700")
+	case ModeUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return t.Format(DefaultLayout)
+}
+
+// FormatRelative shows how long before now t was, rounded like git does
+// it, e.g. "3 hours ago" or "2 years, 1 month ago"
+func FormatRelative(t, now time.Time) string {
+	diff := int64(now.Sub(t) / time.Second)
+	if diff < 0 {
+		return "in the future"
+	}
+	if diff < 90 {
+		return plural(diff, "second") + " ago"
+	}
+	// Each unit is rounded to the nearest one of the next
+	if diff = (diff + 30) / 60; diff < 90 {
+		return plural(diff, "minute") + " ago"
+	}
+	if diff = (diff + 30) / 60; diff < 36 {
+		return plural(diff, "hour") + " ago"
+	}
+	if diff = (diff + 12) / 24; diff < 14 {
+		return plural(diff, "day") + " ago"
+	}
+	if diff < 70 {
+		return plural((diff+3)/7, "week") + " ago"
+	}
+	if diff < 365 {
+		return plural((diff+15)/30, "month") + " ago"
+	}
+	if diff < 1825 {
+		totalMonths := (diff*12*2 + 365) / (365 * 2)
+		years, months := totalMonths/12, totalMonths%12
+		parts := []string{plural(years, "year")}
+		if months > 0 {
+			parts = append(parts, plural(months, "month"))
+		}
+		return strings.Join(parts, ", ") + " ago"
+	}
+	return plural((diff+183)/365, "year") + " ago"
+}
+
+func plural(n int64, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
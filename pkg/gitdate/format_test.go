@@ -0,0 +1,62 @@
+package gitdate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	when := time.Unix(1577927045, 0).In(time.FixedZone("", 2*60*60))
+	tests := []struct {
+		mode Mode
+		want string
+	}{
+		{ModeDefault, "Thu Jan 2 03:04:05 2020 +0200"},
+		{ModeISO, "2020-01-02 03:04:05 +0200"},
+		{ModeISOStrict, "2020-01-02T03:04:05+02:00"},
+		{ModeRFC, "Thu, 2 Jan 2020 03:04:05 +0200"},
+		{ModeShort, "2020-01-02"},
+		{ModeRaw, "1577927045 +0200"},
+		{ModeUnix, "1577927045"},
+	}
+	for _, tt := range tests {
+		if got := Format(when, tt.mode); got != tt.want {
+			t.Errorf("Format(%s) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if mode, err := ParseMode("rfc2822"); err != nil || mode != ModeRFC {
+		t.Errorf("ParseMode(rfc2822) = %s, %v, want rfc", mode, err)
+	}
+	if _, err := ParseMode("fancy"); err == nil {
+		t.Errorf("ParseMode(fancy) should fail")
+	}
+}
+
+func TestFormatRelative(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{ago: 1 * time.Second, want: "1 second ago"},
+		{ago: 89 * time.Second, want: "89 seconds ago"},
+		{ago: 90 * time.Second, want: "2 minutes ago"},
+		{ago: 3 * time.Hour, want: "3 hours ago"},
+		{ago: 35 * time.Hour, want: "35 hours ago"},
+		{ago: 5 * 24 * time.Hour, want: "5 days ago"},
+		{ago: 20 * 24 * time.Hour, want: "3 weeks ago"},
+		{ago: 100 * 24 * time.Hour, want: "3 months ago"},
+		{ago: 365 * 24 * time.Hour, want: "1 year ago"},
+		{ago: 400 * 24 * time.Hour, want: "1 year, 1 month ago"},
+		{ago: 3000 * 24 * time.Hour, want: "8 years ago"},
+		{ago: -time.Hour, want: "in the future"},
+	}
+	for _, tt := range tests {
+		if got := FormatRelative(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("FormatRelative(%s ago) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+}
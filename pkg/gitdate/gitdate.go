@@ -0,0 +1,146 @@
+// Package gitdate parses and formats dates the way git does. Parse reads
+// the exact formats that git stores and accepts in GIT_AUTHOR_DATE, and
+// Approxidate also reads the approximate dates of options like --since,
+// e.g. "2 weeks ago" or "yesterday". Format shows a date in one of the
+// modes of --date.
+package gitdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLayout is the layout git uses for dates by default
+const DefaultLayout = "Mon Jan 2 15:04:05 2006 -0700"
+
+// layouts are the date formats accepted by Parse, besides git's internal
+// "<unix timestamp> <zone>" format
+var layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05",
+	DefaultLayout,
+	"2006-01-02",
+}
+
+// Parse parses a date as accepted by git for GIT_AUTHOR_DATE:
+// "<unix timestamp> <zone>" (optionally prefixed with @), RFC 2822 or
+// ISO 8601. Dates without a zone are in local time.
+func Parse(date string) (time.Time, error) {
+	date = strings.TrimSpace(date)
+
+	seconds, zone, hasZone := strings.Cut(strings.TrimPrefix(date, "@"), " ")
+	if unix, err := strconv.ParseInt(seconds, 10, 64); err == nil {
+		when := time.Unix(unix, 0)
+		if !hasZone {
+			return when, nil
+		}
+		if z, err := time.Parse("-0700", zone); err == nil {
+			return when.In(z.Location()), nil
+		}
+	}
+
+	for _, layout := range layouts {
+		if when, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			return when, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format '%s'", date)
+}
+
+// units are the units of relative dates, like 2.weeks.ago
+var units = map[string]func(t time.Time, n int) time.Time{
+	"second": func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Second) },
+	"minute": func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Minute) },
+	"hour":   func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Hour) },
+	"day":    func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -n) },
+	"week":   func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -7*n) },
+	"month":  func(t time.Time, n int) time.Time { return t.AddDate(0, -n, 0) },
+	"year":   func(t time.Time, n int) time.Time { return t.AddDate(-n, 0, 0) },
+}
+
+// numbers are the numbers that can be written out in relative dates
+var numbers = map[string]int{
+	"a": 1, "an": 1, "last": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+}
+
+// Approxidate parses a date like git's approxidate: what Parse accepts,
+// "now", "today", "yesterday", "noon", "midnight", and dates relative to
+// now like "2 weeks ago", "3.days.ago", "last month" or "an hour ago".
+// Unlike git, which guesses something for any input, it fails for
+// anything else.
+func Approxidate(date string, now time.Time) (time.Time, error) {
+	if when, err := Parse(date); err == nil {
+		return when, nil
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(date), func(r rune) bool { return r == '.' || r == ' ' || r == ',' })
+	fields = trimAgo(fields)
+	switch strings.Join(fields, " ") {
+	case "now", "today":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	case "midnight":
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
+	case "noon":
+		noon := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, now.Location())
+		// Like git, noon is the last one that has passed
+		if noon.After(now) {
+			noon = noon.AddDate(0, 0, -1)
+		}
+		return noon, nil
+	}
+
+	// Relative dates can combine units, like "1 year 2 months ago"
+	when, relative := now, false
+	for len(fields) >= 2 {
+		n, ok := numbers[fields[0]]
+		if !ok {
+			var err error
+			if n, err = strconv.Atoi(fields[0]); err != nil || n < 0 {
+				break
+			}
+		}
+		unit, ok := units[strings.TrimSuffix(fields[1], "s")]
+		if !ok {
+			break
+		}
+		when, fields, relative = unit(when, n), fields[2:], true
+	}
+	if len(fields) > 0 || !relative {
+		return time.Time{}, fmt.Errorf("unknown date format '%s'", date)
+	}
+	return when, nil
+}
+
+// trimAgo removes a trailing "ago", which relative dates may have
+func trimAgo(fields []string) []string {
+	if len(fields) > 0 && fields[len(fields)-1] == "ago" {
+		return fields[:len(fields)-1]
+	}
+	return fields
+}
+
+// Expiry parses an expiry time like git: "now" and "all" are now, "never"
+// and "false" are the start of the epoch, so that nothing is older, and
+// anything else is parsed with Approxidate
+func Expiry(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "now", "all":
+		return now, nil
+	case "never", "false":
+		return time.Unix(0, 0), nil
+	}
+	when, err := Approxidate(value, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry time '%s'", value)
+	}
+	return when, nil
+}
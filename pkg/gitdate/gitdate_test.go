@@ -0,0 +1,91 @@
+package gitdate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		unix    int64
+		zone    string
+		wantErr bool
+	}{
+		{input: "1700000000 +0100", unix: 1700000000, zone: "+0100"},
+		{input: "@1700000000 -0530", unix: 1700000000, zone: "-0530"},
+		{input: "2020-01-02T03:04:05Z", unix: 1577934245, zone: "+0000"},
+		{input: "2020-01-02 03:04:05 +0200", unix: 1577927045, zone: "+0200"},
+		{input: "Thu, 2 Jan 2020 03:04:05 +0200", unix: 1577927045, zone: "+0200"},
+		{input: "Thu Jan 2 03:04:05 2020 +0200", unix: 1577927045, zone: "+0200"},
+		{input: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			when, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if when.Unix() != tt.unix || when.Format("-0700") != tt.zone {
+				t.Errorf("Parse() = %d %s, want %d %s", when.Unix(), when.Format("-0700"), tt.unix, tt.zone)
+			}
+		})
+	}
+}
+
+func TestApproxidate(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "now", want: now},
+		{input: "2 weeks ago", want: time.Date(2024, time.March, 1, 10, 30, 0, 0, time.UTC)},
+		{input: "3.days.ago", want: time.Date(2024, time.March, 12, 10, 30, 0, 0, time.UTC)},
+		{input: "1 month ago", want: time.Date(2024, time.February, 15, 10, 30, 0, 0, time.UTC)},
+		{input: "1 year, 2 months ago", want: time.Date(2023, time.January, 15, 10, 30, 0, 0, time.UTC)},
+		{input: "an hour ago", want: time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)},
+		{input: "last week", want: time.Date(2024, time.March, 8, 10, 30, 0, 0, time.UTC)},
+		{input: "90 minutes", want: time.Date(2024, time.March, 15, 9, 0, 0, 0, time.UTC)},
+		{input: "yesterday", want: time.Date(2024, time.March, 14, 10, 30, 0, 0, time.UTC)},
+		{input: "midnight", want: time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{input: "noon", want: time.Date(2024, time.March, 14, 12, 0, 0, 0, time.UTC)},
+		{input: "2024-01-02 03:04:05 +0000", want: time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)},
+		{input: "@1700000000", want: time.Unix(1700000000, 0)},
+		{input: "2 fortnights ago", wantErr: true},
+		{input: "ago", wantErr: true},
+		{input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Approxidate(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Approxidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("Approxidate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	if got, _ := Expiry("never", now); got.Unix() != 0 {
+		t.Errorf("Expiry(never) = %s, want the epoch", got)
+	}
+	if got, _ := Expiry("all", now); !got.Equal(now) {
+		t.Errorf("Expiry(all) = %s, want now", got)
+	}
+	if got, _ := Expiry("2.weeks.ago", now); !got.Equal(now.AddDate(0, 0, -14)) {
+		t.Errorf("Expiry(2.weeks.ago) = %s", got)
+	}
+	if _, err := Expiry("whenever", now); err == nil {
+		t.Errorf("Expiry(whenever) should fail")
+	}
+}
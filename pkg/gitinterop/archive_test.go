@@ -0,0 +1,62 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveModes(t *testing.T) {
+	dir := gitInit(t)
+	writeFile(t, dir, "file", "contents\n")
+	writeFile(t, dir, "script", "#!/bin/sh\n")
+	if err := os.Chmod(filepath.Join(dir, "script"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "files")
+
+	want := map[string]fs.FileMode{
+		"file":   0o644,
+		"script": 0o755,
+		"link":   fs.ModeSymlink | 0o777,
+	}
+
+	out := got(t, dir, "archive", "--format=tar", "HEAD")
+	tr := tar.NewReader(bytes.NewReader([]byte(out)))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if mode := header.FileInfo().Mode(); mode != want[header.Name] {
+			t.Errorf("tar mode of %s = %v, want %v", header.Name, mode, want[header.Name])
+		}
+		if header.Name == "link" && header.Linkname != "file" {
+			t.Errorf("tar link target = %q, want file", header.Linkname)
+		}
+	}
+
+	out = got(t, dir, "archive", "--format=zip", "HEAD")
+	zr, err := zip.NewReader(bytes.NewReader([]byte(out)), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if mode := f.Mode(); mode != want[f.Name] {
+			t.Errorf("zip mode of %s = %v, want %v", f.Name, mode, want[f.Name])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCloneNarrowed(t *testing.T) {
+	src := gitInit(t)
+	writeFile(t, src, "a.txt", "on master\n")
+	git(t, src, "add", "a.txt")
+	git(t, src, "commit", "-q", "-m", "first")
+	git(t, src, "tag", "-a", "-m", "version 1", "v1")
+	git(t, src, "checkout", "-q", "-b", "topic")
+	writeFile(t, src, "b.txt", "on topic\n")
+	git(t, src, "add", "b.txt")
+	git(t, src, "commit", "-q", "-m", "topic")
+	git(t, src, "tag", "t1")
+	git(t, src, "checkout", "-q", "master")
+	url := "ssh://localhost" + src
+
+	tests := []struct {
+		name    string
+		args    []string
+		refs    string
+		fetch   string
+		tagOpt  string
+		checked string
+	}{
+		{
+			name:    "all",
+			refs:    "refs/heads/master\nrefs/remotes/origin/HEAD\nrefs/remotes/origin/master\nrefs/remotes/origin/topic\nrefs/tags/t1\nrefs/tags/v1\n",
+			fetch:   "+refs/heads/*:refs/remotes/origin/*\n",
+			checked: "refs/heads/master\n",
+		},
+		{
+			// The tags that point to the branch come along
+			name:    "single branch",
+			args:    []string{"--single-branch"},
+			refs:    "refs/heads/master\nrefs/remotes/origin/HEAD\nrefs/remotes/origin/master\nrefs/tags/v1\n",
+			fetch:   "+refs/heads/master:refs/remotes/origin/master\n",
+			checked: "refs/heads/master\n",
+		},
+		{
+			name:    "no tags",
+			args:    []string{"--no-tags"},
+			refs:    "refs/heads/master\nrefs/remotes/origin/HEAD\nrefs/remotes/origin/master\nrefs/remotes/origin/topic\n",
+			fetch:   "+refs/heads/*:refs/remotes/origin/*\n",
+			tagOpt:  "--no-tags\n",
+			checked: "refs/heads/master\n",
+		},
+		{
+			name:    "single branch without tags",
+			args:    []string{"--single-branch", "--no-tags", "-b", "topic"},
+			refs:    "refs/heads/topic\nrefs/remotes/origin/topic\n",
+			fetch:   "+refs/heads/topic:refs/remotes/origin/topic\n",
+			tagOpt:  "--no-tags\n",
+			checked: "refs/heads/topic\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "clone")
+			got(t, filepath.Dir(dst), append(append([]string{"clone"}, tt.args...), url, dst)...)
+			fsck(t, dst)
+			if refs := git(t, dst, "for-each-ref", "--format=%(refname)"); refs != tt.refs {
+				t.Errorf("refs = %q, want %q", refs, tt.refs)
+			}
+			if fetch := git(t, dst, "config", "--get-all", "remote.origin.fetch"); fetch != tt.fetch {
+				t.Errorf("remote.origin.fetch = %q, want %q", fetch, tt.fetch)
+			}
+			tagOpt, _ := runTool(t, dst, nil, "git", "config", "remote.origin.tagOpt")
+			if tagOpt != tt.tagOpt {
+				t.Errorf("remote.origin.tagOpt = %q, want %q", tagOpt, tt.tagOpt)
+			}
+			if head := git(t, dst, "symbolic-ref", "HEAD"); head != tt.checked {
+				t.Errorf("HEAD = %q, want %q", head, tt.checked)
+			}
+		})
+	}
+
+	// Later fetches stay narrowed
+	dst := filepath.Join(t.TempDir(), "clone")
+	got(t, filepath.Dir(dst), "clone", "--single-branch", "--no-tags", url, dst)
+	git(t, src, "tag", "v2", "topic")
+	git(t, src, "branch", "other")
+	got(t, dst, "fetch")
+	if refs := git(t, dst, "for-each-ref", "--format=%(refname)"); refs != "refs/heads/master\nrefs/remotes/origin/HEAD\nrefs/remotes/origin/master\n" {
+		t.Errorf("refs after a fetch = %q, want only master", refs)
+	}
+}
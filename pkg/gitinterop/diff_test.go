@@ -0,0 +1,72 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestDiffNoIndexExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "first\n")
+	writeFile(t, dir, "same.txt", "first\n")
+	writeFile(t, dir, "b.txt", "second\n")
+
+	// exitStatus runs a tool and returns its output and exit status
+	exitStatus := func(name string, args ...string) (string, int) {
+		t.Helper()
+		out, err := runTool(t, dir, nil, name, args...)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return out, exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return out, 0
+	}
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+	}{
+		{"same", []string{"diff", "--no-index", "a.txt", "same.txt"}, 0},
+		{"different", []string{"diff", "--no-index", "a.txt", "b.txt"}, 1},
+		{"different names", []string{"diff", "--no-index", "--name-only", "a.txt", "b.txt"}, 1},
+		{"same stat", []string{"diff", "--no-index", "--stat", "a.txt", "same.txt"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitOut, gitStatus := exitStatus("git", tt.args...)
+			gotOut, gotStatus := exitStatus(gotBinary, tt.args...)
+			if gitStatus != tt.status || gotStatus != tt.status {
+				t.Errorf("exit status of git = %d, got = %d, want %d", gitStatus, gotStatus, tt.status)
+			}
+			if gotOut != gitOut {
+				t.Errorf("got printed %q, git printed %q", gotOut, gitOut)
+			}
+		})
+	}
+}
+
+func TestLogRaw(t *testing.T) {
+	dir := gitInit(t)
+	writeFile(t, dir, "a.txt", "one\ntwo\nthree\nfour\nfive\n")
+	writeFile(t, dir, "b.txt", "b\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "first", "-m", "with a body")
+	git(t, dir, "mv", "a.txt", "c.txt")
+	writeFile(t, dir, "b.txt", "changed\n")
+	git(t, dir, "commit", "-q", "-a", "-m", "second")
+	git(t, dir, "checkout", "-q", "-b", "side", "HEAD~1")
+	writeFile(t, dir, "side.txt", "side\n")
+	git(t, dir, "add", "side.txt")
+	git(t, dir, "commit", "-q", "-m", "side")
+	git(t, dir, "checkout", "-q", "master")
+	git(t, dir, "merge", "-q", "--no-edit", "side")
+
+	if gitOut, gotOut := git(t, dir, "log", "--raw"), got(t, dir, "log", "--raw"); gotOut != gitOut {
+		t.Errorf("got log --raw printed\n%s\ngit printed\n%s", gotOut, gitOut)
+	}
+}
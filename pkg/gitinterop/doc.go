@@ -0,0 +1,9 @@
+// Package gitinterop has the tests that check got against real git:
+// repositories made by one have to be read by the other, and objects have
+// to get the same names and headers. They build got, need git in PATH,
+// and only run with the integration build tag:
+//
+//	go test -tags=integration ./pkg/gitinterop
+//
+// Without git, they are skipped.
+package gitinterop
@@ -0,0 +1,56 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestGrepPatterns(t *testing.T) {
+	dir := gitInit(t)
+	writeFile(t, dir, "walk.go", "func Walk(repo string) {\n\treturn a+b\n}\n")
+	writeFile(t, dir, "other.txt", "cat\ndog\nx{2}\nxx\n*star\n")
+	git(t, dir, "add", ".")
+
+	exitStatus := func(name string, args ...string) (string, int) {
+		t.Helper()
+		out, err := runTool(t, dir, nil, name, args...)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return out, exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return out, 0
+	}
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+	}{
+		{"basic parenthesis", []string{"grep", "func Walk("}, 0},
+		{"basic plus", []string{"grep", "a+b"}, 0},
+		{"basic braces", []string{"grep", "x{2}"}, 0},
+		{"basic interval", []string{"grep", `x\{2\}`}, 0},
+		{"basic alternation", []string{"grep", `cat\|dog`}, 0},
+		{"basic leading star", []string{"grep", "*star"}, 0},
+		{"extended", []string{"grep", "-E", "x{2}|Walk\\("}, 0},
+		{"extended then basic", []string{"grep", "-E", "-G", "a+b"}, 0},
+		{"no match", []string{"grep", "nothing matches this"}, 1},
+		{"no match counted", []string{"grep", "-c", "nothing matches this"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitOut, gitStatus := exitStatus("git", tt.args...)
+			gotOut, gotStatus := exitStatus(gotBinary, tt.args...)
+			if gitStatus != tt.status || gotStatus != tt.status {
+				t.Errorf("exit status of git = %d, got = %d, want %d", gitStatus, gotStatus, tt.status)
+			}
+			if gotOut != gitOut {
+				t.Errorf("got printed %q, git printed %q", gotOut, gitOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gotBinary is the got that TestMain builds for the tests to run
+var gotBinary string
+
+// sshCommand runs the remote command of an ssh URL on this machine, so
+// clones and fetches can go over ssh://localhost
+var sshCommand string
+
+// home is the home directory of both tools, with an identity and a
+// default branch, so the user's configuration doesn't change the results
+var home string
+
+func TestMain(m *testing.M) {
+	if _, err := exec.LookPath("git"); err != nil {
+		fmt.Println("git is not installed, skipping the interop tests")
+		os.Exit(0)
+	}
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "got-interop-*")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	gotBinary = filepath.Join(dir, "got")
+	build := exec.Command("go", "build", "-o", gotBinary, "github.com/jessegeens/got/cmd/main")
+	if out, err := build.CombinedOutput(); err != nil {
+		fmt.Printf("building got: %v\n%s", err, out)
+		return 1
+	}
+
+	sshCommand = filepath.Join(dir, "ssh")
+	if err := os.WriteFile(sshCommand, []byte("#!/bin/sh\nfor last; do :; done\nexec sh -c \"$last\"\n"), 0o755); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	home = filepath.Join(dir, "home")
+	config := "[user]\n\tname = Interop Tester\n\temail = tester@example.com\n[init]\n\tdefaultBranch = master\n"
+	if err := os.MkdirAll(filepath.Join(home, "git"), 0o755); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	for _, path := range []string{filepath.Join(home, ".gitconfig"), filepath.Join(home, "git", "config")} {
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	return m.Run()
+}
+
+// environ is the environment both tools run in
+func environ() []string {
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GIT_") {
+			env = append(env, kv)
+		}
+	}
+	return append(env,
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+home,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_SSH_COMMAND="+sshCommand,
+		"GIT_AUTHOR_DATE=1700000000 +0100",
+		"GIT_COMMITTER_DATE=1700000000 +0100",
+	)
+}
+
+// runTool runs git or got in dir with stdin, and returns what it wrote to
+// stdout
+func runTool(t *testing.T, dir string, stdin []byte, name string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = environ()
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%s %s: %w\n%s%s", filepath.Base(name), strings.Join(args, " "), err, stdout.String(), stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// git runs git in dir and fails the test if it fails
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runTool(t, dir, nil, "git", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// got runs got in dir and fails the test if it fails. got reports errors
+// on stdout and exits with 1.
+func got(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runTool(t, dir, nil, gotBinary, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// writeFile writes a file of a worktree, with its directories
+func writeFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// gitInit makes a repository with git
+func gitInit(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	return dir
+}
+
+// gotInit makes a repository with got
+func gotInit(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	got(t, dir, "init")
+	return dir
+}
+
+// fsck runs git fsck --strict, which fails on anything git wouldn't write
+func fsck(t *testing.T, dir string) {
+	t.Helper()
+	git(t, dir, "fsck", "--strict", "--no-dangling")
+}
@@ -0,0 +1,155 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// looseObject returns the inflated loose object file of an object, which
+// is its header and contents
+func looseObject(t *testing.T, dir, name string) []byte {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, ".git", "objects", name[:2], name[2:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestObjectHeaders(t *testing.T) {
+	emptyBlob, _ := hex.DecodeString("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	tree := "100644 a\x00" + string(emptyBlob) + "40000 dir\x00" + string(emptyBlob)
+	commit := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author A U Thor <author@example.com> 1700000000 +0100\n" +
+		"committer C O Mitter <committer@example.com> 1700000000 -0530\n\nsubject\n\nbody\n"
+	tag := "object e69de29bb2d1d6434b8b29ae775ad8c2e48c5391\ntype blob\ntag v1\n" +
+		"tagger T A Gger <tagger@example.com> 1700000000 +0000\n\nmessage\n"
+
+	tests := []struct {
+		name      string
+		typ       string
+		contents  string
+		literally bool
+	}{
+		{"empty blob", "blob", "", false},
+		{"text blob", "blob", "hello\nworld\n", false},
+		{"binary blob", "blob", "\x00\x01\x02\xff\xfe no newline", false},
+		{"large blob", "blob", strings.Repeat("0123456789", 100000), false},
+		{"empty tree", "tree", "", false},
+		{"tree", "tree", tree, false},
+		{"commit", "commit", commit, false},
+		{"tag", "tag", tag, false},
+		{"unknown type", "bogus", "anything at all", true},
+		{"malformed tree", "tree", "not a tree", true},
+	}
+	gitDir, gotDir := gitInit(t), gitInit(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []string{"hash-object", "-t", tt.typ, "-w", "--stdin"}
+			if tt.literally {
+				args = append(args, "--literally")
+			}
+			gitName, err := runTool(t, gitDir, []byte(tt.contents), "git", args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotName, err := runTool(t, gotDir, []byte(tt.contents), gotBinary, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotName != gitName {
+				t.Fatalf("got hash-object = %s, git hash-object = %s", gotName, gitName)
+			}
+			name := strings.TrimSpace(gitName)
+			if gitObject, gotObject := looseObject(t, gitDir, name), looseObject(t, gotDir, name); !bytes.Equal(gotObject, gitObject) {
+				t.Errorf("got wrote %q, git wrote %q", gotObject[:min(len(gotObject), 40)], gitObject[:min(len(gitObject), 40)])
+			}
+			if tt.literally {
+				return
+			}
+
+			// Each reads what the other wrote
+			for _, flag := range []string{"-t", "-s"} {
+				if gotOut, gitOut := got(t, gitDir, "cat-file", flag, name), git(t, gotDir, "cat-file", flag, name); gotOut != gitOut {
+					t.Errorf("cat-file %s: got says %q, git says %q", flag, gotOut, gitOut)
+				}
+			}
+			if out := got(t, gitDir, "cat-file", tt.typ, name); out != tt.contents {
+				t.Errorf("got cat-file of git's object = %q, want %q", out, tt.contents)
+			}
+			if out := git(t, gotDir, "cat-file", tt.typ, name); out != tt.contents {
+				t.Errorf("git cat-file of got's object = %q, want %q", out, tt.contents)
+			}
+		})
+	}
+}
+
+func TestHashObjectRefusesMalformed(t *testing.T) {
+	dir := gitInit(t)
+	for _, tt := range []struct{ typ, contents string }{
+		{"tree", "not a tree"},
+		{"tree", "100644 a\x00short"},
+		{"bogus", "anything"},
+	} {
+		if _, err := runTool(t, dir, []byte(tt.contents), "git", "hash-object", "-t", tt.typ, "--stdin"); err == nil {
+			t.Fatalf("git hash-object -t %s of %q succeeded", tt.typ, tt.contents)
+		}
+		if out, err := runTool(t, dir, []byte(tt.contents), gotBinary, "hash-object", "-t", tt.typ, "--stdin"); err == nil {
+			t.Errorf("got hash-object -t %s of %q = %s, git refuses it", tt.typ, tt.contents, out)
+		}
+	}
+}
+
+func TestMktagRefusesMalformed(t *testing.T) {
+	dir := gitInit(t)
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "tagged")
+	commit := strings.TrimSpace(git(t, dir, "rev-parse", "HEAD"))
+	headers := "object " + commit + "\ntype commit\ntag v1\ntagger A U Thor <author@example.com> 1700000000 +0100\n"
+	for _, contents := range []string{
+		"",
+		"object abc\n",
+		"object " + commit + "\n",
+		headers[:len(headers)-1],
+		headers + "message\n",
+		headers + "a message\n",
+		"type commit\n\nmessage\n",
+	} {
+		if _, err := runTool(t, dir, []byte(contents), "git", "mktag"); err == nil {
+			t.Fatalf("git mktag of %q succeeded", contents)
+		}
+		out, err := runTool(t, dir, []byte(contents), gotBinary, "mktag")
+		if err == nil {
+			t.Errorf("got mktag of %q = %s, git refuses it", contents, out)
+		} else if strings.Contains(err.Error(), "panic") {
+			t.Errorf("got mktag of %q panicked: %v", contents, err)
+		}
+	}
+
+	// A blank line before the message, or no message at all, is fine
+	for _, tag := range []string{headers + "\nmessage\n", headers} {
+		want, err := runTool(t, dir, []byte(tag), "git", "mktag")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err := runTool(t, dir, []byte(tag), gotBinary, "mktag"); err != nil || out != want {
+			t.Errorf("got mktag of %q = %q, %v, want %q", tag, out, err, want)
+		}
+	}
+}
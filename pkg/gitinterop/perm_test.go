@@ -0,0 +1,63 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withUmask runs git or got in dir under a umask
+func withUmask(t *testing.T, dir, umask, name string, args ...string) string {
+	t.Helper()
+	out, err := runTool(t, dir, nil, "sh", append([]string{"-c", "umask " + umask + " && exec \"$@\"", "sh", name}, args...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// permOf returns the permission bits of a file in the gitdir
+func permOf(t *testing.T, dir, name string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(dir, ".git", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestPermissionsFollowUmask(t *testing.T) {
+	for _, tt := range []struct {
+		umask, shared string
+	}{
+		{"077", ""},
+		{"022", ""},
+		{"077", "group"},
+		{"022", "0640"},
+	} {
+		t.Run(tt.umask+" "+tt.shared, func(t *testing.T) {
+			perms := map[string][]os.FileMode{}
+			for _, tool := range []string{"git", gotBinary} {
+				dir := t.TempDir()
+				withUmask(t, dir, tt.umask, tool, "init")
+				if tt.shared != "" {
+					git(t, dir, "config", "core.sharedRepository", tt.shared)
+				}
+				writeFile(t, dir, "a.txt", "contents\n")
+				withUmask(t, dir, tt.umask, tool, "add", "a.txt")
+				withUmask(t, dir, tt.umask, tool, "commit", "-m", "first")
+				blob := strings.TrimSpace(git(t, dir, "rev-parse", "HEAD:a.txt"))
+				perms[tool] = []os.FileMode{
+					permOf(t, dir, filepath.Join("objects", blob[:2], blob[2:])),
+					permOf(t, dir, filepath.Join("refs", "heads", "master")),
+				}
+			}
+			if got, want := perms[gotBinary], perms["git"]; got[0] != want[0] || got[1] != want[1] {
+				t.Errorf("got's object and ref have modes %o, git's %o", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+//go:build integration
+
+package gitinterop
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitReadsGot(t *testing.T) {
+	dir := gotInit(t)
+	writeFile(t, dir, "a.txt", "first\n")
+	writeFile(t, dir, "dir/b.txt", "in a directory\n")
+	got(t, dir, "add", "a.txt", "dir/b.txt")
+	got(t, dir, "commit", "-m", "first")
+	writeFile(t, dir, "a.txt", "first\nsecond\n")
+	got(t, dir, "add", "a.txt")
+	got(t, dir, "commit", "-m", "second")
+
+	check := func(t *testing.T) {
+		t.Helper()
+		fsck(t, dir)
+		if log := git(t, dir, "log", "--format=%s"); log != "second\nfirst\n" {
+			t.Errorf("git log = %q, want both commits", log)
+		}
+		if status := git(t, dir, "status", "--porcelain"); status != "" {
+			t.Errorf("git status of got's index = %q, want it clean", status)
+		}
+		if out := git(t, dir, "cat-file", "-p", "HEAD:dir/b.txt"); out != "in a directory\n" {
+			t.Errorf("git cat-file of a file in got's commit = %q", out)
+		}
+	}
+	t.Run("loose", check)
+
+	got(t, dir, "repack", "-a", "-q")
+	packs, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("got repack made packs %v, %v, want one", packs, err)
+	}
+	git(t, dir, "verify-pack", packs[0])
+	t.Run("packed", check)
+}
+
+func TestGotReadsGit(t *testing.T) {
+	dir := gitInit(t)
+	lines := []string{}
+	for i := 1; i <= 3; i++ {
+		// Files that grow a little, so git gc makes deltas of them
+		for j := 0; j < 200; j++ {
+			lines = append(lines, fmt.Sprintf("line %d of commit %d", j, i))
+		}
+		writeFile(t, dir, "a.txt", strings.Join(lines, "\n"))
+		writeFile(t, dir, fmt.Sprintf("dir/%d.txt", i), fmt.Sprintf("file %d\n", i))
+		git(t, dir, "add", "-A")
+		git(t, dir, "commit", "-q", "-m", fmt.Sprintf("commit %d", i))
+	}
+	git(t, dir, "tag", "-a", "v1", "-m", "a tag")
+
+	check := func(t *testing.T) {
+		t.Helper()
+		if gotOut, gitOut := got(t, dir, "rev-list", "HEAD"), git(t, dir, "rev-list", "HEAD"); gotOut != gitOut {
+			t.Errorf("got rev-list = %q, git rev-list = %q", gotOut, gitOut)
+		}
+		if gotOut, gitOut := got(t, dir, "ls-tree", "-r", "HEAD"), git(t, dir, "ls-tree", "-r", "HEAD"); gotOut != gitOut {
+			t.Errorf("got ls-tree = %q, git ls-tree = %q", gotOut, gitOut)
+		}
+		if gotOut, gitOut := got(t, dir, "show-ref"), git(t, dir, "show-ref"); gotOut != gitOut {
+			t.Errorf("got show-ref = %q, git show-ref = %q", gotOut, gitOut)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(git(t, dir, "rev-list", "--objects", "--all")), "\n") {
+			name, _, _ := strings.Cut(line, " ")
+			for _, flag := range []string{"-t", "-s"} {
+				if gotOut, gitOut := got(t, dir, "cat-file", flag, name), git(t, dir, "cat-file", flag, name); gotOut != gitOut {
+					t.Errorf("cat-file %s %s: got says %q, git says %q", flag, name, gotOut, gitOut)
+				}
+			}
+			typ := strings.TrimSpace(git(t, dir, "cat-file", "-t", name))
+			if gotOut, gitOut := got(t, dir, "cat-file", typ, name), git(t, dir, "cat-file", typ, name); gotOut != gitOut {
+				t.Errorf("got cat-file %s %s doesn't match git", typ, name)
+			}
+		}
+	}
+	t.Run("loose", check)
+
+	git(t, dir, "gc", "-q")
+	t.Run("packed", check)
+	packs, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("git gc made packs %v, %v, want one", packs, err)
+	}
+	if gotOut, gitOut := got(t, dir, "verify-pack", "-v", packs[0]), git(t, dir, "verify-pack", "-v", packs[0]); gotOut != gitOut {
+		t.Errorf("got verify-pack -v = %q, git verify-pack -v = %q", gotOut, gitOut)
+	}
+
+	// The index got builds for git's pack is the one git wrote
+	idx := filepath.Join(t.TempDir(), "pack.idx")
+	got(t, dir, "index-pack", "-o", idx, strings.TrimSuffix(packs[0], ".idx")+".pack")
+	gotIdx, err := os.ReadFile(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitIdx, err := os.ReadFile(packs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotIdx, gitIdx) {
+		t.Errorf("got index-pack wrote an index that differs from git's")
+	}
+}
@@ -0,0 +1,102 @@
+package grep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TranslateBasic translates a POSIX basic regular expression, the default
+// pattern type of git grep, into the syntax of package regexp. Like in
+// GNU grep, \+, \? and \| are the operators of extended expressions, and
+// (, ), {, }, |, + and ? are plain characters. Back-references can't be
+// translated, so they are an error.
+func TranslateBasic(pattern string) (string, error) {
+	var b strings.Builder
+	// start is whether the next character is at the start of the
+	// expression or of a group, where * is a plain character
+	start := true
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		atStart := start
+		start = false
+		switch c {
+		case '\\':
+			if i+1 == len(pattern) {
+				return "", fmt.Errorf("trailing backslash in %q", pattern)
+			}
+			i++
+			switch next := pattern[i]; {
+			case strings.IndexByte("(){}|+?", next) >= 0:
+				b.WriteByte(next)
+				start = next == '(' || next == '|'
+			case next >= '1' && next <= '9':
+				return "", fmt.Errorf("back-references are not supported: \\%c", next)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(next)
+			}
+		case '(', ')', '{', '}', '|', '+', '?':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '*':
+			if atStart {
+				b.WriteString(`\*`)
+			} else {
+				b.WriteByte('*')
+			}
+		case '^':
+			// Only an anchor at the start, like * it stays at the start
+			if atStart {
+				b.WriteByte('^')
+				start = true
+			} else {
+				b.WriteString(`\^`)
+			}
+		case '$':
+			if end := pattern[i+1:]; end == "" || strings.HasPrefix(end, `\)`) || strings.HasPrefix(end, `\|`) {
+				b.WriteByte('$')
+			} else {
+				b.WriteString(`\$`)
+			}
+		case '[':
+			end, err := bracketEnd(pattern, i)
+			if err != nil {
+				return "", err
+			}
+			// Backslashes are plain characters in brackets
+			b.WriteString(strings.ReplaceAll(pattern[i:end], `\`, `\\`))
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// bracketEnd returns the index after the bracket expression that starts
+// at i. A ] right after the [ or [^ is part of the expression, and so are
+// the brackets of classes like [:alpha:].
+func bracketEnd(pattern string, i int) (int, error) {
+	j := i + 1
+	if j < len(pattern) && pattern[j] == '^' {
+		j++
+	}
+	if j < len(pattern) && pattern[j] == ']' {
+		j++
+	}
+	for j < len(pattern) {
+		switch {
+		case pattern[j] == ']':
+			return j + 1, nil
+		case pattern[j] == '[' && j+1 < len(pattern) && strings.IndexByte(":.=", pattern[j+1]) >= 0:
+			close := strings.Index(pattern[j+2:], string(pattern[j+1])+"]")
+			if close < 0 {
+				return 0, fmt.Errorf("unterminated character class in %q", pattern)
+			}
+			j += 2 + close + 2
+		default:
+			j++
+		}
+	}
+	return 0, fmt.Errorf("unmatched [ in %q", pattern)
+}
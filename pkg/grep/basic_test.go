@@ -0,0 +1,60 @@
+package grep
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTranslateBasic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{pattern: "func Walk(", matches: []string{"func Walk(repo"}, misses: []string{"func Walk"}},
+		{pattern: `a\(b\)*c`, matches: []string{"ac", "abbc"}, misses: []string{"a(b)c"}},
+		{pattern: "a+b?", matches: []string{"a+b?"}, misses: []string{"aab"}},
+		{pattern: `a\+b\?`, matches: []string{"aa", "ab"}, misses: []string{"b"}},
+		{pattern: `x\{2\}`, matches: []string{"xx"}, misses: []string{"x{2}", "x"}},
+		{pattern: "x{2}", matches: []string{"x{2}"}, misses: []string{"xx"}},
+		{pattern: `cat\|dog`, matches: []string{"cat", "dog"}, misses: []string{"cow"}},
+		{pattern: "a|b", matches: []string{"a|b"}, misses: []string{"a"}},
+		{pattern: "*star", matches: []string{"*star"}, misses: []string{"star"}},
+		{pattern: "^*a", matches: []string{"*a"}, misses: []string{"a"}},
+		{pattern: "^a", matches: []string{"ab"}, misses: []string{"ba"}},
+		{pattern: "a^b$", matches: []string{"a^b"}, misses: []string{"a^bc"}},
+		{pattern: "a$b", matches: []string{"a$b"}, misses: []string{"a"}},
+		{pattern: `[]a]x`, matches: []string{"]x", "ax"}, misses: []string{"bx"}},
+		{pattern: `[[:digit:]]\{3\}`, matches: []string{"123"}, misses: []string{"12a"}},
+		{pattern: `[\]`, matches: []string{`\`}, misses: []string{"]"}},
+		{pattern: `a\.b`, matches: []string{"a.b"}, misses: []string{"axb"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			translated, err := TranslateBasic(tt.pattern)
+			if err != nil {
+				t.Fatalf("TranslateBasic() error = %v", err)
+			}
+			re, err := regexp.Compile(translated)
+			if err != nil {
+				t.Fatalf("TranslateBasic() = %q, which doesn't compile: %v", translated, err)
+			}
+			for _, s := range tt.matches {
+				if !re.MatchString(s) {
+					t.Errorf("%q (from %q) doesn't match %q", translated, tt.pattern, s)
+				}
+			}
+			for _, s := range tt.misses {
+				if re.MatchString(s) {
+					t.Errorf("%q (from %q) matches %q", translated, tt.pattern, s)
+				}
+			}
+		})
+	}
+
+	for _, pattern := range []string{`\(a\)\1`, `a\`, "[abc", "[[:alpha:]"} {
+		if translated, err := TranslateBasic(pattern); err == nil {
+			t.Errorf("TranslateBasic(%q) = %q, want an error", pattern, translated)
+		}
+	}
+}
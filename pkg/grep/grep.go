@@ -0,0 +1,140 @@
+// Package grep searches files for lines that match a regular expression,
+// like git grep. Files are searched by a pool of goroutines, but results
+// are always reported in the order of the files, so that the output
+// doesn't depend on which goroutine was fastest.
+package grep
+
+import (
+	"bytes"
+	"regexp"
+	"runtime"
+	"sync"
+
+	"github.com/jessegeens/got/pkg/diff"
+)
+
+// File is a file to search, e.g. a blob in the index or a file in the
+// worktree
+type File struct {
+	Path string
+	// Load returns the contents of the file. It is called by the
+	// goroutine that searches it.
+	Load func() ([]byte, error)
+}
+
+// Options change how files are searched
+type Options struct {
+	Pattern *regexp.Regexp
+	// Invert selects the lines that don't match
+	Invert bool
+	// FirstOnly stops searching a file at its first match, for when only
+	// the files that match are needed
+	FirstOnly bool
+	// Threads is the number of files searched at the same time. Zero or
+	// less means one per CPU.
+	Threads int
+}
+
+// Match is a line that matched
+type Match struct {
+	// Line counts from 1
+	Line int
+	Text []byte
+}
+
+// Result are the matches in a file
+type Result struct {
+	Path    string
+	Matches []Match
+	// Binary files are searched, but their lines should not be shown
+	Binary bool
+}
+
+// Search searches files and calls fn for every file that matches, in the
+// order of files. Searching stops at the first error, of fn or of a Load.
+func Search(files []File, opts Options, fn func(Result) error) error {
+	threads := opts.Threads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+
+	type outcome struct {
+		result Result
+		err    error
+	}
+	// Every file has a buffered channel for its outcome, which is
+	// read in order
+	outcomes := make([]chan outcome, len(files))
+	for i := range outcomes {
+		outcomes[i] = make(chan outcome, 1)
+	}
+	next := make(chan int)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range min(threads, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				data, err := files[i].Load()
+				if err != nil {
+					outcomes[i] <- outcome{err: err}
+					continue
+				}
+				result := searchData(data, opts)
+				result.Path = files[i].Path
+				outcomes[i] <- outcome{result: result}
+			}
+		}()
+	}
+	go func() {
+		defer close(next)
+		for i := range files {
+			select {
+			case next <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	// Workers finish the file they are searching after a stop
+	defer wg.Wait()
+	defer close(stop)
+
+	for i := range files {
+		o := <-outcomes[i]
+		if o.err != nil {
+			return o.err
+		}
+		if len(o.result.Matches) == 0 {
+			continue
+		}
+		if err := fn(o.result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchData returns the matching lines of data
+func searchData(data []byte, opts Options) Result {
+	result := Result{Binary: diff.IsBinary(data)}
+	line := 0
+	for len(data) > 0 {
+		line++
+		text := data
+		if end := bytes.IndexByte(data, '\n'); end >= 0 {
+			text, data = data[:end], data[end+1:]
+		} else {
+			data = nil
+		}
+		if opts.Pattern.Match(text) == opts.Invert {
+			continue
+		}
+		result.Matches = append(result.Matches, Match{Line: line, Text: text})
+		if opts.FirstOnly {
+			break
+		}
+	}
+	return result
+}
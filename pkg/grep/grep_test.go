@@ -0,0 +1,103 @@
+package grep
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// files returns n files, the later ones loading faster, so that searching
+// them in parallel finishes them out of order
+func files(n int) []File {
+	fs := []File{}
+	for i := range n {
+		fs = append(fs, File{
+			Path: fmt.Sprintf("file%02d", i),
+			Load: func() ([]byte, error) {
+				time.Sleep(time.Duration(n-i) * time.Millisecond)
+				return []byte(fmt.Sprintf("first line\nmatch %d\nother line\nmatch again\n", i)), nil
+			},
+		})
+	}
+	return fs
+}
+
+func TestSearchOrder(t *testing.T) {
+	for _, threads := range []int{1, 4, 0} {
+		t.Run(fmt.Sprintf("threads=%d", threads), func(t *testing.T) {
+			paths := []string{}
+			err := Search(files(20), Options{Pattern: regexp.MustCompile("^match"), Threads: threads}, func(r Result) error {
+				paths = append(paths, r.Path)
+				if len(r.Matches) != 2 || r.Matches[0].Line != 2 || r.Matches[1].Line != 4 {
+					t.Errorf("%s: matches = %+v, want lines 2 and 4", r.Path, r.Matches)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Search() error: %v", err)
+			}
+			want := []string{}
+			for i := range 20 {
+				want = append(want, fmt.Sprintf("file%02d", i))
+			}
+			if !reflect.DeepEqual(paths, want) {
+				t.Errorf("results in order %v, want %v", paths, want)
+			}
+		})
+	}
+}
+
+func TestSearchData(t *testing.T) {
+	data := []byte("alpha\nbeta\ngamma\nalphabet")
+	tests := []struct {
+		name string
+		opts Options
+		want []int
+	}{
+		{name: "match", opts: Options{Pattern: regexp.MustCompile("alpha")}, want: []int{1, 4}},
+		{name: "invert", opts: Options{Pattern: regexp.MustCompile("alpha"), Invert: true}, want: []int{2, 3}},
+		{name: "first only", opts: Options{Pattern: regexp.MustCompile("a$"), FirstOnly: true}, want: []int{1}},
+		{name: "no match", opts: Options{Pattern: regexp.MustCompile("delta")}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []int
+			for _, m := range searchData(data, tt.opts).Matches {
+				lines = append(lines, m.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("matching lines = %v, want %v", lines, tt.want)
+			}
+		})
+	}
+
+	if !searchData([]byte("a\x00b"), Options{Pattern: regexp.MustCompile("a")}).Binary {
+		t.Errorf("data with a NUL byte should be binary")
+	}
+}
+
+func TestSearchStops(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := Search(files(20), Options{Pattern: regexp.MustCompile("match"), Threads: 4}, func(r Result) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Search() = %v after %d calls, want the error of the first call", err, calls)
+	}
+
+	failing := files(3)
+	failing[1].Load = func() ([]byte, error) { return nil, errors.New("unreadable") }
+	calls = 0
+	err = Search(failing, Options{Pattern: regexp.MustCompile("match")}, func(r Result) error {
+		calls++
+		return nil
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Search() = %v after %d calls, want an error after the first file", err, calls)
+	}
+}
@@ -1,181 +1,361 @@
+// Parsing and evaluation of gitignore rules
 package ignore
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 
-	"github.com/danwakefield/fnmatch"
+	"github.com/jessegeens/got/pkg/config"
 	"github.com/jessegeens/got/pkg/fs"
-	"github.com/jessegeens/got/pkg/index"
-	"github.com/jessegeens/got/pkg/objects"
 	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/wildmatch"
 )
 
-type Pattern string
+type Rule struct {
+	// Directory of the .gitignore file, relative to the worktree.
+	// Empty for rules that apply to the whole worktree.
+	Dir string
+	// Pattern as written in the file, without "!" and a trailing "/"
+	Pattern string
+	// Negated rules, written as "!pattern", re-include matching paths
+	Negate bool
+	// Rules written as "pattern/" only match directories
+	DirOnly bool
+	// File the rule was read from and its line number, for check-ignore -v
+	Source string
+	Line   int
+
+	// Patterns without a slash match the name of a file in any directory
+	basename bool
+	compiled *wildmatch.Pattern
+}
 
+// Ignore holds the ignore rules of a worktree. In order of decreasing
+// precedence, they come from:
+//
+//   - the .gitignore files, where deeper directories take precedence
+//   - .git/info/exclude
+//   - core.excludesFile, or $XDG_CONFIG_HOME/git/ignore if it isn't set,
+//     which defaults to ~/.config/git/ignore
+//
+// Within a file, the last matching rule wins.
 type Ignore struct {
-	// Rules from global ignore files (usually in ~/.config/git/ignore)
-	// and the repository-specific .git/info/exclude
-	Absolute []*Rule
-	// Scoped rules live in the index, in gitignore files
-	// They are scoped to the directory they are located in
-	Scoped map[string][]*Rule
+	// Rules from the global excludes file and .git/info/exclude,
+	// in order of increasing precedence
+	global []*Rule
+
+	// The worktree the .gitignore files are read from, if any
+	worktree string
+
+	// Protects scoped, which is filled as directories are visited
+	mu sync.Mutex
+	// Rules of the .gitignore files, by directory
+	scoped map[string][]*Rule
 }
-type Rule struct {
-	// True if the file should be ignored,
-	// false if the file should not be ignored
-	Exclude bool
-	Pattern Pattern
+
+func New() *Ignore {
+	return &Ignore{
+		global: []*Rule{},
+		scoped: map[string][]*Rule{},
+	}
 }
 
+// Read collects the ignore rules of a repository. The .gitignore files
+// are read from the worktree when a path in their directory is first
+// checked, so new rules apply before the files are added, and the
+// .gitignore files in ignored directories are never read.
 func Read(repo *repository.Repository) (*Ignore, error) {
-	absolute := []*Rule{}
-	scoped := map[string][]*Rule{}
+	ign := New()
+	ign.worktree = repo.WorkTree()
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if global := excludesFile(cfg); global != "" && fs.IsFile(global) {
+		if err := ign.addFile(global, global); err != nil {
+			return nil, err
+		}
+	}
 
 	// Read rules defined in .git/info/exclude
 	excludeFile := repo.RepositoryPath("info/exclude")
-	if fs.Exists(excludeFile) {
-		rules, err := parseFile(excludeFile)
-		if err != nil {
+	if fs.IsFile(excludeFile) {
+		source := excludeFile
+		if rel, err := filepath.Rel(repo.WorkTree(), excludeFile); err == nil {
+			source = filepath.ToSlash(rel)
+		}
+		if err := ign.addFile(excludeFile, source); err != nil {
 			return nil, err
 		}
-		absolute = append(absolute, rules...)
 	}
 
-	// Read global configuration
-	var configHome string
-	if val, set := os.LookupEnv("XDG_CONFIG_HOME"); set {
-		configHome = val
-	} else {
-		home, err := fs.HomeDir()
-		if err == nil {
-			configHome = path.Join(home, ".config/git/ignore")
+	return ign, nil
+}
+
+// excludesFile returns the global excludes file: core.excludesFile, or
+// the ignore file in the XDG config directory
+func excludesFile(cfg config.GitConfig) string {
+	if file, ok := cfg.Get("core", "excludesFile"); ok {
+		if strings.HasPrefix(file, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				file = path.Join(home, file[2:])
+			}
 		}
+		return file
 	}
-	if configHome != "" && fs.Exists(configHome) {
-		rules, err := parseFile(configHome)
-		if err != nil {
-			return nil, err
-		}
+	if xdg, ok := os.LookupEnv("XDG_CONFIG_HOME"); ok && xdg != "" {
+		return path.Join(xdg, "git", "ignore")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return path.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
 
-		absolute = append(absolute, rules...)
+// addFile adds the rules of a file that applies to the whole worktree,
+// shown as source by check-ignore -v
+func (i *Ignore) addFile(file, source string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file, err)
 	}
+	rules, err := parse(data, "", source)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	i.global = append(i.global, rules...)
+	return nil
+}
 
-	// Read .gitignore files in index
-	idx, err := index.Read(repo)
+// Parse adds the rules in data, as if they were read from the .gitignore
+// file in dir. The rules take precedence over the ones added before.
+func (i *Ignore) Parse(data []byte, dir string) error {
+	rules, err := parse(data, dir, path.Join(dir, ".gitignore"))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.scoped[dir] = append(i.scoped[dir], rules...)
+	return nil
+}
 
-	for _, e := range idx.Entries {
-		if e.Name == ".gitignore" || strings.HasSuffix(e.Name, "/.gitignore") {
-			directory := path.Dir(e.Name)
-			contents, err := objects.ReadObject(repo, e.SHA)
-			if err != nil {
-				return nil, err
-			}
-			lines, err := contents.(*objects.Blob).Serialize()
-			if err != nil {
-				return nil, err
-			}
-			rules, err := parse(lines)
-			if err != nil {
-				return nil, err
-			}
-			scoped[directory] = rules
-		}
+// rules returns the rules of the .gitignore file in dir, reading it from
+// the worktree the first time. Unreadable files are skipped, like git does.
+func (i *Ignore) rules(dir string) []*Rule {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if rules, ok := i.scoped[dir]; ok || i.worktree == "" {
+		return rules
 	}
-	return &Ignore{
-		Absolute: absolute,
-		Scoped:   scoped,
-	}, nil
-}
-
-// Returns true if the given path is to be ignored according to the gitignore rules
-func (i *Ignore) ShouldBeIgnored(path string) bool {
-	// We start by matching agains the scoped rules, working our way up from
-	// the deepest parent to the farthest
-	// If nothing matches, we check the absolute rules
-	parents := fs.Parents(path)
-	for _, parent := range parents {
-		ruleset := i.Scoped[parent]
-		for _, rule := range ruleset {
-			if rule.ShouldBeIncluded(path) {
-				return false
-			}
-			if rule.Matches(path) {
-				return true
-			}
+
+	rules := []*Rule{}
+	file := path.Join(dir, ".gitignore")
+	data, err := os.ReadFile(filepath.Join(i.worktree, file))
+	if err == nil {
+		rules, err = parse(data, dir, file)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "warning: ignoring %s: %s\n", file, err)
+		rules = []*Rule{}
+	}
+	i.scoped[dir] = rules
+	return rules
+}
+
+// ShouldBeIgnored returns true if the given path, relative to the worktree,
+// is to be ignored according to the gitignore rules. A path ending in a
+// slash is a directory.
+func (i *Ignore) ShouldBeIgnored(p string) bool {
+	return i.Explain(p).Ignored
+}
+
+// Match is a rule that matched a path
+type Match struct {
+	// The path that was checked, or one of its parent directories
+	Path string
+	Rule *Rule
+}
+
+// Explanation tells why a path is or isn't ignored
+type Explanation struct {
+	// The path that was checked, cleaned
+	Path    string
+	Ignored bool
+	// The rules that decided for the parent directories and the path
+	// itself, in the order they were checked. The last one decides.
+	Chain []Match
+}
+
+// Deciding returns the rule that decided whether the path is ignored:
+// the rule that excluded it or one of its parents, or the negated rule
+// that re-included it. It is nil if no rule matched the path.
+func (e *Explanation) Deciding() *Rule {
+	if len(e.Chain) == 0 {
+		return nil
+	}
+	last := e.Chain[len(e.Chain)-1]
+	if !e.Ignored && last.Path != e.Path {
+		return nil
+	}
+	return last.Rule
+}
+
+// Explain checks a path like ShouldBeIgnored, and returns the rules
+// that matched along the way
+func (i *Ignore) Explain(p string) *Explanation {
+	e := &Explanation{}
+	isDir := strings.HasSuffix(p, "/")
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	e.Path = p
+	if p == "" {
+		return e
+	}
+	if !isDir && i.worktree != "" {
+		isDir = fs.IsDirectory(filepath.Join(i.worktree, p))
+	}
+
+	// Files in an ignored directory are ignored, and can't be re-included
+	parts := strings.Split(p, "/")
+	for n := 1; n <= len(parts); n++ {
+		checked := strings.Join(parts[:n], "/")
+		rule := i.match(checked, isDir || n < len(parts))
+		if rule == nil {
+			continue
+		}
+		e.Chain = append(e.Chain, Match{Path: checked, Rule: rule})
+		if !rule.Negate {
+			e.Ignored = true
+			break
 		}
+		e.Ignored = false
 	}
-	for _, rule := range i.Absolute {
-		if rule.Matches(path) {
-			if rule.ShouldBeIncluded(path) {
-				return false
-			}
-			if rule.Matches(path) {
-				return true
-			}
+	return e
+}
+
+// match returns the rule that decides whether p is ignored, or nil if no
+// rule matches. The .gitignore file in the deepest directory takes
+// precedence, then the global rules, and within a file the last rule wins.
+func (i *Ignore) match(p string, isDir bool) *Rule {
+	dir := path.Dir(p)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+		if rule := lastMatch(i.rules(dir), p, isDir); rule != nil {
+			return rule
 		}
+		if dir == "" {
+			break
+		}
+		dir = path.Dir(dir)
 	}
+	return lastMatch(i.global, p, isDir)
+}
 
-	return false
+func lastMatch(rules []*Rule, p string, isDir bool) *Rule {
+	for j := len(rules) - 1; j >= 0; j-- {
+		if rules[j].Matches(p, isDir) {
+			return rules[j]
+		}
+	}
+	return nil
 }
 
-func parseFile(path string) ([]*Rule, error) {
-	fileContents, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+// String returns the rule as it is written in a file
+func (r *Rule) String() string {
+	s := r.Pattern
+	if r.Negate {
+		s = "!" + s
 	}
-	rules, err := parse(fileContents)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	if r.DirOnly {
+		s += "/"
 	}
+	return s
+}
 
-	return rules, nil
+// Matches reports whether the rule's pattern matches p, which is relative
+// to the worktree, regardless of whether the rule is negated
+func (r *Rule) Matches(p string, isDir bool) bool {
+	if r.DirOnly && !isDir {
+		return false
+	}
+	if r.Dir != "" {
+		if !strings.HasPrefix(p, r.Dir+"/") {
+			return false
+		}
+		p = strings.TrimPrefix(p, r.Dir+"/")
+	}
+	if r.basename {
+		return r.compiled.Match(path.Base(p))
+	}
+	return r.compiled.Match(p)
 }
 
-func parse(raw []byte) ([]*Rule, error) {
-	lines := strings.Split(string(raw), "\n")
+func parse(data []byte, dir, source string) ([]*Rule, error) {
 	rules := []*Rule{}
-	for _, line := range lines {
-		rule, err := parseLine(line)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		rule, err := parseLine(scanner.Text(), dir)
 		if err != nil {
 			return nil, err
 		}
 		if rule != nil {
+			rule.Source, rule.Line = source, line
 			rules = append(rules, rule)
 		}
 	}
-	return rules, nil
+	return rules, scanner.Err()
 }
 
-func parseLine(line string) (*Rule, error) {
-	line = strings.TrimSpace(line)
-
+func parseLine(line, dir string) (*Rule, error) {
+	line = trimTrailingSpaces(strings.TrimSuffix(line, "\r"))
 	if line == "" || line[0] == '#' {
 		return nil, nil
 	}
 
+	rule := &Rule{Dir: dir}
 	if line[0] == '!' {
-		return &Rule{
-			Exclude: false,
-			Pattern: Pattern(line[1:]),
-		}, nil
+		rule.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		// A backslash escapes a leading "!" or "#"
+		line = line[1:]
 	}
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return nil, nil
+	}
+	rule.Pattern = line
 
-	return &Rule{
-		Exclude: true,
-		Pattern: Pattern(line[1:]),
-	}, nil
-}
-
-func (r *Rule) Matches(path string) bool {
-	return fnmatch.Match(string(r.Pattern), path, 0)
+	// Patterns without a slash match in any directory, the
+	// others are relative to the directory of the file
+	rule.basename = !strings.Contains(line, "/")
+	compiled, err := wildmatch.Compile(strings.TrimPrefix(line, "/"))
+	if err != nil {
+		return nil, err
+	}
+	rule.compiled = compiled
+	return rule, nil
 }
 
-func (r *Rule) ShouldBeIncluded(path string) bool {
-	return r.Matches(path) && r.Exclude
+// trimTrailingSpaces removes trailing spaces, unless they are escaped
+// with a backslash
+func trimTrailingSpaces(line string) string {
+	trimmed := strings.TrimRight(line, " ")
+	if len(trimmed) < len(line) && strings.HasSuffix(trimmed, `\`) {
+		return trimmed[:len(trimmed)-1] + " "
+	}
+	return trimmed
 }
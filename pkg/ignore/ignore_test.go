@@ -0,0 +1,177 @@
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func TestShouldBeIgnored(t *testing.T) {
+	ign := New()
+	root := `# comment
+*.log
+!keep.log
+/build
+tmp/
+docs/*.html
+\#notes
+` + "trailing\\ \n"
+	if err := ign.Parse([]byte(root), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ign.Parse([]byte("*.txt\n!debug.log\n"), "sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a.go", false},
+		{"a.log", true},
+		{"dir/a.log", true},
+		{"keep.log", false},
+		{"dir/keep.log", false},
+		{"build", true},
+		{"build/out.o", true},
+		{"dir/build", false},
+		{"tmp", false},
+		{"tmp/", true},
+		{"tmp/x.go", true},
+		{"dir/tmp/x.go", true},
+		{"docs/index.html", true},
+		{"docs/api/index.html", false},
+		{"#notes", true},
+		{"trailing ", true},
+		{"a.txt", false},
+		{"sub/a.txt", true},
+		{"sub/dir/a.txt", true},
+		{"sub/debug.log", false},
+		{"sub/other.log", true},
+		{"./a.log", true},
+	}
+
+	for _, tt := range tests {
+		if got := ign.ShouldBeIgnored(tt.path); got != tt.want {
+			t.Errorf("ShouldBeIgnored(%q) = %t, want %t", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestReadWorktree(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo, err := repository.Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	// None of these files are in the index
+	files := map[string]string{
+		".gitignore":              "*.o\nvendor/\n",
+		"lib/.gitignore":          "!keep.o\ngenerated/\n",
+		"lib/generated/x.go":      "",
+		"vendor/.gitignore":       "!*.o\n",
+		"vendor/dep.o":            "",
+		".git/info/exclude":       "secret\n",
+		"lib/sub/.gitignore.orig": "",
+	}
+	for name, contents := range files {
+		fullPath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// core.excludesFile has the lowest precedence
+	global := filepath.Join(t.TempDir(), "global-ignore")
+	if err := os.WriteFile(global, []byte("*.bak\n!secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := os.OpenFile(filepath.Join(dir, ".git", "config"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(cfg, "[core]\n\texcludesFile = %s\n", global)
+	cfg.Close()
+
+	ign, err := Read(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"main.o", true},
+		{"lib/a.o", true},
+		{"lib/keep.o", false},
+		{"lib/generated", true},
+		{"lib/generated/x.go", true},
+		{"vendor", true},
+		// The .gitignore in an ignored directory can't re-include files
+		{"vendor/dep.o", true},
+		{"secret", true},
+		{"lib/secret", true},
+		{"lib/main.go", false},
+		{"a.bak", true},
+		{"lib/a.bak", true},
+	}
+
+	for _, tt := range tests {
+		if got := ign.ShouldBeIgnored(tt.path); got != tt.want {
+			t.Errorf("ShouldBeIgnored(%q) = %t, want %t", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	ign := New()
+	if err := ign.Parse([]byte("build/\n*.log\n!keep.log\n!build/keep.log\n"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ign.Parse([]byte("!*.log\n"), "sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path        string
+		wantIgnored bool
+		// Source:line:rule of the deciding rule, or empty if none
+		wantRule string
+		wantLen  int
+	}{
+		{path: "main.go"},
+		{path: "a.log", wantIgnored: true, wantRule: ".gitignore:2:*.log", wantLen: 1},
+		{path: "keep.log", wantRule: ".gitignore:3:!keep.log", wantLen: 1},
+		{path: "sub/a.log", wantRule: "sub/.gitignore:1:!*.log", wantLen: 1},
+		{path: "build/a.go", wantIgnored: true, wantRule: ".gitignore:1:build/", wantLen: 1},
+		{path: "build/keep.log", wantIgnored: true, wantRule: ".gitignore:1:build/", wantLen: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			e := ign.Explain(tt.path)
+			if e.Ignored != tt.wantIgnored {
+				t.Errorf("Explain(%q).Ignored = %t, want %t", tt.path, e.Ignored, tt.wantIgnored)
+			}
+			got := ""
+			if rule := e.Deciding(); rule != nil {
+				got = fmt.Sprintf("%s:%d:%s", rule.Source, rule.Line, rule)
+			}
+			if got != tt.wantRule {
+				t.Errorf("Explain(%q).Deciding() = %q, want %q", tt.path, got, tt.wantRule)
+			}
+			if len(e.Chain) != tt.wantLen {
+				t.Errorf("Explain(%q) has %d matches, want %d", tt.path, len(e.Chain), tt.wantLen)
+			}
+		})
+	}
+}
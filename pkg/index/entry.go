@@ -10,11 +10,22 @@ import (
 type ModeType uint16
 
 const (
+	// ModeTypeTree is only used for the directories of a sparse index
+	ModeTypeTree    = ModeType(uint16(4))  // 0000 0100
 	ModeTypeRegular = ModeType(uint16(8))  // 0000 1000
 	ModeTypeSymlink = ModeType(uint16(10)) // 0000 1010
 	ModeTypeGitlink = ModeType(uint16(14)) // 0000 1110
 )
 
+// Extended flags of version 3 entries
+const (
+	// FlagSkipWorktree marks files that sparse-checkout leaves out of
+	// the worktree
+	FlagSkipWorktree = uint16(0x4000)
+	// FlagIntentToAdd marks files added with git add -N
+	FlagIntentToAdd = uint16(0x2000)
+)
+
 type Entry struct {
 	// Creation Time
 	CTime time.Time
@@ -30,18 +41,45 @@ type Entry struct {
 	Size            uint32
 	SHA             *hashing.SHA
 	FlagAssumeValid bool
-	FlagStage       uint16
+	// FlagStage is 0 for merged entries. Unmerged entries have stage 1
+	// for the common ancestor, 2 for ours and 3 for theirs.
+	FlagStage uint16
+	// ExtendedFlags of version 3 entries, like skip-worktree and
+	// intent-to-add. They are kept as they are.
+	ExtendedFlags uint16
 	// Full path
 	Name string
 }
 
 func isValidModeType(modeType uint16) bool {
-	validModeTypes := []uint16{uint16(ModeTypeRegular), uint16(ModeTypeSymlink), uint16(ModeTypeGitlink)}
+	validModeTypes := []uint16{uint16(ModeTypeTree), uint16(ModeTypeRegular), uint16(ModeTypeSymlink), uint16(ModeTypeGitlink)}
 	return slices.Contains(validModeTypes, modeType)
 }
 
+// SkipWorktree reports whether the file is left out of the worktree
+func (e *Entry) SkipWorktree() bool {
+	return e.ExtendedFlags&FlagSkipWorktree != 0
+}
+
+// SetSkipWorktree sets or clears the skip-worktree flag
+func (e *Entry) SetSkipWorktree(skip bool) {
+	if skip {
+		e.ExtendedFlags |= FlagSkipWorktree
+	} else {
+		e.ExtendedFlags &^= FlagSkipWorktree
+	}
+}
+
+// IsSparseDir reports whether the entry is a directory of a sparse index,
+// which stands for all the files in its tree. Its name ends with a slash.
+func (e *Entry) IsSparseDir() bool {
+	return e.ModeType == ModeTypeTree
+}
+
 func (m ModeType) String() string {
 	switch m {
+	case ModeTypeTree:
+		return "sparse directory"
 	case ModeTypeRegular:
 		return "regular file"
 	case ModeTypeSymlink:
@@ -53,8 +91,25 @@ func (m ModeType) String() string {
 	}
 }
 
+// TreeMode returns the mode of the entry in a tree: 100644 or 100755 for
+// regular files, depending on whether they are executable, 120000 for
+// symlinks, 160000 for gitlinks and 40000 for the directories of a sparse
+// index
+func (e *Entry) TreeMode() []byte {
+	switch e.ModeType {
+	case ModeTypeTree, ModeTypeSymlink, ModeTypeGitlink:
+		return e.ModeType.Octal()
+	}
+	if e.ModePerms&0o111 != 0 {
+		return []byte("100755")
+	}
+	return ModeTypeRegular.Octal()
+}
+
 func (m ModeType) Octal() []byte {
 	switch m {
+	case ModeTypeTree:
+		return []byte("40000")
 	case ModeTypeRegular:
 		return []byte("100644")
 	case ModeTypeSymlink:
@@ -2,12 +2,14 @@ package index
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"math"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jessegeens/got/pkg/fs"
@@ -20,16 +22,145 @@ import (
 type Index struct {
 	Version int
 	Entries []*Entry
+	// Extensions that are written back as they were read
+	Extensions []Extension
+	// Sparse is set for a sparse index, in which directories outside the
+	// sparse-checkout cone are single entries
+	Sparse bool
 }
 
+// ExpandSparse replaces the directories of a sparse index with the files
+// in their trees, and CollapseSparse does the opposite when the repository
+// is configured for a sparse index. The index can't read trees itself, so
+// the objects package sets them.
+var (
+	ExpandSparse   func(repo *repository.Repository, idx *Index) error
+	CollapseSparse func(repo *repository.Repository, idx *Index) (*Index, error)
+)
+
+// New returns an index of entries, sorted like git sorts them
 func New(entries []*Entry) *Index {
+	slices.SortStableFunc(entries, compareEntries)
 	return &Index{
 		Version: 2,
 		Entries: entries,
 	}
 }
 
+// Read reads the index of repo. Use Update to change the index
+// based on its current contents. A sparse index is expanded, so every
+// file is an entry.
 func Read(repo *repository.Repository) (*Index, error) {
+	lock := repo.Lock("index")
+	lock.RLock()
+	defer lock.RUnlock()
+	return readExpanded(repo)
+}
+
+// ReadSparse reads the index of repo without expanding the directories
+// of a sparse index
+func ReadSparse(repo *repository.Repository) (*Index, error) {
+	lock := repo.Lock("index")
+	lock.RLock()
+	defer lock.RUnlock()
+	return read(repo)
+}
+
+// Update reads the index, calls fn to change it and writes it back,
+// while preventing other updates through the same Repository handle
+// or other processes
+func Update(repo *repository.Repository, fn func(idx *Index) error) error {
+	lock := repo.Lock("index")
+	lock.Lock()
+	defer lock.Unlock()
+
+	idx, err := readExpanded(repo)
+	if err != nil {
+		return err
+	}
+	if err := fn(idx); err != nil {
+		return err
+	}
+	return idx.write(repo)
+}
+
+// Unmerged returns the paths that have conflict stages, in index order
+func (i *Index) Unmerged() []string {
+	paths := []string{}
+	for _, e := range i.Entries {
+		if e.FlagStage != 0 && (len(paths) == 0 || paths[len(paths)-1] != e.Name) {
+			paths = append(paths, e.Name)
+		}
+	}
+	return paths
+}
+
+// compareEntries orders entries by name and then by stage, which is the
+// order git expects in the index file
+func compareEntries(a, b *Entry) int {
+	return cmp.Or(strings.Compare(a.Name, b.Name), cmp.Compare(a.FlagStage, b.FlagStage))
+}
+
+// search returns the position of the entry for name and stage, and whether
+// it is in the index. If it isn't, the position is where it would go.
+func (i *Index) search(name string, stage uint16) (int, bool) {
+	return slices.BinarySearchFunc(i.Entries, &Entry{Name: name, FlagStage: stage}, compareEntries)
+}
+
+// Get returns the merged entry for name. It returns false if name is
+// not in the index or only has conflict stages.
+func (i *Index) Get(name string) (*Entry, bool) {
+	pos, ok := i.search(name, 0)
+	if !ok {
+		return nil, false
+	}
+	return i.Entries[pos], true
+}
+
+// Add puts entry in the index, replacing the entry with the same name
+// and stage. Like git, adding a merged entry removes the conflict stages
+// of the file, and adding a conflict stage removes the merged entry.
+func (i *Index) Add(entry *Entry) {
+	if entry.FlagStage == 0 {
+		i.Remove(entry.Name)
+	} else if pos, ok := i.search(entry.Name, 0); ok {
+		i.Entries = slices.Delete(i.Entries, pos, pos+1)
+	}
+
+	pos, ok := i.search(entry.Name, entry.FlagStage)
+	if ok {
+		i.Entries[pos] = entry
+		return
+	}
+	i.Entries = slices.Insert(i.Entries, pos, entry)
+}
+
+// Remove removes every stage of name from the index, and returns
+// whether it was in the index
+func (i *Index) Remove(name string) bool {
+	start, _ := i.search(name, 0)
+	end := start
+	for end < len(i.Entries) && i.Entries[end].Name == name {
+		end++
+	}
+	i.Entries = slices.Delete(i.Entries, start, end)
+	return end > start
+}
+
+// sort puts the entries in the order of the index file, and fails if an
+// entry occurs twice
+func (i *Index) sort() error {
+	slices.SortStableFunc(i.Entries, compareEntries)
+	for j := 1; j < len(i.Entries); j++ {
+		if compareEntries(i.Entries[j-1], i.Entries[j]) == 0 {
+			e := i.Entries[j]
+			return fmt.Errorf("invalid index: duplicate entry for '%s' at stage %d", e.Name, e.FlagStage)
+		}
+	}
+	return nil
+}
+
+func read(repo *repository.Repository) (*Index, error) {
 	indexFile := repo.RepositoryPath("index")
 
 	// New repositories don't have an index file yet
@@ -46,11 +177,108 @@ func Read(repo *repository.Repository) (*Index, error) {
 
 }
 
+func readExpanded(repo *repository.Repository) (*Index, error) {
+	idx, err := read(repo)
+	if err != nil || !idx.Sparse {
+		return idx, err
+	}
+	if ExpandSparse == nil {
+		return nil, errors.New("cannot expand a sparse index")
+	}
+	if err := ExpandSparse(repo, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Write replaces the index of repo
 func (i *Index) Write(repo *repository.Repository) error {
-	filepath, err := repo.RepositoryFile(true, "index")
+	lock := repo.Lock("index")
+	lock.Lock()
+	defer lock.Unlock()
+	return i.write(repo)
+}
+
+// write writes the index to index.lock and renames it, so readers never see
+// a partially written index. Like git, the lock file keeps other processes
+// from updating the index at the same time.
+func (i *Index) write(repo *repository.Repository) error {
+	if err := i.sort(); err != nil {
+		return err
+	}
+	if CollapseSparse != nil {
+		collapsed, err := CollapseSparse(repo, i)
+		if err != nil {
+			return err
+		}
+		i = collapsed
+	}
+
+	indexFile := repo.RepositoryPath("index")
+	lockFile := indexFile + ".lock"
+	f, err := os.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("unable to create '%s': file exists, another got process seems to be running in this repository", lockFile)
+	} else if err != nil {
+		return err
+	}
+
+	_, err = f.Write(i.encode())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = repo.AdjustSharedPerm(lockFile)
+	}
 	if err != nil {
+		os.Remove(lockFile)
 		return err
 	}
+	return os.Rename(lockFile, indexFile)
+}
+
+// Extension is an optional index extension that got doesn't use. It is
+// written back as it was read, so running got in a repository managed by
+// git keeps data like the untracked cache.
+type Extension struct {
+	Signature string
+	Data      []byte
+}
+
+// staleExtensions describe the entries or where they are in the file, so
+// they would be wrong once the entries change. Git rebuilds them when
+// they are missing, so they are dropped.
+var staleExtensions = map[string]bool{
+	// Cached trees of directories
+	"TREE": true,
+	// Offset of the end of the entries
+	"EOIE": true,
+	// Offsets of blocks of entries
+	"IEOT": true,
+}
+
+const (
+	// 1000 0000 0000 0000
+	flagAssumeValid = uint16(0x8000)
+	// 0100 0000 0000 0000
+	flagExtended = uint16(0x4000)
+	// 0011 0000 0000 0000
+	flagStageMask = uint16(0x3000)
+	// 0000 1111 1111 1111
+	flagNameMask = uint16(0x0FFF)
+)
+
+func (i *Index) encode() []byte {
+	version := i.Version
+	if version < 2 {
+		version = 2
+	}
+	for _, e := range i.Entries {
+		if e.ExtendedFlags != 0 {
+			// Extended flags need version 3
+			version = max(version, 3)
+		}
+	}
 
 	data := []byte{}
 
@@ -58,21 +286,20 @@ func (i *Index) Write(repo *repository.Repository) error {
 	data = append(data, []byte("DIRC")...)
 
 	// Write version number
-	data = writeUintToBytes(uint32(i.Version), data)
+	data = writeUintToBytes(uint32(version), data)
 
 	// Write number of entries
 	data = writeUintToBytes(uint32(len(i.Entries)), data)
 
 	// Write the entries
-	idx := 0
 	for _, e := range i.Entries {
-		// Write ctime; 8 bytes
-		ctimeUnix := uint(e.CTime.Unix())
-		data = writeUintToBytes(uint64(ctimeUnix), data)
+		start := len(data)
 
-		// Write mtime; 8 bytes
-		mtimeUnix := uint(e.MTime.Unix())
-		data = writeUintToBytes(uint64(mtimeUnix), data)
+		// Write ctime and mtime, as seconds and nanoseconds; 8 bytes each
+		data = writeUintToBytes(uint32(e.CTime.Unix()), data)
+		data = writeUintToBytes(uint32(e.CTime.Nanosecond()), data)
+		data = writeUintToBytes(uint32(e.MTime.Unix()), data)
+		data = writeUintToBytes(uint32(e.MTime.Nanosecond()), data)
 
 		// Device + inode (8 bytes)
 		data = writeUintToBytes(e.Dev, data)
@@ -94,35 +321,43 @@ func (i *Index) Write(repo *repository.Repository) error {
 		data = append(data, e.SHA.AsBytes()...)
 
 		// Name length and flags
-		flagAsssumeValid := uint16(0)
+		flags := (e.FlagStage << 12) & flagStageMask
 		if e.FlagAssumeValid {
-			flagAsssumeValid = 0x1 << 15
+			flags |= flagAssumeValid
+		}
+		if e.ExtendedFlags != 0 {
+			flags |= flagExtended
+		}
+		flags |= uint16(min(len(e.Name), int(flagNameMask)))
+		data = writeUintToBytes(flags, data)
+		if e.ExtendedFlags != 0 {
+			data = writeUintToBytes(e.ExtendedFlags, data)
 		}
-		// 0011 0000 0000 0000 = 12288
-		flagStage := e.FlagStage & uint16(12288)
-		nameLen := min(len(e.Name), 0xFF)
-		nameFlags := flagAsssumeValid | flagStage | uint16(nameLen)
-		data = writeUintToBytes(nameFlags, data)
 
 		// Name
 		data = append(data, []byte(e.Name)...)
-		data = append(data, 0x0)
 
-		// Padding
-		idx = 62 + len(e.Name) + 1
-		if idx%8 != 0 {
-			for range 8 - (idx % 8) {
-				data = append(data, 0x0)
-			}
-		}
+		// The name is followed by 1 to 8 null bytes, so the entry
+		// is a multiple of 8 bytes long
+		length := len(data) - start
+		data = append(data, make([]byte, 8-length%8)...)
 	}
 
-	os.WriteFile(filepath, data, os.ModePerm)
-	return nil
+	if i.Sparse {
+		data = append(data, []byte(sparseExtension)...)
+		data = writeUintToBytes(uint32(0), data)
+	}
+	for _, ext := range i.Extensions {
+		data = append(data, []byte(ext.Signature)...)
+		data = writeUintToBytes(uint32(len(ext.Data)), data)
+		data = append(data, ext.Data...)
+	}
+
+	// The index ends with a checksum of everything before it
+	return append(data, hashing.NewSHA(data).AsBytes()...)
 }
 
 func parseIndex(index []byte) (*Index, error) {
-	// Implement length check
 	if len(index) < 12 {
 		return nil, errors.New("invalid index: too short")
 	}
@@ -137,8 +372,8 @@ func parseIndex(index []byte) (*Index, error) {
 	}
 
 	version := enc.Uint32(header[4:8])
-	if version != 2 {
-		return nil, errors.New("invalid index version: got only supports git index version 2; got " + strconv.Itoa(int(version)))
+	if version != 2 && version != 3 {
+		return nil, errors.New("invalid index version: got only supports git index versions 2 and 3; got " + strconv.Itoa(int(version)))
 	}
 
 	count := enc.Uint32(header[8:12])
@@ -147,10 +382,10 @@ func parseIndex(index []byte) (*Index, error) {
 
 	for range count {
 		if len(content) < idx+62 {
-			break
+			return nil, errors.New("invalid index: truncated entry")
 		}
-		// TODO: bounds check on content
 		entry := &Entry{}
+		start := idx
 
 		// Read creation time seconds as unix timestamp (8bytes total)
 		ctimeSec := enc.Uint32(content[idx : idx+4])
@@ -191,28 +426,32 @@ func parseIndex(index []byte) (*Index, error) {
 
 		// Parse flags
 		flags := enc.Uint16(content[idx+60 : idx+62])
-		// 1000 0000 0000 0000 = 32768
-		entry.FlagAssumeValid = (flags & uint16(32768)) != 0
-		// 0100 0000 0000 0000 = 16384
-		extended := (flags & uint16(16384)) != 0
-		if extended {
-			return nil, errors.New("extended mode not supported")
-		}
-		// 0011 0000 0000 0000 = 12288
-		entry.FlagStage = (flags & uint16(12288))
-		// 0000 1111 1111 1111 = 4095
-		nameLength := flags & uint16(4095)
+		entry.FlagAssumeValid = flags&flagAssumeValid != 0
+		entry.FlagStage = (flags & flagStageMask) >> 12
+		nameLength := flags & flagNameMask
 
 		// Now we've read 62 bytes, so we advance the index
 		idx += 62
 
+		// Version 3 entries can have two more bytes of flags
+		if flags&flagExtended != 0 {
+			if version < 3 {
+				return nil, errors.New("invalid index: extended flags in a version 2 index")
+			}
+			if len(content) < idx+2 {
+				return nil, errors.New("invalid index: truncated entry")
+			}
+			entry.ExtendedFlags = enc.Uint16(content[idx : idx+2])
+			idx += 2
+		}
+
 		// We read the name
-		if nameLength < 0xFF {
-			if len(content) < idx+int(nameLength) || content[idx+int(nameLength)] != 0 {
+		if nameLength < flagNameMask {
+			if len(content) <= idx+int(nameLength) || content[idx+int(nameLength)] != 0 {
 				return nil, errors.New("invalid name length in index")
 			}
 			entry.Name = string(content[idx : idx+int(nameLength)])
-			idx += int(nameLength) + 1 // Extra byte for the null byte at the end
+			idx += int(nameLength)
 		} else {
 			// If the name is too long, we find the first occurence as a null byte as the demarcator
 			len := findNullByteIndex(content[idx:])
@@ -220,16 +459,100 @@ func parseIndex(index []byte) (*Index, error) {
 				return nil, errors.New("invalid name in index")
 			}
 			entry.Name = string(content[idx : idx+len])
-			idx += len + 1
+			idx += len
 		}
 
-		// index must be multiple of eight, since data is padded for ptr alignment
-		idx = 8 * int(math.Ceil(float64(idx)/8))
+		// The entry is padded with null bytes to a multiple of eight
+		idx += 8 - (idx-start)%8
+		if idx > len(content) {
+			return nil, errors.New("invalid index: truncated entry")
+		}
 
 		entries = append(entries, entry)
 	}
 
-	return New(entries), nil
+	// Older versions of got wrote the entries in the order they were added
+	i := &Index{Version: int(version), Entries: entries}
+	if err := i.sort(); err != nil {
+		return nil, err
+	}
+
+	// Indexes written by older versions of got end right after the entries
+	rest := content[idx:]
+	if len(rest) == 0 {
+		return i, i.checkSparse()
+	}
+
+	if len(rest) < hashLength {
+		return nil, errors.New("invalid index: missing checksum")
+	}
+	checksum := index[len(index)-hashLength:]
+	if !bytes.Equal(hashing.NewSHA(index[:len(index)-hashLength]).AsBytes(), checksum) {
+		return nil, errors.New("index file corrupt: bad checksum")
+	}
+
+	extensions, err := parseExtensions(rest[:len(rest)-hashLength])
+	if err != nil {
+		return nil, err
+	}
+	for _, ext := range extensions {
+		if ext.Signature == sparseExtension {
+			i.Sparse = true
+		} else {
+			i.Extensions = append(i.Extensions, ext)
+		}
+	}
+	return i, i.checkSparse()
+}
+
+// checkSparse fails if the index has directories but isn't sparse, which
+// git doesn't write
+func (i *Index) checkSparse() error {
+	if i.Sparse {
+		return nil
+	}
+	for _, e := range i.Entries {
+		if e.IsSparseDir() {
+			return fmt.Errorf("invalid index: directory entry '%s' in an index that is not sparse", e.Name)
+		}
+	}
+	return nil
+}
+
+// hashLength is the length of the checksum at the end of the index
+const hashLength = 20
+
+// sparseExtension marks a sparse index. It has no data.
+const sparseExtension = "sdir"
+
+// parseExtensions parses the extensions between the entries and the
+// checksum. Unknown optional extensions are kept, but an unknown mandatory
+// extension means we can't safely use the index.
+func parseExtensions(data []byte) ([]Extension, error) {
+	enc := binary.BigEndian
+	extensions := []Extension{}
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("invalid index: truncated extension")
+		}
+		signature := string(data[:4])
+		size := enc.Uint32(data[4:8])
+		if uint64(len(data)-8) < uint64(size) {
+			return nil, fmt.Errorf("invalid index: truncated %s extension", signature)
+		}
+		extData := data[8 : 8+size]
+		data = data[8+size:]
+
+		// Optional extensions start with an uppercase letter
+		if (signature[0] < 'A' || signature[0] > 'Z') && signature != sparseExtension {
+			return nil, fmt.Errorf("index uses the %s extension, which got does not understand", signature)
+		}
+		if staleExtensions[signature] {
+			continue
+		}
+		extensions = append(extensions, Extension{Signature: signature, Data: bytes.Clone(extData)})
+	}
+	return extensions, nil
 }
 
 func findNullByteIndex(arr []byte) int {
@@ -1,8 +1,11 @@
 package index
 
 import (
+	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -101,7 +104,7 @@ func TestIndexWriteAndRead(t *testing.T) {
 			Size:            0,
 			SHA:             sha2,
 			FlagAssumeValid: true,
-			FlagStage:       0xFF & uint16(12288),
+			FlagStage:       2,
 			Name:            "link.txt",
 		},
 	}
@@ -161,6 +164,7 @@ func TestModeTypeString(t *testing.T) {
 		{"regular", ModeTypeRegular, "regular file"},
 		{"symlink", ModeTypeSymlink, "symlink"},
 		{"gitlink", ModeTypeGitlink, "git link"},
+		{"tree", ModeTypeTree, "sparse directory"},
 		{"invalid", ModeType(999), "invalid"},
 	}
 
@@ -182,6 +186,7 @@ func TestIsValidModeType(t *testing.T) {
 		{"regular", uint16(ModeTypeRegular), true},
 		{"symlink", uint16(ModeTypeSymlink), true},
 		{"gitlink", uint16(ModeTypeGitlink), true},
+		{"tree", uint16(ModeTypeTree), true},
 		{"invalid", 999, false},
 	}
 
@@ -264,3 +269,261 @@ func TestIndexWithLongFilename(t *testing.T) {
 		t.Errorf("Expected long filename to be preserved, got '%s'", readIdx.Entries[0].Name)
 	}
 }
+
+func TestConcurrentUpdate(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	sha, _ := hashing.NewShaFromHex("0123456789abcdef0123456789abcdef01234567")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := Update(repo, func(idx *Index) error {
+				idx.Entries = append(idx.Entries, &Entry{
+					ModeType:  ModeTypeRegular,
+					ModePerms: 0o644,
+					SHA:       sha,
+					Name:      fmt.Sprintf("file%02d.txt", i),
+				})
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update() error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	idx, err := Read(repo)
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if len(idx.Entries) != 20 {
+		t.Errorf("Expected 20 entries, got %d", len(idx.Entries))
+	}
+}
+
+func TestWriteWithStaleLock(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	lockFile := repo.RepositoryPath("index.lock")
+	if err := os.WriteFile(lockFile, nil, 0o644); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+
+	err := New([]*Entry{}).Write(repo)
+	if err == nil || !strings.Contains(err.Error(), "file exists") {
+		t.Errorf("Write() error = %v, want lock file error", err)
+	}
+}
+
+func TestUnmerged(t *testing.T) {
+	idx := New([]*Entry{
+		{Name: "a.txt"},
+		{Name: "b.txt", FlagStage: 1},
+		{Name: "b.txt", FlagStage: 2},
+		{Name: "b.txt", FlagStage: 3},
+		{Name: "c.txt"},
+		{Name: "d.txt", FlagStage: 2},
+	})
+	got := idx.Unmerged()
+	want := []string{"b.txt", "d.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Unmerged() = %v, want %v", got, want)
+	}
+}
+
+func TestIndexExtensions(t *testing.T) {
+	sha, _ := hashing.NewShaFromHex("0123456789abcdef0123456789abcdef01234567")
+	now := time.Unix(1700000000, 123456789)
+	idx := New([]*Entry{
+		{CTime: now, MTime: now, ModeType: ModeTypeRegular, ModePerms: 0o644, SHA: sha, Name: "a.txt"},
+		// skip-worktree
+		{CTime: now, MTime: now, ModeType: ModeTypeRegular, ModePerms: 0o644, SHA: sha, Name: "b.txt", ExtendedFlags: 0x4000},
+	})
+	idx.Extensions = []Extension{{Signature: "UNTR", Data: []byte("untracked cache")}}
+
+	// Cached trees are dropped when the index is read
+	data := idx.encode()
+	data = append(data[:len(data)-hashLength], []byte("TREE\x00\x00\x00\x02xx")...)
+	data = append(data, hashing.NewSHA(data).AsBytes()...)
+
+	got, err := parseIndex(data)
+	if err != nil {
+		t.Fatalf("parseIndex() error = %v", err)
+	}
+	if got.Version != 3 {
+		t.Errorf("Version = %d, want 3", got.Version)
+	}
+	if len(got.Extensions) != 1 || got.Extensions[0].Signature != "UNTR" || string(got.Extensions[0].Data) != "untracked cache" {
+		t.Errorf("Extensions = %v, want only UNTR", got.Extensions)
+	}
+	if len(got.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(got.Entries))
+	}
+	if got.Entries[1].ExtendedFlags != 0x4000 || got.Entries[1].Name != "b.txt" {
+		t.Errorf("Entry = %s with flags %x, want b.txt with flags 4000", got.Entries[1].Name, got.Entries[1].ExtendedFlags)
+	}
+	if !got.Entries[0].MTime.Equal(now) {
+		t.Errorf("MTime = %v, want %v", got.Entries[0].MTime, now)
+	}
+}
+
+func TestSparseIndex(t *testing.T) {
+	sha, _ := hashing.NewShaFromHex("0123456789abcdef0123456789abcdef01234567")
+	dir := &Entry{ModeType: ModeTypeTree, SHA: sha, Name: "out/"}
+	dir.SetSkipWorktree(true)
+	idx := New([]*Entry{{ModeType: ModeTypeRegular, ModePerms: 0o644, SHA: sha, Name: "in/a.txt"}, dir})
+	idx.Sparse = true
+
+	got, err := parseIndex(idx.encode())
+	if err != nil {
+		t.Fatalf("parseIndex() error = %v", err)
+	}
+	if !got.Sparse || len(got.Extensions) != 0 {
+		t.Errorf("Sparse = %v with extensions %v, want a sparse index without other extensions", got.Sparse, got.Extensions)
+	}
+	if len(got.Entries) != 2 || !got.Entries[1].IsSparseDir() || !got.Entries[1].SkipWorktree() || got.Entries[1].Name != "out/" {
+		t.Errorf("Entries = %v, want in/a.txt and the directory out/", got.Entries)
+	}
+	if got.Entries[0].SkipWorktree() || got.Entries[0].IsSparseDir() {
+		t.Errorf("in/a.txt is a skip-worktree directory")
+	}
+
+	// Git only writes directories in a sparse index
+	idx.Sparse = false
+	if _, err := parseIndex(idx.encode()); err == nil || !strings.Contains(err.Error(), "not sparse") {
+		t.Errorf("parseIndex() error = %v, want an error about a directory in an index that is not sparse", err)
+	}
+}
+
+func TestParseInvalidIndex(t *testing.T) {
+	valid := New([]*Entry{{ModeType: ModeTypeRegular, ModePerms: 0o644, SHA: hashing.NewSHA(nil), Name: "a.txt"}}).encode()
+	entries := valid[:len(valid)-hashLength]
+	withChecksum := func(data []byte) []byte {
+		return append(slices.Clone(data), hashing.NewSHA(data).AsBytes()...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"valid", valid, ""},
+		{"without checksum", entries, ""},
+		{"bad checksum", append(slices.Clone(entries), make([]byte, hashLength)...), "bad checksum"},
+		{"truncated", valid[:40], "truncated"},
+		{"mandatory extension", withChecksum(append(slices.Clone(entries), []byte("link\x00\x00\x00\x00")...)), "does not understand"},
+		{"truncated extension", withChecksum(append(slices.Clone(entries), []byte("UNTR\x00\x00\x00\x09")...)), "truncated UNTR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseIndex(tt.data)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("parseIndex() error = %v", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("parseIndex() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddRemoveGet(t *testing.T) {
+	idx := New([]*Entry{{Name: "b.txt"}, {Name: "a.txt"}})
+	idx.Add(&Entry{Name: "c/d.txt"})
+	idx.Add(&Entry{Name: "c.txt"})
+	idx.Add(&Entry{Name: "a.txt", Size: 1})
+	idx.Add(&Entry{Name: "e.txt", FlagStage: 3})
+	idx.Add(&Entry{Name: "e.txt", FlagStage: 1})
+	// A conflict stage replaces the merged entry
+	idx.Add(&Entry{Name: "b.txt", FlagStage: 2})
+
+	names := func() []string {
+		names := []string{}
+		for _, e := range idx.Entries {
+			names = append(names, fmt.Sprintf("%s:%d", e.Name, e.FlagStage))
+		}
+		return names
+	}
+	want := []string{"a.txt:0", "b.txt:2", "c.txt:0", "c/d.txt:0", "e.txt:1", "e.txt:3"}
+	if got := names(); !slices.Equal(got, want) {
+		t.Errorf("Entries = %v, want %v", got, want)
+	}
+
+	if e, ok := idx.Get("a.txt"); !ok || e.Size != 1 {
+		t.Errorf("Get(a.txt) = %v, %t, want the replaced entry", e, ok)
+	}
+	if _, ok := idx.Get("e.txt"); ok {
+		t.Errorf("Get(e.txt) found an entry for an unmerged file")
+	}
+	if _, ok := idx.Get("missing"); ok {
+		t.Errorf("Get(missing) found an entry")
+	}
+
+	// A merged entry resolves the conflict
+	idx.Add(&Entry{Name: "e.txt"})
+	if !idx.Remove("c.txt") || idx.Remove("c.txt") {
+		t.Errorf("Remove(c.txt) should only succeed once")
+	}
+	want = []string{"a.txt:0", "b.txt:2", "c/d.txt:0", "e.txt:0"}
+	if got := names(); !slices.Equal(got, want) {
+		t.Errorf("Entries = %v, want %v", got, want)
+	}
+}
+
+func TestWriteSortsEntries(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+	sha, _ := hashing.NewShaFromHex("0123456789abcdef0123456789abcdef01234567")
+
+	idx := New([]*Entry{})
+	for _, name := range []string{"z", "a-b", "a/b", "a"} {
+		idx.Entries = append(idx.Entries, &Entry{ModeType: ModeTypeRegular, ModePerms: 0o644, SHA: sha, Name: name})
+	}
+	if err := idx.Write(repo); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	readIdx, err := Read(repo)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	got := []string{}
+	for _, e := range readIdx.Entries {
+		got = append(got, e.Name)
+	}
+	if want := []string{"a", "a-b", "a/b", "z"}; !slices.Equal(got, want) {
+		t.Errorf("Entries = %v, want %v", got, want)
+	}
+
+	// The same name and stage can't occur twice
+	readIdx.Entries = append(readIdx.Entries, &Entry{ModeType: ModeTypeRegular, SHA: sha, Name: "a"})
+	if err := readIdx.Write(repo); err == nil || !strings.Contains(err.Error(), "duplicate entry for 'a'") {
+		t.Errorf("Write() error = %v, want duplicate entry error", err)
+	}
+	if _, err := parseIndex(readIdx.encode()); err == nil || !strings.Contains(err.Error(), "duplicate entry") {
+		t.Errorf("parseIndex() error = %v, want duplicate entry error", err)
+	}
+}
+
+func TestEntryTreeMode(t *testing.T) {
+	tests := []struct {
+		entry Entry
+		want  string
+	}{
+		{entry: Entry{ModeType: ModeTypeRegular, ModePerms: 0o644}, want: "100644"},
+		{entry: Entry{ModeType: ModeTypeRegular, ModePerms: 0o755}, want: "100755"},
+		{entry: Entry{ModeType: ModeTypeSymlink}, want: "120000"},
+		{entry: Entry{ModeType: ModeTypeGitlink}, want: "160000"},
+		{entry: Entry{ModeType: ModeTypeTree}, want: "40000"},
+	}
+	for _, tt := range tests {
+		if got := string(tt.entry.TreeMode()); got != tt.want {
+			t.Errorf("TreeMode() of %v %o = %s, want %s", tt.entry.ModeType, tt.entry.ModePerms, got, tt.want)
+		}
+	}
+}
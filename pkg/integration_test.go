@@ -157,7 +157,7 @@ func TestGitWorkflow(t *testing.T) {
 	t.Run("commit the file", func(t *testing.T) {
 		// Commit the changes
 		commitCmd := command.CommitCommand()
-		err := commitCmd.Action([]string{})
+		err := commitCmd.Action([]string{"-m", "Add test file"})
 		if err != nil {
 			t.Fatalf("Failed to commit: %v", err)
 		}
@@ -0,0 +1,216 @@
+// Package interop keeps the table that maps the SHA-1 names of objects to
+// the names they have in the SHA-256 object format, so that a repository
+// can be converted to SHA-256 while old names, like the ones in signed
+// messages and links, can still be looked up. Like git, the table is
+// stored in objects/loose-object-idx, one object per line:
+//
+//	# loose-object-idx
+//	<sha-1> SP <sha-256>
+package interop
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/kvlm"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+const mapHeader = "# loose-object-idx"
+
+// Map maps SHA-1 names to SHA-256 names and back, as hex strings
+type Map struct {
+	compat  map[string]string
+	storage map[string]string
+}
+
+// NewMap returns an empty map
+func NewMap() *Map {
+	return &Map{compat: map[string]string{}, storage: map[string]string{}}
+}
+
+// Add records that the object sha1 is named sha256 in the SHA-256 format
+func (m *Map) Add(sha1, sha256 string) {
+	m.compat[sha1] = sha256
+	m.storage[sha256] = sha1
+}
+
+// Compat returns the SHA-256 name of an object named sha1
+func (m *Map) Compat(sha1 string) (string, bool) {
+	name, ok := m.compat[sha1]
+	return name, ok
+}
+
+// Storage returns the SHA-1 name of an object named sha256
+func (m *Map) Storage(sha256 string) (string, bool) {
+	name, ok := m.storage[sha256]
+	return name, ok
+}
+
+// Len returns the number of objects in the map
+func (m *Map) Len() int {
+	return len(m.compat)
+}
+
+func mapPath(repo *repository.Repository) string {
+	return repo.RepositoryPath("objects", "loose-object-idx")
+}
+
+// ReadMap reads the map of a repository. A repository without one has an
+// empty map.
+func ReadMap(repo *repository.Repository) (*Map, error) {
+	m := NewMap()
+	data, err := os.ReadFile(mapPath(repo))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line == 1 && text == mapHeader {
+			continue
+		}
+		sha1, sha256, ok := strings.Cut(text, " ")
+		if !ok || !isHex(sha1, 40) || !isHex(sha256, 64) {
+			return nil, fmt.Errorf("invalid line %d in %s", line, mapPath(repo))
+		}
+		m.Add(sha1, sha256)
+	}
+	return m, scanner.Err()
+}
+
+func isHex(s string, length int) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && len(s) == length
+}
+
+// Write stores the map in the repository, sorted by SHA-1 name
+func (m *Map) Write(repo *repository.Repository) error {
+	var buf bytes.Buffer
+	buf.WriteString(mapHeader + "\n")
+	names := make([]string, 0, len(m.compat))
+	for sha1 := range m.compat {
+		names = append(names, sha1)
+	}
+	slices.Sort(names)
+	for _, sha1 := range names {
+		fmt.Fprintf(&buf, "%s %s\n", sha1, m.compat[sha1])
+	}
+	mode, err := repo.SharedMode(fs.DefaultPerm)
+	if err != nil {
+		return err
+	}
+	return fs.AtomicWrite(mapPath(repo), buf.Bytes(), fs.WithExactPerm(mode))
+}
+
+// Build adds every loose object of the repository to the map, with the
+// objects they refer to. Objects that are already in the map are not
+// converted again, so building again after adding objects is cheap.
+func Build(repo *repository.Repository, m *Map) error {
+	return objects.ForEachLoose(repo, func(obj objects.LooseObject) error {
+		_, err := Convert(repo, m, obj.SHA)
+		return err
+	})
+}
+
+// Convert returns the SHA-256 name of an object, and adds it to the map.
+// The SHA-256 form of a tree, commit or tag refers to other objects by
+// their SHA-256 names, so those are converted first. Signatures are kept
+// as they are: they sign the SHA-1 form, which can be rebuilt from the
+// map to verify them.
+func Convert(repo *repository.Repository, m *Map, sha *hashing.SHA) (string, error) {
+	if name, ok := m.Compat(sha.AsString()); ok {
+		return name, nil
+	}
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return "", fmt.Errorf("object %s: %w", sha.AsString(), err)
+	}
+
+	data, err := obj.Serialize()
+	if err != nil {
+		return "", err
+	}
+	switch obj.Type() {
+	case objects.TypeTree:
+		data, err = convertTree(repo, m, obj.(*objects.Tree))
+	case objects.TypeCommit:
+		data, err = convertKvlm(repo, m, data, []string{"tree", "parent"})
+	case objects.TypeTag:
+		data, err = convertKvlm(repo, m, data, []string{"object"})
+	}
+	if err != nil {
+		return "", fmt.Errorf("object %s: %w", sha.AsString(), err)
+	}
+
+	hasher := sha256.New()
+	hasher.Write([]byte(string(obj.Type()) + " " + strconv.Itoa(len(data))))
+	hasher.Write([]byte{0})
+	hasher.Write(data)
+	name := hex.EncodeToString(hasher.Sum(nil))
+	m.Add(sha.AsString(), name)
+	return name, nil
+}
+
+func convertTree(repo *repository.Repository, m *Map, tree *objects.Tree) ([]byte, error) {
+	converted := &objects.Tree{}
+	for _, leaf := range tree.Items {
+		// Submodule commits are in another repository, with a map of its own
+		if string(leaf.Mode) == "160000" {
+			return nil, fmt.Errorf("cannot convert submodule commit %s at %s", leaf.Sha.AsString(), leaf.Path)
+		}
+		name, err := Convert(repo, m, leaf.Sha)
+		if err != nil {
+			return nil, err
+		}
+		raw, _ := hex.DecodeString(name)
+		converted.Items = append(converted.Items, &objects.TreeLeaf{
+			Mode: leaf.Mode,
+			Path: leaf.Path,
+			Sha:  hashing.NewShaFromBytes(raw),
+		})
+	}
+	return converted.Serialize()
+}
+
+// convertKvlm replaces the object names in the given headers of a commit
+// or tag, keeping all other headers and the message
+func convertKvlm(repo *repository.Repository, m *Map, raw []byte, keys []string) ([]byte, error) {
+	data := kvlm.New()
+	if err := kvlm.Parse(raw, 0, data); err != nil {
+		return nil, err
+	}
+	converted := kvlm.New()
+	for _, key := range data.Okv.Keys() {
+		for _, value := range data.Okv.GetAll(key) {
+			if slices.Contains(keys, key) {
+				sha, err := hashing.NewShaFromHex(string(value))
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s header: %w", key, err)
+				}
+				name, err := Convert(repo, m, sha)
+				if err != nil {
+					return nil, err
+				}
+				value = []byte(name)
+			}
+			converted.Okv.Set(key, value)
+		}
+	}
+	converted.Message = data.Message
+	return []byte(converted.Serialize()), nil
+}
@@ -0,0 +1,55 @@
+package interop
+
+import (
+	"testing"
+
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func TestConvert(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	blob, err := objects.ObjectHash([]byte{}, objects.TypeBlob, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := objects.TreeFromIndex(repo, index.New([]*index.Entry{
+		{ModeType: index.ModeTypeRegular, ModePerms: 0o644, SHA: blob, Name: "a"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMap()
+	if err := Build(repo, m); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	// The names git gives these objects in a SHA-256 repository
+	want := map[string]string{
+		blob.AsString(): "473a0f4c3be8a93681a267e3b1e9a7dcda1185436fe141f7749120a303721813",
+		tree.AsString(): "5f6f307bcc469c02acba4f7da42d8d4defdda8209777fe732956f1e2fa0db3ff",
+	}
+	for sha1, sha256 := range want {
+		if got, ok := m.Compat(sha1); !ok || got != sha256 {
+			t.Errorf("Compat(%s) = %s, %v, want %s", sha1, got, ok, sha256)
+		}
+	}
+
+	if err := m.Write(repo); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	read, err := ReadMap(repo)
+	if err != nil {
+		t.Fatalf("ReadMap() error = %v", err)
+	}
+	if read.Len() != m.Len() {
+		t.Errorf("ReadMap() has %d objects, want %d", read.Len(), m.Len())
+	}
+	if got, ok := read.Storage(want[tree.AsString()]); !ok || got != tree.AsString() {
+		t.Errorf("Storage() = %s, %v, want %s", got, ok, tree.AsString())
+	}
+}
@@ -94,3 +94,77 @@ func TestParseSerialize_RoundTrip(t *testing.T) {
 		t.Errorf("Round-trip failed: %q", serialized)
 	}
 }
+
+func TestParse_ContinuationLines(t *testing.T) {
+	raw := []byte("tree 1234567890abcdef\ngpgsig -----BEGIN PGP SIGNATURE-----\n \n abcdef\n -----END PGP SIGNATURE-----\nauthor Alice <alice@example.com>\n\nCommit message\n")
+	msg := New()
+	if err := Parse(raw, 0, msg); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	sig, ok := msg.Okv.Get("gpgsig")
+	want := "-----BEGIN PGP SIGNATURE-----\n\nabcdef\n-----END PGP SIGNATURE-----"
+	if !ok || string(sig) != want {
+		t.Errorf("gpgsig: got %q, want %q", sig, want)
+	}
+	author, ok := msg.Okv.Get("author")
+	if !ok || string(author) != "Alice <alice@example.com>" {
+		t.Errorf("author: got %q", author)
+	}
+	if msg.Serialize() != string(raw) {
+		t.Errorf("Round-trip failed: %q", msg.Serialize())
+	}
+}
+
+func TestParse_RepeatedKeys(t *testing.T) {
+	raw := []byte("tree 1234567890abcdef\nparent aaaa\nparent bbbb\n\nMerge\n")
+	msg := New()
+	if err := Parse(raw, 0, msg); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	parents := msg.Okv.GetAll("parent")
+	if len(parents) != 2 || string(parents[0]) != "aaaa" || string(parents[1]) != "bbbb" {
+		t.Errorf("Unexpected parents: %q", parents)
+	}
+	if msg.Serialize() != string(raw) {
+		t.Errorf("Round-trip failed: %q", msg.Serialize())
+	}
+}
+
+func TestParse_Truncated(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"no newline", "tree 1234567890abcdef"},
+		{"continuation without newline", "tree 1234567890abcdef\ngpgsig a\n b"},
+		{"tag cut off", "object 1234567890abcdef\ntype commit\ntag v1\ntagger A <a@example.com> 0"},
+		{"header without a value", "tree 1234567890abcdef\nmessage\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Parse([]byte(tt.raw), 0, New()); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.raw)
+			}
+		})
+	}
+}
+
+func TestParse_WithoutMessage(t *testing.T) {
+	// Objects can end after their last header, without a blank line
+	for _, raw := range []string{
+		"tree 1234567890abcdef\n",
+		"object 1234567890abcdef\ntype commit\ntag v1\ntagger A <a@example.com> 0 +0000\n",
+	} {
+		msg := New()
+		if err := Parse([]byte(raw), 0, msg); err != nil {
+			t.Fatalf("Parse(%q) error = %v", raw, err)
+		}
+		if msg.Message != nil {
+			t.Errorf("Parse(%q) message = %q, want none", raw, msg.Message)
+		}
+		if msg.Serialize() != raw {
+			t.Errorf("Round-trip of %q = %q", raw, msg.Serialize())
+		}
+	}
+}
@@ -5,12 +5,16 @@ package kvlm
 type OrderedKV struct {
 	kv   map[string][]byte
 	keys []string
+	// Keys like "parent" can occur multiple times,
+	// so we also keep every value separately
+	values map[string][][]byte
 }
 
 func NewOrderedKV() OrderedKV {
 	return OrderedKV{
-		kv:   map[string][]byte{},
-		keys: make([]string, 0),
+		kv:     map[string][]byte{},
+		keys:   make([]string, 0),
+		values: map[string][][]byte{},
 	}
 }
 
@@ -27,8 +31,14 @@ func (okv *OrderedKV) Get(key string) ([]byte, bool) {
 // If key does not exist yet, we set okv[key] = val
 // else, we set okv[key] = [okv[key], val]
 func (okv *OrderedKV) Set(key string, val []byte) {
+	if okv.values == nil {
+		okv.values = map[string][][]byte{}
+	}
+	okv.values[key] = append(okv.values[key], val)
+
 	if value, ok := okv.kv[key]; ok {
-		okv.kv[key] = append(value, val...)
+		// We copy, so that we never write into the backing array of a previous value
+		okv.kv[key] = append(append([]byte{}, value...), val...)
 	} else {
 		okv.kv[key] = val
 		okv.keys = append(okv.keys, key)
@@ -38,3 +48,8 @@ func (okv *OrderedKV) Set(key string, val []byte) {
 func (okv *OrderedKV) Keys() []string {
 	return okv.keys
 }
+
+// GetAll returns every value that was set for key, in order
+func (okv *OrderedKV) GetAll(key string) [][]byte {
+	return okv.values[key]
+}
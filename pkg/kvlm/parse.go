@@ -1,7 +1,15 @@
 package kvlm
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
 // Key-value List with Message
 type Kvlm struct {
+	// Message is nil when the object ends after its headers, without the
+	// blank line before the message
 	Message []byte
 	Okv     OrderedKV
 }
@@ -19,6 +27,16 @@ func Parse(raw []byte, start int, msg *Kvlm) error {
 	// itself back with a pointer to the next kv pair.  So we first need to know
 	// where we are: at a keyword, or already in the MessageQ
 
+	// Like in git, an object can end after its last header, without the
+	// blank line and the message. Only an empty object has no headers.
+	if start >= len(raw) {
+		if len(raw) == 0 {
+			return errors.New("empty object: no headers")
+		}
+		msg.Message = nil
+		return nil
+	}
+
 	// We search for the next space and new line
 	spaceIndex := find(raw, ' ', start)
 	newlineIndex := find(raw, '\n', start)
@@ -32,6 +50,9 @@ func Parse(raw []byte, start int, msg *Kvlm) error {
 	// find returns -1), we assume a blank line. A blank line means that the
 	// remainder of the data is the Message. We store it in the kvlm and return.
 	if (spaceIndex < 0) || (newlineIndex < spaceIndex) {
+		if raw[start] != '\n' {
+			return fmt.Errorf("malformed header line %q", line(raw, start))
+		}
 		msg.Message = raw[start+1:]
 		return nil
 	}
@@ -49,28 +70,35 @@ func Parse(raw []byte, start int, msg *Kvlm) error {
 	// by a space (because values can be multi-line)
 	end := start
 	for {
-		end += find(raw, '\n', end+1)
-		if end >= len(raw) || raw[end+1] != ' ' {
+		next := find(raw, '\n', end+1)
+		if next < 0 {
+			return fmt.Errorf("truncated header %q", key)
+		}
+		end += next + 1
+		if end+1 >= len(raw) || raw[end+1] != ' ' {
 			break
 		}
 	}
 
-	end += 1
-
-	// Then we can get the value
-	val := raw[spaceIndex+1 : end]
+	// Then we can get the value, dropping the leading space
+	// of each continuation line
+	val := bytes.ReplaceAll(raw[spaceIndex+1:end], []byte("\n "), []byte("\n"))
 
 	// And put the value in the map
-	if mapVal, ok := msg.Okv.Get(key); ok {
-		msg.Okv.Set(key, append(mapVal, val...))
-	} else {
-		msg.Okv.Set(key, val)
-	}
+	msg.Okv.Set(key, val)
 
 	// Finally, recurse over the other values
 	return Parse(raw, end+1, msg)
 }
 
+// line returns the line of raw that begins at start, without its newline
+func line(raw []byte, start int) []byte {
+	if end := find(raw, '\n', start); end >= 0 {
+		return raw[start : start+end]
+	}
+	return raw[start:]
+}
+
 func find(raw []byte, char byte, start int) int {
 	for idx, val := range raw[start:] {
 		if val == char {
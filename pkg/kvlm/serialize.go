@@ -8,13 +8,15 @@ func (kvlm *Kvlm) Serialize() string {
 	var serialized string
 
 	for _, k := range kvlm.Okv.Keys() {
-		val, _ := kvlm.Okv.Get(k)
-
-		line := k + " " + strings.Replace(string(val), "\n", "\n ", -1) + "\n"
-		serialized = serialized + line
+		for _, val := range kvlm.Okv.GetAll(k) {
+			line := k + " " + strings.Replace(string(val), "\n", "\n ", -1) + "\n"
+			serialized = serialized + line
+		}
 	}
 
-	serialized = serialized + "\n" + string(kvlm.Message)
+	if kvlm.Message != nil {
+		serialized = serialized + "\n" + string(kvlm.Message)
+	}
 
 	return serialized
 }
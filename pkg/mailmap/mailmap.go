@@ -0,0 +1,221 @@
+// Package mailmap maps the names and emails that commits were made with to
+// the canonical ones, like git does with .mailmap files. Every line maps
+// an email, or a name and email, to a proper name and/or email:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+//
+// Emails and names are matched without regard to case.
+package mailmap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Mailmap holds the mappings of one or more mailmap files
+type Mailmap struct {
+	// entries are keyed by the lower case commit email
+	entries map[string]*entry
+}
+
+// entry has the mappings of a commit email: the one for any name, and
+// the ones for specific names, keyed by the lower case commit name
+type entry struct {
+	fallback *identity
+	names    map[string]*identity
+}
+
+type identity struct {
+	name  string
+	email string
+}
+
+// New returns an empty mailmap, which maps nothing
+func New() *Mailmap {
+	return &Mailmap{entries: map[string]*entry{}}
+}
+
+// Parse reads the lines of a mailmap file. Lines that don't have an email
+// are ignored, like comments starting with #.
+func Parse(data []byte) *Mailmap {
+	m := New()
+	m.Add(data)
+	return m
+}
+
+// Add adds the mappings of a mailmap file. Later mappings of the same
+// commit identity win.
+func (m *Mailmap) Add(data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		name1, email1, rest, ok := parseNameEmail(line)
+		if !ok {
+			continue
+		}
+		name2, email2, _, ok := parseNameEmail(rest)
+		if !ok {
+			// A single email is both the commit email and the one whose
+			// name is replaced
+			m.add(identity{name: name1}, "", email1)
+			continue
+		}
+		m.add(identity{name: name1, email: email1}, name2, email2)
+	}
+}
+
+func (m *Mailmap) add(proper identity, commitName, commitEmail string) {
+	key := strings.ToLower(commitEmail)
+	e, ok := m.entries[key]
+	if !ok {
+		e = &entry{names: map[string]*identity{}}
+		m.entries[key] = e
+	}
+	var target *identity
+	if commitName == "" {
+		if e.fallback == nil {
+			e.fallback = &identity{}
+		}
+		target = e.fallback
+	} else {
+		name := strings.ToLower(commitName)
+		if e.names[name] == nil {
+			e.names[name] = &identity{}
+		}
+		target = e.names[name]
+	}
+	// Like git, a later line only replaces what it sets
+	if proper.name != "" {
+		target.name = proper.name
+	}
+	if proper.email != "" {
+		target.email = proper.email
+	}
+}
+
+// parseNameEmail reads "Name <email>" from the start of s, and returns
+// the name, the email and what follows
+func parseNameEmail(s string) (string, string, string, bool) {
+	start := strings.IndexByte(s, '<')
+	if start < 0 {
+		return "", "", "", false
+	}
+	end := strings.IndexByte(s[start:], '>')
+	if end < 0 {
+		return "", "", "", false
+	}
+	end += start
+	return strings.TrimSpace(s[:start]), s[start+1 : end], s[end+1:], true
+}
+
+// Resolve returns the canonical name and email of an identity. Mappings
+// for the name and email win over those for the email alone. Identities
+// that aren't mapped are returned as they are.
+func (m *Mailmap) Resolve(name, email string) (string, string) {
+	e, ok := m.entries[strings.ToLower(email)]
+	if !ok {
+		return name, email
+	}
+	proper := e.names[strings.ToLower(name)]
+	if proper == nil {
+		proper = e.fallback
+	}
+	if proper == nil {
+		return name, email
+	}
+	if proper.name != "" {
+		name = proper.name
+	}
+	if proper.email != "" {
+		email = proper.email
+	}
+	return name, email
+}
+
+// ResolveIdent returns ident with its canonical name and email
+func (m *Mailmap) ResolveIdent(ident objects.Ident) objects.Ident {
+	ident.Name, ident.Email = m.Resolve(ident.Name, ident.Email)
+	return ident
+}
+
+// Load reads the mailmap of a repository like git does: .mailmap at the
+// top of the worktree, the file in mailmap.file and the blob in
+// mailmap.blob, e.g. HEAD:.mailmap, in that order. Files that don't
+// exist are skipped.
+func Load(repo *repository.Repository) (*Mailmap, error) {
+	m := New()
+	if data, err := os.ReadFile(filepath.Join(repo.WorkTree(), ".mailmap")); err == nil {
+		m.Add(data)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := cfg.Get("mailmap", "file"); ok {
+		if data, err := os.ReadFile(file); err == nil {
+			m.Add(data)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if blob, ok := cfg.Get("mailmap", "blob"); ok {
+		data, err := readBlob(repo, blob)
+		if err != nil {
+			return nil, err
+		}
+		m.Add(data)
+	}
+	return m, nil
+}
+
+// readBlob reads a blob named like <rev>:<path> or by its hash. A blob
+// that doesn't exist, e.g. because HEAD has no .mailmap, reads as empty.
+func readBlob(repo *repository.Repository, name string) ([]byte, error) {
+	rev, path, ok := strings.Cut(name, ":")
+	var sha *hashing.SHA
+	if ok {
+		tree, err := objects.Find(repo, rev, objects.TypeTree, true)
+		if err != nil {
+			return nil, nil
+		}
+		paths, err := objects.MapFromTree(repo, tree.AsString())
+		if err != nil {
+			return nil, err
+		}
+		if sha = paths[path]; sha == nil {
+			return nil, nil
+		}
+	} else {
+		var err error
+		if sha, err = objects.Find(repo, name, objects.TypeBlob, true); err != nil {
+			return nil, nil
+		}
+	}
+
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	blob, ok := obj.(*objects.Blob)
+	if !ok {
+		return nil, nil
+	}
+	return blob.Serialize()
+}
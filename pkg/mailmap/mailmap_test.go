@@ -0,0 +1,79 @@
+package mailmap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+const testMailmap = `# Comments and lines without an email are skipped
+Just a name
+Jane Doe <jane@example.com>
+<jane@example.com> <jane@old.example.com>
+Joe Developer <joe@example.com> <JOE@laptop.local>
+Other Joe <other@example.com> joe <joe@shared.example.com>
+Shared Account <shared@example.com> <joe@shared.example.com>
+Jane Doe <jane@example.com> # trailing comment
+`
+
+func TestResolve(t *testing.T) {
+	m := Parse([]byte(testMailmap))
+	tests := []struct {
+		name, email         string
+		wantName, wantEmail string
+	}{
+		{name: "jane", email: "jane@example.com", wantName: "Jane Doe", wantEmail: "jane@example.com"},
+		{name: "jane", email: "JANE@example.com", wantName: "Jane Doe", wantEmail: "JANE@example.com"},
+		{name: "Jane", email: "jane@old.example.com", wantName: "Jane", wantEmail: "jane@example.com"},
+		{name: "joe", email: "joe@laptop.local", wantName: "Joe Developer", wantEmail: "joe@example.com"},
+		{name: "Joe", email: "joe@shared.example.com", wantName: "Other Joe", wantEmail: "other@example.com"},
+		{name: "someone", email: "joe@shared.example.com", wantName: "Shared Account", wantEmail: "shared@example.com"},
+		{name: "Unknown", email: "unknown@example.com", wantName: "Unknown", wantEmail: "unknown@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.email, func(t *testing.T) {
+			name, email := m.Resolve(tt.name, tt.email)
+			if name != tt.wantName || email != tt.wantEmail {
+				t.Errorf("Resolve() = %s <%s>, want %s <%s>", name, email, tt.wantName, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := repository.Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".mailmap"), []byte("Jane Doe <jane@example.com>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	extra := filepath.Join(t.TempDir(), "mailmap")
+	if err := os.WriteFile(extra, []byte("Jane D. <jane@example.com>\nJoe <joe@example.com>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := os.OpenFile(repo.RepositoryPath("config"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A blob that doesn't exist is skipped
+	if _, err := config.WriteString("[mailmap]\n\tfile = " + extra + "\n\tblob = HEAD:.mailmap\n"); err != nil {
+		t.Fatal(err)
+	}
+	config.Close()
+
+	m, err := Load(repo)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// mailmap.file comes after .mailmap, so it wins
+	if name, _ := m.Resolve("jane", "jane@example.com"); name != "Jane D." {
+		t.Errorf("Resolve() name = %s, want Jane D.", name)
+	}
+	if name, _ := m.Resolve("joe", "joe@example.com"); name != "Joe" {
+		t.Errorf("Resolve() name = %s, want Joe", name)
+	}
+}
@@ -0,0 +1,198 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/diff"
+)
+
+// defaultMarkerSize is the length of conflict markers, which is passed to
+// external drivers
+const defaultMarkerSize = 7
+
+// Driver merges the three versions of a file. The merge attribute of a
+// path selects its driver:
+//
+//	merge           the built-in text merge
+//	-merge          binary: our version is kept, as a conflict
+//	merge=text      the built-in text merge
+//	merge=binary    binary
+//	merge=union     the text merge, with both sides kept for conflicts
+//	merge=<name>    the command configured as merge.<name>.driver
+//
+// Without the attribute, the driver named by merge.default is used, or
+// the text merge. Drivers that aren't configured fall back to the text
+// merge, like in git. To always keep our version of a file, configure
+// merge.ours.driver as "true" and set merge=ours.
+type Driver interface {
+	Merge(path string, base, ours, theirs []byte, labels Labels) (Result, error)
+}
+
+// TextDriver is the built-in three-way merge. Binary files are merged
+// like BinaryDriver does.
+type TextDriver struct{}
+
+func (TextDriver) Merge(path string, base, ours, theirs []byte, labels Labels) (Result, error) {
+	if diff.IsBinary(base) || diff.IsBinary(ours) || diff.IsBinary(theirs) {
+		return BinaryDriver{}.Merge(path, base, ours, theirs, labels)
+	}
+	return Merge(base, ours, theirs, labels), nil
+}
+
+// BinaryDriver keeps our version and reports a conflict
+type BinaryDriver struct{}
+
+func (BinaryDriver) Merge(_ string, _, ours, _ []byte, _ Labels) (Result, error) {
+	return Result{Content: ours, Conflicts: 1, Binary: true}, nil
+}
+
+// UnionDriver keeps the lines of both sides where they conflict, without
+// conflict markers. This suits files like changelogs, where the order of
+// the lines doesn't matter much.
+type UnionDriver struct{}
+
+func (UnionDriver) Merge(path string, base, ours, theirs []byte, labels Labels) (Result, error) {
+	if diff.IsBinary(base) || diff.IsBinary(ours) || diff.IsBinary(theirs) {
+		return BinaryDriver{}.Merge(path, base, ours, theirs, labels)
+	}
+	return Result{Content: Union(base, ours, theirs)}, nil
+}
+
+// ExternalDriver runs a merge.<name>.driver command through the shell. In
+// the command, these placeholders are replaced:
+//
+//	%O  a temporary file with the ancestor's version
+//	%A  a temporary file with our version, where the result is written
+//	%B  a temporary file with their version
+//	%L  the conflict marker size
+//	%P  the path of the file that is merged
+//	%%  a single %
+//
+// A driver exits with status 0 if the merge is clean, or with another
+// status if there are conflicts. Drivers that are killed by a signal or
+// exit with a status above 128 fail the merge.
+type ExternalDriver struct {
+	Name    string
+	Command string
+	// Dir is where the command runs, the root of the worktree
+	Dir string
+}
+
+func (e ExternalDriver) Merge(path string, base, ours, theirs []byte, _ Labels) (Result, error) {
+	files := map[byte]string{}
+	for _, side := range []struct {
+		placeholder byte
+		contents    []byte
+	}{{'O', base}, {'A', ours}, {'B', theirs}} {
+		file, err := os.CreateTemp(e.Dir, ".merge_file_")
+		if err != nil {
+			return Result{}, err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.Write(side.contents)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		files[side.placeholder] = file.Name()
+	}
+
+	var command strings.Builder
+	for i := 0; i < len(e.Command); i++ {
+		if e.Command[i] != '%' || i+1 == len(e.Command) {
+			command.WriteByte(e.Command[i])
+			continue
+		}
+		i++
+		switch c := e.Command[i]; c {
+		case 'O', 'A', 'B':
+			// The driver runs in Dir, so relative names are enough. Like
+			// in git, they aren't quoted, since they contain no special
+			// characters.
+			name, err := filepath.Rel(e.Dir, files[c])
+			if err != nil {
+				name = files[c]
+			}
+			command.WriteString(name)
+		case 'L':
+			command.WriteString(strconv.Itoa(defaultMarkerSize))
+		case 'P':
+			command.WriteString(shellQuote(path))
+		case '%':
+			command.WriteByte('%')
+		default:
+			command.WriteByte('%')
+			command.WriteByte(c)
+		}
+	}
+
+	cmd := exec.Command("sh", "-c", command.String())
+	cmd.Dir = e.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+	var exitErr *exec.ExitError
+	if runErr != nil && (!errors.As(runErr, &exitErr) || exitErr.ExitCode() < 0 || exitErr.ExitCode() > 128) {
+		return Result{}, fmt.Errorf("merge driver %s failed on %s: %w", e.Name, path, runErr)
+	}
+
+	content, err := os.ReadFile(files['A'])
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Content: content}
+	if runErr != nil {
+		result.Conflicts = 1
+	}
+	return result, nil
+}
+
+// shellQuote quotes s for sh, so it is passed as a single word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// DriverFor returns the driver for path, based on its merge attribute and
+// the merge drivers in the configuration. External drivers run in dir.
+func DriverFor(cfg config.GitConfig, attrs *attributes.Attributes, path, dir string) (Driver, error) {
+	name := ""
+	switch value := attrs.Get(path, "merge"); value.State {
+	case attributes.Set:
+		return TextDriver{}, nil
+	case attributes.Unset:
+		return BinaryDriver{}, nil
+	case attributes.Valued:
+		name = value.Value
+	default:
+		if name, _ = cfg.Get("merge", "default"); name == "" {
+			return TextDriver{}, nil
+		}
+	}
+
+	// Configured drivers take precedence over the built-in ones
+	section := fmt.Sprintf("merge %q", name)
+	if len(cfg.Section(section)) > 0 {
+		command, _ := cfg.Get(section, "driver")
+		if command == "" {
+			return nil, fmt.Errorf("custom merge driver %s lacks command line", name)
+		}
+		return ExternalDriver{Name: name, Command: command, Dir: dir}, nil
+	}
+	switch name {
+	case "binary":
+		return BinaryDriver{}, nil
+	case "union":
+		return UnionDriver{}, nil
+	}
+	return TextDriver{}, nil
+}
@@ -0,0 +1,127 @@
+package merge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/attributes"
+	"github.com/jessegeens/got/pkg/config"
+)
+
+func TestUnion(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   string
+		ours   string
+		theirs string
+		want   string
+	}{
+		{
+			name:   "both appended",
+			base:   "a\n",
+			ours:   "a\nb\n",
+			theirs: "a\nc\n",
+			want:   "a\nb\nc\n",
+		},
+		{
+			name:   "separate changes",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "missing final newline",
+			base:   "a\nb",
+			ours:   "a\nB",
+			theirs: "a\nX",
+			want:   "a\nB\nX",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Union([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs))
+			if string(got) != tt.want {
+				t.Errorf("Union() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriverFor(t *testing.T) {
+	attrs := attributes.New()
+	if err := attrs.Parse([]byte("*.bin -merge\nCHANGES merge=union\n*.txt merge\n*.lock merge=binary\n*.cfg merge=unknown\n"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want Driver
+	}{
+		{path: "main.go", want: TextDriver{}},
+		{path: "a.txt", want: TextDriver{}},
+		{path: "image.bin", want: BinaryDriver{}},
+		{path: "go.lock", want: BinaryDriver{}},
+		{path: "CHANGES", want: UnionDriver{}},
+		{path: "app.cfg", want: TextDriver{}},
+	}
+	for _, tt := range tests {
+		got, err := DriverFor(config.GitConfig{}, attrs, tt.path, "")
+		if err != nil {
+			t.Errorf("DriverFor(%q) error: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DriverFor(%q) = %#v, want %#v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExternalDriver(t *testing.T) {
+	tests := []struct {
+		name          string
+		command       string
+		want          string
+		wantConflicts int
+		wantErr       bool
+	}{
+		{
+			name:    "keeps ours",
+			command: "true",
+			want:    "ours\n",
+		},
+		{
+			name:    "placeholders",
+			command: "cat %O %B >> %A; echo %L %P%% >> %A",
+			want:    "ours\nbase\ntheirs\n7 dir/it's a file%\n",
+		},
+		{
+			name:          "conflict",
+			command:       "echo merged > %A; exit 1",
+			want:          "merged\n",
+			wantConflicts: 1,
+		},
+		{
+			name:    "crash",
+			command: "exit 130",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := ExternalDriver{Name: "test", Command: tt.command, Dir: t.TempDir()}
+			got, err := driver.Merge("dir/it's a file", []byte("base\n"), []byte("ours\n"), []byte("theirs\n"), Labels{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Merge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got.Content) != tt.want {
+				t.Errorf("Merge() content = %q, want %q", got.Content, tt.want)
+			}
+			if got.Conflicts != tt.wantConflicts {
+				t.Errorf("Merge() conflicts = %d, want %d", got.Conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Three-way merges of file contents, like git merge-file.
+//
+// Changes made on one side are taken as is. When both sides changed the
+// same lines differently, the result contains both versions between
+// conflict markers:
+//
+//	<<<<<<< ours
+//	our lines
+//	=======
+//	their lines
+//	>>>>>>> theirs
+package merge
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/diff"
+)
+
+// Labels are shown after the conflict markers
+type Labels struct {
+	Ours   string
+	Theirs string
+}
+
+// Result is the outcome of a merge
+type Result struct {
+	Content []byte
+	// Conflicts is the number of conflicting hunks in Content
+	Conflicts int
+	// Binary is set if the versions couldn't be merged because they are
+	// binary. Content is then our version.
+	Binary bool
+}
+
+// Merge merges the changes from base to ours and from base to theirs
+func Merge(base, ours, theirs []byte, labels Labels) Result {
+	return merge(base, ours, theirs, labels, false)
+}
+
+// Union merges like Merge, but instead of conflict markers, the result has
+// our lines followed by their lines. It is git's union merge driver.
+func Union(base, ours, theirs []byte) []byte {
+	return merge(base, ours, theirs, Labels{}, true).Content
+}
+
+func merge(base, ours, theirs []byte, labels Labels, union bool) Result {
+	baseLines, ourLines, theirLines := diff.SplitLines(base), diff.SplitLines(ours), diff.SplitLines(theirs)
+
+	// For each line of base, its position in ours and theirs, if it was kept
+	inOurs := positions(diff.CommonLines(baseLines, ourLines), len(baseLines))
+	inTheirs := positions(diff.CommonLines(baseLines, theirLines), len(baseLines))
+
+	hunks := []hunk{}
+	i, a, b := 0, 0, 0
+	for i < len(baseLines) || a < len(ourLines) || b < len(theirLines) {
+		// Lines that are unchanged on both sides are stable
+		if i < len(baseLines) && inOurs[i] == a && inTheirs[i] == b {
+			hunks = appendStable(hunks, baseLines[i])
+			i, a, b = i+1, a+1, b+1
+			continue
+		}
+
+		// Otherwise, the chunk runs until the next line of base kept on both sides
+		k := i
+		for k < len(baseLines) && (inOurs[k] < 0 || inTheirs[k] < 0) {
+			k++
+		}
+		nextA, nextB := len(ourLines), len(theirLines)
+		if k < len(baseLines) {
+			nextA, nextB = inOurs[k], inTheirs[k]
+		}
+
+		baseChunk, ourChunk, theirChunk := baseLines[i:k], ourLines[a:nextA], theirLines[b:nextB]
+		switch {
+		case equalLines(ourChunk, theirChunk):
+			// The same change on both sides doesn't separate conflicts
+			hunks = appendStable(hunks, ourChunk...)
+		case equalLines(baseChunk, theirChunk):
+			hunks = append(hunks, hunk{kind: resolved, ours: ourChunk})
+		case equalLines(baseChunk, ourChunk):
+			hunks = append(hunks, hunk{kind: resolved, ours: theirChunk})
+		default:
+			hunks = refineConflict(hunks, ourChunk, theirChunk)
+		}
+		i, a, b = k, nextA, nextB
+	}
+	hunks = simplifyConflicts(hunks)
+
+	var out bytes.Buffer
+	result := Result{}
+	for _, h := range hunks {
+		if h.kind == conflict && union {
+			writeLines(&out, h.ours)
+			out.Write(bytes.Join(h.theirs, nil))
+			continue
+		}
+		if h.kind == conflict {
+			result.Conflicts++
+			writeConflict(&out, h.ours, h.theirs, labels)
+			continue
+		}
+		for _, line := range h.ours {
+			out.Write(line)
+		}
+	}
+	result.Content = out.Bytes()
+	return result
+}
+
+type hunkKind int
+
+const (
+	// stable lines are unchanged, or the same on both sides of a conflict
+	stable hunkKind = iota
+	// resolved lines were changed on one side only, or the same way on both
+	resolved
+	conflict
+)
+
+// hunk is a run of output lines. Only conflicts use theirs.
+type hunk struct {
+	kind   hunkKind
+	ours   [][]byte
+	theirs [][]byte
+}
+
+func appendStable(hunks []hunk, lines ...[]byte) []hunk {
+	if len(hunks) > 0 && hunks[len(hunks)-1].kind == stable {
+		last := &hunks[len(hunks)-1]
+		last.ours = append(last.ours, lines...)
+		return hunks
+	}
+	return append(hunks, hunk{kind: stable, ours: lines})
+}
+
+// refineConflict diffs both sides of a conflict, so lines they have in
+// common end up outside of the conflict markers
+func refineConflict(hunks []hunk, ours, theirs [][]byte) []hunk {
+	if len(ours) == 0 || len(theirs) == 0 {
+		return append(hunks, hunk{kind: conflict, ours: ours, theirs: theirs})
+	}
+
+	a, b := 0, 0
+	for _, m := range append(diff.CommonLines(ours, theirs), diff.Match{A: len(ours), B: len(theirs)}) {
+		if a < m.A || b < m.B {
+			hunks = append(hunks, hunk{kind: conflict, ours: ours[a:m.A], theirs: theirs[b:m.B]})
+		}
+		if m.A < len(ours) {
+			hunks = appendStable(hunks, ours[m.A])
+		}
+		a, b = m.A+1, m.B+1
+	}
+	return hunks
+}
+
+// simplifyConflicts joins conflicts that are only separated by a few
+// lines, or by lines without letters and digits like a closing brace, as
+// git does by default
+func simplifyConflicts(hunks []hunk) []hunk {
+	simplified := []hunk{}
+	for _, h := range hunks {
+		n := len(simplified)
+		if h.kind == conflict && n >= 2 && simplified[n-1].kind == stable && simplified[n-2].kind == conflict {
+			gap := simplified[n-1].ours
+			if len(gap) <= 3 || !containsAlnum(gap) {
+				prev := &simplified[n-2]
+				prev.ours = concatLines(prev.ours, gap, h.ours)
+				prev.theirs = concatLines(prev.theirs, gap, h.theirs)
+				simplified = simplified[:n-1]
+				continue
+			}
+		}
+		simplified = append(simplified, h)
+	}
+	return simplified
+}
+
+func containsAlnum(lines [][]byte) bool {
+	for _, line := range lines {
+		for _, c := range line {
+			if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func concatLines(parts ...[][]byte) [][]byte {
+	lines := [][]byte{}
+	for _, part := range parts {
+		lines = append(lines, part...)
+	}
+	return lines
+}
+
+func equalLines(a, b [][]byte) bool {
+	return bytes.Equal(bytes.Join(a, nil), bytes.Join(b, nil))
+}
+
+// positions maps each line of base to its position in the other
+// version, or -1 if it was removed
+func positions(matches []diff.Match, n int) []int {
+	pos := make([]int, n)
+	for i := range pos {
+		pos[i] = -1
+	}
+	for _, m := range matches {
+		pos[m.A] = m.B
+	}
+	return pos
+}
+
+func writeConflict(out *bytes.Buffer, ours, theirs [][]byte, labels Labels) {
+	marker := func(m, label string) {
+		out.WriteString(strings.TrimSpace(m + " " + label))
+		out.WriteByte('\n')
+	}
+
+	marker("<<<<<<<", labels.Ours)
+	writeLines(out, ours)
+	marker("=======", "")
+	writeLines(out, theirs)
+	marker(">>>>>>>", labels.Theirs)
+}
+
+// writeLines writes lines, ending the last one if it has no newline
+func writeLines(out *bytes.Buffer, lines [][]byte) {
+	for _, line := range lines {
+		out.Write(line)
+		if line[len(line)-1] != '\n' {
+			out.WriteByte('\n')
+		}
+	}
+}
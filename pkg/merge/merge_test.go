@@ -0,0 +1,137 @@
+package merge
+
+import "testing"
+
+func TestMerge(t *testing.T) {
+	labels := Labels{Ours: "ours", Theirs: "theirs"}
+	tests := []struct {
+		name          string
+		base          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "unchanged",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nb\n",
+			want:   "a\nb\n",
+		},
+		{
+			name:   "only ours changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:   "only theirs changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nb\nc\nd\n",
+			want:   "a\nb\nc\nd\n",
+		},
+		{
+			name:   "separate changes",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "same change on both sides",
+			base:   "a\nb\nc\n",
+			ours:   "a\nc\n",
+			theirs: "a\nc\n",
+			want:   "a\nc\n",
+		},
+		{
+			name:   "add/add",
+			ours:   "a\n",
+			theirs: "b\n",
+			want:   "<<<<<<< ours\na\n=======\nb\n>>>>>>> theirs\n",
+
+			wantConflicts: 1,
+		},
+		{
+			name:   "conflict",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nX\nc\n",
+			want:   "a\n<<<<<<< ours\nB\n=======\nX\n>>>>>>> theirs\nc\n",
+
+			wantConflicts: 1,
+		},
+		{
+			name:   "modify/delete",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nc\n",
+			want:   "a\n<<<<<<< ours\nB\n=======\n>>>>>>> theirs\nc\n",
+
+			wantConflicts: 1,
+		},
+		{
+			name:   "common lines move out of the conflict",
+			base:   "l0\nl1\n",
+			ours:   "mod2\nl1\nins2\n",
+			theirs: "mod2\n",
+			want:   "mod2\n<<<<<<< ours\nl1\nins2\n=======\n>>>>>>> theirs\n",
+
+			wantConflicts: 1,
+		},
+		{
+			name:   "conflicts close together are joined",
+			base:   "l0\nl1\nl2\nl3\nl4\nl5\nl6\n",
+			ours:   "mod1\nl1\nl2\nl4\nl6\n",
+			theirs: "mod2\n}\nl2\nl4\nins2\nl5\nl6\n",
+			want:   "<<<<<<< ours\nmod1\nl1\nl2\nl4\n=======\nmod2\n}\nl2\nl4\nins2\nl5\n>>>>>>> theirs\nl6\n",
+
+			wantConflicts: 1,
+		},
+		{
+			name:   "conflicts far apart are kept",
+			base:   "a\nb\nc\nd\ne\nf\n",
+			ours:   "A\nb\nc\nd\ne\nF\n",
+			theirs: "X\nb\nc\nd\ne\nY\n",
+			want:   "<<<<<<< ours\nA\n=======\nX\n>>>>>>> theirs\nb\nc\nd\ne\n<<<<<<< ours\nF\n=======\nY\n>>>>>>> theirs\n",
+
+			wantConflicts: 2,
+		},
+		{
+			name:   "repeated lines",
+			base:   "l0\nl1\nl2\nl3\n",
+			ours:   "}\n}\nl2\nl3\n",
+			theirs: "l0\nins1\n}\nl2\nins2\nl3\n",
+			want:   "<<<<<<< ours\n}\n=======\nl0\nins1\n>>>>>>> theirs\n}\nl2\nins2\nl3\n",
+
+			wantConflicts: 1,
+		},
+		{
+			name:   "missing final newline",
+			base:   "a\nb",
+			ours:   "a\nB",
+			theirs: "a\nX",
+			want:   "a\n<<<<<<< ours\nB\n=======\nX\n>>>>>>> theirs\n",
+
+			wantConflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Merge([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs), labels)
+			if string(got.Content) != tt.want {
+				t.Errorf("Merge() content = %q, want %q", got.Content, tt.want)
+			}
+			if got.Conflicts != tt.wantConflicts {
+				t.Errorf("Merge() conflicts = %d, want %d", got.Conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package merge
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+// Trees are the files of the common ancestor and of both sides of a
+// merge, by path
+type Trees struct {
+	Base, Ours, Theirs map[string]*hashing.SHA
+}
+
+// PathConflict is a conflict about where a file belongs, rather than
+// about its contents. Stages are the versions of the file that are
+// recorded in the index: the ancestor's, ours and theirs.
+type PathConflict struct {
+	// Message explains the conflict. Conflicts that involve several
+	// paths only have it on one of them.
+	Message string
+	Stages  [3]*hashing.SHA
+}
+
+// DirectoryRenames says what happens to a file that one side added to a
+// directory that the other side renamed, like merge.directoryRenames
+type DirectoryRenames int
+
+const (
+	// DirectoryRenamesConflict moves the file to the renamed directory,
+	// but as a conflict, so it gets looked at. This is git's default.
+	DirectoryRenamesConflict DirectoryRenames = iota
+	DirectoryRenamesTrue
+	DirectoryRenamesFalse
+)
+
+// DirectoryRenamesFromConfig reads merge.directoryRenames
+func DirectoryRenamesFromConfig(cfg config.GitConfig) DirectoryRenames {
+	value, _ := cfg.Get("merge", "directoryRenames")
+	if strings.ToLower(value) == "conflict" {
+		return DirectoryRenamesConflict
+	}
+	if enabled, ok := cfg.GetBool("merge", "directoryRenames"); ok {
+		if enabled {
+			return DirectoryRenamesTrue
+		}
+		return DirectoryRenamesFalse
+	}
+	return DirectoryRenamesConflict
+}
+
+// FollowRenames lines up the files that one side renamed with the same
+// files on the other side, so the changes of both sides can be merged
+// path by path:
+//
+//   - When one side renamed a file that the other side changed, both
+//     versions end up at the new path, and the old path is removed.
+//   - When one side renamed a directory, files that the other side added
+//     to it are moved along.
+//
+// Renames that can't be lined up, like a file renamed differently on
+// both sides, or renamed on one side and deleted on the other, are
+// returned as conflicts by path. So are files moved along with a
+// directory, unless mode is DirectoryRenamesTrue.
+func FollowRenames(trees Trees, contents func(sha *hashing.SHA) ([]byte, error), mode DirectoryRenames, labels Labels) (Trees, map[string]PathConflict, error) {
+	ourRenames, err := diff.DetectRenames(trees.Base, trees.Ours, contents, diff.DefaultRenameScore)
+	if err != nil {
+		return Trees{}, nil, err
+	}
+	theirRenames, err := diff.DetectRenames(trees.Base, trees.Theirs, contents, diff.DefaultRenameScore)
+	if err != nil {
+		return Trees{}, nil, err
+	}
+
+	aligned := Trees{Base: maps.Clone(trees.Base), Ours: maps.Clone(trees.Ours), Theirs: maps.Clone(trees.Theirs)}
+	conflicts := map[string]PathConflict{}
+	ourTargets, theirTargets := renameTargets(ourRenames), renameTargets(theirRenames)
+	sources := slices.Sorted(maps.Keys(ourTargets))
+	for from := range theirTargets {
+		if _, ok := ourTargets[from]; !ok {
+			sources = append(sources, from)
+		}
+	}
+	slices.Sort(sources)
+
+	for _, from := range sources {
+		base := trees.Base[from]
+		ourPath, ourRenamed := ourTargets[from]
+		theirPath, theirRenamed := theirTargets[from]
+		switch {
+		case ourRenamed && theirRenamed && ourPath == theirPath:
+			delete(aligned.Base, from)
+			aligned.Base[ourPath] = base
+		case ourRenamed && theirRenamed:
+			conflicts[from] = PathConflict{
+				Message: fmt.Sprintf("CONFLICT (rename/rename): %s renamed to %s in %s and to %s in %s.", from, ourPath, labels.Ours, theirPath, labels.Theirs),
+				Stages:  [3]*hashing.SHA{base, nil, nil},
+			}
+			conflicts[ourPath] = PathConflict{Stages: [3]*hashing.SHA{nil, trees.Ours[ourPath], nil}}
+			conflicts[theirPath] = PathConflict{Stages: [3]*hashing.SHA{nil, nil, trees.Theirs[theirPath]}}
+		case theirRenamed:
+			if _, ok := trees.Ours[theirPath]; ok {
+				// We added another file there, so it isn't lined up
+				continue
+			}
+			ours, ok := trees.Ours[from]
+			delete(aligned.Base, from)
+			aligned.Base[theirPath] = base
+			if !ok {
+				conflicts[theirPath] = PathConflict{
+					Message: fmt.Sprintf("CONFLICT (rename/delete): %s renamed to %s in %s, but deleted in %s.", from, theirPath, labels.Theirs, labels.Ours),
+					Stages:  [3]*hashing.SHA{base, nil, trees.Theirs[theirPath]},
+				}
+				continue
+			}
+			aligned.Ours[theirPath] = ours
+			// Removing our version from the old path is their change
+			aligned.Base[from] = ours
+		case ourRenamed:
+			if _, ok := trees.Theirs[ourPath]; ok {
+				continue
+			}
+			theirs, ok := trees.Theirs[from]
+			delete(aligned.Base, from)
+			aligned.Base[ourPath] = base
+			if !ok {
+				conflicts[ourPath] = PathConflict{
+					Message: fmt.Sprintf("CONFLICT (rename/delete): %s renamed to %s in %s, but deleted in %s.", from, ourPath, labels.Ours, labels.Theirs),
+					Stages:  [3]*hashing.SHA{base, trees.Ours[ourPath], nil},
+				}
+				continue
+			}
+			delete(aligned.Theirs, from)
+			aligned.Theirs[ourPath] = theirs
+		}
+	}
+
+	if mode == DirectoryRenamesFalse {
+		return aligned, conflicts, nil
+	}
+	ourDirs := directoryRenames(trees.Base, trees.Ours, ourRenames)
+	theirDirs := directoryRenames(trees.Base, trees.Theirs, theirRenames)
+	// Where a directory went is unclear if both sides renamed it
+	for dir := range ourDirs {
+		if _, ok := theirDirs[dir]; ok {
+			delete(ourDirs, dir)
+			delete(theirDirs, dir)
+		}
+	}
+
+	// Files they added to a directory that we renamed
+	for _, name := range addedFiles(trees.Base, trees.Theirs, trees.Ours, theirRenames) {
+		newPath, ok := renamedPath(ourDirs, name)
+		if !ok || inUse(aligned, newPath) {
+			continue
+		}
+		sha := trees.Theirs[name]
+		delete(aligned.Theirs, name)
+		aligned.Theirs[newPath] = sha
+		if mode == DirectoryRenamesConflict {
+			conflicts[newPath] = PathConflict{
+				Message: fileLocationMessage(name, labels.Theirs, labels.Ours, newPath),
+				Stages:  [3]*hashing.SHA{nil, nil, sha},
+			}
+		}
+	}
+
+	// Files we added to a directory that they renamed. Moving them is a
+	// change on their side.
+	for _, name := range addedFiles(trees.Base, trees.Ours, trees.Theirs, ourRenames) {
+		newPath, ok := renamedPath(theirDirs, name)
+		if !ok || inUse(aligned, newPath) {
+			continue
+		}
+		sha := trees.Ours[name]
+		aligned.Base[name] = sha
+		delete(aligned.Theirs, name)
+		aligned.Theirs[newPath] = sha
+		if mode == DirectoryRenamesConflict {
+			conflicts[newPath] = PathConflict{
+				Message: fileLocationMessage(name, labels.Ours, labels.Theirs, newPath),
+				Stages:  [3]*hashing.SHA{nil, sha, nil},
+			}
+		}
+	}
+	return aligned, conflicts, nil
+}
+
+func renameTargets(renames []diff.Rename) map[string]string {
+	targets := map[string]string{}
+	for _, r := range renames {
+		targets[r.From] = r.To
+	}
+	return targets
+}
+
+func fileLocationMessage(name, addedIn, renamedIn, newPath string) string {
+	return fmt.Sprintf("CONFLICT (file location): %s added in %s inside a directory that was renamed in %s, suggesting it should perhaps be moved to %s.", name, addedIn, renamedIn, newPath)
+}
+
+// addedFiles returns the files that side added, which aren't renamed
+// files and which the other side doesn't have
+func addedFiles(base, side, other map[string]*hashing.SHA, renames []diff.Rename) []string {
+	renamed := map[string]bool{}
+	for _, r := range renames {
+		renamed[r.To] = true
+	}
+	added := []string{}
+	for name := range side {
+		_, inBase := base[name]
+		_, inOther := other[name]
+		if !inBase && !inOther && !renamed[name] {
+			added = append(added, name)
+		}
+	}
+	slices.Sort(added)
+	return added
+}
+
+func inUse(trees Trees, name string) bool {
+	_, inOurs := trees.Ours[name]
+	_, inTheirs := trees.Theirs[name]
+	return inOurs || inTheirs
+}
+
+// directoryRenames finds the directories of base that side removed, and
+// where most of their files went, like git. A rename of a/b/c/file to
+// x/y/c/file suggests that a/b/c became x/y/c and a/b became x/y, but
+// nothing about a. Directories whose files went to several places
+// equally often aren't renamed.
+func directoryRenames(base, side map[string]*hashing.SHA, renames []diff.Rename) map[string]string {
+	sideDirs := directories(side)
+	removed := map[string]bool{}
+	for dir := range directories(base) {
+		if !sideDirs[dir] {
+			removed[dir] = true
+		}
+	}
+
+	counts := map[string]map[string]int{}
+	for _, r := range renames {
+		oldDir, newDir := parentDir(r.From), parentDir(r.To)
+		for oldDir != newDir && removed[oldDir] {
+			if counts[oldDir] == nil {
+				counts[oldDir] = map[string]int{}
+			}
+			counts[oldDir][newDir]++
+			if newDir == "" || path.Base(oldDir) != path.Base(newDir) {
+				break
+			}
+			oldDir, newDir = parentDir(oldDir), parentDir(newDir)
+		}
+	}
+
+	renamed := map[string]string{}
+	for oldDir, targets := range counts {
+		best, bestCount, tie := "", 0, false
+		for newDir, count := range targets {
+			switch {
+			case count > bestCount:
+				best, bestCount, tie = newDir, count, false
+			case count == bestCount:
+				tie = true
+			}
+		}
+		if !tie {
+			renamed[oldDir] = best
+		}
+	}
+	return renamed
+}
+
+// renamedPath returns where name goes if the deepest of its directories
+// that was renamed moved
+func renamedPath(renames map[string]string, name string) (string, bool) {
+	for dir := parentDir(name); dir != ""; dir = parentDir(dir) {
+		if newDir, ok := renames[dir]; ok {
+			return path.Join(newDir, name[len(dir)+1:]), true
+		}
+	}
+	return "", false
+}
+
+// directories returns all directories that contain the files, except the
+// top one
+func directories(files map[string]*hashing.SHA) map[string]bool {
+	dirs := map[string]bool{}
+	for name := range files {
+		for dir := parentDir(name); dir != "" && !dirs[dir]; dir = parentDir(dir) {
+			dirs[dir] = true
+		}
+	}
+	return dirs
+}
+
+// parentDir is the directory of a path, or "" for the top directory
+func parentDir(name string) string {
+	dir := path.Dir(name)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
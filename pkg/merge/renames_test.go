@@ -0,0 +1,218 @@
+package merge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/diff"
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+// renameTree hashes file contents into a tree, and remembers them in blobs
+func renameTree(files map[string]string, blobs map[string][]byte) map[string]*hashing.SHA {
+	tree := map[string]*hashing.SHA{}
+	for name, data := range files {
+		sha := hashing.NewSHA([]byte(data))
+		blobs[sha.AsString()] = []byte(data)
+		tree[name] = sha
+	}
+	return tree
+}
+
+// treeContents turns a tree back into contents, to compare it
+func treeContents(tree map[string]*hashing.SHA, blobs map[string][]byte) map[string]string {
+	files := map[string]string{}
+	for name, sha := range tree {
+		files[name] = string(blobs[sha.AsString()])
+	}
+	return files
+}
+
+func TestFollowRenames(t *testing.T) {
+	labels := Labels{Ours: "ours", Theirs: "theirs"}
+	tests := []struct {
+		name              string
+		base, ours, their map[string]string
+		mode              DirectoryRenames
+		// want are the aligned base, ours and theirs
+		want          [3]map[string]string
+		wantConflicts map[string]string
+	}{
+		{
+			name:  "they renamed a file we changed",
+			base:  map[string]string{"a": "one\n"},
+			ours:  map[string]string{"a": "two\n"},
+			their: map[string]string{"b": "one\n"},
+			want: [3]map[string]string{
+				{"a": "two\n", "b": "one\n"},
+				{"a": "two\n", "b": "two\n"},
+				{"b": "one\n"},
+			},
+			wantConflicts: map[string]string{},
+		},
+		{
+			name:  "we renamed a file they changed",
+			base:  map[string]string{"a": "one\n"},
+			ours:  map[string]string{"b": "one\n"},
+			their: map[string]string{"a": "two\n"},
+			want: [3]map[string]string{
+				{"b": "one\n"},
+				{"b": "one\n"},
+				{"b": "two\n"},
+			},
+			wantConflicts: map[string]string{},
+		},
+		{
+			name:  "rename/delete",
+			base:  map[string]string{"a": "one\n"},
+			ours:  map[string]string{},
+			their: map[string]string{"b": "one\n"},
+			want: [3]map[string]string{
+				{"b": "one\n"},
+				{},
+				{"b": "one\n"},
+			},
+			wantConflicts: map[string]string{
+				"b": "CONFLICT (rename/delete): a renamed to b in theirs, but deleted in ours.",
+			},
+		},
+		{
+			name:  "rename/rename",
+			base:  map[string]string{"a": "one\n"},
+			ours:  map[string]string{"b": "one\n"},
+			their: map[string]string{"c": "one\n"},
+			want: [3]map[string]string{
+				{"a": "one\n"},
+				{"b": "one\n"},
+				{"c": "one\n"},
+			},
+			wantConflicts: map[string]string{
+				"a": "CONFLICT (rename/rename): a renamed to b in ours and to c in theirs.",
+				"b": "",
+				"c": "",
+			},
+		},
+		{
+			name:  "file added to a renamed directory",
+			base:  map[string]string{"old/f1": "1\n", "old/f2": "2\n"},
+			ours:  map[string]string{"old/f1": "1\n", "old/f2": "2\n", "old/mine": "mine\n"},
+			their: map[string]string{"new/f1": "1\n", "new/f2": "2\n"},
+			mode:  DirectoryRenamesTrue,
+			want: [3]map[string]string{
+				{"new/f1": "1\n", "new/f2": "2\n", "old/f1": "1\n", "old/f2": "2\n", "old/mine": "mine\n"},
+				{"new/f1": "1\n", "new/f2": "2\n", "old/f1": "1\n", "old/f2": "2\n", "old/mine": "mine\n"},
+				{"new/f1": "1\n", "new/f2": "2\n", "new/mine": "mine\n"},
+			},
+			wantConflicts: map[string]string{},
+		},
+		{
+			name:  "file added to a renamed directory conflicts",
+			base:  map[string]string{"old/f1": "1\n", "old/f2": "2\n"},
+			ours:  map[string]string{"new/f1": "1\n", "new/f2": "2\n"},
+			their: map[string]string{"old/f1": "1\n", "old/f2": "2\n", "old/theirs": "theirs\n"},
+			want: [3]map[string]string{
+				{"new/f1": "1\n", "new/f2": "2\n"},
+				{"new/f1": "1\n", "new/f2": "2\n"},
+				{"new/f1": "1\n", "new/f2": "2\n", "new/theirs": "theirs\n"},
+			},
+			wantConflicts: map[string]string{
+				"new/theirs": "CONFLICT (file location): old/theirs added in theirs inside a directory that was renamed in ours, suggesting it should perhaps be moved to new/theirs.",
+			},
+		},
+		{
+			name:  "directory renames disabled",
+			base:  map[string]string{"old/f1": "1\n", "old/f2": "2\n"},
+			ours:  map[string]string{"new/f1": "1\n", "new/f2": "2\n"},
+			their: map[string]string{"old/f1": "1\n", "old/f2": "2\n", "old/theirs": "theirs\n"},
+			mode:  DirectoryRenamesFalse,
+			want: [3]map[string]string{
+				{"new/f1": "1\n", "new/f2": "2\n"},
+				{"new/f1": "1\n", "new/f2": "2\n"},
+				{"new/f1": "1\n", "new/f2": "2\n", "old/theirs": "theirs\n"},
+			},
+			wantConflicts: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := map[string][]byte{}
+			trees := Trees{Base: renameTree(tt.base, blobs), Ours: renameTree(tt.ours, blobs), Theirs: renameTree(tt.their, blobs)}
+			contents := func(sha *hashing.SHA) ([]byte, error) { return blobs[sha.AsString()], nil }
+			aligned, conflicts, err := FollowRenames(trees, contents, tt.mode, labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := [3]map[string]string{treeContents(aligned.Base, blobs), treeContents(aligned.Ours, blobs), treeContents(aligned.Theirs, blobs)}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FollowRenames() trees = %v, want %v", got, tt.want)
+			}
+			messages := map[string]string{}
+			for name, c := range conflicts {
+				messages[name] = c.Message
+			}
+			if !reflect.DeepEqual(messages, tt.wantConflicts) {
+				t.Errorf("FollowRenames() conflicts = %v, want %v", messages, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestDirectoryRenames(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    []string
+		side    []string
+		renames map[string]string
+		want    map[string]string
+	}{
+		{
+			name:    "moved directory",
+			base:    []string{"a/b/c/f1", "a/b/c/f2"},
+			side:    []string{"x/y/c/f1", "x/y/c/f2"},
+			renames: map[string]string{"a/b/c/f1": "x/y/c/f1", "a/b/c/f2": "x/y/c/f2"},
+			want:    map[string]string{"a/b/c": "x/y/c", "a/b": "x/y"},
+		},
+		{
+			name:    "split evenly",
+			base:    []string{"d/f1", "d/f2"},
+			side:    []string{"e/f1", "g/f2"},
+			renames: map[string]string{"d/f1": "e/f1", "d/f2": "g/f2"},
+			want:    map[string]string{},
+		},
+		{
+			name:    "most files win",
+			base:    []string{"d/f1", "d/f2", "d/f3"},
+			side:    []string{"e/f1", "e/f2", "g/f3"},
+			renames: map[string]string{"d/f1": "e/f1", "d/f2": "e/f2", "d/f3": "g/f3"},
+			want:    map[string]string{"d": "e"},
+		},
+		{
+			name:    "directory still exists",
+			base:    []string{"d/f1", "d/f2"},
+			side:    []string{"e/f1", "d/f2"},
+			renames: map[string]string{"d/f1": "e/f1"},
+			want:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := func(names []string) map[string]*hashing.SHA {
+				tree := map[string]*hashing.SHA{}
+				for _, name := range names {
+					tree[name] = hashing.NewSHA([]byte(name))
+				}
+				return tree
+			}
+			renames := []diff.Rename{}
+			for from, to := range tt.renames {
+				renames = append(renames, diff.Rename{From: from, To: to})
+			}
+			got := directoryRenames(files(tt.base), files(tt.side), renames)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("directoryRenames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Package metadata summarizes a repository for static sites, like the
+// project pages of gitweb: its description, branches, tags, recent
+// commits and contributors.
+package metadata
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/mailmap"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/revwalk"
+)
+
+// defaultDescription is what new repositories have in their description
+// file, which says nothing
+const defaultDescription = "Unnamed repository; edit this file 'description' to name the repository."
+
+// Summary is what is known about a repository
+type Summary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Head is the branch HEAD is on, or "" when it is detached
+	Head         string        `json:"head"`
+	Branches     []Ref         `json:"branches"`
+	Tags         []Ref         `json:"tags"`
+	Commits      []Commit      `json:"commits"`
+	Contributors []Contributor `json:"contributors"`
+}
+
+// Ref is a branch or a tag, with the commit it points to
+type Ref struct {
+	Name string `json:"name"`
+	// Object is what the ref points to, which for annotated tags is the
+	// tag
+	Object  string    `json:"object"`
+	Commit  string    `json:"commit"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+}
+
+// Commit is a commit of the history of HEAD
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Parents []string  `json:"parents"`
+	Author  Person    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+type Person struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Contributor is an author of the history of HEAD, after the mailmap
+type Contributor struct {
+	Person
+	Commits int `json:"commits"`
+}
+
+// Options say how much history goes into a summary
+type Options struct {
+	// Commits is how many of the most recent commits are listed
+	Commits int
+}
+
+// Collect summarizes a repository. Refs that don't point to commits,
+// like tags of trees, are left out.
+func Collect(repo *repository.Repository, opts Options) (*Summary, error) {
+	s := &Summary{
+		Name:         repoName(repo),
+		Branches:     []Ref{},
+		Tags:         []Ref{},
+		Commits:      []Commit{},
+		Contributors: []Contributor{},
+	}
+	description, err := os.ReadFile(repo.RepositoryPath("description"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if d := strings.TrimSpace(string(description)); d != defaultDescription {
+		s.Description = d
+	}
+	if target, ok := references.Reference("HEAD").Target(repo); ok {
+		s.Head = strings.TrimPrefix(target.String(), "refs/heads/")
+	}
+
+	store := references.NewRefStore(repo)
+	for _, list := range []struct {
+		prefix string
+		refs   *[]Ref
+	}{{"refs/heads/", &s.Branches}, {"refs/tags/", &s.Tags}} {
+		refs, err := store.List(list.prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			r, ok, err := readRef(repo, ref, list.prefix)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				*list.refs = append(*list.refs, r)
+			}
+		}
+	}
+	// The branches that changed last go first, and so do the newest tags
+	for _, refs := range [][]Ref{s.Branches, s.Tags} {
+		sort.SliceStable(refs, func(i, j int) bool { return refs[i].Date.After(refs[j].Date) })
+	}
+
+	head, err := references.Reference("HEAD").Resolve(repo)
+	if err != nil || head == "" {
+		// Without commits, there is no history
+		return s, nil
+	}
+	sha, err := hashing.NewShaFromHex(head)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.readHistory(repo, sha, opts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// repoName returns the name of the directory of a repository, without
+// the .git of bare repositories
+func repoName(repo *repository.Repository) string {
+	dir := filepath.Clean(repo.WorkTree())
+	if filepath.Base(dir) == ".git" {
+		dir = filepath.Dir(dir)
+	}
+	return strings.TrimSuffix(filepath.Base(dir), ".git")
+}
+
+// readRef reads the commit a ref points to. ok is false if it doesn't
+// point to one.
+func readRef(repo *repository.Repository, ref references.Ref, prefix string) (Ref, bool, error) {
+	sha, err := hashing.NewShaFromHex(ref.SHA)
+	if err != nil {
+		return Ref{}, false, err
+	}
+	peeled, err := objects.Peel(repo, sha, objects.TypeNoTypeSpecified)
+	if err != nil {
+		return Ref{}, false, err
+	}
+	obj, err := objects.ReadObject(repo, peeled)
+	if err != nil {
+		return Ref{}, false, err
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return Ref{}, false, nil
+	}
+	c := newCommit(peeled, commit)
+	return Ref{
+		Name:    strings.TrimPrefix(ref.Name.String(), prefix),
+		Object:  ref.SHA,
+		Commit:  c.Hash,
+		Subject: c.Subject,
+		Date:    c.Date,
+	}, true, nil
+}
+
+// readHistory walks the history of head: the first commits are listed,
+// and all of them count for the contributors
+func (s *Summary) readHistory(repo *repository.Repository, head *hashing.SHA, opts Options) error {
+	mm, err := mailmap.Load(repo)
+	if err != nil {
+		return err
+	}
+	walker, err := revwalk.New(repo, head)
+	if err != nil {
+		return err
+	}
+	counts := map[Person]int{}
+	order := []Person{}
+	for {
+		c, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		commit := newCommit(c.SHA, c.Commit)
+		if len(s.Commits) < opts.Commits {
+			s.Commits = append(s.Commits, commit)
+		}
+		name, email := mm.Resolve(commit.Author.Name, commit.Author.Email)
+		person := Person{Name: name, Email: email}
+		if counts[person] == 0 {
+			order = append(order, person)
+		}
+		counts[person]++
+	}
+	// The most commits go first, then by name like git shortlog -n
+	sort.SliceStable(order, func(i, j int) bool {
+		if counts[order[i]] != counts[order[j]] {
+			return counts[order[i]] > counts[order[j]]
+		}
+		return order[i].Name < order[j].Name
+	})
+	for _, person := range order {
+		s.Contributors = append(s.Contributors, Contributor{Person: person, Commits: counts[person]})
+	}
+	return nil
+}
+
+func newCommit(sha *hashing.SHA, commit *objects.Commit) Commit {
+	author, _ := commit.GetValue("author")
+	ident := objects.ParseIdent(author)
+	subject, _, _ := strings.Cut(strings.TrimLeft(commit.Message(), "\n"), "\n")
+	c := Commit{
+		Hash:    sha.AsString(),
+		Parents: []string{},
+		Author:  Person{Name: ident.Name, Email: ident.Email},
+		Date:    ident.When,
+		Subject: subject,
+	}
+	for _, parent := range commit.GetValues("parent") {
+		c.Parents = append(c.Parents, string(parent))
+	}
+	return c
+}
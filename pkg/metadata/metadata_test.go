@@ -0,0 +1,179 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// commitBy makes a commit by an author at a time
+func commitBy(t *testing.T, repo *repository.Repository, name, email string, when int64, message string, parents ...*hashing.SHA) *hashing.SHA {
+	t.Helper()
+	tree := objects.EmptyTreeSHA()
+	ident := objects.Ident{Name: name, Email: email, When: time.Unix(when, 0).UTC()}
+	sha, err := objects.CreateCommit(repo, tree, parents, ident, ident, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func updateRef(t *testing.T, repo *repository.Repository, name string, sha *hashing.SHA) {
+	t.Helper()
+	if err := references.Update(repo, references.Reference(name), sha.AsString()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testRepo has a main branch of three commits by two people, one under
+// two emails that the mailmap joins, a topic branch and two tags
+func testRepo(t *testing.T) (*repository.Repository, []*hashing.SHA) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "project")
+	repo, err := repository.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitBy(t, repo, "Jane", "jane@old.example.com", 100, "First\n\nWith a body")
+	second := commitBy(t, repo, "Joe", "joe@example.com", 200, "Second", first)
+	third := commitBy(t, repo, "Jane", "jane@example.com", 300, "Third", second)
+	topic := commitBy(t, repo, "Joe", "joe@example.com", 400, "Topic", first)
+	updateRef(t, repo, "refs/heads/main", third)
+	if err := references.UpdateSymbolic(repo, "HEAD", "refs/heads/main"); err != nil {
+		t.Fatal(err)
+	}
+	updateRef(t, repo, "refs/heads/topic", topic)
+	updateRef(t, repo, "refs/tags/light", first)
+
+	tag := "object " + second.AsString() + "\ntype commit\ntag v1\ntagger Jane <jane@example.com> 250 +0000\n\nv1\n"
+	tagSHA, err := objects.ObjectHash([]byte(tag), objects.TypeTag, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateRef(t, repo, "refs/tags/v1", tagSHA)
+	tree := objects.EmptyTreeSHA()
+	updateRef(t, repo, "refs/tags/tree", tree)
+
+	if err := os.WriteFile(filepath.Join(dir, ".mailmap"), []byte("<jane@example.com> <jane@old.example.com>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(repo.RepositoryPath("description"), []byte("A test project\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return repo, []*hashing.SHA{first, second, third, topic, tagSHA}
+}
+
+func TestCollect(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, shas := testRepo(t)
+	first, second, third, topic, tag := shas[0], shas[1], shas[2], shas[3], shas[4]
+
+	s, err := Collect(repo, Options{Commits: 2})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if s.Name != "project" || s.Description != "A test project" || s.Head != "main" {
+		t.Errorf("Collect() = %q, %q on %q, want project, A test project on main", s.Name, s.Description, s.Head)
+	}
+
+	refName := func(refs []Ref) []string {
+		names := []string{}
+		for _, r := range refs {
+			names = append(names, r.Name+"="+r.Commit[:7])
+		}
+		return names
+	}
+	// The ones that changed last go first
+	wantBranches := []string{"topic=" + topic.AsString()[:7], "main=" + third.AsString()[:7]}
+	if got := refName(s.Branches); !reflect.DeepEqual(got, wantBranches) {
+		t.Errorf("Collect() branches = %v, want %v", got, wantBranches)
+	}
+	// The tag of a tree is left out, and annotated tags are peeled
+	wantTags := []string{"v1=" + second.AsString()[:7], "light=" + first.AsString()[:7]}
+	if got := refName(s.Tags); !reflect.DeepEqual(got, wantTags) {
+		t.Errorf("Collect() tags = %v, want %v", got, wantTags)
+	}
+	if s.Tags[0].Object != tag.AsString() || s.Tags[0].Subject != "Second" {
+		t.Errorf("Collect() tag v1 = %+v", s.Tags[0])
+	}
+
+	if len(s.Commits) != 2 || s.Commits[0].Hash != third.AsString() || s.Commits[1].Parents[0] != first.AsString() {
+		t.Errorf("Collect() commits = %+v, want third and second", s.Commits)
+	}
+	wantContributors := []Contributor{
+		{Person{"Jane", "jane@example.com"}, 2},
+		{Person{"Joe", "joe@example.com"}, 1},
+	}
+	if !reflect.DeepEqual(s.Contributors, wantContributors) {
+		t.Errorf("Collect() contributors = %v, want %v", s.Contributors, wantContributors)
+	}
+}
+
+func TestCollectEmpty(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Collect(repo, Options{Commits: 10})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	// The description new repositories get says nothing
+	if s.Description != "" || len(s.Branches) != 0 || len(s.Commits) != 0 || len(s.Contributors) != 0 {
+		t.Errorf("Collect() of an empty repository = %+v", s)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, _ := testRepo(t)
+	s, err := Collect(repo, Options{Commits: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Summary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded.Contributors, s.Contributors) {
+		t.Errorf("WriteJSON() = %s, error %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := WriteHTML(&buf, s, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<title>project</title>", "main (HEAD)", "<td>Third</td>", "<td>Jane</td><td>2</td>"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteHTML() has no %q:\n%s", want, buf.String())
+		}
+	}
+
+	// Templates of one's own get the functions, and escaping
+	tmpl, err := ParseTemplate(`{{range .Commits}}{{short .Hash}} {{date .Date}} {{.Subject}}|{{end}}{{.Description}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Description = "<b>"
+	buf.Reset()
+	if err := WriteHTML(&buf, s, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	want := s.Commits[0].Hash[:7] + " 1970-01-01 Third|" + s.Commits[1].Hash[:7] + " 1970-01-01 Second|" + s.Commits[2].Hash[:7] + " 1970-01-01 First|&lt;b&gt;"
+	if buf.String() != want {
+		t.Errorf("WriteHTML() with a template = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"time"
+)
+
+// Funcs are the functions that templates can use besides the builtins
+var Funcs = template.FuncMap{
+	"short": func(hash string) string { return hash[:min(len(hash), 7)] },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+}
+
+// DefaultTemplate is the page that summaries are rendered to without a
+// template of one's own
+const DefaultTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+{{with .Description}}<p>{{.}}</p>
+{{end}}
+<h2>Branches</h2>
+<table>
+{{range .Branches}}<tr><td>{{.Name}}{{if eq .Name $.Head}} (HEAD){{end}}</td><td>{{short .Commit}}</td><td>{{date .Date}}</td><td>{{.Subject}}</td></tr>
+{{end}}</table>
+<h2>Tags</h2>
+<table>
+{{range .Tags}}<tr><td>{{.Name}}</td><td>{{short .Commit}}</td><td>{{date .Date}}</td><td>{{.Subject}}</td></tr>
+{{end}}</table>
+<h2>Recent commits</h2>
+<table>
+{{range .Commits}}<tr><td>{{short .Hash}}</td><td>{{date .Date}}</td><td>{{.Author.Name}}</td><td>{{.Subject}}</td></tr>
+{{end}}</table>
+<h2>Contributors</h2>
+<table>
+{{range .Contributors}}<tr><td>{{.Name}}</td><td>{{.Commits}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+// ParseTemplate parses the text of a template, which can use Funcs. Its
+// output is escaped as HTML.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("metadata").Funcs(Funcs).Parse(text)
+}
+
+// WriteHTML renders a summary with a template, or with DefaultTemplate
+// if it is nil
+func WriteHTML(w io.Writer, s *Summary, tmpl *template.Template) error {
+	if tmpl == nil {
+		var err error
+		if tmpl, err = ParseTemplate(DefaultTemplate); err != nil {
+			return err
+		}
+	}
+	return tmpl.Execute(w, s)
+}
+
+// WriteJSON writes a summary as indented JSON
+func WriteJSON(w io.Writer, s *Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
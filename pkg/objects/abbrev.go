@@ -0,0 +1,40 @@
+package objects
+
+import (
+	"strconv"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// DefaultAbbrev is the minimum length of abbreviated object names
+const DefaultAbbrev = 7
+
+// AbbrevLength returns the minimum length of abbreviated object names,
+// from core.abbrev
+func AbbrevLength(repo *repository.Repository) int {
+	cfg, err := repo.Config()
+	if err != nil {
+		return DefaultAbbrev
+	}
+	value, ok := cfg.Get("core", "abbrev")
+	if !ok {
+		return DefaultAbbrev
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 4 {
+		return DefaultAbbrev
+	}
+	return min(n, 40)
+}
+
+// ShortSHA abbreviates sha to the shortest prefix of at least minLen
+// characters that no other object in the repository starts with
+func ShortSHA(repo *repository.Repository, sha *hashing.SHA, minLen int) string {
+	hexSha := sha.AsString()
+	length := max(minLen, 4)
+	if idx, err := loadOIDIndex(repo, sha.AsBytes()[0]); err == nil {
+		length = max(length, idx.shortest(hexSha))
+	}
+	return hexSha[:min(length, len(hexSha))]
+}
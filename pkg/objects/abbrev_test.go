@@ -0,0 +1,90 @@
+package objects
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+func TestShortSHA(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	sha, err := WriteObject(&Blob{data: []byte("abbreviate me")}, repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+	hexSha := sha.AsString()
+
+	// A packed object that shares the first 9 characters
+	similar := []byte(hexSha[:9] + "0000000000000000000000000000000")
+	if similar[9] == hexSha[9] {
+		similar[9] = '1'
+	}
+	other, err := hashing.NewShaFromHex(string(similar))
+	if err != nil {
+		t.Fatalf("NewShaFromHex() error = %v", err)
+	}
+	packDir := filepath.Join(repo.GitDir(), "objects", "pack")
+	if err := os.MkdirAll(packDir, 0o755); err != nil {
+		t.Fatalf("Failed to create pack dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(packDir, "pack-1.idx"), buildPackIndex(2, other), 0o644); err != nil {
+		t.Fatalf("Failed to write pack index: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		sha    *hashing.SHA
+		minLen int
+		want   string
+	}{
+		{"conflict in pack", sha, 7, hexSha[:10]},
+		{"longer than conflict", sha, 12, hexSha[:12]},
+		{"too short", sha, 2, hexSha[:10]},
+		{"full length", sha, 40, hexSha},
+		{"packed object", other, 7, string(similar[:10])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShortSHA(repo, tt.sha, tt.minLen); got != tt.want {
+				t.Errorf("ShortSHA() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAbbrevLength(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", DefaultAbbrev},
+		{"12", 12},
+		{"2", DefaultAbbrev},
+		{"auto", DefaultAbbrev},
+		{"50", 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg := "[core]\n\trepositoryformatversion = 0\n"
+			if tt.value != "" {
+				cfg += "\tabbrev = " + tt.value + "\n"
+			}
+			if err := os.WriteFile(filepath.Join(repo.GitDir(), "config"), []byte(cfg), 0o644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			repo.Invalidate(filepath.Join(repo.GitDir(), "config"))
+			if got := AbbrevLength(repo); got != tt.want {
+				t.Errorf("AbbrevLength() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package objects
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Candidate is one of the objects an ambiguous name could refer to
+type Candidate struct {
+	SHA  string
+	Type GitObjectType
+	// Short is the shortest unambiguous abbreviation of SHA
+	Short string
+	// Description is the date and subject of commits, and the date
+	// and name of tags
+	Description string
+}
+
+// AmbiguousError is returned by Find when a name matches several objects
+type AmbiguousError struct {
+	Name       string
+	Candidates []Candidate
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("short object ID %s is ambiguous", e.Name)
+}
+
+// Hint lists the candidates like git does, e.g.
+//
+//	hint: The candidates are:
+//	hint:   1234567 commit 2024-01-02 - Fix the frobnicator
+//	hint:   1234568 blob
+func (e *AmbiguousError) Hint() string {
+	var b strings.Builder
+	b.WriteString("hint: The candidates are:\n")
+	for _, c := range e.Candidates {
+		line := fmt.Sprintf("hint:   %s %s", c.Short, c.Type)
+		if c.Description != "" {
+			line += " " + c.Description
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// typeOrder is the order in which git lists candidates
+var typeOrder = map[GitObjectType]int{TypeTag: 0, TypeCommit: 1, TypeTree: 2, TypeBlob: 3}
+
+func newAmbiguousError(repo *repository.Repository, name string, shas []string) *AmbiguousError {
+	e := &AmbiguousError{Name: name}
+	for _, hexSha := range shas {
+		c := Candidate{SHA: hexSha, Short: hexSha}
+		if sha, err := hashing.NewShaFromHex(hexSha); err == nil {
+			c.Short = ShortSHA(repo, sha, AbbrevLength(repo))
+			c.Type, _, _ = ReadHeader(repo, sha)
+			c.Description = describeCandidate(repo, sha, c.Type)
+		}
+		e.Candidates = append(e.Candidates, c)
+	}
+	sort.Slice(e.Candidates, func(i, j int) bool {
+		a, b := e.Candidates[i], e.Candidates[j]
+		if typeOrder[a.Type] != typeOrder[b.Type] {
+			return typeOrder[a.Type] < typeOrder[b.Type]
+		}
+		return a.SHA < b.SHA
+	})
+	return e
+}
+
+// describeCandidate returns "<date> - <subject>" for commits and
+// "<date> - <name>" for tags
+func describeCandidate(repo *repository.Repository, sha *hashing.SHA, typ GitObjectType) string {
+	if typ != TypeCommit && typ != TypeTag {
+		return ""
+	}
+	obj, err := ReadObject(repo, sha)
+	if err != nil {
+		return ""
+	}
+
+	switch obj := obj.(type) {
+	case *Commit:
+		committer, _ := obj.GetValue("committer")
+		subject, _, _ := strings.Cut(strings.TrimSpace(obj.Message()), "\n")
+		return ParseIdent(committer).When.Format("2006-01-02") + " - " + subject
+	case *Tag:
+		tagger, _ := obj.GetValue("tagger")
+		name, _ := obj.GetValue("tag")
+		return ParseIdent(tagger).When.Format("2006-01-02") + " - " + string(name)
+	}
+	return ""
+}
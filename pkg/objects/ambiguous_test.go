@@ -0,0 +1,72 @@
+package objects
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/kvlm"
+)
+
+func TestFindAmbiguous(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	// Write blobs until two of them share a 4 character prefix
+	seen := map[string]string{}
+	var prefix string
+	for i := 0; prefix == ""; i++ {
+		sha, err := WriteObject(&Blob{data: []byte(strconv.Itoa(i))}, repo)
+		if err != nil {
+			t.Fatalf("WriteObject() error = %v", err)
+		}
+		p := sha.AsString()[:4]
+		if _, ok := seen[p]; ok {
+			prefix = p
+		}
+		seen[p] = sha.AsString()
+	}
+
+	_, err := Find(repo, prefix, TypeNoTypeSpecified, true)
+	var ambiguous *AmbiguousError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("Find() error = %v, want an AmbiguousError", err)
+	}
+	if ambiguous.Name != prefix || len(ambiguous.Candidates) != 2 {
+		t.Fatalf("Find() error = %+v, want 2 candidates for %s", ambiguous, prefix)
+	}
+	for _, c := range ambiguous.Candidates {
+		if c.Type != TypeBlob || len(c.Short) < DefaultAbbrev || c.SHA[:len(c.Short)] != c.Short {
+			t.Errorf("unexpected candidate %+v", c)
+		}
+	}
+}
+
+func TestAmbiguousErrorHint(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	data := kvlm.New()
+	data.Okv.Set("tree", []byte("4b825dc642cb6eb9a060e54bf8d69288fbee4904"))
+	data.Okv.Set("committer", []byte("C O Mitter <c@example.com> 1700000000 +0000"))
+	data.Message = []byte("Fix the frobnicator\n\nDetails.\n")
+	commit, err := WriteObject(NewCommit(data), repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+	blob, err := WriteObject(&Blob{data: []byte("blob")}, repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+
+	e := newAmbiguousError(repo, "abcd", []string{blob.AsString(), commit.AsString()})
+	want := "hint: The candidates are:\n" +
+		"hint:   " + commit.AsString()[:7] + " commit 2023-11-14 - Fix the frobnicator\n" +
+		"hint:   " + blob.AsString()[:7] + " blob\n"
+	if got := e.Hint(); got != want {
+		t.Errorf("Hint() = %q, want %q", got, want)
+	}
+	if got := e.Error(); got != "short object ID abcd is ambiguous" {
+		t.Errorf("Error() = %q", got)
+	}
+}
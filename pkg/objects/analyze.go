@@ -0,0 +1,236 @@
+package objects
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Analysis says what takes up space among the objects of a repository,
+// and what packing them saves
+type Analysis struct {
+	// Types has the number and size of the objects of each type, in the
+	// order commits, trees, blobs and tags
+	Types []TypeStats
+	// Biggest are the largest objects, largest first
+	Biggest []ObjectSize
+	// Duplicates are the blobs that are at more than one path, which are
+	// only stored once, the ones that save the most first
+	Duplicates []Duplicate
+	// Groups are the objects that are packed as deltas of each other, by
+	// the object at the start of their chains, the ones that save the
+	// most first
+	Groups []DeltaGroup
+	// Size is the size of all objects, and DeltaSize what they take with
+	// deltas, before they are compressed
+	Size      int64
+	DeltaSize int64
+}
+
+// TypeStats are the number and size of the objects of a type
+type TypeStats struct {
+	Type  GitObjectType
+	Count int
+	Size  int64
+}
+
+// ObjectSize is an object with its size, and a path it is at, if it is a
+// blob or tree that a commit has
+type ObjectSize struct {
+	SHA  string
+	Type GitObjectType
+	Size int64
+	Path string
+}
+
+// Duplicate is a blob that is at more than one path
+type Duplicate struct {
+	SHA   string
+	Size  int64
+	Paths []string
+}
+
+// Saved is how much storing the blob once saves
+func (d Duplicate) Saved() int64 {
+	return d.Size * int64(len(d.Paths)-1)
+}
+
+// DeltaGroup is a set of objects that are stored as deltas, of Base and
+// of each other. Size is the size of all of them, Base included, and
+// DeltaSize what they take with deltas.
+type DeltaGroup struct {
+	Base      ObjectSize
+	Count     int
+	Size      int64
+	DeltaSize int64
+}
+
+// Saved is how much the deltas of the group save
+func (g DeltaGroup) Saved() int64 {
+	return g.Size - g.DeltaSize
+}
+
+// AnalyzeOptions change what Analyze reports
+type AnalyzeOptions struct {
+	// WriteOptions are the ones the objects would be packed with, which
+	// decide what deltas are made
+	pack.WriteOptions
+	// Top is how many of the biggest objects, duplicates and delta groups
+	// are reported. Zero reports all of them.
+	Top int
+}
+
+// Analyze reports on the objects with the given hex names, like the
+// reachable ones: how many of each type there are, which are the largest,
+// and which blobs and deltas save space in a pack. The deltas are the ones
+// pack.Write would make.
+func Analyze(repo *repository.Repository, shas []string, opts AnalyzeOptions) (*Analysis, error) {
+	analysis := &Analysis{}
+	objects := make([]pack.Object, 0, len(shas))
+	sizes := map[string]ObjectSize{}
+	commits := []*Commit{}
+	for _, name := range slices.Sorted(slices.Values(shas)) {
+		sha, err := hashing.NewShaFromHex(name)
+		if err != nil {
+			return nil, err
+		}
+		objType, data, err := ReadRaw(repo, sha)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		objects = append(objects, pack.Object{Name: sha.AsBytes(), Type: packType(objType), Data: data})
+		sizes[name] = ObjectSize{SHA: name, Type: GitObjectType(objType), Size: int64(len(data))}
+		if GitObjectType(objType) == TypeCommit {
+			commit := &Commit{}
+			if err := commit.Deserialize(data); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", name, err)
+			}
+			commits = append(commits, commit)
+		}
+	}
+
+	// The paths come from the trees of the commits. A tree that is at the
+	// same path in many commits is only read once.
+	paths := map[string][]string{}
+	visited := map[string]bool{}
+	var walk func(sha, prefix string) error
+	walk = func(sha, prefix string) error {
+		if visited[sha+" "+prefix] {
+			return nil
+		}
+		visited[sha+" "+prefix] = true
+		if prefix != "" {
+			paths[sha] = append(paths[sha], prefix)
+		}
+		hash, err := hashing.NewShaFromHex(sha)
+		if err != nil {
+			return err
+		}
+		obj, err := ReadObject(repo, hash)
+		if err != nil {
+			return err
+		}
+		tree, ok := obj.(*Tree)
+		if !ok {
+			return fmt.Errorf("%s is not a tree", sha)
+		}
+		for _, leaf := range tree.Items {
+			name := path.Join(prefix, string(leaf.Path))
+			switch LeafType(leaf) {
+			case TypeTree:
+				if err := walk(leaf.Sha.AsString(), name); err != nil {
+					return err
+				}
+			case TypeBlob:
+				if !slices.Contains(paths[leaf.Sha.AsString()], name) {
+					paths[leaf.Sha.AsString()] = append(paths[leaf.Sha.AsString()], name)
+				}
+			}
+		}
+		return nil
+	}
+	for _, commit := range commits {
+		tree, _ := commit.GetValue("tree")
+		if _, ok := sizes[string(tree)]; !ok {
+			continue
+		}
+		if err := walk(string(tree), ""); err != nil {
+			return nil, err
+		}
+	}
+	for name, objPaths := range paths {
+		sort.Strings(objPaths)
+		if size, ok := sizes[name]; ok {
+			size.Path = objPaths[0]
+			sizes[name] = size
+		}
+	}
+
+	analysis.Types = []TypeStats{{Type: TypeCommit}, {Type: TypeTree}, {Type: TypeBlob}, {Type: TypeTag}}
+	byType := map[GitObjectType]*TypeStats{}
+	for i := range analysis.Types {
+		byType[analysis.Types[i].Type] = &analysis.Types[i]
+	}
+	for _, size := range sizes {
+		if stats, ok := byType[size.Type]; ok {
+			stats.Count++
+			stats.Size += size.Size
+		}
+		analysis.Size += size.Size
+		analysis.Biggest = append(analysis.Biggest, size)
+		if objPaths := paths[size.SHA]; size.Type == TypeBlob && len(objPaths) > 1 {
+			analysis.Duplicates = append(analysis.Duplicates, Duplicate{SHA: size.SHA, Size: size.Size, Paths: objPaths})
+		}
+	}
+	sort.Slice(analysis.Biggest, func(i, j int) bool {
+		a, b := analysis.Biggest[i], analysis.Biggest[j]
+		return a.Size > b.Size || a.Size == b.Size && a.SHA < b.SHA
+	})
+	sort.Slice(analysis.Duplicates, func(i, j int) bool {
+		a, b := analysis.Duplicates[i], analysis.Duplicates[j]
+		return a.Saved() > b.Saved() || a.Saved() == b.Saved() && a.SHA < b.SHA
+	})
+
+	// Deltas are against objects that come before them, so the start of
+	// the chain of a base is known before the deltas on it
+	analysis.DeltaSize = analysis.Size
+	starts := map[string]string{}
+	groups := map[string]*DeltaGroup{}
+	for _, delta := range pack.Deltas(objects, opts.WriteOptions) {
+		name, base := hex.EncodeToString(delta.Name), hex.EncodeToString(delta.Base)
+		start, ok := starts[base]
+		if !ok {
+			start = base
+		}
+		starts[name] = start
+		group, ok := groups[start]
+		if !ok {
+			group = &DeltaGroup{Base: sizes[start], Count: 1, Size: sizes[start].Size, DeltaSize: sizes[start].Size}
+			groups[start] = group
+		}
+		group.Count++
+		group.Size += sizes[name].Size
+		group.DeltaSize += int64(delta.Size)
+		analysis.DeltaSize -= sizes[name].Size - int64(delta.Size)
+	}
+	for _, group := range groups {
+		analysis.Groups = append(analysis.Groups, *group)
+	}
+	sort.Slice(analysis.Groups, func(i, j int) bool {
+		a, b := analysis.Groups[i], analysis.Groups[j]
+		return a.Saved() > b.Saved() || a.Saved() == b.Saved() && a.Base.SHA < b.Base.SHA
+	})
+
+	if opts.Top > 0 {
+		analysis.Biggest = analysis.Biggest[:min(opts.Top, len(analysis.Biggest))]
+		analysis.Duplicates = analysis.Duplicates[:min(opts.Top, len(analysis.Duplicates))]
+		analysis.Groups = analysis.Groups[:min(opts.Top, len(analysis.Groups))]
+	}
+	return analysis, nil
+}
@@ -0,0 +1,76 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func TestAnalyze(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	write := func(obj GitObject) *hashing.SHA {
+		sha, err := WriteObject(obj, repo)
+		if err != nil {
+			t.Fatalf("WriteObject() error = %v", err)
+		}
+		return sha
+	}
+	content := bytes.Repeat([]byte("a line of a file that changes a little\n"), 100)
+	versions := []*hashing.SHA{}
+	for i := range 3 {
+		versions = append(versions, write(&Blob{data: append(append([]byte{}, content...), fmt.Sprintf("version %d\n", i)...)}))
+	}
+	small := write(&Blob{data: []byte("small\n")})
+	leaf := func(mode, name string, sha *hashing.SHA) *TreeLeaf {
+		return &TreeLeaf{Mode: []byte(mode), Path: []byte(name), Sha: sha}
+	}
+	sub := write(&Tree{Items: []*TreeLeaf{leaf("100644", "copy", small)}})
+	shas := []*hashing.SHA{small, sub}
+	var parent []*hashing.SHA
+	for _, version := range versions {
+		tree := write(&Tree{Items: []*TreeLeaf{leaf("100644", "file", version), leaf("100644", "small", small), leaf("40000", "sub", sub)}})
+		ident := Ident{Name: "A U Thor", Email: "author@example.com", When: time.Unix(1700000000, 0).UTC()}
+		commit, err := CreateCommit(repo, tree, parent, ident, ident, "commit")
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent = []*hashing.SHA{commit}
+		shas = append(shas, version, tree, commit)
+	}
+	names := []string{}
+	for _, sha := range shas {
+		names = append(names, sha.AsString())
+	}
+
+	analysis, err := Analyze(repo, names, AnalyzeOptions{WriteOptions: pack.DefaultWriteOptions, Top: 2})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	counts := []int{}
+	for _, stats := range analysis.Types {
+		counts = append(counts, stats.Count)
+	}
+	if want := []int{3, 4, 4, 0}; !slices.Equal(counts, want) {
+		t.Errorf("Analyze() counts = %v, want %v", counts, want)
+	}
+	if len(analysis.Biggest) != 2 || analysis.Biggest[0].Type != TypeBlob || analysis.Biggest[0].Path != "file" {
+		t.Errorf("Analyze() biggest = %+v, want the versions of file", analysis.Biggest)
+	}
+	if len(analysis.Duplicates) != 1 || analysis.Duplicates[0].SHA != small.AsString() ||
+		!slices.Equal(analysis.Duplicates[0].Paths, []string{"small", "sub/copy"}) {
+		t.Errorf("Analyze() duplicates = %+v, want the small blob at both its paths", analysis.Duplicates)
+	}
+	if len(analysis.Groups) == 0 || analysis.Groups[0].Count != 3 || analysis.Groups[0].Base.Path != "file" {
+		t.Errorf("Analyze() groups = %+v, want the three versions of file first", analysis.Groups)
+	}
+	if analysis.DeltaSize >= analysis.Size-int64(len(content)) {
+		t.Errorf("Analyze() deltas take %d of %d bytes, want them to save a version of file", analysis.DeltaSize, analysis.Size)
+	}
+}
@@ -1,6 +1,15 @@
 package objects
 
-import "github.com/jessegeens/got/pkg/kvlm"
+import (
+	"bytes"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/kvlm"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/signature"
+	"github.com/jessegeens/got/pkg/stripspace"
+)
 
 type Commit struct {
 	data *kvlm.Kvlm
@@ -35,3 +44,81 @@ func (c *Commit) GetValue(key string) ([]byte, bool) {
 func NewCommit(data *kvlm.Kvlm) *Commit {
 	return &Commit{data: data}
 }
+
+// CleanupMessage tidies a commit message like git does: trailing
+// whitespace and blank lines at the start and end are removed, and runs
+// of blank lines become one. With stripComments, lines starting with #
+// are removed too. A message that isn't empty ends with a newline.
+func CleanupMessage(message string, stripComments bool) string {
+	if stripComments {
+		return stripspace.Strip(message, stripspace.DefaultCommentPrefix)
+	}
+	return stripspace.Strip(message, "")
+}
+
+// CreateCommit writes a commit of tree. The commit is validated first, so
+// it fails if the tree or a parent is missing or an identity is malformed.
+func CreateCommit(repo *repository.Repository, tree *hashing.SHA, parents []*hashing.SHA, author, committer Ident, message string) (*hashing.SHA, error) {
+	return CreateSignedCommit(repo, tree, parents, author, committer, message, nil)
+}
+
+// CreateSignedCommit is CreateCommit, with a signature of the commit by
+// signer in the gpgsig header. A nil signer makes an unsigned commit.
+func CreateSignedCommit(repo *repository.Repository, tree *hashing.SHA, parents []*hashing.SHA, author, committer Ident, message string, signer signature.Signer) (*hashing.SHA, error) {
+	data := kvlm.New()
+
+	data.Okv.Set("tree", []byte(tree.AsString()))
+
+	for _, parent := range parents {
+		data.Okv.Set("parent", []byte(parent.AsString()))
+	}
+
+	message = strings.TrimSpace(message) + "\n"
+	data.Message = []byte(message)
+
+	data.Okv.Set("author", []byte(author.String()))
+	data.Okv.Set("committer", []byte(committer.String()))
+
+	if signer != nil {
+		sig, err := signer.Sign([]byte(data.Serialize()))
+		if err != nil {
+			return nil, err
+		}
+		data.Okv.Set("gpgsig", bytes.TrimSuffix(sig, []byte("\n")))
+	}
+
+	commit := NewCommit(data)
+	if err := ValidateCommit(repo, commit); err != nil {
+		return nil, err
+	}
+	return WriteObject(commit, repo)
+}
+
+// Signature returns the payload that was signed and the detached signature
+// stored in the gpgsig header. The last return value is false if the
+// commit is not signed.
+func (c *Commit) Signature() ([]byte, []byte, bool) {
+	sig, ok := c.data.Okv.Get("gpgsig")
+	if !ok {
+		return nil, nil, false
+	}
+
+	// The payload is the commit itself, without the gpgsig header
+	payload := kvlm.New()
+	for _, key := range c.data.Okv.Keys() {
+		if key == "gpgsig" {
+			continue
+		}
+		for _, val := range c.data.Okv.GetAll(key) {
+			payload.Okv.Set(key, val)
+		}
+	}
+	payload.Message = c.data.Message
+
+	return []byte(payload.Serialize()), sig, true
+}
+
+// GetValues returns every value of a key that can occur multiple times, such as "parent"
+func (c *Commit) GetValues(key string) [][]byte {
+	return c.data.Okv.GetAll(key)
+}
@@ -0,0 +1,115 @@
+package objects
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommit_Signature(t *testing.T) {
+	raw := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author Alice <alice@example.com> 1700000000 +0000\n" +
+		"committer Alice <alice@example.com> 1700000000 +0000\n" +
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n \n c2lnbmF0dXJl\n -----END PGP SIGNATURE-----\n" +
+		"\nSigned commit\n"
+	wantPayload := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author Alice <alice@example.com> 1700000000 +0000\n" +
+		"committer Alice <alice@example.com> 1700000000 +0000\n" +
+		"\nSigned commit\n"
+	wantSig := "-----BEGIN PGP SIGNATURE-----\n\nc2lnbmF0dXJl\n-----END PGP SIGNATURE-----"
+
+	commit := &Commit{}
+	if err := commit.Deserialize([]byte(raw)); err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+
+	payload, sig, ok := commit.Signature()
+	if !ok {
+		t.Fatal("Expected commit to be signed")
+	}
+	if string(payload) != wantPayload {
+		t.Errorf("payload = %q, want %q", payload, wantPayload)
+	}
+	if string(sig) != wantSig {
+		t.Errorf("signature = %q, want %q", sig, wantSig)
+	}
+
+	unsigned := &Commit{}
+	if err := unsigned.Deserialize([]byte(wantPayload)); err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+	if _, _, ok := unsigned.Signature(); ok {
+		t.Error("Expected unsigned commit to have no signature")
+	}
+}
+
+func TestTag_Signature(t *testing.T) {
+	payload := "object 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"type commit\n" +
+		"tag v1.0\n" +
+		"tagger Alice <alice@example.com> 1700000000 +0000\n" +
+		"\nRelease v1.0\n"
+	sig := "-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----\n"
+
+	tag := &Tag{}
+	if err := tag.Deserialize([]byte(payload + sig)); err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+
+	gotPayload, gotSig, ok := tag.Signature()
+	if !ok {
+		t.Fatal("Expected tag to be signed")
+	}
+	if string(gotPayload) != payload {
+		t.Errorf("payload = %q, want %q", gotPayload, payload)
+	}
+	if string(gotSig) != sig {
+		t.Errorf("signature = %q, want %q", gotSig, sig)
+	}
+}
+
+func TestCommit_SignatureOfMerge(t *testing.T) {
+	payload := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"parent 1111111111111111111111111111111111111111\n" +
+		"parent 2222222222222222222222222222222222222222\n" +
+		"author Alice <alice@example.com> 1700000000 +0000\n" +
+		"committer Alice <alice@example.com> 1700000000 +0000\n" +
+		"\nMerge branch 'topic'\n"
+	raw := strings.Replace(payload, "\n\nMerge", "\ngpgsig -----BEGIN PGP SIGNATURE-----\n \n c2lnbmF0dXJl\n -----END PGP SIGNATURE-----\n\nMerge", 1)
+
+	commit := &Commit{}
+	if err := commit.Deserialize([]byte(raw)); err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+	got, _, ok := commit.Signature()
+	if !ok {
+		t.Fatal("Expected commit to be signed")
+	}
+	// Both parents are signed, each on its own line
+	if string(got) != payload {
+		t.Errorf("payload = %q, want %q", got, payload)
+	}
+}
+
+func TestCleanupMessage(t *testing.T) {
+	tests := []struct {
+		name          string
+		message       string
+		stripComments bool
+		want          string
+	}{
+		{"plain", "subject", false, "subject\n"},
+		{"whitespace", "\n\nsubject  \n\n\n\nbody\t\n\n", false, "subject\n\nbody\n"},
+		{"comments kept", "subject\n# note\n", false, "subject\n# note\n"},
+		{"comments stripped", "subject\n\n# Please enter the commit message\n#\n", true, "subject\n"},
+		{"comment between paragraphs", "subject\n\n# note\n\nbody\n", true, "subject\n\nbody\n"},
+		{"only comments", "# nothing\n\n", true, ""},
+		{"empty", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanupMessage(tt.message, tt.stripComments); got != tt.want {
+				t.Errorf("CleanupMessage(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
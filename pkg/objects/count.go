@@ -0,0 +1,167 @@
+package objects
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// ObjectCounts describes the object database, like git count-objects
+type ObjectCounts struct {
+	// Count is the number of loose objects, and Size the space they take
+	// on disk
+	Count int
+	Size  int64
+	// InPack is the number of objects in the Packs, and SizePack the size
+	// of their .pack and .idx files
+	InPack   int
+	Packs    int
+	SizePack int64
+	// PrunePackable is the number of loose objects that are in a pack too
+	PrunePackable int
+	// Garbage are the files that are neither objects nor packs, and
+	// SizeGarbage their size
+	Garbage     []Garbage
+	SizeGarbage int64
+}
+
+// Garbage is a file in the object database that doesn't belong there
+type Garbage struct {
+	Path string
+	// Reason is what is wrong with it, in the words of git
+	Reason string
+}
+
+// packExtensions are the files that can go with a pack
+var packExtensions = []string{".idx", ".pack", ".bitmap", ".keep", ".promisor", ".mtimes", ".rev"}
+
+// CountObjects counts the loose objects and the packs of the repository,
+// and finds the garbage among them. Files in the fan-out directories that
+// aren't named like objects are garbage, and so are the files in the pack
+// directory that are no part of a pack with an index.
+func CountObjects(repo *repository.Repository) (ObjectCounts, error) {
+	counts := ObjectCounts{}
+	packs, err := pack.List(repo)
+	if err != nil {
+		return counts, err
+	}
+	for _, p := range packs {
+		packInfo, err := os.Stat(p.Path)
+		if err != nil {
+			// An index without its pack is garbage
+			continue
+		}
+		counts.Packs++
+		counts.InPack += p.Index.Count()
+		counts.SizePack += packInfo.Size()
+		if idxInfo, err := os.Stat(strings.TrimSuffix(p.Path, ".pack") + ".idx"); err == nil {
+			counts.SizePack += idxInfo.Size()
+		}
+	}
+
+	if err := counts.packGarbage(repo); err != nil {
+		return counts, err
+	}
+	for fanout := range 256 {
+		prefix := hexByte(fanout)
+		dir := repo.RepositoryPath("objects", prefix)
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return counts, err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return counts, err
+			}
+			sha, err := hashing.NewShaFromHex(prefix + e.Name())
+			if e.IsDir() || len(e.Name()) != 38 || err != nil || sha.AsString() != prefix+e.Name() {
+				counts.addGarbage(filepath.Join(dir, e.Name()), "garbage found", info)
+				continue
+			}
+			counts.Count++
+			counts.Size += fs.DiskUsage(info)
+			for _, p := range packs {
+				if p.Index.Contains(sha.AsBytes()) {
+					counts.PrunePackable++
+					break
+				}
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (c *ObjectCounts) addGarbage(path, reason string, info os.FileInfo) {
+	c.Garbage = append(c.Garbage, Garbage{Path: path, Reason: reason})
+	c.SizeGarbage += info.Size()
+}
+
+// packGarbage finds the garbage in the pack directory. The files of a
+// pack go together by name, and are garbage unless there are both a
+// .pack and an .idx.
+func (c *ObjectCounts) packGarbage(repo *repository.Repository) error {
+	dir := repo.RepositoryPath("objects", "pack")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	groups := map[string][]os.FileInfo{}
+	names := []string{}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		name := e.Name()
+		if name == "multi-pack-index" || strings.HasPrefix(name, "multi-pack-index-") {
+			continue
+		}
+		ext := filepath.Ext(name)
+		if !slices.Contains(packExtensions, ext) {
+			c.addGarbage(filepath.Join(dir, name), "garbage found", info)
+			continue
+		}
+		base := strings.TrimSuffix(name, ext)
+		if _, ok := groups[base]; !ok {
+			names = append(names, base)
+		}
+		groups[base] = append(groups[base], info)
+	}
+	slices.Sort(names)
+	for _, base := range names {
+		hasPack, hasIdx := false, false
+		for _, info := range groups[base] {
+			hasPack = hasPack || filepath.Ext(info.Name()) == ".pack"
+			hasIdx = hasIdx || filepath.Ext(info.Name()) == ".idx"
+		}
+		reason := ""
+		switch {
+		case hasPack && hasIdx:
+			continue
+		case hasPack:
+			reason = "no corresponding .idx"
+		case hasIdx:
+			reason = "no corresponding .pack"
+		default:
+			reason = "no corresponding .idx or .pack"
+		}
+		for _, info := range groups[base] {
+			c.addGarbage(filepath.Join(dir, info.Name()), reason, info)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func TestCountObjects(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	objects := []pack.Object{}
+	for i := range 3 {
+		data := []byte(fmt.Sprintf("packed blob %d\n", i))
+		objects = append(objects, pack.Object{Name: hashing.NewSHA(append([]byte(fmt.Sprintf("blob %d\x00", len(data))), data...)).AsBytes(), Type: pack.TypeBlob, Data: data})
+	}
+	var stream bytes.Buffer
+	if _, _, err := pack.Write(&stream, objects, pack.DefaultWriteOptions); err != nil {
+		t.Fatal(err)
+	}
+	name, err := IndexPack(repo, bytes.NewReader(stream.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One loose object is in the pack too
+	for _, data := range []string{"loose\n", "packed blob 0\n"} {
+		encoded := []byte(fmt.Sprintf("blob %d\x00%s", len(data), data))
+		if err := writeLoose(repo, hashing.NewSHA(encoded), encoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+	packDir := repo.RepositoryPath("objects", "pack")
+	garbage := map[string]string{
+		filepath.Join(packDir, "junk"):                        "garbage",
+		filepath.Join(packDir, "pack-lone.keep"):              "keep",
+		filepath.Join(packDir, name+".keep"):                  "",
+		repo.RepositoryPath("objects", "ab", "not-an-object"): "x",
+	}
+	for path, contents := range garbage {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts, err := CountObjects(repo)
+	if err != nil {
+		t.Fatalf("CountObjects() error = %v", err)
+	}
+	if counts.Count != 2 || counts.Size == 0 {
+		t.Errorf("CountObjects() = %d loose objects of %d bytes, want 2", counts.Count, counts.Size)
+	}
+	packInfo, _ := os.Stat(filepath.Join(packDir, name+".pack"))
+	idxInfo, _ := os.Stat(filepath.Join(packDir, name+".idx"))
+	if counts.Packs != 1 || counts.InPack != 3 || counts.SizePack != packInfo.Size()+idxInfo.Size() {
+		t.Errorf("CountObjects() = %d objects in %d packs of %d bytes, want 3 in 1", counts.InPack, counts.Packs, counts.SizePack)
+	}
+	if counts.PrunePackable != 1 {
+		t.Errorf("CountObjects() prune-packable = %d, want 1", counts.PrunePackable)
+	}
+	want := []Garbage{
+		{filepath.Join(packDir, "junk"), "garbage found"},
+		{filepath.Join(packDir, "pack-lone.keep"), "no corresponding .idx or .pack"},
+		{repo.RepositoryPath("objects", "ab", "not-an-object"), "garbage found"},
+	}
+	if !reflect.DeepEqual(counts.Garbage, want) {
+		t.Errorf("CountObjects() garbage = %v, want %v", counts.Garbage, want)
+	}
+	if counts.SizeGarbage != int64(len("garbage")+len("keep")+len("x")) {
+		t.Errorf("CountObjects() size of the garbage = %d", counts.SizeGarbage)
+	}
+}
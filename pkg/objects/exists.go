@@ -0,0 +1,29 @@
+package objects
+
+import (
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// HasObject reports whether the repository contains an object, either as
+// a loose object or in a pack. Nothing is inflated, so this is much cheaper
+// than reading the object.
+func HasObject(repo *repository.Repository, sha *hashing.SHA) bool {
+	hexSha := sha.AsString()
+	if fs.IsFile(repo.RepositoryPath("objects", hexSha[0:2], hexSha[2:])) {
+		return true
+	}
+
+	packs, err := pack.List(repo)
+	if err != nil {
+		return false
+	}
+	for _, p := range packs {
+		if p.Index.Contains(sha.AsBytes()) {
+			return true
+		}
+	}
+	return false
+}
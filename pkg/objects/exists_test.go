@@ -0,0 +1,131 @@
+package objects
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+// buildPackIndex creates a pack index of the given version with the objects
+func buildPackIndex(version int, shas ...*hashing.SHA) []byte {
+	names := [][]byte{}
+	for _, sha := range shas {
+		names = append(names, sha.AsBytes())
+	}
+	sort.Slice(names, func(i, j int) bool { return bytes.Compare(names[i], names[j]) < 0 })
+
+	data := []byte{}
+	if version == 2 {
+		data = append(data, 0xff, 't', 'O', 'c')
+		data = binary.BigEndian.AppendUint32(data, 2)
+	}
+	for i := 0; i < 256; i++ {
+		count := 0
+		for _, name := range names {
+			if int(name[0]) <= i {
+				count++
+			}
+		}
+		data = binary.BigEndian.AppendUint32(data, uint32(count))
+	}
+	for _, name := range names {
+		if version == 1 {
+			data = binary.BigEndian.AppendUint32(data, 0)
+		}
+		data = append(data, name...)
+	}
+	if version == 2 {
+		// The CRCs and offsets, which HasObject doesn't look at
+		data = append(data, make([]byte, len(names)*8)...)
+	}
+	// The checksum of the pack, which isn't looked at either, and of the index
+	data = append(data, make([]byte, sha1.Size)...)
+	sum := sha1.Sum(data)
+	return append(data, sum[:]...)
+}
+
+func TestHasObject(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	loose, err := WriteObject(&Blob{data: []byte("loose")}, repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+	packed1, _ := CalculateSha(&Blob{data: []byte("packed in v1")})
+	packed2, _ := CalculateSha(&Blob{data: []byte("packed in v2")})
+	other, _ := CalculateSha(&Blob{data: []byte("packed nowhere")})
+	missing, _ := CalculateSha(&Blob{data: []byte("missing")})
+
+	packDir := filepath.Join(repo.GitDir(), "objects", "pack")
+	if err := os.MkdirAll(packDir, 0o755); err != nil {
+		t.Fatalf("Failed to create pack dir: %v", err)
+	}
+	indexes := map[string][]byte{
+		"pack-1.idx": buildPackIndex(1, packed1),
+		"pack-2.idx": buildPackIndex(2, other, packed2),
+	}
+	for name, data := range indexes {
+		if err := os.WriteFile(filepath.Join(packDir, name), data, 0o644); err != nil {
+			t.Fatalf("Failed to write pack index: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		sha  *hashing.SHA
+		want bool
+	}{
+		{"loose object", loose, true},
+		{"in version 1 index", packed1, true},
+		{"in version 2 index", packed2, true},
+		{"missing object", missing, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasObject(repo, tt.sha); got != tt.want {
+				t.Errorf("HasObject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteObjectSkipsExisting(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	blob := &Blob{data: []byte("written once")}
+	hash, err := WriteObject(blob, repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+
+	objPath := filepath.Join(repo.GitDir(), "objects", hash.AsString()[:2], hash.AsString()[2:])
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(objPath, old, old); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	again, err := WriteObject(blob, repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+	if again.AsString() != hash.AsString() {
+		t.Errorf("WriteObject() = %s, want %s", again.AsString(), hash.AsString())
+	}
+	info, err := os.Stat(objPath)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("existing object was rewritten")
+	}
+}
@@ -0,0 +1,59 @@
+package objects
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// ReadHeader returns the type and size of an object, loose or packed.
+// Only the header is inflated, which is much cheaper than ReadObject for large objects.
+func ReadHeader(repo *repository.Repository, sha *hashing.SHA) (GitObjectType, int, error) {
+	hexSha := sha.AsString()
+	f, err := os.Open(repo.RepositoryPath("objects", hexSha[0:2], hexSha[2:]))
+	if errors.Is(err, os.ErrNotExist) {
+		objType, size, packErr := packedHeader(repo, sha)
+		if errors.Is(packErr, pack.ErrNotFound) && hexSha == EmptyTree {
+			return TypeTree, 0, nil
+		} else if errors.Is(packErr, pack.ErrNotFound) {
+			return "", 0, err
+		}
+		return objType, size, packErr
+	} else if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	zlibReader, err := zlib.NewReader(f)
+	if err != nil {
+		return "", 0, errors.New("failed to open file: " + err.Error())
+	}
+	defer zlibReader.Close()
+
+	header, err := bufio.NewReader(zlibReader).ReadString(0x00)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed object %s: %w", hexSha, err)
+	}
+	objType, size, ok := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed object %s, bad header", hexSha)
+	}
+
+	typ, err := ParseType(objType)
+	if err != nil {
+		return "", 0, err
+	}
+	length, err := strconv.Atoi(size)
+	if err != nil {
+		return "", 0, errors.New("invalid object size " + size)
+	}
+	return typ, length, nil
+}
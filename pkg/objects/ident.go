@@ -0,0 +1,52 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/gitdate"
+)
+
+// Ident is the identity in the author, committer and tagger headers
+type Ident struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// ParseIdent parses "Name <email> timestamp zone". Missing
+// parts are left empty, so ParseIdent never fails.
+func ParseIdent(data []byte) Ident {
+	id := Ident{}
+	start := bytes.IndexByte(data, '<')
+	end := bytes.LastIndexByte(data, '>')
+	if start < 0 || end < start {
+		id.Name = string(bytes.TrimSpace(data))
+		return id
+	}
+	id.Name = string(bytes.TrimSpace(data[:start]))
+	id.Email = string(data[start+1 : end])
+
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) > 0 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			id.When = time.Unix(seconds, 0).UTC()
+		}
+	}
+	if len(fields) > 1 {
+		if zone, err := time.Parse("-0700", fields[1]); err == nil {
+			id.When = id.When.In(zone.Location())
+		}
+	}
+	return id
+}
+
+func (id Ident) String() string {
+	return fmt.Sprintf("%s <%s> %d %s", id.Name, id.Email, id.When.Unix(), id.When.Format("-0700"))
+}
+
+// DefaultDateFormat is the layout git uses for dates by default
+const DefaultDateFormat = gitdate.DefaultLayout
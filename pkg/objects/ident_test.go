@@ -0,0 +1,47 @@
+package objects
+
+import (
+	"testing"
+)
+
+func TestParseIdent(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		email string
+		unix  int64
+		zone  string
+	}{
+		{"A U Thor <author@example.com> 1700000000 +0100", "A U Thor", "author@example.com", 1700000000, "+0100"},
+		{"Someone <s@e.x> 0 -0530", "Someone", "s@e.x", 0, "-0530"},
+		{"No Email", "No Email", "", -62135596800, "+0000"},
+	}
+
+	for _, tt := range tests {
+		id := ParseIdent([]byte(tt.input))
+		if id.Name != tt.name || id.Email != tt.email {
+			t.Errorf("ParseIdent(%q) = %q <%q>, want %q <%q>", tt.input, id.Name, id.Email, tt.name, tt.email)
+		}
+		if id.When.Unix() != tt.unix || id.When.Format("-0700") != tt.zone {
+			t.Errorf("ParseIdent(%q) time = %d %s, want %d %s", tt.input, id.When.Unix(), id.When.Format("-0700"), tt.unix, tt.zone)
+		}
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	repo := setupTreeTestRepo(t)
+	defer cleanupTreeTestRepo(t, repo)
+
+	sha, err := ObjectHash([]byte("hello\n"), TypeBlob, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typ, size, err := ReadHeader(repo, sha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != TypeBlob || size != 6 {
+		t.Errorf("ReadHeader() = %s %d, want blob 6", typ, size)
+	}
+}
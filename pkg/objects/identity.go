@@ -0,0 +1,86 @@
+package objects
+
+import (
+	"fmt"
+	"os"
+	gouser "os/user"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/gitdate"
+)
+
+// AuthorIdent returns the author to record in new objects. Like git, the
+// GIT_AUTHOR_NAME, GIT_AUTHOR_EMAIL and GIT_AUTHOR_DATE environment
+// variables take precedence over author.* and user.* in the config.
+func AuthorIdent(cfg config.GitConfig) (Ident, error) {
+	return identFor(cfg, "author", "GIT_AUTHOR")
+}
+
+// CommitterIdent returns the committer to record in new objects, from
+// GIT_COMMITTER_* and the committer.* and user.* config.
+func CommitterIdent(cfg config.GitConfig) (Ident, error) {
+	return identFor(cfg, "committer", "GIT_COMMITTER")
+}
+
+func identFor(cfg config.GitConfig, section, envPrefix string) (Ident, error) {
+	id := Ident{When: time.Now()}
+
+	id.Name = firstNonEmpty(
+		os.Getenv(envPrefix+"_NAME"),
+		configValue(cfg, section, "name"),
+		configValue(cfg, "user", "name"),
+	)
+	id.Email = firstNonEmpty(
+		os.Getenv(envPrefix+"_EMAIL"),
+		configValue(cfg, section, "email"),
+		configValue(cfg, "user", "email"),
+		os.Getenv("EMAIL"),
+	)
+
+	// Fall back to the system user, like git does
+	if id.Name == "" || id.Email == "" {
+		systemUser, err := gouser.Current()
+		username := "User"
+		if err == nil {
+			username = systemUser.Username
+		}
+		if id.Name == "" {
+			id.Name = username
+			if err == nil && systemUser.Name != "" {
+				id.Name = strings.Split(systemUser.Name, ",")[0]
+			}
+		}
+		if id.Email == "" {
+			host, err := os.Hostname()
+			if err != nil {
+				host = "localhost"
+			}
+			id.Email = username + "@" + host
+		}
+	}
+
+	if date := os.Getenv(envPrefix + "_DATE"); date != "" {
+		when, err := gitdate.Parse(date)
+		if err != nil {
+			return Ident{}, fmt.Errorf("invalid %s_DATE: %w", envPrefix, err)
+		}
+		id.When = when
+	}
+	return id, nil
+}
+
+func configValue(cfg config.GitConfig, section, key string) string {
+	value, _ := cfg.Get(section, key)
+	return value
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package objects
+
+import (
+	"testing"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+func TestIdentFromEnvironment(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "A U Thor")
+	t.Setenv("GIT_AUTHOR_EMAIL", "author@example.com")
+	t.Setenv("GIT_AUTHOR_DATE", "1700000000 +0100")
+	t.Setenv("GIT_COMMITTER_NAME", "")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+	t.Setenv("GIT_COMMITTER_DATE", "")
+
+	author, err := AuthorIdent(config.GitConfig{})
+	if err != nil {
+		t.Fatalf("AuthorIdent() error = %v", err)
+	}
+	if got := author.String(); got != "A U Thor <author@example.com> 1700000000 +0100" {
+		t.Errorf("AuthorIdent() = %q", got)
+	}
+
+	committer, err := CommitterIdent(config.GitConfig{})
+	if err != nil {
+		t.Fatalf("CommitterIdent() error = %v", err)
+	}
+	if committer.Email != "committer@example.com" || committer.Name == "" {
+		t.Errorf("CommitterIdent() = %q", committer.String())
+	}
+
+	t.Setenv("GIT_AUTHOR_DATE", "not a date")
+	if _, err := AuthorIdent(config.GitConfig{}); err == nil {
+		t.Errorf("AuthorIdent() should fail on an invalid date")
+	}
+}
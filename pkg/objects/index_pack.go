@@ -0,0 +1,41 @@
+package objects
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// IndexPack stores a pack stream, like one that came in from a fetch, in
+// the repository with the index it builds for it, and returns the name of
+// the pack. Thin packs, with deltas on objects they don't have, are
+// refused, since the stored pack has to stand on its own. With a check,
+// nothing is stored unless every object in the pack passes it. When the
+// stream is cut off, what arrived is kept for RecoverPartialPack.
+func IndexPack(repo *repository.Repository, r io.Reader, check CheckFunc) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", errors.Join(err, keepPartialPack(repo, data))
+	}
+	entries, checksum, err := pack.IndexStream(bytes.NewReader(data), nil)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", errors.Join(err, keepPartialPack(repo, data))
+	} else if err != nil {
+		return "", err
+	}
+	if !bytes.HasSuffix(data, checksum) {
+		return "", errors.New("pack has junk at the end")
+	}
+	if check != nil {
+		err := pack.Unpack(bytes.NewReader(data), nil, func(name []byte, typ pack.ObjectType, data []byte) error {
+			return checkObject(check, name, typ, data)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return storePack(repo, data, entries, checksum, nil)
+}
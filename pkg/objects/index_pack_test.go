@@ -0,0 +1,88 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func TestIndexPack(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	content := bytes.Repeat([]byte("a line that every blob has\n"), 50)
+	objects := []pack.Object{}
+	for i := range 3 {
+		data := append(append([]byte{}, content...), byte('0'+i))
+		objects = append(objects, pack.Object{Name: hashing.NewSHA(append([]byte(fmt.Sprintf("blob %d\x00", len(data))), data...)).AsBytes(), Type: pack.TypeBlob, Data: data})
+	}
+	var stream bytes.Buffer
+	if _, _, err := pack.Write(&stream, objects, pack.DefaultWriteOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := IndexPack(repo, bytes.NewReader(append(append([]byte{}, stream.Bytes()...), "junk"...)), nil); err == nil {
+		t.Errorf("IndexPack() of a pack with junk at the end succeeded")
+	}
+	name, err := IndexPack(repo, bytes.NewReader(stream.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("IndexPack() error = %v", err)
+	}
+	if !fs.IsFile(repo.RepositoryPath("objects", "pack", name+".idx")) {
+		t.Errorf("IndexPack() didn't write %s.idx", name)
+	}
+	for i, obj := range objects {
+		read, err := ReadObject(repo, hashing.NewShaFromBytes(obj.Name))
+		if err != nil {
+			t.Fatalf("ReadObject() of object %d error = %v", i, err)
+		}
+		if data, _ := read.Serialize(); !bytes.Equal(data, obj.Data) {
+			t.Errorf("ReadObject() of object %d = %q, want %q", i, data, obj.Data)
+		}
+	}
+}
+
+func TestIndexPack_TruncatedHeaders(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	check := func(sha *hashing.SHA, obj GitObject) error { return nil }
+	for _, obj := range []struct {
+		typ  pack.ObjectType
+		data string
+		ok   bool
+	}{
+		{pack.TypeCommit, "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904", false},
+		{pack.TypeTag, "object 4b825dc642cb6eb9a060e54bf8d69288fbee4904\ntype tree\ntag v1", false},
+		// Headers without a message after them are whole, like in git
+		{pack.TypeCommit, "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n", true},
+		{pack.TypeTag, "object 4b825dc642cb6eb9a060e54bf8d69288fbee4904\ntype tree\ntag v1\n", true},
+	} {
+		name := hashing.NewSHA(append([]byte(fmt.Sprintf("%s %d\x00", obj.typ, len(obj.data))), obj.data...)).AsBytes()
+		var stream bytes.Buffer
+		if _, _, err := pack.Write(&stream, []pack.Object{{Name: name, Type: obj.typ, Data: []byte(obj.data)}}, pack.DefaultWriteOptions); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := UnpackObjects(repo, bytes.NewReader(stream.Bytes()), true, check); (err == nil) != obj.ok {
+			t.Errorf("UnpackObjects() of %s %q error = %v, want an error: %v", obj.typ, obj.data, err, !obj.ok)
+		}
+		if _, err := IndexPack(repo, bytes.NewReader(stream.Bytes()), check); (err == nil) != obj.ok {
+			t.Errorf("IndexPack() of %s %q error = %v, want an error: %v", obj.typ, obj.data, err, !obj.ok)
+			continue
+		}
+		if !obj.ok {
+			continue
+		}
+		read, err := ReadObject(repo, hashing.NewShaFromBytes(name))
+		if err != nil {
+			t.Fatalf("ReadObject() error = %v", err)
+		}
+		if data, _ := read.Serialize(); string(data) != obj.data {
+			t.Errorf("ReadObject() of %s %q = %q", obj.typ, obj.data, data)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package objects
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// LooseObject is an object stored in its own file, objects/xx/yyyy...
+type LooseObject struct {
+	SHA  *hashing.SHA
+	Path string
+	// Size is the size of the compressed file
+	Size    int64
+	ModTime time.Time
+}
+
+// LooseOption changes how ForEachLoose iterates
+type LooseOption func(*looseOptions)
+
+type looseOptions struct {
+	parallelism int
+}
+
+// WithParallelism reads up to n fan-out directories at the same time.
+// fn is then called from several goroutines, in no particular order.
+func WithParallelism(n int) LooseOption {
+	return func(o *looseOptions) { o.parallelism = max(n, 1) }
+}
+
+// ForEachLoose calls fn for every loose object of the repository. Only
+// the 256 fan-out directories are read, and files in them that aren't
+// named like objects, e.g. temporary files, are skipped. Without
+// parallelism, objects come sorted by name. The first error fn returns
+// stops the iteration and is returned.
+func ForEachLoose(repo *repository.Repository, fn func(obj LooseObject) error, opts ...LooseOption) error {
+	o := looseOptions{parallelism: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dirs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	stop := make(chan struct{})
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(stop)
+		})
+	}
+
+	for range o.parallelism {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fanout := range dirs {
+				if err := forEachLooseIn(repo, fanout, fn, stop); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for fanout := range 256 {
+		select {
+		case dirs <- fanout:
+		case <-stop:
+			break feed
+		}
+	}
+	close(dirs)
+	wg.Wait()
+	return firstErr
+}
+
+// forEachLooseIn calls fn for the objects in one fan-out directory
+func forEachLooseIn(repo *repository.Repository, fanout int, fn func(obj LooseObject) error, stop <-chan struct{}) error {
+	prefix := hexByte(fanout)
+	dir := repo.RepositoryPath("objects", prefix)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		if e.IsDir() || len(e.Name()) != 38 {
+			continue
+		}
+		sha, err := hashing.NewShaFromHex(prefix + e.Name())
+		if err != nil || sha.AsString() != prefix+e.Name() {
+			continue
+		}
+		info, err := e.Info()
+		// The object may have been removed since the directory was read
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		obj := LooseObject{SHA: sha, Path: filepath.Join(dir, e.Name()), Size: info.Size(), ModTime: info.ModTime()}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hexByte(b int) string {
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[b>>4], digits[b&0xf]})
+}
@@ -0,0 +1,70 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestForEachLoose(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	want := []string{}
+	for i := range 20 {
+		sha, err := WriteObject(&Blob{data: []byte(fmt.Sprintf("blob %d", i))}, repo)
+		if err != nil {
+			t.Fatalf("WriteObject() error = %v", err)
+		}
+		want = append(want, sha.AsString())
+	}
+	slices.Sort(want)
+	// Files that aren't objects are skipped
+	if err := os.WriteFile(repo.RepositoryPath("objects", want[0][:2], "tmp_obj_123"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	err := ForEachLoose(repo, func(obj LooseObject) error {
+		if obj.Size == 0 || obj.ModTime.IsZero() {
+			t.Errorf("ForEachLoose() object %s has no size or time", obj.SHA.AsString())
+		}
+		got = append(got, obj.SHA.AsString())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachLoose() error = %v", err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ForEachLoose() = %v, want %v", got, want)
+	}
+
+	var mu sync.Mutex
+	got = []string{}
+	err = ForEachLoose(repo, func(obj LooseObject) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, obj.SHA.AsString())
+		return nil
+	}, WithParallelism(8))
+	if err != nil {
+		t.Fatalf("ForEachLoose() error = %v", err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("ForEachLoose() in parallel = %v, want %v", got, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = ForEachLoose(repo, func(obj LooseObject) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ForEachLoose() = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
@@ -0,0 +1,87 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+)
+
+// modeTypes are the modes a tree entry can have, with the type of the object
+// it points to
+var modeTypes = map[string]GitObjectType{
+	"100644": TypeBlob,
+	"100755": TypeBlob,
+	"120000": TypeBlob,
+	"160000": TypeCommit,
+	"040000": TypeTree,
+}
+
+// ParseTreeEntry parses a line as printed by ls-tree, i.e.
+// "<mode> SP <type> SP <sha> TAB <path>", into a tree entry. The mode and
+// type have to agree, and the path has to be a single path component.
+func ParseTreeEntry(line string) (*TreeLeaf, error) {
+	info, name, ok := strings.Cut(line, "\t")
+	if !ok {
+		return nil, fmt.Errorf("input format error: %s", line)
+	}
+	fields := strings.Split(info, " ")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("input format error: %s", line)
+	}
+
+	mode := fields[0]
+	if len(mode) == 5 {
+		mode = "0" + mode
+	}
+	want, ok := modeTypes[mode]
+	if !ok {
+		return nil, fmt.Errorf("invalid mode %s in line: %s", fields[0], line)
+	}
+	typ, err := ParseType(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	if typ != want {
+		return nil, fmt.Errorf("entry '%s' object type (%s) doesn't match mode type (%s)", name, typ, want)
+	}
+	sha, err := hashing.NewShaFromHex(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid object id %s in line: %s", fields[2], line)
+	}
+
+	switch {
+	case name == "" || name == "." || name == "..":
+		return nil, fmt.Errorf("invalid path '%s'", name)
+	case strings.ContainsAny(name, "/\x00"):
+		return nil, fmt.Errorf("path %s contains slash", name)
+	}
+	return &TreeLeaf{Sha: sha, Path: []byte(name), Mode: []byte(mode)}, nil
+}
+
+// LeafType returns the type of the object a tree entry points to
+func LeafType(leaf *TreeLeaf) GitObjectType {
+	mode := string(leaf.Mode)
+	if len(mode) == 5 {
+		mode = "0" + mode
+	}
+	return modeTypes[mode]
+}
+
+// NewTree builds a tree from entries, which can be in any order. Like git
+// mktree, it refuses entries with the same name.
+func NewTree(entries []*TreeLeaf) (*Tree, error) {
+	names := []string{}
+	for _, leaf := range entries {
+		names = append(names, string(leaf.Path))
+	}
+	slices.Sort(names)
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] {
+			return nil, errors.New("duplicate entry in tree: " + names[i])
+		}
+	}
+	return &Tree{Items: slices.Clone(entries)}, nil
+}
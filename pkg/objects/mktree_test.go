@@ -0,0 +1,88 @@
+package objects
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseTreeEntry(t *testing.T) {
+	sha := "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	tests := []struct {
+		name     string
+		line     string
+		wantMode string
+		wantPath string
+		wantErr  bool
+	}{
+		{name: "blob", line: "100644 blob " + sha + "\tfile.txt", wantMode: "100644", wantPath: "file.txt"},
+		{name: "executable", line: "100755 blob " + sha + "\trun.sh", wantMode: "100755", wantPath: "run.sh"},
+		{name: "tree", line: "040000 tree " + sha + "\tdir", wantMode: "040000", wantPath: "dir"},
+		{name: "unpadded tree", line: "40000 tree " + sha + "\tdir", wantMode: "040000", wantPath: "dir"},
+		{name: "submodule", line: "160000 commit " + sha + "\tsub", wantMode: "160000", wantPath: "sub"},
+		{name: "path with spaces", line: "100644 blob " + sha + "\ta b", wantMode: "100644", wantPath: "a b"},
+		{name: "no tab", line: "100644 blob " + sha + " file", wantErr: true},
+		{name: "bad mode", line: "100600 blob " + sha + "\tfile", wantErr: true},
+		{name: "type does not match mode", line: "100644 tree " + sha + "\tfile", wantErr: true},
+		{name: "unknown type", line: "100644 bolb " + sha + "\tfile", wantErr: true},
+		{name: "short sha", line: "100644 blob e69de29\tfile", wantErr: true},
+		{name: "slash in path", line: "100644 blob " + sha + "\tdir/file", wantErr: true},
+		{name: "empty path", line: "100644 blob " + sha + "\t", wantErr: true},
+		{name: "dot dot", line: "040000 tree " + sha + "\t..", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf, err := ParseTreeEntry(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTreeEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if string(leaf.Mode) != tt.wantMode || string(leaf.Path) != tt.wantPath || leaf.PrintSHA() != sha {
+				t.Errorf("ParseTreeEntry() = %s %s %s, want %s %s %s", leaf.Mode, leaf.PrintSHA(), leaf.Path, tt.wantMode, sha, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestNewTree(t *testing.T) {
+	sha := "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	parse := func(line string) *TreeLeaf {
+		leaf, err := ParseTreeEntry(line)
+		if err != nil {
+			t.Fatalf("ParseTreeEntry(%q) error = %v", line, err)
+		}
+		return leaf
+	}
+
+	tree, err := NewTree([]*TreeLeaf{
+		parse("100644 blob " + sha + "\ta.txt"),
+		parse("040000 tree " + sha + "\ta"),
+		parse("100644 blob " + sha + "\ta-b"),
+	})
+	if err != nil {
+		t.Fatalf("NewTree() error = %v", err)
+	}
+	data, err := tree.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	// Trees sort as if their name ends in a slash, and aren't zero-padded
+	want := []string{"100644 a-b\x00", "100644 a.txt\x00", "40000 a\x00"}
+	pos := 0
+	for _, entry := range want {
+		i := bytes.Index(data[pos:], []byte(entry))
+		if i < 0 {
+			t.Fatalf("Serialize() = %q, want %q in order", data, want)
+		}
+		pos += i + len(entry)
+	}
+
+	_, err = NewTree([]*TreeLeaf{
+		parse("100644 blob " + sha + "\tsame"),
+		parse("100755 blob " + sha + "\tsame"),
+	})
+	if err == nil {
+		t.Error("NewTree() with duplicate names, want an error")
+	}
+}
@@ -0,0 +1,53 @@
+package objects
+
+import (
+	"encoding/hex"
+	"os"
+	"time"
+
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// ModTimes returns when each object of the repository was last written,
+// by its hex name: the time of its loose file or of its pack, or for the
+// objects of cruft packs, the time the pack has for them. Objects that are
+// in more than one place have the latest of their times.
+func ModTimes(repo *repository.Repository) (map[string]time.Time, error) {
+	times := map[string]time.Time{}
+	update := func(name string, modTime time.Time) {
+		if current, ok := times[name]; !ok || modTime.After(current) {
+			times[name] = modTime
+		}
+	}
+	err := ForEachLoose(repo, func(obj LooseObject) error {
+		update(obj.SHA.AsString(), obj.ModTime)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	packs, err := pack.List(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		info, err := os.Stat(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		mtimes, err := p.Mtimes()
+		if err != nil {
+			return nil, err
+		}
+		for i := range p.Index.Count() {
+			modTime := info.ModTime()
+			if mtimes != nil {
+				modTime = time.Unix(int64(mtimes[i]), 0)
+			}
+			update(hex.EncodeToString(p.Index.Name(i)), modTime)
+		}
+	}
+	return times, nil
+}
@@ -3,16 +3,19 @@ package objects
 import (
 	"bytes"
 	"compress/zlib"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/jessegeens/got/pkg/fs"
 	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
 	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
 )
@@ -23,6 +26,10 @@ type GitObject interface {
 	Type() GitObjectType
 }
 
+// CheckFunc checks an object that came in, like with fsck, before it is
+// stored
+type CheckFunc func(sha *hashing.SHA, obj GitObject) error
+
 // Enum for Git object types
 type GitObjectType string
 
@@ -52,35 +59,85 @@ func ParseType(objectType string) (GitObjectType, error) {
 	return "", errors.New("Not a valid object type: " + objectType)
 }
 
+// ReadObject reads an object, which is either loose or in a pack
 func ReadObject(repo *repository.Repository, sha *hashing.SHA) (GitObject, error) {
-	hexSha := sha.AsString()
-	path, err := repo.RepositoryFile(false, "objects", hexSha[0:2], hexSha[2:])
+	objType, contents, err := ReadRaw(repo, sha)
 	if err != nil {
 		return nil, err
 	}
 
+	return parseObject(objType, contents)
+}
+
+// parseObject returns the object of a type with the given contents
+func parseObject(objType string, contents []byte) (GitObject, error) {
+	switch objType {
+	case "commit":
+		commit := &Commit{}
+		err := commit.Deserialize(contents)
+		return commit, err
+	case "tree":
+		tree := &Tree{}
+		err := tree.Deserialize(contents)
+		return tree, err
+	case "tag":
+		tag := &Tag{}
+		err := tag.Deserialize(contents)
+		return tag, err
+	case "blob":
+		blob := &Blob{}
+		err := blob.Deserialize(contents)
+		return blob, err
+	}
+	return nil, errors.New("invalid object type " + objType)
+}
+
+// checkObject parses an object of a pack and checks it
+func checkObject(check CheckFunc, name []byte, typ pack.ObjectType, data []byte) error {
+	sha := hashing.NewShaFromBytes(name)
+	obj, err := parseObject(typ.String(), data)
+	if err != nil {
+		return fmt.Errorf("object %s: %w", sha.AsString(), err)
+	}
+	return check(sha, obj)
+}
+
+// ReadRaw returns the type and contents of an object, as they are stored.
+// Loose objects are looked for first, as new objects are written loose.
+func ReadRaw(repo *repository.Repository, sha *hashing.SHA) (string, []byte, error) {
+	hexSha := sha.AsString()
+	path := repo.RepositoryPath("objects", hexSha[0:2], hexSha[2:])
 	if !fs.IsFile(path) {
-		return nil, errors.New("not a file: " + path)
+		objType, contents, err := readPacked(repo, sha)
+		if errors.Is(err, pack.ErrNotFound) && hexSha == EmptyTree {
+			return "tree", []byte{}, nil
+		} else if errors.Is(err, pack.ErrNotFound) {
+			return "", nil, errors.New("not a file: " + path)
+		}
+		return objType, contents, err
 	}
 
 	f, err := os.OpenFile(path, os.O_RDONLY, os.ModePerm)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 	defer f.Close()
 
 	zlibReader, err := zlib.NewReader(f)
 	if err != nil {
-		return nil, errors.New("failed to open file: " + err.Error())
+		return "", nil, errors.New("failed to open file: " + err.Error())
 	}
 	defer zlibReader.Close()
 	rawObjectContents, err := io.ReadAll(zlibReader)
 	if err != nil {
-		return nil, errors.New("failed to read file: " + err.Error())
+		return "", nil, errors.New("failed to read file: " + err.Error())
 	}
 
 	// Read object type
 	idx := bytes.IndexByte(rawObjectContents, ' ')
+	if idx < 0 {
+		return "", nil, errors.New("malformed object " + hexSha + ", bad header")
+	}
 	objType := string(rawObjectContents[0:idx])
 
 	// Read and validate obj size
@@ -89,10 +146,13 @@ func ReadObject(repo *repository.Repository, sha *hashing.SHA) (GitObject, error
 	rawObjectContents = rawObjectContents[idx:]
 
 	idx = bytes.IndexByte(rawObjectContents, 0x00)
+	if idx < 0 {
+		return "", nil, errors.New("malformed object " + hexSha + ", bad header")
+	}
 	stringLen := string(rawObjectContents[0:idx])
 	size, err := strconv.Atoi(stringLen)
 	if err != nil {
-		return nil, errors.New("invalid object size " + stringLen)
+		return "", nil, errors.New("invalid object size " + stringLen)
 	}
 
 	// Now we pass over the size itself and go to the actual contents
@@ -101,28 +161,9 @@ func ReadObject(repo *repository.Repository, sha *hashing.SHA) (GitObject, error
 
 	// We verify the size
 	if size != len(rawObjectContents) {
-		return nil, errors.New("malformed object " + sha.AsString() + ", bad length")
-	}
-
-	switch objType {
-	case "commit":
-		commit := &Commit{}
-		err := commit.Deserialize(rawObjectContents)
-		return commit, err
-	case "tree":
-		tree := &Tree{}
-		err := tree.Deserialize(rawObjectContents)
-		return tree, err
-	case "tag":
-		tag := &Tag{}
-		err := tag.Deserialize(rawObjectContents)
-		return tag, err
-	case "blob":
-		blob := &Blob{}
-		err := blob.Deserialize(rawObjectContents)
-		return blob, err
+		return "", nil, errors.New("malformed object " + hexSha + ", bad length")
 	}
-	return nil, errors.New("invalid object type " + objType)
+	return objType, rawObjectContents, nil
 }
 
 // encode serializes the object, including the header
@@ -147,48 +188,53 @@ func CalculateSha(o GitObject) (*hashing.SHA, error) {
 	return hash, nil
 }
 
+// WriteObject stores the object as a loose object. Objects that are already
+// in the repository, loose or packed, are not compressed or written again.
 func WriteObject(o GitObject, repo *repository.Repository) (*hashing.SHA, error) {
-	hash, err := CalculateSha(o)
+	encodedObject, err := Encode(o)
 	if err != nil {
 		return nil, err
 	}
+	hash := hashing.NewSHA(encodedObject)
+	if HasObject(repo, hash) {
+		return hash, nil
+	}
+	// The empty tree can be read without being stored, so it is stored
+	// first when something refers to it
+	if refersToEmptyTree(o) && !HasObject(repo, EmptyTreeSHA()) {
+		if _, err := WriteObject(&Tree{Items: []*TreeLeaf{}}, repo); err != nil {
+			return nil, err
+		}
+	}
+	return hash, writeLoose(repo, hash, encodedObject)
+}
+
+// writeLoose writes an encoded object to its loose object file
+func writeLoose(repo *repository.Repository, hash *hashing.SHA, encodedObject []byte) error {
 	hexHash := hash.AsString()
 
 	// First, create directory structure if it does not exist
 	if _, err := repo.RepositoryDir(true, "objects", hexHash[0:2]); err != nil {
-		return nil, fmt.Errorf("failed to create directory under objects: %s", err)
+		return fmt.Errorf("failed to create directory under objects: %s", err)
 	}
 	path := repo.RepositoryPath("objects", hexHash[0:2], hexHash[2:])
 
-	if !fs.PathExists(path) {
-		err := fs.WriteStringToFile(path, "")
-		if err != nil {
-			return nil, err
-		}
-		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
-
-		encodedObject, err := Encode(o)
-		if err != nil {
-			return nil, err
-		}
-
-		zlibWriter := zlib.NewWriter(f)
-		_, err = zlibWriter.Write(encodedObject)
-		if err != nil {
-			zlibWriter.Close()
-			return nil, err
-		}
-		err = zlibWriter.Close()
-		if err != nil {
-			return nil, err
-		}
+	var compressed bytes.Buffer
+	zlibWriter := zlib.NewWriter(&compressed)
+	if _, err := zlibWriter.Write(encodedObject); err != nil {
+		return err
+	}
+	if err := zlibWriter.Close(); err != nil {
+		return err
 	}
 
-	return hash, nil
+	// Objects are immutable, so they are made read-only. The write is
+	// atomic, so an interrupted write never leaves a corrupt object behind.
+	mode, err := repo.SharedMode(0o444)
+	if err != nil {
+		return err
+	}
+	return fs.AtomicWrite(path, compressed.Bytes(), fs.WithExactPerm(mode), fs.WithSync())
 }
 
 // Find finds an object called `name` in a repository `repo`.
@@ -202,8 +248,10 @@ func Find(repo *repository.Repository, name string, format GitObjectType, follow
 		return nil, err
 	}
 
+	// A branch or tag can resolve to the same object as a hash prefix
+	shas = slices.Compact(slices.Sorted(slices.Values(shas)))
 	if len(shas) > 1 {
-		return nil, errors.New("cannot find object ambiguous name: found " + strconv.Itoa(len(shas)) + " possible objects!")
+		return nil, newAmbiguousError(repo, name, shas)
 	}
 
 	if len(shas) == 0 || shas[0] == "" {
@@ -215,63 +263,73 @@ func Find(repo *repository.Repository, name string, format GitObjectType, follow
 		return nil, fmt.Errorf("malformed candidate: %s", err)
 	}
 
-	for {
-		// Not really efficient: we read the whole object just to determine its type (in a loop!)
-		obj, err := ReadObject(repo, sha)
+	if format == TypeNoTypeSpecified {
+		return sha, nil
+	}
+	if !follow {
+		typ, _, err := ReadHeader(repo, sha)
 		if err != nil {
 			return nil, err
 		}
-
-		if obj.Type() == format || format == TypeNoTypeSpecified {
-			return sha, nil
-		}
-
-		if !follow {
-			return nil, errors.New("did not find any match for object named " + name + " matching the specified format")
-		}
-
-		if obj.Type() == TypeTag {
-			tag := obj.(*Tag)
-			objSha, ok := tag.GetValue("object")
-			if !ok {
-				return nil, errors.New("failed to parse tag")
-			}
-			sha, err = hashing.NewShaFromHex(string(objSha))
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse tag, malformed hash: %s", err)
-			}
-		} else if obj.Type() == TypeCommit && format == TypeTree {
-			commit := obj.(*Commit)
-			objSha, ok := commit.GetValue("tree")
-			if !ok || len(objSha) == 0 {
-				return nil, errors.New("failed to parse commit")
-			}
-			sha, err = hashing.NewShaFromHex(string(objSha))
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse commit, malformed hash: %s", err)
-			}
-		} else {
+		if typ != format {
 			return nil, errors.New("did not find any match for object named " + name + " matching the specified format")
 		}
+		return sha, nil
 	}
 
-	//return name, nil
+	peeled, err := Peel(repo, sha, format)
+	if errors.Is(err, ErrCannotPeel) {
+		return nil, errors.New("did not find any match for object named " + name + " matching the specified format")
+	}
+	return peeled, err
 }
 
+// ObjectHash returns the name of an object with the given contents, and
+// writes it to repo unless repo is nil. Commits, trees and tags have to
+// parse, but are stored as given.
 func ObjectHash(fileContents []byte, objectType GitObjectType, repo *repository.Repository) (*hashing.SHA, error) {
-	var obj GitObject = nil
+	var obj GitObject
 	switch objectType {
 	case TypeBlob:
-		obj = &Blob{data: fileContents}
+		obj = &Blob{}
+	case TypeCommit:
+		obj = &Commit{}
+	case TypeTree:
+		obj = &Tree{}
+	case TypeTag:
+		obj = &Tag{}
+	default:
+		return nil, errors.New("Not a valid object type: " + string(objectType))
+	}
+	if err := obj.Deserialize(fileContents); err != nil {
+		return nil, fmt.Errorf("malformed %s: %w", objectType, err)
+	}
+	return HashLiterally(fileContents, string(objectType), repo)
+}
+
+// HashLiterally is like ObjectHash, but the contents aren't checked and
+// the type can be any word, like git hash-object --literally. It makes
+// broken objects on purpose, as test fixtures.
+func HashLiterally(fileContents []byte, objectType string, repo *repository.Repository) (*hashing.SHA, error) {
+	if objectType == "" || strings.ContainsAny(objectType, " \x00") {
+		return nil, fmt.Errorf("invalid object type %q", objectType)
+	}
+	encoded := append([]byte(objectType+" "+strconv.Itoa(len(fileContents))+"\x00"), fileContents...)
+	hash := hashing.NewSHA(encoded)
+	if repo == nil || HasObject(repo, hash) {
+		return hash, nil
 	}
-	return WriteObject(obj, repo)
+	return hash, writeLoose(repo, hash, encoded)
 }
 
+var pseudoRefRegex = regexp.MustCompile("^[A-Z_]+_HEAD$")
+
 // Resolve name to an object hash in repo.
 //
 // This function is aware of:
 //
 //   - the HEAD literal
+//   - pseudo refs like MERGE_HEAD and ORIG_HEAD
 //   - short and long hashes
 //   - tags
 //   - branches
@@ -297,25 +355,32 @@ func Resolve(repo *repository.Repository, name string) ([]string, error) {
 		return []string{res}, err
 	}
 
+	// Pseudo refs like MERGE_HEAD are files in the repository directory.
+	// MERGE_HEAD has a line per merged commit, of which we take the first,
+	// and FETCH_HEAD a line per fetched ref, which starts with its hash.
+	if pseudoRefRegex.MatchString(name) {
+		if data, err := os.ReadFile(repo.RepositoryPath(name)); err == nil {
+			line, _, _ := strings.Cut(string(data), "\n")
+			sha, _, _ := strings.Cut(line, "\t")
+			return []string{strings.TrimSpace(sha)}, nil
+		}
+	}
+
 	// Next we try for hashes
 	if hashRegex.Match([]byte(name)) {
 		name = strings.ToLower(name)
-		prefix := name[0:2]
-		path, err := repo.RepositoryDir(false, "objects", prefix)
+		first, err := hex.DecodeString(name[:2])
+		if err != nil {
+			return nil, err
+		}
+		idx, err := loadOIDIndex(repo, first[0])
 		if err != nil {
 			return nil, err
 		}
-		if path != "" {
-			remainder := name[2:]
-			entries, err := os.ReadDir(path)
-			if err != nil {
-				return nil, err
-			}
-			for _, entry := range entries {
-				if strings.HasPrefix(entry.Name(), remainder) {
-					candidates = append(candidates, prefix+entry.Name())
-				}
-			}
+		candidates = append(candidates, idx.withPrefix(name)...)
+		// The empty tree can be read without being stored
+		if name == EmptyTree && !slices.Contains(candidates, name) {
+			candidates = append(candidates, name)
 		}
 	}
 
@@ -325,11 +390,20 @@ func Resolve(repo *repository.Repository, name string) ([]string, error) {
 		candidates = append(candidates, tag)
 	}
 
-	// Finally we try for branches
+	// Next we try for branches
 	branch, err := references.Reference("refs/heads/" + name).Resolve(repo)
 	if err == nil && branch != "" {
 		candidates = append(candidates, branch)
 	}
 
+	// And remote-tracking branches, like origin/main, where the name of a
+	// remote is its default branch
+	remoteBranch, err := references.Reference(references.RemotesPrefix + name).Resolve(repo)
+	if err == nil && remoteBranch != "" {
+		candidates = append(candidates, remoteBranch)
+	} else if head, err := references.RemoteHead(name).Resolve(repo); err == nil && head != "" {
+		candidates = append(candidates, head)
+	}
+
 	return candidates, nil
 }
@@ -113,6 +113,62 @@ func TestObjectHash(t *testing.T) {
 	}
 }
 
+func TestObjectHashTypes(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	// The names git hash-object gives these objects
+	tests := []struct {
+		name     string
+		typ      GitObjectType
+		contents string
+		want     string
+		wantErr  bool
+	}{
+		{"empty blob", TypeBlob, "", "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", false},
+		{"empty tree", TypeTree, "", "4b825dc642cb6eb9a060e54bf8d69288fbee4904", false},
+		{"commit", TypeCommit, "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\nauthor A <a@example.com> 0 +0000\ncommitter A <a@example.com> 0 +0000\n\nmessage\n", "3e69154c7907f840f755c95aa0668b6d7a1beb21", false},
+		{"malformed tree", TypeTree, "100644 a", "", true},
+		{"no type", TypeNoTypeSpecified, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sha, err := ObjectHash([]byte(tt.contents), tt.typ, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ObjectHash() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && sha.AsString() != tt.want {
+				t.Errorf("ObjectHash() = %s, want %s", sha.AsString(), tt.want)
+			}
+			if err == nil && HasObject(repo, sha) {
+				t.Errorf("ObjectHash() without a repository wrote %s", sha.AsString())
+			}
+		})
+	}
+}
+
+func TestHashLiterally(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	// git hash-object -t bogus --literally of "data"
+	sha, err := HashLiterally([]byte("data"), "bogus", repo)
+	if err != nil {
+		t.Fatalf("HashLiterally() error = %v", err)
+	}
+	if want := "f0021a7755a54f5a16d605c4f6d247c085d587fe"; sha.AsString() != want {
+		t.Errorf("HashLiterally() = %s, want %s", sha.AsString(), want)
+	}
+	if !HasObject(repo, sha) {
+		t.Errorf("HashLiterally() didn't write %s", sha.AsString())
+	}
+	for _, typ := range []string{"", "two words", "nul\x00"} {
+		if _, err := HashLiterally(nil, typ, nil); err == nil {
+			t.Errorf("HashLiterally() of type %q succeeded", typ)
+		}
+	}
+}
+
 func TestGitObjectType_String(t *testing.T) {
 	tests := []struct {
 		name string
@@ -183,6 +239,31 @@ func TestResolve(t *testing.T) {
 		t.Fatalf("Failed to create branch reference: %v", err)
 	}
 
+	// MERGE_HEAD has a line per merged commit
+	mergeHeadPath := filepath.Join(repo.GitDir(), "MERGE_HEAD")
+	if err := os.WriteFile(mergeHeadPath, []byte(commitHash.AsString()+"\n"+hash.AsString()+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create MERGE_HEAD: %v", err)
+	}
+
+	// A remote-tracking branch, and the symbolic ref to the default
+	// branch of its remote
+	remoteDir := filepath.Join(repo.GitDir(), "refs", "remotes", "origin")
+	if err := os.MkdirAll(remoteDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remoteDir, "main"), []byte(hash.AsString()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remoteDir, "HEAD"), []byte("ref: refs/remotes/origin/main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// FETCH_HEAD has a line per fetched ref, the one to merge first
+	fetchHead := hash.AsString() + "\t\tbranch 'main' of /remote\n" + commitHash.AsString() + "\tnot-for-merge\tbranch 'dev' of /remote\n"
+	if err := os.WriteFile(filepath.Join(repo.GitDir(), "FETCH_HEAD"), []byte(fetchHead), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name    string
 		input   string
@@ -190,6 +271,7 @@ func TestResolve(t *testing.T) {
 	}{
 		{"empty name", "", true},
 		{"HEAD", "HEAD", false},
+		{"MERGE_HEAD", "MERGE_HEAD", false},
 		{"master", "master", false},
 		{"short hash", hash.AsString()[:4], false},
 		{"full hash", hash.AsString(), false},
@@ -207,6 +289,17 @@ func TestResolve(t *testing.T) {
 			}
 		})
 	}
+
+	shas, err := Resolve(repo, "MERGE_HEAD")
+	if err != nil || len(shas) != 1 || shas[0] != commitHash.AsString() {
+		t.Errorf("Resolve(MERGE_HEAD) = %v, %v, want the first commit %s", shas, err, commitHash.AsString())
+	}
+	for _, name := range []string{"FETCH_HEAD", "origin/main", "origin"} {
+		shas, err := Resolve(repo, name)
+		if err != nil || len(shas) != 1 || shas[0] != hash.AsString() {
+			t.Errorf("Resolve(%s) = %v, %v, want %s", name, shas, err, hash.AsString())
+		}
+	}
 }
 
 func TestFind(t *testing.T) {
@@ -556,3 +649,31 @@ func TestWriteObject(t *testing.T) {
 		}
 	})
 }
+
+func TestWriteObjectPermissions(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	hash, err := WriteObject(&Blob{data: []byte("read-only")}, repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+
+	objPath := filepath.Join(repo.GitDir(), "objects", hash.AsString()[:2], hash.AsString()[2:])
+	info, err := os.Stat(objPath)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o444 {
+		t.Errorf("object permissions = %o, want 444", perm)
+	}
+
+	// No temporary files should be left behind
+	tmpFiles, err := filepath.Glob(filepath.Join(repo.GitDir(), "objects", "*", ".tmp-*"))
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+	if len(tmpFiles) != 0 {
+		t.Errorf("temporary files left behind: %v", tmpFiles)
+	}
+}
@@ -0,0 +1,98 @@
+package objects
+
+import (
+	"encoding/hex"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// oidIndex is the sorted hex names of the objects of a repository that
+// start with the same byte, loose and packed, to look up abbreviations by
+// binary search
+type oidIndex []string
+
+// loadOIDIndex returns the index of the objects that start with first. It
+// is reused until its fan-out directory or the packs change.
+func loadOIDIndex(repo *repository.Repository, first byte) (oidIndex, error) {
+	prefix := hexByte(int(first))
+	dir := repo.RepositoryPath("objects", prefix)
+	value, err := repo.CachedDirs(func() (any, error) {
+		names := []string{}
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if name := prefix + e.Name(); !e.IsDir() && isHexName(name) {
+				names = append(names, name)
+			}
+		}
+		packs, err := pack.List(repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packs {
+			lo, hi := p.Index.Bounds(first)
+			for i := lo; i < hi; i++ {
+				names = append(names, hex.EncodeToString(p.Index.Name(i)))
+			}
+		}
+		slices.Sort(names)
+		return oidIndex(slices.Compact(names)), nil
+	}, dir, repo.RepositoryPath("objects", "pack"))
+	if err != nil {
+		return nil, err
+	}
+	return value.(oidIndex), nil
+}
+
+// isHexName reports whether name is the full lowercase hex name of an
+// object
+func isHexName(name string) bool {
+	if len(name) != 40 {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// withPrefix returns the names that start with a lowercase hex prefix
+func (idx oidIndex) withPrefix(prefix string) []string {
+	names := []string{}
+	for i := sort.SearchStrings(idx, prefix); i < len(idx) && strings.HasPrefix(idx[i], prefix); i++ {
+		names = append(names, idx[i])
+	}
+	return names
+}
+
+// shortest returns the length of the shortest prefix of name that no
+// other object starts with. Only the names next to it in the order can
+// share a longer prefix.
+func (idx oidIndex) shortest(name string) int {
+	length := 1
+	i := sort.SearchStrings(idx, name)
+	neighbours := []int{i - 1, i}
+	if i < len(idx) && idx[i] == name {
+		neighbours[1] = i + 1
+	}
+	for _, j := range neighbours {
+		if j < 0 || j >= len(idx) {
+			continue
+		}
+		common := 0
+		for common < len(name) && name[common] == idx[j][common] {
+			common++
+		}
+		length = max(length, common+1)
+	}
+	return min(length, len(name))
+}
@@ -0,0 +1,78 @@
+package objects
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func TestOIDIndex(t *testing.T) {
+	idx := oidIndex{
+		"ab00000000000000000000000000000000000000",
+		"ab12300000000000000000000000000000000000",
+		"ab12340000000000000000000000000000000000",
+		"abf0000000000000000000000000000000000000",
+	}
+
+	prefixes := []struct {
+		prefix string
+		want   []string
+	}{
+		{"ab", []string(idx)},
+		{"ab123", []string{idx[1], idx[2]}},
+		{"ab1234", []string{idx[2]}},
+		{"ab5", []string{}},
+		{"abf0000000000000000000000000000000000000", []string{idx[3]}},
+	}
+	for _, tt := range prefixes {
+		if got := idx.withPrefix(tt.prefix); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("withPrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	shortest := []struct {
+		name string
+		want int
+	}{
+		{idx[0], 3},
+		{idx[1], 6},
+		{idx[2], 6},
+		{idx[3], 3},
+		// Names that aren't in the index count too
+		{"ab12350000000000000000000000000000000000", 6},
+		{"ac00000000000000000000000000000000000000", 2},
+	}
+	for _, tt := range shortest {
+		if got := idx.shortest(tt.name); got != tt.want {
+			t.Errorf("shortest(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadOIDIndex(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	sha, err := WriteObject(&Blob{data: []byte("indexed")}, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stream bytes.Buffer
+	if _, _, err := pack.Write(&stream, []pack.Object{{Name: sha.AsBytes(), Type: pack.TypeBlob, Data: []byte("indexed")}}, pack.DefaultWriteOptions); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := IndexPack(repo, &stream, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The object is both loose and packed, but listed once
+	idx, err := loadOIDIndex(repo, sha.AsBytes()[0])
+	if err != nil {
+		t.Fatalf("loadOIDIndex() error = %v", err)
+	}
+	if want := (oidIndex{sha.AsString()}); !reflect.DeepEqual(idx, want) {
+		t.Errorf("loadOIDIndex() = %v, want %v", idx, want)
+	}
+}
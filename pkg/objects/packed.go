@@ -0,0 +1,70 @@
+package objects
+
+import (
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// readPacked reads an object from the packs of the repository. It
+// returns pack.ErrNotFound if no pack has it.
+func readPacked(repo *repository.Repository, sha *hashing.SHA) (string, []byte, error) {
+	p, err := findPack(repo, sha)
+	if err != nil {
+		return "", nil, err
+	}
+	typ, data, err := p.Read(sha.AsBytes())
+	if err != nil {
+		return "", nil, err
+	}
+	return typ.String(), data, nil
+}
+
+// packedHeader is ReadHeader for packed objects
+func packedHeader(repo *repository.Repository, sha *hashing.SHA) (GitObjectType, int, error) {
+	p, err := findPack(repo, sha)
+	if err != nil {
+		return "", 0, err
+	}
+	typ, size, err := p.Header(sha.AsBytes())
+	if err != nil {
+		return "", 0, err
+	}
+	objType, err := ParseType(typ.String())
+	return objType, int(size), err
+}
+
+// findPack returns the pack that has an object. The bases of its deltas
+// that are in other packs or loose are read from there.
+func findPack(repo *repository.Repository, sha *hashing.SHA) (*pack.Pack, error) {
+	packs, err := pack.List(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		if !p.Index.Contains(sha.AsBytes()) {
+			continue
+		}
+		p.Base = func(base []byte) (pack.ObjectType, []byte, error) {
+			objType, data, err := ReadRaw(repo, hashing.NewShaFromBytes(base))
+			if err != nil {
+				return 0, nil, err
+			}
+			return packType(objType), data, nil
+		}
+		return p, nil
+	}
+	return nil, pack.ErrNotFound
+}
+
+func packType(objType string) pack.ObjectType {
+	switch GitObjectType(objType) {
+	case TypeCommit:
+		return pack.TypeCommit
+	case TypeTree:
+		return pack.TypeTree
+	case TypeTag:
+		return pack.TypeTag
+	}
+	return pack.TypeBlob
+}
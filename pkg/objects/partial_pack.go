@@ -0,0 +1,186 @@
+package objects
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// PartialPackName is the file in objects/pack where IndexPack keeps what
+// arrived of a pack stream that was cut off. It is named like the
+// temporary packs of git, which git prune cleans up.
+const PartialPackName = "tmp_pack_partial"
+
+// keepPartialPack keeps the part of a pack stream that arrived, so a fetch
+// that is tried again only needs the rest
+func keepPartialPack(repo *repository.Repository, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := repo.RepositoryDir(true, "objects", "pack"); err != nil {
+		return err
+	}
+	mode, err := repo.SharedMode(0o444)
+	if err != nil {
+		return err
+	}
+	return fs.AtomicWrite(repo.RepositoryPath("objects", "pack", PartialPackName), data, fs.WithExactPerm(mode))
+}
+
+// RecoverPartialPack stores the objects of the pack an interrupted fetch
+// or clone kept, and removes it. Only objects whose history and contents
+// are all there are stored, so everything in the repository still reaches
+// only what it has; the rest is fetched again. They are returned to send as
+// haves, since a have tells the remote not to send anything it reaches,
+// leaving out the ones that other haves reach.
+func RecoverPartialPack(repo *repository.Repository, check CheckFunc) ([]string, error) {
+	path := repo.RepositoryPath("objects", "pack", PartialPackName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	type recoveredObject struct {
+		obj  GitObject
+		typ  pack.ObjectType
+		data []byte
+	}
+	recovered := map[string]recoveredObject{}
+	order := []string{}
+	// Deltas can be on objects that came before them in the pack
+	base := func(sha []byte) (pack.ObjectType, []byte, error) {
+		if r, ok := recovered[hashing.NewShaFromBytes(sha).AsString()]; ok {
+			return r.typ, r.data, nil
+		}
+		objType, data, err := ReadRaw(repo, hashing.NewShaFromBytes(sha))
+		return packType(objType), data, err
+	}
+	store := func(name []byte, typ pack.ObjectType, data []byte) error {
+		if check != nil {
+			if err := checkObject(check, name, typ, data); err != nil {
+				return err
+			}
+		}
+		sha := hashing.NewShaFromBytes(name)
+		obj, err := parseObject(typ.String(), data)
+		if err != nil {
+			return fmt.Errorf("object %s: %w", sha.AsString(), err)
+		}
+		if _, ok := recovered[sha.AsString()]; !ok {
+			order = append(order, sha.AsString())
+		}
+		recovered[sha.AsString()] = recoveredObject{obj, typ, data}
+		return nil
+	}
+	if _, err := pack.Recover(bytes.NewReader(data), base, store); err != nil {
+		return nil, err
+	}
+
+	parsed := map[string]GitObject{}
+	for name, r := range recovered {
+		parsed[name] = r.obj
+	}
+	complete := completeObjects(repo, parsed)
+	reached := map[string]bool{}
+	for name, whole := range complete {
+		if !whole || parsed[name] == nil {
+			continue
+		}
+		for _, child := range objectChildren(parsed[name]) {
+			reached[child] = true
+		}
+	}
+	haves := []string{}
+	for _, name := range order {
+		if !complete[name] {
+			continue
+		}
+		sha, _ := hashing.NewShaFromHex(name)
+		if !HasObject(repo, sha) {
+			r := recovered[name]
+			encoded := append([]byte(fmt.Sprintf("%s %d\x00", r.typ, len(r.data))), r.data...)
+			if err := writeLoose(repo, sha, encoded); err != nil {
+				return nil, err
+			}
+		}
+		if !reached[name] {
+			haves = append(haves, name)
+		}
+	}
+	return haves, os.Remove(path)
+}
+
+// completeObjects returns which of the recovered objects have everything
+// they reach in the repository. Objects that were there before are taken
+// to be complete, like the rest of the repository.
+func completeObjects(repo *repository.Repository, recovered map[string]GitObject) map[string]bool {
+	complete := map[string]bool{}
+	// Histories can be long, so the objects are done without recursion:
+	// an object is decided once all of its children are
+	for name := range recovered {
+		stack := []string{name}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if _, ok := complete[top]; ok {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			obj, ok := recovered[top]
+			if !ok {
+				sha, err := hashing.NewShaFromHex(top)
+				complete[top] = err == nil && HasObject(repo, sha)
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			done, whole := true, true
+			for _, child := range objectChildren(obj) {
+				if isComplete, ok := complete[child]; !ok {
+					stack = append(stack, child)
+					done = false
+				} else if !isComplete {
+					whole = false
+				}
+			}
+			if done {
+				complete[top] = whole
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return complete
+}
+
+// objectChildren returns the objects an object points to: the tree and
+// parents of a commit, the entries of a tree, and the object of a tag.
+// Submodule commits aren't in the repository, so they are left out.
+func objectChildren(obj GitObject) []string {
+	children := []string{}
+	switch obj := obj.(type) {
+	case *Commit:
+		if tree, ok := obj.GetValue("tree"); ok {
+			children = append(children, string(tree))
+		}
+		for _, parent := range obj.GetValues("parent") {
+			children = append(children, string(parent))
+		}
+	case *Tree:
+		for _, leaf := range obj.Items {
+			if string(bytes.TrimLeft(leaf.Mode, "0")) != "160000" {
+				children = append(children, leaf.Sha.AsString())
+			}
+		}
+	case *Tag:
+		if object, ok := obj.GetValue("object"); ok {
+			children = append(children, string(object))
+		}
+	}
+	return children
+}
@@ -0,0 +1,76 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func TestRecoverPartialPack(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	object := func(typ pack.ObjectType, data string) pack.Object {
+		name := hashing.NewSHA(append([]byte(fmt.Sprintf("%s %d\x00", typ, len(data))), data...)).AsBytes()
+		return pack.Object{Name: name, Type: typ, Data: []byte(data)}
+	}
+	hex := func(obj pack.Object) string { return hashing.NewShaFromBytes(obj.Name).AsString() }
+	commit := func(tree pack.Object, parents ...pack.Object) pack.Object {
+		data := "tree " + hex(tree) + "\n"
+		for _, parent := range parents {
+			data += "parent " + hex(parent) + "\n"
+		}
+		return object(pack.TypeCommit, data+"author A <a@example.com> 0 +0000\ncommitter A <a@example.com> 0 +0000\n\nmessage\n")
+	}
+	blob := object(pack.TypeBlob, string(bytes.Repeat([]byte("contents\n"), 100)))
+	tree := object(pack.TypeTree, "100644 file\x00"+string(blob.Name))
+	first := commit(tree)
+	otherBlob := object(pack.TypeBlob, "other contents\n")
+	otherTree := object(pack.TypeTree, "100644 other\x00"+string(otherBlob.Name))
+	second := commit(otherTree, first)
+
+	// Packs have the smallest blob last, so the stream is cut off in the
+	// blob of the second commit
+	var stream bytes.Buffer
+	objects := []pack.Object{blob, tree, first, second, otherTree, otherBlob}
+	if _, _, err := pack.Write(&stream, objects, pack.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	cut := stream.Bytes()[:stream.Len()-30]
+	if _, err := IndexPack(repo, bytes.NewReader(cut), nil); err == nil {
+		t.Fatalf("IndexPack() of a pack that was cut off succeeded")
+	}
+	if !fs.IsFile(repo.RepositoryPath("objects", "pack", PartialPackName)) {
+		t.Fatalf("IndexPack() didn't keep the partial pack")
+	}
+
+	haves, err := RecoverPartialPack(repo, nil)
+	if err != nil {
+		t.Fatalf("RecoverPartialPack() error = %v", err)
+	}
+	if want := []string{hex(first)}; !slices.Equal(haves, want) {
+		t.Errorf("RecoverPartialPack() = %v, want %v", haves, want)
+	}
+	for _, obj := range []pack.Object{blob, tree, first} {
+		if !HasObject(repo, hashing.NewShaFromBytes(obj.Name)) {
+			t.Errorf("RecoverPartialPack() didn't store %s", hex(obj))
+		}
+	}
+	for _, obj := range []pack.Object{second, otherTree} {
+		if HasObject(repo, hashing.NewShaFromBytes(obj.Name)) {
+			t.Errorf("RecoverPartialPack() stored %s, which isn't complete", hex(obj))
+		}
+	}
+	if fs.IsFile(repo.RepositoryPath("objects", "pack", PartialPackName)) {
+		t.Errorf("RecoverPartialPack() didn't remove the partial pack")
+	}
+
+	if haves, err := RecoverPartialPack(repo, nil); err != nil || len(haves) != 0 {
+		t.Errorf("RecoverPartialPack() without a partial pack = %v, %v, want none", haves, err)
+	}
+}
@@ -0,0 +1,66 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// ErrCannotPeel is returned by Peel when the object cannot be
+// dereferenced to the requested type
+var ErrCannotPeel = errors.New("object cannot be peeled to the requested type")
+
+// Peel dereferences tags until it finds an object of type target, like
+// git's <rev>^{<type>}. Commits are peeled to their tree when a tree is
+// requested. With TypeNoTypeSpecified, tags are peeled until the first
+// object that is not a tag, like <rev>^{}.
+func Peel(repo *repository.Repository, sha *hashing.SHA, target GitObjectType) (*hashing.SHA, error) {
+	seen := map[string]bool{}
+	for {
+		if seen[sha.AsString()] {
+			return nil, fmt.Errorf("tag cycle detected at %s", sha.AsString())
+		}
+		seen[sha.AsString()] = true
+
+		typ, _, err := ReadHeader(repo, sha)
+		if err != nil {
+			return nil, err
+		}
+		if typ == target || (target == TypeNoTypeSpecified && typ != TypeTag) {
+			return sha, nil
+		}
+
+		var key string
+		switch {
+		case typ == TypeTag:
+			key = "object"
+		case typ == TypeCommit && target == TypeTree:
+			key = "tree"
+		default:
+			return nil, ErrCannotPeel
+		}
+
+		obj, err := ReadObject(repo, sha)
+		if err != nil {
+			return nil, err
+		}
+		var next []byte
+		var ok bool
+		switch obj := obj.(type) {
+		case *Tag:
+			next, ok = obj.GetValue(key)
+		case *Commit:
+			next, ok = obj.GetValue(key)
+		}
+		if !ok || len(next) == 0 {
+			return nil, fmt.Errorf("malformed %s %s: missing %s", typ, sha.AsString(), key)
+		}
+		nextSha, err := hashing.NewShaFromHex(string(next))
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s %s: %s", typ, sha.AsString(), err)
+		}
+		sha = nextSha
+	}
+}
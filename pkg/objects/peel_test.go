@@ -0,0 +1,107 @@
+package objects
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/kvlm"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func writeTestTag(t *testing.T, repo *repository.Repository, target *hashing.SHA, targetType GitObjectType) *hashing.SHA {
+	data := kvlm.New()
+	data.Okv.Set("object", []byte(target.AsString()))
+	data.Okv.Set("type", []byte(targetType))
+	data.Okv.Set("tag", []byte("test"))
+	data.Message = []byte("test tag\n")
+	tag := Tag(*NewCommit(data))
+	sha, err := WriteObject(&tag, repo)
+	if err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+	return sha
+}
+
+func TestPeel(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	blob, _ := WriteObject(&Blob{data: []byte("content")}, repo)
+	tree, _ := WriteObject(&Tree{Items: []*TreeLeaf{{Mode: []byte("100644"), Path: []byte("file"), Sha: blob}}}, repo)
+	commitData := kvlm.New()
+	commitData.Okv.Set("tree", []byte(tree.AsString()))
+	commitData.Message = []byte("commit\n")
+	commit, _ := WriteObject(NewCommit(commitData), repo)
+	tag := writeTestTag(t, repo, commit, TypeCommit)
+	tagOfTag := writeTestTag(t, repo, tag, TypeTag)
+	blobTag := writeTestTag(t, repo, blob, TypeBlob)
+
+	tests := []struct {
+		name    string
+		sha     *hashing.SHA
+		target  GitObjectType
+		want    *hashing.SHA
+		wantErr error
+	}{
+		{"commit is a commit", commit, TypeCommit, commit, nil},
+		{"tag to commit", tag, TypeCommit, commit, nil},
+		{"nested tags", tagOfTag, TypeCommit, commit, nil},
+		{"nested tags to tag", tagOfTag, TypeTag, tagOfTag, nil},
+		{"tag to tree", tagOfTag, TypeTree, tree, nil},
+		{"commit to tree", commit, TypeTree, tree, nil},
+		{"peel all tags", tagOfTag, TypeNoTypeSpecified, commit, nil},
+		{"peel non-tag", blob, TypeNoTypeSpecified, blob, nil},
+		{"tag to blob", blobTag, TypeBlob, blob, nil},
+		{"blob tag to commit", blobTag, TypeCommit, nil, ErrCannotPeel},
+		{"tree to commit", tree, TypeCommit, nil, ErrCannotPeel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Peel(repo, tt.sha, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Peel() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && got.AsString() != tt.want.AsString() {
+				t.Errorf("Peel() = %s, want %s", got.AsString(), tt.want.AsString())
+			}
+		})
+	}
+}
+
+func TestPeelCycle(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	// A tag pointing to itself cannot be created normally, so we store
+	// it under a name that does not match its contents
+	sha, _ := hashing.NewShaFromHex("1111111111111111111111111111111111111111")
+	data := kvlm.New()
+	data.Okv.Set("object", []byte(sha.AsString()))
+	data.Okv.Set("type", []byte("tag"))
+	data.Message = []byte("cycle\n")
+	encoded, err := Encode(&Tag{data: data})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(encoded)
+	w.Close()
+	path := filepath.Join(repo.GitDir(), "objects", "11", sha.AsString()[2:])
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, compressed.Bytes(), 0o444); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Peel(repo, sha, TypeCommit); err == nil {
+		t.Errorf("Peel() should detect the tag cycle")
+	}
+}
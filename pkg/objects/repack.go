@@ -0,0 +1,270 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// RepackOptions change what Repack packs
+type RepackOptions struct {
+	pack.WriteOptions
+	// All packs the objects of the existing packs too, into one pack that
+	// replaces them. Packs with a .keep file are left alone.
+	All bool
+	// Keep selects the objects to pack by their hex name. Loose objects it
+	// leaves out stay loose, and packed ones are written loose before their
+	// pack is replaced, so they can be pruned once they expire. Without
+	// Keep, every object is packed.
+	Keep func(sha string) bool
+	// Cruft, with Keep, puts the objects that Keep leaves out in a cruft
+	// pack instead: a pack with the time each object was last written,
+	// so they expire like loose objects without being written loose. It is
+	// given that time, and the packed objects it turns down are dropped,
+	// while loose ones stay loose until they are pruned.
+	Cruft func(sha string, modTime time.Time) bool
+}
+
+// RepackResult is what Repack wrote
+type RepackResult struct {
+	// Name is the name of the new pack, like pack-<checksum>, and Count
+	// how many objects it has. Without objects to pack, no pack is
+	// written and the name is empty.
+	Name  string
+	Count int
+	// CruftName and CruftCount are the same for the cruft pack
+	CruftName  string
+	CruftCount int
+}
+
+// Repack writes the loose objects of the repository to a new pack, and
+// removes them once the pack is in place
+func Repack(repo *repository.Repository, opts RepackOptions) (RepackResult, error) {
+	result := RepackResult{}
+	keep := opts.Keep
+	if keep == nil {
+		keep = func(string) bool { return true }
+	}
+
+	packed := []pack.Object{}
+	paths := []string{}
+	seen := map[string]bool{}
+	read := func(sha *hashing.SHA) (pack.Object, error) {
+		objType, data, err := ReadRaw(repo, sha)
+		if err != nil {
+			return pack.Object{}, fmt.Errorf("reading %s: %w", sha.AsString(), err)
+		}
+		return pack.Object{Name: sha.AsBytes(), Type: packType(objType), Data: data}, nil
+	}
+	add := func(sha *hashing.SHA) error {
+		obj, err := read(sha)
+		packed = append(packed, obj)
+		return err
+	}
+	// The objects that are left out, with whether they are loose
+	left := map[string]bool{}
+	leftOut := []*hashing.SHA{}
+	err := ForEachLoose(repo, func(obj LooseObject) error {
+		name := obj.SHA.AsString()
+		seen[name] = true
+		if !keep(name) {
+			left[name] = true
+			leftOut = append(leftOut, obj.SHA)
+			return nil
+		}
+		paths = append(paths, obj.Path)
+		return add(obj.SHA)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	replaced := []*pack.Pack{}
+	if opts.All {
+		if replaced, err = replaceablePacks(repo); err != nil {
+			return result, err
+		}
+	}
+	for _, p := range replaced {
+		for i := range p.Index.Count() {
+			sha := hashing.NewShaFromBytes(p.Index.Name(i))
+			name := sha.AsString()
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if keep(name) {
+				if err := add(sha); err != nil {
+					return result, err
+				}
+				continue
+			}
+			leftOut = append(leftOut, sha)
+		}
+	}
+
+	// The times are read once the packs are known, so they have the
+	// objects of all of them
+	times := map[string]time.Time{}
+	if len(leftOut) > 0 {
+		if times, err = ModTimes(repo); err != nil {
+			return result, err
+		}
+	}
+	cruft := []pack.Object{}
+	cruftTimes := map[string]time.Time{}
+	for _, sha := range leftOut {
+		name := sha.AsString()
+		switch {
+		case opts.Cruft == nil && !left[name]:
+			err = loosen(repo, sha, times[name])
+		case opts.Cruft != nil && opts.Cruft(name, times[name]):
+			var obj pack.Object
+			obj, err = read(sha)
+			cruft = append(cruft, obj)
+			cruftTimes[name] = times[name]
+			if left[name] {
+				hexSha := sha.AsString()
+				paths = append(paths, repo.RepositoryPath("objects", hexSha[:2], hexSha[2:]))
+			}
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	if len(packed) == 0 && len(cruft) == 0 && len(replaced) == 0 {
+		return result, nil
+	}
+
+	if len(packed) > 0 {
+		if result.Name, err = writePack(repo, packed, opts.WriteOptions, nil); err != nil {
+			return result, err
+		}
+		result.Count = len(packed)
+	}
+	if len(cruft) > 0 {
+		if result.CruftName, err = writePack(repo, cruft, opts.WriteOptions, cruftTimes); err != nil {
+			return result, err
+		}
+		result.CruftCount = len(cruft)
+	}
+	// The objects are in the new packs now, so what held them before goes
+	for _, p := range replaced {
+		base := strings.TrimSuffix(p.Path, ".pack")
+		if name := filepath.Base(base); name == result.Name || name == result.CruftName {
+			continue
+		}
+		for _, path := range []string{base + ".idx", base + ".mtimes", p.Path} {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return result, err
+			}
+		}
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return result, err
+		}
+		// Like git, fan-out directories that are empty are removed
+		os.Remove(filepath.Dir(path))
+	}
+	return result, nil
+}
+
+// replaceablePacks returns the packs of the repository that don't have a
+// .keep file
+func replaceablePacks(repo *repository.Repository) ([]*pack.Pack, error) {
+	packs, err := pack.List(repo)
+	if err != nil {
+		return nil, err
+	}
+	replaceable := []*pack.Pack{}
+	for _, p := range packs {
+		if !fs.Exists(strings.TrimSuffix(p.Path, ".pack") + ".keep") {
+			replaceable = append(replaceable, p)
+		}
+	}
+	return replaceable, nil
+}
+
+// loosen writes a packed object loose, with the time it was last written,
+// so it expires as if it had always been loose
+func loosen(repo *repository.Repository, sha *hashing.SHA, modTime time.Time) error {
+	objType, data, err := ReadRaw(repo, sha)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", sha.AsString(), err)
+	}
+	encoded := append([]byte(fmt.Sprintf("%s %d\x00", objType, len(data))), data...)
+	if err := writeLoose(repo, sha, encoded); err != nil {
+		return err
+	}
+	hexSha := sha.AsString()
+	return os.Chtimes(repo.RepositoryPath("objects", hexSha[:2], hexSha[2:]), modTime, modTime)
+}
+
+// writePack writes a pack of the objects and then its index, since packs
+// are found through their index, and returns the name of the pack. With
+// times, it is a cruft pack with the time of each object.
+func writePack(repo *repository.Repository, objects []pack.Object, opts pack.WriteOptions, times map[string]time.Time) (string, error) {
+	var packData bytes.Buffer
+	entries, checksum, err := pack.Write(&packData, objects, opts)
+	if err != nil {
+		return "", err
+	}
+	var mtimes []uint32
+	if times != nil {
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, hex.EncodeToString(e.Name))
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			mtimes = append(mtimes, uint32(times[name].Unix()))
+		}
+	}
+	return storePack(repo, packData.Bytes(), entries, checksum, mtimes)
+}
+
+// storePack writes a pack and then its index to objects/pack, since packs
+// are found through their index, and returns the name of the pack. The
+// .mtimes file of a cruft pack goes before the index too.
+func storePack(repo *repository.Repository, packData []byte, entries []pack.IndexEntry, checksum []byte, mtimes []uint32) (string, error) {
+	var idxData bytes.Buffer
+	if err := pack.WriteIndex(&idxData, entries, checksum); err != nil {
+		return "", err
+	}
+	if _, err := repo.RepositoryDir(true, "objects", "pack"); err != nil {
+		return "", err
+	}
+	mode, err := repo.SharedMode(0o444)
+	if err != nil {
+		return "", err
+	}
+	name := "pack-" + hex.EncodeToString(checksum)
+	if err := fs.AtomicWrite(repo.RepositoryPath("objects", "pack", name+".pack"), packData, fs.WithExactPerm(mode), fs.WithSync()); err != nil {
+		return "", err
+	}
+	if mtimes != nil {
+		var mtimesData bytes.Buffer
+		if err := pack.WriteMtimes(&mtimesData, mtimes, checksum); err != nil {
+			return "", err
+		}
+		if err := fs.AtomicWrite(repo.RepositoryPath("objects", "pack", name+".mtimes"), mtimesData.Bytes(), fs.WithExactPerm(mode), fs.WithSync()); err != nil {
+			return "", err
+		}
+	}
+	if err := fs.AtomicWrite(repo.RepositoryPath("objects", "pack", name+".idx"), idxData.Bytes(), fs.WithExactPerm(mode), fs.WithSync()); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,180 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func TestRepack(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	if result, err := Repack(repo, RepackOptions{WriteOptions: pack.DefaultWriteOptions}); err != nil || result.Name != "" || result.Count != 0 {
+		t.Fatalf("Repack() without objects = %+v, %v, want nothing", result, err)
+	}
+
+	content := bytes.Repeat([]byte("some content that is about the same in every blob\n"), 20)
+	shas := []*hashing.SHA{}
+	for i := range 10 {
+		sha, err := WriteObject(&Blob{data: append(append([]byte{}, content...), fmt.Sprintf("blob %d\n", i)...)}, repo)
+		if err != nil {
+			t.Fatalf("WriteObject() error = %v", err)
+		}
+		shas = append(shas, sha)
+	}
+
+	result, err := Repack(repo, RepackOptions{WriteOptions: pack.DefaultWriteOptions})
+	if err != nil {
+		t.Fatalf("Repack() error = %v", err)
+	}
+	if result.Count != len(shas) {
+		t.Errorf("Repack() packed %d objects, want %d", result.Count, len(shas))
+	}
+	if !fs.IsFile(repo.RepositoryPath("objects", "pack", result.Name+".idx")) {
+		t.Errorf("Repack() didn't write %s.idx", result.Name)
+	}
+
+	loose := 0
+	if err := ForEachLoose(repo, func(LooseObject) error { loose++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if loose != 0 {
+		t.Errorf("Repack() left %d loose objects", loose)
+	}
+	for i, sha := range shas {
+		obj, err := ReadObject(repo, sha)
+		if err != nil {
+			t.Fatalf("ReadObject() error = %v", err)
+		}
+		want := fmt.Sprintf("blob %d\n", i)
+		if data, _ := obj.Serialize(); !bytes.HasSuffix(data, []byte(want)) {
+			t.Errorf("ReadObject() of a packed blob = %q, want it to end with %q", data, want)
+		}
+	}
+}
+
+func TestRepackAll(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	write := func(content string) *hashing.SHA {
+		sha, err := WriteObject(&Blob{data: []byte(content)}, repo)
+		if err != nil {
+			t.Fatalf("WriteObject() error = %v", err)
+		}
+		return sha
+	}
+	kept, dropped := write("kept"), write("dropped")
+	first, err := Repack(repo, RepackOptions{})
+	if err != nil {
+		t.Fatalf("Repack() error = %v", err)
+	}
+	loose := write("loose")
+
+	keep := func(sha string) bool { return sha != dropped.AsString() }
+	result, err := Repack(repo, RepackOptions{All: true, Keep: keep})
+	if err != nil {
+		t.Fatalf("Repack() error = %v", err)
+	}
+	if result.Count != 2 {
+		t.Errorf("Repack() packed %d objects, want 2", result.Count)
+	}
+	if fs.Exists(repo.RepositoryPath("objects", "pack", first.Name+".pack")) {
+		t.Errorf("Repack() kept the pack it replaced")
+	}
+	packs, err := pack.List(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packs) != 1 || !packs[0].Index.Contains(kept.AsBytes()) || !packs[0].Index.Contains(loose.AsBytes()) {
+		t.Errorf("Repack() left packs %v, want only %s with both kept objects", packs, result.Name)
+	}
+	// What isn't kept is loose now, to be pruned later
+	hexSha := dropped.AsString()
+	if !fs.IsFile(repo.RepositoryPath("objects", hexSha[:2], hexSha[2:])) {
+		t.Errorf("Repack() didn't write the object it left out loose")
+	}
+	if obj, err := ReadObject(repo, dropped); err != nil {
+		t.Errorf("ReadObject() error = %v", err)
+	} else if data, _ := obj.Serialize(); string(data) != "dropped" {
+		t.Errorf("ReadObject() = %q, want %q", data, "dropped")
+	}
+}
+
+func TestRepackCruft(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	write := func(content string) *hashing.SHA {
+		sha, err := WriteObject(&Blob{data: []byte(content)}, repo)
+		if err != nil {
+			t.Fatalf("WriteObject() error = %v", err)
+		}
+		return sha
+	}
+	kept, recent, expired := write("kept"), write("recent"), write("expired")
+	if _, err := Repack(repo, RepackOptions{}); err != nil {
+		t.Fatalf("Repack() error = %v", err)
+	}
+	loose := write("loose")
+
+	old := time.Now().AddDate(0, -1, 0)
+	opts := RepackOptions{
+		All:  true,
+		Keep: func(sha string) bool { return sha == kept.AsString() },
+		Cruft: func(sha string, modTime time.Time) bool {
+			return sha != expired.AsString()
+		},
+	}
+	result, err := Repack(repo, opts)
+	if err != nil {
+		t.Fatalf("Repack() error = %v", err)
+	}
+	if result.Count != 1 || result.CruftCount != 2 {
+		t.Fatalf("Repack() = %+v, want one object packed and two in the cruft pack", result)
+	}
+	if !fs.IsFile(repo.RepositoryPath("objects", "pack", result.CruftName+".mtimes")) {
+		t.Errorf("Repack() didn't write %s.mtimes", result.CruftName)
+	}
+	if HasObject(repo, expired) {
+		t.Error("Repack() kept the object that isn't cruft")
+	}
+	hexSha := loose.AsString()
+	if fs.Exists(repo.RepositoryPath("objects", hexSha[:2], hexSha[2:])) {
+		t.Error("Repack() left the loose object that went in the cruft pack")
+	}
+
+	// The cruft pack has the times of its objects, which are kept when it
+	// is replaced
+	times, err := ModTimes(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if when := times[recent.AsString()]; when.Before(time.Now().Add(-time.Hour)) {
+		t.Errorf("ModTimes() of a cruft object = %v, want the time it was written", when)
+	}
+	packs, _ := pack.List(repo)
+	for _, p := range packs {
+		if filepath.Base(p.Path) == result.CruftName+".pack" {
+			os.Chtimes(p.Path, old, old)
+		}
+	}
+	opts.Cruft = func(sha string, modTime time.Time) bool {
+		return sha == recent.AsString() && modTime.After(old)
+	}
+	result, err = Repack(repo, opts)
+	if err != nil {
+		t.Fatalf("Repack() error = %v", err)
+	}
+	if result.CruftCount != 1 || !HasObject(repo, recent) || HasObject(repo, loose) {
+		t.Errorf("Repack() = %+v, want only the recent object in the cruft pack", result)
+	}
+}
@@ -0,0 +1,193 @@
+package objects
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/sparse"
+)
+
+func init() {
+	index.ExpandSparse = expandSparse
+	index.CollapseSparse = collapseSparse
+}
+
+// expandSparse replaces the directories of a sparse index with the files
+// of their trees. The files are outside the cone, so they are marked
+// skip-worktree.
+func expandSparse(repo *repository.Repository, idx *index.Index) error {
+	entries := []*index.Entry{}
+	for _, e := range idx.Entries {
+		if !e.IsSparseDir() {
+			entries = append(entries, e)
+			continue
+		}
+		files, err := sparseDirEntries(repo, e.SHA.AsString(), strings.TrimSuffix(e.Name, "/"))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, files...)
+	}
+	idx.Entries = index.New(entries).Entries
+	idx.Sparse = false
+	return nil
+}
+
+// sparseDirEntries returns the skip-worktree entries for the files of a
+// tree, whose full paths start with prefix
+func sparseDirEntries(repo *repository.Repository, treeRef, prefix string) ([]*index.Entry, error) {
+	sha, err := Find(repo, treeRef, TypeTree, true)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := ReadObject(repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	tree, ok := obj.(*Tree)
+	if !ok {
+		return nil, errors.New(treeRef + " is not a tree")
+	}
+
+	entries := []*index.Entry{}
+	for _, leaf := range tree.Items {
+		name := path.Join(prefix, string(leaf.Path))
+		entry := &index.Entry{SHA: leaf.Sha, Name: name, ModeType: index.ModeTypeRegular, ModePerms: 0o644}
+		switch strings.TrimLeft(string(leaf.Mode), "0") {
+		case "40000":
+			files, err := sparseDirEntries(repo, leaf.Sha.AsString(), name)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, files...)
+			continue
+		case "100755":
+			entry.ModePerms = 0o755
+		case "120000":
+			entry.ModeType, entry.ModePerms = index.ModeTypeSymlink, 0
+		case "160000":
+			entry.ModeType, entry.ModePerms = index.ModeTypeGitlink, 0
+		}
+		entry.SetSkipWorktree(true)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// collapseSparse returns the index as it is written. When the repository
+// uses a sparse index, every directory outside the cone whose files are
+// all merged and skip-worktree becomes a single entry, like git does.
+// Otherwise a sparse index is expanded.
+func collapseSparse(repo *repository.Repository, idx *index.Index) (*index.Index, error) {
+	settings, err := sparse.ReadSettings(repo)
+	if err != nil {
+		return nil, err
+	}
+	var cone *sparse.Cone
+	if settings.Enabled && settings.Cone && settings.SparseIndex {
+		if cone, err = sparse.Load(repo); err != nil {
+			return nil, err
+		}
+	}
+	if cone == nil {
+		if !idx.Sparse {
+			return idx, nil
+		}
+		expanded := *idx
+		expanded.Entries = append([]*index.Entry{}, idx.Entries...)
+		return &expanded, expandSparse(repo, &expanded)
+	}
+
+	collapsed := *idx
+	collapsed.Sparse = true
+	entries, err := collapseEntries(repo, cone, idx.Entries, 1)
+	collapsed.Entries = entries
+	return &collapsed, err
+}
+
+// collapseEntries collapses the directories outside the cone with at least
+// depth parts in their paths. A directory that can't be collapsed as a
+// whole may still have subdirectories that can.
+func collapseEntries(repo *repository.Repository, cone *sparse.Cone, entries []*index.Entry, depth int) ([]*index.Entry, error) {
+	collapsed := []*index.Entry{}
+	for len(entries) > 0 {
+		dir := sparseDir(cone, entries[0].Name, depth)
+		if dir == "" {
+			collapsed = append(collapsed, entries[0])
+			entries = entries[1:]
+			continue
+		}
+		end := 0
+		for end < len(entries) && strings.HasPrefix(entries[end].Name, dir) {
+			end++
+		}
+		group := entries[:end]
+		entries = entries[end:]
+		if !canCollapse(group) {
+			inner, err := collapseEntries(repo, cone, group, strings.Count(dir, "/")+1)
+			if err != nil {
+				return nil, err
+			}
+			collapsed = append(collapsed, inner...)
+			continue
+		}
+		entry, err := collapseDir(repo, dir, group)
+		if err != nil {
+			return nil, err
+		}
+		collapsed = append(collapsed, entry)
+	}
+	return collapsed, nil
+}
+
+// sparseDir returns the outermost directory of name that is outside the
+// cone and has at least depth parts, with a trailing slash, or "" if there
+// is none. The name of a directory ends with a slash.
+func sparseDir(cone *sparse.Cone, name string, depth int) string {
+	parts := strings.Split(name, "/")
+	for i := depth; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if !cone.IncludesDir(dir) {
+			return dir + "/"
+		}
+	}
+	return ""
+}
+
+// canCollapse reports whether entries can become a directory: git only
+// collapses files that are merged and left out of the worktree
+func canCollapse(entries []*index.Entry) bool {
+	for _, e := range entries {
+		if e.IsSparseDir() {
+			continue
+		}
+		if e.FlagStage != 0 || !e.SkipWorktree() {
+			return false
+		}
+	}
+	return true
+}
+
+// collapseDir returns the entry of a sparse index that stands for the
+// entries in dir
+func collapseDir(repo *repository.Repository, dir string, entries []*index.Entry) (*index.Entry, error) {
+	trimmed := make([]*index.Entry, 0, len(entries))
+	for _, e := range entries {
+		if e.Name == dir {
+			return e, nil
+		}
+		inner := *e
+		inner.Name = strings.TrimPrefix(e.Name, dir)
+		trimmed = append(trimmed, &inner)
+	}
+	sha, err := TreeFromIndex(repo, index.New(trimmed))
+	if err != nil {
+		return nil, err
+	}
+	entry := &index.Entry{SHA: sha, Name: dir, ModeType: index.ModeTypeTree}
+	entry.SetSkipWorktree(true)
+	return entry, nil
+}
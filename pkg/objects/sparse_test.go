@@ -0,0 +1,102 @@
+package objects
+
+import (
+	"testing"
+
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/sparse"
+)
+
+func TestSparseIndex(t *testing.T) {
+	repo := setupTreeTestRepo(t)
+	defer cleanupTreeTestRepo(t, repo)
+
+	blob := &Blob{}
+	blob.Deserialize([]byte("contents\n"))
+	sha, err := WriteObject(blob, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := func(name string, skip bool, perms uint16) *index.Entry {
+		e := &index.Entry{ModeType: index.ModeTypeRegular, ModePerms: perms, SHA: sha, Name: name}
+		e.SetSkipWorktree(skip)
+		return e
+	}
+	entries := func() []*index.Entry {
+		return []*index.Entry{
+			entry("README", false, 0o644),
+			entry("in/a", false, 0o644),
+			entry("out/a", true, 0o644),
+			entry("out/deep/b", true, 0o755),
+			entry("partly/changed", false, 0o644),
+			entry("partly/sub/c", true, 0o644),
+		}
+	}
+	want, err := TreeFromIndex(repo, index.New(entries()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sparse.Write(repo, sparse.NewCone([]string{"in"})); err != nil {
+		t.Fatal(err)
+	}
+	err = sparse.WriteSettings(repo, sparse.Settings{Enabled: true, Cone: true, SparseIndex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.New(entries()).Write(repo); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := index.ReadSparse(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range stored.Entries {
+		names = append(names, e.Name)
+	}
+	// partly/ has a file in the worktree, so only its subdirectory is collapsed
+	wantNames := []string{"README", "in/a", "out/", "partly/changed", "partly/sub/"}
+	if !stored.Sparse || len(names) != len(wantNames) {
+		t.Fatalf("ReadSparse() = %q (sparse %v), want %q", names, stored.Sparse, wantNames)
+	}
+	for i := range names {
+		if names[i] != wantNames[i] {
+			t.Errorf("entry %d = %q, want %q", i, names[i], wantNames[i])
+		}
+	}
+	if tree, err := TreeFromIndex(repo, stored); err != nil || tree.AsString() != want.AsString() {
+		t.Errorf("TreeFromIndex() of the sparse index = %v, %v, want %s", tree, err, want)
+	}
+
+	expanded, err := index.Read(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expanded.Sparse || len(expanded.Entries) != 6 {
+		t.Fatalf("Read() has %d entries (sparse %v), want the 6 files", len(expanded.Entries), expanded.Sparse)
+	}
+	for i, e := range entries() {
+		got := expanded.Entries[i]
+		if got.Name != e.Name || got.SkipWorktree() != e.SkipWorktree() || string(got.TreeMode()) != string(e.TreeMode()) {
+			t.Errorf("entry %d = %s %s skip %v, want %s %s skip %v", i, got.TreeMode(), got.Name, got.SkipWorktree(), e.TreeMode(), e.Name, e.SkipWorktree())
+		}
+	}
+
+	// Without a sparse index, the index is written out in full
+	err = sparse.WriteSettings(repo, sparse.Settings{Enabled: true, Cone: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stored.Write(repo); err != nil {
+		t.Fatal(err)
+	}
+	full, err := index.ReadSparse(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Sparse || len(full.Entries) != 6 {
+		t.Errorf("ReadSparse() has %d entries (sparse %v), want the 6 files", len(full.Entries), full.Sparse)
+	}
+}
@@ -0,0 +1,69 @@
+package objects
+
+import (
+	"compress/zlib"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// WriteBlobStream stores a blob of size bytes that is read from r,
+// without holding it in memory: it is hashed and compressed as it is
+// read, into a temporary file that becomes the loose object. It is meant
+// for big files, which would take too much memory otherwise.
+func WriteBlobStream(repo *repository.Repository, r io.Reader, size int64) (*hashing.SHA, error) {
+	f, err := fs.TempFile(repo.RepositoryPath("objects"), "tmp_obj_*")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+	defer f.Close()
+
+	h := sha1.New()
+	zw := zlib.NewWriter(f)
+	out := io.MultiWriter(h, zw)
+	if _, err := fmt.Fprintf(out, "%s %d\x00", TypeBlob, size); err != nil {
+		return nil, err
+	}
+	if n, err := io.CopyN(out, r, size); err == io.EOF {
+		return nil, fmt.Errorf("blob is %d bytes instead of %d", n, size)
+	} else if err != nil {
+		return nil, err
+	}
+	// What is read has to match the size in the header
+	if n, _ := r.Read(make([]byte, 1)); n > 0 {
+		return nil, fmt.Errorf("blob is longer than %d bytes", size)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	sha := hashing.NewShaFromBytes(h.Sum(nil))
+	if HasObject(repo, sha) {
+		return sha, nil
+	}
+	hexSha := sha.AsString()
+	if _, err := repo.RepositoryDir(true, "objects", hexSha[0:2]); err != nil {
+		return nil, fmt.Errorf("failed to create directory under objects: %s", err)
+	}
+	mode, err := repo.SharedMode(0o444)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return nil, err
+	}
+	return sha, os.Rename(tmpPath, repo.RepositoryPath("objects", hexSha[0:2], hexSha[2:]))
+}
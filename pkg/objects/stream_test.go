@@ -0,0 +1,49 @@
+package objects
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteBlobStream(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	content := bytes.Repeat([]byte("streamed content\n"), 10000)
+	sha, err := WriteBlobStream(repo, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("WriteBlobStream() error = %v", err)
+	}
+	want, err := ObjectHash(content, TypeBlob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha.AsString() != want.AsString() {
+		t.Errorf("WriteBlobStream() = %s, want %s", sha.AsString(), want.AsString())
+	}
+	obj, err := ReadObject(repo, sha)
+	if err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if data, _ := obj.Serialize(); !bytes.Equal(data, content) {
+		t.Errorf("ReadObject() of a streamed blob has %d bytes, want %d", len(data), len(content))
+	}
+
+	// Writing it again keeps the object there is
+	if again, err := WriteBlobStream(repo, bytes.NewReader(content), int64(len(content))); err != nil || again.AsString() != sha.AsString() {
+		t.Errorf("WriteBlobStream() again = %v, %v, want %s", again, err, sha.AsString())
+	}
+
+	for _, size := range []int64{int64(len(content)) + 1, int64(len(content)) - 1} {
+		if _, err := WriteBlobStream(repo, bytes.NewReader(content), size); err == nil {
+			t.Errorf("WriteBlobStream() of %d bytes with size %d succeeded", len(content), size)
+		}
+	}
+	loose := 0
+	if err := ForEachLoose(repo, func(LooseObject) error { loose++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if loose != 1 {
+		t.Errorf("WriteBlobStream() left %d loose objects, want 1", loose)
+	}
+}
@@ -1,6 +1,10 @@
 package objects
 
-import "github.com/jessegeens/got/pkg/kvlm"
+import (
+	"bytes"
+
+	"github.com/jessegeens/got/pkg/kvlm"
+)
 
 type Tag Commit
 
@@ -28,3 +32,31 @@ func (t *Tag) Message() string {
 func (t *Tag) GetValue(key string) ([]byte, bool) {
 	return t.data.Okv.Get(key)
 }
+
+func (t *Tag) GetValues(key string) [][]byte {
+	return t.data.Okv.GetAll(key)
+}
+
+// Tag signatures are not stored in a header, but appended to the message
+var signatureMarkers = [][]byte{
+	[]byte("-----BEGIN PGP SIGNATURE-----"),
+	[]byte("-----BEGIN SSH SIGNATURE-----"),
+}
+
+// Signature returns the payload that was signed and the signature that
+// was appended to the tag message. The last return value is false if the
+// tag is not signed.
+func (t *Tag) Signature() ([]byte, []byte, bool) {
+	data, err := t.Serialize()
+	if err != nil {
+		return nil, nil, false
+	}
+
+	for _, marker := range signatureMarkers {
+		idx := bytes.Index(data, marker)
+		if idx >= 0 {
+			return data[:idx], data[idx:], true
+		}
+	}
+	return nil, nil, false
+}
@@ -11,9 +11,9 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/jessegeens/got/pkg/fs"
 	"github.com/jessegeens/got/pkg/hashing"
 	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/references"
 	"github.com/jessegeens/got/pkg/repository"
 )
 
@@ -47,7 +47,8 @@ func (t *Tree) Serialize() ([]byte, error) {
 
 	data := []byte{}
 	for _, leaf := range t.Items {
-		data = append(data, leaf.Mode...)
+		// Modes are zero-padded when parsed, but git writes trees as 40000
+		data = append(data, bytes.TrimLeft(leaf.Mode, "0")...)
 		data = append(data, ' ')
 		data = append(data, leaf.Path...)
 		data = append(data, 0x00)
@@ -113,6 +114,9 @@ func parseLeaf(databuffer []byte, start int) (int, *TreeLeaf, error) {
 
 	// Now we find the NULL terminator of the path
 	nullTermLoc := bytes.IndexByte(data, 0x00)
+	if nullTermLoc < spaceTermLoc || len(data) < nullTermLoc+21 {
+		return 0, nil, errors.New("truncated tree entry")
+	}
 
 	// Now we can read the path
 	path := data[spaceTermLoc+1 : nullTermLoc]
@@ -129,10 +133,10 @@ func parseLeaf(databuffer []byte, start int) (int, *TreeLeaf, error) {
 // Git sorts by file name, with a '/' added to paths of subdirectories
 // This function returns the sorting key of a specific leaf
 func sortingKey(leaf *TreeLeaf) string {
-	if strings.HasPrefix(string(leaf.Mode), "10") {
-		return string(leaf.Path)
+	if strings.HasPrefix(strings.TrimLeft(string(leaf.Mode), "0"), "4") {
+		return string(leaf.Path) + "/"
 	}
-	return string(append(leaf.Path, '/'))
+	return string(leaf.Path)
 }
 
 // Given a repository and a reference to a tree object, return the tree
@@ -144,7 +148,7 @@ func MapFromTree(repo *repository.Repository, treeRef string) (map[string]*hashi
 func mapFromTree(repo *repository.Repository, treeRef string, pathPrefix string) (map[string]*hashing.SHA, error) {
 	ret := make(map[string]*hashing.SHA)
 
-	treeSha, err := Find(repo, treeRef, TypeNoTypeSpecified, true)
+	treeSha, err := Find(repo, treeRef, TypeTree, true)
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +167,7 @@ func mapFromTree(repo *repository.Repository, treeRef string, pathPrefix string)
 
 		// If the path is a directory, (i.e. the child is another tree), we recurse
 		// Otherwise, we set the SHA
-		if fs.IsDirectory(fullPath) {
+		if strings.HasPrefix(string(leaf.Mode), "04") {
 			res, err := mapFromTree(repo, leaf.Sha.AsString(), fullPath)
 			if err != nil {
 				return nil, err
@@ -178,16 +182,72 @@ func mapFromTree(repo *repository.Repository, treeRef string, pathPrefix string)
 
 }
 
+// EmptyTree is the name of the tree without any entries. Like in git, it
+// can be read in every repository, and it is only written when a commit
+// or a tree refers to it.
+// See https://floatingoctothorpe.uk/2017/empty-trees-in-git.html
+const EmptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// EmptyTreeSHA returns the hash of the tree without any entries
+func EmptyTreeSHA() *hashing.SHA {
+	sha, _ := hashing.NewShaFromHex(EmptyTree)
+	return sha
+}
+
+// refersToEmptyTree reports whether a commit has the empty tree, or a
+// tree has it as an entry
+func refersToEmptyTree(o GitObject) bool {
+	switch o := o.(type) {
+	case *Commit:
+		tree, _ := o.GetValue("tree")
+		return string(tree) == EmptyTree
+	case *Tree:
+		return slices.ContainsFunc(o.Items, func(leaf *TreeLeaf) bool { return leaf.Sha.AsString() == EmptyTree })
+	}
+	return false
+}
+
+// HeadTree returns the tree of the commit HEAD points to. If there
+// are no commits yet, HEAD is treated as the empty tree.
+func HeadTree(repo *repository.Repository) (*hashing.SHA, error) {
+	head, err := references.Reference("HEAD").Resolve(repo)
+	if err != nil {
+		return nil, err
+	}
+	if head == "" {
+		return EmptyTreeSHA(), nil
+	}
+	return Find(repo, head, TypeTree, true)
+}
+
 func TreeFromIndex(repo *repository.Repository, idx *index.Index) (*hashing.SHA, error) {
 	return treeFromIndex(repo, idx)
 }
 
 func treeFromIndex(repo *repository.Repository, idx *index.Index) (*hashing.SHA, error) {
-	contents := make(map[string][]*index.Entry)
+	contents := make(map[string][]*TreeLeaf)
 
 	for _, e := range idx.Entries {
-		dirname := filepath.Dir(e.Name)
-		contents[dirname] = append(contents[dirname], e)
+		// The directories of a sparse index are trees already, and their
+		// names end with a slash
+		name := strings.TrimSuffix(e.Name, "/")
+		dirname := filepath.Dir(name)
+		contents[dirname] = append(contents[dirname], &TreeLeaf{
+			Mode: e.TreeMode(),
+			Sha:  e.SHA,
+			Path: []byte(filepath.Base(name)),
+		})
+	}
+
+	// Every parent directory needs a tree as well, even if
+	// it only contains other directories
+	for _, dirname := range slices.Collect(maps.Keys(contents)) {
+		for dirname != "." {
+			dirname = filepath.Dir(dirname)
+			if _, ok := contents[dirname]; !ok {
+				contents[dirname] = []*TreeLeaf{}
+			}
+		}
 	}
 
 	// We sort reversed by length, so that we always come across an element
@@ -206,25 +266,16 @@ func treeFromIndex(repo *repository.Repository, idx *index.Index) (*hashing.SHA,
 	// In our iteration, the root will come last,
 	// so we will end up with the root tree's SHA
 
-	// We initialize to the hash of an empty tree
-	// $ git hash-object -t tree /dev/null
-	// 4b825dc642cb6eb9a060e54bf8d69288fbee4904
-	// See https://floatingoctothorpe.uk/2017/empty-trees-in-git.html
-	currentSha, _ := hashing.NewShaFromHex("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	// An empty index is the empty tree, which is written like any
+	// other tree made from the index
+	if len(paths) == 0 {
+		return WriteObject(&Tree{Items: []*TreeLeaf{}}, repo)
+	}
+	var currentSha *hashing.SHA
 
 	for _, p := range paths {
 		tree := Tree{
-			Items: []*TreeLeaf{},
-		}
-
-		for _, e := range contents[p] {
-			leaf := TreeLeaf{
-				Mode: index.ModeTypeRegular.Octal(),
-				Sha:  e.SHA,
-				Path: []byte(filepath.Base(e.Name)),
-			}
-
-			tree.Items = append(tree.Items, &leaf)
+			Items: contents[p],
 		}
 
 		sha, err := WriteObject(GitObject(&tree), repo)
@@ -232,6 +283,17 @@ func treeFromIndex(repo *repository.Repository, idx *index.Index) (*hashing.SHA,
 			return nil, err
 		}
 		currentSha = sha
+
+		// The tree is an entry of its parent tree. Git does not
+		// zero-pad the mode of trees, so neither do we.
+		if p != "." {
+			parent := filepath.Dir(p)
+			contents[parent] = append(contents[parent], &TreeLeaf{
+				Mode: []byte("40000"),
+				Sha:  sha,
+				Path: []byte(filepath.Base(p)),
+			})
+		}
 	}
 
 	return currentSha, nil
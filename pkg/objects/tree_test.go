@@ -412,3 +412,100 @@ func TestTreeFromIndex_MultipleFiles_SingleDirectory(t *testing.T) {
 		t.Errorf("c.txt sha = %s, want %s", found["c.txt"], shaC.AsString())
 	}
 }
+
+func TestHeadTree_NoCommits(t *testing.T) {
+	repo := setupTreeTestRepo(t)
+	defer cleanupTreeTestRepo(t, repo)
+
+	treeSha, err := HeadTree(repo)
+	if err != nil {
+		t.Fatalf("HeadTree() error = %v", err)
+	}
+	if treeSha.AsString() != "4b825dc642cb6eb9a060e54bf8d69288fbee4904" {
+		t.Errorf("HeadTree() = %s, want the empty tree", treeSha.AsString())
+	}
+
+	// The empty tree must be readable like any other tree
+	entries, err := MapFromTree(repo, treeSha.AsString())
+	if err != nil {
+		t.Fatalf("MapFromTree() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries, got %d", len(entries))
+	}
+	// Reading it doesn't write it, so a repository without commits stays
+	// as it is
+	if HasObject(repo, treeSha) {
+		t.Errorf("HeadTree() wrote the empty tree")
+	}
+}
+
+func TestEmptyTree_WrittenWhenReferred(t *testing.T) {
+	repo := setupTreeTestRepo(t)
+	defer cleanupTreeTestRepo(t, repo)
+
+	commit := &Commit{}
+	if err := commit.Deserialize([]byte("tree " + EmptyTree + "\nauthor A <a@example.com> 0 +0000\ncommitter A <a@example.com> 0 +0000\n\nmessage\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteObject(commit, repo); err != nil {
+		t.Fatalf("WriteObject() error = %v", err)
+	}
+	if !HasObject(repo, EmptyTreeSHA()) {
+		t.Errorf("WriteObject() of a commit of the empty tree didn't write the tree")
+	}
+}
+
+func TestTreeFromIndex_Empty(t *testing.T) {
+	repo := setupTreeTestRepo(t)
+	defer cleanupTreeTestRepo(t, repo)
+
+	sha, err := TreeFromIndex(repo, index.New(nil))
+	if err != nil {
+		t.Fatalf("TreeFromIndex() error = %v", err)
+	}
+	if sha.AsString() != EmptyTree || !HasObject(repo, sha) {
+		t.Errorf("TreeFromIndex() of an empty index = %s, want the empty tree, written", sha.AsString())
+	}
+}
+
+func TestTreeFromIndex_NestedDirectories(t *testing.T) {
+	repo := setupTreeTestRepo(t)
+	defer cleanupTreeTestRepo(t, repo)
+
+	blob := &Blob{data: []byte("nested")}
+	blobSha, err := WriteObject(blob, repo)
+	if err != nil {
+		t.Fatalf("Failed to write blob: %v", err)
+	}
+
+	idx := index.New([]*index.Entry{})
+	for _, name := range []string{"top.txt", "a/b/deep.txt", "a/mid.txt"} {
+		idx.Entries = append(idx.Entries, &index.Entry{
+			ModeType:  index.ModeTypeRegular,
+			ModePerms: 0o644,
+			SHA:       blobSha,
+			Name:      name,
+		})
+	}
+
+	treeSha, err := TreeFromIndex(repo, idx)
+	if err != nil {
+		t.Fatalf("TreeFromIndex() error = %v", err)
+	}
+
+	entries, err := MapFromTree(repo, treeSha.AsString())
+	if err != nil {
+		t.Fatalf("MapFromTree() error = %v", err)
+	}
+
+	want := []string{"top.txt", "a/b/deep.txt", "a/mid.txt"}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %v", len(want), entries)
+	}
+	for _, name := range want {
+		if sha, ok := entries[name]; !ok || sha.AsString() != blobSha.AsString() {
+			t.Errorf("Expected %s to point to %s", name, blobSha.AsString())
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package objects
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// UnpackObjects writes the objects of a pack stream as loose objects,
+// and returns how many it wrote. Objects the repository already has are
+// skipped, and so can be the bases of ref deltas, as in thin packs. With
+// dryRun, the pack is only checked. With a check, nothing is written unless
+// every object in the pack passes it.
+func UnpackObjects(repo *repository.Repository, r io.Reader, dryRun bool, check CheckFunc) (int, error) {
+	base := func(sha []byte) (pack.ObjectType, []byte, error) {
+		objType, data, err := ReadRaw(repo, hashing.NewShaFromBytes(sha))
+		return packType(objType), data, err
+	}
+	type looseObject struct {
+		sha     *hashing.SHA
+		encoded []byte
+	}
+	unpacked := []looseObject{}
+	store := func(name []byte, typ pack.ObjectType, data []byte) error {
+		if check != nil {
+			if err := checkObject(check, name, typ, data); err != nil {
+				return err
+			}
+		}
+		sha := hashing.NewShaFromBytes(name)
+		if HasObject(repo, sha) {
+			return nil
+		}
+		encoded := append([]byte(fmt.Sprintf("%s %d\x00", typ, len(data))), data...)
+		unpacked = append(unpacked, looseObject{sha, encoded})
+		return nil
+	}
+	if err := pack.Unpack(r, base, store); err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return len(unpacked), nil
+	}
+	for _, obj := range unpacked {
+		if err := writeLoose(repo, obj.sha, obj.encoded); err != nil {
+			return 0, err
+		}
+	}
+	return len(unpacked), nil
+}
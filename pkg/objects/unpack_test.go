@@ -0,0 +1,52 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+func TestUnpackObjects(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	existing, err := ObjectHash([]byte("already here\n"), TypeBlob, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Repeat([]byte("a line that every blob has\n"), 50)
+	objects := []pack.Object{{Name: existing.AsBytes(), Type: pack.TypeBlob, Data: []byte("already here\n")}}
+	for i := range 3 {
+		data := append(append([]byte{}, content...), byte('0'+i))
+		objects = append(objects, pack.Object{Name: hashing.NewSHA(append([]byte(fmt.Sprintf("blob %d\x00", len(data))), data...)).AsBytes(), Type: pack.TypeBlob, Data: data})
+	}
+	var stream bytes.Buffer
+	if _, _, err := pack.Write(&stream, objects, pack.DefaultWriteOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := UnpackObjects(repo, bytes.NewReader(stream.Bytes()), true, nil); err != nil || n != 3 {
+		t.Errorf("UnpackObjects() dry run = %d, %v, want 3", n, err)
+	}
+	if HasObject(repo, hashing.NewShaFromBytes(objects[1].Name)) {
+		t.Errorf("UnpackObjects() dry run wrote objects")
+	}
+
+	n, err := UnpackObjects(repo, bytes.NewReader(stream.Bytes()), false, nil)
+	if err != nil {
+		t.Fatalf("UnpackObjects() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("UnpackObjects() wrote %d objects, want 3", n)
+	}
+	for i, obj := range objects {
+		hexSha := hashing.NewShaFromBytes(obj.Name).AsString()
+		if !fs.IsFile(repo.RepositoryPath("objects", hexSha[:2], hexSha[2:])) {
+			t.Errorf("UnpackObjects() didn't write object %d loose", i)
+		}
+	}
+}
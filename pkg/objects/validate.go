@@ -0,0 +1,117 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Reasons a commit is rejected by ValidateCommit, wrapped in a CommitError
+var (
+	ErrMissingHeader   = errors.New("header is missing")
+	ErrDuplicateHeader = errors.New("header occurs more than once")
+	ErrMissingObject   = errors.New("object does not exist")
+	ErrWrongType       = errors.New("object has the wrong type")
+	ErrBadIdent        = errors.New("malformed identity")
+)
+
+// CommitError describes the header that makes a commit invalid
+type CommitError struct {
+	// tree, parent, author or committer
+	Header string
+	Value  string
+	Err    error
+}
+
+func (e *CommitError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("invalid commit: %s: %s", e.Header, e.Err)
+	}
+	return fmt.Sprintf("invalid commit: %s '%s': %s", e.Header, e.Value, e.Err)
+}
+
+func (e *CommitError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateCommit checks that a commit is consistent before it is written:
+// its tree and parents exist and have the right type, and it has an author
+// and a committer that parse. Unlike the fsck checks, it can't be disabled.
+func ValidateCommit(repo *repository.Repository, commit *Commit) error {
+	trees := commit.GetValues("tree")
+	if len(trees) != 1 {
+		return singleHeaderError("tree", len(trees))
+	}
+	if err := validateReference(repo, "tree", trees[0], TypeTree); err != nil {
+		return err
+	}
+
+	for _, parent := range commit.GetValues("parent") {
+		if err := validateReference(repo, "parent", parent, TypeCommit); err != nil {
+			return err
+		}
+	}
+
+	for _, header := range []string{"author", "committer"} {
+		idents := commit.GetValues(header)
+		if len(idents) != 1 {
+			return singleHeaderError(header, len(idents))
+		}
+		if err := validateIdent(idents[0]); err != nil {
+			return &CommitError{Header: header, Value: string(idents[0]), Err: err}
+		}
+	}
+	return nil
+}
+
+func singleHeaderError(header string, count int) error {
+	if count == 0 {
+		return &CommitError{Header: header, Err: ErrMissingHeader}
+	}
+	return &CommitError{Header: header, Err: ErrDuplicateHeader}
+}
+
+// validateReference checks that the object a header refers to exists and has the expected type
+func validateReference(repo *repository.Repository, header string, value []byte, want GitObjectType) error {
+	sha, err := hashing.NewShaFromHex(string(value))
+	if err != nil {
+		return &CommitError{Header: header, Value: string(value), Err: err}
+	}
+	typ, _, err := ReadHeader(repo, sha)
+	if errors.Is(err, os.ErrNotExist) {
+		return &CommitError{Header: header, Value: string(value), Err: ErrMissingObject}
+	} else if err != nil {
+		return &CommitError{Header: header, Value: string(value), Err: err}
+	}
+	if typ != want {
+		return &CommitError{Header: header, Value: string(value), Err: fmt.Errorf("%w: expected a %s, found a %s", ErrWrongType, want, typ)}
+	}
+	return nil
+}
+
+var identDateRegex = regexp.MustCompile(`^ [0-9]+ [+-][0-9]{4}$`)
+
+// validateIdent checks that an identity has the form "Name <email> timestamp zone"
+func validateIdent(data []byte) error {
+	ident := string(data)
+	start := strings.IndexByte(ident, '<')
+	end := strings.IndexByte(ident, '>')
+	switch {
+	case strings.ContainsAny(ident, "\n\x00"):
+		return fmt.Errorf("%w: it contains a newline or NUL", ErrBadIdent)
+	case start < 0 || end < start || strings.Count(ident, "<") > 1 || strings.Count(ident, ">") > 1:
+		return fmt.Errorf("%w: expected an email between < and >", ErrBadIdent)
+	case strings.TrimSpace(ident[:start]) == "":
+		return fmt.Errorf("%w: the name is empty", ErrBadIdent)
+	case !strings.HasSuffix(ident[:start], " "):
+		return fmt.Errorf("%w: expected a space before the email", ErrBadIdent)
+	case !identDateRegex.MatchString(ident[end+1:]):
+		return fmt.Errorf("%w: expected a timestamp and timezone after the email", ErrBadIdent)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package objects
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/kvlm"
+)
+
+func TestValidateCommit(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	blob, _ := WriteObject(&Blob{data: []byte("content")}, repo)
+	tree, _ := WriteObject(&Tree{Items: []*TreeLeaf{{Mode: []byte("100644"), Path: []byte("file"), Sha: blob}}}, repo)
+	ident := Ident{Name: "A U Thor", Email: "author@example.com", When: time.Unix(1700000000, 0).UTC()}
+	parent, err := CreateCommit(repo, tree, nil, ident, ident, "parent")
+	if err != nil {
+		t.Fatalf("CreateCommit() error = %v", err)
+	}
+	missing := "0123456789012345678901234567890123456789"
+	valid := "A U Thor <author@example.com> 1700000000 +0000"
+
+	tests := []struct {
+		name    string
+		headers [][2]string
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			headers: [][2]string{{"tree", tree.AsString()}, {"parent", parent.AsString()}, {"author", valid}, {"committer", valid}},
+		},
+		{
+			name:    "root commit",
+			headers: [][2]string{{"tree", tree.AsString()}, {"author", valid}, {"committer", valid}},
+		},
+		{
+			name:    "no tree",
+			headers: [][2]string{{"author", valid}, {"committer", valid}},
+			wantErr: ErrMissingHeader,
+		},
+		{
+			name:    "two trees",
+			headers: [][2]string{{"tree", tree.AsString()}, {"tree", tree.AsString()}, {"author", valid}, {"committer", valid}},
+			wantErr: ErrDuplicateHeader,
+		},
+		{
+			name:    "missing tree",
+			headers: [][2]string{{"tree", missing}, {"author", valid}, {"committer", valid}},
+			wantErr: ErrMissingObject,
+		},
+		{
+			name:    "tree is a blob",
+			headers: [][2]string{{"tree", blob.AsString()}, {"author", valid}, {"committer", valid}},
+			wantErr: ErrWrongType,
+		},
+		{
+			name:    "missing parent",
+			headers: [][2]string{{"tree", tree.AsString()}, {"parent", missing}, {"author", valid}, {"committer", valid}},
+			wantErr: ErrMissingObject,
+		},
+		{
+			name:    "parent is a tree",
+			headers: [][2]string{{"tree", tree.AsString()}, {"parent", tree.AsString()}, {"author", valid}, {"committer", valid}},
+			wantErr: ErrWrongType,
+		},
+		{
+			name:    "no committer",
+			headers: [][2]string{{"tree", tree.AsString()}, {"author", valid}},
+			wantErr: ErrMissingHeader,
+		},
+		{
+			name:    "ident without email",
+			headers: [][2]string{{"tree", tree.AsString()}, {"author", "jesse"}, {"committer", valid}},
+			wantErr: ErrBadIdent,
+		},
+		{
+			name:    "ident without date",
+			headers: [][2]string{{"tree", tree.AsString()}, {"author", "A U Thor <author@example.com>"}, {"committer", valid}},
+			wantErr: ErrBadIdent,
+		},
+		{
+			name:    "ident with bad timezone",
+			headers: [][2]string{{"tree", tree.AsString()}, {"author", valid}, {"committer", "C <c@example.com> 1700000000 0000"}},
+			wantErr: ErrBadIdent,
+		},
+		{
+			name:    "ident without name",
+			headers: [][2]string{{"tree", tree.AsString()}, {"author", " <author@example.com> 1700000000 +0000"}, {"committer", valid}},
+			wantErr: ErrBadIdent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := kvlm.New()
+			for _, h := range tt.headers {
+				data.Okv.Set(h[0], []byte(h[1]))
+			}
+			data.Message = []byte("message\n")
+
+			err := ValidateCommit(repo, NewCommit(data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateCommit() error = %v, want %v", err, tt.wantErr)
+			}
+			var commitErr *CommitError
+			if tt.wantErr != nil && !errors.As(err, &commitErr) {
+				t.Errorf("ValidateCommit() error = %T, want a *CommitError", err)
+			}
+		})
+	}
+}
+
+func TestCreateCommitRejectsMissingTree(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	ident := Ident{Name: "A U Thor", Email: "author@example.com", When: time.Unix(1700000000, 0).UTC()}
+	tree, _ := WriteObject(&Blob{data: []byte("not a tree")}, repo)
+	sha, err := CreateCommit(repo, tree, nil, ident, ident, "message")
+	if !errors.Is(err, ErrWrongType) || sha != nil {
+		t.Errorf("CreateCommit() = %v, %v, want ErrWrongType", sha, err)
+	}
+}
+
+// stubSigner remembers what it signed, and returns a fixed signature
+type stubSigner struct{ payload []byte }
+
+func (s *stubSigner) Sign(payload []byte) ([]byte, error) {
+	s.payload = payload
+	return []byte("-----BEGIN SSH SIGNATURE-----\nsig\n-----END SSH SIGNATURE-----\n"), nil
+}
+
+func TestCreateSignedCommit(t *testing.T) {
+	repo := setupTestRepo(t)
+	defer cleanupTestRepo(t, repo)
+
+	ident := Ident{Name: "A U Thor", Email: "author@example.com", When: time.Unix(1700000000, 0).UTC()}
+	tree, _ := WriteObject(&Tree{}, repo)
+	signer := &stubSigner{}
+	sha, err := CreateSignedCommit(repo, tree, nil, ident, ident, "message", signer)
+	if err != nil {
+		t.Fatalf("CreateSignedCommit() error = %v", err)
+	}
+	obj, err := ReadObject(repo, sha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, ok := obj.(*Commit).Signature()
+	if !ok {
+		t.Fatal("CreateSignedCommit() made an unsigned commit")
+	}
+	if string(payload) != string(signer.payload) {
+		t.Errorf("Signature() payload = %q, want the signed payload %q", payload, signer.payload)
+	}
+	if want := "-----BEGIN SSH SIGNATURE-----\nsig\n-----END SSH SIGNATURE-----"; string(sig) != want {
+		t.Errorf("Signature() = %q, want %q", sig, want)
+	}
+}
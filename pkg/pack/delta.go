@@ -0,0 +1,200 @@
+package pack
+
+import "errors"
+
+var errCorruptDelta = errors.New("corrupt delta")
+
+// ApplyDelta rebuilds an object from its base and a delta. A delta starts
+// with the sizes of the base and the result, followed by instructions
+// that either copy a range of the base or insert new bytes.
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, n := deltaSize(delta)
+	if n == 0 || baseSize != uint64(len(base)) {
+		return nil, errCorruptDelta
+	}
+	delta = delta[n:]
+	resultSize, n := deltaSize(delta)
+	if n == 0 {
+		return nil, errCorruptDelta
+	}
+	delta = delta[n:]
+
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// The low bits say which bytes of the offset and size follow
+			var offset, size uint64
+			for i := range 7 {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errCorruptDelta
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errCorruptDelta
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errCorruptDelta
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			// Opcode 0 is reserved
+			return nil, errCorruptDelta
+		}
+	}
+	if uint64(len(result)) != resultSize {
+		return nil, errCorruptDelta
+	}
+	return result, nil
+}
+
+// DeltaResultSize returns the size of the object a delta rebuilds
+func DeltaResultSize(delta []byte) (uint64, error) {
+	_, n := deltaSize(delta)
+	if n == 0 {
+		return 0, errCorruptDelta
+	}
+	size, m := deltaSize(delta[n:])
+	if m == 0 {
+		return 0, errCorruptDelta
+	}
+	return size, nil
+}
+
+// deltaSize reads a size of 7 bits per byte, least significant first,
+// and returns how many bytes it took, or 0 if it was cut off
+func deltaSize(data []byte) (uint64, int) {
+	var size uint64
+	for i, b := range data {
+		if i > 9 {
+			return 0, 0
+		}
+		size |= uint64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return size, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// deltaBlock is the size of the blocks of the base that CreateDelta looks
+// for in the target. Shorter matches aren't found.
+const deltaBlock = 16
+
+// maxDeltaCopy is the most one copy instruction copies when CreateDelta
+// writes it, like git does
+const maxDeltaCopy = 0x10000
+
+// maxBlockCandidates limits where a block of the base is remembered, for
+// bases that repeat the same block over and over
+const maxBlockCandidates = 8
+
+// CreateDelta makes a delta that rebuilds target from base. The blocks of
+// the base are indexed, and every match in the target is extended as far
+// as it goes in both directions. What doesn't match is inserted.
+func CreateDelta(base, target []byte) []byte {
+	delta := appendDeltaSize(nil, len(base))
+	delta = appendDeltaSize(delta, len(target))
+
+	blocks := map[[deltaBlock]byte][]int{}
+	for i := 0; i+deltaBlock <= len(base); i += deltaBlock {
+		key := [deltaBlock]byte(base[i : i+deltaBlock])
+		if len(blocks[key]) < maxBlockCandidates {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	insertFrom := 0
+	for i := 0; i+deltaBlock <= len(target); {
+		offset, length := 0, 0
+		for _, candidate := range blocks[[deltaBlock]byte(target[i:i+deltaBlock])] {
+			if n := matchLength(base[candidate:], target[i:]); n > length {
+				offset, length = candidate, n
+			}
+		}
+		if length == 0 {
+			i++
+			continue
+		}
+		for offset > 0 && i > insertFrom && base[offset-1] == target[i-1] {
+			offset--
+			i--
+			length++
+		}
+		delta = appendDeltaInsert(delta, target[insertFrom:i])
+		delta = appendDeltaCopy(delta, offset, length)
+		i += length
+		insertFrom = i
+	}
+	return appendDeltaInsert(delta, target[insertFrom:])
+}
+
+func matchLength(a, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func appendDeltaSize(delta []byte, size int) []byte {
+	for size >= 0x80 {
+		delta = append(delta, byte(size&0x7f)|0x80)
+		size >>= 7
+	}
+	return append(delta, byte(size))
+}
+
+// appendDeltaInsert inserts data, at most 127 bytes per instruction
+func appendDeltaInsert(delta, data []byte) []byte {
+	for len(data) > 0 {
+		n := min(len(data), 0x7f)
+		delta = append(delta, byte(n))
+		delta = append(delta, data[:n]...)
+		data = data[n:]
+	}
+	return delta
+}
+
+// appendDeltaCopy copies a range of the base. Only the bytes of the offset
+// and size that aren't zero are written, with a bit for each in the opcode.
+func appendDeltaCopy(delta []byte, offset, size int) []byte {
+	for size > 0 {
+		n := min(size, maxDeltaCopy)
+		op := byte(0x80)
+		args := []byte{}
+		for i := range 4 {
+			if b := byte(offset >> (8 * i)); b != 0 {
+				op |= 1 << i
+				args = append(args, b)
+			}
+		}
+		for i := range 3 {
+			if b := byte(n >> (8 * i)); b != 0 {
+				op |= 1 << (4 + i)
+				args = append(args, b)
+			}
+		}
+		delta = append(append(delta, op), args...)
+		offset += n
+		size -= n
+	}
+	return delta
+}
@@ -0,0 +1,245 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// indexMagic starts version 2 indexes. Version 1 has no header.
+var indexMagic = []byte{0xff, 't', 'O', 'c'}
+
+// Index is a parsed pack index (.idx) file, which has the sorted names
+// of the objects in a pack and where they are in it
+type Index struct {
+	fanout [256]uint32
+	// names holds the sorted object names, stride bytes apart
+	names  []byte
+	stride int
+	// offset is the position of a name within its record
+	offset int
+	// Version 1 keeps the pack offsets in the records with the names,
+	// version 2 in a table of its own, with a table of 8 byte offsets for
+	// packs larger than 2GB
+	offsets      []byte
+	largeOffsets []byte
+	// crcs has the CRC-32 of every packed object, in version 2 only
+	crcs []byte
+	// packChecksum is the checksum at the end of the pack
+	packChecksum []byte
+	version      int
+}
+
+// ParseIndex parses a version 1 or 2 pack index. A missing file, which
+// is nil data, parses as an empty index.
+func ParseIndex(data []byte) (*Index, error) {
+	idx := &Index{stride: 20, version: 2}
+	if data == nil {
+		return idx, nil
+	}
+
+	// Both versions end with the checksum of the pack and of the index
+	if len(data) < 2*sha1.Size {
+		return nil, errors.New("pack index is too short")
+	}
+	trailer := data[len(data)-2*sha1.Size:]
+	if sum := sha1.Sum(data[:len(data)-sha1.Size]); !bytes.Equal(sum[:], trailer[sha1.Size:]) {
+		return nil, errors.New("pack index checksum mismatch")
+	}
+	idx.packChecksum = trailer[:sha1.Size]
+	data = data[:len(data)-2*sha1.Size]
+
+	if bytes.HasPrefix(data, indexMagic) {
+		if len(data) < 8 {
+			return nil, errors.New("pack index is too short")
+		}
+		if version := binary.BigEndian.Uint32(data[4:8]); version != 2 {
+			return nil, fmt.Errorf("unsupported pack index version %d", version)
+		}
+		data = data[8:]
+	} else {
+		idx.stride = 24
+		idx.offset = 4
+		idx.version = 1
+	}
+
+	if len(data) < 256*4 {
+		return nil, errors.New("pack index is too short")
+	}
+	for i := range idx.fanout {
+		idx.fanout[i] = binary.BigEndian.Uint32(data[i*4:])
+		if i > 0 && idx.fanout[i] < idx.fanout[i-1] {
+			return nil, errors.New("pack index has a corrupt fanout table")
+		}
+	}
+	data = data[256*4:]
+
+	count := int(idx.fanout[255])
+	if len(data) < count*idx.stride {
+		return nil, errors.New("pack index is truncated")
+	}
+	idx.names = data[:count*idx.stride]
+	data = data[count*idx.stride:]
+	if idx.version == 1 {
+		return idx, nil
+	}
+
+	// The CRCs of the packed objects come before their offsets
+	if len(data) < count*8 {
+		return nil, errors.New("pack index is truncated")
+	}
+	idx.crcs = data[:count*4]
+	idx.offsets = data[count*4 : count*8]
+	idx.largeOffsets = data[count*8:]
+	return idx, nil
+}
+
+// Version returns the version of the index file
+func (idx *Index) Version() int {
+	return idx.version
+}
+
+// Count returns the number of objects in the pack
+func (idx *Index) Count() int {
+	return int(idx.fanout[255])
+}
+
+// Name returns the name of the i-th object, in sorted order
+func (idx *Index) Name(i int) []byte {
+	start := i*idx.stride + idx.offset
+	return idx.names[start : start+20]
+}
+
+// Offset returns where the i-th object starts in the pack
+func (idx *Index) Offset(i int) (int64, error) {
+	if idx.version == 1 {
+		return int64(binary.BigEndian.Uint32(idx.names[i*idx.stride:])), nil
+	}
+	offset := binary.BigEndian.Uint32(idx.offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), nil
+	}
+	large := int(offset&0x7fffffff) * 8
+	if large+8 > len(idx.largeOffsets) {
+		return 0, errors.New("pack index has a corrupt large offset")
+	}
+	return int64(binary.BigEndian.Uint64(idx.largeOffsets[large:])), nil
+}
+
+// CRC returns the CRC-32 of the i-th object as it is stored in the pack,
+// which version 1 indexes don't have
+func (idx *Index) CRC(i int) (uint32, bool) {
+	if idx.version == 1 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(idx.crcs[i*4:]), true
+}
+
+// PackChecksum returns the checksum at the end of the pack, which names it
+func (idx *Index) PackChecksum() []byte {
+	return idx.packChecksum
+}
+
+// Bounds uses the fanout table to find the range of names starting with
+// the given byte
+func (idx *Index) Bounds(first byte) (int, int) {
+	lo := 0
+	if first > 0 {
+		lo = int(idx.fanout[first-1])
+	}
+	hi := int(idx.fanout[first])
+	return min(lo, hi), hi
+}
+
+// Find returns the position of an object in the index
+func (idx *Index) Find(sha []byte) (int, bool) {
+	lo, hi := idx.Bounds(sha[0])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(idx.Name(lo+i), sha) >= 0
+	})
+	return i, i < hi && bytes.Equal(idx.Name(i), sha)
+}
+
+// Contains reports whether the pack has an object
+func (idx *Index) Contains(sha []byte) bool {
+	_, ok := idx.Find(sha)
+	return ok
+}
+
+// WithPrefix returns the hex names of the objects that start with a hex
+// prefix of at least two characters
+func (idx *Index) WithPrefix(prefix string) []string {
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	lo, hi := idx.Bounds(first[0])
+	for i := lo; i < hi; i++ {
+		if name := hex.EncodeToString(idx.Name(i)); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// IndexEntry is an object of a pack, to write to its index
+type IndexEntry struct {
+	Name   []byte
+	Offset int64
+	// CRC is the CRC-32 of the object as it is stored in the pack
+	CRC uint32
+}
+
+// WriteIndex writes a version 2 index of a pack with the given objects
+// and checksum. Offsets that don't fit in 31 bits go in the table of
+// large offsets.
+func WriteIndex(w io.Writer, entries []IndexEntry, packChecksum []byte) error {
+	sorted := append([]IndexEntry{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Name, sorted[j].Name) < 0 })
+
+	h := sha1.New()
+	out := io.MultiWriter(w, h)
+	data := append([]byte{}, indexMagic...)
+	data = binary.BigEndian.AppendUint32(data, 2)
+	var fanout [256]uint32
+	for _, e := range sorted {
+		fanout[e.Name[0]]++
+	}
+	count := uint32(0)
+	for _, n := range fanout {
+		count += n
+		data = binary.BigEndian.AppendUint32(data, count)
+	}
+	for i, e := range sorted {
+		if i > 0 && bytes.Equal(e.Name, sorted[i-1].Name) {
+			return fmt.Errorf("object %s is in the pack twice", hex.EncodeToString(e.Name))
+		}
+		data = append(data, e.Name...)
+	}
+	for _, e := range sorted {
+		data = binary.BigEndian.AppendUint32(data, e.CRC)
+	}
+	large := []byte{}
+	for _, e := range sorted {
+		if e.Offset < 0x80000000 {
+			data = binary.BigEndian.AppendUint32(data, uint32(e.Offset))
+			continue
+		}
+		data = binary.BigEndian.AppendUint32(data, 0x80000000|uint32(len(large)/8))
+		large = binary.BigEndian.AppendUint64(large, uint64(e.Offset))
+	}
+	data = append(data, large...)
+	data = append(data, packChecksum...)
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write(h.Sum(nil))
+	return err
+}
@@ -0,0 +1,107 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"hash/crc32"
+	"testing"
+)
+
+func TestParseIndex(t *testing.T) {
+	name := bytes.Repeat([]byte{0xab}, 20)
+	objects := []testObject{{name: name}}
+	valid := buildIndex(objects, []int64{12})
+	unsupported := append([]byte{}, valid...)
+	unsupported[7] = 3
+	sum := sha1.Sum(unsupported[:len(unsupported)-sha1.Size])
+	copy(unsupported[len(unsupported)-sha1.Size:], sum[:])
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"missing file", nil, false},
+		{"valid", valid, false},
+		{"too short", []byte{1, 2, 3}, true},
+		{"unsupported version", unsupported, true},
+		{"truncated", valid[:len(valid)-2], true},
+		{"bad checksum", append(append([]byte{}, valid[:len(valid)-1]...), 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseIndex(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseIndex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	idx, _ := ParseIndex(valid)
+	if i, ok := idx.Find(name); !ok || i != 0 {
+		t.Errorf("Find() = %d, %v, want 0, true", i, ok)
+	}
+	if offset, err := idx.Offset(0); err != nil || offset != 12 {
+		t.Errorf("Offset() = %d, %v, want 12", offset, err)
+	}
+	if got := idx.WithPrefix("abab"); len(got) != 1 {
+		t.Errorf("WithPrefix() = %v, want one name", got)
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	data, objects, _ := testStream(t)
+	s, err := NewScanner(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []IndexEntry{}
+	for i := range objects {
+		e, err := s.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := int64(len(data) - sha1.Size)
+		if i+1 < len(objects) {
+			end = s.Offset()
+		}
+		if want := crc32.ChecksumIEEE(data[e.Offset:end]); e.CRC != want {
+			t.Errorf("entry %d has CRC %08x, want %08x", i, e.CRC, want)
+		}
+		entries = append(entries, IndexEntry{Name: objects[i].name, Offset: e.Offset, CRC: e.CRC})
+	}
+	// One more object, far enough in a pack to need a large offset
+	large := IndexEntry{Name: bytes.Repeat([]byte{0x01}, 20), Offset: 5 << 32, CRC: 7}
+	entries = append(entries, large)
+
+	var buf bytes.Buffer
+	if err := WriteIndex(&buf, entries, data[len(data)-sha1.Size:]); err != nil {
+		t.Fatalf("WriteIndex() error = %v", err)
+	}
+	idx, err := ParseIndex(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseIndex() error = %v", err)
+	}
+	if idx.Count() != len(entries) {
+		t.Errorf("Count() = %d, want %d", idx.Count(), len(entries))
+	}
+	if !bytes.Equal(idx.PackChecksum(), data[len(data)-sha1.Size:]) {
+		t.Errorf("PackChecksum() = %x, want the checksum of the pack", idx.PackChecksum())
+	}
+	for _, e := range entries {
+		i, ok := idx.Find(e.Name)
+		if !ok {
+			t.Fatalf("Find(%x) found nothing", e.Name)
+		}
+		if offset, err := idx.Offset(i); err != nil || offset != e.Offset {
+			t.Errorf("Offset() = %d, %v, want %d", offset, err, e.Offset)
+		}
+		if crc, ok := idx.CRC(i); !ok || crc != e.CRC {
+			t.Errorf("CRC() = %08x, %v, want %08x", crc, ok, e.CRC)
+		}
+	}
+
+	if err := WriteIndex(&buf, append(entries, large), nil); err == nil {
+		t.Error("WriteIndex() with an object twice error = nil, want an error")
+	}
+}
@@ -0,0 +1,73 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// mtimesMagic starts the .mtimes file of a cruft pack
+var mtimesMagic = []byte{'M', 'T', 'M', 'E'}
+
+// WriteMtimes writes the .mtimes file of a cruft pack, which has when
+// each of its unreachable objects was last written, so they can expire
+// one by one. The times are in the order of the index, by object name.
+func WriteMtimes(w io.Writer, mtimes []uint32, packChecksum []byte) error {
+	data := append([]byte{}, mtimesMagic...)
+	// Version 1, with SHA-1 object names
+	data = binary.BigEndian.AppendUint32(data, 1)
+	data = binary.BigEndian.AppendUint32(data, 1)
+	for _, mtime := range mtimes {
+		data = binary.BigEndian.AppendUint32(data, mtime)
+	}
+	data = append(data, packChecksum...)
+	sum := sha1.Sum(data)
+	_, err := w.Write(append(data, sum[:]...))
+	return err
+}
+
+// ParseMtimes parses the .mtimes file of a cruft pack with count objects
+// and the given checksum
+func ParseMtimes(data []byte, count int, packChecksum []byte) ([]uint32, error) {
+	if len(data) != 12+4*count+2*sha1.Size {
+		return nil, errors.New("pack mtimes file has the wrong size")
+	}
+	if sum := sha1.Sum(data[:len(data)-sha1.Size]); !bytes.Equal(sum[:], data[len(data)-sha1.Size:]) {
+		return nil, errors.New("pack mtimes checksum mismatch")
+	}
+	if !bytes.HasPrefix(data, mtimesMagic) {
+		return nil, errors.New("not a pack mtimes file")
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != 1 {
+		return nil, fmt.Errorf("unsupported pack mtimes version %d", version)
+	}
+	if hash := binary.BigEndian.Uint32(data[8:12]); hash != 1 {
+		return nil, fmt.Errorf("unsupported pack mtimes hash %d", hash)
+	}
+	if !bytes.Equal(data[12+4*count:len(data)-sha1.Size], packChecksum) {
+		return nil, errors.New("pack mtimes file is for another pack")
+	}
+	mtimes := make([]uint32, count)
+	for i := range mtimes {
+		mtimes[i] = binary.BigEndian.Uint32(data[12+4*i:])
+	}
+	return mtimes, nil
+}
+
+// Mtimes returns the times in the .mtimes file of a cruft pack, in the
+// order of its index. Other packs have none.
+func (p *Pack) Mtimes() ([]uint32, error) {
+	data, err := os.ReadFile(strings.TrimSuffix(p.Path, ".pack") + ".mtimes")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseMtimes(data, p.Index.Count(), p.Index.PackChecksum())
+}
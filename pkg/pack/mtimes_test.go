@@ -0,0 +1,44 @@
+package pack
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMtimes(t *testing.T) {
+	p, _, _ := writeTestPack(t)
+	if mtimes, err := p.Mtimes(); err != nil || mtimes != nil {
+		t.Fatalf("Mtimes() of a pack that isn't cruft = %v, %v, want none", mtimes, err)
+	}
+
+	want := []uint32{1700000000, 1700000001, 1600000000, 1800000000}
+	var data bytes.Buffer
+	if err := WriteMtimes(&data, want, p.Index.PackChecksum()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(strings.TrimSuffix(p.Path, ".pack")+".mtimes", data.Bytes(), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	mtimes, err := p.Mtimes()
+	if err != nil {
+		t.Fatalf("Mtimes() error = %v", err)
+	}
+	if !slices.Equal(mtimes, want) {
+		t.Errorf("Mtimes() = %v, want %v", mtimes, want)
+	}
+
+	if _, err := ParseMtimes(data.Bytes(), 3, p.Index.PackChecksum()); err == nil {
+		t.Error("ParseMtimes() with the wrong count succeeded")
+	}
+	if _, err := ParseMtimes(data.Bytes(), 4, bytes.Repeat([]byte{1}, 20)); err == nil {
+		t.Error("ParseMtimes() for another pack succeeded")
+	}
+	corrupt := bytes.Clone(data.Bytes())
+	corrupt[13]++
+	if _, err := ParseMtimes(corrupt, 4, p.Index.PackChecksum()); err == nil {
+		t.Error("ParseMtimes() with a bad checksum succeeded")
+	}
+}
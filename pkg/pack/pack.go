@@ -0,0 +1,306 @@
+// Package pack reads packfiles, which store many objects in one file,
+// compressed and often as deltas against other objects. Every .pack
+// file has an .idx file with the sorted names of its objects and where
+// they start in the pack.
+package pack
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// ObjectType is the type of an entry in a pack
+type ObjectType byte
+
+const (
+	TypeCommit ObjectType = 1
+	TypeTree   ObjectType = 2
+	TypeBlob   ObjectType = 3
+	TypeTag    ObjectType = 4
+	// A delta against an object earlier in the pack, at a relative offset
+	TypeOfsDelta ObjectType = 6
+	// A delta against an object by name
+	TypeRefDelta ObjectType = 7
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case TypeCommit:
+		return "commit"
+	case TypeTree:
+		return "tree"
+	case TypeBlob:
+		return "blob"
+	case TypeTag:
+		return "tag"
+	case TypeOfsDelta:
+		return "ofs-delta"
+	case TypeRefDelta:
+		return "ref-delta"
+	}
+	return fmt.Sprintf("unknown type %d", byte(t))
+}
+
+// ErrNotFound is returned for objects that aren't in a pack
+var ErrNotFound = errors.New("object not found in pack")
+
+// maxDeltaDepth guards against deltas that refer to each other in a loop.
+// git's default depth is 50, so real chains are far shorter.
+const maxDeltaDepth = 10000
+
+// Pack is a packfile with its index
+type Pack struct {
+	// Path is the .pack file
+	Path  string
+	Index *Index
+	// Base reads the base of a delta that isn't in the pack, like the ones
+	// of thin packs. Without it, such deltas can't be read.
+	Base func(sha []byte) (ObjectType, []byte, error)
+}
+
+// List returns the packs in the objects/pack directory of a repository.
+// Their indexes are cached until they change on disk.
+func List(repo *repository.Repository) ([]*Pack, error) {
+	idxFiles, err := filepath.Glob(repo.RepositoryPath("objects", "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	packs := []*Pack{}
+	for _, idxFile := range idxFiles {
+		value, err := repo.CachedFile(idxFile, func(data []byte) (any, error) {
+			return ParseIndex(data)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(idxFile), err)
+		}
+		packs = append(packs, &Pack{Path: strings.TrimSuffix(idxFile, ".idx") + ".pack", Index: value.(*Index)})
+	}
+	return packs, nil
+}
+
+// Read returns the type and contents of an object, with its deltas
+// applied
+func (p *Pack) Read(sha []byte) (ObjectType, []byte, error) {
+	i, ok := p.Index.Find(sha)
+	if !ok {
+		return 0, nil, ErrNotFound
+	}
+	offset, err := p.Index.Offset(i)
+	if err != nil {
+		return 0, nil, err
+	}
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	return p.ReadAt(f, offset)
+}
+
+// Header returns the type and size of an object. Only the first entry of
+// a delta chain is inflated, and the bases just enough to find their type.
+func (p *Pack) Header(sha []byte) (ObjectType, int64, error) {
+	i, ok := p.Index.Find(sha)
+	if !ok {
+		return 0, 0, ErrNotFound
+	}
+	offset, err := p.Index.Offset(i)
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	e, err := readEntry(f, offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !e.isDelta() {
+		return e.typ, e.size, nil
+	}
+	data, err := e.inflate()
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err := DeltaResultSize(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	// The type is the one of the object at the end of the chain
+	for depth := 0; e.isDelta(); depth++ {
+		if depth > maxDeltaDepth {
+			return 0, 0, errors.New("delta chain is too long")
+		}
+		if e.typ == TypeRefDelta {
+			typ, _, err := p.readRef(f, e.baseName)
+			return typ, int64(size), err
+		}
+		if e, err = readEntry(f, e.baseOffset); err != nil {
+			return 0, 0, err
+		}
+	}
+	return e.typ, int64(size), nil
+}
+
+// ReadAt reads the object at an offset of the pack, and the bases of its
+// deltas
+func (p *Pack) ReadAt(r io.ReaderAt, offset int64) (ObjectType, []byte, error) {
+	// Deltas are applied from the base up, so the chain is collected first
+	deltas := [][]byte{}
+	var typ ObjectType
+	var data []byte
+	for {
+		if len(deltas) > maxDeltaDepth {
+			return 0, nil, errors.New("delta chain is too long")
+		}
+		e, err := readEntry(r, offset)
+		if err != nil {
+			return 0, nil, err
+		}
+		inflated, err := e.inflate()
+		if err != nil {
+			return 0, nil, err
+		}
+		if !e.isDelta() {
+			typ, data = e.typ, inflated
+			break
+		}
+		deltas = append(deltas, inflated)
+		if e.typ == TypeOfsDelta {
+			offset = e.baseOffset
+			continue
+		}
+		if typ, data, err = p.readRef(r, e.baseName); err != nil {
+			return 0, nil, err
+		}
+		break
+	}
+
+	for i := len(deltas) - 1; i >= 0; i-- {
+		var err error
+		if data, err = ApplyDelta(data, deltas[i]); err != nil {
+			return 0, nil, err
+		}
+	}
+	return typ, data, nil
+}
+
+// readRef reads the base of a ref delta, from this pack or elsewhere
+func (p *Pack) readRef(r io.ReaderAt, sha []byte) (ObjectType, []byte, error) {
+	if i, ok := p.Index.Find(sha); ok {
+		offset, err := p.Index.Offset(i)
+		if err != nil {
+			return 0, nil, err
+		}
+		return p.ReadAt(r, offset)
+	}
+	if p.Base == nil {
+		return 0, nil, fmt.Errorf("delta base %s is missing", hex.EncodeToString(sha))
+	}
+	return p.Base(sha)
+}
+
+// entry is the header of an object in a pack, and what follows it
+type entry struct {
+	typ ObjectType
+	// size is the size of the inflated data, which is the delta itself
+	// for deltas
+	size int64
+	// baseOffset is where the base of an offset delta starts, and baseName
+	// the name of the base of a ref delta
+	baseOffset int64
+	baseName   []byte
+	// data reads the compressed data
+	data flate.Reader
+}
+
+func (e *entry) isDelta() bool {
+	return e.typ == TypeOfsDelta || e.typ == TypeRefDelta
+}
+
+// readEntry reads the header of the entry at an offset of the pack
+func readEntry(r io.ReaderAt, offset int64) (*entry, error) {
+	return parseEntry(bufio.NewReader(io.NewSectionReader(r, offset, 1<<62)), offset)
+}
+
+// parseEntry reads the header of an entry that starts at offset from br,
+// which is left at the start of the compressed data
+func parseEntry(br flate.Reader, offset int64) (*entry, error) {
+	c, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("reading pack entry at %d: %w", offset, err)
+	}
+	e := &entry{typ: ObjectType((c >> 4) & 7), size: int64(c & 0x0f), data: br}
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if shift > 60 {
+			return nil, fmt.Errorf("pack entry at %d has a bad size", offset)
+		}
+		if c, err = br.ReadByte(); err != nil {
+			return nil, fmt.Errorf("reading pack entry at %d: %w", offset, err)
+		}
+		e.size |= int64(c&0x7f) << shift
+	}
+
+	switch e.typ {
+	case TypeCommit, TypeTree, TypeBlob, TypeTag:
+	case TypeOfsDelta:
+		// The distance back to the base, in a variable length encoding
+		// where every continuation also adds one
+		if c, err = br.ReadByte(); err != nil {
+			return nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(c&0x7f)
+		}
+		if distance <= 0 || distance > offset {
+			return nil, fmt.Errorf("pack entry at %d has a bad delta base offset", offset)
+		}
+		e.baseOffset = offset - distance
+	case TypeRefDelta:
+		e.baseName = make([]byte, 20)
+		if _, err := io.ReadFull(br, e.baseName); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("pack entry at %d has %s", offset, e.typ)
+	}
+	return e, nil
+}
+
+// inflate decompresses the data of the entry
+func (e *entry) inflate() ([]byte, error) {
+	zr, err := zlib.NewReader(e.data)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data := make([]byte, e.size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, fmt.Errorf("inflating pack entry: %w", err)
+	}
+	// Reading to the end checks the checksum of the compressed data, and
+	// leaves a stream right after the entry
+	if n, err := zr.Read(make([]byte, 1)); n != 0 {
+		return nil, errors.New("pack entry is longer than its size")
+	} else if err != io.EOF {
+		return nil, fmt.Errorf("inflating pack entry: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,165 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testObject is an object to put in a test pack. Deltas have the index of
+// their base in the list, which comes earlier.
+type testObject struct {
+	typ   ObjectType
+	data  []byte
+	base  int
+	delta []byte
+	// name is the name of the object, which for deltas is the name of
+	// what they rebuild
+	name []byte
+}
+
+func compress(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// buildPack writes a pack and its version 2 index, and returns the pack
+func buildPack(t *testing.T, objects []testObject) *Pack {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("PACK")
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+	binary.Write(&buf, binary.BigEndian, uint32(len(objects)))
+
+	offsets := make([]int64, len(objects))
+	for i, obj := range objects {
+		offsets[i] = int64(buf.Len())
+		switch obj.typ {
+		case TypeOfsDelta:
+			buf.Write(appendEntryHeader(nil, obj.typ, len(obj.delta)))
+			buf.Write(appendOfsDistance(nil, offsets[i]-offsets[obj.base]))
+			buf.Write(compress(t, obj.delta))
+		case TypeRefDelta:
+			buf.Write(appendEntryHeader(nil, obj.typ, len(obj.delta)))
+			buf.Write(objects[obj.base].name)
+			buf.Write(compress(t, obj.delta))
+		default:
+			buf.Write(appendEntryHeader(nil, obj.typ, len(obj.data)))
+			buf.Write(compress(t, obj.data))
+		}
+	}
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pack-test.pack")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := ParseIndex(buildIndex(objects, offsets))
+	if err != nil {
+		t.Fatalf("ParseIndex() error = %v", err)
+	}
+	return &Pack{Path: path, Index: idx}
+}
+
+func buildIndex(objects []testObject, offsets []int64) []byte {
+	entries := []IndexEntry{}
+	for i, obj := range objects {
+		entries = append(entries, IndexEntry{Name: obj.name, Offset: offsets[i]})
+	}
+	var buf bytes.Buffer
+	WriteIndex(&buf, entries, make([]byte, sha1.Size))
+	return buf.Bytes()
+}
+
+// copyAndInsert makes a delta that copies base and appends extra
+func copyAndInsert(base, extra []byte) []byte {
+	delta := appendDeltaSize(nil, len(base))
+	delta = append(delta, appendDeltaSize(nil, len(base)+len(extra))...)
+	delta = append(delta, 0x80|0x10, byte(len(base)))
+	delta = append(delta, byte(len(extra)))
+	return append(delta, extra...)
+}
+
+func TestRead(t *testing.T) {
+	base := []byte("hello world\n")
+	second := append(append([]byte{}, base...), "second line\n"...)
+	third := append(append([]byte{}, second...), "third line\n"...)
+	large := bytes.Repeat([]byte("large object "), 1000)
+	objects := []testObject{
+		{typ: TypeBlob, data: base},
+		{typ: TypeOfsDelta, base: 0, delta: copyAndInsert(base, []byte("second line\n"))},
+		{typ: TypeRefDelta, base: 1, delta: copyAndInsert(second, []byte("third line\n"))},
+		{typ: TypeCommit, data: large},
+	}
+	objects[0].name = objectName(TypeBlob, base)
+	objects[1].name = objectName(TypeBlob, second)
+	objects[2].name = objectName(TypeBlob, third)
+	objects[3].name = objectName(TypeCommit, large)
+	p := buildPack(t, objects)
+
+	tests := []struct {
+		name     string
+		sha      []byte
+		wantType ObjectType
+		want     []byte
+	}{
+		{"full object", objects[0].name, TypeBlob, base},
+		{"offset delta", objects[1].name, TypeBlob, second},
+		{"ref delta on a delta", objects[2].name, TypeBlob, third},
+		{"large object", objects[3].name, TypeCommit, large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, data, err := p.Read(tt.sha)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if typ != tt.wantType || !bytes.Equal(data, tt.want) {
+				t.Errorf("Read() = %s %q, want %s %q", typ, data, tt.wantType, tt.want)
+			}
+			typ, size, err := p.Header(tt.sha)
+			if err != nil {
+				t.Fatalf("Header() error = %v", err)
+			}
+			if typ != tt.wantType || size != int64(len(tt.want)) {
+				t.Errorf("Header() = %s %d, want %s %d", typ, size, tt.wantType, len(tt.want))
+			}
+		})
+	}
+
+	if _, _, err := p.Read(objectName(TypeBlob, []byte("missing"))); err != ErrNotFound {
+		t.Errorf("Read() of a missing object error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestApplyDeltaCorrupt(t *testing.T) {
+	base := []byte("base")
+	tests := []struct {
+		name  string
+		delta []byte
+	}{
+		{"wrong base size", append(appendDeltaSize(nil, 5), appendDeltaSize(nil, 4)...)},
+		{"copy out of range", append(append(appendDeltaSize(nil, 4), appendDeltaSize(nil, 8)...), 0x90, 8)},
+		{"short insert", append(append(appendDeltaSize(nil, 4), appendDeltaSize(nil, 3)...), 3, 'a')},
+		{"wrong result size", append(append(appendDeltaSize(nil, 4), appendDeltaSize(nil, 9)...), 1, 'a')},
+		{"reserved opcode", append(append(appendDeltaSize(nil, 4), appendDeltaSize(nil, 0)...), 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyDelta(base, tt.delta); err == nil {
+				t.Error("ApplyDelta() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package pack
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errUnknownBase is returned for ref deltas whose base hasn't been named
+// yet
+var errUnknownBase = errors.New("delta base is unknown")
+
+// resolved is an entry of a pack with its deltas applied
+type resolved struct {
+	typ  ObjectType
+	data []byte
+	name []byte
+	// depth is how many deltas were applied
+	depth int
+}
+
+// resolver applies the deltas of the entries of a pack, to find the
+// objects and their names without an index
+type resolver struct {
+	entries  []*Entry
+	byOffset map[int64]int
+	byName   map[string]int
+	objects  []*resolved
+	// base reads the bases of ref deltas that aren't in the pack, which
+	// are kept in external
+	base     func(sha []byte) (ObjectType, []byte, error)
+	external map[string]*resolved
+}
+
+// scanPack reads all the entries of a pack stream. It returns them with
+// the checksum at the end of the pack, and where that checksum starts.
+func scanPack(r io.Reader) ([]*Entry, []byte, int64, error) {
+	s, err := NewScanner(r)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	entries := []*Entry{}
+	for {
+		e, err := s.Next()
+		if err == io.EOF {
+			return entries, s.Checksum(), s.Offset() - int64(len(s.Checksum())), nil
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+func newResolver(entries []*Entry, base func(sha []byte) (ObjectType, []byte, error)) *resolver {
+	r := &resolver{
+		entries:  entries,
+		byOffset: map[int64]int{},
+		byName:   map[string]int{},
+		objects:  make([]*resolved, len(entries)),
+		base:     base,
+		external: map[string]*resolved{},
+	}
+	for i, e := range entries {
+		r.byOffset[e.Offset] = i
+	}
+	return r
+}
+
+// resolveAll resolves every entry. Ref deltas can have their base
+// anywhere in the pack, so the ones whose base isn't named yet are tried
+// again once more objects are, and at last with bases from outside the
+// pack.
+func (r *resolver) resolveAll() error {
+	pending := []int{}
+	for i := range r.entries {
+		if _, err := r.resolve(i, 0); errors.Is(err, errUnknownBase) {
+			pending = append(pending, i)
+		} else if err != nil {
+			return err
+		}
+	}
+	for len(pending) > 0 {
+		left := []int{}
+		for _, i := range pending {
+			if _, err := r.resolve(i, 0); errors.Is(err, errUnknownBase) {
+				left = append(left, i)
+			} else if err != nil {
+				return err
+			}
+		}
+		if len(left) == len(pending) {
+			if err := r.readExternal(left); err != nil {
+				return err
+			}
+		}
+		pending = left
+	}
+	return nil
+}
+
+// readExternal reads the bases that the ref deltas among the entries
+// miss from outside the pack. Bases that aren't found there may still be
+// in the pack, as deltas on the ones that are.
+func (r *resolver) readExternal(entries []int) error {
+	var missing error
+	for _, i := range entries {
+		e := r.entries[i]
+		if e.Type != TypeRefDelta || r.known(e.BaseName) {
+			continue
+		}
+		if r.base == nil {
+			return fmt.Errorf("delta base %s is missing", hex.EncodeToString(e.BaseName))
+		}
+		typ, data, err := r.base(e.BaseName)
+		if err != nil {
+			if missing == nil {
+				missing = fmt.Errorf("delta base %s: %w", hex.EncodeToString(e.BaseName), err)
+			}
+			continue
+		}
+		r.external[string(e.BaseName)] = &resolved{typ: typ, data: data, name: e.BaseName}
+		return nil
+	}
+	if missing == nil {
+		missing = errors.New("delta bases refer to each other in a loop")
+	}
+	return missing
+}
+
+// known reports whether an object of that name was resolved or read
+func (r *resolver) known(name []byte) bool {
+	_, inPack := r.byName[string(name)]
+	_, outside := r.external[string(name)]
+	return inPack || outside
+}
+
+// resolve applies the deltas of the i-th entry. depth guards against
+// chains that are too long.
+func (r *resolver) resolve(i, depth int) (*resolved, error) {
+	if r.objects[i] != nil {
+		return r.objects[i], nil
+	}
+	if depth > maxDeltaDepth {
+		return nil, errors.New("delta chain is too long")
+	}
+	e := r.entries[i]
+	obj := &resolved{typ: e.Type, data: e.Data}
+	if e.Type == TypeOfsDelta || e.Type == TypeRefDelta {
+		base, err := r.deltaBase(e, depth)
+		if err != nil {
+			return nil, err
+		}
+		if obj.data, err = ApplyDelta(base.data, e.Data); err != nil {
+			return nil, fmt.Errorf("pack entry at %d: %w", e.Offset, err)
+		}
+		obj.typ, obj.depth = base.typ, base.depth+1
+	}
+	obj.name = objectName(obj.typ, obj.data)
+	r.objects[i] = obj
+	r.byName[string(obj.name)] = i
+	return obj, nil
+}
+
+// deltaBase returns the resolved base of a delta
+func (r *resolver) deltaBase(e *Entry, depth int) (*resolved, error) {
+	if e.Type == TypeOfsDelta {
+		i, ok := r.byOffset[e.BaseOffset]
+		if !ok {
+			return nil, fmt.Errorf("pack entry at %d has no delta base", e.Offset)
+		}
+		return r.resolve(i, depth+1)
+	}
+	if i, ok := r.byName[string(e.BaseName)]; ok {
+		return r.resolve(i, depth+1)
+	}
+	if base, ok := r.external[string(e.BaseName)]; ok {
+		return base, nil
+	}
+	return nil, errUnknownBase
+}
+
+// IndexStream reads a pack stream, like one that came in over the wire,
+// and returns the entries of its index and its checksum, to pass to
+// WriteIndex. Every delta is applied to name its object. base reads the
+// bases of ref deltas that aren't in the pack, which only thin packs
+// have; without it, such deltas are an error.
+func IndexStream(r io.Reader, base func(sha []byte) (ObjectType, []byte, error)) ([]IndexEntry, []byte, error) {
+	entries, checksum, _, err := scanPack(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := newResolver(entries, base)
+	if err := res.resolveAll(); err != nil {
+		return nil, nil, err
+	}
+	indexed := make([]IndexEntry, len(entries))
+	for i, e := range entries {
+		indexed[i] = IndexEntry{Name: res.objects[i].name, Offset: e.Offset, CRC: e.CRC}
+	}
+	return indexed, checksum, nil
+}
+
+// Unpack reads a whole pack stream and passes every object to store, with
+// its deltas applied, in the order of the pack. base reads the bases of
+// ref deltas that aren't in the pack, like the objects a repository
+// already has. Nothing is stored unless the whole pack is good.
+func Unpack(r io.Reader, base func(sha []byte) (ObjectType, []byte, error), store func(sha []byte, typ ObjectType, data []byte) error) error {
+	entries, _, _, err := scanPack(r)
+	if err != nil {
+		return err
+	}
+	res := newResolver(entries, base)
+	if err := res.resolveAll(); err != nil {
+		return err
+	}
+	for _, obj := range res.objects {
+		if err := store(obj.name, obj.typ, obj.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
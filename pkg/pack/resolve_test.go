@@ -0,0 +1,177 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestIndexStream(t *testing.T) {
+	data, objects, _ := testStream(t)
+	entries, checksum, err := IndexStream(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("IndexStream() error = %v", err)
+	}
+	if !bytes.Equal(checksum, data[len(data)-20:]) {
+		t.Errorf("IndexStream() checksum = %x, want %x", checksum, data[len(data)-20:])
+	}
+	if len(entries) != len(objects) {
+		t.Fatalf("IndexStream() = %d entries, want %d", len(entries), len(objects))
+	}
+	s, err := NewScanner(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, obj := range objects {
+		e, err := s.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(entries[i].Name, obj.name) || entries[i].Offset != e.Offset || entries[i].CRC != e.CRC {
+			t.Errorf("entry %d = %x at %d, want %x at %d", i, entries[i].Name, entries[i].Offset, obj.name, e.Offset)
+		}
+	}
+
+	// The index it makes is one Verify accepts
+	var idx bytes.Buffer
+	if err := WriteIndex(&idx, entries, checksum); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ParseIndex(idx.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/pack-test.pack"
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(&Pack{Path: path, Index: index}); err != nil {
+		t.Errorf("Verify() of the indexed pack error = %v", err)
+	}
+}
+
+func TestIndexStreamRefDeltas(t *testing.T) {
+	base := []byte("hello world\n")
+	extended := append(append([]byte{}, base...), "more\n"...)
+	baseName, extendedName := objectName(TypeBlob, base), objectName(TypeBlob, extended)
+	outside := func(sha []byte) (ObjectType, []byte, error) {
+		if bytes.Equal(sha, baseName) {
+			return TypeBlob, base, nil
+		}
+		return 0, nil, ErrNotFound
+	}
+
+	tests := []struct {
+		name    string
+		objects []testObject
+		base    func(sha []byte) (ObjectType, []byte, error)
+		want    [][]byte
+		wantErr bool
+	}{
+		{
+			name: "base later in the pack",
+			objects: []testObject{
+				{typ: TypeRefDelta, base: 1, delta: copyAndInsert(base, []byte("more\n")), name: extendedName},
+				{typ: TypeBlob, data: base, name: baseName},
+			},
+			want: [][]byte{extendedName, baseName},
+		},
+		{
+			name: "chain of deltas on later bases",
+			objects: []testObject{
+				{typ: TypeRefDelta, base: 1, delta: copyAndInsert(extended, []byte("more\n")), name: objectName(TypeBlob, append(append([]byte{}, extended...), "more\n"...))},
+				{typ: TypeRefDelta, base: 2, delta: copyAndInsert(base, []byte("more\n")), name: extendedName},
+				{typ: TypeBlob, data: base, name: baseName},
+			},
+			base: outside,
+			want: [][]byte{objectName(TypeBlob, append(append([]byte{}, extended...), "more\n"...)), extendedName},
+		},
+		{
+			name: "bases that refer to each other",
+			objects: []testObject{
+				{typ: TypeRefDelta, base: 1, delta: copyAndInsert(base, []byte("more\n")), name: extendedName},
+				{typ: TypeRefDelta, base: 0, delta: copyAndInsert(extended, []byte("more\n")), name: objectName(TypeBlob, []byte("other"))},
+			},
+			base:    outside,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(buildPack(t, tt.objects).Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, _, err := IndexStream(bytes.NewReader(data), tt.base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IndexStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for i, name := range tt.want {
+				if !bytes.Equal(entries[i].Name, name) {
+					t.Errorf("entry %d = %x, want %x", i, entries[i].Name, name)
+				}
+			}
+		})
+	}
+
+	// A thin pack only has the delta, its base is elsewhere
+	var thin bytes.Buffer
+	thin.WriteString("PACK")
+	binary.Write(&thin, binary.BigEndian, uint32(2))
+	binary.Write(&thin, binary.BigEndian, uint32(1))
+	delta := copyAndInsert(base, []byte("more\n"))
+	thin.Write(appendEntryHeader(nil, TypeRefDelta, len(delta)))
+	thin.Write(baseName)
+	thin.Write(compress(t, delta))
+	sum := sha1.Sum(thin.Bytes())
+	thin.Write(sum[:])
+
+	if _, _, err := IndexStream(bytes.NewReader(thin.Bytes()), nil); err == nil {
+		t.Errorf("IndexStream() of a thin pack without its bases succeeded")
+	}
+	entries, _, err := IndexStream(bytes.NewReader(thin.Bytes()), outside)
+	if err != nil {
+		t.Fatalf("IndexStream() of a thin pack error = %v", err)
+	}
+	if len(entries) != 1 || !bytes.Equal(entries[0].Name, extendedName) {
+		t.Errorf("IndexStream() of a thin pack = %v, want only %x", entries, extendedName)
+	}
+	if _, _, err := IndexStream(bytes.NewReader(thin.Bytes()[:thin.Len()-10]), outside); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("IndexStream() of a truncated pack error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	data, objects, contents := testStream(t)
+	stored := [][]byte{}
+	store := func(sha []byte, typ ObjectType, data []byte) error {
+		if !bytes.Equal(sha, objectName(typ, data)) {
+			t.Errorf("Unpack() stored %x with contents named %x", sha, objectName(typ, data))
+		}
+		stored = append(stored, data)
+		return nil
+	}
+	if err := Unpack(bytes.NewReader(data), nil, store); err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	if len(stored) != len(objects) {
+		t.Fatalf("Unpack() stored %d objects, want %d", len(stored), len(objects))
+	}
+	for i := range stored {
+		if !bytes.Equal(stored[i], contents[i]) {
+			t.Errorf("Unpack() stored %q, want %q", stored[i], contents[i])
+		}
+	}
+
+	stored = nil
+	if err := Unpack(bytes.NewReader(data[:len(data)-1]), nil, store); err == nil {
+		t.Errorf("Unpack() of a truncated pack succeeded")
+	}
+	if len(stored) != 0 {
+		t.Errorf("Unpack() of a truncated pack stored %d objects", len(stored))
+	}
+}
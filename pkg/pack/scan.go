@@ -0,0 +1,205 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Entry is an entry of a pack stream, as it is stored. Deltas aren't
+// applied.
+type Entry struct {
+	Offset int64
+	Type   ObjectType
+	// Data is the inflated data, which is the delta itself for deltas
+	Data []byte
+	// BaseOffset is where the base of an offset delta starts, and BaseName
+	// the name of the base of a ref delta
+	BaseOffset int64
+	BaseName   []byte
+	// CRC is the CRC-32 of the entry as it is stored, for the index
+	CRC uint32
+}
+
+// Scanner reads a pack stream from the start, one entry after the other,
+// like it comes in from the other side of a fetch. Unlike Pack, it
+// doesn't need an index.
+type Scanner struct {
+	r        *streamReader
+	count    uint32
+	read     uint32
+	checksum []byte
+}
+
+// streamReader counts and hashes what is read, so the scanner knows where
+// every entry starts and can check the checksum at the end of the pack.
+// crc is reset for every entry.
+type streamReader struct {
+	br     *bufio.Reader
+	offset int64
+	hash   hash.Hash
+	crc    hash.Hash32
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.br.Read(p)
+	r.hash.Write(p[:n])
+	r.crc.Write(p[:n])
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *streamReader) ReadByte() (byte, error) {
+	c, err := r.br.ReadByte()
+	if err == nil {
+		r.hash.Write([]byte{c})
+		r.crc.Write([]byte{c})
+		r.offset++
+	}
+	return c, err
+}
+
+// NewScanner reads the header of a pack stream
+func NewScanner(r io.Reader) (*Scanner, error) {
+	sr := &streamReader{br: bufio.NewReader(r), hash: sha1.New(), crc: crc32.NewIEEE()}
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(sr, header); err != nil {
+		return nil, fmt.Errorf("reading pack header: %w", truncated(err))
+	}
+	if string(header[:4]) != "PACK" {
+		return nil, errors.New("not a pack")
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	return &Scanner{r: sr, count: binary.BigEndian.Uint32(header[8:])}, nil
+}
+
+// Count returns the number of entries the pack says it has
+func (s *Scanner) Count() uint32 {
+	return s.count
+}
+
+// Offset returns how far the stream has been read, which is where the
+// next entry starts
+func (s *Scanner) Offset() int64 {
+	return s.r.offset
+}
+
+// Checksum returns the checksum at the end of the pack, which names it,
+// once Next has returned io.EOF
+func (s *Scanner) Checksum() []byte {
+	return s.checksum
+}
+
+// Next returns the next entry. After the last one, the checksum at the
+// end of the pack is checked and io.EOF returned. A stream that is cut
+// off gives an error that wraps io.ErrUnexpectedEOF.
+func (s *Scanner) Next() (*Entry, error) {
+	if s.read == s.count {
+		sum := s.r.hash.Sum(nil)
+		trailer := make([]byte, len(sum))
+		if _, err := io.ReadFull(s.r, trailer); err != nil {
+			return nil, fmt.Errorf("reading pack checksum: %w", truncated(err))
+		}
+		if !bytes.Equal(trailer, sum) {
+			return nil, errors.New("pack checksum mismatch")
+		}
+		s.checksum = trailer
+		return nil, io.EOF
+	}
+
+	offset := s.r.offset
+	s.r.crc.Reset()
+	e, err := parseEntry(s.r, offset)
+	if err != nil {
+		return nil, truncated(err)
+	}
+	data, err := e.inflate()
+	if err != nil {
+		return nil, fmt.Errorf("pack entry at %d: %w", offset, truncated(err))
+	}
+	s.read++
+	return &Entry{Offset: offset, Type: e.typ, Data: data, BaseOffset: e.baseOffset, BaseName: e.baseName, CRC: s.r.crc.Sum32()}, nil
+}
+
+// truncated turns the io.EOF of a stream that ends too early into
+// io.ErrUnexpectedEOF
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Recover reads what arrived of a pack stream that may have been cut off,
+// like the part of a pack an interrupted clone or fetch kept, so only the
+// rest has to be fetched again. Every object that arrived whole is passed
+// to store with its deltas applied. base reads the bases of deltas, which
+// are objects that were stored before or that the repository already had.
+//
+// It returns the number of bytes of the stream that held whole entries,
+// which is all of it when the pack is complete.
+func Recover(r io.Reader, base func(sha []byte) (ObjectType, []byte, error), store func(sha []byte, typ ObjectType, data []byte) error) (int64, error) {
+	s, err := NewScanner(r)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Only names are kept, objects are read back through base
+	names := map[int64][]byte{}
+	for {
+		complete := s.Offset()
+		e, err := s.Next()
+		if err == io.EOF {
+			return s.Offset(), nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return complete, nil
+		}
+		if err != nil {
+			return complete, err
+		}
+
+		typ, data := e.Type, e.Data
+		if e.Type == TypeOfsDelta || e.Type == TypeRefDelta {
+			baseName := e.BaseName
+			if e.Type == TypeOfsDelta {
+				var ok bool
+				if baseName, ok = names[e.BaseOffset]; !ok {
+					return complete, fmt.Errorf("pack entry at %d has no delta base", e.Offset)
+				}
+			}
+			var baseData []byte
+			if typ, baseData, err = base(baseName); err != nil {
+				return complete, err
+			}
+			if data, err = ApplyDelta(baseData, e.Data); err != nil {
+				return complete, fmt.Errorf("pack entry at %d: %w", e.Offset, err)
+			}
+		}
+		name := objectName(typ, data)
+		if err := store(name, typ, data); err != nil {
+			return complete, err
+		}
+		names[e.Offset] = name
+	}
+}
+
+// objectName returns the name of an object, which is the hash of its
+// loose form
+func objectName(typ ObjectType, data []byte) []byte {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", typ, len(data))
+	h.Write(data)
+	return h.Sum(nil)
+}
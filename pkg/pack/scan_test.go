@@ -0,0 +1,133 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func testStream(t *testing.T) ([]byte, []testObject, [][]byte) {
+	base := []byte("hello world\n")
+	second := append(append([]byte{}, base...), "second line\n"...)
+	third := append(append([]byte{}, second...), "third line\n"...)
+	objects := []testObject{
+		{typ: TypeBlob, data: base},
+		{typ: TypeOfsDelta, base: 0, delta: copyAndInsert(base, []byte("second line\n"))},
+		{typ: TypeRefDelta, base: 1, delta: copyAndInsert(second, []byte("third line\n"))},
+		{typ: TypeCommit, data: bytes.Repeat([]byte("large object "), 1000)},
+	}
+	contents := [][]byte{base, second, third, objects[3].data}
+	for i, typ := range []ObjectType{TypeBlob, TypeBlob, TypeBlob, TypeCommit} {
+		objects[i].name = objectName(typ, contents[i])
+	}
+	data, err := os.ReadFile(buildPack(t, objects).Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, objects, contents
+}
+
+func TestScanner(t *testing.T) {
+	data, objects, _ := testStream(t)
+	s, err := NewScanner(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewScanner() error = %v", err)
+	}
+	if s.Count() != uint32(len(objects)) {
+		t.Errorf("Count() = %d, want %d", s.Count(), len(objects))
+	}
+	for i, obj := range objects {
+		e, err := s.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if e.Type != obj.typ {
+			t.Errorf("entry %d has type %s, want %s", i, e.Type, obj.typ)
+		}
+		want := obj.data
+		if obj.delta != nil {
+			want = obj.delta
+		}
+		if !bytes.Equal(e.Data, want) {
+			t.Errorf("entry %d has data %q, want %q", i, e.Data, want)
+		}
+	}
+	if _, err := s.Next(); err != io.EOF {
+		t.Errorf("Next() at the end error = %v, want io.EOF", err)
+	}
+
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	s, _ = NewScanner(bytes.NewReader(corrupt))
+	for range objects {
+		s.Next()
+	}
+	if _, err := s.Next(); err == nil || err == io.EOF {
+		t.Errorf("Next() with a bad checksum error = %v, want an error", err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	data, _, contents := testStream(t)
+
+	// Find where the entries end to cut the stream at them
+	ends := []int64{}
+	s, _ := NewScanner(bytes.NewReader(data))
+	for {
+		if _, err := s.Next(); err != nil {
+			break
+		}
+		ends = append(ends, s.Offset())
+	}
+
+	tests := []struct {
+		name         string
+		length       int64
+		wantComplete int64
+		wantObjects  int
+	}{
+		{"cut in the header", 6, 0, 0},
+		{"only the header", 12, 12, 0},
+		{"cut in the first entry", 14, 12, 0},
+		{"first entry", ends[0], ends[0], 1},
+		{"cut in a delta", ends[1] - 1, ends[0], 1},
+		{"cut in the last entry", ends[3] - 10, ends[2], 3},
+		{"cut in the checksum", ends[3] + 5, ends[3], 4},
+		{"whole pack", int64(len(data)), int64(len(data)), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := map[string][]byte{}
+			base := func(sha []byte) (ObjectType, []byte, error) {
+				data, ok := stored[hex.EncodeToString(sha)]
+				if !ok {
+					return 0, nil, errors.New("missing base")
+				}
+				return TypeBlob, data, nil
+			}
+			store := func(sha []byte, typ ObjectType, data []byte) error {
+				stored[hex.EncodeToString(sha)] = data
+				return nil
+			}
+			complete, err := Recover(bytes.NewReader(data[:tt.length]), base, store)
+			if err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("Recover() = %d, want %d", complete, tt.wantComplete)
+			}
+			if len(stored) != tt.wantObjects {
+				t.Errorf("Recover() stored %d objects, want %d", len(stored), tt.wantObjects)
+			}
+			for i, want := range contents[:tt.wantObjects] {
+				name := hex.EncodeToString(objectName([]ObjectType{TypeBlob, TypeBlob, TypeBlob, TypeCommit}[i], want))
+				if !bytes.Equal(stored[name], want) {
+					t.Errorf("object %d = %q, want %q", i, stored[name], want)
+				}
+			}
+		})
+	}
+}
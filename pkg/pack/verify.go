@@ -0,0 +1,93 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// VerifiedObject is an object of a pack that Verify checked
+type VerifiedObject struct {
+	Name []byte
+	// Type is the type of the object, with its deltas applied
+	Type ObjectType
+	// Size is the inflated size of the entry, which is the size of the
+	// delta itself for deltas
+	Size int64
+	// PackedSize is how much of the pack the entry takes, with its header
+	PackedSize int64
+	Offset     int64
+	// Depth is how many deltas have to be applied to get the object, and
+	// Base the name of the base of its delta
+	Depth int
+	Base  []byte
+}
+
+// Verify reads the whole pack and checks it against its index: the
+// checksum at the end of the pack, that every object inflates and its
+// deltas apply, and that the index has the same objects at the same
+// offsets with the same CRCs. It returns the objects in the order of the
+// pack.
+func Verify(p *Pack) ([]VerifiedObject, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, checksum, end, err := scanPack(f)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(checksum, p.Index.PackChecksum()) {
+		return nil, errors.New("pack checksum doesn't match the index")
+	}
+	if len(entries) != p.Index.Count() {
+		return nil, fmt.Errorf("pack has %d objects but the index has %d", len(entries), p.Index.Count())
+	}
+	r := newResolver(entries, p.Base)
+	if err := r.resolveAll(); err != nil {
+		return nil, err
+	}
+
+	verified := make([]VerifiedObject, len(entries))
+	for i, e := range entries {
+		obj := r.objects[i]
+		next := end
+		if i+1 < len(entries) {
+			next = entries[i+1].Offset
+		}
+		verified[i] = VerifiedObject{Name: obj.name, Type: obj.typ, Size: int64(len(e.Data)), PackedSize: next - e.Offset, Offset: e.Offset, Depth: obj.depth}
+		if e.Type == TypeOfsDelta {
+			verified[i].Base = r.objects[r.byOffset[e.BaseOffset]].name
+		} else if e.Type == TypeRefDelta {
+			verified[i].Base = e.BaseName
+		}
+		if err := checkIndexed(p.Index, obj.name, e); err != nil {
+			return nil, err
+		}
+	}
+	return verified, nil
+}
+
+// checkIndexed makes sure the index has the object of an entry, at its
+// offset and with its CRC
+func checkIndexed(idx *Index, name []byte, e *Entry) error {
+	i, ok := idx.Find(name)
+	if !ok {
+		return fmt.Errorf("object %s at %d isn't in the index", hex.EncodeToString(name), e.Offset)
+	}
+	offset, err := idx.Offset(i)
+	if err != nil {
+		return err
+	}
+	if offset != e.Offset {
+		return fmt.Errorf("index has object %s at %d, but it is at %d", hex.EncodeToString(name), offset, e.Offset)
+	}
+	if crc, ok := idx.CRC(i); ok && crc != e.CRC {
+		return fmt.Errorf("CRC of object %s doesn't match the index", hex.EncodeToString(name))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package pack
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestPack writes a pack with deltas and its index, and returns the
+// pack and its raw data
+func writeTestPack(t *testing.T) (*Pack, []byte, []byte) {
+	t.Helper()
+	content := bytes.Repeat([]byte("a line of a file that changes a little\n"), 100)
+	objects := []Object{}
+	for i := range 4 {
+		data := append(append([]byte{}, content...), bytes.Repeat([]byte{byte('0' + i)}, i*10)...)
+		objects = append(objects, Object{Name: objectName(TypeBlob, data), Type: TypeBlob, Data: data})
+	}
+	var packData, idxData bytes.Buffer
+	entries, checksum, err := Write(&packData, objects, DefaultWriteOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteIndex(&idxData, entries, checksum); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ParseIndex(idxData.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pack-test.pack")
+	if err := os.WriteFile(path, packData.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &Pack{Path: path, Index: index}, packData.Bytes(), idxData.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	p, data, _ := writeTestPack(t)
+	verified, err := Verify(p)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(verified) != 4 {
+		t.Fatalf("Verify() = %d objects, want 4", len(verified))
+	}
+	total := int64(12 + 20)
+	deltas := 0
+	for i, obj := range verified {
+		total += obj.PackedSize
+		if i > 0 && obj.Offset != verified[i-1].Offset+verified[i-1].PackedSize {
+			t.Errorf("object %d is at %d, want it right after the one before", i, obj.Offset)
+		}
+		if obj.Type != TypeBlob {
+			t.Errorf("object %d is a %s, want a blob", i, obj.Type)
+		}
+		if !p.Index.Contains(obj.Name) {
+			t.Errorf("object %d is called %x, which isn't in the index", i, obj.Name)
+		}
+		if obj.Depth == 0 {
+			if obj.Base != nil {
+				t.Errorf("object %d has base %x but no depth", i, obj.Base)
+			}
+			continue
+		}
+		deltas++
+		if !p.Index.Contains(obj.Base) {
+			t.Errorf("object %d has base %x, which isn't in the pack", i, obj.Base)
+		}
+	}
+	if total != int64(len(data)) {
+		t.Errorf("Verify() objects take %d bytes, want %d", total, len(data))
+	}
+	if deltas != 3 {
+		t.Errorf("Verify() found %d deltas, want 3", deltas)
+	}
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(pack, idx []byte) ([]byte, []byte)
+	}{
+		{"flipped byte", func(pack, idx []byte) ([]byte, []byte) {
+			pack[len(pack)/2] ^= 0xff
+			return pack, idx
+		}},
+		{"bad checksum", func(pack, idx []byte) ([]byte, []byte) {
+			pack[len(pack)-1] ^= 0xff
+			return pack, idx
+		}},
+		{"truncated", func(pack, idx []byte) ([]byte, []byte) {
+			return pack[:len(pack)-30], idx
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, data, idx := writeTestPack(t)
+			data, idx = tt.corrupt(append([]byte{}, data...), append([]byte{}, idx...))
+			if err := os.WriteFile(p.Path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			index, err := ParseIndex(idx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Verify(&Pack{Path: p.Path, Index: index}); err == nil {
+				t.Errorf("Verify() of a corrupt pack succeeded")
+			}
+		})
+	}
+}
+
+func TestVerifyWrongIndex(t *testing.T) {
+	p, _, _ := writeTestPack(t)
+	entries := []IndexEntry{}
+	for i := range p.Index.Count() {
+		offset, _ := p.Index.Offset(i)
+		crc, _ := p.Index.CRC(i)
+		entries = append(entries, IndexEntry{Name: p.Index.Name(i), Offset: offset, CRC: crc ^ 1})
+	}
+	var idx bytes.Buffer
+	if err := WriteIndex(&idx, entries, p.Index.PackChecksum()); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ParseIndex(idx.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(&Pack{Path: p.Path, Index: index}); err == nil {
+		t.Errorf("Verify() with wrong CRCs in the index succeeded")
+	}
+}
@@ -0,0 +1,188 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// Object is an object to write to a pack
+type Object struct {
+	Name []byte
+	Type ObjectType
+	Data []byte
+}
+
+// WriteOptions change how objects are deltified
+type WriteOptions struct {
+	// Window is how many of the objects written before an object are tried
+	// as its delta base. Without a window, no deltas are made.
+	Window int
+	// Depth is how long delta chains can get
+	Depth int
+	// BigFileThreshold is the size above which objects are stored whole,
+	// and aren't tried as delta bases either. Zero means no limit.
+	BigFileThreshold int64
+}
+
+// DefaultBigFileThreshold is the default of core.bigFileThreshold
+const DefaultBigFileThreshold = 512 << 20
+
+// DefaultWriteOptions are the window, depth and big file threshold git
+// uses by default
+var DefaultWriteOptions = WriteOptions{Window: 10, Depth: 50, BigFileThreshold: DefaultBigFileThreshold}
+
+// Write writes a pack of the objects, and returns the entries for its
+// index and the checksum that names it. The objects are sorted by type
+// and size, largest first, so every object is tried as a delta against
+// objects like it that were written before it. An object is stored as an
+// offset delta when that is much smaller, like git does.
+func Write(w io.Writer, objects []Object, opts WriteOptions) ([]IndexEntry, []byte, error) {
+	sorted := deltaOrder(objects)
+	h := sha1.New()
+	out := io.MultiWriter(w, h)
+	header := []byte("PACK")
+	header = binary.BigEndian.AppendUint32(header, 2)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(sorted)))
+	if _, err := out.Write(header); err != nil {
+		return nil, nil, err
+	}
+	offset := int64(len(header))
+
+	entries := make([]IndexEntry, len(sorted))
+	depths := make([]int, len(sorted))
+	for i, obj := range sorted {
+		base, delta := findDeltaBase(sorted, depths, i, opts)
+
+		var entry bytes.Buffer
+		if delta != nil {
+			depths[i] = depths[base] + 1
+			entry.Write(appendEntryHeader(nil, TypeOfsDelta, len(delta)))
+			entry.Write(appendOfsDistance(nil, offset-entries[base].Offset))
+		} else {
+			entry.Write(appendEntryHeader(nil, obj.Type, len(obj.Data)))
+		}
+		zw := zlib.NewWriter(&entry)
+		data := obj.Data
+		if delta != nil {
+			data = delta
+		}
+		if _, err := zw.Write(data); err != nil {
+			return nil, nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, nil, err
+		}
+
+		entries[i] = IndexEntry{Name: obj.Name, Offset: offset, CRC: crc32.ChecksumIEEE(entry.Bytes())}
+		if _, err := out.Write(entry.Bytes()); err != nil {
+			return nil, nil, err
+		}
+		offset += int64(entry.Len())
+	}
+
+	checksum := h.Sum(nil)
+	if _, err := w.Write(checksum); err != nil {
+		return nil, nil, err
+	}
+	return entries, checksum, nil
+}
+
+// Delta is an object that Write stores as a delta
+type Delta struct {
+	Name []byte
+	// Base is the object the delta is against
+	Base []byte
+	// Size is the size of the delta, before it is compressed
+	Size int
+}
+
+// Deltas returns the objects that Write stores as deltas with the same
+// options, in the order it writes them, without writing a pack
+func Deltas(objects []Object, opts WriteOptions) []Delta {
+	sorted := deltaOrder(objects)
+	deltas := []Delta{}
+	depths := make([]int, len(sorted))
+	for i, obj := range sorted {
+		base, delta := findDeltaBase(sorted, depths, i, opts)
+		if delta != nil {
+			depths[i] = depths[base] + 1
+			deltas = append(deltas, Delta{Name: obj.Name, Base: sorted[base].Name, Size: len(delta)})
+		}
+	}
+	return deltas
+}
+
+// deltaOrder returns the objects sorted by type and size, largest first,
+// which is the order they are tried as deltas in
+func deltaOrder(objects []Object) []Object {
+	sorted := append([]Object{}, objects...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Type != sorted[j].Type {
+			return sorted[i].Type < sorted[j].Type
+		}
+		return len(sorted[i].Data) > len(sorted[j].Data)
+	})
+	return sorted
+}
+
+// findDeltaBase returns the smallest delta for the i-th object against
+// the objects in the window before it, and which one is the base. The
+// delta is nil when no delta is small enough to be worth it.
+func findDeltaBase(objects []Object, depths []int, i int, opts WriteOptions) (int, []byte) {
+	target := objects[i]
+	if opts.big(target) {
+		return -1, nil
+	}
+	// Like git, a delta has to save at least half of the object
+	maxSize := len(target.Data)/2 - 20
+	base := -1
+	var best []byte
+	for j := i - 1; j >= 0 && j >= i-opts.Window; j-- {
+		candidate := objects[j]
+		if candidate.Type != target.Type || depths[j] >= opts.Depth || opts.big(candidate) {
+			continue
+		}
+		// What the base doesn't have has to be inserted
+		if len(target.Data)-len(candidate.Data) >= maxSize {
+			continue
+		}
+		delta := CreateDelta(candidate.Data, target.Data)
+		if len(delta) < maxSize && (best == nil || len(delta) < len(best)) {
+			base, best = j, delta
+		}
+	}
+	return base, best
+}
+
+// big reports whether an object is too big for deltas
+func (opts WriteOptions) big(obj Object) bool {
+	return opts.BigFileThreshold > 0 && int64(len(obj.Data)) > opts.BigFileThreshold
+}
+
+// appendEntryHeader appends the type and size of a pack entry: the type
+// and the low 4 bits of the size in the first byte, then 7 bits of the
+// size per byte
+func appendEntryHeader(data []byte, typ ObjectType, size int) []byte {
+	c := byte(typ)<<4 | byte(size&0x0f)
+	for size >>= 4; size > 0; size >>= 7 {
+		data = append(data, c|0x80)
+		c = byte(size & 0x7f)
+	}
+	return append(data, c)
+}
+
+// appendOfsDistance appends how far back the base of an offset delta is,
+// most significant bits first, where every continuation also adds one
+func appendOfsDistance(data []byte, distance int64) []byte {
+	encoded := []byte{byte(distance & 0x7f)}
+	for distance >>= 7; distance > 0; distance >>= 7 {
+		distance--
+		encoded = append([]byte{byte(0x80 | distance&0x7f)}, encoded...)
+	}
+	return append(data, encoded...)
+}
@@ -0,0 +1,122 @@
+package pack
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateDelta(t *testing.T) {
+	random := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(random)
+	edited := append(append(append([]byte{}, random[:1000]...), "an edit"...), random[1500:]...)
+
+	tests := []struct {
+		name         string
+		base, target []byte
+		maxSize      int
+	}{
+		{"empty", nil, nil, 2},
+		{"empty base", nil, []byte("new content"), 20},
+		{"empty target", []byte("old content"), nil, 2},
+		{"same", random, random, 30},
+		{"edited", random, edited, 40},
+		{"repeated block", bytes.Repeat([]byte("0123456789abcdef"), 100), bytes.Repeat([]byte("0123456789abcdef"), 200), 20},
+		{"unrelated", []byte("nothing in common here at all"), []byte("something else entirely, really"), 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := CreateDelta(tt.base, tt.target)
+			got, err := ApplyDelta(tt.base, delta)
+			if err != nil {
+				t.Fatalf("ApplyDelta() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.target) {
+				t.Errorf("ApplyDelta() doesn't rebuild the target")
+			}
+			if len(delta) > tt.maxSize {
+				t.Errorf("CreateDelta() is %d bytes, want at most %d", len(delta), tt.maxSize)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	content := bytes.Repeat([]byte("a line of a file that changes a little\n"), 100)
+	objects := []Object{}
+	for i := range 5 {
+		data := append(append([]byte{}, content...), bytes.Repeat([]byte{byte('0' + i)}, i*10)...)
+		objects = append(objects, Object{Name: objectName(TypeBlob, data), Type: TypeBlob, Data: data})
+	}
+	commit := []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbc88904\n\nmessage\n")
+	objects = append(objects, Object{Name: objectName(TypeCommit, commit), Type: TypeCommit, Data: commit})
+
+	tests := []struct {
+		name       string
+		opts       WriteOptions
+		wantDeltas int
+	}{
+		{"no deltas", WriteOptions{}, 0},
+		{"deltas", DefaultWriteOptions, 4},
+		{"short chains", WriteOptions{Window: 1, Depth: 1}, 2},
+		{"big objects", WriteOptions{Window: 10, Depth: 50, BigFileThreshold: 1000}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			entries, checksum, err := Write(&buf, objects, tt.opts)
+			if err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			deltas := 0
+			s, err := NewScanner(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range entries {
+				e, err := s.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if e.Offset != entries[i].Offset || e.CRC != entries[i].CRC {
+					t.Errorf("entry %d is at %d with CRC %08x, want %d and %08x", i, e.Offset, e.CRC, entries[i].Offset, entries[i].CRC)
+				}
+				if e.Type == TypeOfsDelta {
+					deltas++
+				}
+			}
+			if deltas != tt.wantDeltas {
+				t.Errorf("Write() made %d deltas, want %d", deltas, tt.wantDeltas)
+			}
+			if planned := Deltas(objects, tt.opts); len(planned) != deltas {
+				t.Errorf("Deltas() = %d deltas, want the %d of Write()", len(planned), deltas)
+			}
+
+			var idx bytes.Buffer
+			if err := WriteIndex(&idx, entries, checksum); err != nil {
+				t.Fatal(err)
+			}
+			index, err := ParseIndex(idx.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "pack-test.pack")
+			if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			p := &Pack{Path: path, Index: index}
+			for _, obj := range objects {
+				typ, data, err := p.Read(obj.Name)
+				if err != nil {
+					t.Fatalf("Read() error = %v", err)
+				}
+				if typ != obj.Type || !bytes.Equal(data, obj.Data) {
+					t.Errorf("Read() = %s of %d bytes, want %s of %d bytes", typ, len(data), obj.Type, len(obj.Data))
+				}
+			}
+		})
+	}
+}
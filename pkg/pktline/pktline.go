@@ -0,0 +1,183 @@
+// Package pktline reads and writes the pkt-line framing that all git
+// transports speak. A packet starts with four hex digits giving its
+// length, including themselves; the lengths below four mark special
+// packets without data, like the flush packet that ends a list.
+package pktline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Type is the kind of a packet: data, or one of the special packets
+type Type int
+
+const (
+	// Data is a packet that carries data
+	Data Type = iota
+	// Flush, "0000", ends a list of packets
+	Flush
+	// Delim, "0001", separates the sections of a protocol version 2
+	// request or response
+	Delim
+	// ResponseEnd, "0002", ends a protocol version 2 response in
+	// stateless connections
+	ResponseEnd
+)
+
+// MaxData is the most data a packet can carry
+const MaxData = 65516
+
+// RemoteError is the message of an "ERR" packet, which the other side
+// sends instead of what was asked when it can't go on
+type RemoteError string
+
+func (e RemoteError) Error() string {
+	return "remote error: " + string(e)
+}
+
+// Reader reads packets
+type Reader struct {
+	r      *bufio.Reader
+	header [4]byte
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// ReadPacket reads the next packet. The data is nil for special packets.
+func (r *Reader) ReadPacket() ([]byte, Type, error) {
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		return nil, 0, err
+	}
+	length, err := strconv.ParseUint(string(r.header[:]), 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid packet length %q", r.header)
+	}
+	switch {
+	case length == 0:
+		return nil, Flush, nil
+	case length == 1:
+		return nil, Delim, nil
+	case length == 2:
+		return nil, ResponseEnd, nil
+	case length < 4:
+		return nil, 0, fmt.Errorf("invalid packet length %q", r.header)
+	}
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return data, Data, nil
+}
+
+// ReadLine reads the next packet as a line of text, without its newline.
+// An "ERR" packet is returned as a RemoteError.
+func (r *Reader) ReadLine() (string, Type, error) {
+	data, typ, err := r.ReadPacket()
+	if err != nil {
+		return "", 0, err
+	}
+	line := strings.TrimSuffix(string(data), "\n")
+	if message, ok := strings.CutPrefix(line, "ERR "); ok {
+		return "", 0, RemoteError(message)
+	}
+	return line, typ, nil
+}
+
+// ReadSection reads lines up to a special packet, and returns them with
+// the type of the packet that ended them
+func (r *Reader) ReadSection() ([]string, Type, error) {
+	lines := []string{}
+	for {
+		line, typ, err := r.ReadLine()
+		if err != nil {
+			return nil, 0, err
+		}
+		if typ != Data {
+			return lines, typ, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// Peek returns the next n bytes without reading them
+func (r *Reader) Peek(n int) ([]byte, error) {
+	return r.r.Peek(n)
+}
+
+// Rest returns a reader of what comes after the packets, like a pack
+// that is sent without sideband
+func (r *Reader) Rest() io.Reader {
+	return r.r
+}
+
+// Writer writes packets
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WritePacket writes data as one packet
+func (w *Writer) WritePacket(data []byte) error {
+	if len(data) > MaxData {
+		return fmt.Errorf("packet of %d bytes is too long", len(data))
+	}
+	if _, err := fmt.Fprintf(w.w, "%04x", len(data)+4); err != nil {
+		return err
+	}
+	_, err := w.w.Write(data)
+	return err
+}
+
+// WriteLine writes a line of text as one packet, with a newline at the end
+func (w *Writer) WriteLine(line string) error {
+	return w.WritePacket([]byte(line + "\n"))
+}
+
+// WriteLines writes each line as a packet, then a flush packet
+func (w *Writer) WriteLines(lines ...string) error {
+	for _, line := range lines {
+		if err := w.WriteLine(line); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Flush writes a flush packet
+func (w *Writer) Flush() error {
+	return w.special(Flush)
+}
+
+// Delim writes a delimiter packet
+func (w *Writer) Delim() error {
+	return w.special(Delim)
+}
+
+// ResponseEnd writes a response end packet
+func (w *Writer) ResponseEnd() error {
+	return w.special(ResponseEnd)
+}
+
+// special writes a packet without data, whose length is one less than
+// its type
+func (w *Writer) special(typ Type) error {
+	_, err := fmt.Fprintf(w.w, "%04x", int(typ)-1)
+	return err
+}
+
+// WriteError writes an "ERR" packet with message
+func (w *Writer) WriteError(message string) error {
+	return w.WriteLine("ERR " + message)
+}
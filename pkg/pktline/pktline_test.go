@@ -0,0 +1,113 @@
+package pktline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// advertisement is what git upload-pack --advertise-refs sent for a
+// repository with one commit and an annotated tag
+const advertisement = "010b2e770244a2bfe3b2a4343d99c50395f789ceb3bf HEAD\x00multi_ack thin-pack side-band side-band-64k ofs-delta shallow deepen-since deepen-not deepen-relative no-progress include-tag multi_ack_detailed no-done symref=HEAD:refs/heads/main object-format=sha1 agent=git/2.39.5\n" +
+	"003d2e770244a2bfe3b2a4343d99c50395f789ceb3bf refs/heads/main\n" +
+	"003a8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762 refs/tags/v1\n" +
+	"003d2e770244a2bfe3b2a4343d99c50395f789ceb3bf refs/tags/v1^{}\n" +
+	"0000"
+
+var advertisedLines = []string{
+	"2e770244a2bfe3b2a4343d99c50395f789ceb3bf HEAD\x00multi_ack thin-pack side-band side-band-64k ofs-delta shallow deepen-since deepen-not deepen-relative no-progress include-tag multi_ack_detailed no-done symref=HEAD:refs/heads/main object-format=sha1 agent=git/2.39.5",
+	"2e770244a2bfe3b2a4343d99c50395f789ceb3bf refs/heads/main",
+	"8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762 refs/tags/v1",
+	"2e770244a2bfe3b2a4343d99c50395f789ceb3bf refs/tags/v1^{}",
+}
+
+func TestReadSection(t *testing.T) {
+	r := NewReader(strings.NewReader(advertisement))
+	lines, typ, err := r.ReadSection()
+	if err != nil {
+		t.Fatalf("ReadSection() error = %v", err)
+	}
+	if typ != Flush {
+		t.Errorf("ReadSection() ended at %v, want %v", typ, Flush)
+	}
+	if !reflect.DeepEqual(lines, advertisedLines) {
+		t.Errorf("ReadSection() = %q, want %q", lines, advertisedLines)
+	}
+	if _, _, err := r.ReadPacket(); err != io.EOF {
+		t.Errorf("ReadPacket() at the end error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestWriteLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).WriteLines(advertisedLines...); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != advertisement {
+		t.Errorf("WriteLines() = %q, want %q", buf.String(), advertisement)
+	}
+
+	buf.Reset()
+	w := NewWriter(&buf)
+	w.WriteLine("command=ls-refs")
+	w.Delim()
+	w.WriteLine("peel")
+	w.Flush()
+	w.ResponseEnd()
+	want := "0014command=ls-refs\n00010009peel\n00000002"
+	if buf.String() != want {
+		t.Errorf("written = %q, want %q", buf.String(), want)
+	}
+	if err := w.WritePacket(make([]byte, MaxData+1)); err == nil {
+		t.Errorf("WritePacket() of too much data succeeded")
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Type
+		wantErr bool
+	}{
+		{"special packets", "000000010002", []Type{Flush, Delim, ResponseEnd}, false},
+		{"empty data", "0004", []Type{Data}, false},
+		{"bad length", "00x1", nil, true},
+		{"length inside the header", "0003", nil, true},
+		{"cut off", "0010abc", nil, true},
+		{"cut off header", "00", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(strings.NewReader(tt.input))
+			got := []Type{}
+			var err error
+			for {
+				var typ Type
+				if _, typ, err = r.ReadPacket(); err != nil {
+					break
+				}
+				got = append(got, typ)
+			}
+			if (err != io.EOF) != tt.wantErr {
+				t.Errorf("ReadPacket() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadPacket() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLineError(t *testing.T) {
+	var buf bytes.Buffer
+	NewWriter(&buf).WriteError("access denied")
+	_, _, err := NewReader(&buf).ReadLine()
+	var remote RemoteError
+	if !errors.As(err, &remote) || remote != "access denied" {
+		t.Errorf("ReadLine() error = %v, want the remote error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package pktline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The bands of a sideband stream. With side-band or side-band-64k, the
+// first byte of every packet says which band its data is on.
+const (
+	// BandData carries the data that was asked for, like a pack
+	BandData = 1
+	// BandProgress carries progress messages for the user
+	BandProgress = 2
+	// BandError carries a fatal error, after which nothing else comes
+	BandError = 3
+)
+
+// Demuxer reads the data band of a sideband stream, which ends at a flush
+// packet. Progress messages are copied to progress, if it isn't nil.
+type Demuxer struct {
+	r        *Reader
+	progress io.Writer
+	pending  []byte
+	done     bool
+}
+
+func NewDemuxer(r *Reader, progress io.Writer) *Demuxer {
+	return &Demuxer{r: r, progress: progress}
+}
+
+func (d *Demuxer) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		data, typ, err := d.r.ReadPacket()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if typ != Data {
+			d.done = true
+			continue
+		}
+		if len(data) == 0 {
+			return 0, errors.New("sideband packet without a band")
+		}
+		switch data[0] {
+		case BandData:
+			d.pending = data[1:]
+		case BandProgress:
+			if d.progress != nil {
+				if _, err := d.progress.Write(data[1:]); err != nil {
+					return 0, err
+				}
+			}
+		case BandError:
+			d.done = true
+			return 0, RemoteError(strings.TrimSuffix(string(data[1:]), "\n"))
+		default:
+			return 0, fmt.Errorf("sideband packet on unknown band %d", data[0])
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// bandWriter writes data in packets on a band
+type bandWriter struct {
+	w    *Writer
+	band byte
+	max  int
+}
+
+// Sideband returns a writer that writes on a band of a sideband stream,
+// in packets that carry at most max bytes, band included: 65520 - 4 for
+// side-band-64k, 1000 - 4 for side-band
+func (w *Writer) Sideband(band byte, max int) io.Writer {
+	return &bandWriter{w: w, band: band, max: min(max, MaxData)}
+}
+
+func (b *bandWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), b.max-1)]
+		if err := b.w.WritePacket(append([]byte{b.band}, chunk...)); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
@@ -0,0 +1,115 @@
+package pktline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/pack"
+)
+
+// fetchResponse is what git upload-pack --stateless-rpc answered to a
+// want of the commit in advertisement, with side-band-64k
+const fetchResponse = "0008NAK\n" +
+	"0023\x02Enumerating objects: 3, done.\n" +
+	"0022\x02Counting objects:  33% (1/3)\r" +
+	"0022\x02Counting objects:  66% (2/3)\r" +
+	"007b\x02Counting objects: 100% (3/3)\rCounting objects: 100% (3/3), done.\nTotal 3 (delta 0), reused 0 (delta 0), pack-reused 0\n" +
+	"00b7\x01PACK\x00\x00\x00\x02\x00\x00\x00\x03\x96\x07x\x9c+)JMUH6\xb1\xb4\xb04O3\xb2L\xb3\xb40\xb4L4HL2\xb305H1O5221165\xb0H4L4\xb2L1\xe5J,-\xc9\xc8/RpT\xb0It\xa8\xb0S047\x80\x02\x05m\x10\xc9\x95\x9c\x9f\x9b\x9bYR\x92\x8aG\x09W~^*\x17\x00[\x03\x1e>\xad\x01x\x9c340031QHdp\xdd\xd9\xbc\xcfl\xbb\r{\xc7\x9d9{\xb6\xe7\xecn\xf8S\xbd!\x1c\x00\x98}\x0c\xb83x\x9c\xcb\xc8\xe4\x02\x00\x02\x17\x00\xdcP\xa0\xfdNGA\x03\x80\xd8\xe9\xf0\x9e\x10s\x81\xf2\x9d\x9cT" +
+	"0006\x01 " +
+	"0000"
+
+func TestDemuxer(t *testing.T) {
+	r := NewReader(strings.NewReader(fetchResponse))
+	if line, _, err := r.ReadLine(); err != nil || line != "NAK" {
+		t.Fatalf("ReadLine() = %q, %v, want NAK", line, err)
+	}
+	var progress bytes.Buffer
+	data, err := io.ReadAll(NewDemuxer(r, &progress))
+	if err != nil {
+		t.Fatalf("reading the data band error = %v", err)
+	}
+	if !strings.HasSuffix(progress.String(), "Total 3 (delta 0), reused 0 (delta 0), pack-reused 0\n") {
+		t.Errorf("progress = %q", progress.String())
+	}
+
+	entries, _, err := pack.IndexStream(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("the data band is not a pack: %v", err)
+	}
+	names := map[string]bool{}
+	for _, e := range entries {
+		names[string(e.Name)] = true
+	}
+	if len(entries) != 3 || !names["\x2e\x77\x02\x44\xa2\xbf\xe3\xb2\xa4\x34\x3d\x99\xc5\x03\x95\xf7\x89\xce\xb3\xbf"] {
+		t.Errorf("the pack has %d objects, want 3 with the wanted commit", len(entries))
+	}
+}
+
+func TestDemuxerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"error band", "0013\x03upload failed\n0000"},
+		{"unknown band", "0006\x04x0000"},
+		{"no band", "00040000"},
+		{"no flush", "0006\x01x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(NewDemuxer(NewReader(strings.NewReader(tt.input)), nil))
+			if err == nil {
+				t.Errorf("reading the data band succeeded")
+			}
+		})
+	}
+
+	_, err := io.ReadAll(NewDemuxer(NewReader(strings.NewReader("0013\x03upload failed\n0000")), nil))
+	var remote RemoteError
+	if !errors.As(err, &remote) || remote != "upload failed" {
+		t.Errorf("error band error = %v, want the remote error", err)
+	}
+}
+
+func TestSideband(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	data := bytes.Repeat([]byte("0123456789"), 30)
+	if _, err := w.Sideband(BandData, 100).Write(data); err != nil {
+		t.Fatal(err)
+	}
+	w.Sideband(BandProgress, 100).Write([]byte("done\n"))
+	w.Flush()
+
+	// Every packet carries at most 100 bytes
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	packets := 0
+	for {
+		packet, typ, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ == Flush {
+			break
+		}
+		if len(packet) > 100 {
+			t.Errorf("packet of %d bytes, want at most 100", len(packet))
+		}
+		packets++
+	}
+	if packets != 5 {
+		t.Errorf("wrote %d packets, want 5", packets)
+	}
+
+	var progress bytes.Buffer
+	got, err := io.ReadAll(NewDemuxer(NewReader(&buf), &progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) || progress.String() != "done\n" {
+		t.Errorf("read %q and progress %q, want %q and %q", got, progress.String(), data, "done\n")
+	}
+}
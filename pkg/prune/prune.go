@@ -0,0 +1,176 @@
+package prune
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// ErrPreciousObjects is returned for repositories that set
+// extensions.preciousObjects, whose objects may be used by other
+// repositories and must never be removed
+var ErrPreciousObjects = errors.New("cannot prune in a precious-objects repo")
+
+// Options change which objects Prune removes
+type Options struct {
+	// Expire is when unreachable objects expire: objects that were
+	// written after it are kept, and so is everything they reach
+	Expire time.Time
+	// DryRun only reports what would be removed
+	DryRun bool
+}
+
+// Object is a loose object
+type Object struct {
+	SHA     string
+	ModTime time.Time
+}
+
+// Prune removes the loose objects that are unreachable and expired, and
+// returns them sorted by name. Nothing is removed if the objects that are
+// reachable can't all be read, since objects below one that is missing
+// would be taken for unreachable.
+func Prune(repo *repository.Repository, opts Options) ([]Object, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if precious, _ := cfg.GetBool("extensions", "preciousObjects"); precious {
+		return nil, ErrPreciousObjects
+	}
+
+	loose, err := LooseObjects(repo)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := Roots(repo)
+	if err != nil {
+		return nil, err
+	}
+	// Recent objects may be used by a command that is still running, so
+	// what they reach has to be kept too
+	for _, obj := range loose {
+		if !obj.ModTime.Before(opts.Expire) {
+			roots = append(roots, Root{SHA: obj.SHA, Source: "recent object"})
+		}
+	}
+	reachable, err := Reachable(repo, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := []Object{}
+	for _, obj := range loose {
+		if reachable[obj.SHA] || !obj.ModTime.Before(opts.Expire) {
+			continue
+		}
+		pruned = append(pruned, obj)
+		if opts.DryRun {
+			continue
+		}
+		if err := os.Remove(repo.RepositoryPath("objects", obj.SHA[:2], obj.SHA[2:])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		// Like git, fan-out directories that are empty are removed
+		os.Remove(repo.RepositoryPath("objects", obj.SHA[:2]))
+	}
+	return pruned, nil
+}
+
+// Recent returns the unreachable objects that haven't expired, since they
+// were written at or after expire, with the objects they reach. A command
+// that is still running may use them, so they have to be kept.
+func Recent(repo *repository.Repository, reachable map[string]bool, expire time.Time) (map[string]bool, error) {
+	times, err := objects.ModTimes(repo)
+	if err != nil {
+		return nil, err
+	}
+	roots := []Root{}
+	for sha, modTime := range times {
+		if !reachable[sha] && !modTime.Before(expire) {
+			roots = append(roots, Root{SHA: sha, Source: "recent object"})
+		}
+	}
+	return Reachable(repo, roots)
+}
+
+// LooseObjects returns the loose objects of the repository, sorted by name
+func LooseObjects(repo *repository.Repository) ([]Object, error) {
+	loose := []Object{}
+	err := objects.ForEachLoose(repo, func(obj objects.LooseObject) error {
+		loose = append(loose, Object{SHA: obj.SHA.AsString(), ModTime: obj.ModTime})
+		return nil
+	})
+	return loose, err
+}
+
+// Reachable returns the objects that can be reached from roots: commits
+// reach their tree and parents, tags the object they tag and trees their
+// entries. Roots that don't exist are skipped, but an object that is
+// missing below a root is an error, since what it reaches is unknown.
+// Submodule commits are never followed, they are in another repository.
+func Reachable(repo *repository.Repository, roots []Root) (map[string]bool, error) {
+	reachable := map[string]bool{}
+	type item struct {
+		sha  string
+		from string
+	}
+	queue := []item{}
+	for _, root := range roots {
+		sha, err := hashing.NewShaFromHex(root.SHA)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object name %s in %s", root.SHA, root.Source)
+		}
+		if objects.HasObject(repo, sha) {
+			queue = append(queue, item{sha: root.SHA})
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if reachable[current.sha] {
+			continue
+		}
+		reachable[current.sha] = true
+
+		sha, err := hashing.NewShaFromHex(current.sha)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := objects.ReadObject(repo, sha)
+		if err != nil {
+			if current.from != "" {
+				return nil, fmt.Errorf("object %s, referenced by %s, can't be read: %w", current.sha, current.from, err)
+			}
+			return nil, err
+		}
+
+		next := func(sha []byte) {
+			queue = append(queue, item{sha: string(sha), from: current.sha})
+		}
+		switch obj := obj.(type) {
+		case *objects.Commit:
+			tree, _ := obj.GetValue("tree")
+			next(tree)
+			for _, parent := range obj.GetValues("parent") {
+				next(parent)
+			}
+		case *objects.Tag:
+			target, _ := obj.GetValue("object")
+			next(target)
+		case *objects.Tree:
+			for _, leaf := range obj.Items {
+				if objects.LeafType(leaf) != objects.TypeCommit {
+					next([]byte(leaf.PrintSHA()))
+				}
+			}
+		}
+	}
+	return reachable, nil
+}
@@ -0,0 +1,196 @@
+package prune
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/reflog"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+type testRepo struct {
+	t    *testing.T
+	repo *repository.Repository
+}
+
+func setupRepo(t *testing.T) *testRepo {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	return &testRepo{t: t, repo: repo}
+}
+
+func (r *testRepo) blob(contents string) *hashing.SHA {
+	sha, err := objects.ObjectHash([]byte(contents), objects.TypeBlob, r.repo)
+	if err != nil {
+		r.t.Fatalf("ObjectHash() error = %v", err)
+	}
+	return sha
+}
+
+func (r *testRepo) commit(blob *hashing.SHA, parents ...*hashing.SHA) *hashing.SHA {
+	tree, err := objects.WriteObject(&objects.Tree{Items: []*objects.TreeLeaf{{Mode: []byte("100644"), Path: []byte("file"), Sha: blob}}}, r.repo)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	ident := objects.Ident{Name: "A U Thor", Email: "author@example.com", When: time.Unix(1700000000, 0).UTC()}
+	sha, err := objects.CreateCommit(r.repo, tree, parents, ident, ident, "commit")
+	if err != nil {
+		r.t.Fatalf("CreateCommit() error = %v", err)
+	}
+	return sha
+}
+
+// age makes every loose object a month old
+func (r *testRepo) age() {
+	loose, err := LooseObjects(r.repo)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	old := time.Now().AddDate(0, -1, 0)
+	for _, obj := range loose {
+		if err := os.Chtimes(r.repo.RepositoryPath("objects", obj.SHA[:2], obj.SHA[2:]), old, old); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+func names(objs []Object) []string {
+	shas := []string{}
+	for _, obj := range objs {
+		shas = append(shas, obj.SHA)
+	}
+	return shas
+}
+
+func TestPrune(t *testing.T) {
+	r := setupRepo(t)
+	first := r.commit(r.blob("first"))
+	second := r.commit(r.blob("second"), first)
+	if err := references.Update(r.repo, "refs/heads/main", second.AsString()); err != nil {
+		t.Fatal(err)
+	}
+	amended := r.commit(r.blob("amended"), first)
+	if err := reflog.Write(r.repo, "refs/heads/main", []*reflog.Entry{
+		{Old: strings.Repeat("0", 40), New: amended.AsString(), Ident: "A U Thor <author@example.com>", When: time.Now(), Zone: "+0000"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	staged := r.blob("staged")
+	if err := index.Update(r.repo, func(idx *index.Index) error {
+		idx.Add(&index.Entry{ModeType: index.ModeTypeRegular, ModePerms: 0o644, SHA: staged, Name: "staged"})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	merging := r.blob("merging")
+	if err := os.WriteFile(r.repo.RepositoryPath("MERGE_HEAD"), []byte(merging.AsString()+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dangling := r.blob("dangling")
+	r.age()
+	recent := r.blob("recent")
+
+	opts := Options{Expire: time.Now().AddDate(0, 0, -14), DryRun: true}
+	pruned, err := Prune(r.repo, opts)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if want := []string{dangling.AsString()}; !reflect.DeepEqual(names(pruned), want) {
+		t.Errorf("Prune() = %v, want %v", names(pruned), want)
+	}
+	if !objects.HasObject(r.repo, dangling) {
+		t.Error("Prune() with DryRun removed an object")
+	}
+
+	opts.DryRun = false
+	if _, err := Prune(r.repo, opts); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if objects.HasObject(r.repo, dangling) {
+		t.Error("Prune() kept an unreachable object that expired")
+	}
+	for _, sha := range []*hashing.SHA{first, second, amended, staged, merging, recent} {
+		if !objects.HasObject(r.repo, sha) {
+			t.Errorf("Prune() removed %s, which is in use", sha.AsString())
+		}
+	}
+}
+
+func TestPruneKeepsWhatRecentObjectsReach(t *testing.T) {
+	r := setupRepo(t)
+	old := r.blob("old")
+	r.age()
+	// A commit that is being created, whose ref isn't updated yet
+	r.commit(old)
+
+	pruned, err := Prune(r.repo, Options{Expire: time.Now().AddDate(0, 0, -14)})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(pruned) != 0 {
+		t.Errorf("Prune() = %v, want nothing, since a recent commit uses the blob", names(pruned))
+	}
+}
+
+func TestRecent(t *testing.T) {
+	r := setupRepo(t)
+	old, expired := r.blob("old"), r.blob("expired")
+	r.age()
+	commit := r.commit(old)
+	fresh := r.blob("fresh")
+
+	recent, err := Recent(r.repo, map[string]bool{}, time.Now().AddDate(0, 0, -14))
+	if err != nil {
+		t.Fatalf("Recent() error = %v", err)
+	}
+	for _, sha := range []*hashing.SHA{commit, old, fresh} {
+		if !recent[sha.AsString()] {
+			t.Errorf("Recent() doesn't have %s", sha.AsString())
+		}
+	}
+	if recent[expired.AsString()] {
+		t.Errorf("Recent() has the expired blob")
+	}
+}
+
+func TestPruneMissingObject(t *testing.T) {
+	r := setupRepo(t)
+	blob := r.blob("content")
+	commit := r.commit(blob)
+	if err := references.Update(r.repo, "refs/heads/main", commit.AsString()); err != nil {
+		t.Fatal(err)
+	}
+	dangling := r.blob("dangling")
+	r.age()
+	if err := os.Remove(r.repo.RepositoryPath("objects", blob.AsString()[:2], blob.AsString()[2:])); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Prune(r.repo, Options{Expire: time.Now()}); err == nil {
+		t.Error("Prune() with a missing object, want an error")
+	}
+	if !objects.HasObject(r.repo, dangling) {
+		t.Error("Prune() removed objects although the history is incomplete")
+	}
+}
+
+func TestPrunePreciousObjects(t *testing.T) {
+	r := setupRepo(t)
+	config := "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tpreciousObjects = true\n"
+	if err := os.WriteFile(r.repo.RepositoryPath("config"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Prune(r.repo, Options{Expire: time.Now()}); !errors.Is(err, ErrPreciousObjects) {
+		t.Errorf("Prune() error = %v, want %v", err, ErrPreciousObjects)
+	}
+}
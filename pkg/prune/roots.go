@@ -0,0 +1,170 @@
+// Package prune removes loose objects that nothing needs anymore, like
+// git prune. An object is only removed when it can't be reached from any
+// root, see Roots, and when it is older than the expiry time, so objects
+// written by commands that run at the same time are kept.
+package prune
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/index"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/reflog"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Root is an object that is in use, and that keeps everything it reaches
+// from being pruned
+type Root struct {
+	SHA string
+	// Source says where the object is used, e.g. refs/heads/main or the
+	// index of a worktree
+	Source string
+}
+
+// specialHeads are the files in the gitdir of a worktree that name objects
+// while a command is in progress, or that remember them afterwards
+var specialHeads = []string{
+	"HEAD",
+	"ORIG_HEAD",
+	"MERGE_HEAD",
+	"CHERRY_PICK_HEAD",
+	"REVERT_HEAD",
+	"REBASE_HEAD",
+	"BISECT_HEAD",
+	"AUTO_MERGE",
+	"FETCH_HEAD",
+}
+
+var hexShaRegex = regexp.MustCompile(`\b[0-9a-f]{40}\b`)
+
+// Roots returns the objects that are in use: the refs, including
+// refs/stash, and for every worktree its HEAD and the other special heads
+// like MERGE_HEAD, its index, including conflicts, and the blobs that
+// got undo needs. Every object in the ref logs is a root as well, until
+// its entry expires.
+func Roots(repo *repository.Repository) ([]Root, error) {
+	roots := []Root{}
+	add := func(sha, source string) {
+		if sha != "" && strings.Trim(sha, "0") != "" {
+			roots = append(roots, Root{SHA: sha, Source: source})
+		}
+	}
+
+	refs, err := references.All(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		add(ref.SHA, ref.Name.String())
+	}
+	if err := addReflogs(repo, add); err != nil {
+		return nil, err
+	}
+
+	worktrees, err := repo.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range worktrees {
+		if err := addWorktreeRoots(wt, add); err != nil {
+			return nil, fmt.Errorf("worktree %s: %w", wt.WorkTree(), err)
+		}
+	}
+	return roots, nil
+}
+
+// addWorktreeRoots adds the roots that each worktree has on its own
+func addWorktreeRoots(wt *repository.Repository, add func(sha, source string)) error {
+	for _, name := range specialHeads {
+		shas, err := readHeadFile(wt.RepositoryPath(name))
+		if err != nil {
+			return err
+		}
+		for _, sha := range shas {
+			add(sha, name)
+		}
+	}
+
+	idx, err := index.Read(wt)
+	if err != nil {
+		return err
+	}
+	for _, e := range idx.Entries {
+		// Submodule commits are in another repository
+		if e.ModeType != index.ModeTypeGitlink {
+			add(e.SHA.AsString(), "index")
+		}
+	}
+
+	entries, err := reflog.Read(wt, "HEAD")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		add(e.Old, "logs/HEAD")
+		add(e.New, "logs/HEAD")
+	}
+
+	// The undo record has the blobs that were backed up, and the index
+	// entries and stashes to put back
+	undo, err := os.ReadFile(wt.RepositoryPath("GOT_UNDO"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, sha := range hexShaRegex.FindAllString(string(undo), -1) {
+		add(sha, "GOT_UNDO")
+	}
+	return nil
+}
+
+// addReflogs adds the objects in the logs of the refs that are shared by
+// all worktrees. The HEAD logs are added per worktree.
+func addReflogs(repo *repository.Repository, add func(sha, source string)) error {
+	refs, err := reflog.Refs(repo)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if ref == "HEAD" {
+			continue
+		}
+		entries, err := reflog.Read(repo, ref)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			add(e.Old, "logs/"+ref)
+			add(e.New, "logs/"+ref)
+		}
+	}
+	return nil
+}
+
+// readHeadFile returns the objects named in a file like HEAD or
+// FETCH_HEAD: every line that starts with an object name. A symbolic ref
+// names no object, since the ref it points to is a root already.
+func readHeadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	shas := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) >= 40 && hexShaRegex.MatchString(line[:40]) {
+			shas = append(shas, line[:40])
+		}
+	}
+	return shas, scanner.Err()
+}
@@ -0,0 +1,82 @@
+package references
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckOptions relaxes the rules of CheckName
+type CheckOptions struct {
+	// AllowOneLevel accepts names without a slash, like HEAD
+	AllowOneLevel bool
+	// RefspecPattern accepts a single * as a component, like refs/heads/*
+	RefspecPattern bool
+}
+
+// CheckName validates a ref name with the rules of git check-ref-format
+func CheckName(name string, opts CheckOptions) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("'%s' is not a valid ref name: %s", name, reason)
+	}
+
+	switch {
+	case name == "":
+		return invalid("it is empty")
+	case name == "@":
+		return invalid("it cannot be '@'")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return invalid("it cannot begin or end with a slash")
+	case strings.HasSuffix(name, "."):
+		return invalid("it cannot end with a dot")
+	case strings.Contains(name, ".."):
+		return invalid("it cannot contain '..'")
+	case strings.Contains(name, "@{"):
+		return invalid("it cannot contain '@{'")
+	case !opts.AllowOneLevel && !strings.Contains(name, "/"):
+		return invalid("it must contain at least one slash")
+	}
+
+	stars := 0
+	for _, c := range name {
+		switch {
+		case c < 0x20 || c == 0x7f:
+			return invalid("it cannot contain control characters")
+		case strings.ContainsRune(" ~^:?[\\", c):
+			return invalid(fmt.Sprintf("it cannot contain '%c'", c))
+		case c == '*':
+			stars++
+			if !opts.RefspecPattern || stars > 1 {
+				return invalid("it cannot contain '*'")
+			}
+		}
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		switch {
+		case component == "":
+			return invalid("it cannot contain consecutive slashes")
+		case strings.HasPrefix(component, "."):
+			return invalid("a component cannot begin with a dot")
+		case strings.HasSuffix(component, ".lock"):
+			return invalid("a component cannot end with '.lock'")
+		}
+	}
+	return nil
+}
+
+// NormalizeName removes leading slashes and collapses consecutive slashes,
+// like git check-ref-format --normalize
+func NormalizeName(name string) string {
+	components := []string{}
+	for _, component := range strings.Split(name, "/") {
+		if component != "" {
+			components = append(components, component)
+		}
+	}
+	normalized := strings.Join(components, "/")
+	if strings.HasSuffix(name, "/") && normalized != "" {
+		// A trailing slash stays, so CheckName rejects it
+		normalized += "/"
+	}
+	return normalized
+}
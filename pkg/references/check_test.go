@@ -0,0 +1,69 @@
+package references
+
+import "testing"
+
+func TestCheckName(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CheckOptions
+		wantErr bool
+	}{
+		{name: "refs/heads/main"},
+		{name: "refs/heads/feature/x-1.2"},
+		{name: "refs/tags/v1.0"},
+		{name: "HEAD", wantErr: true},
+		{name: "HEAD", opts: CheckOptions{AllowOneLevel: true}},
+		{name: "", wantErr: true},
+		{name: "@", wantErr: true},
+		{name: "refs/heads/a..b", wantErr: true},
+		{name: "refs/heads/.hidden", wantErr: true},
+		{name: "refs/heads/main.lock", wantErr: true},
+		{name: "refs/heads/main/", wantErr: true},
+		{name: "/refs/heads/main", wantErr: true},
+		{name: "refs//heads/main", wantErr: true},
+		{name: "refs/heads/main.", wantErr: true},
+		{name: "refs/heads/a@{1}", wantErr: true},
+		{name: "refs/heads/a b", wantErr: true},
+		{name: "refs/heads/a\tb", wantErr: true},
+		{name: "refs/heads/a\x7fb", wantErr: true},
+		{name: "refs/heads/a~1", wantErr: true},
+		{name: "refs/heads/a^", wantErr: true},
+		{name: "refs/heads/a:b", wantErr: true},
+		{name: "refs/heads/a?", wantErr: true},
+		{name: "refs/heads/a[b", wantErr: true},
+		{name: "refs/heads/a\\b", wantErr: true},
+		{name: "refs/heads/*", wantErr: true},
+		{name: "refs/heads/*", opts: CheckOptions{RefspecPattern: true}},
+		{name: "refs/*/*", opts: CheckOptions{RefspecPattern: true}, wantErr: true},
+		{name: "refs/heads/a@b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckName(tt.name, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"refs/heads/main", "refs/heads/main"},
+		{"/refs/heads/main", "refs/heads/main"},
+		{"refs//heads///main", "refs/heads/main"},
+		{"refs/heads/main/", "refs/heads/main/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeName(tt.name); got != tt.want {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
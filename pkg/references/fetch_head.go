@@ -0,0 +1,71 @@
+package references
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// FetchHeadEntry is a line of FETCH_HEAD, which has a line for every ref
+// that the last fetch got
+type FetchHeadEntry struct {
+	SHA string
+	// NotForMerge marks the refs that a merge of FETCH_HEAD leaves out
+	NotForMerge bool
+	// Description says where the ref comes from, e.g.
+	// "branch 'main' of https://example.com/repo"
+	Description string
+}
+
+// WriteFetchHead replaces FETCH_HEAD with entries, one per line as
+// "<sha>\t[not-for-merge]\t<description>"
+func WriteFetchHead(repo *repository.Repository, entries []FetchHeadEntry) error {
+	var b strings.Builder
+	for _, e := range entries {
+		marker := ""
+		if e.NotForMerge {
+			marker = "not-for-merge"
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", e.SHA, marker, e.Description)
+	}
+	mode, err := repo.SharedMode(fs.DefaultPerm)
+	if err != nil {
+		return err
+	}
+	return fs.AtomicWrite(repo.RepositoryPath("FETCH_HEAD"), []byte(b.String()), fs.WithExactPerm(mode))
+}
+
+// ReadFetchHead returns the entries of FETCH_HEAD, or none if nothing was
+// fetched yet
+func ReadFetchHead(repo *repository.Repository) ([]FetchHeadEntry, error) {
+	data, err := os.ReadFile(repo.RepositoryPath("FETCH_HEAD"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseFetchHead(data)
+}
+
+func parseFetchHead(data []byte) ([]FetchHeadEntry, error) {
+	entries := []FetchHeadEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 || (fields[1] != "" && fields[1] != "not-for-merge") {
+			return nil, errors.New("malformed FETCH_HEAD line: " + line)
+		}
+		entries = append(entries, FetchHeadEntry{SHA: fields[0], NotForMerge: fields[1] != "", Description: fields[2]})
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,103 @@
+package references
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// NamespacePrefix returns the prefix under which the refs of a namespace
+// are stored. Nested namespaces are separated by slashes, so "a/b" is
+// stored in refs/namespaces/a/refs/namespaces/b/.
+func NamespacePrefix(namespace string) string {
+	prefix := ""
+	for _, component := range strings.Split(namespace, "/") {
+		if component != "" {
+			prefix += "refs/namespaces/" + component + "/"
+		}
+	}
+	return prefix
+}
+
+// NamespaceFromEnv returns the namespace set in GIT_NAMESPACE
+func NamespaceFromEnv() string {
+	return os.Getenv("GIT_NAMESPACE")
+}
+
+// HiddenRefs implements the transfer.hideRefs configuration, which keeps
+// refs out of the advertisement of the server-side commands.
+//
+// Each rule hides the refs that start with it. A rule starting with !
+// shows the refs again, and the last matching rule wins. Rules starting
+// with ^ match the full ref name, including the namespace prefix.
+type HiddenRefs []string
+
+// LoadHiddenRefs reads transfer.hideRefs followed by <section>.hideRefs,
+// where section is uploadpack or receive
+func LoadHiddenRefs(cfg config.GitConfig, section string) HiddenRefs {
+	rules := HiddenRefs{}
+	rules = append(rules, cfg.GetAll("transfer", "hideRefs")...)
+	rules = append(rules, cfg.GetAll(section, "hideRefs")...)
+	return rules
+}
+
+// Hidden reports whether a ref is hidden. ref is the name without the
+// namespace prefix, full is the name including it.
+func (h HiddenRefs) Hidden(ref, full string) bool {
+	for i := len(h) - 1; i >= 0; i-- {
+		rule := h[i]
+		negated := strings.HasPrefix(rule, "!")
+		rule = strings.TrimPrefix(rule, "!")
+
+		name := ref
+		if strings.HasPrefix(rule, "^") {
+			rule = rule[1:]
+			name = full
+		}
+		rule = strings.TrimSuffix(rule, "/")
+		if name == rule || strings.HasPrefix(name, rule+"/") {
+			return !negated
+		}
+	}
+	return false
+}
+
+// Advertised returns the refs that the server-side commands expose for a
+// namespace, with the namespace prefix stripped from their names. HEAD is
+// included first when it resolves to an object.
+func Advertised(repo *repository.Repository, namespace string, hidden HiddenRefs) ([]Ref, error) {
+	prefix := NamespacePrefix(namespace)
+	refs := []Ref{}
+
+	if head, err := Reference(prefix + "HEAD").Resolve(repo); err == nil && head != "" && !hidden.Hidden("HEAD", prefix+"HEAD") {
+		refs = append(refs, Ref{Name: "HEAD", SHA: head})
+	}
+
+	err := NewRefStore(repo).Each(prefix+"refs/", func(ref Ref) error {
+		name := strings.TrimPrefix(ref.Name.String(), prefix)
+		if hidden.Hidden(name, ref.Name.String()) {
+			return nil
+		}
+		ref.Name = Reference(name)
+		refs = append(refs, ref)
+		return nil
+	})
+	return refs, err
+}
+
+// MatchesPrefixes reports whether a ref starts with one of the prefixes a
+// client asked for, like the ref-prefix arguments of ls-refs. Without
+// prefixes, every ref matches.
+func MatchesPrefixes(ref string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+	return false
+}
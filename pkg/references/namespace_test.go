@@ -0,0 +1,60 @@
+package references
+
+import "testing"
+
+func TestNamespacePrefix(t *testing.T) {
+	tests := map[string]string{
+		"":     "",
+		"foo":  "refs/namespaces/foo/",
+		"a/b":  "refs/namespaces/a/refs/namespaces/b/",
+		"a//b": "refs/namespaces/a/refs/namespaces/b/",
+	}
+	for namespace, want := range tests {
+		if got := NamespacePrefix(namespace); got != want {
+			t.Errorf("NamespacePrefix(%q) = %q, want %q", namespace, got, want)
+		}
+	}
+}
+
+func TestHiddenRefs(t *testing.T) {
+	hidden := HiddenRefs{"refs/tags", "!refs/tags/v1", "^refs/namespaces/foo/refs/heads/secret", "refs/pull/"}
+
+	tests := []struct {
+		ref  string
+		full string
+		want bool
+	}{
+		{"refs/heads/main", "refs/heads/main", false},
+		{"refs/tags/v0", "refs/tags/v0", true},
+		{"refs/tags/v1", "refs/tags/v1", false},
+		{"refs/tagsfoo", "refs/tagsfoo", false},
+		{"refs/pull/1/head", "refs/pull/1/head", true},
+		{"refs/heads/secret", "refs/namespaces/foo/refs/heads/secret", true},
+		{"refs/heads/secret", "refs/heads/secret", false},
+	}
+	for _, tt := range tests {
+		if got := hidden.Hidden(tt.ref, tt.full); got != tt.want {
+			t.Errorf("Hidden(%q, %q) = %v, want %v", tt.ref, tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesPrefixes(t *testing.T) {
+	tests := []struct {
+		ref      string
+		prefixes []string
+		want     bool
+	}{
+		{"refs/heads/main", nil, true},
+		{"refs/heads/main", []string{"refs/heads/"}, true},
+		{"refs/heads/main", []string{"refs/tags/", "refs/heads/ma"}, true},
+		{"refs/tags/v1", []string{"refs/heads/"}, false},
+		{"HEAD", []string{"HEAD"}, true},
+		{"HEAD", []string{"refs/"}, false},
+	}
+	for _, tt := range tests {
+		if got := MatchesPrefixes(tt.ref, tt.prefixes); got != tt.want {
+			t.Errorf("MatchesPrefixes(%q, %q) = %v, want %v", tt.ref, tt.prefixes, got, tt.want)
+		}
+	}
+}
@@ -17,15 +17,16 @@ func (r Reference) String() string {
 }
 
 func (r Reference) Resolve(repo *repository.Repository) (string, error) {
-	path, err := repo.RepositoryFile(false, r.String())
+	// References that do not exist (yet), like the branch HEAD points
+	// to in a repository without commits, resolve to nothing
+	data, err := os.ReadFile(repo.RepositoryPath(r.String()))
 	if err != nil {
-		return "", err
-	}
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		//return "", fmt.Errorf("failed to resolve reference %s: %w", r.String(), err)
-		return "", nil
+		// The reference may have been packed
+		packed, err := NewRefStore(repo).packed()
+		if err != nil {
+			return "", err
+		}
+		return packed[r].SHA, nil
 	}
 	if bytes.HasPrefix(data, []byte("ref: ")) {
 		// We trim the "ref: " and the final "\n"
@@ -35,18 +36,30 @@ func (r Reference) Resolve(repo *repository.Repository) (string, error) {
 	return string(data[:len(data)-1]), nil
 }
 
+// Target returns the ref a symbolic ref points to, like the branch HEAD
+// is on. ok is false for refs that aren't symbolic.
+func (r Reference) Target(repo *repository.Repository) (Reference, bool) {
+	data, err := os.ReadFile(repo.RepositoryPath(r.String()))
+	if err != nil || !bytes.HasPrefix(data, []byte("ref: ")) {
+		return "", false
+	}
+	return Reference(bytes.TrimSpace(data[5:])), true
+}
+
 func List(repo *repository.Repository) (map[Reference]any, error) {
 	return list(repo, "refs")
 }
 
+// list returns the refs below path, which is relative to the gitdir.
+// Values are either the hash a ref resolves to, or a nested map for directories.
 func list(repo *repository.Repository, path string) (map[Reference]any, error) {
-	path, err := repo.RepositoryDir(false, path)
+	dir, err := repo.RepositoryDir(false, path)
 	if err != nil {
 		return nil, err
 	}
 
 	mapping := make(map[Reference]any)
-	entries, err := os.ReadDir(path)
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -55,17 +68,17 @@ func list(repo *repository.Repository, path string) (map[Reference]any, error) {
 		return entries[i].Name() < entries[j].Name()
 	})
 
-	for _, dir := range entries {
-		subdir := filepath.Join(path, dir.Name())
-		ref := Reference(dir.Name())
-		if fs.IsDirectory(subdir) {
-			res, err := list(repo, subdir)
+	for _, entry := range entries {
+		subpath := filepath.ToSlash(filepath.Join(path, entry.Name()))
+		ref := Reference(entry.Name())
+		if fs.IsDirectory(filepath.Join(dir, entry.Name())) {
+			res, err := list(repo, subpath)
 			if err != nil {
 				return nil, err
 			}
 			mapping[ref] = res
 		} else {
-			res, err := ref.Resolve(repo)
+			res, err := Reference(subpath).Resolve(repo)
 			if err != nil {
 				return nil, err
 			}
@@ -74,3 +87,17 @@ func list(repo *repository.Repository, path string) (map[Reference]any, error) {
 	}
 	return mapping, nil
 }
+
+// Ref is a reference together with the object it resolves to
+type Ref struct {
+	// Full name, e.g. refs/heads/main
+	Name Reference
+	SHA  string
+	// For annotated tags in packed-refs, the object the tag points to
+	Peeled string
+}
+
+// All returns every reference below refs/, sorted by name
+func All(repo *repository.Repository) ([]Ref, error) {
+	return NewRefStore(repo).List("refs/")
+}
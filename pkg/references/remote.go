@@ -0,0 +1,64 @@
+package references
+
+import (
+	"strings"
+)
+
+// RemotesPrefix is where the remote-tracking branches of remotes are, as
+// refs/remotes/<remote>/<branch>
+const RemotesPrefix = "refs/remotes/"
+
+// RemoteBranch returns the remote-tracking branch of a branch of a remote,
+// e.g. refs/remotes/origin/main
+func RemoteBranch(remote, branch string) Reference {
+	return Reference(RemotesPrefix + remote + "/" + branch)
+}
+
+// RemoteHead returns refs/remotes/<remote>/HEAD, the symbolic ref to the
+// default branch of a remote
+func RemoteHead(remote string) Reference {
+	return RemoteBranch(remote, "HEAD")
+}
+
+// SplitRemoteBranch splits a remote-tracking branch into the remote and
+// the branch. Since both can have slashes, the longest of remotes that
+// the name starts with is the remote. Without remotes, the remote is the
+// first part of the name.
+func SplitRemoteBranch(ref Reference, remotes []string) (remote, branch string, ok bool) {
+	rest, ok := strings.CutPrefix(ref.String(), RemotesPrefix)
+	if !ok {
+		return "", "", false
+	}
+	for _, r := range remotes {
+		if b, found := strings.CutPrefix(rest, r+"/"); found && b != "" && len(r) > len(remote) {
+			remote, branch = r, b
+		}
+	}
+	if remote != "" {
+		return remote, branch, true
+	}
+	if remotes != nil {
+		return "", "", false
+	}
+	remote, branch, ok = strings.Cut(rest, "/")
+	if !ok || remote == "" || branch == "" {
+		return "", "", false
+	}
+	return remote, branch, true
+}
+
+// RemoteBranches returns the remote-tracking branches of a remote, sorted
+// by name. Its symbolic HEAD is left out.
+func (s *RefStore) RemoteBranches(remote string) ([]Ref, error) {
+	refs, err := s.List(RemotesPrefix + remote + "/")
+	if err != nil {
+		return nil, err
+	}
+	branches := []Ref{}
+	for _, ref := range refs {
+		if ref.Name != RemoteHead(remote) {
+			branches = append(branches, ref)
+		}
+	}
+	return branches, nil
+}
@@ -0,0 +1,81 @@
+package references
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func TestSplitRemoteBranch(t *testing.T) {
+	tests := []struct {
+		ref     Reference
+		remotes []string
+		remote  string
+		branch  string
+		ok      bool
+	}{
+		{"refs/remotes/origin/main", nil, "origin", "main", true},
+		{"refs/remotes/origin/feature/x", nil, "origin", "feature/x", true},
+		{"refs/remotes/team/a/main", []string{"team", "team/a"}, "team/a", "main", true},
+		{"refs/remotes/team/a/main", []string{"origin"}, "", "", false},
+		{"refs/remotes/origin", nil, "", "", false},
+		{"refs/heads/main", nil, "", "", false},
+	}
+	for _, tt := range tests {
+		remote, branch, ok := SplitRemoteBranch(tt.ref, tt.remotes)
+		if remote != tt.remote || branch != tt.branch || ok != tt.ok {
+			t.Errorf("SplitRemoteBranch(%s, %q) = %q, %q, %v, want %q, %q, %v", tt.ref, tt.remotes, remote, branch, ok, tt.remote, tt.branch, tt.ok)
+		}
+	}
+}
+
+func TestRemoteBranches(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha := strings.Repeat("a", 40)
+	for _, ref := range []Reference{RemoteBranch("origin", "main"), RemoteBranch("origin", "dev"), RemoteBranch("originals", "main")} {
+		if err := Update(repo, ref, sha); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := UpdateSymbolic(repo, RemoteHead("origin"), RemoteBranch("origin", "main")); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := NewRefStore(repo).RemoteBranches("origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Name != "refs/remotes/origin/dev" || refs[1].Name != "refs/remotes/origin/main" {
+		t.Errorf("RemoteBranches(origin) = %+v", refs)
+	}
+}
+
+func TestFetchHead(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := ReadFetchHead(repo); err != nil || len(entries) != 0 {
+		t.Errorf("ReadFetchHead() without FETCH_HEAD = %v, %v", entries, err)
+	}
+	entries := []FetchHeadEntry{
+		{SHA: strings.Repeat("a", 40), Description: "branch 'main' of https://example.com/repo"},
+		{SHA: strings.Repeat("b", 40), NotForMerge: true, Description: "tag 'v1' of https://example.com/repo"},
+	}
+	if err := WriteFetchHead(repo, entries); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFetchHead(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != entries[0] || got[1] != entries[1] {
+		t.Errorf("ReadFetchHead() = %+v, want %+v", got, entries)
+	}
+	if _, err := parseFetchHead([]byte("aaaa\tmerge\tx\n")); err == nil {
+		t.Error("parseFetchHead() of a malformed line succeeded")
+	}
+}
@@ -0,0 +1,144 @@
+package references
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// RefStore gives access to all references of a repository: the loose
+// ones, which are files below refs/, and the ones in packed-refs
+type RefStore struct {
+	repo *repository.Repository
+}
+
+func NewRefStore(repo *repository.Repository) *RefStore {
+	return &RefStore{repo: repo}
+}
+
+// Each calls fn for every reference whose name starts with prefix, sorted
+// by name. Loose references take precedence over packed ones.
+func (s *RefStore) Each(prefix string, fn func(ref Ref) error) error {
+	packed, err := s.packed()
+	if err != nil {
+		return err
+	}
+	loose, err := s.loose()
+	if err != nil {
+		return err
+	}
+
+	// The packed refs are cached, so we must not modify them
+	refs := make(map[Reference]Ref, len(packed)+len(loose))
+	for name, ref := range packed {
+		refs[name] = ref
+	}
+	for name, ref := range loose {
+		refs[name] = ref
+	}
+
+	names := make([]Reference, 0, len(refs))
+	for name := range refs {
+		if strings.HasPrefix(name.String(), prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		if err := fn(refs[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the references whose name starts with prefix, sorted by name
+func (s *RefStore) List(prefix string) ([]Ref, error) {
+	refs := []Ref{}
+	err := s.Each(prefix, func(ref Ref) error {
+		refs = append(refs, ref)
+		return nil
+	})
+	return refs, err
+}
+
+func (s *RefStore) loose() (map[Reference]Ref, error) {
+	refs := map[Reference]Ref{}
+	root := s.repo.RepositoryPath("refs")
+	err := filepath.WalkDir(root, func(path string, d iofs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.repo.CommonDir(), path)
+		if err != nil {
+			return err
+		}
+		name := Reference(filepath.ToSlash(rel))
+		sha, err := name.Resolve(s.repo)
+		if err != nil {
+			return err
+		}
+		// Symbolic refs to branches without commits don't point anywhere
+		if sha != "" {
+			refs[name] = Ref{Name: name, SHA: sha}
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// packed returns the refs in the packed-refs file, which is parsed
+// once per repository handle and reparsed when it changes
+func (s *RefStore) packed() (map[Reference]Ref, error) {
+	value, err := s.repo.CachedFile(s.repo.RepositoryPath("packed-refs"), func(data []byte) (any, error) {
+		return parsePackedRefs(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(map[Reference]Ref), nil
+}
+
+// parsePackedRefs parses the packed-refs file. Lines starting with ^
+// contain the object an annotated tag on the previous line peels to.
+func parsePackedRefs(data []byte) (map[Reference]Ref, error) {
+	refs := map[Reference]Ref{}
+
+	var last Reference
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "^"):
+			if ref, ok := refs[last]; ok {
+				ref.Peeled = line[1:]
+				refs[last] = ref
+			}
+		default:
+			sha, name, ok := strings.Cut(line, " ")
+			if !ok {
+				return nil, errors.New("malformed packed-refs line: " + line)
+			}
+			last = Reference(name)
+			refs[last] = Ref{Name: last, SHA: sha}
+		}
+	}
+	return refs, scanner.Err()
+}
@@ -0,0 +1,115 @@
+package references
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Update points ref to sha. The update can be vetoed by the ref update
+// callbacks and the reference-transaction hook. Symbolic refs are not
+// followed, so updating HEAD detaches it. Invalid ref names are rejected.
+func Update(repo *repository.Repository, ref Reference, sha string) error {
+	if err := CheckName(ref.String(), CheckOptions{AllowOneLevel: true}); err != nil {
+		return err
+	}
+	old, err := ref.Resolve(repo)
+	if err != nil {
+		return err
+	}
+	if err := repo.RunRefUpdate(ref.String(), old, sha); err != nil {
+		return err
+	}
+
+	path := repo.RepositoryPath(ref.String())
+	if _, err := repo.RepositoryDir(true, filepath.Dir(ref.String())); err != nil {
+		return err
+	}
+	mode, err := repo.SharedMode(fs.DefaultPerm)
+	if err != nil {
+		return err
+	}
+	if err := fs.AtomicWrite(path, []byte(sha+"\n"), fs.WithExactPerm(mode)); err != nil {
+		return err
+	}
+
+	// Like git, we ignore the exit status of the hook once the ref is updated
+	repo.RunRefUpdated(ref.String(), old, sha)
+	return nil
+}
+
+// Delete removes a ref, loose or packed, with the same hooks as Update
+func Delete(repo *repository.Repository, ref Reference) error {
+	old, err := ref.Resolve(repo)
+	if err != nil {
+		return err
+	}
+	if err := repo.RunRefUpdate(ref.String(), old, ""); err != nil {
+		return err
+	}
+
+	err = os.Remove(repo.RepositoryPath(ref.String()))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	removed := err == nil
+	wasPacked, err := removePacked(repo, ref)
+	if err != nil {
+		return err
+	}
+	if removed || wasPacked {
+		repo.RunRefUpdated(ref.String(), old, "")
+	}
+	return nil
+}
+
+// removePacked removes ref from packed-refs, with the object it peels to,
+// and reports whether it was there
+func removePacked(repo *repository.Repository, ref Reference) (bool, error) {
+	path := repo.RepositoryPath("packed-refs")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	kept := []string{}
+	removed, peeled := false, false
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if peeled && strings.HasPrefix(line, "^") {
+			continue
+		}
+		peeled = false
+		if _, name, ok := strings.Cut(strings.TrimSpace(line), " "); ok && !strings.HasPrefix(line, "#") && name == ref.String() {
+			removed, peeled = true, true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !removed {
+		return false, nil
+	}
+	mode, err := repo.SharedMode(fs.DefaultPerm)
+	if err != nil {
+		return false, err
+	}
+	return true, fs.AtomicWrite(path, []byte(strings.Join(kept, "")), fs.WithExactPerm(mode))
+}
+
+// UpdateSymbolic makes ref, like HEAD, a symbolic ref pointing to target
+func UpdateSymbolic(repo *repository.Repository, ref, target Reference) error {
+	if err := CheckName(target.String(), CheckOptions{}); err != nil {
+		return err
+	}
+	mode, err := repo.SharedMode(fs.DefaultPerm)
+	if err != nil {
+		return err
+	}
+	return fs.AtomicWrite(repo.RepositoryPath(ref.String()), []byte("ref: "+target.String()+"\n"), fs.WithExactPerm(mode))
+}
@@ -0,0 +1,44 @@
+package references
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func TestDelete(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := strings.Repeat("a", 40), strings.Repeat("b", 40)
+	packed := "# pack-refs with: peeled fully-peeled sorted \n" +
+		a + " refs/remotes/origin/main\n" +
+		a + " refs/tags/v1\n^" + b + "\n" +
+		b + " refs/tags/v2\n"
+	if err := os.WriteFile(repo.RepositoryPath("packed-refs"), []byte(packed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(repo, "refs/remotes/origin/main", b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []Reference{"refs/remotes/origin/main", "refs/tags/v1", "refs/heads/missing"} {
+		if err := Delete(repo, ref); err != nil {
+			t.Fatalf("Delete(%s) error: %v", ref, err)
+		}
+	}
+	refs, err := NewRefStore(repo).List("refs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].Name != "refs/tags/v2" {
+		t.Errorf("refs after Delete() = %+v, want only refs/tags/v2", refs)
+	}
+	data, _ := os.ReadFile(repo.RepositoryPath("packed-refs"))
+	if want := "# pack-refs with: peeled fully-peeled sorted \n" + b + " refs/tags/v2\n"; string(data) != want {
+		t.Errorf("packed-refs = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,136 @@
+package references
+
+import "strings"
+
+// States of CompareVersions, which are offsets in the tables below
+const (
+	// Comparing other characters
+	versionNormal = 0
+	// Comparing the integral part of a number
+	versionIntegral = 3
+	// Comparing the fractional part of a number, which starts with a zero
+	versionFractional = 6
+	// Like versionFractional, but only zeroes were seen yet
+	versionZeroes = 9
+)
+
+// How the result is decided, if it isn't given directly
+const (
+	versionByDiff   = 2
+	versionByLength = 3
+)
+
+// versionNextState is indexed by state + the kind of the next character:
+// 0 for other characters, 1 for digits other than 0 and 2 for 0
+var versionNextState = [12]int{
+	versionNormal, versionIntegral, versionZeroes,
+	versionNormal, versionIntegral, versionIntegral,
+	versionNormal, versionFractional, versionFractional,
+	versionNormal, versionFractional, versionZeroes,
+}
+
+// versionResult is indexed by state * 3 + the kind of the character
+// of b where a and b first differ
+var versionResult = [36]int{
+	versionByDiff, versionByDiff, versionByDiff, versionByDiff, versionByLength, versionByDiff, versionByDiff, versionByDiff, versionByDiff,
+	versionByDiff, -1, -1, +1, versionByLength, versionByLength, +1, versionByLength, versionByLength,
+	versionByDiff, versionByDiff, versionByDiff, versionByDiff, versionByDiff, versionByDiff, versionByDiff, versionByDiff, versionByDiff,
+	versionByDiff, +1, +1, -1, versionByDiff, versionByDiff, -1, versionByDiff, versionByDiff,
+}
+
+// CompareVersions compares two names that contain version numbers, like
+// git's version sort: numbers are compared by value, so v1.10 comes after
+// v1.9. A number that starts with 0 is fractional, so v1.05 comes before
+// v1.1. Names with one of the prerelease suffixes, like "-rc", come
+// before the same version without it, in the order of suffixes, like
+// git's versionsort.suffix. It returns a negative number if a is the
+// lower version, a positive one if b is, and 0 if they are equal.
+func CompareVersions(a, b string, suffixes []string) int {
+	if a == b {
+		return 0
+	}
+	// at returns the character at i, with a NUL after the end like in C
+	at := func(s string, i int) int {
+		if i < len(s) {
+			return int(s[i])
+		}
+		return 0
+	}
+	kind := func(c int) int {
+		switch {
+		case c == '0':
+			return 2
+		case '1' <= c && c <= '9':
+			return 1
+		}
+		return 0
+	}
+
+	i := 0
+	c1, c2 := at(a, i), at(b, i)
+	state := versionNormal + kind(c1)
+	diff := c1 - c2
+	for diff == 0 {
+		if c1 == 0 {
+			return 0
+		}
+		state = versionNextState[state]
+		i++
+		c1, c2 = at(a, i), at(b, i)
+		state += kind(c1)
+		diff = c1 - c2
+	}
+
+	if len(suffixes) > 0 {
+		if diff, ok := comparePrereleases(a, b, i, suffixes); ok {
+			return diff
+		}
+	}
+
+	switch result := versionResult[state*3+kind(c2)]; result {
+	case versionByDiff:
+		return diff
+	case versionByLength:
+		// The number with more digits is the larger one
+		j := i + 1
+		for ; kind(at(a, j)) != 0; j++ {
+			if kind(at(b, j)) == 0 {
+				return 1
+			}
+		}
+		if kind(at(b, j)) != 0 {
+			return -1
+		}
+		return diff
+	default:
+		return result
+	}
+}
+
+// comparePrereleases looks for the suffixes around position off, where a
+// and b start to differ. If only one of them has a suffix, it comes first,
+// and if they have different suffixes, the order of the suffixes decides.
+func comparePrereleases(a, b string, off int, suffixes []string) (int, bool) {
+	i1, i2 := -1, -1
+	for i, suffix := range suffixes {
+		for start := max(off-len(suffix), 0); start <= off; start++ {
+			if i1 == -1 && start <= len(a) && strings.HasPrefix(a[start:], suffix) {
+				i1 = i
+			}
+			if i2 == -1 && start <= len(b) && strings.HasPrefix(b[start:], suffix) {
+				i2 = i
+			}
+		}
+	}
+	switch {
+	case i1 == i2:
+		// Both have no suffix, or the same one, like in v1.0-rc1 and
+		// v1.0-rc2, so what comes after it decides
+		return 0, false
+	case i1 >= 0 && i2 >= 0:
+		return i1 - i2, true
+	case i1 >= 0:
+		return -1, true
+	}
+	return 1, true
+}
@@ -0,0 +1,41 @@
+package references
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		suffixes []string
+		want     int
+	}{
+		{"v1.9", "v1.10", nil, -1},
+		{"v1.10", "v1.9", nil, 1},
+		{"v1.2.3", "v1.2.3", nil, 0},
+		{"v2.0", "v10.0", nil, -1},
+		{"v1.05", "v1.1", nil, -1},
+		{"v1.01", "v1.010", nil, -1},
+		{"a", "b", nil, -1},
+		{"v1.0", "v1.0.1", nil, -1},
+		{"v1.0-rc1", "v1.0", nil, 1},
+		{"v1.0-rc1", "v1.0", []string{"-rc"}, -1},
+		{"v1.0-rc1", "v1.0-rc2", []string{"-rc"}, -1},
+		{"v1.0-beta", "v1.0-rc1", []string{"-beta", "-rc"}, -1},
+		{"v1.0-rc1", "v1.0-beta", []string{"-beta", "-rc"}, 1},
+	}
+	for _, tt := range tests {
+		got := CompareVersions(tt.a, tt.b, tt.suffixes)
+		if (got < 0) != (tt.want < 0) || (got > 0) != (tt.want > 0) {
+			t.Errorf("CompareVersions(%q, %q, %q) = %d, want %d", tt.a, tt.b, tt.suffixes, got, tt.want)
+		}
+	}
+
+	tags := []string{"v1.10", "v1.0-rc1", "v1.9", "v1.0", "v1.2.10", "v1.2.9", "v1.0-rc2"}
+	slices.SortFunc(tags, func(a, b string) int { return CompareVersions(a, b, []string{"-rc"}) })
+	want := []string{"v1.0-rc1", "v1.0-rc2", "v1.0", "v1.2.9", "v1.2.10", "v1.9", "v1.10"}
+	if !slices.Equal(tags, want) {
+		t.Errorf("sorted versions = %v, want %v", tags, want)
+	}
+}
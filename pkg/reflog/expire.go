@@ -0,0 +1,164 @@
+package reflog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/gitdate"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/wildmatch"
+)
+
+// The expiry times git uses when nothing is configured
+const (
+	defaultExpire            = "90.days.ago"
+	defaultExpireUnreachable = "30.days.ago"
+)
+
+// ExpireOptions change which entries Expire removes
+type ExpireOptions struct {
+	// Expire is when entries expire: older entries are removed
+	Expire time.Time
+	// ExpireUnreachable is when entries whose commit can't be reached from
+	// the tip of the ref expire, e.g. commits that were amended or rebased
+	ExpireUnreachable time.Time
+	// DryRun only reports what would be removed
+	DryRun bool
+}
+
+// LoadExpireOptions returns the expiry times of the log of ref from
+// gc.reflogExpire and gc.reflogExpireUnreachable. Like git, settings in
+// gc "<pattern>" sections apply to the refs the pattern matches, e.g.
+// gc "refs/remotes/*".reflogExpire, and win over the plain ones.
+func LoadExpireOptions(cfg config.GitConfig, ref string, now time.Time) (ExpireOptions, error) {
+	expire, expireUnreachable := defaultExpire, defaultExpireUnreachable
+	if value, ok := cfg.Get("gc", "reflogExpire"); ok {
+		expire = value
+	}
+	if value, ok := cfg.Get("gc", "reflogExpireUnreachable"); ok {
+		expireUnreachable = value
+	}
+	for _, section := range cfg.Sections() {
+		pattern, ok := gcPattern(section)
+		if !ok || !wildmatch.MatchText(pattern, ref) {
+			continue
+		}
+		if value, ok := cfg.Get(section, "reflogExpire"); ok {
+			expire = value
+		}
+		if value, ok := cfg.Get(section, "reflogExpireUnreachable"); ok {
+			expireUnreachable = value
+		}
+	}
+
+	var opts ExpireOptions
+	var err error
+	if opts.Expire, err = gitdate.Expiry(expire, now); err != nil {
+		return ExpireOptions{}, fmt.Errorf("gc.reflogExpire: %w", err)
+	}
+	if opts.ExpireUnreachable, err = gitdate.Expiry(expireUnreachable, now); err != nil {
+		return ExpireOptions{}, fmt.Errorf("gc.reflogExpireUnreachable: %w", err)
+	}
+	return opts, nil
+}
+
+// gcPattern returns the pattern of a section like `gc "refs/stash"`
+func gcPattern(section string) (string, bool) {
+	name, pattern, ok := strings.Cut(section, " ")
+	if !ok || !strings.EqualFold(name, "gc") {
+		return "", false
+	}
+	return strings.Trim(pattern, `"`), true
+}
+
+// Expire removes the entries of the log of ref that expired, like git
+// reflog expire, and returns them. Entries are unreachable when their
+// commit isn't the current tip of the ref or one of its ancestors.
+func Expire(repo *repository.Repository, ref string, opts ExpireOptions) ([]*Entry, error) {
+	entries, err := Read(repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finding the reachable commits walks the history of the ref, so
+	// it's only done when an entry needs it
+	var reachable map[string]bool
+	isReachable := func(sha string) (bool, error) {
+		if reachable == nil {
+			if reachable, err = reachableFromTip(repo, ref); err != nil {
+				return false, err
+			}
+		}
+		return reachable[sha], nil
+	}
+
+	kept, expired := []*Entry{}, []*Entry{}
+	for _, e := range entries {
+		remove := e.When.Before(opts.Expire)
+		if !remove && e.When.Before(opts.ExpireUnreachable) {
+			ok, err := isReachable(e.New)
+			if err != nil {
+				return nil, err
+			}
+			remove = !ok
+		}
+		if remove {
+			expired = append(expired, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+
+	if len(expired) > 0 && !opts.DryRun {
+		if err := Write(repo, ref, kept); err != nil {
+			return nil, err
+		}
+	}
+	return expired, nil
+}
+
+// reachableFromTip returns the commits that can be reached from the
+// current tip of ref. Without a tip, nothing is reachable. Commits that
+// are missing end the walk, since their history can't be read.
+func reachableFromTip(repo *repository.Repository, ref string) (map[string]bool, error) {
+	reachable := map[string]bool{}
+	tip, err := references.Reference(ref).Resolve(repo)
+	if err != nil {
+		return nil, err
+	}
+	if tip == "" {
+		return reachable, nil
+	}
+
+	queue := []string{tip}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if reachable[current] {
+			continue
+		}
+		reachable[current] = true
+
+		sha, err := hashing.NewShaFromHex(current)
+		if err != nil {
+			continue
+		}
+		obj, err := objects.ReadObject(repo, sha)
+		if err != nil {
+			continue
+		}
+		commit, ok := obj.(*objects.Commit)
+		if !ok {
+			continue
+		}
+		for _, parent := range commit.GetValues("parent") {
+			queue = append(queue, string(parent))
+		}
+	}
+	return reachable, nil
+}
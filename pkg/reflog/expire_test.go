@@ -0,0 +1,116 @@
+package reflog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/references"
+)
+
+func TestLoadExpireOptions(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config")
+	contents := "[gc]\nreflogExpire = 60.days.ago\n" +
+		"[gc \"refs/remotes/*\"]\nreflogExpire = never\nreflogExpireUnreachable = now\n"
+	if err := os.WriteFile(cfgPath, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.ReadWithRepository(cfgPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+
+	tests := []struct {
+		ref                   string
+		expire, unreachableAt time.Time
+	}{
+		{ref: "refs/heads/main", expire: days(60), unreachableAt: days(30)},
+		{ref: "refs/remotes/origin/main", expire: time.Unix(0, 0), unreachableAt: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			opts, err := LoadExpireOptions(cfg, tt.ref, now)
+			if err != nil {
+				t.Fatalf("LoadExpireOptions() error = %v", err)
+			}
+			if !opts.Expire.Equal(tt.expire) || !opts.ExpireUnreachable.Equal(tt.unreachableAt) {
+				t.Errorf("LoadExpireOptions() = %v, %v, want %v, %v", opts.Expire, opts.ExpireUnreachable, tt.expire, tt.unreachableAt)
+			}
+		})
+	}
+}
+
+func TestExpire(t *testing.T) {
+	repo := setupRepo(t)
+	tree := objects.EmptyTreeSHA()
+	ident := objects.Ident{Name: "A U Thor", Email: "author@example.com", When: time.Unix(1700000000, 0).UTC()}
+	commit := func(message string, parents ...*hashing.SHA) string {
+		sha, err := objects.CreateCommit(repo, tree, parents, ident, ident, message)
+		if err != nil {
+			t.Fatalf("CreateCommit() error = %v", err)
+		}
+		return sha.AsString()
+	}
+	first := commit("first")
+	firstSha, _ := hashing.NewShaFromHex(first)
+	amended := commit("second")
+	second := commit("second, amended", firstSha)
+	if err := references.Update(repo, "refs/heads/main", second); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	zero := strings.Repeat("0", 40)
+	entries := []*Entry{
+		{Old: zero, New: first, When: days(100), Ident: "A U Thor <author@example.com>", Zone: "+0000", Message: "old"},
+		{Old: first, New: amended, When: days(40), Ident: "A U Thor <author@example.com>", Zone: "+0000", Message: "unreachable"},
+		{Old: amended, New: second, When: days(40), Ident: "A U Thor <author@example.com>", Zone: "+0000", Message: "reachable"},
+		{Old: second, New: amended, When: days(10), Ident: "A U Thor <author@example.com>", Zone: "+0000", Message: "recent"},
+	}
+	write := func() {
+		if err := Write(repo, "refs/heads/main", entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	messages := func(entries []*Entry) string {
+		names := []string{}
+		for _, e := range entries {
+			names = append(names, e.Message)
+		}
+		return strings.Join(names, ",")
+	}
+	opts := ExpireOptions{Expire: days(90), ExpireUnreachable: days(30)}
+
+	write()
+	opts.DryRun = true
+	expired, err := Expire(repo, "refs/heads/main", opts)
+	if err != nil {
+		t.Fatalf("Expire() error = %v", err)
+	}
+	if got := messages(expired); got != "old,unreachable" {
+		t.Errorf("Expire() expired %s, want old,unreachable", got)
+	}
+	if kept, _ := Read(repo, "refs/heads/main"); len(kept) != len(entries) {
+		t.Errorf("Expire() with DryRun removed entries")
+	}
+
+	opts.DryRun = false
+	if _, err := Expire(repo, "refs/heads/main", opts); err != nil {
+		t.Fatalf("Expire() error = %v", err)
+	}
+	kept, err := Read(repo, "refs/heads/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(kept); got != "reachable,recent" {
+		t.Errorf("Expire() kept %s, want reachable,recent", got)
+	}
+}
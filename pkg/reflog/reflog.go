@@ -0,0 +1,136 @@
+// Package reflog reads, writes and expires ref logs, which git keeps in
+// logs/<ref> in the gitdir. Every line of a ref log is an update of the
+// ref: "<old sha> <new sha> <name> <<email>> <timestamp> <zone>\t<message>".
+package reflog
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gotfs "github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Entry is a single update of a ref
+type Entry struct {
+	Old string
+	New string
+	// Ident is the name and email of who updated the ref, e.g.
+	// "A U Thor <author@example.com>"
+	Ident string
+	When  time.Time
+	// Zone is the timezone as written in the log, e.g. +0100
+	Zone    string
+	Message string
+}
+
+func (e *Entry) String() string {
+	return fmt.Sprintf("%s %s %s %d %s\t%s", e.Old, e.New, e.Ident, e.When.Unix(), e.Zone, e.Message)
+}
+
+// parseEntry parses a line of a ref log
+func parseEntry(line string) (*Entry, error) {
+	header, message, _ := strings.Cut(line, "\t")
+	fields := strings.SplitN(header, " ", 3)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("malformed reflog entry: %s", line)
+	}
+	ident := fields[2]
+	end := strings.LastIndexByte(ident, '>')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed reflog entry: %s", line)
+	}
+	timestamp, zone, _ := strings.Cut(strings.TrimSpace(ident[end+1:]), " ")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed reflog entry: %s", line)
+	}
+	return &Entry{
+		Old:     fields[0],
+		New:     fields[1],
+		Ident:   ident[:end+1],
+		When:    time.Unix(unix, 0),
+		Zone:    zone,
+		Message: message,
+	}, nil
+}
+
+// Path returns the path of the log of ref, e.g. HEAD or refs/heads/main
+func Path(repo *repository.Repository, ref string) string {
+	return repo.RepositoryPath(append([]string{"logs"}, strings.Split(ref, "/")...)...)
+}
+
+// Read returns the entries of the log of ref, oldest first. A ref without
+// a log has no entries. Lines that don't parse are skipped, like git does.
+func Read(repo *repository.Repository, ref string) ([]*Entry, error) {
+	data, err := os.ReadFile(Path(repo, ref))
+	if errors.Is(err, os.ErrNotExist) {
+		return []*Entry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entries := []*Entry{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if entry, err := parseEntry(line); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Write replaces the log of ref with entries
+func Write(repo *repository.Repository, ref string, entries []*Entry) error {
+	var log strings.Builder
+	for _, e := range entries {
+		log.WriteString(e.String())
+		log.WriteByte('\n')
+	}
+
+	path := Path(repo, ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return gotfs.AtomicWrite(path, []byte(log.String()))
+}
+
+// Refs returns the refs that have a log, sorted by name. In a linked
+// worktree, HEAD is the HEAD of that worktree.
+func Refs(repo *repository.Repository) ([]string, error) {
+	root := repo.RepositoryPath("logs")
+	refs := []string{}
+	if gotfs.Exists(Path(repo, "HEAD")) {
+		refs = append(refs, "HEAD")
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "HEAD" {
+			return nil
+		}
+		refs = append(refs, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(refs)
+	return refs, nil
+}
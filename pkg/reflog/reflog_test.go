@@ -0,0 +1,84 @@
+package reflog
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func setupRepo(t *testing.T) *repository.Repository {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	return repo
+}
+
+func TestReadWrite(t *testing.T) {
+	repo := setupRepo(t)
+	zero := strings.Repeat("0", 40)
+	first := strings.Repeat("1", 40)
+	log := zero + " " + first + " A U Thor <author@example.com> 1700000000 +0100\tcommit (initial): first\n" +
+		"not a reflog entry\n" +
+		first + " " + first + " A U Thor <author@example.com> 1700000100 -0500\tcheckout: moving from main to topic\n"
+	if err := os.MkdirAll(repo.RepositoryPath("logs", "refs", "heads"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(Path(repo, "refs/heads/main"), []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(repo, "refs/heads/main")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []*Entry{
+		{Old: zero, New: first, Ident: "A U Thor <author@example.com>", When: time.Unix(1700000000, 0), Zone: "+0100", Message: "commit (initial): first"},
+		{Old: first, New: first, Ident: "A U Thor <author@example.com>", When: time.Unix(1700000100, 0), Zone: "-0500", Message: "checkout: moving from main to topic"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Read() = %v, want %v", entries, want)
+	}
+
+	if err := Write(repo, "refs/heads/main", entries); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	data, err := os.ReadFile(Path(repo, "refs/heads/main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The line that doesn't parse is dropped
+	wantLog := strings.Replace(log, "not a reflog entry\n", "", 1)
+	if string(data) != wantLog {
+		t.Errorf("Write() wrote %q, want %q", data, wantLog)
+	}
+}
+
+func TestReadMissing(t *testing.T) {
+	repo := setupRepo(t)
+	entries, err := Read(repo, "refs/heads/main")
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Read() = %v, %v, want no entries", entries, err)
+	}
+}
+
+func TestRefs(t *testing.T) {
+	repo := setupRepo(t)
+	for _, ref := range []string{"refs/heads/main", "HEAD", "refs/stash", "refs/remotes/origin/main"} {
+		if err := Write(repo, ref, []*Entry{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refs, err := Refs(repo)
+	if err != nil {
+		t.Fatalf("Refs() error = %v", err)
+	}
+	want := []string{"HEAD", "refs/heads/main", "refs/remotes/origin/main", "refs/stash"}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("Refs() = %v, want %v", refs, want)
+	}
+}
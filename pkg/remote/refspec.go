@@ -0,0 +1,121 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+// Refspec maps the refs of a remote to local refs, like
+// +refs/heads/*:refs/remotes/origin/*. Src and Dst may have one "*",
+// which matches any part of a name. A refspec without Dst only fetches.
+type Refspec struct {
+	// Force allows updates that aren't fast-forwards
+	Force bool
+	// Negative refspecs, which start with "^", leave out the refs that
+	// Src matches
+	Negative bool
+	Src      string
+	Dst      string
+}
+
+// ParseRefspec parses a refspec of a fetch
+func ParseRefspec(spec string) (Refspec, error) {
+	r := Refspec{}
+	rest := spec
+	if strings.HasPrefix(rest, "^") {
+		r.Negative = true
+		rest = rest[1:]
+	} else if strings.HasPrefix(rest, "+") {
+		r.Force = true
+		rest = rest[1:]
+	}
+	r.Src, r.Dst, _ = strings.Cut(rest, ":")
+	if r.Negative && r.Dst != "" {
+		return Refspec{}, fmt.Errorf("invalid refspec '%s': negative refspecs have no destination", spec)
+	}
+	srcStars, dstStars := strings.Count(r.Src, "*"), strings.Count(r.Dst, "*")
+	if srcStars > 1 || dstStars > 1 || (r.Dst != "" && srcStars != dstStars) {
+		return Refspec{}, fmt.Errorf("invalid refspec '%s'", spec)
+	}
+	if r.Src == "" {
+		return Refspec{}, fmt.Errorf("invalid refspec '%s'", spec)
+	}
+	return r, nil
+}
+
+// String returns the refspec as it is written
+func (r Refspec) String() string {
+	s := r.Src
+	if r.Dst != "" {
+		s += ":" + r.Dst
+	}
+	if r.Force {
+		s = "+" + s
+	} else if r.Negative {
+		s = "^" + s
+	}
+	return s
+}
+
+// IsPattern reports whether the refspec has a "*"
+func (r Refspec) IsPattern() bool {
+	return strings.Contains(r.Src, "*")
+}
+
+// Match reports whether Src matches the remote ref name, and returns the
+// local ref it maps to, which is "" without Dst
+func (r Refspec) Match(name string) (string, bool) {
+	if !r.IsPattern() {
+		return r.Dst, name == r.Src
+	}
+	prefix, suffix, _ := strings.Cut(r.Src, "*")
+	if len(name) < len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	if r.Dst == "" {
+		return "", true
+	}
+	return strings.Replace(r.Dst, "*", name[len(prefix):len(name)-len(suffix)], 1), true
+}
+
+// FetchRefspecs returns the remote.<name>.fetch refspecs of a remote
+func FetchRefspecs(cfg config.GitConfig, remote string) ([]Refspec, error) {
+	refspecs := []Refspec{}
+	for _, spec := range cfg.GetAll(section(remote), "fetch") {
+		r, err := ParseRefspec(spec)
+		if err != nil {
+			return nil, err
+		}
+		refspecs = append(refspecs, r)
+	}
+	return refspecs, nil
+}
+
+// MapRef returns the local ref that refspecs map a remote ref to, and
+// whether a refspec matches it. Negative refspecs take precedence.
+func MapRef(refspecs []Refspec, name string) (Refspec, string, bool) {
+	if Excluded(refspecs, name) {
+		return Refspec{}, "", false
+	}
+	for _, r := range refspecs {
+		if r.Negative {
+			continue
+		}
+		if dst, ok := r.Match(name); ok {
+			return r, dst, true
+		}
+	}
+	return Refspec{}, "", false
+}
+
+// Excluded reports whether a negative refspec matches a remote ref name
+func Excluded(refspecs []Refspec, name string) bool {
+	for _, r := range refspecs {
+		if _, ok := r.Match(name); ok && r.Negative {
+			return true
+		}
+	}
+	return false
+}
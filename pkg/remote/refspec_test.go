@@ -0,0 +1,81 @@
+package remote
+
+import (
+	"testing"
+)
+
+func TestParseRefspec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Refspec
+		wantErr bool
+	}{
+		{spec: "+refs/heads/*:refs/remotes/origin/*", want: Refspec{Force: true, Src: "refs/heads/*", Dst: "refs/remotes/origin/*"}},
+		{spec: "master", want: Refspec{Src: "master"}},
+		{spec: "refs/heads/main:refs/heads/copy", want: Refspec{Src: "refs/heads/main", Dst: "refs/heads/copy"}},
+		{spec: "^refs/heads/wip/*", want: Refspec{Negative: true, Src: "refs/heads/wip/*"}},
+		{spec: "refs/heads/*:refs/remotes/origin/main", wantErr: true},
+		{spec: "refs/*/*:refs/x/*", wantErr: true},
+		{spec: "^refs/heads/a:refs/heads/b", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRefspec(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRefspec(%q) = %+v, want an error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRefspec(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+		if got.String() != tt.spec {
+			t.Errorf("String() = %q, want %q", got.String(), tt.spec)
+		}
+	}
+}
+
+func TestMapRef(t *testing.T) {
+	refspecs := []Refspec{}
+	for _, spec := range []string{"+refs/heads/*:refs/remotes/origin/*", "^refs/heads/wip/*", "refs/tags/v*-rc:refs/rc/*", "refs/notes/commits"} {
+		r, err := ParseRefspec(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refspecs = append(refspecs, r)
+	}
+	tests := []struct {
+		name   string
+		dst    string
+		ok     bool
+		forced bool
+	}{
+		{"refs/heads/main", "refs/remotes/origin/main", true, true},
+		{"refs/heads/feature/x", "refs/remotes/origin/feature/x", true, true},
+		{"refs/heads/wip/x", "", false, false},
+		{"refs/tags/v1.0-rc", "refs/rc/1.0", true, false},
+		{"refs/tags/v1.0", "", false, false},
+		{"refs/notes/commits", "", true, false},
+	}
+	for _, tt := range tests {
+		r, dst, ok := MapRef(refspecs, tt.name)
+		if dst != tt.dst || ok != tt.ok || r.Force != tt.forced {
+			t.Errorf("MapRef(%q) = %q, %v (force %v), want %q, %v (force %v)", tt.name, dst, ok, r.Force, tt.dst, tt.ok, tt.forced)
+		}
+	}
+}
+
+func TestFetchRefspecs(t *testing.T) {
+	cfg := readConfig(t, "[remote \"origin\"]\n\turl = /a\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n\tfetch = refs/tags/*:refs/tags/*\n")
+	refspecs, err := FetchRefspecs(cfg, "origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refspecs) != 2 || refspecs[1].Src != "refs/tags/*" || !refspecs[0].Force {
+		t.Errorf("FetchRefspecs() = %+v", refspecs)
+	}
+	if refspecs, _ := FetchRefspecs(cfg, "other"); len(refspecs) != 0 {
+		t.Errorf("FetchRefspecs() of an unknown remote = %+v, want none", refspecs)
+	}
+}
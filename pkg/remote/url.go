@@ -0,0 +1,135 @@
+// Package remote resolves the remotes of a repository: their URLs, as
+// configured in remote.<name> sections and rewritten by url.<base>
+// sections.
+package remote
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+// rewrite replaces prefix at the start of a URL by base
+type rewrite struct {
+	base   string
+	prefix string
+}
+
+// Rewrites are the url.<base>.insteadOf and url.<base>.pushInsteadOf
+// rules. A URL that starts with the value of one is rewritten to start
+// with the base instead, and when several match, the longest one wins.
+// This is commonly used to swap https for ssh without touching remotes.
+type Rewrites struct {
+	fetch []rewrite
+	push  []rewrite
+}
+
+// LoadRewrites reads the rewrite rules of the configuration
+func LoadRewrites(cfg config.GitConfig) Rewrites {
+	rewrites := Rewrites{}
+	for _, section := range cfg.Sections() {
+		name, base, ok := strings.Cut(section, " ")
+		if !ok || !strings.EqualFold(name, "url") {
+			continue
+		}
+		base = strings.Trim(base, `"`)
+		for _, prefix := range cfg.GetAll(section, "insteadOf") {
+			rewrites.fetch = append(rewrites.fetch, rewrite{base: base, prefix: prefix})
+		}
+		for _, prefix := range cfg.GetAll(section, "pushInsteadOf") {
+			rewrites.push = append(rewrites.push, rewrite{base: base, prefix: prefix})
+		}
+	}
+	return rewrites
+}
+
+// URL rewrites a URL to fetch from with the insteadOf rules
+func (r Rewrites) URL(url string) string {
+	rewritten, _ := apply(r.fetch, url)
+	return rewritten
+}
+
+// PushURL rewrites a URL to push to with the pushInsteadOf rules. ok is
+// false when none of them matches.
+func (r Rewrites) PushURL(url string) (string, bool) {
+	return apply(r.push, url)
+}
+
+// apply applies the rule with the longest prefix that matches the URL
+func apply(rules []rewrite, url string) (string, bool) {
+	best := -1
+	for i, rule := range rules {
+		if strings.HasPrefix(url, rule.prefix) && (best < 0 || len(rule.prefix) > len(rules[best].prefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return url, false
+	}
+	return rules[best].base + strings.TrimPrefix(url, rules[best].prefix), true
+}
+
+// FetchURLs returns the URLs to fetch from for a remote, which is either
+// the name of a remote.<name> section or a URL. The rewrite rules are
+// applied to them.
+func FetchURLs(cfg config.GitConfig, remote string) []string {
+	rewrites := LoadRewrites(cfg)
+	urls := []string{}
+	for _, url := range configuredURLs(cfg, remote) {
+		urls = append(urls, rewrites.URL(url))
+	}
+	return urls
+}
+
+// PushURLs returns the URLs to push to for a remote, like git does:
+//   - the remote.<name>.pushurl values, rewritten by insteadOf,
+//   - or else the URLs that a pushInsteadOf rule matches, rewritten by it,
+//   - or else the URLs to fetch from.
+func PushURLs(cfg config.GitConfig, remote string) []string {
+	rewrites := LoadRewrites(cfg)
+	urls := []string{}
+	if pushURLs := cfg.GetAll(section(remote), "pushurl"); len(pushURLs) > 0 {
+		for _, url := range pushURLs {
+			urls = append(urls, rewrites.URL(url))
+		}
+		return urls
+	}
+	for _, url := range configuredURLs(cfg, remote) {
+		if rewritten, ok := rewrites.PushURL(url); ok {
+			urls = append(urls, rewritten)
+		}
+	}
+	if len(urls) > 0 {
+		return urls
+	}
+	return FetchURLs(cfg, remote)
+}
+
+// configuredURLs returns the remote.<name>.url values of a remote, or the
+// remote itself when it isn't configured, since it is a URL then
+func configuredURLs(cfg config.GitConfig, remote string) []string {
+	if urls := cfg.GetAll(section(remote), "url"); len(urls) > 0 {
+		return urls
+	}
+	return []string{remote}
+}
+
+// Names returns the names of the configured remotes, in the order of the
+// configuration
+func Names(cfg config.GitConfig) []string {
+	names := []string{}
+	for _, sec := range cfg.Sections() {
+		if name, ok := strings.CutPrefix(sec, `remote "`); ok && strings.HasSuffix(name, `"`) {
+			name = strings.TrimSuffix(name, `"`)
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func section(remote string) string {
+	return `remote "` + remote + `"`
+}
@@ -0,0 +1,94 @@
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+func readConfig(t *testing.T, data string) config.GitConfig {
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.ReadWithRepository(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+const rewriteConfig = `[url "git@github.com:"]
+	insteadOf = https://github.com/
+	insteadOf = gh:
+[url "git@github.com:corp/"]
+	insteadOf = https://github.com/corp/
+[url "ssh://push.example.com/"]
+	pushInsteadOf = https://example.com/
+[remote "origin"]
+	url = https://github.com/corp/app.git
+[remote "mirror"]
+	url = https://example.com/app.git
+	url = gh:me/app.git
+[remote "split"]
+	url = https://example.com/app.git
+	pushurl = gh:me/app.git
+`
+
+func TestRewrites(t *testing.T) {
+	rewrites := LoadRewrites(readConfig(t, rewriteConfig))
+	tests := []struct {
+		url      string
+		want     string
+		wantPush string
+	}{
+		{"https://github.com/me/app.git", "git@github.com:me/app.git", ""},
+		{"https://github.com/corp/app.git", "git@github.com:corp/app.git", ""},
+		{"gh:me/app.git", "git@github.com:me/app.git", ""},
+		{"https://example.com/app.git", "https://example.com/app.git", "ssh://push.example.com/app.git"},
+		{"/local/path", "/local/path", ""},
+	}
+	for _, tt := range tests {
+		if got := rewrites.URL(tt.url); got != tt.want {
+			t.Errorf("URL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+		if got, ok := rewrites.PushURL(tt.url); ok != (tt.wantPush != "") || (ok && got != tt.wantPush) {
+			t.Errorf("PushURL(%q) = %q, %v, want %q", tt.url, got, ok, tt.wantPush)
+		}
+	}
+}
+
+func TestURLs(t *testing.T) {
+	cfg := readConfig(t, rewriteConfig)
+	tests := []struct {
+		remote   string
+		want     []string
+		wantPush []string
+	}{
+		{"origin", []string{"git@github.com:corp/app.git"}, []string{"git@github.com:corp/app.git"}},
+		// Only the URLs pushInsteadOf rewrites are pushed to
+		{"mirror", []string{"https://example.com/app.git", "git@github.com:me/app.git"}, []string{"ssh://push.example.com/app.git"}},
+		// pushurl isn't rewritten by pushInsteadOf
+		{"split", []string{"https://example.com/app.git"}, []string{"git@github.com:me/app.git"}},
+		{"https://github.com/x/y", []string{"git@github.com:x/y"}, []string{"git@github.com:x/y"}},
+	}
+	for _, tt := range tests {
+		if got := FetchURLs(cfg, tt.remote); !slices.Equal(got, tt.want) {
+			t.Errorf("FetchURLs(%q) = %q, want %q", tt.remote, got, tt.want)
+		}
+		if got := PushURLs(cfg, tt.remote); !slices.Equal(got, tt.wantPush) {
+			t.Errorf("PushURLs(%q) = %q, want %q", tt.remote, got, tt.wantPush)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	cfg := readConfig(t, rewriteConfig)
+	if got, want := Names(cfg), []string{"origin", "mirror", "split"}; !slices.Equal(got, want) {
+		t.Errorf("Names() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+// fileCache keeps parsed versions of files in the gitdir, and what was
+// built from the listings of directories
+type fileCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	dirs    map[string]*dirEntry
+}
+
+type dirEntry struct {
+	modTimes []time.Time
+	value    any
+}
+
+// racyDuration is how long after a directory changed its listing is
+// trusted. Timestamps are coarse, so a directory that changes again right
+// after it was read may keep the same mtime.
+const racyDuration = time.Second
+
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+	value   any
+}
+
+// CachedFile returns the result of parse for the file at path. The result is
+// reused until the file changes on disk or Invalidate is called. parse gets
+// nil data if the file does not exist. Callers must not modify the result.
+func (r *Repository) CachedFile(path string, parse func(data []byte) (any, error)) (any, error) {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+
+	info, err := os.Stat(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if entry, ok := r.cache.entries[path]; ok && entry.exists == exists {
+		if !exists || (entry.modTime.Equal(info.ModTime()) && entry.size == info.Size()) {
+			return entry.value, nil
+		}
+	}
+
+	var data []byte
+	if exists {
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	value, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &cacheEntry{exists: exists, value: value}
+	if exists {
+		entry.modTime = info.ModTime()
+		entry.size = info.Size()
+	}
+	r.cache.entries[path] = entry
+	return value, nil
+}
+
+// Invalidate drops the cached version of a file, for when it was
+// changed in a way that may not be visible in its size or mtime
+func (r *Repository) Invalidate(path string) {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+	delete(r.cache.entries, path)
+}
+
+// CachedDirs returns the result of build, which reads the directories at
+// paths. The result is reused until one of them changes, which adding or
+// removing a file in it does. Directories that don't exist count as
+// unchanged until they are created.
+func (r *Repository) CachedDirs(build func() (any, error), paths ...string) (any, error) {
+	now := time.Now()
+	key := strings.Join(paths, "\x00")
+	modTimes := make([]time.Time, len(paths))
+	trusted := true
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+		trusted = trusted && now.Sub(modTimes[i]) > racyDuration
+	}
+	r.cache.mu.Lock()
+	entry, ok := r.cache.dirs[key]
+	r.cache.mu.Unlock()
+	if ok && slices.EqualFunc(entry.modTimes, modTimes, time.Time.Equal) {
+		return entry.value, nil
+	}
+
+	// build may read cached files, so it runs without the lock
+	value, err := build()
+	if err != nil {
+		return nil, err
+	}
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+	if trusted {
+		r.cache.dirs[key] = &dirEntry{modTimes: modTimes, value: value}
+	} else {
+		delete(r.cache.dirs, key)
+	}
+	return value, nil
+}
+
+// Config returns the configuration of the repository, overlaid on the
+// global configuration. It is read once and reused until the repository
+// configuration file changes. With extensions.worktreeConfig, the
+// config.worktree of the worktree is overlaid on it.
+func (r *Repository) Config() (config.GitConfig, error) {
+	path := r.RepositoryPath("config")
+	value, err := r.CachedFile(path, func(data []byte) (any, error) {
+		return config.ReadWithRepository(path)
+	})
+	if err != nil {
+		return config.GitConfig{}, err
+	}
+	cfg := value.(config.GitConfig)
+	if enabled, _ := cfg.GetBool("extensions", "worktreeConfig"); !enabled {
+		return cfg, nil
+	}
+	return r.worktreeConfig(cfg)
+}
+
+// layeredConfig is a configuration with config.worktree, and the
+// configuration it was overlaid on
+type layeredConfig struct {
+	base   config.GitConfig
+	config config.GitConfig
+}
+
+// worktreeConfig returns cfg overlaid with config.worktree. The result is
+// cached for config.worktree, and reread when cfg was reread.
+func (r *Repository) worktreeConfig(cfg config.GitConfig) (config.GitConfig, error) {
+	path := r.RepositoryPath("config")
+	worktreePath := r.RepositoryPath("config.worktree")
+	parse := func(data []byte) (any, error) {
+		layered, err := config.ReadWithWorktree(path, worktreePath)
+		return layeredConfig{base: cfg, config: layered}, err
+	}
+	value, err := r.CachedFile(worktreePath, parse)
+	if err == nil && value.(layeredConfig).base != cfg {
+		r.Invalidate(worktreePath)
+		value, err = r.CachedFile(worktreePath, parse)
+	}
+	if err != nil {
+		return config.GitConfig{}, err
+	}
+	return value.(layeredConfig).config, nil
+}
+
+// Lock returns a lock shared by all users of this handle, e.g. "index"
+// to serialize updates of the index. It does not protect against other
+// processes, which is what lock files in the gitdir are for.
+func (r *Repository) Lock(name string) *sync.RWMutex {
+	r.locksMu.Lock()
+	defer r.locksMu.Unlock()
+	lock, ok := r.locks[name]
+	if !ok {
+		lock = &sync.RWMutex{}
+		r.locks[name] = lock
+	}
+	return lock
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachedFile(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	path := repo.RepositoryPath("cached")
+	parses := 0
+	parse := func(data []byte) (any, error) {
+		parses++
+		return string(data), nil
+	}
+
+	tests := []struct {
+		name       string
+		write      string
+		invalidate bool
+		want       string
+		wantParses int
+	}{
+		{name: "missing file", want: "", wantParses: 1},
+		{name: "created", write: "one", want: "one", wantParses: 2},
+		{name: "unchanged", want: "one", wantParses: 2},
+		{name: "changed size", write: "three", want: "three", wantParses: 3},
+		{name: "invalidated", invalidate: true, want: "three", wantParses: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.write != "" {
+				if err := os.WriteFile(path, []byte(tt.write), 0o644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+			}
+			if tt.invalidate {
+				repo.Invalidate(path)
+			}
+			value, err := repo.CachedFile(path, parse)
+			if err != nil {
+				t.Fatalf("CachedFile() error: %v", err)
+			}
+			if value.(string) != tt.want {
+				t.Errorf("CachedFile() = %q, want %q", value, tt.want)
+			}
+			if parses != tt.wantParses {
+				t.Errorf("parsed %d times, want %d", parses, tt.wantParses)
+			}
+		})
+	}
+}
+
+func TestCachedDirs(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	path := repo.RepositoryPath("listed")
+	builds := 0
+	build := func() (any, error) {
+		builds++
+		entries, _ := os.ReadDir(path)
+		return len(entries), nil
+	}
+	// Directories that changed just now aren't trusted yet
+	age := func() {
+		old := time.Now().Add(-time.Minute)
+		if err := os.Chtimes(path, old, old); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		add        string
+		age        bool
+		want       int
+		wantBuilds int
+	}{
+		{name: "missing directory", want: 0, wantBuilds: 1},
+		{name: "still missing", want: 0, wantBuilds: 1},
+		{name: "new file", add: "a", want: 1, wantBuilds: 2},
+		{name: "racy", want: 1, wantBuilds: 3},
+		{name: "unchanged", age: true, want: 1, wantBuilds: 4},
+		{name: "cached", want: 1, wantBuilds: 4},
+		{name: "another file", add: "b", want: 2, wantBuilds: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.add != "" {
+				if err := os.MkdirAll(path, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(path, tt.add), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.age {
+				age()
+			}
+			value, err := repo.CachedDirs(build, path)
+			if err != nil {
+				t.Fatalf("CachedDirs() error: %v", err)
+			}
+			if value.(int) != tt.want {
+				t.Errorf("CachedDirs() = %d, want %d", value, tt.want)
+			}
+			if builds != tt.wantBuilds {
+				t.Errorf("built %d times, want %d", builds, tt.wantBuilds)
+			}
+		})
+	}
+}
+
+func TestConcurrentConfig(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg, err := repo.Config()
+			if err != nil {
+				t.Errorf("Config() error: %v", err)
+				return
+			}
+			if got, _ := cfg.Get("core", "bare"); got != "false" {
+				t.Errorf("core.bare = %q, want false", got)
+			}
+			lock := repo.Lock("test")
+			lock.Lock()
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if repo.Lock("test") != repo.Lock("test") {
+		t.Errorf("Lock() should return the same lock for a name")
+	}
+}
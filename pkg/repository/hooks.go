@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PreCommitFunc is called before a commit is created.
+// Returning an error aborts the commit.
+type PreCommitFunc func(repo *Repository) error
+
+// PostCommitFunc is called after a commit has been recorded
+type PostCommitFunc func(repo *Repository, commit string)
+
+// CommitMsgFunc is called with the message of a commit before it is
+// created. Returning an error aborts the commit.
+type CommitMsgFunc func(repo *Repository, message string) error
+
+// RefUpdateFunc is called before a ref is changed from oldSHA to newSHA.
+// oldSHA is empty for new refs. Returning an error vetoes the update.
+type RefUpdateFunc func(repo *Repository, ref, oldSHA, newSHA string) error
+
+// hooks holds the callbacks that embedders registered on a Repository.
+// They run before the executable hooks in the hooks directory, except for
+// the commit-msg callbacks: they get the message after the commit-msg hook
+// had the chance to change it, and it was cleaned up.
+type hooks struct {
+	mu         sync.Mutex
+	preCommit  []PreCommitFunc
+	commitMsg  []CommitMsgFunc
+	postCommit []PostCommitFunc
+	refUpdate  []RefUpdateFunc
+}
+
+func (r *Repository) OnPreCommit(fn PreCommitFunc) {
+	r.hooks.mu.Lock()
+	defer r.hooks.mu.Unlock()
+	r.hooks.preCommit = append(r.hooks.preCommit, fn)
+}
+
+func (r *Repository) OnCommitMsg(fn CommitMsgFunc) {
+	r.hooks.mu.Lock()
+	defer r.hooks.mu.Unlock()
+	r.hooks.commitMsg = append(r.hooks.commitMsg, fn)
+}
+
+func (r *Repository) OnPostCommit(fn PostCommitFunc) {
+	r.hooks.mu.Lock()
+	defer r.hooks.mu.Unlock()
+	r.hooks.postCommit = append(r.hooks.postCommit, fn)
+}
+
+func (r *Repository) OnRefUpdate(fn RefUpdateFunc) {
+	r.hooks.mu.Lock()
+	defer r.hooks.mu.Unlock()
+	r.hooks.refUpdate = append(r.hooks.refUpdate, fn)
+}
+
+// RunPreCommit runs the pre-commit callbacks and the pre-commit hook.
+// The first failure aborts the commit.
+func (r *Repository) RunPreCommit() error {
+	r.hooks.mu.Lock()
+	callbacks := append([]PreCommitFunc{}, r.hooks.preCommit...)
+	r.hooks.mu.Unlock()
+
+	for _, fn := range callbacks {
+		if err := fn(r); err != nil {
+			return fmt.Errorf("pre-commit callback failed: %w", err)
+		}
+	}
+	return r.RunHook("pre-commit", "")
+}
+
+// RunCommitMsg runs the commit-msg callbacks on the message of the commit
+// that is about to be created. The first failure aborts the commit.
+func (r *Repository) RunCommitMsg(message string) error {
+	r.hooks.mu.Lock()
+	callbacks := append([]CommitMsgFunc{}, r.hooks.commitMsg...)
+	r.hooks.mu.Unlock()
+
+	for _, fn := range callbacks {
+		if err := fn(r, message); err != nil {
+			return fmt.Errorf("commit-msg callback failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// RunPostCommit runs the post-commit callbacks and the post-commit hook.
+// The commit has already been made, so failures are only reported.
+func (r *Repository) RunPostCommit(commit string) error {
+	r.hooks.mu.Lock()
+	callbacks := append([]PostCommitFunc{}, r.hooks.postCommit...)
+	r.hooks.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(r, commit)
+	}
+	return r.RunHook("post-commit", "")
+}
+
+// RunRefUpdate runs the ref update callbacks, followed by the
+// reference-transaction hook in the "prepared" state. Any failure
+// vetoes the update.
+func (r *Repository) RunRefUpdate(ref, oldSHA, newSHA string) error {
+	r.hooks.mu.Lock()
+	callbacks := append([]RefUpdateFunc{}, r.hooks.refUpdate...)
+	r.hooks.mu.Unlock()
+
+	for _, fn := range callbacks {
+		if err := fn(r, ref, oldSHA, newSHA); err != nil {
+			return fmt.Errorf("ref update of %s vetoed: %w", ref, err)
+		}
+	}
+	return r.RunHook("reference-transaction", refUpdateLine(ref, oldSHA, newSHA), "prepared")
+}
+
+// RunRefUpdated runs the reference-transaction hook in the
+// "committed" state, after a ref has been updated
+func (r *Repository) RunRefUpdated(ref, oldSHA, newSHA string) error {
+	return r.RunHook("reference-transaction", refUpdateLine(ref, oldSHA, newSHA), "committed")
+}
+
+func refUpdateLine(ref, oldSHA, newSHA string) string {
+	zero := strings.Repeat("0", 40)
+	if oldSHA == "" {
+		oldSHA = zero
+	}
+	if newSHA == "" {
+		newSHA = zero
+	}
+	return fmt.Sprintf("%s %s %s\n", oldSHA, newSHA, ref)
+}
+
+// HooksDir returns the directory with the executable hooks,
+// which is core.hooksPath if it is set
+func (r *Repository) HooksDir() (string, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+	if hooksPath, ok := cfg.Get("core", "hooksPath"); ok && hooksPath != "" {
+		if filepath.IsAbs(hooksPath) {
+			return hooksPath, nil
+		}
+		return filepath.Join(r.worktree, hooksPath), nil
+	}
+	return r.RepositoryPath("hooks"), nil
+}
+
+// RunHook runs the executable hook called name with args, and stdin as its
+// standard input. Hooks that don't exist or aren't executable are skipped.
+// A hook that exits with a non-zero status results in an error.
+func (r *Repository) RunHook(name, stdin string, args ...string) error {
+	dir, err := r.HooksDir()
+	if err != nil {
+		return err
+	}
+	hook := filepath.Join(dir, name)
+	info, err := os.Stat(hook)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() || info.Mode()&0o111 == 0 {
+		return nil
+	}
+
+	cmd := exec.Command(hook, args...)
+	cmd.Dir = r.worktree
+	cmd.Env = append(os.Environ(), "GIT_DIR="+r.gitdir)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCallbacks(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	calls := []string{}
+	repo.OnPreCommit(func(r *Repository) error {
+		calls = append(calls, "pre-commit")
+		return nil
+	})
+	repo.OnCommitMsg(func(r *Repository, message string) error {
+		if message == "wip" {
+			return errors.New("no wip commits")
+		}
+		calls = append(calls, "commit-msg "+message)
+		return nil
+	})
+	repo.OnPostCommit(func(r *Repository, commit string) {
+		calls = append(calls, "post-commit "+commit)
+	})
+	repo.OnRefUpdate(func(r *Repository, ref, oldSHA, newSHA string) error {
+		if ref == "refs/heads/protected" {
+			return errors.New("protected branch")
+		}
+		calls = append(calls, "update "+ref)
+		return nil
+	})
+
+	if err := repo.RunPreCommit(); err != nil {
+		t.Errorf("RunPreCommit() error: %v", err)
+	}
+	if err := repo.RunCommitMsg("message"); err != nil {
+		t.Errorf("RunCommitMsg() error: %v", err)
+	}
+	if err := repo.RunCommitMsg("wip"); err == nil {
+		t.Errorf("RunCommitMsg() should be vetoed")
+	}
+	if err := repo.RunRefUpdate("refs/heads/master", "", "abc"); err != nil {
+		t.Errorf("RunRefUpdate() error: %v", err)
+	}
+	if err := repo.RunRefUpdate("refs/heads/protected", "", "abc"); err == nil {
+		t.Errorf("RunRefUpdate() should be vetoed")
+	}
+	if err := repo.RunPostCommit("abc"); err != nil {
+		t.Errorf("RunPostCommit() error: %v", err)
+	}
+
+	want := []string{"pre-commit", "commit-msg message", "update refs/heads/master", "post-commit abc"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("calls[%d] = %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	// Missing hooks are skipped
+	if err := repo.RunPreCommit(); err != nil {
+		t.Errorf("RunPreCommit() without hook error: %v", err)
+	}
+
+	hooksDir := filepath.Join(dir, ".git", "hooks")
+	if err := os.MkdirAll(hooksDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hooksDir, "pre-commit"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RunPreCommit(); err == nil {
+		t.Errorf("RunPreCommit() should fail when the hook fails")
+	}
+
+	// Hooks that aren't executable are skipped
+	if err := os.Chmod(filepath.Join(hooksDir, "pre-commit"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RunPreCommit(); err != nil {
+		t.Errorf("RunPreCommit() with non-executable hook error: %v", err)
+	}
+}
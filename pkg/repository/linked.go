@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The .git of a linked worktree is a file with "gitdir: <path>", where
+// path is its own gitdir below worktrees/<id> in the gitdir of the main
+// worktree. The commondir file in it points to the gitdir of the main
+// worktree, which has the files that all worktrees share.
+
+// commonPaths are the paths below the gitdir that are shared by all
+// worktrees, or that are not when the value is false. The longest one
+// that a path is in decides; paths that are in none are per worktree.
+var commonPaths = map[string]bool{
+	"blame-cache":          true,
+	"branches":             true,
+	"common":               true,
+	"config":               true,
+	"hooks":                true,
+	"info":                 true,
+	"info/sparse-checkout": false,
+	"logs":                 true,
+	"logs/HEAD":            false,
+	"logs/refs/bisect":     false,
+	"logs/refs/rewritten":  false,
+	"logs/refs/worktree":   false,
+	"lost-found":           true,
+	"objects":              true,
+	"packed-refs":          true,
+	"refs":                 true,
+	"refs/bisect":          false,
+	"refs/rewritten":       false,
+	"refs/worktree":        false,
+	"remotes":              true,
+	"rr-cache":             true,
+	"shallow":              true,
+	"worktrees":            true,
+}
+
+// isCommonPath returns whether rel, a path below the gitdir, is shared
+// by all worktrees
+func isCommonPath(rel string) bool {
+	for p := path.Clean(rel); p != "." && p != "/"; p = path.Dir(p) {
+		if common, ok := commonPaths[p]; ok {
+			return common
+		}
+	}
+	return false
+}
+
+// readGitFile returns the gitdir and the common dir of the linked worktree
+// whose .git file is at dotgit
+func readGitFile(dotgit string) (string, string, error) {
+	contents, err := os.ReadFile(dotgit)
+	if err != nil {
+		return "", "", err
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimRight(string(contents), " \t\r\n"), "gitdir: ")
+	if !ok || gitdir == "" {
+		return "", "", fmt.Errorf("invalid gitfile format: %s", dotgit)
+	}
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(filepath.Dir(dotgit), gitdir)
+	}
+	gitdir = filepath.Clean(gitdir)
+
+	commondir := gitdir
+	if contents, err := os.ReadFile(filepath.Join(gitdir, "commondir")); err == nil {
+		commondir = strings.TrimRight(string(contents), " \t\r\n")
+		if !filepath.IsAbs(commondir) {
+			commondir = filepath.Join(gitdir, commondir)
+		}
+		commondir = filepath.Clean(commondir)
+	}
+	return gitdir, commondir, nil
+}
+
+// Worktrees returns a handle for every worktree of the repository: the
+// main worktree first, then the linked worktrees sorted by ID. The gitdir
+// of a linked worktree is kept until it is pruned, so its HEAD and index
+// can be read even when its directory is gone.
+func (r *Repository) Worktrees() ([]*Repository, error) {
+	worktrees := []*Repository{newRepository(filepath.Dir(r.commondir), r.commondir, r.commondir)}
+	if r.gitdir == r.commondir {
+		worktrees[0] = r
+	}
+
+	dir := filepath.Join(r.commondir, "worktrees")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return worktrees, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		gitdir := filepath.Join(dir, id)
+		if gitdir == r.gitdir {
+			worktrees = append(worktrees, r)
+			continue
+		}
+		worktree := ""
+		if dotgit, err := os.ReadFile(filepath.Join(gitdir, "gitdir")); err == nil {
+			worktree = filepath.Dir(strings.TrimRight(string(dotgit), "\r\n"))
+		}
+		worktrees = append(worktrees, newRepository(worktree, gitdir, r.commondir))
+	}
+	return worktrees, nil
+}
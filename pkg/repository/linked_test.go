@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupLinked creates a repository with a linked worktree "wt" next to it,
+// like git worktree add, and returns the handles of both
+func setupLinked(t *testing.T) (*Repository, *Repository) {
+	dir := setupTestDir(t)
+	t.Cleanup(func() { cleanupTestDir(t, dir) })
+	main, err := Create(filepath.Join(dir, "main"))
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	adminDir := main.RepositoryPath("worktrees", "wt")
+	path := filepath.Join(dir, "wt")
+	for _, d := range []string{adminDir, path} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(adminDir, "gitdir"):    filepath.Join(path, ".git") + "\n",
+		filepath.Join(adminDir, "commondir"): "../..\n",
+		filepath.Join(adminDir, "HEAD"):      "ref: refs/heads/wt\n",
+		filepath.Join(path, ".git"):          "gitdir: " + adminDir + "\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	linked, err := Find(path)
+	if err != nil {
+		t.Fatalf("Find() on linked worktree error: %v", err)
+	}
+	return main, linked
+}
+
+func TestLinkedRepositoryPath(t *testing.T) {
+	main, linked := setupLinked(t)
+	adminDir := main.RepositoryPath("worktrees", "wt")
+
+	if linked.CommonDir() != main.GitDir() {
+		t.Errorf("CommonDir() = %s, want %s", linked.CommonDir(), main.GitDir())
+	}
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{paths: []string{"HEAD"}, want: filepath.Join(adminDir, "HEAD")},
+		{paths: []string{"index"}, want: filepath.Join(adminDir, "index")},
+		{paths: []string{"config.worktree"}, want: filepath.Join(adminDir, "config.worktree")},
+		{paths: []string{"config"}, want: main.RepositoryPath("config")},
+		{paths: []string{"objects", "ab"}, want: main.RepositoryPath("objects", "ab")},
+		{paths: []string{"refs/heads/master"}, want: main.RepositoryPath("refs", "heads", "master")},
+		{paths: []string{"refs", "worktree", "x"}, want: filepath.Join(adminDir, "refs", "worktree", "x")},
+		{paths: []string{"logs", "HEAD"}, want: filepath.Join(adminDir, "logs", "HEAD")},
+		{paths: []string{"logs", "refs", "heads", "wt"}, want: main.RepositoryPath("logs", "refs", "heads", "wt")},
+		{paths: []string{"info", "exclude"}, want: main.RepositoryPath("info", "exclude")},
+		{paths: []string{"info", "sparse-checkout"}, want: filepath.Join(adminDir, "info", "sparse-checkout")},
+	}
+	for _, tt := range tests {
+		if got := linked.RepositoryPath(tt.paths...); got != tt.want {
+			t.Errorf("RepositoryPath(%q) = %s, want %s", tt.paths, got, tt.want)
+		}
+	}
+
+	branch, ok, err := linked.GetActiveBranch()
+	if err != nil || !ok || branch != "wt" {
+		t.Errorf("GetActiveBranch() = %q, %v, %v, want wt", branch, ok, err)
+	}
+}
+
+func TestWorktreeConfig(t *testing.T) {
+	main, linked := setupLinked(t)
+	write := func(name, contents string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(main.RepositoryPath("config"), "[core]\n\trepositoryformatversion = 1\n[user]\n\tname = Shared\n")
+	write(main.RepositoryPath("config.worktree"), "[user]\n\tname = Main\n")
+	write(linked.RepositoryPath("config.worktree"), "[user]\n\tname = Linked\n")
+
+	userName := func(repo *Repository) string {
+		t.Helper()
+		cfg, err := repo.Config()
+		if err != nil {
+			t.Fatalf("Config() error: %v", err)
+		}
+		name, _ := cfg.Get("user", "name")
+		return name
+	}
+
+	// config.worktree is only read with extensions.worktreeConfig
+	if got := userName(linked); got != "Shared" {
+		t.Errorf("without extension: user.name = %q, want Shared", got)
+	}
+
+	write(main.RepositoryPath("config"), "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tworktreeConfig = true\n[user]\n\tname = Shared\n\temail = shared@example.com\n")
+	main.Invalidate(main.RepositoryPath("config"))
+	linked.Invalidate(linked.RepositoryPath("config"))
+	if got := userName(main); got != "Main" {
+		t.Errorf("main worktree: user.name = %q, want Main", got)
+	}
+	if got := userName(linked); got != "Linked" {
+		t.Errorf("linked worktree: user.name = %q, want Linked", got)
+	}
+	cfg, _ := linked.Config()
+	if got, _ := cfg.Get("user", "email"); got != "shared@example.com" {
+		t.Errorf("linked worktree: user.email = %q, want the shared one", got)
+	}
+
+	// A change of the repository configuration is seen through the
+	// cached worktree configuration
+	write(main.RepositoryPath("config"), "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tworktreeConfig = true\n[user]\n\temail = other@example.com\n")
+	linked.Invalidate(linked.RepositoryPath("config"))
+	cfg, _ = linked.Config()
+	if got, _ := cfg.Get("user", "email"); got != "other@example.com" {
+		t.Errorf("after change: user.email = %q, want other@example.com", got)
+	}
+}
+
+func TestUnknownExtension(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	config := "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectFormat = sha256\n"
+	if err := os.WriteFile(repo.RepositoryPath("config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(dir, false); err == nil {
+		t.Errorf("New() should fail for an unknown extension")
+	}
+}
+
+func TestWorktrees(t *testing.T) {
+	main, linked := setupLinked(t)
+	for _, repo := range []*Repository{main, linked} {
+		worktrees, err := repo.Worktrees()
+		if err != nil {
+			t.Fatalf("Worktrees() error = %v", err)
+		}
+		if len(worktrees) != 2 {
+			t.Fatalf("Worktrees() returned %d worktrees, want 2", len(worktrees))
+		}
+		if worktrees[0].GitDir() != main.GitDir() || worktrees[1].GitDir() != linked.GitDir() {
+			t.Errorf("Worktrees() gitdirs = %s, %s, want %s, %s", worktrees[0].GitDir(), worktrees[1].GitDir(), main.GitDir(), linked.GitDir())
+		}
+		if filepath.Clean(worktrees[1].WorkTree()) != filepath.Clean(linked.WorkTree()) {
+			t.Errorf("Worktrees() linked worktree = %s, want %s", worktrees[1].WorkTree(), linked.WorkTree())
+		}
+		if got := worktrees[1].RepositoryPath("HEAD"); got != linked.RepositoryPath("HEAD") {
+			t.Errorf("RepositoryPath(HEAD) of linked worktree = %s, want %s", got, linked.RepositoryPath("HEAD"))
+		}
+	}
+}
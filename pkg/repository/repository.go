@@ -6,44 +6,96 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/jessegeens/got/pkg/fs"
 	"gopkg.in/ini.v1"
 )
 
+// Repository is a handle to a repository on disk.
+//
+// A Repository is safe for concurrent use by multiple goroutines: its paths
+// never change, and the callbacks, caches and locks are synchronized. The
+// index package serializes index updates through Lock("index"), and cached
+// files like the config and packed-refs are reloaded when they change on
+// disk. Operations that read and then write state, like updating the index,
+// must use the corresponding helpers (e.g. index.Update) to be atomic.
 type Repository struct {
 	worktree string
 	gitdir   string
+	// commondir has the files shared by all worktrees. It is the gitdir
+	// of the main worktree, which is gitdir itself unless this is a
+	// linked worktree.
+	commondir string
+	// Callbacks registered by embedders
+	hooks *hooks
+	// Parsed files, like the config
+	cache *fileCache
+
+	locksMu sync.Mutex
+	locks   map[string]*sync.RWMutex
 }
 
 // Constructor
 func New(repositoryPath string, disableChecks bool) (*Repository, error) {
 	worktree := repositoryPath
 	gitdir := path.Join(repositoryPath, ".git")
+	commondir := gitdir
+	// The .git of a linked worktree is a file
+	if fs.IsFile(gitdir) {
+		var err error
+		if gitdir, commondir, err = readGitFile(gitdir); err != nil {
+			return nil, err
+		}
+	}
 
 	if !disableChecks {
 		if _, err := os.Stat(gitdir); os.IsNotExist(err) {
 			return nil, errors.New("not a git repository " + repositoryPath)
 		}
 
-		cfg, err := ini.Load(path.Join(gitdir, "config"))
+		cfg, err := ini.Load(path.Join(commondir, "config"))
 		if err != nil {
 			return nil, fmt.Errorf("failed to read repository configuration: %s", err.Error())
 		}
-		if cfg.Section("core").Key("repositoryformatversion").MustInt(0) != 0 {
-			return nil, errors.New("wrong repositoryformatversion")
+		if err := checkFormatVersion(cfg); err != nil {
+			return nil, err
 		}
 	}
 
+	return newRepository(worktree, gitdir, commondir), nil
+}
+
+func newRepository(worktree, gitdir, commondir string) *Repository {
 	return &Repository{
-		worktree: worktree,
-		gitdir:   gitdir,
-	}, nil
+		worktree:  worktree,
+		gitdir:    gitdir,
+		commondir: commondir,
+		hooks:     &hooks{},
+		cache:     &fileCache{entries: map[string]*cacheEntry{}, dirs: map[string]*dirEntry{}},
+		locks:     map[string]*sync.RWMutex{},
+	}
+}
+
+// CreateOptions change how CreateWithOptions sets up a repository
+type CreateOptions struct {
+	// TemplateDir is the template directory whose files are copied into
+	// the gitdir. The built-in templates are used if it is empty.
+	TemplateDir string
+	// Quiet leaves out the message that the repository was created, for
+	// commands like clone that create one on the way
+	Quiet bool
 }
 
 // Create repository on filesystem
 func Create(repositoryPath string) (*Repository, error) {
+	return CreateWithOptions(repositoryPath, CreateOptions{})
+}
+
+// CreateWithOptions creates a repository like Create, with opts
+func CreateWithOptions(repositoryPath string, opts CreateOptions) (*Repository, error) {
 	repo, _ := New(repositoryPath, true)
 
 	// Make sure path doesn't exist or that it is an empty dir
@@ -70,14 +122,21 @@ func Create(repositoryPath string) (*Repository, error) {
 		}
 	}
 
-	repoFile, _ := repo.RepositoryFile(true, "description")
-	err := fs.WriteStringToFile(repoFile, "Unnamed repository; edit this file 'description' to name the repository.\n")
-	if err != nil {
-		return nil, errors.New("Failed to create repository description: " + err.Error())
+	if err := repo.copyTemplates(opts.TemplateDir); err != nil {
+		return nil, fmt.Errorf("failed to copy templates: %w", err)
+	}
+
+	// The template may have a description
+	if !fs.PathExists(repo.RepositoryPath("description")) {
+		repoFile, _ := repo.RepositoryFile(true, "description")
+		err := fs.WriteStringToFile(repoFile, "Unnamed repository; edit this file 'description' to name the repository.\n")
+		if err != nil {
+			return nil, errors.New("Failed to create repository description: " + err.Error())
+		}
 	}
 
-	repoFile, _ = repo.RepositoryFile(true, "HEAD")
-	err = fs.WriteStringToFile(repoFile, "ref: refs/heads/master\n")
+	repoFile, _ := repo.RepositoryFile(true, "HEAD")
+	err := fs.WriteStringToFile(repoFile, "ref: refs/heads/master\n")
 	if err != nil {
 		return nil, errors.New("Failed to create repository HEAD: " + err.Error())
 	}
@@ -89,33 +148,50 @@ func Create(repositoryPath string) (*Repository, error) {
 		return nil, err
 	}
 
-	fmt.Println("Initialized new empty git repository")
+	if !opts.Quiet {
+		fmt.Println("Initialized new empty git repository")
+	}
 
 	return repo, nil
 }
 
-// Locate the root of a git repo among the parent directories
+// Locate the root of a git repo among the parent directories. The search
+// doesn't go up into the directories of GIT_CEILING_DIRECTORIES, and a
+// repository that is owned by another user is refused, see
+// ErrDubiousOwnership.
 func Find(childPath string) (*Repository, error) {
 	realPath, err := filepath.Abs(childPath)
 	if err != nil {
 		return nil, err
 	}
+	ceiling := ceilingDirectory(realPath)
 
-	if fs.IsDirectory(path.Join(realPath, ".git")) {
-		return New(realPath, false)
-	}
-
-	parent := path.Join(realPath, "..")
-	// base case, if parent == child then we are in /
-	if parent == realPath {
-		return nil, errors.New("not a git directory")
+	for dir := realPath; ; {
+		if fs.PathExists(path.Join(dir, ".git")) {
+			repo, err := New(dir, false)
+			if err != nil {
+				return nil, err
+			}
+			if err := repo.checkOwnership(); err != nil {
+				return nil, err
+			}
+			return repo, nil
+		}
+		parent := path.Dir(dir)
+		// base case, if parent == child then we are in /
+		if parent == dir || parent == ceiling {
+			return nil, errors.New("not a git directory")
+		}
+		dir = parent
 	}
-
-	return Find(parent)
 }
 
-// Compute path under repo's gitdir
+// Compute path under repo's gitdir. In a linked worktree, the files
+// shared by all worktrees, like objects and refs, are in the common dir.
 func (r *Repository) RepositoryPath(paths ...string) string {
+	if r.commondir != r.gitdir && isCommonPath(path.Join(paths...)) {
+		return path.Join(append([]string{r.commondir}, paths...)...)
+	}
 	return path.Join(append([]string{r.gitdir}, paths...)...)
 }
 
@@ -141,7 +217,7 @@ func (r *Repository) RepositoryFile(create bool, paths ...string) (string, error
 		}
 		// path does not exist
 		if errors.Is(err, os.ErrNotExist) {
-			err = fs.WriteStringToFile(path, "")
+			err = fs.WriteFile(path, nil)
 			if err != nil {
 				return "", err
 			}
@@ -167,7 +243,7 @@ func (r *Repository) RepositoryDir(create bool, paths ...string) (string, error)
 		}
 	} else { // path does not exist
 		if create {
-			err = os.MkdirAll(path, os.ModePerm)
+			err = r.mkdirShared(path)
 			return path, err
 		}
 		return "", errors.New("path does not exist and create = false")
@@ -225,6 +301,33 @@ func (r *Repository) GitDir() string {
 	return r.gitdir
 }
 
+// CommonDir returns the gitdir of the main worktree, which has the files
+// shared by all worktrees
+func (r *Repository) CommonDir() string {
+	return r.commondir
+}
+
+// knownExtensions are the extensions.* keys that repositories with
+// repositoryformatversion 1 may use
+var knownExtensions = []string{"noop", "preciousobjects", "worktreeconfig"}
+
+// checkFormatVersion fails for repositories that got can't use. Like git,
+// repositories of version 1 can only have extensions that are known.
+func checkFormatVersion(cfg *ini.File) error {
+	switch cfg.Section("core").Key("repositoryformatversion").MustInt(0) {
+	case 0:
+		return nil
+	case 1:
+		for _, key := range cfg.Section("extensions").KeyStrings() {
+			if !slices.Contains(knownExtensions, strings.ToLower(key)) {
+				return fmt.Errorf("unknown repository extension found: %s", key)
+			}
+		}
+		return nil
+	}
+	return errors.New("wrong repositoryformatversion")
+}
+
 func defaultRepositoryConfig() *ini.File {
 	cfg := ini.Empty()
 	cfg.NewSection("core")
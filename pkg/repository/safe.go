@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+// ErrDubiousOwnership is returned for repositories that are owned by
+// another user and aren't listed in safe.directory. Their configuration
+// and hooks could make got run commands as the current user.
+var ErrDubiousOwnership = errors.New("detected dubious ownership in repository")
+
+// checkOwnership makes sure that the current user owns the worktree and
+// gitdir of a discovered repository, or that safe.directory allows it.
+// Like git, safe.directory is only read from the global configuration
+// and the environment, never from the repository itself.
+func (r *Repository) checkOwnership() error {
+	// Like in git, tests can pretend that the repository isn't owned
+	if os.Getenv("GIT_TEST_ASSUME_DIFFERENT_OWNER") != "1" && ownedByCurrentUser(r.worktree) && ownedByCurrentUser(r.gitdir) {
+		return nil
+	}
+	cfg, err := config.Read()
+	if err != nil {
+		return err
+	}
+	if isSafeDirectory(cfg.GetAll("safe", "directory"), r.worktree) {
+		return nil
+	}
+	return fmt.Errorf("%w at '%s'; to allow it, add it to safe.directory in your global configuration", ErrDubiousOwnership, r.worktree)
+}
+
+// isSafeDirectory reports whether dir is allowed by the safe.directory
+// values: * allows every directory, a value ending in /* the directories
+// below it, and an empty value resets the list
+func isSafeDirectory(values []string, dir string) bool {
+	safe := false
+	for _, value := range values {
+		if value == "" {
+			safe = false
+			continue
+		}
+		if value == "*" {
+			safe = true
+			continue
+		}
+		if rest, ok := strings.CutPrefix(value, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				value = filepath.Join(home, rest)
+			}
+		}
+		if prefix, ok := strings.CutSuffix(value, "/*"); ok {
+			if strings.HasPrefix(dir, strings.TrimSuffix(prefix, "/")+"/") {
+				safe = true
+			}
+			continue
+		}
+		if filepath.Clean(value) == dir {
+			safe = true
+		}
+	}
+	return safe
+}
+
+// currentUID returns the user that runs got. Like git, a root that got
+// there through sudo is taken for the user that ran sudo.
+func currentUID() int {
+	uid := os.Geteuid()
+	if uid == 0 {
+		if sudoUID, err := strconv.Atoi(os.Getenv("SUDO_UID")); err == nil {
+			return sudoUID
+		}
+	}
+	return uid
+}
+
+// ceilingDirectory returns the closest directory above dir in
+// GIT_CEILING_DIRECTORIES, which discovery doesn't go up into, or "" if
+// there is none. Like git, entries that aren't absolute are ignored.
+func ceilingDirectory(dir string) string {
+	ceiling := ""
+	for _, entry := range filepath.SplitList(os.Getenv("GIT_CEILING_DIRECTORIES")) {
+		if !filepath.IsAbs(entry) {
+			continue
+		}
+		entry = filepath.Clean(entry)
+		above := entry != dir && (entry == "/" || strings.HasPrefix(dir, entry+"/"))
+		if above && len(entry) > len(ceiling) {
+			ceiling = entry
+		}
+	}
+	return ceiling
+}
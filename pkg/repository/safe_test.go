@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsSafeDirectory(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	tests := []struct {
+		name   string
+		values []string
+		dir    string
+		want   bool
+	}{
+		{"nothing", nil, "/srv/repo", false},
+		{"exact", []string{"/srv/repo"}, "/srv/repo", true},
+		{"trailing slash", []string{"/srv/repo/"}, "/srv/repo", true},
+		{"other", []string{"/srv/other"}, "/srv/repo", false},
+		{"star", []string{"*"}, "/srv/repo", true},
+		{"below", []string{"/srv/*"}, "/srv/repo", true},
+		{"not below", []string{"/srv/*"}, "/srv", false},
+		{"home", []string{"~/repo"}, filepath.Join(home, "repo"), true},
+		{"reset", []string{"*", ""}, "/srv/repo", false},
+		{"after reset", []string{"*", "", "/srv/repo"}, "/srv/repo", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSafeDirectory(tt.values, tt.dir); got != tt.want {
+				t.Errorf("isSafeDirectory(%q, %q) = %v, want %v", tt.values, tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCeiling(t *testing.T) {
+	dir := filepath.Clean(setupTestDir(t))
+	defer os.RemoveAll(dir)
+	if _, err := Create(dir); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		ceilings string
+		start    string
+		wantErr  bool
+	}{
+		{"no ceiling", "", sub, false},
+		{"ceiling above the repository", filepath.Dir(dir), sub, false},
+		{"ceiling at the repository", dir, sub, true},
+		{"ceiling below the repository", filepath.Join(dir, "a"), sub, true},
+		{"the start is never a ceiling", sub, sub, false},
+		{"relative ceilings are ignored", "a", sub, false},
+		{"start in the repository", dir + string(filepath.ListSeparator) + "/nowhere", dir, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GIT_CEILING_DIRECTORIES", tt.ceilings)
+			_, err := Find(tt.start)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFindOwnership(t *testing.T) {
+	dir := filepath.Clean(setupTestDir(t))
+	defer os.RemoveAll(dir)
+	if _, err := Create(dir); err != nil {
+		t.Fatal(err)
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, "git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "git", "config"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Find(dir); err != nil {
+		t.Fatalf("Find() of an owned repository error = %v", err)
+	}
+
+	t.Setenv("GIT_TEST_ASSUME_DIFFERENT_OWNER", "1")
+	if _, err := Find(dir); !errors.Is(err, ErrDubiousOwnership) {
+		t.Errorf("Find() of a repository of another user error = %v, want %v", err, ErrDubiousOwnership)
+	}
+
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "safe.directory")
+	t.Setenv("GIT_CONFIG_VALUE_0", dir)
+	if _, err := Find(dir); err != nil {
+		t.Errorf("Find() of a safe directory error = %v", err)
+	}
+
+	// The repository can't make itself safe
+	t.Setenv("GIT_CONFIG_COUNT", "0")
+	if err := os.WriteFile(filepath.Join(dir, ".git", "config"), []byte("[safe]\n\tdirectory = *\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Find(dir); !errors.Is(err, ErrDubiousOwnership) {
+		t.Errorf("Find() with safe.directory in the repository error = %v, want %v", err, ErrDubiousOwnership)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package repository
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByCurrentUser reports whether the current user owns a path.
+// Paths that don't exist don't count against a repository.
+func ownedByCurrentUser(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return !ok || int(stat.Uid) == currentUID()
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/fs"
+)
+
+// sharedPerm is the parsed value of core.sharedRepository. The zero
+// value means the permissions are left to the umask.
+type sharedPerm struct {
+	// mode holds the permission bits to add
+	mode os.FileMode
+	// exact is set when an octal mode was configured; the permissions of
+	// files are then replaced by mode instead of extended with it
+	exact bool
+}
+
+const (
+	sharedGroup      os.FileMode = 0o660
+	sharedEverybody  os.FileMode = 0o664
+	sharedConfigName             = "core.sharedRepository"
+)
+
+// parseSharedPerm parses a core.sharedRepository value, like git
+func parseSharedPerm(value string) (sharedPerm, error) {
+	switch strings.ToLower(value) {
+	case "", "umask", "false", "no", "off":
+		return sharedPerm{}, nil
+	case "group", "true", "yes", "on":
+		return sharedPerm{mode: sharedGroup}, nil
+	case "all", "world", "everybody":
+		return sharedPerm{mode: sharedEverybody}, nil
+	}
+
+	i, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return sharedPerm{}, fmt.Errorf("invalid %s value '%s'", sharedConfigName, value)
+	}
+	switch i {
+	case 0:
+		return sharedPerm{}, nil
+	case 1:
+		return sharedPerm{mode: sharedGroup}, nil
+	case 2:
+		return sharedPerm{mode: sharedEverybody}, nil
+	}
+	if i&0o600 != 0o600 {
+		return sharedPerm{}, fmt.Errorf("problem with %s filemode value (0%.3o): the owner of files must always have read and write permissions", sharedConfigName, i)
+	}
+	return sharedPerm{mode: os.FileMode(i) & 0o666, exact: true}, nil
+}
+
+// apply computes the permissions of a file with the given mode
+func (s sharedPerm) apply(mode os.FileMode, dir bool) os.FileMode {
+	tweak := s.mode
+	// Read-only files, like objects, stay read-only
+	if mode&0o200 == 0 {
+		tweak &^= 0o222
+	}
+	// Copy the read bits to the execute bits for executables
+	if mode&0o100 != 0 {
+		tweak |= (tweak & 0o444) >> 2
+	}
+
+	perm := mode.Perm() | tweak
+	if s.exact {
+		perm = tweak
+	}
+	if dir {
+		// Directories need to be searchable by whoever can read them, and
+		// the group is inherited by the files created in them
+		perm |= (perm & 0o444) >> 2
+		return mode&^os.ModePerm | perm | os.ModeSetgid
+	}
+	return mode&^os.ModePerm | perm
+}
+
+func (r *Repository) sharedPerm() (sharedPerm, error) {
+	// While a repository is being created, it has no config yet
+	if !fs.Exists(r.RepositoryPath("config")) {
+		return sharedPerm{}, nil
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return sharedPerm{}, err
+	}
+	value, _ := cfg.Get("core", "sharedRepository")
+	return parseSharedPerm(value)
+}
+
+// SharedMode returns the permissions for a new file in the gitdir that would
+// otherwise get mode: like git, the umask is applied first, and then
+// core.sharedRepository. The result is meant for fs.WithExactPerm.
+func (r *Repository) SharedMode(mode os.FileMode) (os.FileMode, error) {
+	shared, err := r.sharedPerm()
+	if err != nil {
+		return 0, err
+	}
+	return shared.apply(mode&^fs.Umask(), false), nil
+}
+
+// AdjustSharedPerm updates the permissions of a file or directory that
+// was created in the gitdir according to core.sharedRepository, so all
+// users sharing the repository can access it.
+func (r *Repository) AdjustSharedPerm(path string) error {
+	shared, err := r.sharedPerm()
+	if err != nil {
+		return err
+	}
+	if shared == (sharedPerm{}) {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	mode := info.Mode() & (os.ModePerm | os.ModeSetgid)
+	newMode := shared.apply(mode, info.IsDir())
+	if newMode == mode {
+		return nil
+	}
+	return os.Chmod(path, newMode)
+}
+
+// mkdirShared creates path and its missing parents, with permissions
+// adjusted by AdjustSharedPerm for the directories under the gitdir
+func (r *Repository) mkdirShared(path string) error {
+	// In a linked worktree, shared files are in the common dir
+	root := r.gitdir
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		root = r.commondir
+		rel, err = filepath.Rel(root, path)
+	}
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return os.MkdirAll(path, os.ModePerm)
+	}
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return err
+	}
+
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		err := os.Mkdir(dir, os.ModePerm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := r.AdjustSharedPerm(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
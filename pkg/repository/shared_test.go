@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSharedPerm(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    sharedPerm
+		wantErr bool
+	}{
+		{"", sharedPerm{}, false},
+		{"umask", sharedPerm{}, false},
+		{"false", sharedPerm{}, false},
+		{"0", sharedPerm{}, false},
+		{"group", sharedPerm{mode: 0o660}, false},
+		{"true", sharedPerm{mode: 0o660}, false},
+		{"1", sharedPerm{mode: 0o660}, false},
+		{"all", sharedPerm{mode: 0o664}, false},
+		{"everybody", sharedPerm{mode: 0o664}, false},
+		{"2", sharedPerm{mode: 0o664}, false},
+		{"0640", sharedPerm{mode: 0o640, exact: true}, false},
+		{"0777", sharedPerm{mode: 0o666, exact: true}, false},
+		{"0440", sharedPerm{}, true},
+		{"sometimes", sharedPerm{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSharedPerm(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSharedPerm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSharedPerm() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSharedPermApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		shared sharedPerm
+		mode   os.FileMode
+		dir    bool
+		want   os.FileMode
+	}{
+		{"group file", sharedPerm{mode: 0o660}, 0o644, false, 0o664},
+		{"group object", sharedPerm{mode: 0o660}, 0o444, false, 0o444},
+		{"group restricted object", sharedPerm{mode: 0o660}, 0o400, false, 0o440},
+		{"everybody executable", sharedPerm{mode: 0o664}, 0o700, false, 0o775},
+		{"group directory", sharedPerm{mode: 0o660}, 0o755, true, 0o775 | os.ModeSetgid},
+		{"exact file", sharedPerm{mode: 0o640, exact: true}, 0o644, false, 0o640},
+		{"exact object", sharedPerm{mode: 0o640, exact: true}, 0o444, false, 0o440},
+		{"exact directory", sharedPerm{mode: 0o600, exact: true}, 0o755, true, 0o700 | os.ModeSetgid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.shared.apply(tt.mode, tt.dir); got != tt.want {
+				t.Errorf("apply(%o) = %o, want %o", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSharedRepositoryDir(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	cfg := "[core]\n\trepositoryformatversion = 0\n\tsharedRepository = group\n"
+	if err := os.WriteFile(repo.RepositoryPath("config"), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	path, err := repo.RepositoryDir(true, "objects", "ab")
+	if err != nil {
+		t.Fatalf("RepositoryDir() error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error: %v", err)
+	}
+	if info.Mode()&os.ModeSetgid == 0 || info.Mode().Perm()&0o070 != 0o070 {
+		t.Errorf("directory mode = %v, want group writable and setgid", info.Mode())
+	}
+}
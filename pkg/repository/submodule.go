@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+
+	"github.com/jessegeens/got/pkg/fs"
+)
+
+// ErrSubmoduleNotInitialized is returned for a submodule that has no
+// repository yet
+var ErrSubmoduleNotInitialized = errors.New("submodule not initialized")
+
+// Submodule returns the repository of the submodule at name, a path
+// relative to the worktree. Its gitdir is the .git of the submodule, a
+// directory or a gitfile, or modules/<name> in the gitdir, where git
+// keeps the repositories of submodules that aren't checked out.
+func (r *Repository) Submodule(name string) (*Repository, error) {
+	worktree := filepath.Join(r.worktree, filepath.FromSlash(name))
+	if fs.PathExists(filepath.Join(worktree, ".git")) {
+		return New(worktree, false)
+	}
+	gitdir := path.Join(r.commondir, "modules", name)
+	if !fs.IsDirectory(gitdir) {
+		return nil, ErrSubmoduleNotInitialized
+	}
+	return newRepository(worktree, gitdir, gitdir), nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubmodule(t *testing.T) {
+	dir := setupTestDir(t)
+	t.Cleanup(func() { cleanupTestDir(t, dir) })
+	super, err := Create(filepath.Join(dir, "super"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A submodule with its own .git directory
+	if _, err := Create(filepath.Join(dir, "super", "lib")); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := super.Submodule("lib")
+	if err != nil {
+		t.Fatalf("Submodule(lib) error: %v", err)
+	}
+	if sub.GitDir() != filepath.Join(dir, "super", "lib", ".git") {
+		t.Errorf("Submodule(lib).GitDir() = %s", sub.GitDir())
+	}
+
+	// An absorbed submodule, whose repository is in modules/ and which
+	// isn't checked out
+	modules := super.RepositoryPath("modules", "deps", "x")
+	if err := os.MkdirAll(modules, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sub, err = super.Submodule("deps/x")
+	if err != nil {
+		t.Fatalf("Submodule(deps/x) error: %v", err)
+	}
+	if sub.GitDir() != modules || sub.WorkTree() != filepath.Join(dir, "super", "deps", "x")+string(filepath.Separator) {
+		t.Errorf("Submodule(deps/x) = %s in %s", sub.GitDir(), sub.WorkTree())
+	}
+
+	if _, err := super.Submodule("missing"); !errors.Is(err, ErrSubmoduleNotInitialized) {
+		t.Errorf("Submodule(missing) error = %v, want ErrSubmoduleNotInitialized", err)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"embed"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// builtinTemplates are used when no template directory is given, like
+// the templates that come with git: sample hooks and info/exclude
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+// templateSkip are the files of a template directory that aren't copied,
+// since Create writes them
+var templateSkip = map[string]bool{"HEAD": true, "config": true}
+
+// copyTemplates copies the files of a template directory into the gitdir,
+// like git init. Files that already exist are kept. An empty dir selects
+// the built-in templates, and a dir that doesn't exist none at all.
+func (r *Repository) copyTemplates(dir string) error {
+	var templates iofs.FS
+	if dir == "" {
+		sub, err := iofs.Sub(builtinTemplates, "templates")
+		if err != nil {
+			return err
+		}
+		templates = sub
+	} else {
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		templates = os.DirFS(dir)
+	}
+
+	return iofs.WalkDir(templates, ".", func(name string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." || templateSkip[name] {
+			return nil
+		}
+		target := r.RepositoryPath(filepath.FromSlash(name))
+		if d.IsDir() {
+			return r.mkdirShared(target)
+		}
+		if _, err := os.Lstat(target); err == nil {
+			return nil
+		}
+		data, err := iofs.ReadFile(templates, name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, templateMode(templates, name)); err != nil {
+			return err
+		}
+		return r.AdjustSharedPerm(target)
+	})
+}
+
+// templateMode returns the permissions of a copied template file. The
+// built-in templates have no modes, so their hooks are made executable.
+func templateMode(templates iofs.FS, name string) os.FileMode {
+	info, err := iofs.Stat(templates, name)
+	if err == nil && info.Mode().Perm()&0o200 != 0 {
+		return info.Mode().Perm()
+	}
+	if path.Dir(name) == "hooks" {
+		return 0o755
+	}
+	return 0o644
+}
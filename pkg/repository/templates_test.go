@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateWithBuiltinTemplates(t *testing.T) {
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	for _, hook := range []string{"pre-commit", "prepare-commit-msg", "commit-msg", "post-commit", "reference-transaction"} {
+		info, err := os.Stat(repo.RepositoryPath("hooks", hook+".sample"))
+		if err != nil {
+			t.Errorf("missing sample hook %s: %v", hook, err)
+			continue
+		}
+		if info.Mode()&0o100 == 0 {
+			t.Errorf("sample hook %s is not executable", hook)
+		}
+		// Samples are never run
+		if _, err := os.Stat(repo.RepositoryPath("hooks", hook)); err == nil {
+			t.Errorf("hook %s should not be enabled", hook)
+		}
+	}
+	if _, err := os.Stat(repo.RepositoryPath("info", "exclude")); err != nil {
+		t.Errorf("missing info/exclude: %v", err)
+	}
+}
+
+func TestCreateWithTemplateDir(t *testing.T) {
+	template := t.TempDir()
+	files := map[string]string{
+		"description":     "From the template\n",
+		"HEAD":            "ref: refs/heads/template\n",
+		"hooks/pre-push":  "#!/bin/sh\n",
+		"info/attributes": "*.bin binary\n",
+	}
+	for name, contents := range files {
+		file := filepath.Join(template, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(contents), 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := setupTestDir(t)
+	defer cleanupTestDir(t, dir)
+	repo, err := CreateWithOptions(dir, CreateOptions{TemplateDir: template})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() error: %v", err)
+	}
+
+	read := func(name string) string {
+		data, _ := os.ReadFile(repo.RepositoryPath(name))
+		return string(data)
+	}
+	if got := read("description"); got != "From the template\n" {
+		t.Errorf("description = %q, want the one of the template", got)
+	}
+	if got := read("HEAD"); got != "ref: refs/heads/master\n" {
+		t.Errorf("HEAD = %q, should not come from the template", got)
+	}
+	if got := read("info/attributes"); got != "*.bin binary\n" {
+		t.Errorf("info/attributes = %q, want the one of the template", got)
+	}
+	if info, err := os.Stat(repo.RepositoryPath("hooks", "pre-push")); err != nil || info.Mode()&0o100 == 0 {
+		t.Errorf("hooks/pre-push should be copied with its mode")
+	}
+	// A template directory replaces the built-in templates
+	if _, err := os.Stat(repo.RepositoryPath("hooks", "pre-commit.sample")); err == nil {
+		t.Errorf("built-in templates should not be used with a template directory")
+	}
+}
@@ -0,0 +1,117 @@
+package revwalk
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jessegeens/got/pkg/commitgraph"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// IsAncestor reports whether commit a is in the history of commit b, b
+// included, like git merge-base --is-ancestor. Tags are peeled to the
+// commits they tag.
+func IsAncestor(repo *repository.Repository, a, b *hashing.SHA) (bool, error) {
+	reached, err := Reachable(repo, []*hashing.SHA{b}, []*hashing.SHA{a})
+	return reached[a.AsString()], err
+}
+
+// Reachable returns which of the targets are in the history of one of the
+// commits in from, by their hex names. Tags are peeled to the commits they
+// tag. With a commit-graph, the walk skips the history of commits with a
+// lower generation than the targets, since none of them can be in it.
+func Reachable(repo *repository.Repository, from, targets []*hashing.SHA) (map[string]bool, error) {
+	// A commit-graph only speeds up the walk, so the walk goes without one
+	// that is broken, like in git
+	graph, err := commitgraph.Read(repo)
+	if err != nil {
+		graph = nil
+	}
+
+	// The names the targets were given by, for the commits they peel to
+	given := map[string][]string{}
+	// Targets that aren't in the graph can't be reached from the commits
+	// in it, whose parents are all in it too
+	minGeneration := uint32(math.MaxUint32)
+	for _, target := range targets {
+		commit, err := objects.Peel(repo, target, objects.TypeCommit)
+		if err != nil {
+			return nil, err
+		}
+		given[commit.AsString()] = append(given[commit.AsString()], target.AsString())
+		if c, ok, err := graph.Lookup(commit); err != nil {
+			return nil, err
+		} else if ok {
+			minGeneration = min(minGeneration, c.Generation)
+		}
+	}
+
+	reached := map[string]bool{}
+	remaining := len(given)
+	seen := map[string]bool{}
+	stack := []*hashing.SHA{}
+	for _, start := range from {
+		commit, err := objects.Peel(repo, start, objects.TypeCommit)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, commit)
+	}
+	for len(stack) > 0 && remaining > 0 {
+		sha := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		name := sha.AsString()
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if names, ok := given[name]; ok {
+			for _, n := range names {
+				reached[n] = true
+			}
+			remaining--
+		}
+
+		parents, generation, err := commitParents(repo, graph, sha)
+		if err != nil {
+			return nil, err
+		}
+		// A generation of zero is unknown, so it doesn't stop the walk, and
+		// a target that has one lets every commit through
+		if generation != 0 && generation < minGeneration {
+			continue
+		}
+		stack = append(stack, parents...)
+	}
+	return reached, nil
+}
+
+// commitParents returns the parents of a commit and its generation, from
+// the graph if it has the commit. Commits that aren't in the graph have the
+// highest generation.
+func commitParents(repo *repository.Repository, graph *commitgraph.Graph, sha *hashing.SHA) ([]*hashing.SHA, uint32, error) {
+	if c, ok, err := graph.Lookup(sha); err != nil {
+		return nil, 0, err
+	} else if ok {
+		return c.Parents, c.Generation, nil
+	}
+	obj, err := objects.ReadObject(repo, sha)
+	if err != nil {
+		return nil, 0, err
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return nil, 0, fmt.Errorf("object %s is a %s, not a commit", sha.AsString(), obj.Type())
+	}
+	parents := []*hashing.SHA{}
+	for _, parent := range commit.GetValues("parent") {
+		parentSha, err := hashing.NewShaFromHex(string(parent))
+		if err != nil {
+			return nil, 0, err
+		}
+		parents = append(parents, parentSha)
+	}
+	return parents, math.MaxUint32, nil
+}
@@ -0,0 +1,64 @@
+package revwalk
+
+import (
+	"testing"
+
+	"github.com/jessegeens/got/pkg/commitgraph"
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+func TestReachable(t *testing.T) {
+	for _, withGraph := range []bool{false, true} {
+		repo, err := repository.Create(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create repository: %v", err)
+		}
+		// root - a - b - merge - newer
+		//    \- side ---/
+		//     \- other
+		root := commitAt(t, repo, 100)
+		a := commitAt(t, repo, 200, root)
+		side := commitAt(t, repo, 250, root)
+		other := commitAt(t, repo, 260, root)
+		b := commitAt(t, repo, 300, a)
+		merge := commitAt(t, repo, 400, b, side)
+		if withGraph {
+			if _, err := commitgraph.Write(repo, []*hashing.SHA{merge, other}); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+		}
+		newer := commitAt(t, repo, 500, merge)
+
+		tests := []struct {
+			a, b *hashing.SHA
+			want bool
+		}{
+			{root, newer, true},
+			{side, merge, true},
+			{merge, merge, true},
+			{newer, newer, true},
+			{other, newer, false},
+			{merge, b, false},
+			{newer, merge, false},
+			{b, side, false},
+		}
+		for _, tt := range tests {
+			got, err := IsAncestor(repo, tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("IsAncestor() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsAncestor(%s, %s) with graph %v = %v, want %v", tt.a.AsString(), tt.b.AsString(), withGraph, got, tt.want)
+			}
+		}
+
+		reached, err := Reachable(repo, []*hashing.SHA{b, other}, []*hashing.SHA{a, side, other, newer})
+		if err != nil {
+			t.Fatalf("Reachable() error = %v", err)
+		}
+		if !reached[a.AsString()] || !reached[other.AsString()] || reached[side.AsString()] || reached[newer.AsString()] || len(reached) != 2 {
+			t.Errorf("Reachable() with graph %v = %v, want %s and %s", withGraph, reached, a.AsString(), other.AsString())
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package revwalk walks the history of commits, newest first, like git
+// rev-list. A walk can be stopped and resumed later from a cursor, so
+// that long histories can be shown one page at a time without walking
+// the commits of earlier pages again.
+package revwalk
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Commit is a commit that the walk came across
+type Commit struct {
+	SHA    *hashing.SHA
+	Commit *objects.Commit
+	// When is the committer date, which orders the walk
+	When time.Time
+}
+
+// Walker returns the commits reachable from its starting points, the one
+// with the most recent committer date first. Commits with the same date
+// come in the order they were found.
+type Walker struct {
+	repo  *repository.Repository
+	queue commitQueue
+	seen  map[string]bool
+	// seq numbers the commits in the order they were found
+	seq int
+}
+
+// New returns a walker that starts at the given commits
+func New(repo *repository.Repository, starts ...*hashing.SHA) (*Walker, error) {
+	w := &Walker{repo: repo, seen: map[string]bool{}}
+	for _, sha := range starts {
+		if err := w.push(sha); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Resume returns a walker that continues a walk where Cursor was called.
+// The commits that were already returned aren't remembered, so a commit
+// that is reachable from the cursor as well, which only happens when a
+// commit has an older date than its parent, is returned again.
+func Resume(repo *repository.Repository, cursor string) (*Walker, error) {
+	starts := []*hashing.SHA{}
+	if cursor != "" {
+		for _, name := range strings.Split(cursor, ".") {
+			sha, err := hashing.NewShaFromHex(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor: %w", err)
+			}
+			starts = append(starts, sha)
+		}
+	}
+	return New(repo, starts...)
+}
+
+// Cursor returns the state of the walk: the commits that are found but
+// not returned yet, in the order they'd be returned. An empty cursor means
+// the walk is done.
+func (w *Walker) Cursor() string {
+	pending := make(commitQueue, len(w.queue))
+	copy(pending, w.queue)
+	names := []string{}
+	for pending.Len() > 0 {
+		names = append(names, heap.Pop(&pending).(*queued).commit.SHA.AsString())
+	}
+	return strings.Join(names, ".")
+}
+
+// Next returns the next commit of the walk, or io.EOF when there are no
+// more
+func (w *Walker) Next() (*Commit, error) {
+	if w.queue.Len() == 0 {
+		return nil, io.EOF
+	}
+	next := heap.Pop(&w.queue).(*queued)
+	for _, parent := range next.commit.Commit.GetValues("parent") {
+		sha, err := hashing.NewShaFromHex(string(parent))
+		if err != nil {
+			return nil, err
+		}
+		if err := w.push(sha); err != nil {
+			return nil, err
+		}
+	}
+	return &next.commit, nil
+}
+
+// Skip passes over up to n commits, and returns how many there were
+func (w *Walker) Skip(n int) (int, error) {
+	for i := range n {
+		if _, err := w.Next(); errors.Is(err, io.EOF) {
+			return i, nil
+		} else if err != nil {
+			return i, err
+		}
+	}
+	return n, nil
+}
+
+func (w *Walker) push(sha *hashing.SHA) error {
+	if w.seen[sha.AsString()] {
+		return nil
+	}
+	w.seen[sha.AsString()] = true
+
+	obj, err := objects.ReadObject(w.repo, sha)
+	if err != nil {
+		return err
+	}
+	commit, ok := obj.(*objects.Commit)
+	if !ok {
+		return fmt.Errorf("object %s is a %s, not a commit", sha.AsString(), obj.Type())
+	}
+	committer, _ := commit.GetValue("committer")
+	heap.Push(&w.queue, &queued{
+		commit: Commit{SHA: sha, Commit: commit, When: objects.ParseIdent(committer).When},
+		seq:    w.seq,
+	})
+	w.seq++
+	return nil
+}
+
+type queued struct {
+	commit Commit
+	seq    int
+}
+
+// commitQueue is a heap of the most recent commit first
+type commitQueue []*queued
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	if !q[i].commit.When.Equal(q[j].commit.When) {
+		return q[i].commit.When.After(q[j].commit.When)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(*queued)) }
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,86 @@
+package revwalk
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// commitAt makes a commit with the given parents and committer date
+func commitAt(t *testing.T, repo *repository.Repository, when int64, parents ...*hashing.SHA) *hashing.SHA {
+	t.Helper()
+	tree := objects.EmptyTreeSHA()
+	ident := objects.Ident{Name: "Jane", Email: "jane@example.com", When: time.Unix(when, 0).UTC()}
+	sha, err := objects.CreateCommit(repo, tree, parents, ident, ident, "commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func names(t *testing.T, w *Walker, n int) []string {
+	t.Helper()
+	got := []string{}
+	for len(got) < n {
+		c, err := w.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		got = append(got, c.SHA.AsString())
+	}
+	return got
+}
+
+func TestWalk(t *testing.T) {
+	repo, err := repository.Create(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	// root - a - b - merge
+	//    \- side ---/
+	root := commitAt(t, repo, 100)
+	a := commitAt(t, repo, 200, root)
+	side := commitAt(t, repo, 250, root)
+	b := commitAt(t, repo, 300, a)
+	merge := commitAt(t, repo, 400, b, side)
+	want := []string{merge.AsString(), b.AsString(), side.AsString(), a.AsString(), root.AsString()}
+
+	w, err := New(repo, merge)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := names(t, w, 10); !slices.Equal(got, want) {
+		t.Errorf("walk = %v, want %v", got, want)
+	}
+	if cursor := w.Cursor(); cursor != "" {
+		t.Errorf("Cursor() at the end = %q, want empty", cursor)
+	}
+
+	// Pages of two, each resumed from the cursor of the one before
+	w, _ = New(repo, merge)
+	if skipped, err := w.Skip(1); err != nil || skipped != 1 {
+		t.Fatalf("Skip() = %d, %v, want 1", skipped, err)
+	}
+	got := names(t, w, 2)
+	for cursor := w.Cursor(); cursor != ""; cursor = w.Cursor() {
+		if w, err = Resume(repo, cursor); err != nil {
+			t.Fatalf("Resume() error = %v", err)
+		}
+		got = append(got, names(t, w, 2)...)
+	}
+	if !slices.Equal(got, want[1:]) {
+		t.Errorf("paged walk = %v, want %v", got, want[1:])
+	}
+
+	if _, err := Resume(repo, "not-a-cursor"); err == nil {
+		t.Error("Resume() of an invalid cursor error = nil, want an error")
+	}
+}
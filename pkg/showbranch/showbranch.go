@@ -0,0 +1,389 @@
+// Package showbranch works out how branches relate, like git show-branch:
+// which commits each of them has that the others don't, down to where
+// they all meet. The histories are walked together, newest first, and
+// every commit is marked with the branches it can be reached from.
+package showbranch
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxBranches is how many branches can be compared at once, as in git
+const MaxBranches = 26
+
+// History gives access to the commits that are walked
+type History interface {
+	// Commit returns the parents of a commit and its commit time, as a
+	// unix timestamp
+	Commit(sha string) (parents []string, when int64, err error)
+}
+
+// Commit is a commit in the history of the branches
+type Commit struct {
+	SHA     string
+	Parents []string
+	// Reach says, per branch, whether the commit can be reached from it
+	Reach []bool
+	// Name is the commit named after the first branch that reaches it,
+	// like main~2 or topic^2, or "" if it has no name
+	Name string
+}
+
+// Options change how much history Compare walks
+type Options struct {
+	// More is how many commits are shown past the first one that all
+	// branches reach
+	More int
+	// Sparse keeps the merges that only one branch reaches
+	Sparse bool
+}
+
+// uninteresting marks commits below one that all branches reach; the
+// branches have the bits above it
+const uninteresting = 1 << 1
+const revShift = 2
+
+type node struct {
+	sha     string
+	parents []string
+	when    int64
+	flags   uint
+	seen    bool
+	name    *commitName
+}
+
+// commitName names a commit after a branch, or after a parent of a merge
+// below one: it is generation first parents below head
+type commitName struct {
+	head       string
+	generation int
+}
+
+func (n *commitName) String() string {
+	switch n.generation {
+	case 0:
+		return n.head
+	case 1:
+		return n.head + "^"
+	}
+	return fmt.Sprintf("%s~%d", n.head, n.generation)
+}
+
+type walker struct {
+	history History
+	nodes   map[string]*node
+	// seen has the commits in the order they were found
+	seen []*node
+}
+
+func (w *walker) node(sha string) (*node, error) {
+	if n, ok := w.nodes[sha]; ok {
+		return n, nil
+	}
+	parents, when, err := w.history.Commit(sha)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{sha: sha, parents: parents, when: when}
+	w.nodes[sha] = n
+	return n, nil
+}
+
+// markSeen adds n to the commits that were found, unless it is there
+// already, and reports whether it did
+func (w *walker) markSeen(n *node) bool {
+	if n.seen {
+		return false
+	}
+	n.seen = true
+	w.seen = append(w.seen, n)
+	return true
+}
+
+// Compare walks the histories of the tips, newest first, until it finds
+// the commits that all of them reach, and returns the commits to show,
+// children before parents. names are the names of the tips, which name
+// the commits below them.
+func Compare(history History, tips, names []string, opts Options) ([]Commit, error) {
+	w, revs, err := walk(history, tips, opts.More)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := w.topoSort()
+	nameCommits(w, sorted, revs, names)
+
+	allRevs := (uint(1)<<(revShift+len(tips)) - 1) &^ (1<<revShift - 1)
+	extra := opts.More
+	shownMergePoint := false
+	commits := []Commit{}
+	for _, n := range sorted {
+		mergePoint := n.flags&allRevs == allRevs
+		shownMergePoint = shownMergePoint || mergePoint
+		if len(tips) > 1 && !opts.Sparse && len(n.parents) > 1 && omitInDense(n, revs) {
+			continue
+		}
+		c := Commit{SHA: n.sha, Parents: n.parents, Reach: make([]bool, len(tips))}
+		for i := range tips {
+			c.Reach[i] = n.flags&(1<<(i+revShift)) != 0
+		}
+		if n.name != nil {
+			c.Name = n.name.String()
+		}
+		commits = append(commits, c)
+		if shownMergePoint {
+			if extra--; extra < 0 {
+				break
+			}
+		}
+	}
+	return commits, nil
+}
+
+// MergeBases returns the commits that all tips reach, but that aren't
+// reachable from another such commit
+func MergeBases(history History, tips []string) ([]string, error) {
+	w, _, err := walk(history, tips, 0)
+	if err != nil {
+		return nil, err
+	}
+	allRevs := (uint(1)<<(revShift+len(tips)) - 1) &^ (1<<revShift - 1)
+	// The newest go first, like in git
+	found := []*node{}
+	for i := len(w.seen) - 1; i >= 0; i-- {
+		n := w.seen[i]
+		if n.flags&uninteresting == 0 && n.flags&allRevs == allRevs {
+			found = append(found, n)
+			n.flags |= uninteresting
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].when > found[j].when })
+	bases := make([]string, len(found))
+	for i, n := range found {
+		bases[i] = n.sha
+	}
+	return bases, nil
+}
+
+// walk marks the commits below the tips with the branches that reach
+// them, and returns the walker with the nodes of the tips
+func walk(history History, tips []string, extra int) (*walker, []*node, error) {
+	if len(tips) > MaxBranches {
+		return nil, nil, fmt.Errorf("cannot handle more than %d branches", MaxBranches)
+	}
+	w := &walker{history: history, nodes: map[string]*node{}}
+	revs := make([]*node, len(tips))
+	list := []*node{}
+	for i, tip := range tips {
+		n, err := w.node(tip)
+		if err != nil {
+			return nil, nil, err
+		}
+		flag := uint(1) << (i + revShift)
+		n.flags |= flag
+		if n.flags == flag {
+			list = insertByDate(list, n)
+		}
+		revs[i] = n
+	}
+	return w, revs, w.join(list, len(tips), extra)
+}
+
+// insertByDate adds n to list, which is sorted newest first, after the
+// commits of the same date
+func insertByDate(list []*node, n *node) []*node {
+	i := 0
+	for i < len(list) && list[i].when >= n.when {
+		i++
+	}
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = n
+	return list
+}
+
+// join walks down from the commits in list, newest first, passing the
+// branches that reach a commit on to its parents. Once all the commits
+// left are below ones that every branch reaches, the walk goes extra
+// commits further and stops.
+func (w *walker) join(list []*node, revs, extra int) error {
+	allMask := uint(1)<<(revShift+revs) - 1
+	allRevs := allMask &^ (1<<revShift - 1)
+	for len(list) > 0 {
+		stillInteresting := false
+		for _, n := range list {
+			if n.flags&uninteresting == 0 {
+				stillInteresting = true
+				break
+			}
+		}
+		n := list[0]
+		list = list[1:]
+		flags := n.flags & allMask
+		if !stillInteresting && extra <= 0 {
+			break
+		}
+		w.markSeen(n)
+		if flags&allRevs == allRevs {
+			flags |= uninteresting
+		}
+		for _, sha := range n.parents {
+			p, err := w.node(sha)
+			if err != nil {
+				return err
+			}
+			if p.flags&flags == flags {
+				continue
+			}
+			if w.markSeen(p) && !stillInteresting {
+				extra--
+			}
+			p.flags |= flags
+			list = insertByDate(list, p)
+		}
+	}
+
+	// Everything below a commit that all branches reach is
+	// uninteresting too
+	for changed := true; changed; {
+		changed = false
+		for _, n := range w.seen {
+			if n.flags&allRevs != allRevs && n.flags&uninteresting == 0 {
+				continue
+			}
+			for _, sha := range n.parents {
+				if p, ok := w.nodes[sha]; ok && p.flags&uninteresting == 0 {
+					p.flags |= uninteresting
+					changed = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// topoSort orders the commits that were found so that children come
+// before their parents. Of the commits that are ready, the one that got
+// ready last goes first, so that lines of history stay together.
+func (w *walker) topoSort() []*node {
+	indegree := map[*node]int{}
+	for _, n := range w.seen {
+		indegree[n] = 1
+	}
+	for _, n := range w.seen {
+		for _, sha := range n.parents {
+			if p, ok := w.nodes[sha]; ok && indegree[p] > 0 {
+				indegree[p]++
+			}
+		}
+	}
+	// The newest tips go first, and of tips of the same date the one
+	// found last
+	stack := []*node{}
+	for _, n := range w.seen {
+		if indegree[n] == 1 {
+			stack = append(stack, n)
+		}
+	}
+	sort.SliceStable(stack, func(i, j int) bool { return stack[i].when < stack[j].when })
+	sorted := []*node{}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, sha := range n.parents {
+			p, ok := w.nodes[sha]
+			if !ok || indegree[p] == 0 {
+				continue
+			}
+			if indegree[p]--; indegree[p] == 1 {
+				stack = append(stack, p)
+			}
+		}
+		indegree[n] = 0
+		sorted = append(sorted, n)
+	}
+	return sorted
+}
+
+// omitInDense reports whether a merge is left out: merges that only one
+// branch reaches say little, unless a branch points at them
+func omitInDense(n *node, revs []*node) bool {
+	count := 0
+	for i, rev := range revs {
+		if rev == n {
+			return false
+		}
+		if n.flags&(1<<(i+revShift)) != 0 {
+			count++
+		}
+	}
+	return count == 1
+}
+
+// nameCommits names the commits after the branches: first the tips,
+// then the first parents below them, then the other parents of merges,
+// which are named like main^2, and the first parents below those
+func nameCommits(w *walker, sorted, revs []*node, names []string) {
+	for _, n := range sorted {
+		if n.name != nil {
+			continue
+		}
+		for i, rev := range revs {
+			if rev == n {
+				n.name = &commitName{head: names[i]}
+				break
+			}
+		}
+	}
+	for named := true; named; {
+		named = false
+		for _, n := range sorted {
+			if w.nameFirstParents(n) > 0 {
+				named = true
+			}
+		}
+	}
+	for named := true; named; {
+		named = false
+		for _, n := range sorted {
+			if n.name == nil {
+				continue
+			}
+			for nth, sha := range n.parents {
+				p, ok := w.nodes[sha]
+				if !ok || p.name != nil {
+					continue
+				}
+				var head string
+				switch n.name.generation {
+				case 0:
+					head = fmt.Sprintf("%s^%d", n.name.head, nth+1)
+				case 1:
+					head = fmt.Sprintf("%s^^%d", n.name.head, nth+1)
+				default:
+					head = fmt.Sprintf("%s~%d^%d", n.name.head, n.name.generation, nth+1)
+				}
+				p.name = &commitName{head: head}
+				named = true
+				w.nameFirstParents(p)
+			}
+		}
+	}
+}
+
+// nameFirstParents names the first parents below n after it, for as long
+// as they have no name yet, and returns how many it named
+func (w *walker) nameFirstParents(n *node) int {
+	count := 0
+	for n.name != nil && len(n.parents) > 0 {
+		p, ok := w.nodes[n.parents[0]]
+		if !ok || p.name != nil {
+			break
+		}
+		p.name = &commitName{head: n.name.head, generation: n.name.generation + 1}
+		count++
+		n = p
+	}
+	return count
+}
@@ -0,0 +1,139 @@
+package showbranch
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fakeCommit is a commit of a fakeHistory
+type fakeCommit struct {
+	parents []string
+	when    int64
+}
+
+type fakeHistory map[string]fakeCommit
+
+func (h fakeHistory) Commit(sha string) ([]string, int64, error) {
+	return h[sha].parents, h[sha].when, nil
+}
+
+// history has a topic branched off main, and main merged a side branch:
+//
+//	base1 - base2 - m1 ----- merge (main)
+//	           |\          /
+//	           | s1 - s2 (side)
+//	            \
+//	             t1 - t2 - t3 (topic)
+var history = fakeHistory{
+	"base1": {nil, 60},
+	"base2": {[]string{"base1"}, 120},
+	"t1":    {[]string{"base2"}, 180},
+	"t2":    {[]string{"t1"}, 240},
+	"t3":    {[]string{"t2"}, 360},
+	"m1":    {[]string{"base2"}, 300},
+	"s1":    {[]string{"base2"}, 300},
+	"s2":    {[]string{"s1"}, 300},
+	"merge": {[]string{"m1", "s2"}, 800},
+}
+
+func TestCompare(t *testing.T) {
+	type shown struct {
+		sha, name string
+		reach     []bool
+	}
+	tests := []struct {
+		name  string
+		tips  []string
+		names []string
+		opts  Options
+		want  []shown
+	}{
+		{
+			name:  "two branches",
+			tips:  []string{"merge", "t3"},
+			names: []string{"main", "topic"},
+			want: []shown{
+				{"merge", "main", []bool{true, false}},
+				{"s2", "main^2", []bool{true, false}},
+				{"s1", "main^2^", []bool{true, false}},
+				{"m1", "main^", []bool{true, false}},
+				{"t3", "topic", []bool{false, true}},
+				{"t2", "topic^", []bool{false, true}},
+				{"t1", "topic~2", []bool{false, true}},
+				{"base2", "main~2", []bool{true, true}},
+			},
+		},
+		{
+			name:  "merges at a tip are shown",
+			tips:  []string{"merge", "s2", "t3"},
+			names: []string{"main", "side", "topic"},
+			want: []shown{
+				{"merge", "main", []bool{true, false, false}},
+				{"s2", "side", []bool{true, true, false}},
+				{"s1", "side^", []bool{true, true, false}},
+				{"m1", "main^", []bool{true, false, false}},
+				{"t3", "topic", []bool{false, false, true}},
+				{"t2", "topic^", []bool{false, false, true}},
+				{"t1", "topic~2", []bool{false, false, true}},
+				{"base2", "main~2", []bool{true, true, true}},
+			},
+		},
+		{
+			name:  "more",
+			tips:  []string{"t3", "s2"},
+			names: []string{"topic", "side"},
+			opts:  Options{More: 1},
+			want: []shown{
+				{"t3", "topic", []bool{true, false}},
+				{"t2", "topic^", []bool{true, false}},
+				{"t1", "topic~2", []bool{true, false}},
+				{"s2", "side", []bool{false, true}},
+				{"s1", "side^", []bool{false, true}},
+				{"base2", "topic~3", []bool{true, true}},
+				{"base1", "topic~4", []bool{true, true}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, err := Compare(history, tt.tips, tt.names, tt.opts)
+			if err != nil {
+				t.Fatalf("Compare() error = %v", err)
+			}
+			got := []shown{}
+			for _, c := range commits {
+				got = append(got, shown{c.SHA, c.Name, c.Reach})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeBases(t *testing.T) {
+	tests := []struct {
+		name string
+		tips []string
+		want []string
+	}{
+		{"branches", []string{"merge", "t3"}, []string{"base2"}},
+		{"ancestor", []string{"merge", "s2"}, []string{"s2"}},
+		{"same commit", []string{"t3", "t3"}, []string{"t3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeBases(history, tt.tips)
+			if err != nil {
+				t.Fatalf("MergeBases() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeBases() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := MergeBases(history, make([]string, MaxBranches+1)); err == nil {
+		t.Errorf("MergeBases() of too many branches succeeded")
+	}
+}
@@ -0,0 +1,227 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// namespace keeps signatures of git objects from being valid for anything
+// else signed with the same ssh key
+const namespace = "git"
+
+// KeySigner signs in-process with a private key, without gpg or
+// ssh-keygen. It makes SSH signatures, which git verifies with
+// gpg.format=ssh and the public key in gpg.ssh.allowedSignersFile.
+// Ed25519, ECDSA and RSA keys are supported.
+type KeySigner struct {
+	key crypto.Signer
+}
+
+func NewKeySigner(key crypto.Signer) (*KeySigner, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey, *rsa.PrivateKey:
+	case *ecdsa.PrivateKey:
+		if _, _, err := ecdsaCurve(k.Curve); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return &KeySigner{key: key}, nil
+}
+
+// ParsePrivateKey reads a PEM encoded private key, in PKCS #8, PKCS #1
+// (RSA) or SEC 1 (ECDSA) form. Keys in the OpenSSH format aren't
+// supported, use SSHSigner for those.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// PublicKey returns the public key in the authorized_keys format, as it
+// goes in an allowed signers file
+func (s *KeySigner) PublicKey() string {
+	blob := s.publicKeyBlob()
+	var keyType []byte
+	readSSHString(blob, &keyType)
+	return string(keyType) + " " + base64.StdEncoding.EncodeToString(blob)
+}
+
+// Sign makes an SSH signature in the format of ssh-keygen -Y sign, see
+// PROTOCOL.sshsig in OpenSSH
+func (s *KeySigner) Sign(payload []byte) ([]byte, error) {
+	digest := sha512.Sum512(payload)
+	var signed bytes.Buffer
+	signed.WriteString("SSHSIG")
+	writeSSHString(&signed, []byte(namespace))
+	writeSSHString(&signed, nil)
+	writeSSHString(&signed, []byte("sha512"))
+	writeSSHString(&signed, digest[:])
+
+	sig, err := s.sign(signed.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	var blob bytes.Buffer
+	blob.WriteString("SSHSIG")
+	binary.Write(&blob, binary.BigEndian, uint32(1))
+	writeSSHString(&blob, s.publicKeyBlob())
+	writeSSHString(&blob, []byte(namespace))
+	writeSSHString(&blob, nil)
+	writeSSHString(&blob, []byte("sha512"))
+	writeSSHString(&blob, sig)
+
+	// ssh-keygen wraps the base64 at 70 columns
+	encoded := base64.StdEncoding.EncodeToString(blob.Bytes())
+	var armored bytes.Buffer
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+	return armored.Bytes(), nil
+}
+
+// sign returns the signature of data in the SSH wire format
+func (s *KeySigner) sign(data []byte) ([]byte, error) {
+	var sig bytes.Buffer
+	switch k := s.key.(type) {
+	case ed25519.PrivateKey:
+		writeSSHString(&sig, []byte("ssh-ed25519"))
+		writeSSHString(&sig, ed25519.Sign(k, data))
+	case *rsa.PrivateKey:
+		digest := sha512.Sum512(data)
+		raw, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA512, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		writeSSHString(&sig, []byte("rsa-sha2-512"))
+		writeSSHString(&sig, raw)
+	case *ecdsa.PrivateKey:
+		keyType, _, _ := ecdsaCurve(k.Curve)
+		r, rs, err := ecdsa.Sign(rand.Reader, k, ecdsaDigest(k.Curve, data))
+		if err != nil {
+			return nil, err
+		}
+		var inner bytes.Buffer
+		writeSSHInt(&inner, r)
+		writeSSHInt(&inner, rs)
+		writeSSHString(&sig, []byte(keyType))
+		writeSSHString(&sig, inner.Bytes())
+	}
+	return sig.Bytes(), nil
+}
+
+func (s *KeySigner) publicKeyBlob() []byte {
+	var blob bytes.Buffer
+	switch k := s.key.(type) {
+	case ed25519.PrivateKey:
+		writeSSHString(&blob, []byte("ssh-ed25519"))
+		writeSSHString(&blob, k.Public().(ed25519.PublicKey))
+	case *rsa.PrivateKey:
+		writeSSHString(&blob, []byte("ssh-rsa"))
+		writeSSHInt(&blob, big.NewInt(int64(k.E)))
+		writeSSHInt(&blob, k.N)
+	case *ecdsa.PrivateKey:
+		keyType, curve, _ := ecdsaCurve(k.Curve)
+		point, _ := k.PublicKey.ECDH()
+		writeSSHString(&blob, []byte(keyType))
+		writeSSHString(&blob, []byte(curve))
+		writeSSHString(&blob, point.Bytes())
+	}
+	return blob.Bytes()
+}
+
+// ecdsaCurve returns the SSH key type and curve name of an ECDSA curve
+func ecdsaCurve(curve elliptic.Curve) (string, string, error) {
+	switch curve {
+	case elliptic.P256():
+		return "ecdsa-sha2-nistp256", "nistp256", nil
+	case elliptic.P384():
+		return "ecdsa-sha2-nistp384", "nistp384", nil
+	case elliptic.P521():
+		return "ecdsa-sha2-nistp521", "nistp521", nil
+	}
+	return "", "", fmt.Errorf("unsupported ECDSA curve %s", curve.Params().Name)
+}
+
+// ecdsaDigest hashes data with the hash that goes with the curve
+func ecdsaDigest(curve elliptic.Curve, data []byte) []byte {
+	switch curve {
+	case elliptic.P256():
+		digest := sha256.Sum256(data)
+		return digest[:]
+	case elliptic.P384():
+		digest := sha512.Sum384(data)
+		return digest[:]
+	}
+	digest := sha512.Sum512(data)
+	return digest[:]
+}
+
+func writeSSHString(buf *bytes.Buffer, s []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.Write(s)
+}
+
+// writeSSHInt writes a positive mpint, which has a leading zero byte if
+// the high bit is set
+func writeSSHInt(buf *bytes.Buffer, n *big.Int) {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	writeSSHString(buf, b)
+}
+
+// readSSHString reads a string from the start of data into s, and returns
+// the rest of data
+func readSSHString(data []byte, s *[]byte) []byte {
+	if len(data) < 4 {
+		return nil
+	}
+	n := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < n {
+		return nil
+	}
+	*s = data[4 : 4+n]
+	return data[4+n:]
+}
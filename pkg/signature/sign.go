@@ -0,0 +1,144 @@
+package signature
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+// FormatX509 signatures are made with X.509 certificates by gpgsm
+const FormatX509 Format = "x509"
+
+// Signer makes detached, armored signatures of commit and tag payloads,
+// in a format that git can verify. Applications that embed got can
+// provide their own.
+type Signer interface {
+	Sign(payload []byte) ([]byte, error)
+}
+
+// GPGSigner signs by calling out to gpg, or to gpgsm for X.509
+// certificates
+type GPGSigner struct {
+	Program string
+	// Key is the key id or user id to sign with. gpg uses its default key
+	// if it is empty.
+	Key string
+}
+
+func (s *GPGSigner) Sign(payload []byte) ([]byte, error) {
+	args := []string{"--status-fd=2", "-bsa"}
+	if s.Key != "" {
+		args = append(args, "-u", s.Key)
+	}
+	cmd := exec.Command(s.Program, args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	// Like git, we only trust the signature if gpg says it made one
+	if err != nil || !strings.Contains(stderr.String(), "[GNUPG:] SIG_CREATED ") {
+		return nil, fmt.Errorf("%s failed to sign the data:\n%s", s.Program, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// SSHSigner signs by calling out to ssh-keygen
+type SSHSigner struct {
+	Program string
+	// Key is the file of the private key, or of a public key whose private
+	// key is in ssh-agent. Like in user.signingKey, it can also be a
+	// public key itself, prefixed with "key::".
+	Key string
+}
+
+func (s *SSHSigner) Sign(payload []byte) ([]byte, error) {
+	keyFile := s.Key
+	args := []string{"-Y", "sign", "-n", namespace}
+	if literal, ok := strings.CutPrefix(s.Key, "key::"); ok {
+		// ssh-keygen only reads keys from files
+		f, err := os.CreateTemp("", "got-signing-key-*.pub")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(literal + "\n")
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		keyFile = f.Name()
+		args = append(args, "-U")
+	}
+
+	// The signature is written next to the signed file
+	f, err := os.CreateTemp("", "got-signing-buffer-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer os.Remove(f.Name() + ".sig")
+	_, err = f.Write(payload)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, "-f", keyFile, f.Name())
+	out, err := exec.Command(s.Program, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to sign the data:\n%s", s.Program, out)
+	}
+	return os.ReadFile(f.Name() + ".sig")
+}
+
+// NewSigner creates the signer that git would use, from gpg.format and
+// user.signingKey. The programs are gpg.program (or gpg.openpgp.program),
+// gpg.x509.program and gpg.ssh.program. Without user.signingKey, gpg signs
+// with the key of defaultKey, which is usually the committer.
+func NewSigner(cfg config.GitConfig, defaultKey string) (Signer, error) {
+	key, _ := cfg.Get("user", "signingKey")
+	format, _ := cfg.Get("gpg", "format")
+	switch Format(strings.ToLower(format)) {
+	case "", FormatOpenPGP:
+		program := "gpg"
+		if value, ok := cfg.Get(`gpg "openpgp"`, "program"); ok && value != "" {
+			program = value
+		} else if value, ok := cfg.Get("gpg", "program"); ok && value != "" {
+			program = value
+		}
+		if key == "" {
+			key = defaultKey
+		}
+		return &GPGSigner{Program: program, Key: key}, nil
+	case FormatX509:
+		program := "gpgsm"
+		if value, ok := cfg.Get(`gpg "x509"`, "program"); ok && value != "" {
+			program = value
+		}
+		if key == "" {
+			key = defaultKey
+		}
+		return &GPGSigner{Program: program, Key: key}, nil
+	case FormatSSH:
+		program := "ssh-keygen"
+		if value, ok := cfg.Get(`gpg "ssh"`, "program"); ok && value != "" {
+			program = value
+		}
+		if key == "" {
+			return nil, errors.New("user.signingKey needs to be set for ssh signing")
+		}
+		if strings.HasPrefix(key, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				key = home + key[1:]
+			}
+		}
+		return &SSHSigner{Program: program, Key: key}, nil
+	}
+	return nil, fmt.Errorf("unsupported value for gpg.format: %s", format)
+}
@@ -0,0 +1,174 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+func TestKeySigner(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not installed")
+	}
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+	}{
+		{"ed25519", edKey},
+		{"ecdsa p256", ecKey},
+		{"ecdsa p384", ec384Key},
+		{"rsa", rsaKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewKeySigner(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload := []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nmessage\n")
+			sig, err := signer.Sign(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format, _ := DetectFormat(sig); format != FormatSSH {
+				t.Fatalf("Sign() made a %q signature, want ssh", format)
+			}
+
+			// ssh-keygen has the final say on whether the signature is valid
+			dir := t.TempDir()
+			allowed := filepath.Join(dir, "allowed_signers")
+			if err := os.WriteFile(allowed, []byte("signer@example.com "+signer.PublicKey()+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			v := &Verifier{SSHProgram: "ssh-keygen", AllowedSignersFile: allowed}
+			result, err := v.Verify(payload, sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Good || result.Signer != "signer@example.com" {
+				t.Errorf("Verify() = %+v, want a good signature by signer@example.com", result)
+			}
+			if result, _ := v.Verify([]byte("other payload"), sig); result.Good {
+				t.Error("Verify() accepted the signature for another payload")
+			}
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		want    crypto.Signer
+		wantErr bool
+	}{
+		{"pkcs8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), edKey, false},
+		{"sec1", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), ecKey, false},
+		{"openssh", pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: []byte("key")}), nil, true},
+		{"not pem", []byte("not a key"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePrivateKey(tt.pem)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePrivateKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		name    string
+		config  string
+		want    Signer
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: &GPGSigner{Program: "gpg", Key: "Committer <c@example.com>"},
+		},
+		{
+			name:   "openpgp",
+			config: "[user]\n\tsigningKey = ABCD\n[gpg]\n\tprogram = gpg2\n",
+			want:   &GPGSigner{Program: "gpg2", Key: "ABCD"},
+		},
+		{
+			name:   "x509",
+			config: "[gpg]\n\tformat = x509\n",
+			want:   &GPGSigner{Program: "gpgsm", Key: "Committer <c@example.com>"},
+		},
+		{
+			name:   "ssh",
+			config: "[gpg]\n\tformat = ssh\n[gpg \"ssh\"]\n\tprogram = /usr/bin/ssh-keygen\n[user]\n\tsigningKey = /keys/id_ed25519\n",
+			want:   &SSHSigner{Program: "/usr/bin/ssh-keygen", Key: "/keys/id_ed25519"},
+		},
+		{
+			name:    "ssh without key",
+			config:  "[gpg]\n\tformat = ssh\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown format",
+			config:  "[gpg]\n\tformat = pgp\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(file, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := config.ReadWithRepository(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewSigner(cfg, "Committer <c@example.com>")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewSigner() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHSignerErrors(t *testing.T) {
+	signer := &SSHSigner{Program: "ssh-keygen", Key: filepath.Join(t.TempDir(), "missing")}
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not installed")
+	}
+	_, err := signer.Sign([]byte("payload"))
+	if err == nil || !strings.Contains(err.Error(), "failed to sign") {
+		t.Errorf("Sign() with a missing key error = %v, want a signing failure", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Signing of commits and tags, and verification of their signatures
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+type Format string
+
+const (
+	FormatOpenPGP Format = "openpgp"
+	FormatSSH     Format = "ssh"
+)
+
+// Result describes the outcome of a signature verification
+type Result struct {
+	Format Format
+	// Good is true if the signature is valid for the payload
+	Good bool
+	// Signer is the user id (OpenPGP) or principal (SSH) that made the signature
+	Signer string
+	// Output holds the raw output of the verification program
+	Output string
+}
+
+// Verifier verifies signatures by calling out to gpg or ssh-keygen
+type Verifier struct {
+	GPGProgram         string
+	SSHProgram         string
+	AllowedSignersFile string
+}
+
+// NewVerifier creates a verifier, honouring gpg.program, gpg.ssh.program
+// and gpg.ssh.allowedSignersFile from the configuration
+func NewVerifier(cfg config.GitConfig) *Verifier {
+	v := &Verifier{
+		GPGProgram: "gpg",
+		SSHProgram: "ssh-keygen",
+	}
+	if program, ok := cfg.Get("gpg", "program"); ok && program != "" {
+		v.GPGProgram = program
+	}
+	if program, ok := cfg.Get(`gpg "ssh"`, "program"); ok && program != "" {
+		v.SSHProgram = program
+	}
+	if file, ok := cfg.Get(`gpg "ssh"`, "allowedSignersFile"); ok {
+		v.AllowedSignersFile = file
+	}
+	return v
+}
+
+// DetectFormat determines the format of an armored signature
+func DetectFormat(sig []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(sig, []byte("-----BEGIN PGP SIGNATURE-----")):
+		return FormatOpenPGP, nil
+	case bytes.HasPrefix(sig, []byte("-----BEGIN SSH SIGNATURE-----")):
+		return FormatSSH, nil
+	}
+	return "", errors.New("unknown signature format")
+}
+
+// Verify checks that sig is a valid signature of payload
+func (v *Verifier) Verify(payload, sig []byte) (*Result, error) {
+	format, err := DetectFormat(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both gpg and ssh-keygen want the signature in a file
+	sigFile, err := os.CreateTemp("", "got-signature-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(sigFile.Name())
+	_, err = sigFile.Write(sig)
+	sigFile.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatSSH:
+		return v.verifySSH(payload, sigFile.Name())
+	default:
+		return v.verifyGPG(payload, sigFile.Name())
+	}
+}
+
+func (v *Verifier) verifyGPG(payload []byte, sigFile string) (*Result, error) {
+	cmd := exec.Command(v.GPGProgram, "--status-fd=1", "--keyid-format=long", "--verify", sigFile, "-")
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	status, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run %s: %w", v.GPGProgram, err)
+	}
+
+	result := &Result{
+		Format: FormatOpenPGP,
+		Output: stderr.String(),
+	}
+
+	// Status lines look like "[GNUPG:] GOODSIG <long keyid> <user id>"
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimPrefix(scanner.Text(), "[GNUPG:] "), " ", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "GOODSIG":
+			result.Good = true
+			result.Signer = fields[2]
+		case "BADSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			result.Signer = fields[2]
+		}
+	}
+	result.Good = result.Good && err == nil
+
+	return result, nil
+}
+
+func (v *Verifier) verifySSH(payload []byte, sigFile string) (*Result, error) {
+	if v.AllowedSignersFile == "" {
+		return nil, errors.New("gpg.ssh.allowedSignersFile needs to be configured to verify ssh signatures")
+	}
+
+	result := &Result{Format: FormatSSH}
+
+	// First we look up which principal made the signature
+	cmd := exec.Command(v.SSHProgram, "-Y", "find-principals", "-f", v.AllowedSignersFile, "-s", sigFile)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		result.Output = string(out)
+		return result, nil
+	}
+	principal := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+
+	// Then we verify the signature for that principal
+	cmd = exec.Command(v.SSHProgram, "-Y", "verify", "-f", v.AllowedSignersFile, "-I", principal, "-n", "git", "-s", sigFile)
+	cmd.Stdin = bytes.NewReader(payload)
+	out, err = cmd.CombinedOutput()
+	result.Output = string(out)
+	result.Signer = principal
+	result.Good = err == nil
+
+	return result, nil
+}
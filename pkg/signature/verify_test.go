@@ -0,0 +1,40 @@
+package signature
+
+import (
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		sig     string
+		want    Format
+		wantErr bool
+	}{
+		{"openpgp", "-----BEGIN PGP SIGNATURE-----\n\nabc\n-----END PGP SIGNATURE-----", FormatOpenPGP, false},
+		{"ssh", "-----BEGIN SSH SIGNATURE-----\nabc\n-----END SSH SIGNATURE-----", FormatSSH, false},
+		{"x509", "-----BEGIN SIGNED MESSAGE-----\nabc", "", true},
+		{"empty", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat([]byte(tt.sig))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DetectFormat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify_SSHRequiresAllowedSigners(t *testing.T) {
+	v := &Verifier{SSHProgram: "ssh-keygen"}
+	_, err := v.Verify([]byte("payload"), []byte("-----BEGIN SSH SIGNATURE-----\nabc\n-----END SSH SIGNATURE-----"))
+	if err == nil {
+		t.Error("Expected an error when gpg.ssh.allowedSignersFile is not configured")
+	}
+}
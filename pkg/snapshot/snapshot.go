@@ -0,0 +1,194 @@
+// Package snapshot reads the files of a snapshot of a project, like a
+// release archive, so that it can be committed. A snapshot is either a
+// directory or a tar archive, which can be compressed with gzip or bzip2.
+package snapshot
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// File is a file in a snapshot
+type File struct {
+	// Path is relative to the top of the snapshot, with slashes
+	Path string
+	// Executable files get mode 100755
+	Executable bool
+	// Symlinks have their target as data
+	Symlink bool
+	Data    []byte
+}
+
+// Snapshot is the state of a project at some time
+type Snapshot struct {
+	// Files are sorted by path
+	Files []File
+	// ModTime is the time of the newest file, which is used as the date of
+	// the snapshot when there is no other
+	ModTime time.Time
+}
+
+// Read reads a snapshot from a directory or an archive. Like git's
+// import-tars, when every file of an archive is in the same directory,
+// e.g. project-1.0/, that directory is taken as the top of the snapshot.
+// The .git directories of a directory snapshot are skipped.
+func Read(name string) (*Snapshot, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	var s *Snapshot
+	if info.IsDir() {
+		s, err = readDir(name)
+	} else {
+		s, err = readArchive(name)
+		if err == nil {
+			stripTopDir(s)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	slices.SortFunc(s.Files, func(a, b File) int { return strings.Compare(a.Path, b.Path) })
+	return s, nil
+}
+
+func (s *Snapshot) add(f File, modTime time.Time) {
+	s.Files = append(s.Files, f)
+	if modTime.After(s.ModTime) {
+		s.ModTime = modTime
+	}
+}
+
+func readDir(dir string) (*Snapshot, error) {
+	s := &Snapshot{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		f := File{Path: filepath.ToSlash(rel)}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			f.Symlink, f.Data = true, []byte(target)
+		case info.Mode().IsRegular():
+			if f.Data, err = os.ReadFile(p); err != nil {
+				return err
+			}
+			f.Executable = info.Mode()&0o111 != 0
+		default:
+			// Sockets, devices and the like can't be committed
+			return nil
+		}
+		s.add(f, info.ModTime())
+		return nil
+	})
+	return s, err
+}
+
+// readArchive reads a tar archive, which is decompressed if it starts
+// like a gzip or bzip2 file
+func readArchive(name string) (*Snapshot, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	magic, _ := r.Peek(3)
+	var archive io.Reader = r
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		archive = gz
+	case bytes.Equal(magic, []byte("BZh")):
+		archive = bzip2.NewReader(r)
+	}
+
+	s := &Snapshot{}
+	tr := tar.NewReader(archive)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		p := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if p == "." || p == ".." || strings.HasPrefix(p, "../") || path.IsAbs(p) {
+			return nil, fmt.Errorf("unsafe path in archive: %s", header.Name)
+		}
+		f := File{Path: p}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			if f.Data, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+			f.Executable = header.Mode&0o111 != 0
+		case tar.TypeSymlink:
+			f.Symlink, f.Data = true, []byte(header.Linkname)
+		default:
+			// Directories are implied by their files, and hard links,
+			// devices and the like are skipped
+			continue
+		}
+		s.add(f, header.ModTime)
+	}
+	return s, nil
+}
+
+// stripTopDir removes the directory that all files are in from their paths
+func stripTopDir(s *Snapshot) {
+	if len(s.Files) == 0 {
+		return
+	}
+	top, _, ok := strings.Cut(s.Files[0].Path, "/")
+	if !ok {
+		return
+	}
+	for _, f := range s.Files {
+		if !strings.HasPrefix(f.Path, top+"/") {
+			return
+		}
+	}
+	for i := range s.Files {
+		s.Files[i].Path = strings.TrimPrefix(s.Files[i].Path, top+"/")
+	}
+}
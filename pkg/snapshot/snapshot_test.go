@@ -0,0 +1,106 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadArchive(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "project-1.0.tar.gz")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	newest := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	headers := []struct {
+		header tar.Header
+		data   string
+	}{
+		{header: tar.Header{Name: "project-1.0/", Typeflag: tar.TypeDir, Mode: 0o755}},
+		{header: tar.Header{Name: "project-1.0/configure", Mode: 0o755, ModTime: newest}, data: "#!/bin/sh\n"},
+		{header: tar.Header{Name: "project-1.0/src/main.c", Mode: 0o644, ModTime: newest.Add(-time.Hour)}, data: "int main;\n"},
+		{header: tar.Header{Name: "project-1.0/README", Typeflag: tar.TypeSymlink, Linkname: "src/main.c"}},
+	}
+	for _, h := range headers {
+		h.header.Size = int64(len(h.data))
+		if err := tw.WriteHeader(&h.header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(h.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	file.Close()
+
+	s, err := Read(name)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []File{
+		{Path: "README", Symlink: true, Data: []byte("src/main.c")},
+		{Path: "configure", Executable: true, Data: []byte("#!/bin/sh\n")},
+		{Path: "src/main.c", Data: []byte("int main;\n")},
+	}
+	if len(s.Files) != len(want) {
+		t.Fatalf("Read() has %d files, want %d", len(s.Files), len(want))
+	}
+	for i, f := range s.Files {
+		w := want[i]
+		if f.Path != w.Path || f.Executable != w.Executable || f.Symlink != w.Symlink || string(f.Data) != string(w.Data) {
+			t.Errorf("Read() file %d = %+v, want %+v", i, f, w)
+		}
+	}
+	if !s.ModTime.Equal(newest) {
+		t.Errorf("Read() ModTime = %v, want %v", s.ModTime, newest)
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"a.txt": "a", "sub/b.txt": "b", ".git/HEAD": "ref: refs/heads/main\n"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	// A directory is never stripped, even when everything is in sub/
+	if len(s.Files) != 2 || s.Files[0].Path != "a.txt" || s.Files[1].Path != "sub/b.txt" {
+		t.Errorf("Read() files = %+v, want a.txt and sub/b.txt", s.Files)
+	}
+}
+
+func TestReadUnsafeArchive(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "evil.tar")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(file)
+	if err := tw.WriteHeader(&tar.Header{Name: "../outside", Mode: 0o644}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	file.Close()
+
+	if _, err := Read(name); err == nil {
+		t.Error("Read() error = nil, want an error for a path outside the snapshot")
+	}
+}
@@ -0,0 +1,240 @@
+// Package sparse reads and writes sparse-checkout definitions in cone
+// mode, which git keeps in info/sparse-checkout. A cone is a set of
+// directories whose files are all checked out, and the files at the top
+// and in the parents of those directories. Everything else is left out of
+// the worktree, and with a sparse index, out of the index too.
+package sparse
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/fs"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Cone is a sparse-checkout definition in cone mode
+type Cone struct {
+	// recursive are the directories whose files are all in the cone
+	recursive map[string]bool
+	// parents are the directories that lead to them, whose files are in
+	// the cone but not their subdirectories
+	parents map[string]bool
+}
+
+// NewCone returns the cone of directories, like git sparse-checkout set
+func NewCone(dirs []string) *Cone {
+	c := &Cone{recursive: map[string]bool{}, parents: map[string]bool{}}
+	for _, dir := range dirs {
+		dir = strings.Trim(path.Clean("/"+dir), "/")
+		if dir == "" {
+			continue
+		}
+		c.recursive[dir] = true
+	}
+	for dir := range c.recursive {
+		for parent := path.Dir(dir); parent != "."; parent = path.Dir(parent) {
+			c.parents[parent] = true
+		}
+	}
+	// A directory inside another one adds nothing
+	for dir := range c.recursive {
+		if c.underRecursive(path.Dir(dir)) {
+			delete(c.recursive, dir)
+		}
+	}
+	for dir := range c.parents {
+		if c.underRecursive(dir) {
+			delete(c.parents, dir)
+		}
+	}
+	return c
+}
+
+// ParseCone parses the patterns of info/sparse-checkout in cone mode:
+//
+//	/*
+//	!/*/
+//	/dir/
+//	!/dir/*/
+//	/dir/sub/
+//
+// where "!/dir/*/" makes dir a parent instead of a recursive directory.
+func ParseCone(data string) (*Cone, error) {
+	positive := []string{}
+	negative := map[string]bool{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || line == "/*" || line == "!/*/":
+		case strings.HasPrefix(line, "!/") && strings.HasSuffix(line, "/*/"):
+			negative[strings.TrimSuffix(line[2:], "/*/")] = true
+		case strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") && !hasWildcard(line):
+			positive = append(positive, strings.Trim(line, "/"))
+		default:
+			return nil, fmt.Errorf("unrecognized pattern for cone mode: '%s'", line)
+		}
+	}
+	dirs := []string{}
+	for _, dir := range positive {
+		if !negative[dir] {
+			dirs = append(dirs, unescape(dir))
+		}
+	}
+	return NewCone(dirs), nil
+}
+
+// Patterns returns the patterns of the cone, as git writes them
+func (c *Cone) Patterns() string {
+	var b strings.Builder
+	b.WriteString("/*\n!/*/\n")
+	dirs := slices.Concat(slices.Collect(maps.Keys(c.recursive)), slices.Collect(maps.Keys(c.parents)))
+	slices.Sort(dirs)
+	for _, dir := range dirs {
+		b.WriteString("/" + escape(dir) + "/\n")
+		if c.parents[dir] {
+			b.WriteString("!/" + escape(dir) + "/*/\n")
+		}
+	}
+	return b.String()
+}
+
+// Dirs returns the directories whose files are all in the cone, sorted
+func (c *Cone) Dirs() []string {
+	return slices.Sorted(maps.Keys(c.recursive))
+}
+
+// Includes reports whether a file is in the cone
+func (c *Cone) Includes(name string) bool {
+	dir := path.Dir(name)
+	return dir == "." || c.parents[dir] || c.underRecursive(dir)
+}
+
+// IncludesDir reports whether a directory has files in the cone. The
+// directories that don't can be a single entry of a sparse index.
+func (c *Cone) IncludesDir(dir string) bool {
+	dir = strings.TrimSuffix(dir, "/")
+	return dir == "" || dir == "." || c.parents[dir] || c.underRecursive(dir)
+}
+
+// underRecursive reports whether dir is one of the recursive directories
+// or in one of them
+func (c *Cone) underRecursive(dir string) bool {
+	for ; dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		if c.recursive[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// escape escapes the characters that are special in patterns
+func escape(dir string) string {
+	var b strings.Builder
+	for _, r := range dir {
+		if strings.ContainsRune(`\*?[`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// hasWildcard reports whether a pattern has a wildcard that isn't escaped
+func hasWildcard(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+func unescape(dir string) string {
+	var b strings.Builder
+	for i := 0; i < len(dir); i++ {
+		if dir[i] == '\\' && i+1 < len(dir) {
+			i++
+		}
+		b.WriteByte(dir[i])
+	}
+	return b.String()
+}
+
+// Settings are the configuration of sparse-checkout in a repository
+type Settings struct {
+	// Enabled is core.sparseCheckout
+	Enabled bool
+	// Cone is core.sparseCheckoutCone; got only supports cone mode
+	Cone bool
+	// SparseIndex is index.sparse, which makes the index sparse too
+	SparseIndex bool
+}
+
+// ReadSettings reads the sparse-checkout settings of a repository
+func ReadSettings(repo *repository.Repository) (Settings, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return Settings{}, err
+	}
+	s := Settings{}
+	s.Enabled, _ = cfg.GetBool("core", "sparseCheckout")
+	s.Cone, _ = cfg.GetBool("core", "sparseCheckoutCone")
+	s.SparseIndex, _ = cfg.GetBool("index", "sparse")
+	return s, nil
+}
+
+// WriteSettings writes the sparse-checkout settings to the repository
+// configuration
+func WriteSettings(repo *repository.Repository, s Settings) error {
+	path := repo.RepositoryPath("config")
+	for _, setting := range []struct {
+		section, key string
+		value        bool
+	}{{"core", "sparseCheckout", s.Enabled}, {"core", "sparseCheckoutCone", s.Cone}, {"index", "sparse", s.SparseIndex}} {
+		if err := config.Set(path, setting.section, setting.key, fmt.Sprint(setting.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load returns the cone of a repository, or nil if sparse-checkout isn't
+// enabled. Sparse-checkout without cone mode is not supported.
+func Load(repo *repository.Repository) (*Cone, error) {
+	s, err := ReadSettings(repo)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Enabled {
+		return nil, nil
+	}
+	if !s.Cone {
+		return nil, errors.New("got only supports sparse-checkout in cone mode")
+	}
+	data, err := os.ReadFile(repo.RepositoryPath("info", "sparse-checkout"))
+	if errors.Is(err, os.ErrNotExist) {
+		return NewCone(nil), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return ParseCone(string(data))
+}
+
+// Write writes the patterns of a cone to info/sparse-checkout
+func Write(repo *repository.Repository, c *Cone) error {
+	file, err := repo.RepositoryFile(true, "info", "sparse-checkout")
+	if err != nil {
+		return err
+	}
+	return fs.AtomicWrite(file, []byte(c.Patterns()))
+}
@@ -0,0 +1,72 @@
+package sparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+// gitPatterns is what git sparse-checkout set A/B C writes
+const gitPatterns = "/*\n!/*/\n/A/\n!/A/*/\n/A/B/\n/C/\n"
+
+func TestPatterns(t *testing.T) {
+	c := NewCone([]string{"C/", "A/B", "C/D"})
+	if got := c.Patterns(); got != gitPatterns {
+		t.Errorf("Patterns() = %q, want %q", got, gitPatterns)
+	}
+	parsed, err := ParseCone(gitPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, c) {
+		t.Errorf("ParseCone() = %+v, want %+v", parsed, c)
+	}
+	if got, want := parsed.Dirs(), []string{"A/B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dirs() = %q, want %q", got, want)
+	}
+
+	if _, err := ParseCone("/*\n!/*/\n*.txt\n"); err == nil {
+		t.Error("ParseCone() accepted a pattern that is not for cone mode")
+	}
+	escaped := NewCone([]string{"we*rd"})
+	if parsed, err := ParseCone(escaped.Patterns()); err != nil || !reflect.DeepEqual(parsed, escaped) {
+		t.Errorf("ParseCone(%q) = %+v, %v", escaped.Patterns(), parsed, err)
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	c := NewCone([]string{"A/B", "C"})
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"README", true},
+		{"A/file", true},
+		{"A/B/file", true},
+		{"A/B/deep/file", true},
+		{"A/E/file", false},
+		{"C/x/y", true},
+		{"D/file", false},
+		{"AB/file", false},
+	}
+	for _, tt := range tests {
+		if got := c.Includes(tt.name); got != tt.want {
+			t.Errorf("Includes(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	dirs := []struct {
+		dir  string
+		want bool
+	}{
+		{"A", true},
+		{"A/B/sub/", true},
+		{"A/E", false},
+		{"D/", false},
+		{"C/x", true},
+	}
+	for _, tt := range dirs {
+		if got := c.IncludesDir(tt.dir); got != tt.want {
+			t.Errorf("IncludesDir(%q) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package stripspace cleans up messages the way git does for commits and
+// tags: trailing whitespace goes, runs of blank lines become one, blank
+// lines at the start and end are removed, and the message ends with a
+// newline. Comment lines can be removed as well, or added.
+package stripspace
+
+import (
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+// DefaultCommentPrefix starts comment lines, unless core.commentChar says
+// otherwise
+const DefaultCommentPrefix = "#"
+
+// Strip cleans up text. Lines starting with commentPrefix are removed,
+// unless it is empty. A message that is left empty stays empty, without
+// a newline.
+func Strip(text, commentPrefix string) string {
+	var b strings.Builder
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		if commentPrefix != "" && strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = b.Len() > 0
+			continue
+		}
+		if blank {
+			b.WriteString("\n")
+			blank = false
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// CommentLines turns every line of text into a comment. Like git, the
+// prefix is followed by a space, except for empty lines and lines that
+// start with a tab, and the last line ends with a newline.
+func CommentLines(text, commentPrefix string) string {
+	var b strings.Builder
+	for len(text) > 0 {
+		line, rest, _ := strings.Cut(text, "\n")
+		b.WriteString(commentPrefix)
+		if line != "" && !strings.HasPrefix(line, "\t") {
+			b.WriteString(" ")
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		text = rest
+	}
+	return b.String()
+}
+
+// CommentPrefix returns the prefix of comment lines from core.commentString
+// or core.commentChar. git picks a character that isn't used in the
+// message for auto, which got doesn't, so it uses the default.
+func CommentPrefix(cfg config.GitConfig) string {
+	for _, key := range []string{"commentString", "commentChar"} {
+		if value, ok := cfg.Get("core", key); ok && value != "" && value != "auto" {
+			return value
+		}
+	}
+	return DefaultCommentPrefix
+}
@@ -0,0 +1,86 @@
+package stripspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		commentPrefix string
+		want          string
+	}{
+		{"empty", "", "", ""},
+		{"only blank lines", "\n  \n\t\n", "", ""},
+		{"collapses blank lines", "  \n\n# c\na  \n\n\n\tb\n\n", "", "# c\na\n\n\tb\n"},
+		{"adds a newline", "a", "", "a\n"},
+		{"strips comments", "  \n\n# c\na  \n\n\n\tb\n\n", "#", "a\n\n\tb\n"},
+		{"custom prefix", "# a\n;b\nc", ";", "# a\nc\n"},
+		{"keeps other whitespace", "a\n\v\nb", "", "a\n\v\nb\n"},
+		{"carriage returns", "a\r\n\r\nb\r\n", "", "a\n\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Strip(tt.text, tt.commentPrefix); got != tt.want {
+				t.Errorf("Strip(%q, %q) = %q, want %q", tt.text, tt.commentPrefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentLines(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "", ""},
+		{"lines", "x\n\n\ty\nz", "# x\n#\n#\ty\n# z\n"},
+		{"trailing newline", "x\n", "# x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CommentLines(tt.text, "#"); got != tt.want {
+				t.Errorf("CommentLines(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"default", "", "#"},
+		{"comment char", "[core]\n\tcommentChar = \";\"\n", ";"},
+		{"auto", "[core]\n\tcommentChar = auto\n", "#"},
+		{"comment string wins", "[core]\n\tcommentChar = \";\"\n\tcommentString = //\n", "//"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CommentPrefix(readConfig(t, tt.config)); got != tt.want {
+				t.Errorf("CommentPrefix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func readConfig(t *testing.T, data string) config.GitConfig {
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.ReadWithRepository(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
@@ -0,0 +1,195 @@
+// Package submodule describes how the commit that a gitlink points to
+// changed, with the commits of the submodule in between, like git diff
+// --submodule=log
+package submodule
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+	"github.com/jessegeens/got/pkg/revwalk"
+)
+
+// Change is how the commit of the submodule at Path changed. Old or New
+// is nil when the submodule is added or deleted.
+type Change struct {
+	Path     string
+	Old, New *hashing.SHA
+	// FastForward is set when New descends from Old, and Rewind when Old
+	// descends from New
+	FastForward, Rewind bool
+	// Commits are the commits that are on one side only, newest first.
+	// Like git, only first parents are followed.
+	Commits []Commit
+	// Message says why there are no commits, e.g. "(new submodule)"
+	Message string
+
+	// sub is the repository of the submodule, if it is there
+	sub *repository.Repository
+}
+
+// Commit is a commit that is only on one side of a Change
+type Commit struct {
+	// Left is set for commits that are only on the old side
+	Left    bool
+	Subject string
+}
+
+// Describe finds the commits between the old and new commit of the
+// submodule at path in the repository of the submodule
+func Describe(repo *repository.Repository, path string, old, new *hashing.SHA) (*Change, error) {
+	c := &Change{Path: path, Old: old, New: new}
+	sub, err := repo.Submodule(path)
+	if err == nil {
+		c.sub = sub
+	} else if !errors.Is(err, repository.ErrSubmoduleNotInitialized) {
+		return nil, err
+	}
+
+	switch {
+	case old == nil:
+		c.Message = "(new submodule)"
+	case new == nil:
+		c.Message = "(submodule deleted)"
+	case sub == nil:
+		c.Message = "(not initialized)"
+	case !objects.HasObject(sub, old) || !objects.HasObject(sub, new):
+		c.Message = "(commits not present)"
+	}
+	if c.Message != "" {
+		return c, nil
+	}
+
+	oldAncestors, err := ancestors(sub, old)
+	if err != nil {
+		return nil, err
+	}
+	newAncestors, err := ancestors(sub, new)
+	if err != nil {
+		return nil, err
+	}
+	c.FastForward = newAncestors[old.AsString()]
+	c.Rewind = !c.FastForward && oldAncestors[new.AsString()]
+
+	left, err := firstParents(sub, old, newAncestors)
+	if err != nil {
+		return nil, err
+	}
+	right, err := firstParents(sub, new, oldAncestors)
+	if err != nil {
+		return nil, err
+	}
+	// A walk from both sides puts the commits in date order
+	w, err := revwalk.New(sub, old, new)
+	if err != nil {
+		return nil, err
+	}
+	for remaining := len(left) + len(right); remaining > 0; {
+		commit, err := w.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		isLeft, isRight := left[commit.SHA.AsString()], right[commit.SHA.AsString()]
+		if !isLeft && !isRight {
+			continue
+		}
+		remaining--
+		subject, _, _ := strings.Cut(commit.Commit.Message(), "\n")
+		c.Commits = append(c.Commits, Commit{Left: isLeft, Subject: subject})
+	}
+	return c, nil
+}
+
+// ancestors returns the commits reachable from start, start included
+func ancestors(repo *repository.Repository, start *hashing.SHA) (map[string]bool, error) {
+	w, err := revwalk.New(repo, start)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for {
+		commit, err := w.Next()
+		if errors.Is(err, io.EOF) {
+			return seen, nil
+		} else if err != nil {
+			return nil, err
+		}
+		seen[commit.SHA.AsString()] = true
+	}
+}
+
+// firstParents returns the commits on the first-parent line from start
+// until a commit of other
+func firstParents(repo *repository.Repository, start *hashing.SHA, other map[string]bool) (map[string]bool, error) {
+	line := map[string]bool{}
+	for sha := start; sha != nil && !other[sha.AsString()]; {
+		line[sha.AsString()] = true
+		obj, err := objects.ReadObject(repo, sha)
+		if err != nil {
+			return nil, err
+		}
+		commit, ok := obj.(*objects.Commit)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a commit", sha.AsString())
+		}
+		sha = nil
+		if parent, ok := commit.GetValue("parent"); ok {
+			if sha, err = hashing.NewShaFromHex(string(parent)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return line, nil
+}
+
+// Write writes the change like git diff --submodule=log:
+//
+//	Submodule lib 1234567..89abcde:
+//	  > Add a feature
+func (c *Change) Write(w io.Writer) error {
+	sep := "..."
+	if c.FastForward || c.Rewind {
+		sep = ".."
+	}
+	header := fmt.Sprintf("Submodule %s %s%s%s", c.Path, c.short(c.Old), sep, c.short(c.New))
+	switch {
+	case c.Message != "":
+		header += " " + c.Message + "\n"
+	case c.Rewind:
+		header += " (rewind):\n"
+	default:
+		header += ":\n"
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	for _, commit := range c.Commits {
+		marker := '>'
+		if commit.Left {
+			marker = '<'
+		}
+		if _, err := fmt.Fprintf(w, "  %c %s\n", marker, commit.Subject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// short abbreviates a commit of the submodule, uniquely if we have its
+// repository
+func (c *Change) short(sha *hashing.SHA) string {
+	if sha == nil {
+		return strings.Repeat("0", objects.DefaultAbbrev)
+	}
+	if c.sub == nil {
+		return sha.AsString()[:objects.DefaultAbbrev]
+	}
+	return objects.ShortSHA(c.sub, sha, objects.AbbrevLength(c.sub))
+}
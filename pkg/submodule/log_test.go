@@ -0,0 +1,77 @@
+package submodule
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/hashing"
+	"github.com/jessegeens/got/pkg/objects"
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// commitAt makes a commit with the given subject, date and parents
+func commitAt(t *testing.T, repo *repository.Repository, subject string, when int64, parents ...*hashing.SHA) *hashing.SHA {
+	t.Helper()
+	tree := objects.EmptyTreeSHA()
+	ident := objects.Ident{Name: "Jane", Email: "jane@example.com", When: time.Unix(when, 0).UTC()}
+	sha, err := objects.CreateCommit(repo, tree, parents, ident, ident, subject+"\n\nbody\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func TestDescribe(t *testing.T) {
+	dir := t.TempDir()
+	super, err := repository.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := repository.Create(filepath.Join(dir, "lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// root - a - b
+	//    \- c
+	root := commitAt(t, sub, "root", 100)
+	a := commitAt(t, sub, "a", 200, root)
+	b := commitAt(t, sub, "b", 300, a)
+	c := commitAt(t, sub, "c", 250, root)
+	short := func(sha *hashing.SHA) string { return sha.AsString()[:7] }
+
+	tests := []struct {
+		name     string
+		path     string
+		old, new *hashing.SHA
+		want     string
+	}{
+		{"fast-forward", "lib", root, b, "Submodule lib " + short(root) + ".." + short(b) + ":\n  > b\n  > a\n"},
+		{"rewind", "lib", b, a, "Submodule lib " + short(b) + ".." + short(a) + " (rewind):\n  < b\n"},
+		{"diverged", "lib", b, c, "Submodule lib " + short(b) + "..." + short(c) + ":\n  < b\n  > c\n  < a\n"},
+		{"added", "lib", nil, a, "Submodule lib 0000000..." + short(a) + " (new submodule)\n"},
+		{"deleted", "lib", a, nil, "Submodule lib " + short(a) + "...0000000 (submodule deleted)\n"},
+		{"not initialized", "other", a, b, "Submodule other " + short(a) + "..." + short(b) + " (not initialized)\n"},
+	}
+	for _, tt := range tests {
+		change, err := Describe(super, tt.path, tt.old, tt.new)
+		if err != nil {
+			t.Fatalf("%s: Describe() error: %v", tt.name, err)
+		}
+		var out bytes.Buffer
+		if err := change.Write(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: Write() = %q, want %q", tt.name, out.String(), tt.want)
+		}
+	}
+
+	missing := hashing.NewSHA([]byte(strings.Repeat("x", 20)))
+	change, err := Describe(super, "lib", a, missing)
+	if err != nil || change.Message != "(commits not present)" {
+		t.Errorf("Describe() of a missing commit = %+v, %v", change, err)
+	}
+}
@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/pktline"
+	"github.com/jessegeens/got/pkg/references"
+)
+
+// Advertisement is what a service says it has: its refs, and the
+// capabilities that requests can ask for
+type Advertisement struct {
+	Refs         []references.Ref
+	Capabilities []string
+}
+
+// Has reports whether the service has a capability, like ofs-delta, or
+// agent for agent=<version>
+func (a *Advertisement) Has(capability string) bool {
+	_, ok := a.Value(capability)
+	return ok
+}
+
+// Value returns the value of a capability like agent=<version>, which is
+// "" for capabilities without a value
+func (a *Advertisement) Value(capability string) (string, bool) {
+	for _, c := range a.Capabilities {
+		if c == capability {
+			return "", true
+		}
+		if value, ok := strings.CutPrefix(c, capability+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Symrefs returns the targets of the symbolic refs, like HEAD, that the
+// service advertised
+func (a *Advertisement) Symrefs() map[string]string {
+	symrefs := map[string]string{}
+	for _, c := range a.Capabilities {
+		if value, ok := strings.CutPrefix(c, "symref="); ok {
+			if name, target, ok := strings.Cut(value, ":"); ok {
+				symrefs[name] = target
+			}
+		}
+	}
+	return symrefs
+}
+
+// ReadAdvertisement reads the lines of a ref advertisement, up to the
+// flush packet that ends it. The capabilities come after the first ref,
+// or after a placeholder when there are no refs, and the objects that
+// annotated tags point to come after them as <tag>^{}.
+func ReadAdvertisement(r *pktline.Reader) (*Advertisement, error) {
+	lines, typ, err := r.ReadSection()
+	if err != nil {
+		return nil, err
+	}
+	if typ != pktline.Flush {
+		return nil, fmt.Errorf("ref advertisement does not end with a flush")
+	}
+	adv := &Advertisement{Refs: []references.Ref{}, Capabilities: []string{}}
+	for i, line := range lines {
+		// Servers say which version they speak when asked for another
+		if i == 0 && line == "version 1" {
+			continue
+		}
+		if strings.HasPrefix(line, "shallow ") {
+			continue
+		}
+		line, caps, hasCaps := strings.Cut(line, "\x00")
+		if hasCaps {
+			adv.Capabilities = strings.Fields(caps)
+		}
+		sha, name, ok := strings.Cut(line, " ")
+		if !ok || !isObjectName(sha) {
+			return nil, fmt.Errorf("invalid ref advertisement line %q", line)
+		}
+		if name == "capabilities^{}" {
+			continue
+		}
+		if tag, ok := strings.CutSuffix(name, "^{}"); ok {
+			if n := len(adv.Refs); n > 0 && adv.Refs[n-1].Name.String() == tag {
+				adv.Refs[n-1].Peeled = sha
+			}
+			continue
+		}
+		adv.Refs = append(adv.Refs, references.Ref{Name: references.Reference(name), SHA: sha})
+	}
+	return adv, nil
+}
+
+// isObjectName reports whether s is a full hex object name
+func isObjectName(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/pktline"
+	"github.com/jessegeens/got/pkg/references"
+)
+
+// advertisement is what git upload-pack advertised for a repository with
+// one commit and an annotated tag
+const advertisement = "010b2e770244a2bfe3b2a4343d99c50395f789ceb3bf HEAD\x00multi_ack thin-pack side-band side-band-64k ofs-delta shallow deepen-since deepen-not deepen-relative no-progress include-tag multi_ack_detailed no-done symref=HEAD:refs/heads/main object-format=sha1 agent=git/2.39.5\n" +
+	"003d2e770244a2bfe3b2a4343d99c50395f789ceb3bf refs/heads/main\n" +
+	"003a8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762 refs/tags/v1\n" +
+	"003d2e770244a2bfe3b2a4343d99c50395f789ceb3bf refs/tags/v1^{}\n" +
+	"0000"
+
+const commitSHA = "2e770244a2bfe3b2a4343d99c50395f789ceb3bf"
+
+var advertisedRefs = []references.Ref{
+	{Name: "HEAD", SHA: commitSHA},
+	{Name: "refs/heads/main", SHA: commitSHA},
+	{Name: "refs/tags/v1", SHA: "8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762", Peeled: commitSHA},
+}
+
+func TestReadAdvertisement(t *testing.T) {
+	adv, err := ReadAdvertisement(pktline.NewReader(strings.NewReader(advertisement)))
+	if err != nil {
+		t.Fatalf("ReadAdvertisement() error = %v", err)
+	}
+	if !reflect.DeepEqual(adv.Refs, advertisedRefs) {
+		t.Errorf("ReadAdvertisement() refs = %v, want %v", adv.Refs, advertisedRefs)
+	}
+	if !adv.Has("ofs-delta") || adv.Has("ofs") || !adv.Has("agent") {
+		t.Errorf("ReadAdvertisement() capabilities = %v", adv.Capabilities)
+	}
+	if agent, _ := adv.Value("agent"); agent != "git/2.39.5" {
+		t.Errorf("Value(agent) = %q, want git/2.39.5", agent)
+	}
+	if symrefs := adv.Symrefs(); !reflect.DeepEqual(symrefs, map[string]string{"HEAD": "refs/heads/main"}) {
+		t.Errorf("Symrefs() = %v", symrefs)
+	}
+}
+
+func TestReadAdvertisementSpecialLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantRefs int
+		wantCaps []string
+		wantErr  bool
+	}{
+		{"empty repository", "004b0000000000000000000000000000000000000000 capabilities^{}\x00report-status\n0000", 0, []string{"report-status"}, false},
+		{"no refs at all", "0000", 0, []string{}, false},
+		{"version line", "000eversion 1\n003d" + commitSHA + " refs/heads/main\n0000", 1, []string{}, false},
+		{"shallow", "0035shallow " + commitSHA + "\n0000", 0, []string{}, false},
+		{"bad object name", "0018nothex refs/heads/x\n0000", 0, nil, true},
+		{"no flush", "003d" + commitSHA + " refs/heads/main\n", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adv, err := ReadAdvertisement(pktline.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAdvertisement() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(adv.Refs) != tt.wantRefs || !reflect.DeepEqual(adv.Capabilities, tt.wantCaps) {
+				t.Errorf("ReadAdvertisement() = %v with %v, want %d refs with %v", adv.Refs, adv.Capabilities, tt.wantRefs, tt.wantCaps)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/pktline"
+)
+
+// FetchRequest says which objects to fetch
+type FetchRequest struct {
+	// Wants are the commits, or other objects, to fetch with everything
+	// they reach
+	Wants []string
+	// Haves are commits that are there already, so the objects they reach
+	// aren't sent
+	Haves []string
+	// IncludeTag asks for the annotated tags that point to what is sent
+	IncludeTag bool
+	// Progress gets the progress messages of the remote. With nil, the
+	// remote is asked not to send them.
+	Progress io.Writer
+}
+
+// Fetch asks upload-pack for the objects of a request and returns the
+// pack it sends. All the haves are sent at once, so the remote doesn't
+// have to hold state between requests.
+func Fetch(t Transport, adv *Advertisement, req FetchRequest) (io.ReadCloser, error) {
+	if len(req.Wants) == 0 {
+		return nil, errors.New("nothing to fetch")
+	}
+	capabilities := fetchCapabilities(adv, req)
+	sideband := slices.ContainsFunc(capabilities, func(c string) bool { return strings.HasPrefix(c, "side-band") })
+
+	var buf bytes.Buffer
+	w := pktline.NewWriter(&buf)
+	seen := map[string]bool{}
+	for _, want := range req.Wants {
+		if seen[want] {
+			continue
+		}
+		line := "want " + want
+		if len(seen) == 0 && len(capabilities) > 0 {
+			line += " " + strings.Join(capabilities, " ")
+		}
+		seen[want] = true
+		if err := w.WriteLine(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	for _, have := range req.Haves {
+		if err := w.WriteLine("have " + have); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.WriteLine("done"); err != nil {
+		return nil, err
+	}
+
+	response, err := t.Request(UploadPack, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	// Without multi_ack, the remote answers with a NAK, or an ACK of a
+	// common commit, which newer versions of git send for every common
+	// commit, then sends the pack
+	r := pktline.NewReader(response)
+	line, _, err := r.ReadLine()
+	if err != nil {
+		response.Close()
+		return nil, fmt.Errorf("reading the fetch response: %w", err)
+	}
+	if line != "NAK" && !strings.HasPrefix(line, "ACK ") {
+		response.Close()
+		return nil, fmt.Errorf("unexpected fetch response %q", line)
+	}
+	for strings.HasPrefix(line, "ACK ") {
+		if next, err := r.Peek(8); err != nil || string(next[4:]) != "ACK " {
+			break
+		}
+		if line, _, err = r.ReadLine(); err != nil {
+			response.Close()
+			return nil, fmt.Errorf("reading the fetch response: %w", err)
+		}
+	}
+	pack := r.Rest()
+	if sideband {
+		pack = pktline.NewDemuxer(r, req.Progress)
+	}
+	return readCloser{pack, response}, nil
+}
+
+// fetchCapabilities returns the capabilities a fetch asks for, of the
+// ones the remote has
+func fetchCapabilities(adv *Advertisement, req FetchRequest) []string {
+	capabilities := []string{}
+	switch {
+	case adv.Has("side-band-64k"):
+		capabilities = append(capabilities, "side-band-64k")
+	case adv.Has("side-band"):
+		capabilities = append(capabilities, "side-band")
+	}
+	if adv.Has("ofs-delta") {
+		capabilities = append(capabilities, "ofs-delta")
+	}
+	if req.Progress == nil && adv.Has("no-progress") {
+		capabilities = append(capabilities, "no-progress")
+	}
+	if req.IncludeTag && adv.Has("include-tag") {
+		capabilities = append(capabilities, "include-tag")
+	}
+	if adv.Has("agent") {
+		capabilities = append(capabilities, "agent="+agent)
+	}
+	return capabilities
+}
+
+// agent is how got introduces itself to remotes
+const agent = "got"
+
+// readCloser reads from one reader and closes another, like a pack
+// that is read from a response
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/pack"
+	"github.com/jessegeens/got/pkg/pktline"
+)
+
+// fetchResponse is what git upload-pack --stateless-rpc answered to a
+// want of commitSHA with side-band-64k
+const fetchResponse = "0008NAK\n" +
+	"0023\x02Enumerating objects: 3, done.\n" +
+	"0022\x02Counting objects:  33% (1/3)\r" +
+	"0022\x02Counting objects:  66% (2/3)\r" +
+	"007b\x02Counting objects: 100% (3/3)\rCounting objects: 100% (3/3), done.\nTotal 3 (delta 0), reused 0 (delta 0), pack-reused 0\n" +
+	"00b7\x01PACK\x00\x00\x00\x02\x00\x00\x00\x03\x96\x07x\x9c+)JMUH6\xb1\xb4\xb04O3\xb2L\xb3\xb40\xb4L4HL2\xb305H1O5221165\xb0H4L4\xb2L1\xe5J,-\xc9\xc8/RpT\xb0It\xa8\xb0S047\x80\x02\x05m\x10\xc9\x95\x9c\x9f\x9b\x9bYR\x92\x8aG\x09W~^*\x17\x00[\x03\x1e>\xad\x01x\x9c340031QHdp\xdd\xd9\xbc\xcfl\xbb\r{\xc7\x9d9{\xb6\xe7\xecn\xf8S\xbd!\x1c\x00\x98}\x0c\xb83x\x9c\xcb\xc8\xe4\x02\x00\x02\x17\x00\xdcP\xa0\xfdNGA\x03\x80\xd8\xe9\xf0\x9e\x10s\x81\xf2\x9d\x9cT" +
+	"0006\x01 " +
+	"0000"
+
+// fakeTransport answers every request with response, and keeps the
+// requests
+type fakeTransport struct {
+	adv      *Advertisement
+	response string
+	requests []string
+}
+
+func (f *fakeTransport) Advertise(service string) (*Advertisement, error) {
+	return f.adv, nil
+}
+
+func (f *fakeTransport) Request(service string, request []byte) (io.ReadCloser, error) {
+	f.requests = append(f.requests, service+":"+string(request))
+	return io.NopCloser(strings.NewReader(f.response)), nil
+}
+
+func (f *fakeTransport) Close() error {
+	return nil
+}
+
+func TestFetch(t *testing.T) {
+	adv, err := ReadAdvertisement(pktline.NewReader(strings.NewReader(advertisement)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeTransport{adv: adv, response: fetchResponse}
+	var progress bytes.Buffer
+	r, err := Fetch(fake, adv, FetchRequest{Wants: []string{commitSHA, commitSHA}, Haves: []string{"8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762"}, Progress: &progress})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := pack.IndexStream(bytes.NewReader(data), nil)
+	if err != nil || len(entries) != 3 {
+		t.Errorf("Fetch() returned a pack of %d objects, error %v, want 3", len(entries), err)
+	}
+	if !strings.Contains(progress.String(), "Total 3") {
+		t.Errorf("Fetch() progress = %q", progress.String())
+	}
+
+	// The capabilities go on the first want, and wants are only sent once
+	want := "git-upload-pack:0054want " + commitSHA + " side-band-64k ofs-delta agent=got\n" +
+		"0000" +
+		"0032have 8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762\n" +
+		"0009done\n"
+	if len(fake.requests) != 1 || fake.requests[0] != want {
+		t.Errorf("Fetch() requests = %q, want %q", fake.requests, want)
+	}
+}
+
+func TestFetch_Acks(t *testing.T) {
+	adv, err := ReadAdvertisement(pktline.NewReader(strings.NewReader(advertisement)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Newer versions of git acknowledge every common commit
+	acks := "0031ACK 8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762\n" +
+		"0031ACK 2bd3e8c4b1f4f2e20c31bfa0cd8a0b84d8e72c64\n"
+	fake := &fakeTransport{adv: adv, response: acks + strings.TrimPrefix(fetchResponse, "0008NAK\n")}
+	r, err := Fetch(fake, adv, FetchRequest{Wants: []string{commitSHA}, Haves: []string{"8e6e0088a4e283c1c1d94eddd0c5e6a6a313d762", "2bd3e8c4b1f4f2e20c31bfa0cd8a0b84d8e72c64"}, Progress: io.Discard})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading the pack error = %v", err)
+	}
+	if entries, _, err := pack.IndexStream(bytes.NewReader(data), nil); err != nil || len(entries) != 3 {
+		t.Errorf("Fetch() returned a pack of %d objects, error %v, want 3", len(entries), err)
+	}
+}
+
+func TestFetchCapabilities(t *testing.T) {
+	adv := &Advertisement{Capabilities: []string{"side-band", "no-progress", "include-tag"}}
+	got := fetchCapabilities(adv, FetchRequest{IncludeTag: true})
+	want := []string{"side-band", "no-progress", "include-tag"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("fetchCapabilities() = %v, want %v", got, want)
+	}
+	if got := fetchCapabilities(&Advertisement{}, FetchRequest{}); len(got) != 0 {
+		t.Errorf("fetchCapabilities() of a remote without capabilities = %v", got)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	adv := &Advertisement{}
+	tests := []struct {
+		name     string
+		response string
+		wants    []string
+	}{
+		{"nothing wanted", "0008NAK\n", nil},
+		{"remote error", "0016ERR not our ref\n", []string{commitSHA}},
+		{"unexpected response", "000ahello\n", []string{commitSHA}},
+		{"no response", "", []string{commitSHA}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Fetch(&fakeTransport{response: tt.response}, adv, FetchRequest{Wants: tt.wants}); err == nil {
+				t.Errorf("Fetch() succeeded")
+			}
+		})
+	}
+}
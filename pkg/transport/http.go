@@ -0,0 +1,138 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/pktline"
+)
+
+// HTTP is a transport over the smart HTTP protocol. The advertisement of
+// a service is at info/refs?service=<service>, and every request is a
+// POST to <service>. Credentials are taken from the URL.
+type HTTP struct {
+	// base is the URL of the repository, without credentials
+	base     string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// NewHTTP returns a transport to the repository at rawURL. With a nil
+// client, http.DefaultClient is used.
+func NewHTTP(rawURL string, client *http.Client) (*HTTP, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("not an HTTP URL: %s", rawURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	h := &HTTP{client: client}
+	if u.User != nil {
+		h.user = u.User.Username()
+		h.password, _ = u.User.Password()
+		u.User = nil
+	}
+	h.base = strings.TrimSuffix(u.String(), "/")
+	return h, nil
+}
+
+func (h *HTTP) Advertise(service string) (*Advertisement, error) {
+	req, err := http.NewRequest(http.MethodGet, h.base+"/info/refs?service="+service, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Dumb servers serve info/refs as a plain file
+	if contentType(resp) != "application/x-"+service+"-advertisement" {
+		return nil, fmt.Errorf("%s is not a smart HTTP server", h.base)
+	}
+	// After a redirect, the requests go where it led
+	if final := resp.Request.URL; final != nil {
+		moved := *final
+		moved.RawQuery, moved.User = "", nil
+		if base, ok := strings.CutSuffix(moved.String(), "/info/refs"); ok {
+			h.base = base
+		}
+	}
+
+	r := pktline.NewReader(resp.Body)
+	line, _, err := r.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if line != "# service="+service {
+		return nil, fmt.Errorf("unexpected service line %q", line)
+	}
+	if _, typ, err := r.ReadPacket(); err != nil {
+		return nil, err
+	} else if typ != pktline.Flush {
+		return nil, fmt.Errorf("expected a flush after the service line")
+	}
+	return ReadAdvertisement(r)
+}
+
+func (h *HTTP) Request(service string, request []byte) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodPost, h.base+"/"+service, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-"+service+"-request")
+	req.Header.Set("Accept", "application/x-"+service+"-result")
+	resp, err := h.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if contentType(resp) != "application/x-"+service+"-result" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected content type %q from %s", contentType(resp), h.base)
+	}
+	return resp.Body, nil
+}
+
+func (h *HTTP) Close() error {
+	return nil
+}
+
+// do sends a request with the credentials, and turns error statuses into
+// errors
+func (h *HTTP) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", agent)
+	if h.user != "" || h.password != "" {
+		req.SetBasicAuth(h.user, h.password)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("authentication failed for %s", h.base)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("repository %s not found", h.base)
+	}
+	return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+}
+
+// contentType returns the media type of a response, without parameters
+func contentType(resp *http.Response) string {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// smartServer serves the advertisement and fetchResponse like git
+// http-backend does, for a repository at /repo.git
+func smartServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repo.git/info/refs", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("service") != UploadPack {
+			t.Errorf("info/refs asked for service %q", r.URL.Query().Get("service"))
+		}
+		if r.Header.Get("User-Agent") != agent {
+			t.Errorf("User-Agent = %q, want %q", r.Header.Get("User-Agent"), agent)
+		}
+		if user, password, ok := r.BasicAuth(); ok && (user != "me" || password != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		io.WriteString(w, "001e# service=git-upload-pack\n0000"+advertisement)
+	})
+	mux.HandleFunc("/repo.git/git-upload-pack", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-git-upload-pack-request" {
+			t.Errorf("upload-pack request is %s of %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+		io.WriteString(w, fetchResponse)
+	})
+	mux.HandleFunc("/moved.git/info/refs", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/repo.git/info/refs?"+r.URL.RawQuery, http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/dumb.git/info/refs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, commitSHA+"\trefs/heads/main\n")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTP(t *testing.T) {
+	server := smartServer(t)
+	for _, path := range []string{"/repo.git", "/repo.git/", "/moved.git"} {
+		t.Run(path, func(t *testing.T) {
+			h, err := NewHTTP(server.URL+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			adv, err := h.Advertise(UploadPack)
+			if err != nil {
+				t.Fatalf("Advertise() error = %v", err)
+			}
+			if !reflect.DeepEqual(adv.Refs, advertisedRefs) {
+				t.Errorf("Advertise() refs = %v, want %v", adv.Refs, advertisedRefs)
+			}
+			pack, err := Fetch(h, adv, FetchRequest{Wants: []string{commitSHA}})
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			defer pack.Close()
+			data, err := io.ReadAll(pack)
+			if err != nil || !strings.HasPrefix(string(data), "PACK") {
+				t.Errorf("Fetch() = %d bytes, error %v, want a pack", len(data), err)
+			}
+		})
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	server := smartServer(t)
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"not found", server.URL + "/missing.git", "not found"},
+		{"dumb server", server.URL + "/dumb.git", "not a smart HTTP server"},
+		{"wrong credentials", strings.Replace(server.URL, "http://", "http://me:wrong@", 1) + "/repo.git", "authentication failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewHTTP(tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := h.Advertise(UploadPack); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Advertise() error = %v, want one with %q", err, tt.want)
+			}
+		})
+	}
+
+	// Right credentials are sent along
+	h, err := NewHTTP(strings.Replace(server.URL, "http://", "http://me:secret@", 1)+"/repo.git", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Advertise(UploadPack); err != nil {
+		t.Errorf("Advertise() with credentials error = %v", err)
+	}
+	if _, err := NewHTTP("ssh://example.com/repo.git", nil); err == nil {
+		t.Errorf("NewHTTP() of an ssh URL succeeded")
+	}
+}
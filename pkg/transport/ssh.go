@@ -0,0 +1,257 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+	"github.com/jessegeens/got/pkg/pktline"
+)
+
+// SSH is a transport that runs git-upload-pack or git-receive-pack on a
+// remote host with ssh. Like git, it leaves host keys, keys and the agent
+// to ssh. Every advertisement starts a new connection, which serves one
+// request.
+type SSH struct {
+	user, host, port string
+	path             string
+	command          sshCommand
+	conn             *sshConn
+}
+
+// sshCommand says how ssh is run: a program, or a command line for the
+// shell, and which variant of ssh it is, which decides its options
+type sshCommand struct {
+	program string
+	shell   bool
+	variant string
+}
+
+// sshConn is a service running on the remote host
+type sshConn struct {
+	service   string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	reader    *pktline.Reader
+	requested bool
+}
+
+// IsSSHURL reports whether a URL is one for ssh: ssh://[user@]host/path,
+// or [user@]host:path like scp, when there is no slash before the colon
+func IsSSHURL(rawURL string) bool {
+	for _, scheme := range []string{"ssh://", "git+ssh://", "ssh+git://"} {
+		if strings.HasPrefix(rawURL, scheme) {
+			return true
+		}
+	}
+	colon := strings.Index(rawURL, ":")
+	slash := strings.Index(rawURL, "/")
+	return colon > 0 && (slash < 0 || colon < slash) && !strings.Contains(rawURL, "://") && !isDrive(rawURL)
+}
+
+// isDrive reports whether a path starts with a Windows drive letter, like
+// C:\repo, which is not a host
+func isDrive(path string) bool {
+	return len(path) >= 2 && path[1] == ':' && (len(path) == 2 || path[2] == '\\' || path[2] == '/') &&
+		strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", rune(path[0]))
+}
+
+// NewSSH returns a transport to the repository at an ssh URL. ssh is run
+// as GIT_SSH_COMMAND, core.sshCommand or GIT_SSH say, or else as ssh.
+func NewSSH(rawURL string, cfg config.GitConfig) (*SSH, error) {
+	s := &SSH{command: findSSHCommand(cfg)}
+	if scheme, rest, ok := strings.Cut(rawURL, "://"); ok {
+		u, err := url.Parse("ssh://" + rest)
+		if err != nil {
+			return nil, err
+		}
+		if scheme != "ssh" && scheme != "git+ssh" && scheme != "ssh+git" {
+			return nil, fmt.Errorf("not an ssh URL: %s", rawURL)
+		}
+		s.host, s.port, s.path = u.Hostname(), u.Port(), u.Path
+		if u.User != nil {
+			s.user = u.User.Username()
+		}
+		// ssh://host/~user/repo is relative to a home directory
+		if strings.HasPrefix(s.path, "/~") {
+			s.path = s.path[1:]
+		}
+	} else {
+		var ok bool
+		if s.host, s.path, ok = strings.Cut(rawURL, ":"); !ok {
+			return nil, fmt.Errorf("not an ssh URL: %s", rawURL)
+		}
+		if user, host, ok := strings.Cut(s.host, "@"); ok {
+			s.user, s.host = user, host
+		}
+	}
+	// They would be taken for options
+	if strings.HasPrefix(s.host, "-") || strings.HasPrefix(s.user, "-") {
+		return nil, fmt.Errorf("strange hostname '%s' blocked", s.host)
+	}
+	if strings.HasPrefix(s.path, "-") {
+		return nil, fmt.Errorf("strange pathname '%s' blocked", s.path)
+	}
+	if s.host == "" || s.path == "" {
+		return nil, fmt.Errorf("not an ssh URL: %s", rawURL)
+	}
+	return s, nil
+}
+
+// findSSHCommand returns how ssh is run, as git decides it
+func findSSHCommand(cfg config.GitConfig) sshCommand {
+	command := sshCommand{program: "ssh"}
+	if c := os.Getenv("GIT_SSH_COMMAND"); c != "" {
+		command = sshCommand{program: c, shell: true}
+	} else if c, ok := cfg.Get("core", "sshCommand"); ok && c != "" {
+		command = sshCommand{program: c, shell: true}
+	} else if c := os.Getenv("GIT_SSH"); c != "" {
+		command = sshCommand{program: c}
+	}
+
+	if variant := os.Getenv("GIT_SSH_VARIANT"); variant != "" {
+		command.variant = variant
+	} else if variant, ok := cfg.Get("ssh", "variant"); ok {
+		command.variant = variant
+	}
+	if command.variant == "" || command.variant == "auto" {
+		program := command.program
+		if command.shell {
+			program, _, _ = strings.Cut(strings.TrimSpace(program), " ")
+		}
+		switch strings.ToLower(strings.TrimSuffix(filepath.Base(program), ".exe")) {
+		case "plink":
+			command.variant = "plink"
+		case "tortoiseplink":
+			command.variant = "tortoiseplink"
+		default:
+			command.variant = "ssh"
+		}
+	}
+	return command
+}
+
+// args returns the arguments of ssh, which runs command on the remote
+func (s *SSH) args(command string) ([]string, error) {
+	args := []string{}
+	switch s.command.variant {
+	case "ssh":
+		if s.port != "" {
+			args = append(args, "-p", s.port)
+		}
+	case "plink", "putty":
+		if s.port != "" {
+			args = append(args, "-P", s.port)
+		}
+	case "tortoiseplink":
+		args = append(args, "-batch")
+		if s.port != "" {
+			args = append(args, "-P", s.port)
+		}
+	case "simple":
+		if s.port != "" {
+			return nil, errors.New("ssh variant 'simple' does not support setting port")
+		}
+	default:
+		return nil, fmt.Errorf("unknown ssh variant '%s'", s.command.variant)
+	}
+	host := s.host
+	if s.user != "" {
+		host = s.user + "@" + host
+	}
+	return append(args, host, command), nil
+}
+
+// shellQuote quotes s for the shell on the remote host
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (s *SSH) Advertise(service string) (*Advertisement, error) {
+	if err := s.Close(); err != nil {
+		return nil, err
+	}
+	args, err := s.args(service + " " + shellQuote(s.path))
+	if err != nil {
+		return nil, err
+	}
+	var cmd *exec.Cmd
+	if s.command.shell {
+		// The arguments are passed to the command line as "$@"
+		cmd = exec.Command("sh", append([]string{"-c", s.command.program + ` "$@"`, s.command.program}, args...)...)
+	} else {
+		cmd = exec.Command(s.command.program, args...)
+	}
+	// ssh asks for passwords and tells about host keys itself
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	s.conn = &sshConn{service: service, cmd: cmd, stdin: stdin, reader: pktline.NewReader(stdout)}
+
+	adv, err := ReadAdvertisement(s.conn.reader)
+	if err != nil {
+		s.Close()
+		// When ssh or the service fails, they have told why on stderr
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("could not read from remote repository %s", s.host)
+		}
+		return nil, fmt.Errorf("could not read from remote repository %s: %w", s.host, err)
+	}
+	return adv, nil
+}
+
+func (s *SSH) Request(service string, request []byte) (io.ReadCloser, error) {
+	if s.conn == nil || s.conn.service != service || s.conn.requested {
+		return nil, fmt.Errorf("a request to %s needs its own advertisement over ssh", service)
+	}
+	s.conn.requested = true
+	if _, err := s.conn.stdin.Write(request); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := s.conn.stdin.Close(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return readCloser{s.conn.reader.Rest(), closerFunc(s.Close)}, nil
+}
+
+// Close ends the connection. Without a request, the service is told that
+// nothing is wanted, with a flush packet, so it exits cleanly.
+func (s *SSH) Close() error {
+	conn := s.conn
+	if conn == nil {
+		return nil
+	}
+	s.conn = nil
+	if !conn.requested {
+		pktline.NewWriter(conn.stdin).Flush()
+		conn.stdin.Close()
+	}
+	io.Copy(io.Discard, conn.reader.Rest())
+	if err := conn.cmd.Wait(); err != nil {
+		return fmt.Errorf("ssh: %w", err)
+	}
+	return nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
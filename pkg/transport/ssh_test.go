@@ -0,0 +1,197 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+func TestIsSSHURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"ssh://host/repo.git", true},
+		{"git+ssh://git@host:22/repo.git", true},
+		{"git@host:repo.git", true},
+		{"host:/srv/repo.git", true},
+		{"https://host/repo.git", false},
+		{"/srv/repo.git", false},
+		{"./host:repo.git", false},
+		{`C:\repo`, false},
+		{"origin", false},
+	}
+	for _, tt := range tests {
+		if got := IsSSHURL(tt.url); got != tt.want {
+			t.Errorf("IsSSHURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestNewSSH(t *testing.T) {
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("GIT_SSH", "")
+	t.Setenv("GIT_SSH_VARIANT", "")
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"ssh://host/srv/repo.git", []string{"host", "git-upload-pack '/srv/repo.git'"}},
+		{"ssh://git@host:2222/repo.git", []string{"-p", "2222", "git@host", "git-upload-pack '/repo.git'"}},
+		{"ssh://host/~user/repo.git", []string{"host", "git-upload-pack '~user/repo.git'"}},
+		{"git@host:repo.git", []string{"git@host", "git-upload-pack 'repo.git'"}},
+		{"host:it's.git", []string{"host", `git-upload-pack 'it'\''s.git'`}},
+	}
+	for _, tt := range tests {
+		s, err := NewSSH(tt.url, config.GitConfig{})
+		if err != nil {
+			t.Errorf("NewSSH(%q) error = %v", tt.url, err)
+			continue
+		}
+		args, err := s.args(UploadPack + " " + shellQuote(s.path))
+		if err != nil || strings.Join(args, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("NewSSH(%q) runs ssh %q, error %v, want %q", tt.url, args, err, tt.want)
+		}
+	}
+
+	for _, url := range []string{"ssh://-oProxyCommand=x/repo", "host:-repo", "ssh://host", "ftp://host/repo"} {
+		if _, err := NewSSH(url, config.GitConfig{}); err == nil {
+			t.Errorf("NewSSH(%q) succeeded, want an error", url)
+		}
+	}
+}
+
+func TestSSHVariants(t *testing.T) {
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("GIT_SSH_VARIANT", "")
+	tests := []struct {
+		program string
+		variant string
+		want    string
+	}{
+		{"ssh", "", "-p|22|host|cmd"},
+		{"/usr/bin/plink.exe", "", "-P|22|host|cmd"},
+		{"TortoisePlink", "", "-batch|-P|22|host|cmd"},
+		{"ssh", "putty", "-P|22|host|cmd"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GIT_SSH", tt.program)
+		t.Setenv("GIT_SSH_VARIANT", tt.variant)
+		s, err := NewSSH("ssh://host:22/repo", config.GitConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		args, err := s.args("cmd")
+		if err != nil || strings.Join(args, "|") != tt.want {
+			t.Errorf("%s (variant %q) args = %q, error %v, want %q", tt.program, tt.variant, args, err, tt.want)
+		}
+	}
+
+	t.Setenv("GIT_SSH_VARIANT", "simple")
+	s, err := NewSSH("ssh://host:22/repo", config.GitConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.args("cmd"); err == nil {
+		t.Error("the simple variant took a port")
+	}
+}
+
+// fakeSSH writes a script that answers with response and keeps its
+// arguments and its input in dir
+func fakeSSH(t *testing.T, dir, response string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh is a shell script")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "response"), []byte(response), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$@\" > '" + dir + "/args'\n" +
+		"cat '" + dir + "/response'\n" +
+		"cat > '" + dir + "/request'\n"
+	program := filepath.Join(dir, "fake ssh")
+	if err := os.WriteFile(program, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestSSHFetch(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("GIT_SSH_VARIANT", "")
+	t.Setenv("GIT_SSH", fakeSSH(t, dir, advertisement+fetchResponse))
+
+	s, err := NewSSH("git@example.com:repo.git", config.GitConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adv, err := s.Advertise(UploadPack)
+	if err != nil {
+		t.Fatalf("Advertise() error = %v", err)
+	}
+	if len(adv.Refs) != len(advertisedRefs) {
+		t.Errorf("Advertise() refs = %v, want %v", adv.Refs, advertisedRefs)
+	}
+	r, err := Fetch(s, adv, FetchRequest{Wants: []string{commitSHA}})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("PACK")) {
+		t.Errorf("Fetch() over ssh returned %q, want a pack", data)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if want := "git@example.com\ngit-upload-pack 'repo.git'\n"; string(args) != want {
+		t.Errorf("ssh ran with %q, want %q", args, want)
+	}
+	request, _ := os.ReadFile(filepath.Join(dir, "request"))
+	if !strings.HasPrefix(string(request)[4:], "want "+commitSHA) || !strings.HasSuffix(string(request), "0009done\n") {
+		t.Errorf("ssh was sent %q", request)
+	}
+	if _, err := s.Request(UploadPack, nil); err == nil {
+		t.Error("Request() succeeded twice over one connection")
+	}
+}
+
+func TestSSHCommand(t *testing.T) {
+	dir := t.TempDir()
+	// The command is run by the shell, which is why the fake ssh in it
+	// is quoted
+	program := fakeSSH(t, dir, advertisement)
+	t.Setenv("GIT_SSH_COMMAND", "'"+program+"' -o BatchMode=yes")
+	t.Setenv("GIT_SSH_VARIANT", "")
+
+	s, err := NewSSH("ssh://example.com:2222/srv/repo.git", config.GitConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Advertise(UploadPack); err != nil {
+		t.Fatalf("Advertise() error = %v", err)
+	}
+	// Without a request, the service is told that nothing is wanted
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if want := "-o\nBatchMode=yes\n-p\n2222\nexample.com\ngit-upload-pack '/srv/repo.git'\n"; string(args) != want {
+		t.Errorf("ssh ran with %q, want %q", args, want)
+	}
+	if request, _ := os.ReadFile(filepath.Join(dir, "request")); string(request) != "0000" {
+		t.Errorf("ssh was sent %q, want a flush", request)
+	}
+}
@@ -0,0 +1,44 @@
+// Package transport talks to remote repositories: it reads the refs that
+// the upload-pack and receive-pack services of a remote advertise, and
+// sends them requests. Every request is a whole exchange, as in the
+// stateless protocol that smart HTTP speaks.
+package transport
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/config"
+)
+
+// The services of a remote
+const (
+	// UploadPack sends objects to fetch
+	UploadPack = "git-upload-pack"
+	// ReceivePack takes the objects that are pushed
+	ReceivePack = "git-receive-pack"
+)
+
+// Transport is a connection to a remote repository
+type Transport interface {
+	// Advertise returns the refs and capabilities that a service
+	// advertises
+	Advertise(service string) (*Advertisement, error)
+	// Request sends a request to a service, after its advertisement, and
+	// returns the response
+	Request(service string, request []byte) (io.ReadCloser, error)
+	Close() error
+}
+
+// Open returns the transport for a URL. The configuration says how ssh is
+// run.
+func Open(url string, cfg config.GitConfig) (Transport, error) {
+	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
+		return NewHTTP(url, nil)
+	}
+	if IsSSHURL(url) {
+		return NewSSH(url, cfg)
+	}
+	return nil, fmt.Errorf("unsupported URL %s", url)
+}
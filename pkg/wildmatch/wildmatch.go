@@ -0,0 +1,156 @@
+// Glob matching of paths with git's wildmatch semantics, as used
+// by gitignore and gitattributes patterns
+package wildmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled glob pattern.
+//
+//   - '*' and '?' match anything but a slash
+//   - '[...]' matches a character class, '[!...]' or '[^...]' its complement
+//   - a leading "**/" matches in all directories, a trailing "/**" matches
+//     everything inside a directory and "/**/" matches zero or more directories
+//   - a backslash escapes the next character
+type Pattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+func Compile(glob string) (*Pattern, error) {
+	return compile(glob, true)
+}
+
+// CompileText compiles glob without the rules for paths: '*' and '?' also
+// match a slash, like git's wildmatch without WM_PATHNAME. Git matches ref
+// names in the configuration this way, e.g. gc."refs/remotes/*".
+func CompileText(glob string) (*Pattern, error) {
+	return compile(glob, false)
+}
+
+func compile(glob string, pathname bool) (*Pattern, error) {
+	star, one := "[^/]*", "[^/]"
+	if !pathname {
+		star, one = ".*", "."
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if pathname && strings.HasPrefix(glob[i:], "**") {
+				atStart := i == 0 || glob[i-1] == '/'
+				rest := glob[i+2:]
+				if atStart && strings.HasPrefix(rest, "/") {
+					// "**/" matches zero or more directories
+					re.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+				if atStart && rest == "" {
+					// "/**" matches everything inside
+					re.WriteString(".*")
+					i++
+					continue
+				}
+			}
+			re.WriteString(star)
+		case '?':
+			re.WriteString(one)
+		case '[':
+			end, class, ok := parseClass(glob, i)
+			if !ok {
+				re.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			re.WriteString(class)
+			i = end
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	regex, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", glob, err)
+	}
+	return &Pattern{glob: glob, regex: regex}, nil
+}
+
+// parseClass translates the character class starting at glob[start] to a
+// regular expression. It returns the position of the closing bracket.
+func parseClass(glob string, start int) (int, string, bool) {
+	i := start + 1
+	negate := false
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		negate = true
+		i++
+	}
+
+	var class strings.Builder
+	// A closing bracket right at the start is part of the class
+	first := true
+	for ; i < len(glob); i++ {
+		c := glob[i]
+		if c == ']' && !first {
+			if negate {
+				return i, "[^/" + class.String() + "]", true
+			}
+			return i, "[" + class.String() + "]", true
+		}
+		first = false
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			class.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case '-':
+			class.WriteByte('-')
+		default:
+			class.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return 0, "", false
+}
+
+// Match reports whether path matches the pattern as a whole
+func (p *Pattern) Match(path string) bool {
+	return p.regex.MatchString(path)
+}
+
+func (p *Pattern) String() string {
+	return p.glob
+}
+
+// Match compiles glob and matches it against path.
+// Invalid patterns never match.
+func Match(glob, path string) bool {
+	pattern, err := Compile(glob)
+	if err != nil {
+		return false
+	}
+	return pattern.Match(path)
+}
+
+// MatchText compiles glob with CompileText and matches it against text.
+// Invalid patterns never match.
+func MatchText(glob, text string) bool {
+	pattern, err := CompileText(glob)
+	if err != nil {
+		return false
+	}
+	return pattern.Match(text)
+}
@@ -0,0 +1,63 @@
+package wildmatch
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.txt", "file.txt", true},
+		{"*.txt", "dir/file.txt", false},
+		{"file.?", "file.c", true},
+		{"file.?", "file.cc", false},
+		{"dir/*.c", "dir/main.c", true},
+		{"dir/*.c", "dir/sub/main.c", false},
+		{"**/foo", "foo", true},
+		{"**/foo", "a/b/foo", true},
+		{"foo/**", "foo/a/b", true},
+		{"foo/**", "foo", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/xb", false},
+		{"[abc].go", "b.go", true},
+		{"[!abc].go", "d.go", true},
+		{"[!abc].go", "a.go", false},
+		{"[a-c]x", "bx", true},
+		{"[]]", "]", true},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"a+b(c)", "a+b(c)", true},
+		{"[unterminated", "[unterminated", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchText(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    bool
+	}{
+		{"refs/remotes/*", "refs/remotes/origin/main", true},
+		{"refs/*/main", "refs/remotes/origin/main", true},
+		{"refs/heads/?", "refs/heads/a", true},
+		{"refs/heads/?", "refs/heads/a/b", false},
+		{"refs/stash", "refs/stash", true},
+		{"refs/tags/*", "refs/heads/main", false},
+		{"refs/**", "refs/heads/main", true},
+	}
+	for _, tt := range tests {
+		if got := MatchText(tt.pattern, tt.text); got != tt.want {
+			t.Errorf("MatchText(%q, %q) = %v, want %v", tt.pattern, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Cache keeps the files of a worktree in memory between walks. When it is
+// told which paths changed, like by a file watcher, only those are read
+// again, so keeping track of untracked files doesn't mean walking the
+// whole worktree every time. A Cache is not safe for concurrent use.
+type Cache struct {
+	repo    *repository.Repository
+	opts    Options
+	entries map[string]Entry
+}
+
+// NewCache walks the worktree, or the part of it below opts.Root, to fill
+// the cache
+func NewCache(repo *repository.Repository, opts Options) (*Cache, error) {
+	c := &Cache{repo: repo}
+	return c, c.Reset(opts)
+}
+
+// Reset walks the worktree again with opts, e.g. because the ignore rules
+// changed
+func (c *Cache) Reset(opts Options) error {
+	opts.IncludeDirectories = false
+	entries, err := Collect(c.repo, opts)
+	if err != nil {
+		return err
+	}
+	c.opts = opts
+	c.entries = make(map[string]Entry, len(entries))
+	for _, entry := range entries {
+		c.entries[entry.Path] = entry
+	}
+	return nil
+}
+
+// Refresh reads the given paths again, which are relative to the worktree.
+// A path that is a directory is walked, and a path that is gone is removed
+// from the cache with everything below it.
+func (c *Cache) Refresh(paths ...string) error {
+	for _, p := range paths {
+		p = path.Clean(filepath.ToSlash(p))
+		c.remove(p)
+		if !c.inScope(p) {
+			continue
+		}
+
+		fullPath := filepath.Join(c.repo.WorkTree(), p)
+		info, err := os.Lstat(fullPath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			c.entries[p] = Entry{Path: p, Info: info}
+			continue
+		}
+
+		// Nested repositories are not part of this worktree
+		if _, err := os.Lstat(filepath.Join(fullPath, ".git")); err == nil {
+			continue
+		}
+		opts := c.opts
+		opts.Root = p
+		entries, err := Collect(c.repo, opts)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			c.entries[entry.Path] = entry
+		}
+	}
+	return nil
+}
+
+// Entries returns a copy of the files in the cache
+func (c *Cache) Entries() map[string]Entry {
+	entries := make(map[string]Entry, len(c.entries))
+	for p, entry := range c.entries {
+		entries[p] = entry
+	}
+	return entries
+}
+
+// remove drops p, and everything below it if it was a directory
+func (c *Cache) remove(p string) {
+	delete(c.entries, p)
+	for other := range c.entries {
+		if strings.HasPrefix(other, p+"/") {
+			delete(c.entries, other)
+		}
+	}
+}
+
+// inScope reports whether a walk would yield p: it has to be below the
+// root, outside of the gitdir or .git file, and neither p nor one of its
+// directories can be ignored
+func (c *Cache) inScope(p string) bool {
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return false
+	}
+	root := path.Clean(filepath.ToSlash(c.opts.Root))
+	if root != "." && root != "/" && root != "" && !strings.HasPrefix(p, root+"/") {
+		return false
+	}
+	dotgit := filepath.Join(c.repo.WorkTree(), ".git")
+	for dir := p; dir != "."; dir = path.Dir(dir) {
+		fullPath := filepath.Join(c.repo.WorkTree(), dir)
+		if fullPath == dotgit || (c.opts.Ignore != nil && c.opts.Ignore.ShouldBeIgnored(dir)) {
+			return false
+		}
+	}
+	return true
+}
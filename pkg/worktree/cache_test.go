@@ -0,0 +1,100 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestCacheRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		// change is done to the worktree before paths are refreshed
+		change func(t *testing.T, dir string)
+		paths  []string
+		want   []string
+	}{
+		{
+			name:   "nothing changed",
+			change: func(t *testing.T, dir string) {},
+			paths:  []string{"b.txt"},
+			want:   []string{"a/one.txt", "a/two.txt", "b.txt"},
+		},
+		{
+			name:   "new file",
+			change: func(t *testing.T, dir string) { writeFile(t, dir, "c.txt") },
+			paths:  []string{"c.txt"},
+			want:   []string{"a/one.txt", "a/two.txt", "b.txt", "c.txt"},
+		},
+		{
+			name:   "new directory",
+			change: func(t *testing.T, dir string) { writeFile(t, dir, "d/e/f.txt") },
+			paths:  []string{"d"},
+			want:   []string{"a/one.txt", "a/two.txt", "b.txt", "d/e/f.txt"},
+		},
+		{
+			name: "removed directory",
+			change: func(t *testing.T, dir string) {
+				if err := os.RemoveAll(filepath.Join(dir, "a")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			paths: []string{"a"},
+			want:  []string{"b.txt"},
+		},
+		{
+			name:   "ignored file",
+			change: func(t *testing.T, dir string) { writeFile(t, dir, "build/out.bin") },
+			paths:  []string{"build/out.bin", "build"},
+			want:   []string{"a/one.txt", "a/two.txt", "b.txt"},
+		},
+		{
+			name:   "gitdir",
+			change: func(t *testing.T, dir string) {},
+			paths:  []string{".git/index", ".git"},
+			want:   []string{"a/one.txt", "a/two.txt", "b.txt"},
+		},
+		{
+			name: "changed outside the refreshed paths",
+			change: func(t *testing.T, dir string) {
+				writeFile(t, dir, "c.txt")
+				writeFile(t, dir, "d.txt")
+			},
+			paths: []string{"d.txt"},
+			want:  []string{"a/one.txt", "a/two.txt", "b.txt", "d.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupWorktree(t, "b.txt", "a/one.txt", "a/two.txt")
+			cache, err := NewCache(repo, Options{Ignore: prefixMatcher{"build"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t, repo.WorkTree())
+			if err := cache.Refresh(tt.paths...); err != nil {
+				t.Fatalf("Refresh() error = %v", err)
+			}
+			got := []string{}
+			for p := range cache.Entries() {
+				got = append(got, p)
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Entries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir, file string) {
+	fullPath := filepath.Join(dir, file)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,361 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Linked worktrees are registered like git does it: worktrees/<id> in the
+// gitdir of the main worktree is the gitdir of the linked worktree. Its
+// gitdir file holds the path of the .git file of the linked worktree, and
+// that .git file points back to it with "gitdir: <path>". When one of them
+// is moved, the other one has to be repaired.
+
+// Linked is a linked worktree
+type Linked struct {
+	// ID is the name of its directory below worktrees
+	ID string
+	// Path is where the worktree is, which may no longer exist
+	Path string
+	// Locked worktrees are never pruned
+	Locked bool
+}
+
+// List returns the linked worktrees of a repository, sorted by ID.
+// Registrations without a valid gitdir file are left out.
+func List(repo *repository.Repository) ([]Linked, error) {
+	ids, err := linkedIDs(repo)
+	if err != nil {
+		return nil, err
+	}
+	linked := []Linked{}
+	for _, id := range ids {
+		dotgit, err := os.ReadFile(repo.RepositoryPath("worktrees", id, "gitdir"))
+		if err != nil || strings.TrimRight(string(dotgit), "\r\n") == "" {
+			continue
+		}
+		_, err = os.Stat(repo.RepositoryPath("worktrees", id, "locked"))
+		linked = append(linked, Linked{ID: id, Path: linkedPath(repo, id, string(dotgit)), Locked: err == nil})
+	}
+	return linked, nil
+}
+
+// Pruned is a registration that was removed
+type Pruned struct {
+	ID     string
+	Reason string
+}
+
+// PruneOptions change which registrations Prune removes
+type PruneOptions struct {
+	// Expire is when worktrees that are gone expire: one whose index was
+	// modified after it is kept. The zero time means none are kept, like
+	// git worktree prune without --expire.
+	Expire time.Time
+	// DryRun only reports what would be removed
+	DryRun bool
+}
+
+// Prune removes the registrations of linked worktrees that are gone, that
+// are broken, or that are registered twice, like git worktree prune.
+// Locked worktrees are kept. The worktrees directory itself is removed
+// once it is empty.
+func Prune(repo *repository.Repository, opts PruneOptions) ([]Pruned, error) {
+	ids, err := linkedIDs(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := []Pruned{}
+	prune := func(id, reason string) error {
+		pruned = append(pruned, Pruned{ID: id, Reason: reason})
+		if opts.DryRun {
+			return nil
+		}
+		return os.RemoveAll(repo.RepositoryPath("worktrees", id))
+	}
+	// kept are the registrations that stay, with the main worktree, which
+	// has no ID, to find duplicates
+	type kept struct{ id, path string }
+	remaining := []kept{{path: filepath.Clean(repo.WorkTree())}}
+	for _, id := range ids {
+		reason, path := shouldPrune(repo, id, opts.Expire)
+		if reason != "" {
+			if err := prune(id, reason); err != nil {
+				return nil, err
+			}
+		} else if path != "" {
+			remaining = append(remaining, kept{id: id, path: path})
+		}
+	}
+
+	// Of the registrations with the same path, the main worktree or the
+	// first ID is kept
+	slices.SortStableFunc(remaining, func(a, b kept) int {
+		if a.path != b.path {
+			return strings.Compare(a.path, b.path)
+		}
+		return strings.Compare(a.id, b.id)
+	})
+	for i := 1; i < len(remaining); i++ {
+		if remaining[i].path == remaining[i-1].path {
+			if err := prune(remaining[i].id, "duplicate entry"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !opts.DryRun {
+		// Other entries may still be there, so errors are fine
+		os.Remove(repo.RepositoryPath("worktrees"))
+	}
+	return pruned, nil
+}
+
+// shouldPrune returns why the registration id should be pruned, or the
+// path of its worktree if it shouldn't. Locked worktrees have no path,
+// so they are never duplicates.
+func shouldPrune(repo *repository.Repository, id string, expire time.Time) (string, string) {
+	adminDir := repo.RepositoryPath("worktrees", id)
+	if info, err := os.Stat(adminDir); err != nil || !info.IsDir() {
+		return "not a valid directory", ""
+	}
+	if _, err := os.Stat(filepath.Join(adminDir, "locked")); err == nil {
+		return "", ""
+	}
+	dotgit, err := os.ReadFile(filepath.Join(adminDir, "gitdir"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "gitdir file does not exist", ""
+	} else if err != nil {
+		return fmt.Sprintf("unable to read gitdir file (%s)", err), ""
+	}
+	if strings.TrimRight(string(dotgit), "\r\n") == "" {
+		return "invalid gitdir file", ""
+	}
+	path := linkedPath(repo, id, string(dotgit))
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		// A worktree that was only just removed may be on a disk that
+		// isn't mounted, so it is only pruned once it expired
+		info, err := os.Stat(filepath.Join(adminDir, "index"))
+		if err != nil || expire.IsZero() || !info.ModTime().After(expire) {
+			return "gitdir file points to non-existent location", ""
+		}
+	}
+	return "", path
+}
+
+// Problem is something Repair found, and fixed unless Failed is set
+type Problem struct {
+	// Path is the file or worktree the problem is about
+	Path    string
+	Message string
+	Failed  bool
+}
+
+// Repair fixes the links between the repository and its linked worktrees,
+// like git worktree repair. The worktrees at paths, which may have been
+// moved, are registered at their new location first. Then the .git file
+// of every linked worktree is pointed to the repository again, in case
+// the repository was moved.
+func Repair(repo *repository.Repository, paths []string) ([]Problem, error) {
+	problems := []Problem{}
+	for _, p := range paths {
+		problem, err := repairAt(repo, p)
+		if err != nil {
+			return nil, err
+		}
+		if problem != nil {
+			problems = append(problems, *problem)
+		}
+	}
+
+	linked, err := List(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range linked {
+		problem, err := repairGitFile(repo, wt)
+		if err != nil {
+			return nil, err
+		}
+		if problem != nil {
+			problems = append(problems, *problem)
+		}
+	}
+	return problems, nil
+}
+
+// repairAt registers the worktree at path at its current location. If the
+// repository was moved too, its .git file no longer points to the
+// registration, which is then found by its ID.
+func repairAt(repo *repository.Repository, path string) (*Problem, error) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return &Problem{Path: path, Message: "not a valid path", Failed: true}, nil
+	}
+	realPath, err = filepath.Abs(realPath)
+	if err != nil {
+		return nil, err
+	}
+	if mainPath, err := filepath.EvalSymlinks(repo.WorkTree()); err == nil && mainPath == realPath {
+		return nil, nil
+	}
+
+	dotgit := filepath.Join(realPath, ".git")
+	adminDir, err := readGitFile(dotgit)
+	switch {
+	case errors.Is(err, errNotAFile):
+		return &Problem{Path: dotgit, Message: "unable to locate repository; .git is not a file", Failed: true}, nil
+	case errors.Is(err, errNotARepo):
+		if adminDir = inferAdminDir(repo, dotgit); adminDir == "" {
+			return &Problem{Path: dotgit, Message: "unable to locate repository; .git file does not reference a repository", Failed: true}, nil
+		}
+	case err != nil:
+		return &Problem{Path: dotgit, Message: "unable to locate repository; .git file broken", Failed: true}, nil
+	}
+
+	gitdirFile := filepath.Join(adminDir, "gitdir")
+	message := ""
+	if old, err := os.ReadFile(gitdirFile); err != nil {
+		message = "gitdir unreadable"
+	} else if strings.TrimRight(string(old), " \t\r\n") != dotgit {
+		message = "gitdir incorrect"
+	}
+	if message == "" {
+		return nil, nil
+	}
+	if err := os.WriteFile(gitdirFile, []byte(dotgit+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	return &Problem{Path: gitdirFile, Message: message}, nil
+}
+
+// repairGitFile points the .git file of a linked worktree to its
+// registration. Worktrees that are gone can't be repaired.
+func repairGitFile(repo *repository.Repository, wt Linked) (*Problem, error) {
+	info, err := os.Stat(wt.Path)
+	if err != nil {
+		return nil, nil
+	}
+	if !info.IsDir() {
+		return &Problem{Path: wt.Path, Message: "not a directory", Failed: true}, nil
+	}
+
+	adminDir, err := filepath.EvalSymlinks(repo.RepositoryPath("worktrees", wt.ID))
+	if err != nil {
+		return nil, err
+	}
+	adminDir, err = filepath.Abs(adminDir)
+	if err != nil {
+		return nil, err
+	}
+	dotgit := filepath.Join(wt.Path, ".git")
+	backlink, err := readGitFile(dotgit)
+	message := ""
+	switch {
+	case errors.Is(err, errNotAFile):
+		return &Problem{Path: wt.Path, Message: ".git is not a file", Failed: true}, nil
+	case err != nil:
+		message = ".git file broken"
+	case backlink != adminDir:
+		message = ".git file incorrect"
+	}
+	if message == "" {
+		return nil, nil
+	}
+	if err := os.WriteFile(dotgit, []byte("gitdir: "+adminDir+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	return &Problem{Path: wt.Path, Message: message}, nil
+}
+
+var (
+	errNotAFile = errors.New(".git is not a file")
+	errNotARepo = errors.New(".git file does not reference a repository")
+)
+
+// readGitFile returns the real path of the gitdir a .git file points to.
+// It fails with errNotARepo if that is not a gitdir.
+func readGitFile(dotgit string) (string, error) {
+	info, err := os.Stat(dotgit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", errNotAFile
+	}
+	contents, err := os.ReadFile(dotgit)
+	if err != nil {
+		return "", err
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimRight(string(contents), " \t\r\n"), "gitdir: ")
+	if !ok || gitdir == "" {
+		return "", fmt.Errorf("invalid gitfile format: %s", dotgit)
+	}
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(filepath.Dir(dotgit), gitdir)
+	}
+	if _, err := os.Stat(filepath.Join(gitdir, "HEAD")); err != nil {
+		return "", errNotARepo
+	}
+	gitdir, err = filepath.EvalSymlinks(gitdir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(gitdir)
+}
+
+// inferAdminDir finds the registration of a worktree whose .git file
+// points to a repository that was moved, by the ID at the end of the path
+// in it
+func inferAdminDir(repo *repository.Repository, dotgit string) string {
+	contents, err := os.ReadFile(dotgit)
+	if err != nil {
+		return ""
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimSpace(string(contents)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	id := filepath.Base(strings.TrimSpace(gitdir))
+	if id == "." || id == string(filepath.Separator) {
+		return ""
+	}
+	adminDir := repo.RepositoryPath("worktrees", id)
+	if info, err := os.Stat(adminDir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return adminDir
+}
+
+// linkedIDs returns the names of the entries below worktrees, sorted
+func linkedIDs(repo *repository.Repository) ([]string, error) {
+	entries, err := os.ReadDir(repo.RepositoryPath("worktrees"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.Name())
+	}
+	return ids, nil
+}
+
+// linkedPath returns the worktree of a gitdir file, which holds the path
+// of its .git file, relative to the registration if it isn't absolute
+func linkedPath(repo *repository.Repository, id, dotgit string) string {
+	dotgit = strings.TrimRight(dotgit, "\r\n")
+	if !filepath.IsAbs(dotgit) {
+		dotgit = filepath.Join(repo.RepositoryPath("worktrees", id), dotgit)
+	}
+	return strings.TrimSuffix(filepath.Clean(dotgit), string(filepath.Separator)+".git")
+}
@@ -0,0 +1,218 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// addLinked registers a linked worktree at path, like git worktree add
+func addLinked(t *testing.T, repo *repository.Repository, id, path string) {
+	adminDir := repo.RepositoryPath("worktrees", id)
+	if err := os.MkdirAll(adminDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(adminDir, "gitdir"): filepath.Join(path, ".git") + "\n",
+		filepath.Join(adminDir, "HEAD"):   "ref: refs/heads/" + id + "\n",
+		filepath.Join(adminDir, "index"):  "",
+		filepath.Join(path, ".git"):       "gitdir: " + adminDir + "\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name string
+		// change breaks the worktrees a, b and c
+		change func(t *testing.T, repo *repository.Repository, dir string)
+		opts   PruneOptions
+		want   []Pruned
+		// kept are the registrations that are left
+		kept []string
+	}{
+		{
+			name:   "nothing to prune",
+			change: func(t *testing.T, repo *repository.Repository, dir string) {},
+			kept:   []string{"a", "b", "c"},
+		},
+		{
+			name: "worktree is gone",
+			change: func(t *testing.T, repo *repository.Repository, dir string) {
+				os.RemoveAll(filepath.Join(dir, "a"))
+			},
+			want: []Pruned{{ID: "a", Reason: "gitdir file points to non-existent location"}},
+			kept: []string{"b", "c"},
+		},
+		{
+			name: "gone but not expired",
+			change: func(t *testing.T, repo *repository.Repository, dir string) {
+				os.RemoveAll(filepath.Join(dir, "a"))
+			},
+			opts: PruneOptions{Expire: time.Now().Add(-time.Hour)},
+			kept: []string{"a", "b", "c"},
+		},
+		{
+			name: "locked",
+			change: func(t *testing.T, repo *repository.Repository, dir string) {
+				os.RemoveAll(filepath.Join(dir, "a"))
+				os.WriteFile(repo.RepositoryPath("worktrees", "a", "locked"), nil, 0644)
+			},
+			kept: []string{"a", "b", "c"},
+		},
+		{
+			name: "broken registrations",
+			change: func(t *testing.T, repo *repository.Repository, dir string) {
+				os.Remove(repo.RepositoryPath("worktrees", "a", "gitdir"))
+				os.WriteFile(repo.RepositoryPath("worktrees", "b", "gitdir"), []byte("\n"), 0644)
+				os.WriteFile(repo.RepositoryPath("worktrees", "file"), nil, 0644)
+			},
+			want: []Pruned{
+				{ID: "a", Reason: "gitdir file does not exist"},
+				{ID: "b", Reason: "invalid gitdir file"},
+				{ID: "file", Reason: "not a valid directory"},
+			},
+			kept: []string{"c"},
+		},
+		{
+			name: "duplicates",
+			change: func(t *testing.T, repo *repository.Repository, dir string) {
+				os.WriteFile(repo.RepositoryPath("worktrees", "c", "gitdir"), []byte(filepath.Join(dir, "b", ".git")+"\n"), 0644)
+				os.WriteFile(repo.RepositoryPath("worktrees", "a", "gitdir"), []byte(filepath.Join(repo.WorkTree(), ".git")+"\n"), 0644)
+			},
+			want: []Pruned{
+				{ID: "a", Reason: "duplicate entry"},
+				{ID: "c", Reason: "duplicate entry"},
+			},
+			kept: []string{"b"},
+		},
+		{
+			name: "dry run",
+			change: func(t *testing.T, repo *repository.Repository, dir string) {
+				os.RemoveAll(filepath.Join(dir, "a"))
+			},
+			opts: PruneOptions{DryRun: true},
+			want: []Pruned{{ID: "a", Reason: "gitdir file points to non-existent location"}},
+			kept: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setupWorktree(t)
+			dir := t.TempDir()
+			for _, id := range []string{"a", "b", "c"} {
+				addLinked(t, repo, id, filepath.Join(dir, id))
+			}
+			tt.change(t, repo, dir)
+
+			got, err := Prune(repo, tt.opts)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if len(got) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Prune() = %v, want %v", got, tt.want)
+			}
+			kept, err := linkedIDs(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(kept, tt.kept) {
+				t.Errorf("kept %v, want %v", kept, tt.kept)
+			}
+		})
+	}
+}
+
+func TestPruneRemovesEmptyDirectory(t *testing.T) {
+	repo := setupWorktree(t)
+	dir := t.TempDir()
+	addLinked(t, repo, "a", filepath.Join(dir, "a"))
+	os.RemoveAll(filepath.Join(dir, "a"))
+
+	if _, err := Prune(repo, PruneOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(repo.RepositoryPath("worktrees")); !os.IsNotExist(err) {
+		t.Errorf("worktrees directory still exists: %v", err)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	repo := setupWorktree(t)
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addLinked(t, repo, "a", filepath.Join(dir, "a"))
+	addLinked(t, repo, "b", filepath.Join(dir, "b"))
+	addLinked(t, repo, "c", filepath.Join(dir, "c"))
+	adminDir := func(id string) string {
+		path, err := filepath.EvalSymlinks(repo.RepositoryPath("worktrees", id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// a was moved, the .git file of b points elsewhere and c is a
+	// directory
+	if err := os.Rename(filepath.Join(dir, "a"), filepath.Join(dir, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "b", ".git"), []byte("gitdir: "+adminDir("c")+"\n"), 0644)
+	os.Remove(filepath.Join(dir, "c", ".git"))
+	os.Mkdir(filepath.Join(dir, "c", ".git"), 0755)
+
+	got, err := Repair(repo, []string{filepath.Join(dir, "moved"), filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatalf("Repair() error = %v", err)
+	}
+	want := []Problem{
+		{Path: filepath.Join(adminDir("a"), "gitdir"), Message: "gitdir incorrect"},
+		{Path: filepath.Join(dir, "missing"), Message: "not a valid path", Failed: true},
+		{Path: filepath.Join(dir, "b"), Message: ".git file incorrect"},
+		{Path: filepath.Join(dir, "c"), Message: ".git is not a file", Failed: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Repair() = %v, want %v", got, want)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(dir, "b", ".git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "gitdir: "+adminDir("b")+"\n" {
+		t.Errorf(".git file of b = %q", contents)
+	}
+	linked, err := List(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked[0].Path != filepath.Join(dir, "moved") {
+		t.Errorf("path of a = %s, want %s", linked[0].Path, filepath.Join(dir, "moved"))
+	}
+
+	// Everything is fine now, except for c
+	got, err = Repair(repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Path != filepath.Join(dir, "c") {
+		t.Errorf("second Repair() = %v", got)
+	}
+}
@@ -0,0 +1,171 @@
+// Traversal of the files in a repository's worktree
+package worktree
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+// Matcher decides which paths are skipped during a walk.
+// *ignore.Ignore is a Matcher.
+type Matcher interface {
+	ShouldBeIgnored(path string) bool
+}
+
+type Options struct {
+	// Only walk the files below this directory, relative to the worktree.
+	// The whole worktree is walked if it is empty.
+	Root string
+	// Paths that match are skipped. Ignored directories are not descended into.
+	Ignore Matcher
+	// Also yield the directories themselves, not just the files in them
+	IncludeDirectories bool
+	// Number of directories that are read concurrently.
+	// Defaults to the number of CPUs.
+	Parallelism int
+}
+
+// Entry is a file (or directory) in the worktree
+type Entry struct {
+	// Path relative to the worktree, separated by slashes
+	Path string
+	// Result of lstat on the file
+	Info iofs.FileInfo
+}
+
+type WalkFunc func(entry Entry) error
+
+// Walk calls fn for every file in the worktree, sorted by path.
+// The .git directory and nested repositories are skipped.
+func Walk(repo *repository.Repository, opts Options, fn WalkFunc) error {
+	entries, err := Collect(repo, opts)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Collect returns every file in the worktree, sorted by path
+func Collect(repo *repository.Repository, opts Options) ([]Entry, error) {
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	root := path.Clean(filepath.ToSlash(opts.Root))
+	if root == "." || root == "/" {
+		root = ""
+	}
+
+	w := &walker{
+		worktree: repo.WorkTree(),
+		dotgit:   filepath.Join(repo.WorkTree(), ".git"),
+		opts:     opts,
+		sem:      make(chan struct{}, parallelism),
+	}
+	w.wg.Add(1)
+	go w.walkDir(root)
+	w.wg.Wait()
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	sort.Slice(w.entries, func(i, j int) bool {
+		return w.entries[i].Path < w.entries[j].Path
+	})
+	return w.entries, nil
+}
+
+type walker struct {
+	worktree string
+	// dotgit is the gitdir, or the .git file of a linked worktree
+	dotgit string
+	opts   Options
+
+	// Limits the number of directories that are read at the same time
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	// Protects entries and err
+	mu      sync.Mutex
+	entries []Entry
+	err     error
+}
+
+func (w *walker) walkDir(dir string) {
+	defer w.wg.Done()
+
+	w.sem <- struct{}{}
+	dirEntries, err := os.ReadDir(filepath.Join(w.worktree, dir))
+	<-w.sem
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	for _, d := range dirEntries {
+		relPath := path.Join(dir, d.Name())
+		fullPath := filepath.Join(w.worktree, relPath)
+
+		if fullPath == w.dotgit {
+			continue
+		}
+		// Ignore rules are applied before descending, so that
+		// large ignored directories are never read
+		if w.opts.Ignore != nil && w.opts.Ignore.ShouldBeIgnored(relPath) {
+			continue
+		}
+
+		info, err := d.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			// The file was removed while we were walking
+			continue
+		} else if err != nil {
+			w.fail(err)
+			return
+		}
+
+		if d.IsDir() {
+			// Nested repositories are not part of this worktree
+			if _, err := os.Lstat(filepath.Join(fullPath, ".git")); err == nil {
+				continue
+			}
+			if w.opts.IncludeDirectories {
+				w.add(Entry{Path: relPath, Info: info})
+			}
+			w.wg.Add(1)
+			go w.walkDir(relPath)
+			continue
+		}
+
+		w.add(Entry{Path: relPath, Info: info})
+	}
+}
+
+func (w *walker) add(entry Entry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries = append(w.entries, entry)
+}
+
+func (w *walker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
@@ -0,0 +1,111 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessegeens/got/pkg/repository"
+)
+
+type prefixMatcher []string
+
+func (m prefixMatcher) ShouldBeIgnored(path string) bool {
+	for _, prefix := range m {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func setupWorktree(t *testing.T, files ...string) *repository.Repository {
+	dir := t.TempDir()
+	repo, err := repository.Create(dir)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	for _, file := range files {
+		fullPath := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(file), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	return repo
+}
+
+func paths(entries []Entry) []string {
+	result := []string{}
+	for _, e := range entries {
+		result = append(result, e.Path)
+	}
+	return result
+}
+
+func TestCollect(t *testing.T) {
+	repo := setupWorktree(t, "b.txt", "a/one.txt", "a/two.txt", "a/deep/three.txt", "build/out.bin", "nested/.git/HEAD", "nested/file")
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "everything",
+			opts: Options{},
+			want: []string{"a/deep/three.txt", "a/one.txt", "a/two.txt", "b.txt", "build/out.bin"},
+		},
+		{
+			name: "sequential",
+			opts: Options{Parallelism: 1},
+			want: []string{"a/deep/three.txt", "a/one.txt", "a/two.txt", "b.txt", "build/out.bin"},
+		},
+		{
+			name: "ignored directory is pruned",
+			opts: Options{Ignore: prefixMatcher{"build", "a/deep"}},
+			want: []string{"a/one.txt", "a/two.txt", "b.txt"},
+		},
+		{
+			name: "subdirectory",
+			opts: Options{Root: "a"},
+			want: []string{"a/deep/three.txt", "a/one.txt", "a/two.txt"},
+		},
+		{
+			name: "with directories",
+			opts: Options{Root: "a", IncludeDirectories: true},
+			want: []string{"a/deep", "a/deep/three.txt", "a/one.txt", "a/two.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Collect(repo, tt.opts)
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if got := paths(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Collect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollect_StatInfo(t *testing.T) {
+	repo := setupWorktree(t, "file.txt")
+
+	entries, err := Collect(repo, Options{})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Info.Size() != int64(len("file.txt")) {
+		t.Errorf("Size = %d, want %d", entries[0].Info.Size(), len("file.txt"))
+	}
+}